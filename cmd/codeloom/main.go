@@ -4,19 +4,24 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/heefoo/codeloom/internal/cassette"
 	"github.com/heefoo/codeloom/internal/config"
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/httpclient"
 	"github.com/heefoo/codeloom/internal/indexer"
 	"github.com/heefoo/codeloom/internal/llm"
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/heefoo/codeloom/internal/parser"
+	"github.com/heefoo/codeloom/internal/telemetry"
 	"github.com/heefoo/codeloom/pkg/mcp"
 )
 
@@ -29,8 +34,7 @@ func main() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "index":
-			indexCmd(os.Args[2:])
-			return
+			os.Exit(indexCmd(os.Args[2:]))
 		case "version":
 			fmt.Println("codeloom v0.1.0")
 			return
@@ -48,7 +52,7 @@ func main() {
 			startCmd.Var(&transportFlag, "transport", "Transport: stdio, sse, streamable-http, both, auto")
 			startCmd.Var(&portFlag, "port", "HTTP server port")
 			startCmd.Var(&httpPathFlag, "http-path", "Streamable HTTP endpoint path")
-			startCmd.BoolVar(&watch, "watch", false, "Watch for file changes")
+			startCmd.BoolVar(&watch, "watch", false, "Resume watching directories saved by codeloom_watch")
 
 			if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") {
 				transportFlag.value = os.Args[2]
@@ -58,8 +62,7 @@ func main() {
 				startCmd.Parse(os.Args[2:])
 			}
 
-			runServer(configPath, transportFlag, portFlag, httpPathFlag, watch)
-			return
+			os.Exit(runServer(configPath, transportFlag, portFlag, httpPathFlag, watch))
 		}
 	}
 
@@ -72,10 +75,10 @@ func main() {
 	startCmd.Var(&transportFlag, "transport", "Transport: stdio, sse, streamable-http, both, auto")
 	startCmd.Var(&portFlag, "port", "HTTP server port")
 	startCmd.Var(&httpPathFlag, "http-path", "Streamable HTTP endpoint path")
-	startCmd.BoolVar(&watch, "watch", false, "Watch for file changes")
+	startCmd.BoolVar(&watch, "watch", false, "Resume watching directories saved by codeloom_watch")
 	startCmd.Parse(os.Args[1:])
 
-	runServer(configPath, transportFlag, portFlag, httpPathFlag, watch)
+	os.Exit(runServer(configPath, transportFlag, portFlag, httpPathFlag, watch))
 }
 
 type stringFlag struct {
@@ -112,29 +115,49 @@ func (f *intFlag) Set(val string) error {
 	return nil
 }
 
-func runServer(configPath string, transportFlag stringFlag, portFlag intFlag, httpPathFlag stringFlag, watch bool) {
-	_ = watch
+// runServer serves MCP until the transport stops or the process is
+// signalled, and returns the exit status. It returns rather than exiting so
+// that its deferred cleanups run: closing the log, flushing traces, saving
+// the cassette and closing the server.
+func runServer(configPath string, transportFlag stringFlag, portFlag intFlag, httpPathFlag stringFlag, watch bool) int {
 
 	// Load configuration
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
+		return 1
 	}
 
 	transport := resolveTransport(transportFlag, cfg)
 	port := resolvePort(portFlag, cfg)
 	httpPath := resolveHTTPPath(httpPathFlag, cfg)
 
+	// In stdio mode stdout belongs to the MCP protocol, so logs must not use it
+	logger, closeLog := setupLogging(cfg, transport == "stdio")
+	defer closeLog()
+
+	shutdownTracing := setupTracing(cfg, logger)
+	defer shutdownTracing()
+
+	saveCassette, err := setupCassette(cfg, logger)
+	if err != nil {
+		logger.Error("failed to open cassette", "error", err)
+		return 1
+	}
+	defer saveCassette()
+
 	// Create LLM provider
 	llmProvider, err := llm.NewProvider(cfg.LLM)
 	if err != nil {
-		log.Fatalf("Failed to create LLM provider: %v", err)
+		logger.Error("failed to create LLM provider", "error", err)
+		return 1
 	}
 
 	// Create MCP server
 	server := mcp.NewServer(mcp.ServerConfig{
 		LLM:    llmProvider,
 		Config: cfg,
+		Logger: logger,
 	})
 	defer server.Close()
 
@@ -146,35 +169,120 @@ func runServer(configPath string, transportFlag stringFlag, portFlag intFlag, ht
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigCh
-		log.Println("Shutting down...")
+		logger.Info("shutting down")
 		cancel()
 	}()
 
+	// Resume index jobs interrupted by a previous shutdown
+	go func() {
+		n, err := server.ResumeJobs(ctx)
+		if err != nil {
+			logger.Warn("failed to resume index jobs", "error", err)
+			return
+		}
+		if n > 0 {
+			logger.Info("resumed index jobs", "count", n)
+		}
+	}()
+
+	// Resume watching the directories saved by the last codeloom_watch start
+	if watch {
+		go func() {
+			dirs, err := server.ResumeWatch(ctx)
+			if err != nil {
+				logger.Warn("failed to resume watching", "error", err)
+				return
+			}
+			if len(dirs) == 0 {
+				logger.Info("no saved watch directories; use codeloom_watch to start watching")
+				return
+			}
+			logger.Info("resumed watching", "directories", dirs)
+		}()
+	}
+
 	// Start server
 	switch transport {
 	case "stdio":
-		log.Println("Starting MCP server in stdio mode...")
-		if err := server.ServeStdio(ctx); err != nil {
-			log.Fatalf("Server error: %v", err)
-		}
+		logger.Info("starting MCP server", "transport", "stdio")
+		err = server.ServeStdio(ctx)
 	case "sse":
-		log.Printf("Starting MCP server (SSE) on port %d...\n", port)
-		if err := server.ServeSSE(ctx, port); err != nil {
-			log.Fatalf("Server error: %v", err)
-		}
+		logger.Info("starting MCP server", "transport", "sse", "port", port)
+		err = server.ServeSSE(ctx, port)
 	case "streamable-http":
-		log.Printf("Starting MCP server (Streamable HTTP) on port %d, path %s...\n", port, httpPath)
-		if err := server.ServeStreamableHTTP(ctx, port, httpPath); err != nil {
-			log.Fatalf("Server error: %v", err)
-		}
+		logger.Info("starting MCP server", "transport", "streamable-http", "port", port, "path", httpPath)
+		err = server.ServeStreamableHTTP(ctx, port, httpPath)
 	case "both":
-		log.Printf("Starting MCP server (SSE + Streamable HTTP) on port %d, path %s...\n", port, httpPath)
-		if err := server.ServeHTTPMulti(ctx, port, httpPath); err != nil {
-			log.Fatalf("Server error: %v", err)
-		}
+		logger.Info("starting MCP server", "transport", "sse+streamable-http", "port", port, "path", httpPath)
+		err = server.ServeHTTPMulti(ctx, port, httpPath)
 	default:
-		log.Fatalf("Unknown transport: %s", transport)
+		logger.Error("unknown transport", "transport", transport)
+		return 1
+	}
+	if err != nil {
+		logger.Error("server error", "error", err)
+		return 1
 	}
+	return 0
+}
+
+// setupLogging installs the configured structured logger as the process
+// default. If the configuration is invalid it falls back to text on stderr.
+func setupLogging(cfg *config.Config, stdio bool) (*slog.Logger, func()) {
+	logger, closer, err := logging.Setup(cfg.Logging, logging.Options{Stdio: stdio})
+	if err != nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+		slog.SetDefault(logger)
+		logger.Warn("invalid logging configuration, using defaults", "error", err)
+		return logger, func() {}
+	}
+	return logger, func() { _ = closer() }
+}
+
+// setupTracing installs the configured trace exporter. Failures only disable
+// tracing; the returned function flushes pending spans.
+func setupTracing(cfg *config.Config, logger *slog.Logger) func() {
+	shutdown, err := telemetry.Setup(context.Background(), cfg.Telemetry)
+	if err != nil {
+		logger.Warn("tracing disabled", "error", err)
+		return func() {}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logger.Warn("failed to flush traces", "error", err)
+		}
+	}
+}
+
+// setupCassette records or replays provider HTTP traffic when
+// CODELOOM_CASSETTE names a cassette file; CODELOOM_CASSETTE_MODE is record,
+// replay or auto (the default). The returned function saves a recording.
+func setupCassette(cfg *config.Config, logger *slog.Logger) (func(), error) {
+	path := os.Getenv("CODELOOM_CASSETTE")
+	if path == "" {
+		return func() {}, nil
+	}
+	mode := cassette.Mode(os.Getenv("CODELOOM_CASSETTE_MODE"))
+	if mode == "" {
+		mode = cassette.ModeAuto
+	}
+	secrets := []string{cfg.LLM.APIKey, cfg.Embedding.APIKey}
+	for _, p := range cfg.LLM.Providers {
+		secrets = append(secrets, p.APIKey)
+	}
+	tr, err := cassette.New(path, mode, httpclient.Transport(), cassette.WithSecrets(secrets...))
+	if err != nil {
+		return nil, fmt.Errorf("cassette %s in %s mode: %w", path, mode, err)
+	}
+	httpclient.SetTransport(tr)
+	logger.Info("using cassette for provider traffic", "mode", tr.Mode(), "path", path)
+	return func() {
+		if err := tr.Save(); err != nil {
+			logger.Warn("failed to save cassette", "mode", tr.Mode(), "path", path, "error", err)
+		}
+	}, nil
 }
 
 func resolveTransport(flagVal stringFlag, cfg *config.Config) string {
@@ -260,7 +368,8 @@ func stdinIsTTY() bool {
 	return (info.Mode() & os.ModeCharDevice) != 0
 }
 
-func indexCmd(args []string) {
+// indexCmd indexes a directory and returns the exit status, like runServer
+func indexCmd(args []string) int {
 	// Parse index-specific flags
 	indexFlags := flag.NewFlagSet("index", flag.ExitOnError)
 	configPath := indexFlags.String("config", "", "Path to config file")
@@ -270,7 +379,7 @@ func indexCmd(args []string) {
 
 	if err := indexFlags.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
 	remaining := indexFlags.Args()
@@ -278,7 +387,7 @@ func indexCmd(args []string) {
 		fmt.Println("Usage: codeloom index [options] <directory>")
 		fmt.Println("\nOptions:")
 		indexFlags.PrintDefaults()
-		os.Exit(1)
+		return 1
 	}
 
 	dir := remaining[0]
@@ -287,11 +396,28 @@ func indexCmd(args []string) {
 	// Load configuration
 	cfg, err := config.Load(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		slog.Error("failed to load config", "error", err)
+		return 1
+	}
+
+	if *verbose {
+		cfg.Logging.Level = "debug"
+	}
+	logger, closeLog := setupLogging(cfg, false)
+	defer closeLog()
+
+	shutdownTracing := setupTracing(cfg, logger)
+	defer shutdownTracing()
+
+	saveCassette, err := setupCassette(cfg, logger)
+	if err != nil {
+		logger.Error("failed to open cassette", "error", err)
+		return 1
 	}
+	defer saveCassette()
 
 	// Create parser
-	p := parser.NewParser()
+	p := parser.NewParser(parser.WithCompileCommands(cfg.Server.CompileCommands))
 
 	// Create storage
 	storage, err := graph.NewStorage(graph.StorageConfig{
@@ -300,9 +426,11 @@ func indexCmd(args []string) {
 		Database:  cfg.Database.SurrealDB.Database,
 		Username:  cfg.Database.SurrealDB.Username,
 		Password:  cfg.Database.SurrealDB.Password,
+		Logger:    logger,
 	})
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logger.Error("failed to connect to database", "error", err)
+		return 1
 	}
 	defer storage.Close()
 
@@ -311,8 +439,7 @@ func indexCmd(args []string) {
 	if !*noEmbeddings {
 		embProvider, err = embedding.NewProvider(cfg.Embedding)
 		if err != nil {
-			log.Printf("Warning: embedding provider not available: %v", err)
-			log.Println("Continuing without embeddings (semantic search will be limited)")
+			logger.Warn("embedding provider not available; continuing without embeddings, semantic search will be limited", "error", err)
 		}
 	}
 
@@ -322,12 +449,21 @@ func indexCmd(args []string) {
 		excludePatterns = append(excludePatterns, strings.Split(*exclude, ",")...)
 	}
 
+	documents, err := embedding.NewDocumentBuilder(cfg.Embedding)
+	if err != nil {
+		logger.Error("invalid embedding configuration", "error", err)
+		return 1
+	}
+
 	// Create indexer
 	idx := indexer.New(indexer.Config{
 		Parser:          p,
 		Storage:         storage,
 		Embedding:       embProvider,
+		Documents:       documents,
 		ExcludePatterns: excludePatterns,
+		ErrorThreshold:  cfg.Server.ParseErrorThreshold,
+		Logger:          logger,
 	})
 
 	// Create context with cancellation
@@ -353,7 +489,8 @@ func indexCmd(args []string) {
 	fmt.Println("Starting indexing...")
 
 	if err := idx.IndexDirectory(ctx, dir, progressCb); err != nil {
-		log.Fatalf("Indexing failed: %v", err)
+		logger.Error("indexing failed", "error", err)
+		return 1
 	}
 
 	// Print final status
@@ -388,6 +525,7 @@ func indexCmd(args []string) {
 			}
 		}
 	}
+	return 0
 }
 
 func printHelp() {
@@ -410,7 +548,7 @@ Server Options:
   --transport     Transport: stdio, sse, streamable-http, both, auto (default: sse)
   --port          HTTP server port (default: from config or 3003)
   --http-path     Streamable HTTP endpoint path (default: from config or /mcp)
-  --watch         Watch for file changes
+  --watch         Resume watching the directories saved by codeloom_watch
 
 Examples:
   codeloom index ./src                     Index src directory
@@ -433,5 +571,11 @@ Environment Variables:
   CODELOOM_SURREALDB_URL          SurrealDB connection URL
   CODELOOM_TRANSPORT              Transport override (stdio, sse, streamable-http, auto)
   CODELOOM_HTTP_PATH              Streamable HTTP path override
+  CODELOOM_TRACE_EXPORTER         Trace exporter (none, otlp, file)
+  CODELOOM_TRACE_FILE             Output file for the file trace exporter
+  OTEL_EXPORTER_OTLP_ENDPOINT     OTLP/HTTP collector endpoint
+  CODELOOM_LOG_LEVEL              Log level (debug, info, warn, error)
+  CODELOOM_LOG_FORMAT             Log format (text, json)
+  CODELOOM_LOG_FILE               Log sink (stderr, stdout, or a file path)
 `)
 }
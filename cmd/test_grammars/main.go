@@ -21,6 +21,15 @@ func main() {
 		parser.LangClojure,
 		parser.LangJulia,
 		parser.LangCommonLisp,
+		parser.LangRuby,
+		parser.LangPHP,
+		parser.LangCSharp,
+		parser.LangKotlin,
+		parser.LangSwift,
+		parser.LangScala,
+		parser.LangLua,
+		parser.LangBash,
+		parser.LangElixir,
 	}
 
 	for _, lang := range langs {
@@ -98,6 +98,14 @@ func (a *Agent) Execute(ctx context.Context, query string) (*AgentOutput, error)
 
 		// Check if LLM wants to use a tool
 		if len(response.ToolCalls) > 0 {
+			// One assistant turn holds all of its calls, followed by one
+			// result per call, so providers can pair them up
+			messages = append(messages, llm.Message{
+				Role:      llm.RoleAssistant,
+				Content:   response.Content,
+				ToolCalls: response.ToolCalls,
+			})
+
 			for _, tc := range response.ToolCalls {
 				output.ToolUseCount++
 
@@ -107,12 +115,6 @@ func (a *Agent) Execute(ctx context.Context, query string) (*AgentOutput, error)
 					toolResult = fmt.Sprintf("Error: %v", err)
 				}
 
-				// Add assistant message with tool call
-				messages = append(messages, llm.Message{
-					Role:    llm.RoleAssistant,
-					Content: response.Content,
-				})
-
 				// Add tool result
 				messages = append(messages, llm.Message{
 					Role:       llm.RoleTool,
@@ -200,10 +202,12 @@ func (a *Agent) executeTool(ctx context.Context, tc llm.ToolCall) (string, error
 		return "", fmt.Errorf("unknown tool: %s", tc.Name)
 	}
 
-	// Parse arguments
-	var args map[string]interface{}
-	if err := json.Unmarshal([]byte(tc.Arguments), &args); err != nil {
-		return "", fmt.Errorf("invalid arguments: %w", err)
+	// Parse arguments; calls to tools without parameters may send none
+	args := map[string]interface{}{}
+	if strings.TrimSpace(tc.Arguments) != "" {
+		if err := json.Unmarshal([]byte(tc.Arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
 	}
 
 	return tool.Execute(ctx, args)
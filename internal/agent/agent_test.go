@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/llm"
+)
+
+// scriptedLLM asks for two tools in parallel, then answers, recording the
+// history it was sent
+type scriptedLLM struct {
+	turns   int
+	history []llm.Message
+}
+
+func (s *scriptedLLM) Generate(ctx context.Context, messages []llm.Message, opts ...llm.Option) (string, error) {
+	return "", nil
+}
+
+func (s *scriptedLLM) GenerateWithTools(ctx context.Context, messages []llm.Message, tools []llm.Tool) (*llm.ToolCallResponse, error) {
+	s.turns++
+	s.history = messages
+	if s.turns == 1 {
+		return &llm.ToolCallResponse{Content: "Looking.", ToolCalls: []llm.ToolCall{
+			{ID: "a", Name: "lookup", Arguments: `{"name":"Parse"}`},
+			{ID: "b", Name: "lookup", Arguments: ""},
+		}}, nil
+	}
+	return &llm.ToolCallResponse{Content: `{"answer":"done","findings":"two lookups","confidence":"high"}`}, nil
+}
+
+func (s *scriptedLLM) Stream(ctx context.Context, messages []llm.Message, opts ...llm.Option) (<-chan string, error) {
+	return nil, nil
+}
+
+func (s *scriptedLLM) Name() string { return "scripted" }
+
+func (s *scriptedLLM) Close() error { return nil }
+
+func TestExecuteRecordsParallelToolCalls(t *testing.T) {
+	model := &scriptedLLM{}
+	a := NewAgent(AgentConfig{LLM: model})
+	a.RegisterTool(Tool{
+		Name: "lookup",
+		Execute: func(ctx context.Context, args map[string]interface{}) (string, error) {
+			name, _ := args["name"].(string)
+			return "found " + name, nil
+		},
+	})
+
+	out, err := a.Execute(context.Background(), "Who calls Parse?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Answer != "done" || out.ToolUseCount != 2 || out.StepsTaken != 2 {
+		t.Errorf("unexpected output %+v", out)
+	}
+
+	// system, user, one assistant turn with both calls, then both results
+	h := model.history
+	if len(h) != 5 {
+		t.Fatalf("expected 5 messages, got %+v", h)
+	}
+	if h[2].Role != llm.RoleAssistant || h[2].Content != "Looking." || len(h[2].ToolCalls) != 2 {
+		t.Errorf("expected one assistant message carrying both calls, got %+v", h[2])
+	}
+	if h[3].ToolCallID != "a" || h[3].Content != "found Parse" || h[4].ToolCallID != "b" || h[4].Content != "found " {
+		t.Errorf("expected a result per call in order, got %+v and %+v", h[3], h[4])
+	}
+}
@@ -0,0 +1,318 @@
+// Package cassette records HTTP traffic to model provider APIs and replays
+// it, so tests of providers and agentic tools can run offline and
+// deterministically. Secrets are scrubbed before anything is written.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Transport talks to the network
+type Mode string
+
+const (
+	// ModeReplay answers from the cassette and fails on unknown requests
+	ModeReplay Mode = "replay"
+	// ModeRecord sends requests to the network and records them
+	ModeRecord Mode = "record"
+	// ModeAuto replays when the cassette file exists and records otherwise
+	ModeAuto Mode = "auto"
+)
+
+// Redacted replaces scrubbed values
+const Redacted = "REDACTED"
+
+// defaultHeaders are credentials sent by the supported providers
+var defaultHeaders = []string{
+	"Authorization",
+	"Api-Key",
+	"X-Api-Key",
+	"X-Goog-Api-Key",
+	"Openai-Organization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// defaultParams are URL query parameters that carry credentials
+var defaultParams = []string{"key", "api_key", "apikey", "access_token", "token"}
+
+// Request is a recorded request
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Interaction is one request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the file format: interactions in the order they happened
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Option configures a Transport
+type Option func(*Transport)
+
+// WithSecrets scrubs literal values, such as API keys, wherever they appear
+// in URLs, headers and bodies
+func WithSecrets(secrets ...string) Option {
+	return func(t *Transport) {
+		for _, s := range secrets {
+			if s != "" {
+				t.secrets = append(t.secrets, s)
+			}
+		}
+	}
+}
+
+// WithHeaders scrubs additional request and response headers
+func WithHeaders(headers ...string) Option {
+	return func(t *Transport) {
+		t.headers = append(t.headers, headers...)
+	}
+}
+
+// Transport is an http.RoundTripper that records or replays interactions.
+// A replayed request matches the first unused interaction with the same
+// method, URL and body, after scrubbing; JSON bodies are compared by value.
+type Transport struct {
+	path    string
+	mode    Mode
+	next    http.RoundTripper
+	headers []string
+	secrets []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// New opens the cassette at path. In replay mode the file must exist. next
+// carries recorded requests; nil means http.DefaultTransport.
+func New(path string, mode Mode, next http.RoundTripper, opts ...Option) (*Transport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &Transport{path: path, mode: mode, next: next, headers: defaultHeaders}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if t.mode == ModeAuto {
+		t.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			t.mode = ModeReplay
+		}
+	}
+	switch t.mode {
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	case ModeRecord:
+	default:
+		return nil, fmt.Errorf("unknown cassette mode: %s", mode)
+	}
+	return t, nil
+}
+
+// Mode returns the mode in effect, with auto resolved
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := t.scrubRequest(req, body)
+
+	if t.mode == ModeReplay {
+		return t.replay(req, recorded)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, &Interaction{
+		Request: recorded,
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: t.scrubHeaders(resp.Header),
+			Body:    t.scrubString(string(respBody)),
+		},
+	})
+	t.mu.Unlock()
+	return resp, nil
+}
+
+func (t *Transport) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, in := range t.cassette.Interactions {
+		if t.used[i] || !matches(in.Request, recorded) {
+			continue
+		}
+		t.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+			StatusCode:    in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %s has no unused interaction for %s %s", filepath.Base(t.path), recorded.Method, recorded.URL)
+}
+
+// Save writes the recorded interactions to the cassette file. It does
+// nothing in replay mode.
+func (t *Transport) Save() error {
+	if t.mode != ModeRecord {
+		return nil
+	}
+	t.mu.Lock()
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, append(data, '\n'), 0o644)
+}
+
+// Unused returns the replayable interactions no request has matched, which
+// usually means the code under test made fewer calls than were recorded
+func (t *Transport) Unused() []Request {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Request
+	for i, in := range t.cassette.Interactions {
+		if i < len(t.used) && !t.used[i] {
+			out = append(out, in.Request)
+		}
+	}
+	return out
+}
+
+// readBody reads the request body and replaces it so it can still be sent
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func (t *Transport) scrubRequest(req *http.Request, body []byte) Request {
+	u := *req.URL
+	q := u.Query()
+	for _, p := range defaultParams {
+		if q.Has(p) {
+			q.Set(p, Redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return Request{
+		Method:  req.Method,
+		URL:     t.scrubString(u.String()),
+		Headers: t.scrubHeaders(req.Header),
+		Body:    t.scrubString(string(body)),
+	}
+}
+
+func (t *Transport) scrubHeaders(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range t.headers {
+		if out.Get(name) != "" {
+			out.Set(name, Redacted)
+		}
+	}
+	for name, values := range out {
+		for i, v := range values {
+			out[name][i] = t.scrubString(v)
+		}
+	}
+	return out
+}
+
+func (t *Transport) scrubString(s string) string {
+	for _, secret := range t.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func matches(recorded, req Request) bool {
+	if recorded.Method != req.Method || !sameURL(recorded.URL, req.URL) {
+		return false
+	}
+	if recorded.Body == req.Body {
+		return true
+	}
+	var a, b any
+	if json.Unmarshal([]byte(recorded.Body), &a) != nil || json.Unmarshal([]byte(req.Body), &b) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+// sameURL compares URLs with their query parameters in any order
+func sameURL(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host && ua.Path == ub.Path &&
+		ua.Query().Encode() == ub.Query().Encode()
+}
@@ -0,0 +1,94 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func send(t *testing.T, client *http.Client, url, body string) string {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-live-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.Status + " " + string(data)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"echo":` + string(data) + `,"call":` + string(rune('0'+calls)) + `}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	rec, err := New(path, ModeAuto, nil, WithSecrets("sk-live-secret", "org-42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != ModeRecord {
+		t.Fatalf("expected auto mode to record without a cassette, got %s", rec.Mode())
+	}
+	client := &http.Client{Transport: rec}
+	first := send(t, client, server.URL+"/v1/embed?key=AIza-secret", `{"model":"m","input":"org-42 a"}`)
+	second := send(t, client, server.URL+"/v1/embed?key=AIza-secret", `{"model":"m","input":"org-42 a"}`)
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	// Secrets echoed in responses are scrubbed too
+	first = strings.ReplaceAll(first, "org-42", Redacted)
+	second = strings.ReplaceAll(second, "org-42", Redacted)
+
+	data, _ := os.ReadFile(path)
+	for _, secret := range []string{"sk-live-secret", "AIza-secret", "org-42", "session=abc"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains secret %q:\n%s", secret, data)
+		}
+	}
+
+	// Replay works with the server gone, answers repeated requests in
+	// order and matches JSON bodies by value
+	play, err := New(path, ModeAuto, nil, WithSecrets("sk-live-secret", "org-42"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if play.Mode() != ModeReplay {
+		t.Fatalf("expected auto mode to replay an existing cassette, got %s", play.Mode())
+	}
+	client = &http.Client{Transport: play}
+	if got := send(t, client, server.URL+"/v1/embed?key=AIza-secret", `{"input": "org-42 a", "model": "m"}`); got != first {
+		t.Errorf("first replay = %q, want %q", got, first)
+	}
+	if len(play.Unused()) != 1 {
+		t.Errorf("expected one unused interaction, got %v", play.Unused())
+	}
+	if got := send(t, client, server.URL+"/v1/embed?key=AIza-secret", `{"model":"m","input":"org-42 a"}`); got != second {
+		t.Errorf("second replay = %q, want %q", got, second)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/embed", strings.NewReader(`{"input":"b"}`))
+	if _, err := client.Do(req); err == nil || !strings.Contains(err.Error(), "no unused interaction") {
+		t.Errorf("expected an error for an unrecorded request, got %v", err)
+	}
+}
+
+func TestNewReplayRequiresCassette(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil); err == nil {
+		t.Error("expected error for a missing cassette in replay mode")
+	}
+	if _, err := New("x.json", Mode("rewind"), nil); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
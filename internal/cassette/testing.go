@@ -0,0 +1,51 @@
+package cassette
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/httpclient"
+)
+
+// secretEnv are the environment variables holding provider API keys; their
+// values are scrubbed from cassettes recorded by Use
+var secretEnv = []string{
+	"OPENAI_API_KEY",
+	"ANTHROPIC_API_KEY",
+	"GOOGLE_API_KEY",
+	"GEMINI_API_KEY",
+	"XAI_API_KEY",
+	"CODELOOM_EMBEDDING_API_KEY",
+}
+
+// Use routes the clients that httpclient creates for the rest of the test
+// through testdata/cassettes/<name>.json. Create providers after calling it.
+// The mode comes from CODELOOM_CASSETTE_MODE and defaults to replay; run
+// with CODELOOM_CASSETTE_MODE=record and real API keys to re-record.
+func Use(t testing.TB, name string, opts ...Option) *Transport {
+	t.Helper()
+	mode := Mode(os.Getenv("CODELOOM_CASSETTE_MODE"))
+	if mode == "" {
+		mode = ModeReplay
+	}
+	var secrets []string
+	for _, env := range secretEnv {
+		secrets = append(secrets, os.Getenv(env))
+	}
+	opts = append([]Option{WithSecrets(secrets...)}, opts...)
+
+	path := filepath.Join("testdata", "cassettes", name+".json")
+	tr, err := New(path, mode, httpclient.Transport(), opts...)
+	if err != nil {
+		t.Fatalf("cassette %s: %v", name, err)
+	}
+	httpclient.SetTransport(tr)
+	t.Cleanup(func() {
+		httpclient.SetTransport(nil)
+		if err := tr.Save(); err != nil {
+			t.Errorf("failed to save cassette %s: %v", name, err)
+		}
+	})
+	return tr
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/BurntSushi/toml"
 )
@@ -14,6 +15,8 @@ type Config struct {
 	Embedding EmbeddingConfig `toml:"embedding"`
 	Database  DatabaseConfig  `toml:"database"`
 	Server    ServerConfig    `toml:"server"`
+	Telemetry TelemetryConfig `toml:"telemetry"`
+	Logging   LoggingConfig   `toml:"logging"`
 }
 
 type LLMConfig struct {
@@ -26,6 +29,92 @@ type LLMConfig struct {
 	MaxTokens     int     `toml:"max_tokens"`
 	ContextWindow int     `toml:"context_window"`
 	TimeoutSecs   int     `toml:"timeout_secs"`
+
+	// Requests beyond requests_per_minute wait; transient failures are
+	// retried max_retries times. Prices (USD per million tokens) are used
+	// for cost estimates.
+	RequestsPerMinute int     `toml:"requests_per_minute"`
+	MaxRetries        int     `toml:"max_retries"`
+	InputPrice        float64 `toml:"input_price"`
+	OutputPrice       float64 `toml:"output_price"`
+
+	// Providers is an ordered fallback chain; when set it replaces the
+	// single provider above, whose settings become defaults for the entries.
+	// Routes maps a tool name to the providers (by name) tried for it.
+	Providers           []LLMProviderConfig `toml:"providers"`
+	Routes              map[string][]string `toml:"routes"`
+	BreakerThreshold    int                 `toml:"breaker_threshold"`     // consecutive failures before a provider is skipped
+	BreakerCooldownSecs int                 `toml:"breaker_cooldown_secs"` // how long it is skipped before a trial request
+
+	// Agentic tool answers are cached until the indexed graph changes, for
+	// at most cache_ttl_secs. cache_max_entries = 0 disables the cache.
+	CacheTTLSecs    int `toml:"cache_ttl_secs"`
+	CacheMaxEntries int `toml:"cache_max_entries"`
+
+	// PromptsDir holds project overrides of the agentic prompt templates,
+	// one <name>.tmpl file per template. A relative path is resolved
+	// against the indexed or watched project root
+	PromptsDir string `toml:"prompts_dir"`
+}
+
+// LLMProviderConfig is one entry in the [[llm.providers]] chain. Zero values
+// inherit from [llm].
+type LLMProviderConfig struct {
+	Name        string  `toml:"name"` // defaults to the provider type
+	Provider    string  `toml:"provider"`
+	Model       string  `toml:"model"`
+	APIKey      string  `toml:"api_key"`
+	BaseURL     string  `toml:"base_url"`
+	Temperature float32 `toml:"temperature"`
+	MaxTokens   int     `toml:"max_tokens"`
+	TimeoutSecs int     `toml:"timeout_secs"`
+
+	RequestsPerMinute int     `toml:"requests_per_minute"`
+	MaxRetries        int     `toml:"max_retries"`
+	InputPrice        float64 `toml:"input_price"`
+	OutputPrice       float64 `toml:"output_price"`
+}
+
+// ChainName returns the name routes use to refer to the entry
+func (p LLMProviderConfig) ChainName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Provider
+}
+
+// Member returns the settings for one chain entry, filling unset values
+// from the top-level LLM settings
+func (c LLMConfig) Member(p LLMProviderConfig) LLMConfig {
+	m := LLMConfig{
+		Enabled:       c.Enabled,
+		Provider:      p.Provider,
+		Model:         p.Model,
+		APIKey:        p.APIKey,
+		BaseURL:       p.BaseURL,
+		Temperature:   p.Temperature,
+		MaxTokens:     p.MaxTokens,
+		ContextWindow: c.ContextWindow,
+		TimeoutSecs:   p.TimeoutSecs,
+
+		RequestsPerMinute: p.RequestsPerMinute,
+		MaxRetries:        p.MaxRetries,
+		InputPrice:        p.InputPrice,
+		OutputPrice:       p.OutputPrice,
+	}
+	if m.Temperature == 0 {
+		m.Temperature = c.Temperature
+	}
+	if m.MaxTokens == 0 {
+		m.MaxTokens = c.MaxTokens
+	}
+	if m.TimeoutSecs == 0 {
+		m.TimeoutSecs = c.TimeoutSecs
+	}
+	if m.MaxRetries == 0 {
+		m.MaxRetries = c.MaxRetries
+	}
+	return m
 }
 
 type EmbeddingConfig struct {
@@ -36,6 +125,51 @@ type EmbeddingConfig struct {
 	APIKey        string `toml:"api_key"`
 	BatchSize     int    `toml:"batch_size"`
 	MaxConcurrency int    `toml:"max_concurrency"`
+
+	// Local provider: model is a path to an .onnx or .gguf file
+	TokenizerPath string `toml:"tokenizer_path"` // ONNX only; defaults to tokenizer.json or vocab.txt beside the model
+	RuntimePath   string `toml:"runtime_path"`   // ONNX Runtime shared library
+	MaxTokens     int    `toml:"max_tokens"`
+	Threads       int    `toml:"threads"`
+	Pooling       string `toml:"pooling"` // mean or cls (ONNX only; GGUF models declare their own)
+
+	// Remote providers: headers are sent with every request (values may use
+	// ${ENV_VAR}); truncate cuts longer vectors down to dimension
+	Headers  map[string]string   `toml:"headers"`
+	Truncate bool                `toml:"truncate"`
+	HTTP     HTTPEmbeddingConfig `toml:"http"`
+
+	// Task prefixes for query and document texts; when both are empty, known
+	// models (nomic-embed, e5) get theirs. "none" disables a prefix.
+	QueryPrefix    string `toml:"query_prefix"`
+	DocumentPrefix string `toml:"document_prefix"`
+
+	// DocumentTemplate is a text/template rendering what is embedded for a
+	// node; bodies are split into chunks of up to ChunkChars characters, and
+	// each of up to MaxChunks chunks gets its own vector
+	DocumentTemplate string `toml:"document_template"`
+	ChunkChars       int    `toml:"chunk_chars"`
+	MaxChunks        int    `toml:"max_chunks"`
+
+	// Fallback is used when the provider cannot be reached at startup:
+	// "none" (default) to fail, or "hash" to continue with hash embeddings
+	Fallback string `toml:"fallback"`
+
+	// Remote providers: rate limit, retries and prices, as for [llm]
+	RequestsPerMinute int     `toml:"requests_per_minute"`
+	MaxRetries        int     `toml:"max_retries"`
+	InputPrice        float64 `toml:"input_price"`
+}
+
+// HTTPEmbeddingConfig maps the generic "http" embedding provider onto a JSON
+// API. Paths are dotted; "*" in response_field iterates an array.
+type HTTPEmbeddingConfig struct {
+	InputField    string         `toml:"input_field"`    // request path for the texts (default "input")
+	ModelField    string         `toml:"model_field"`    // request path for the model (default "model", "-" to omit)
+	SingleInput   bool           `toml:"single_input"`   // send one text per request, as a string
+	Body          map[string]any `toml:"body"`           // extra fields sent with every request
+	QueryBody     map[string]any `toml:"query_body"`     // fields overriding body for search queries
+	ResponseField string         `toml:"response_field"` // path to the vectors (default "data.*.embedding")
 }
 
 type DatabaseConfig struct {
@@ -58,6 +192,39 @@ type ServerConfig struct {
 	HTTPPath          string `toml:"http_path"`
 	WatcherDebounceMs int    `toml:"watcher_debounce_ms"`
 	IndexTimeoutMs    int    `toml:"index_timeout_ms"`
+
+	// ParseErrorThreshold is the share of a file in syntax errors above
+	// which an indexed file keeps its last good nodes. It must be above 0
+	// and at most 1; 1 always replaces the stored nodes
+	ParseErrorThreshold float64 `toml:"parse_error_threshold"`
+
+	// CompileCommands reads C and C++ build flags from the project's
+	// compile_commands.json
+	CompileCommands bool `toml:"compile_commands"`
+}
+
+// TelemetryConfig controls OpenTelemetry tracing. Exporter is one of
+// "none", "otlp" (OTLP over HTTP to Endpoint) or "file" (JSON lines to FilePath).
+type TelemetryConfig struct {
+	Exporter    string  `toml:"exporter"`
+	Endpoint    string  `toml:"endpoint"`
+	Insecure    bool    `toml:"insecure"`
+	FilePath    string  `toml:"file_path"`
+	ServiceName string  `toml:"service_name"`
+	SampleRatio float64 `toml:"sample_ratio"`
+}
+
+// LoggingConfig controls the structured logger. Level and the values in
+// Components are one of debug, info, warn or error; Components overrides the
+// level for individual components (indexer, watcher, graph, llm, ...).
+// File is "stderr" (default), "stdout" or a path rotated at MaxSizeMB.
+type LoggingConfig struct {
+	Level      string            `toml:"level"`
+	Format     string            `toml:"format"`
+	File       string            `toml:"file"`
+	MaxSizeMB  int               `toml:"max_size_mb"`
+	MaxBackups int               `toml:"max_backups"`
+	Components map[string]string `toml:"components"`
 }
 
 func Load(path string) (*Config, error) {
@@ -100,6 +267,14 @@ func DefaultConfig() *Config {
 			MaxTokens:     4096,
 			ContextWindow: 128000,
 			TimeoutSecs:   120,
+			MaxRetries:    3,
+
+			BreakerThreshold:    3,
+			BreakerCooldownSecs: 30,
+
+			CacheTTLSecs:    3600,
+			CacheMaxEntries: 256,
+			PromptsDir:      ".codeloom/prompts",
 		},
 		Embedding: EmbeddingConfig{
 			Provider:      "ollama",
@@ -108,6 +283,10 @@ func DefaultConfig() *Config {
 			BaseURL:       "http://localhost:11434",
 			BatchSize:     64,
 			MaxConcurrency: 10,
+			MaxRetries:    3,
+			ChunkChars:    2000,
+			MaxChunks:     4,
+			Fallback:      "none",
 		},
 		Database: DatabaseConfig{
 			Backend: "surrealdb",
@@ -126,6 +305,23 @@ func DefaultConfig() *Config {
 			HTTPPath:          "/mcp",
 			WatcherDebounceMs: 100,
 			IndexTimeoutMs:    60000, // Default 60 second timeout for indexing operations
+
+			ParseErrorThreshold: 0.2,
+			CompileCommands:     true,
+		},
+		Telemetry: TelemetryConfig{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "codeloom",
+			SampleRatio: 1.0,
+		},
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "text",
+			File:       "stderr",
+			MaxSizeMB:  100,
+			MaxBackups: 3,
 		},
 	}
 }
@@ -135,7 +331,7 @@ func Validate(cfg *Config) []string {
 
 	// Validate LLM settings
 	if cfg.LLM.Enabled {
-		if cfg.LLM.Provider == "" {
+		if cfg.LLM.Provider == "" && len(cfg.LLM.Providers) == 0 {
 			warnings = append(warnings, "LLM provider is enabled but no provider specified")
 		}
 		if cfg.LLM.MaxTokens < 1 {
@@ -153,6 +349,30 @@ func Validate(cfg *Config) []string {
 		if cfg.LLM.TimeoutSecs > 600 {
 			warnings = append(warnings, "LLM TimeoutSecs exceeds reasonable maximum (600 seconds)")
 		}
+		names := make(map[string]bool)
+		for i, p := range cfg.LLM.Providers {
+			if p.Provider == "" {
+				warnings = append(warnings, "LLM providers entry "+strconv.Itoa(i+1)+" has no provider")
+				continue
+			}
+			if names[p.ChainName()] {
+				warnings = append(warnings, "LLM provider name "+p.ChainName()+" is used more than once; set name to tell them apart")
+			}
+			names[p.ChainName()] = true
+		}
+		for tool, route := range cfg.LLM.Routes {
+			for _, name := range route {
+				if !names[name] {
+					warnings = append(warnings, "LLM route for "+tool+" refers to unknown provider "+name)
+				}
+			}
+		}
+		if cfg.LLM.BreakerThreshold < 0 || cfg.LLM.BreakerCooldownSecs < 0 {
+			warnings = append(warnings, "LLM breaker_threshold and breaker_cooldown_secs cannot be negative")
+		}
+	}
+	if cfg.LLM.CacheTTLSecs < 0 || cfg.LLM.CacheMaxEntries < 0 {
+		warnings = append(warnings, "LLM cache_ttl_secs and cache_max_entries cannot be negative")
 	}
 
 	// Validate embedding settings
@@ -168,6 +388,36 @@ func Validate(cfg *Config) []string {
 	if cfg.Embedding.MaxConcurrency < 1 || cfg.Embedding.MaxConcurrency > 100 {
 		warnings = append(warnings, "Embedding max concurrency must be between 1 and 100")
 	}
+	if cfg.Embedding.DocumentTemplate != "" {
+		if _, err := template.New("document").Parse(cfg.Embedding.DocumentTemplate); err != nil {
+			warnings = append(warnings, "Embedding document_template is invalid: "+err.Error())
+		}
+	}
+	if cfg.Embedding.ChunkChars < 0 || cfg.Embedding.MaxChunks < 0 {
+		warnings = append(warnings, "Embedding chunk_chars and max_chunks cannot be negative")
+	}
+	if cfg.Embedding.Fallback != "" && cfg.Embedding.Fallback != "hash" && cfg.Embedding.Fallback != "none" {
+		warnings = append(warnings, "Embedding fallback must be hash or none")
+	}
+	switch cfg.Embedding.Provider {
+	case "openai-compatible", "http":
+		if cfg.Embedding.BaseURL == "" {
+			warnings = append(warnings, "Embedding base_url is required for the "+cfg.Embedding.Provider+" provider")
+		}
+	case "google":
+		if cfg.Embedding.APIKey == "" {
+			warnings = append(warnings, "Google embedding provider requires an API key")
+		}
+	}
+	if cfg.Embedding.Provider == "local" {
+		ext := strings.ToLower(filepath.Ext(cfg.Embedding.Model))
+		if ext != ".onnx" && ext != ".gguf" {
+			warnings = append(warnings, "Local embedding model must be a path to an .onnx or .gguf file")
+		}
+		if cfg.Embedding.Pooling != "" && cfg.Embedding.Pooling != "mean" && cfg.Embedding.Pooling != "cls" {
+			warnings = append(warnings, "Embedding pooling must be mean or cls")
+		}
+	}
 
 	// Validate database settings
 	if cfg.Database.Backend == "surrealdb" {
@@ -205,13 +455,78 @@ func Validate(cfg *Config) []string {
 	if cfg.Server.IndexTimeoutMs > 300000 {
 		warnings = append(warnings, "Index timeout exceeds reasonable maximum (300 seconds)")
 	}
+	if cfg.Server.ParseErrorThreshold <= 0 || cfg.Server.ParseErrorThreshold > 1 {
+		warnings = append(warnings, "Parse error threshold must be above 0 and at most 1")
+	}
 	if cfg.Server.HTTPPath != "" && !strings.HasPrefix(cfg.Server.HTTPPath, "/") {
 		warnings = append(warnings, "Server http_path must start with '/'")
 	}
 
+	// Validate telemetry settings
+	switch cfg.Telemetry.Exporter {
+	case "", "none", "otlp":
+	case "file":
+		if cfg.Telemetry.FilePath == "" {
+			warnings = append(warnings, "Telemetry file exporter requires file_path")
+		}
+	default:
+		warnings = append(warnings, "Telemetry exporter must be one of: none, otlp, file")
+	}
+	if cfg.Telemetry.SampleRatio < 0 || cfg.Telemetry.SampleRatio > 1 {
+		warnings = append(warnings, "Telemetry sample_ratio must be between 0 and 1")
+	}
+
+	// Validate logging settings
+	if !validLogLevel(cfg.Logging.Level) {
+		warnings = append(warnings, "Logging level must be one of: debug, info, warn, error")
+	}
+	for component, level := range cfg.Logging.Components {
+		if !validLogLevel(level) {
+			warnings = append(warnings, "Logging level for component "+component+" must be one of: debug, info, warn, error")
+		}
+	}
+	switch strings.ToLower(cfg.Logging.Format) {
+	case "", "text", "json":
+	default:
+		warnings = append(warnings, "Logging format must be one of: text, json")
+	}
+	if cfg.Logging.MaxSizeMB < 0 || cfg.Logging.MaxBackups < 0 {
+		warnings = append(warnings, "Logging max_size_mb and max_backups cannot be negative")
+	}
+
 	return warnings
 }
 
+// llmAPIKeyFromEnv returns the conventional API key variable for a provider
+// type, so chain entries need not repeat keys in the config file
+func llmAPIKeyFromEnv(provider string) string {
+	var names []string
+	switch provider {
+	case "openai", "openai-compatible":
+		names = []string{"OPENAI_API_KEY"}
+	case "anthropic":
+		names = []string{"ANTHROPIC_API_KEY"}
+	case "google":
+		names = []string{"GOOGLE_API_KEY", "GEMINI_API_KEY"}
+	case "xai":
+		names = []string{"XAI_API_KEY"}
+	}
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func validLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "", "debug", "info", "warn", "warning", "error":
+		return true
+	}
+	return false
+}
+
 func applyEnvOverrides(cfg *Config) {
 	// LLM settings
 	if v := os.Getenv("CODELOOM_LLM_PROVIDER"); v != "" {
@@ -232,6 +547,12 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("ANTHROPIC_API_KEY"); v != "" && cfg.LLM.Provider == "anthropic" {
 		cfg.LLM.APIKey = v
 	}
+	for i := range cfg.LLM.Providers {
+		p := &cfg.LLM.Providers[i]
+		if p.APIKey == "" {
+			p.APIKey = llmAPIKeyFromEnv(p.Provider)
+		}
+	}
 	if v := os.Getenv("CODELOOM_CONTEXT_WINDOW"); v != "" {
 		if i, err := strconv.Atoi(v); err == nil {
 			cfg.LLM.ContextWindow = i
@@ -263,6 +584,25 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.Embedding.MaxConcurrency = i
 		}
 	}
+	if v := os.Getenv("CODELOOM_EMBEDDING_BASE_URL"); v != "" {
+		cfg.Embedding.BaseURL = v
+	}
+	if v := os.Getenv("CODELOOM_EMBEDDING_API_KEY"); v != "" {
+		cfg.Embedding.APIKey = v
+	}
+	if cfg.Embedding.Provider == "google" && cfg.Embedding.APIKey == "" {
+		if v := os.Getenv("GOOGLE_API_KEY"); v != "" {
+			cfg.Embedding.APIKey = v
+		} else if v := os.Getenv("GEMINI_API_KEY"); v != "" {
+			cfg.Embedding.APIKey = v
+		}
+	}
+	if v := os.Getenv("CODELOOM_EMBEDDING_RUNTIME_PATH"); v != "" {
+		cfg.Embedding.RuntimePath = v
+	}
+	if v := os.Getenv("CODELOOM_EMBEDDING_FALLBACK"); v != "" {
+		cfg.Embedding.Fallback = v
+	}
 
 	// Database settings
 	if v := os.Getenv("CODELOOM_SURREALDB_URL"); v != "" {
@@ -301,10 +641,45 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.Server.IndexTimeoutMs = i
 		}
 	}
+	if v := os.Getenv("CODELOOM_PARSE_ERROR_THRESHOLD"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.Server.ParseErrorThreshold = f
+		}
+	}
+	if v := os.Getenv("CODELOOM_COMPILE_COMMANDS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.Server.CompileCommands = b
+		}
+	}
 	if v := os.Getenv("CODELOOM_TRANSPORT"); v != "" {
 		cfg.Server.Transport = v
 	}
 	if v := os.Getenv("CODELOOM_HTTP_PATH"); v != "" {
 		cfg.Server.HTTPPath = v
 	}
+
+	// Telemetry settings
+	if v := os.Getenv("CODELOOM_TRACE_EXPORTER"); v != "" {
+		cfg.Telemetry.Exporter = v
+	}
+	if v := os.Getenv("CODELOOM_TRACE_FILE"); v != "" {
+		cfg.Telemetry.FilePath = v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		cfg.Telemetry.Endpoint = v
+	}
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.Telemetry.ServiceName = v
+	}
+
+	// Logging settings
+	if v := os.Getenv("CODELOOM_LOG_LEVEL"); v != "" {
+		cfg.Logging.Level = v
+	}
+	if v := os.Getenv("CODELOOM_LOG_FORMAT"); v != "" {
+		cfg.Logging.Format = v
+	}
+	if v := os.Getenv("CODELOOM_LOG_FILE"); v != "" {
+		cfg.Logging.File = v
+	}
 }
@@ -175,3 +175,221 @@ func TestEnvOverrideHTTPPath(t *testing.T) {
 		t.Errorf("Expected HTTPPath '/custom' from env, got '%s'", cfg.Server.HTTPPath)
 	}
 }
+
+// TestParseErrorThresholdConfig verifies the parse error threshold
+// default, validation and env override
+func TestParseErrorThresholdConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Server.ParseErrorThreshold != 0.2 {
+		t.Errorf("Expected default parse error threshold 0.2, got %v", cfg.Server.ParseErrorThreshold)
+	}
+
+	for _, threshold := range []float64{0, 1.5} {
+		cfg.Server.ParseErrorThreshold = threshold
+		warnings := Validate(cfg)
+		found := false
+		for _, w := range warnings {
+			if contains(w, "Parse error threshold") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected warning for a parse error threshold of %v, got %v", threshold, warnings)
+		}
+	}
+
+	t.Setenv("CODELOOM_PARSE_ERROR_THRESHOLD", "0.5")
+	cfg = DefaultConfig()
+	applyEnvOverrides(cfg)
+	if cfg.Server.ParseErrorThreshold != 0.5 {
+		t.Errorf("Expected parse error threshold 0.5 from env, got %v", cfg.Server.ParseErrorThreshold)
+	}
+}
+
+// TestCompileCommandsConfig verifies the compile_commands.json lookup is on
+// by default and can be turned off from the environment
+func TestCompileCommandsConfig(t *testing.T) {
+	if !DefaultConfig().Server.CompileCommands {
+		t.Error("Expected compile_commands to be on by default")
+	}
+
+	t.Setenv("CODELOOM_COMPILE_COMMANDS", "false")
+	cfg := DefaultConfig()
+	applyEnvOverrides(cfg)
+	if cfg.Server.CompileCommands {
+		t.Error("Expected compile_commands off from env")
+	}
+}
+
+// TestTelemetryConfig verifies telemetry defaults, validation and env overrides
+func TestTelemetryConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Telemetry.Exporter != "none" {
+		t.Errorf("Expected default trace exporter 'none', got %q", cfg.Telemetry.Exporter)
+	}
+
+	cfg.Telemetry.Exporter = "file"
+	warnings := Validate(cfg)
+	found := false
+	for _, w := range warnings {
+		if contains(w, "file_path") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected warning for file exporter without file_path, got %v", warnings)
+	}
+
+	t.Setenv("CODELOOM_TRACE_EXPORTER", "otlp")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	cfg = DefaultConfig()
+	applyEnvOverrides(cfg)
+	if cfg.Telemetry.Exporter != "otlp" {
+		t.Errorf("Expected exporter 'otlp' from env, got %q", cfg.Telemetry.Exporter)
+	}
+	if cfg.Telemetry.Endpoint != "http://collector:4318" {
+		t.Errorf("Expected endpoint from env, got %q", cfg.Telemetry.Endpoint)
+	}
+}
+
+// TestLoggingConfig verifies logging defaults, validation and env overrides
+func TestLoggingConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Logging.Level != "info" || cfg.Logging.File != "stderr" {
+		t.Errorf("Unexpected logging defaults: %+v", cfg.Logging)
+	}
+
+	cfg.Logging.Components = map[string]string{"indexer": "verbose"}
+	warnings := Validate(cfg)
+	found := false
+	for _, w := range warnings {
+		if contains(w, "component indexer") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected warning for invalid component level, got %v", warnings)
+	}
+
+	t.Setenv("CODELOOM_LOG_LEVEL", "debug")
+	t.Setenv("CODELOOM_LOG_FORMAT", "json")
+	t.Setenv("CODELOOM_LOG_FILE", "/tmp/codeloom.log")
+	cfg = DefaultConfig()
+	applyEnvOverrides(cfg)
+	if cfg.Logging.Level != "debug" || cfg.Logging.Format != "json" || cfg.Logging.File != "/tmp/codeloom.log" {
+		t.Errorf("Expected logging settings from env, got %+v", cfg.Logging)
+	}
+}
+
+// TestEmbeddingFallbackConfig verifies the embedding fallback default,
+// validation and env override
+func TestEmbeddingFallbackConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Embedding.Fallback != "none" {
+		t.Errorf("Expected default embedding fallback 'none', got %q", cfg.Embedding.Fallback)
+	}
+
+	cfg.Embedding.Fallback = "random"
+	found := false
+	for _, w := range Validate(cfg) {
+		if contains(w, "fallback") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected warning for invalid embedding fallback")
+	}
+
+	t.Setenv("CODELOOM_EMBEDDING_FALLBACK", "hash")
+	cfg = DefaultConfig()
+	applyEnvOverrides(cfg)
+	if cfg.Embedding.Fallback != "hash" {
+		t.Errorf("Expected fallback 'hash' from env, got %q", cfg.Embedding.Fallback)
+	}
+}
+
+// TestRemoteEmbeddingConfig verifies validation and API key lookup for
+// remote embedding providers
+func TestRemoteEmbeddingConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Embedding.Provider = "openai-compatible"
+	cfg.Embedding.BaseURL = ""
+	found := false
+	for _, w := range Validate(cfg) {
+		if contains(w, "base_url") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected warning for openai-compatible provider without base_url")
+	}
+
+	t.Setenv("GEMINI_API_KEY", "gemini-key")
+	cfg = DefaultConfig()
+	cfg.Embedding.Provider = "google"
+	applyEnvOverrides(cfg)
+	if cfg.Embedding.APIKey != "gemini-key" {
+		t.Errorf("Expected Google API key from env, got %q", cfg.Embedding.APIKey)
+	}
+}
+
+// TestEmbeddingDocumentConfig verifies chunking defaults and document
+// template validation
+func TestEmbeddingDocumentConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.Embedding.ChunkChars != 2000 || cfg.Embedding.MaxChunks != 4 {
+		t.Errorf("Expected chunk defaults 2000/4, got %d/%d", cfg.Embedding.ChunkChars, cfg.Embedding.MaxChunks)
+	}
+
+	cfg.Embedding.DocumentTemplate = "{{.Name"
+	found := false
+	for _, w := range Validate(cfg) {
+		if contains(w, "document_template") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected warning for unparseable document template")
+	}
+}
+
+// TestLLMProviderChainConfig verifies chain entry defaults, validation and
+// API key lookup
+func TestLLMProviderChainConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LLM.Provider = ""
+	cfg.LLM.Providers = []LLMProviderConfig{
+		{Provider: "anthropic", Model: "claude-sonnet-4-5"},
+		{Name: "local", Provider: "ollama", Model: "qwen2.5-coder", Temperature: 0.5},
+	}
+	cfg.LLM.Routes = map[string][]string{"codeloom_context": {"local", "missing"}}
+
+	warnings := Validate(cfg)
+	if len(warnings) != 1 || !contains(warnings[0], "missing") {
+		t.Errorf("Expected one warning for the unknown route provider, got %v", warnings)
+	}
+
+	local := cfg.LLM.Member(cfg.LLM.Providers[1])
+	if local.Temperature != 0.5 || local.MaxTokens != cfg.LLM.MaxTokens || local.TimeoutSecs != cfg.LLM.TimeoutSecs || local.MaxRetries != 3 {
+		t.Errorf("Expected unset member values to inherit from [llm], got %+v", local)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "anthropic-key")
+	applyEnvOverrides(cfg)
+	if cfg.LLM.Providers[0].APIKey != "anthropic-key" || cfg.LLM.Providers[1].APIKey != "" {
+		t.Errorf("Expected API key from env for the anthropic entry only, got %+v", cfg.LLM.Providers)
+	}
+}
+
+func TestLLMResponseCacheConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.LLM.CacheTTLSecs != 3600 || cfg.LLM.CacheMaxEntries != 256 {
+		t.Errorf("Expected response cache enabled by default, got ttl=%d entries=%d", cfg.LLM.CacheTTLSecs, cfg.LLM.CacheMaxEntries)
+	}
+
+	cfg.LLM.CacheMaxEntries = -1
+	warnings := Validate(cfg)
+	if len(warnings) != 1 || !contains(warnings[0], "cache_max_entries") {
+		t.Errorf("Expected one warning for a negative cache size, got %v", warnings)
+	}
+}
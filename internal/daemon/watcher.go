@@ -3,9 +3,12 @@ package daemon
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,20 +17,34 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/heefoo/codeloom/internal/parser"
 	"github.com/heefoo/codeloom/internal/util"
 )
 
+const (
+	// maxBatchWait caps how long a stream of events can postpone processing
+	maxBatchWait = 5 * time.Second
+
+	// embedBatchSize is the number of nodes sent per embedding request
+	embedBatchSize = 32
+)
+
 type Watcher struct {
 	watcher         *fsnotify.Watcher
 	parser          *parser.Parser
 	storage         *graph.Storage
 	embedding       embedding.Provider
+	documents       *embedding.DocumentBuilder
 	excludePatterns []string
+	errorThreshold  float64
+	logger          *slog.Logger
 	debounceMs      atomic.Int64
 	indexTimeoutMs  atomic.Int64
 	mu              sync.Mutex
 	pendingFiles    map[string]time.Time
+	batchStarted    time.Time // when the oldest pending event was queued
+	lastQueued      time.Time // when the newest pending event was queued
 	stopCh          chan struct{}
 	stopOnce        sync.Once
 }
@@ -36,9 +53,16 @@ type WatcherConfig struct {
 	Parser          *parser.Parser
 	Storage         *graph.Storage
 	Embedding       embedding.Provider
+	Documents       *embedding.DocumentBuilder // optional; renders the texts embedded per node
 	ExcludePatterns []string
 	DebounceMs      int
 	IndexTimeoutMs  int
+	Logger          *slog.Logger // optional; defaults to slog.Default()
+
+	// ErrorThreshold is the share of a file in syntax errors above which
+	// an indexed file keeps its nodes instead of taking those of the new
+	// parse; 0 means parser.DefaultErrorThreshold
+	ErrorThreshold float64
 }
 
 func NewWatcher(cfg WatcherConfig) (*Watcher, error) {
@@ -57,12 +81,20 @@ func NewWatcher(cfg WatcherConfig) (*Watcher, error) {
 		indexTimeoutMs = 60000 // Default 60 second timeout for indexing operations
 	}
 
+	documents := cfg.Documents
+	if documents == nil {
+		documents = embedding.DefaultDocumentBuilder()
+	}
+
 	w := &Watcher{
 		watcher:         fsWatcher,
 		parser:          cfg.Parser,
 		storage:         cfg.Storage,
 		embedding:       cfg.Embedding,
+		documents:       documents,
 		excludePatterns: cfg.ExcludePatterns,
+		errorThreshold:  cfg.ErrorThreshold,
+		logger:          logging.Component(cfg.Logger, "watcher"),
 		pendingFiles:    make(map[string]time.Time),
 		stopCh:          make(chan struct{}),
 	}
@@ -72,13 +104,39 @@ func NewWatcher(cfg WatcherConfig) (*Watcher, error) {
 }
 
 func (w *Watcher) Watch(ctx context.Context, dirs []string) error {
-	// Add directories to watch
+	// Use absolute paths so stored file paths match the indexer's
+	absDirs := make([]string, 0, len(dirs))
 	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			w.logger.Warn("failed to resolve directory", "dir", dir, "error", err)
+			continue
+		}
+		absDirs = append(absDirs, absDir)
+		w.parser.AddProjectRoot(absDir)
+	}
+
+	// Add directories to watch
+	for _, dir := range absDirs {
 		if err := w.addDirRecursive(dir); err != nil {
-			log.Printf("Warning: failed to watch %s: %v", dir, err)
+			w.logger.Warn("failed to watch directory", "dir", dir, "error", err)
 		}
 	}
 
+	// Catch up on changes made while nothing was watching. Watches are already
+	// in place, so nothing is missed between the scan and the event loop.
+	go func() {
+		result, err := w.Reconcile(ctx, absDirs)
+		if err != nil {
+			w.logger.Warn("failed to reconcile watched directories", "error", err)
+			return
+		}
+		if result.Changed > 0 || result.Deleted > 0 {
+			w.logger.Info("reconciled watched directories", "changed", result.Changed,
+				"deleted", result.Deleted, "unchanged", result.Unchanged)
+		}
+	}()
+
 	// Start debounce processor
 	go w.processDebounced(ctx)
 
@@ -98,7 +156,7 @@ func (w *Watcher) Watch(ctx context.Context, dirs []string) error {
 			if !ok {
 				return nil
 			}
-			log.Printf("Watcher error: %v", err)
+			w.logger.Error("watcher error", "error", err)
 		}
 	}
 }
@@ -127,6 +185,109 @@ func (w *Watcher) addDirRecursive(dir string) error {
 	})
 }
 
+// ReconcileResult counts the files Reconcile found in each state
+type ReconcileResult struct {
+	Changed   int
+	Deleted   int
+	Unchanged int
+}
+
+// Reconcile compares the files under dirs with stored file metadata and queues
+// new, modified and deleted files for processing, the same way file events are.
+func (w *Watcher) Reconcile(ctx context.Context, dirs []string) (ReconcileResult, error) {
+	if w.storage == nil {
+		return ReconcileResult{}, nil
+	}
+	for _, dir := range dirs {
+		w.parser.AddProjectRoot(dir)
+	}
+
+	stored, err := w.storage.GetAllFileMetadata(ctx)
+	if err != nil {
+		return ReconcileResult{}, fmt.Errorf("failed to load file metadata: %w", err)
+	}
+
+	changed, deleted, unchanged := w.diffTree(ctx, dirs, stored)
+	if err := ctx.Err(); err != nil {
+		return ReconcileResult{}, err
+	}
+
+	for _, path := range changed {
+		w.queueFile(path)
+	}
+	for _, path := range deleted {
+		w.queueFile(path + "|DELETE")
+	}
+
+	return ReconcileResult{
+		Changed:   len(changed),
+		Deleted:   len(deleted),
+		Unchanged: unchanged,
+	}, nil
+}
+
+// diffTree walks dirs and classifies source files against stored metadata,
+// using the same mtime-then-hash check as incremental indexing. Stored files
+// under dirs that no longer exist are reported as deleted.
+func (w *Watcher) diffTree(ctx context.Context, dirs []string, stored []graph.FileMetadata) (changed, deleted []string, unchanged int) {
+	storedFiles := make(map[string]*graph.FileMetadata, len(stored))
+	for i := range stored {
+		storedFiles[stored[i].FilePath] = &stored[i]
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Skip files with errors
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if w.shouldExclude(path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || w.parser.DetectLanguage(path) == "" || seen[path] {
+				return nil
+			}
+			seen[path] = true
+
+			existing, ok := storedFiles[path]
+			if !ok {
+				changed = append(changed, path)
+				return nil
+			}
+			if info.ModTime().Unix() == existing.ModTime {
+				unchanged++
+				return nil
+			}
+			hash, err := util.HashFile(ctx, path)
+			if err != nil || hash != existing.ContentHash {
+				changed = append(changed, path)
+			} else {
+				unchanged++
+			}
+			return nil
+		})
+	}
+
+	for path := range storedFiles {
+		if seen[path] {
+			continue
+		}
+		for _, dir := range dirs {
+			if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				deleted = append(deleted, path)
+				break
+			}
+		}
+	}
+	return changed, deleted, unchanged
+}
+
 func (w *Watcher) shouldExclude(path string) bool {
 	name := filepath.Base(path)
 	for _, pattern := range w.excludePatterns {
@@ -154,27 +315,83 @@ func (w *Watcher) handleEvent(event fsnotify.Event) {
 		return
 	}
 
-	// Skip non-code files
-	if w.parser.DetectLanguage(event.Name) == "" {
-		return
+	// New directories (mkdir, git checkout, a directory moved in) are watched
+	// as they appear, and files already inside them are queued
+	if event.Op&fsnotify.Create == fsnotify.Create {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.addNewDir(event.Name)
+			return
+		}
 	}
 
+	isSource := w.parser.DetectLanguage(event.Name) != ""
+
 	switch {
 	case event.Op&fsnotify.Write == fsnotify.Write,
 		event.Op&fsnotify.Create == fsnotify.Create:
-		w.queueFile(event.Name)
+		if isSource {
+			w.queueFile(event.Name)
+		}
 
 	case event.Op&fsnotify.Remove == fsnotify.Remove,
 		event.Op&fsnotify.Rename == fsnotify.Rename:
-		// Handle deletion
+		// The path is gone, so it cannot be stat'ed. Paths without an extension
+		// may have been directories; their stored files are found by prefix.
+		if !isSource && filepath.Ext(event.Name) != "" {
+			return
+		}
+		if !isSource {
+			w.unwatchDir(event.Name)
+		}
+		// Handle deletion; a matching create in the same batch becomes a move
 		w.queueFile(event.Name + "|DELETE")
 	}
 }
 
+// addNewDir watches a directory created after Watch started and queues the
+// source files already in it, which were written before the watch existed.
+func (w *Watcher) addNewDir(dir string) {
+	if err := w.addDirRecursive(dir); err != nil {
+		w.logger.Warn("failed to watch new directory", "dir", dir, "error", err)
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if w.shouldExclude(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && w.parser.DetectLanguage(path) != "" {
+			w.queueFile(path)
+		}
+		return nil
+	})
+}
+
+// unwatchDir drops watches on a removed or renamed directory and everything
+// below it. A renamed directory keeps its inotify watch under the old name,
+// which would report events with stale paths.
+func (w *Watcher) unwatchDir(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for _, watched := range w.watcher.WatchList() {
+		if watched == dir || strings.HasPrefix(watched, prefix) {
+			w.watcher.Remove(watched)
+		}
+	}
+}
+
 func (w *Watcher) queueFile(path string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.pendingFiles[path] = time.Now()
+	now := time.Now()
+	if len(w.pendingFiles) == 0 {
+		w.batchStarted = now
+	}
+	w.pendingFiles[path] = now
+	w.lastQueued = now
 }
 
 func (w *Watcher) processDebounced(ctx context.Context) {
@@ -193,71 +410,288 @@ func (w *Watcher) processDebounced(ctx context.Context) {
 	}
 }
 
+// processPending flushes the queue as one batch once no event has arrived for
+// the debounce interval, so bulk changes such as a branch switch are handled
+// together. Under a constant stream of events the batch is flushed after
+// maxBatchWait anyway.
 func (w *Watcher) processPending(ctx context.Context) {
 	w.mu.Lock()
 	now := time.Now()
 	debounceThreshold := time.Duration(w.debounceMs.Load()) * time.Millisecond
 
-	var toProcess []string
-	for path, queuedAt := range w.pendingFiles {
-		if now.Sub(queuedAt) >= debounceThreshold {
-			toProcess = append(toProcess, path)
-			delete(w.pendingFiles, path)
+	if len(w.pendingFiles) == 0 ||
+		(now.Sub(w.lastQueued) < debounceThreshold && now.Sub(w.batchStarted) < maxBatchWait) {
+		w.mu.Unlock()
+		return
+	}
+
+	var changed, removed []string
+	for path := range w.pendingFiles {
+		if strings.HasSuffix(path, "|DELETE") {
+			removed = append(removed, strings.TrimSuffix(path, "|DELETE"))
+		} else {
+			changed = append(changed, path)
 		}
 	}
+	w.pendingFiles = make(map[string]time.Time)
 	w.mu.Unlock()
 
-	// Process files
-	for _, path := range toProcess {
-		if strings.HasSuffix(path, "|DELETE") {
-			w.handleDelete(ctx, strings.TrimSuffix(path, "|DELETE"))
+	sort.Strings(changed)
+	sort.Strings(removed)
+	w.processBatch(ctx, changed, removed)
+}
+
+// fileMove is a removed file paired with the created file it was renamed to
+type fileMove struct {
+	from string
+	to   string
+}
+
+// processBatch applies one batch of changes: removed files that reappear
+// elsewhere with the same content are moved, the rest are deleted, and all
+// changed files are reindexed together.
+func (w *Watcher) processBatch(ctx context.Context, changed, removed []string) {
+	if len(changed)+len(removed) > 1 {
+		w.logger.Info("processing file changes", "changed", len(changed), "removed", len(removed))
+	}
+
+	// A path removed and recreated in the same batch (editors that save by
+	// rename) is just a change
+	changedSet := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		changedSet[path] = true
+	}
+	var gone []string
+	for _, path := range removed {
+		if _, err := os.Stat(path); err == nil {
+			if !changedSet[path] && w.parser.DetectLanguage(path) != "" {
+				changedSet[path] = true
+				changed = append(changed, path)
+			}
+			continue
+		}
+		gone = append(gone, path)
+	}
+
+	var moves []fileMove
+	if w.storage != nil && len(gone) > 0 {
+		moves, gone, changed = w.detectMoves(ctx, gone, changed)
+	}
+
+	for _, mv := range moves {
+		if err := w.moveFile(ctx, mv); err != nil {
+			w.logger.Warn("failed to move file, reindexing instead", "from", mv.from, "to", mv.to, "error", err)
+			gone = append(gone, mv.from)
+			changed = append(changed, mv.to)
 		} else {
-			if err := w.indexFile(ctx, path); err != nil {
-				log.Printf("Failed to index %s: %v", path, err)
-			} else {
-				log.Printf("Indexed: %s", path)
+			w.logger.Info("moved file", "from", mv.from, "to", mv.to)
+		}
+	}
+
+	for _, path := range gone {
+		w.handleDelete(ctx, path)
+	}
+
+	w.indexBatch(ctx, changed)
+}
+
+// detectMoves expands removed directories to the files stored under them and
+// pairs removed files with new files of identical content. It returns the
+// moves plus the removed and changed paths that were not paired.
+func (w *Watcher) detectMoves(ctx context.Context, gone, changed []string) ([]fileMove, []string, []string) {
+	var removedMeta []graph.FileMetadata
+	var unknown []string
+	for _, path := range gone {
+		if w.parser.DetectLanguage(path) == "" {
+			// Possibly a directory: everything stored under it is gone too
+			under, err := w.storage.GetFileMetadataUnder(ctx, path)
+			if err != nil {
+				w.logger.Warn("failed to look up files under removed path", "path", path, "error", err)
+			}
+			removedMeta = append(removedMeta, under...)
+			continue
+		}
+		meta, err := w.storage.GetFileMetadata(ctx, path)
+		if err != nil || meta == nil {
+			unknown = append(unknown, path)
+			continue
+		}
+		removedMeta = append(removedMeta, *meta)
+	}
+
+	// Hash new files only; a file that already has metadata was not moved here
+	created := make(map[string]string)
+	for _, path := range changed {
+		if meta, err := w.storage.GetFileMetadata(ctx, path); err != nil || meta != nil {
+			continue
+		}
+		if hash, err := util.HashFile(ctx, path); err == nil {
+			created[path] = hash
+		}
+	}
+
+	moves := pairMoves(removedMeta, created)
+
+	movedFrom := make(map[string]bool, len(moves))
+	movedTo := make(map[string]bool, len(moves))
+	for _, mv := range moves {
+		movedFrom[mv.from] = true
+		movedTo[mv.to] = true
+	}
+	remainingGone := unknown
+	for _, meta := range removedMeta {
+		if !movedFrom[meta.FilePath] {
+			remainingGone = append(remainingGone, meta.FilePath)
+		}
+	}
+	var remainingChanged []string
+	for _, path := range changed {
+		if !movedTo[path] {
+			remainingChanged = append(remainingChanged, path)
+		}
+	}
+	return moves, remainingGone, remainingChanged
+}
+
+// pairMoves matches removed files to created files (path to content hash) with
+// the same content and extension. When several candidates match, the one with
+// the same base name wins, then the first by path.
+func pairMoves(removed []graph.FileMetadata, created map[string]string) []fileMove {
+	byHash := make(map[string][]string)
+	for path, hash := range created {
+		byHash[hash] = append(byHash[hash], path)
+	}
+	for _, paths := range byHash {
+		sort.Strings(paths)
+	}
+
+	sorted := make([]graph.FileMetadata, len(removed))
+	copy(sorted, removed)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].FilePath < sorted[j].FilePath })
+
+	used := make(map[string]bool)
+	var moves []fileMove
+	for _, meta := range sorted {
+		if meta.ContentHash == "" {
+			continue
+		}
+		best := ""
+		for _, candidate := range byHash[meta.ContentHash] {
+			if used[candidate] || filepath.Ext(candidate) != filepath.Ext(meta.FilePath) {
+				continue
+			}
+			if filepath.Base(candidate) == filepath.Base(meta.FilePath) {
+				best = candidate
+				break
 			}
+			if best == "" {
+				best = candidate
+			}
+		}
+		if best != "" {
+			used[best] = true
+			moves = append(moves, fileMove{from: meta.FilePath, to: best})
 		}
 	}
+	return moves
 }
 
-func (w *Watcher) indexFile(ctx context.Context, path string) error {
-	// Check for context cancellation before starting work
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+// moveFile re-keys the stored nodes of a moved file and refreshes its metadata
+func (w *Watcher) moveFile(ctx context.Context, mv fileMove) error {
+	moveCtx, cancel := context.WithTimeout(ctx, time.Duration(w.indexTimeoutMs.Load())*time.Millisecond)
+	defer cancel()
+
+	if err := w.storage.MoveFile(moveCtx, mv.from, mv.to); err != nil {
+		return err
+	}
+	w.parser.Forget(mv.from)
+
+	meta, err := w.storage.GetFileMetadata(moveCtx, mv.to)
+	if err != nil || meta == nil {
+		return err
+	}
+	return w.saveFileMetadata(moveCtx, meta)
+}
+
+// indexBatch reindexes files together so that embeddings are requested in
+// batches across files rather than one node at a time. Failures are per file.
+func (w *Watcher) indexBatch(ctx context.Context, paths []string) {
+	var files []*parsedFile
+	var allNodes []*graph.CodeNode
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return
+		}
+		f, err := w.parseFile(ctx, path)
+		if err != nil {
+			w.logger.Error("failed to index file", "file", path, "error", err)
+			continue
+		}
+		files = append(files, f)
+		allNodes = append(allNodes, f.embed...)
+	}
+
+	if err := w.embedNodes(ctx, allNodes); err != nil {
+		return
+	}
+
+	for _, f := range files {
+		if err := w.storeFile(ctx, f); err != nil {
+			w.logger.Error("failed to index file", "file", f.path, "error", err)
+		} else {
+			w.logger.Info("indexed file", "file", f.path)
+		}
 	}
+}
+
+// parsedFile is a file parsed for storing
+type parsedFile struct {
+	path         string
+	nodes        []*graph.CodeNode
+	edges        []*graph.CodeEdge
+	syntaxErrors []graph.SyntaxError
+	errorRatio   float64
+
+	// prev is the metadata of a file whose parse has more syntax errors
+	// than the threshold allows: its stored nodes are kept
+	prev *graph.FileMetadata
+
+	// incremental is set for files with nodes stored: changed are the
+	// nodes to store over them and removed the IDs of those to delete.
+	// embed are the nodes whose embeddings cannot be kept.
+	incremental bool
+	changed     []*graph.CodeNode
+	removed     []string
+	embed       []*graph.CodeNode
+}
 
-	// Parse file
+// parseFile parses path into graph nodes (without embeddings) and edges, and
+// compares them with the nodes stored for it. A file indexed before whose
+// new parse has too many syntax errors gets no nodes, and keeps those
+// stored.
+func (w *Watcher) parseFile(ctx context.Context, path string) (*parsedFile, error) {
 	result, err := w.parser.ParseFile(ctx, path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Generate embeddings for all nodes before the transaction
-	// This is done outside the transaction since embedding generation is I/O intensive
-	nodesWithEmbeddings := make([]*graph.CodeNode, 0, len(result.Nodes))
-	for i := range result.Nodes {
-		// Check for cancellation between nodes to avoid processing all if context is cancelled
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	f := &parsedFile{path: path, errorRatio: result.ErrorRatio}
+	for _, e := range result.SyntaxErrors {
+		f.syntaxErrors = append(f.syntaxErrors, graph.SyntaxError(e))
+	}
+	if result.Degraded(w.errorThreshold) && w.storage != nil {
+		prev, err := w.storage.GetFileMetadata(ctx, path)
+		if err == nil && prev != nil && prev.NodeCount > 0 {
+			w.logger.Warn("too many syntax errors, keeping the last good nodes", "file", path, "error_ratio", result.ErrorRatio)
+			f.prev = prev
+			return f, nil
 		}
+	}
 
+	nodes := make([]*graph.CodeNode, 0, len(result.Nodes))
+	for i := range result.Nodes {
 		node := &result.Nodes[i]
-		// Generate embedding for this node
-		var emb []float32
-		var err error
-		if w.embedding != nil && node.Content != "" {
-			emb, err = w.embedding.EmbedSingle(ctx, node.Content)
-			if err != nil {
-				log.Printf("Warning: failed to generate embedding for %s: %v", node.Name, err)
-				// Continue without embedding rather than failing entirely
-			}
-		}
-		graphNode := &graph.CodeNode{
+		nodes = append(nodes, &graph.CodeNode{
 			ID:          node.ID,
 			Name:        node.Name,
 			NodeType:    graph.NodeType(node.NodeType),
@@ -268,52 +702,244 @@ func (w *Watcher) indexFile(ctx context.Context, path string) error {
 			Content:     node.Content,
 			DocComment:  node.DocComment,
 			Annotations: node.Annotations,
-			Embedding:   emb,
-		}
-		nodesWithEmbeddings = append(nodesWithEmbeddings, graphNode)
+		})
 	}
 
 	// Convert parser edges to graph edges
-	graphEdges := make([]*graph.CodeEdge, 0, len(result.Edges))
+	edges := make([]*graph.CodeEdge, 0, len(result.Edges))
 	for i := range result.Edges {
 		edge := &result.Edges[i]
-		graphEdge := &graph.CodeEdge{
+		edges = append(edges, &graph.CodeEdge{
 			ID:       graph.FormatEdgeID(edge.FromID, edge.ToID, graph.EdgeType(edge.EdgeType)),
 			FromID:   edge.FromID,
 			ToID:     edge.ToID,
 			EdgeType: graph.EdgeType(edge.EdgeType),
 			Weight:   1.0,
+		})
+	}
+	f.nodes, f.edges, f.embed = nodes, edges, nodes
+	if w.storage != nil {
+		stored, err := w.storage.GetNodesByFile(ctx, path)
+		if err == nil && len(stored) > 0 {
+			var shared []graph.CodeNode
+			shared, err = w.storage.GetNodesByIDs(ctx, sharedNodeIDs(path, nodes))
+			stored = append(stored, shared...)
+		}
+		if err != nil {
+			w.logger.Warn("failed to load stored nodes, replacing them all", "file", path, "error", err)
+		} else if len(stored) > 0 {
+			w.diffNodes(f, stored)
 		}
-		graphEdges = append(graphEdges, graphEdge)
 	}
+	return f, nil
+}
 
-	// Atomically update the file: delete old nodes/edges and store new ones in a single transaction
-	if w.storage != nil {
-		if err := w.storage.UpdateFileAtomic(ctx, path, nodesWithEmbeddings, graphEdges); err != nil {
-			return fmt.Errorf("atomic file update failed for %s: %w", path, err)
+// diffNodes compares the nodes of a reparsed file with those stored for it.
+// Nodes stored unchanged are left alone with their embeddings. Changed nodes
+// whose embedded texts are the same, such as those moved by an edit above
+// them, take the stored embeddings, so only the rest are embedded again.
+// stored includes the nodes shared between files, such as external
+// modules, that the file refers to, so they are only written and embedded
+// again when they change.
+func (w *Watcher) diffNodes(f *parsedFile, stored []graph.CodeNode) {
+	byID := make(map[string]*graph.CodeNode, len(stored))
+	for i := range stored {
+		byID[stored[i].ID] = &stored[i]
+	}
+
+	f.incremental = true
+	f.changed, f.removed, f.embed = nil, nil, nil
+	for _, n := range f.nodes {
+		old, ok := byID[n.ID]
+		delete(byID, n.ID)
+		embedded := ok && (len(old.Embedding) > 0 || w.embedding == nil)
+		switch {
+		case embedded && sameNode(old, n):
+			continue
+		case embedded && slices.Equal(w.documents.Texts(old), w.documents.Texts(n)):
+			n.Embedding, n.ChunkEmbeddings = old.Embedding, old.ChunkEmbeddings
+		default:
+			f.embed = append(f.embed, n)
+		}
+		f.changed = append(f.changed, n)
+	}
+	for id := range byID {
+		f.removed = append(f.removed, id)
+	}
+	sort.Strings(f.removed)
+}
+
+// sharedNodeIDs returns the IDs of the nodes of a parsed file that are not
+// stored with it, such as package and external module nodes
+func sharedNodeIDs(path string, nodes []*graph.CodeNode) []string {
+	var ids []string
+	for _, n := range nodes {
+		if n.FilePath != path && !strings.HasPrefix(n.FilePath, path+"#") {
+			ids = append(ids, n.ID)
 		}
 	}
+	return ids
+}
+
+// sameNode reports whether a stored node is the same as a parsed one
+func sameNode(stored, parsed *graph.CodeNode) bool {
+	return stored.Name == parsed.Name &&
+		stored.NodeType == parsed.NodeType &&
+		stored.Language == parsed.Language &&
+		stored.FilePath == parsed.FilePath &&
+		stored.StartLine == parsed.StartLine &&
+		stored.EndLine == parsed.EndLine &&
+		stored.Content == parsed.Content &&
+		stored.DocComment == parsed.DocComment &&
+		maps.Equal(stored.Annotations, parsed.Annotations)
+}
+
+// embedNodes fills in node embeddings, one vector per chunk of each node's
+// document text, sending embedBatchSize texts per request. If a batch request
+// fails its texts are embedded one at a time; a node whose chunks still fail
+// keeps the vectors embedded before the failure. Only cancellation is
+// returned as an error.
+func (w *Watcher) embedNodes(ctx context.Context, nodes []*graph.CodeNode) error {
+	if w.embedding == nil {
+		return nil
+	}
 
+	type chunkText struct {
+		node *graph.CodeNode
+		text string
+	}
+	var pending []chunkText
+	for _, node := range nodes {
+		for _, text := range w.documents.Texts(node) {
+			pending = append(pending, chunkText{node: node, text: text})
+		}
+	}
+
+	vecs := make(map[*graph.CodeNode][][]float32)
+	failed := make(map[*graph.CodeNode]bool)
+	for start := 0; start < len(pending); start += embedBatchSize {
+		// Check for cancellation between batches
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		batch := pending[start:min(start+embedBatchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.text
+		}
+
+		embs, err := w.embedding.Embed(ctx, texts)
+		if err == nil && len(embs) == len(batch) {
+			for i, c := range batch {
+				vecs[c.node] = append(vecs[c.node], embs[i])
+			}
+			continue
+		}
+		if err != nil {
+			w.logger.Warn("batch embedding failed, embedding nodes individually", "nodes", len(batch), "error", err)
+		}
+
+		for _, c := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			// Chunks after a failed one would leave a gap in the node's vectors
+			if failed[c.node] {
+				continue
+			}
+			emb, err := w.embedding.EmbedSingle(ctx, c.text)
+			if err != nil {
+				w.logger.Warn("failed to generate embedding", "node", c.node.Name, "error", err)
+				// Continue without embedding rather than failing entirely
+				failed[c.node] = true
+				continue
+			}
+			vecs[c.node] = append(vecs[c.node], emb)
+		}
+	}
+
+	for _, node := range nodes {
+		node.Embedding, node.ChunkEmbeddings = embedding.SplitVectors(vecs[node])
+	}
+	return nil
+}
+
+// storeFile atomically replaces the stored nodes and edges of a file and
+// records its metadata. A file keeping its last good nodes only has its
+// metadata updated.
+func (w *Watcher) storeFile(ctx context.Context, f *parsedFile) error {
+	if w.storage == nil {
+		return nil
+	}
+
+	meta := &graph.FileMetadata{
+		FilePath:     f.path,
+		NodeCount:    len(f.nodes),
+		EdgeCount:    len(f.edges),
+		SyntaxErrors: f.syntaxErrors,
+		ErrorRatio:   f.errorRatio,
+	}
+	switch {
+	case f.prev != nil:
+		meta.NodeCount, meta.EdgeCount, meta.Stale = f.prev.NodeCount, f.prev.EdgeCount, true
+	case f.incremental:
+		// Only the nodes that changed are written
+		if err := w.storage.UpdateFileNodes(ctx, f.path, f.changed, f.removed, f.edges); err != nil {
+			return fmt.Errorf("incremental file update failed for %s: %w", f.path, err)
+		}
+		w.logger.Debug("updated changed nodes", "file", f.path, "changed", len(f.changed), "removed", len(f.removed), "kept", len(f.nodes)-len(f.changed))
+	default:
+		// Atomically update the file: delete old nodes/edges and store new ones in a single transaction
+		if err := w.storage.UpdateFileAtomic(ctx, f.path, f.nodes, f.edges); err != nil {
+			return fmt.Errorf("atomic file update failed for %s: %w", f.path, err)
+		}
+	}
+
+	// Keep file metadata current so the next incremental index skips this file
+	if err := w.saveFileMetadata(ctx, meta); err != nil {
+		w.logger.Warn("failed to save file metadata", "file", f.path, "error", err)
+	}
 	return nil
 }
 
+// saveFileMetadata records meta with the current state of its file on disk
+func (w *Watcher) saveFileMetadata(ctx context.Context, meta *graph.FileMetadata) error {
+	info, err := os.Stat(meta.FilePath)
+	if err != nil {
+		return err
+	}
+	hash, err := util.HashFile(ctx, meta.FilePath)
+	if err != nil {
+		return err
+	}
+
+	meta.ContentHash = hash
+	meta.ModTime = info.ModTime().Unix()
+	meta.IndexedAt = time.Now().Unix()
+	meta.FileSize = info.Size()
+	meta.Language = string(w.parser.DetectLanguage(meta.FilePath))
+	return w.storage.UpsertFileMetadata(ctx, meta)
+}
+
 func (w *Watcher) handleDelete(ctx context.Context, path string) {
 	// Use a timeout context to avoid blocking indefinitely
-	// This matches the timeout protection used in indexFile
+	// This matches the timeout protection used when indexing
 	indexCtx, cancel := context.WithTimeout(ctx, time.Duration(w.indexTimeoutMs.Load())*time.Millisecond)
 	defer cancel()
 
 	if path == "" {
-		log.Printf("Warning: skipping delete with empty path")
+		w.logger.Warn("skipping delete with empty path")
 		return
 	}
+	w.parser.Forget(path)
 
 	// Only attempt to delete if storage is configured
 	if w.storage != nil {
 		if err := w.storage.UpdateFileAtomic(indexCtx, path, []*graph.CodeNode{}, []*graph.CodeEdge{}); err != nil {
-			log.Printf("Warning: failed to delete file %s atomically: %v", path, err)
+			w.logger.Warn("failed to delete file atomically", "file", path, "error", err)
 		} else {
-			log.Printf("Deleted: %s", path)
+			w.logger.Info("deleted file", "file", path)
 		}
 	}
 }
@@ -1,14 +1,23 @@
 package daemon
 
 import (
+	"bytes"
 	"context"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
 	"github.com/heefoo/codeloom/internal/parser"
+	"github.com/heefoo/codeloom/internal/util"
 )
 
 // TestWatcherEdgeIDFormat verifies that edge IDs generated during file watching
@@ -31,7 +40,7 @@ func TestWatcherEdgeIDFormat(t *testing.T) {
 	}
 
 	// Verify edge ID format includes edge type
-	// This mirrors what happens in watcher.indexFile function
+	// This mirrors what happens in watcher.parseFile
 	ids := make(map[string]bool)
 	for _, edge := range result.Edges {
 		id := graph.FormatEdgeID(edge.FromID, edge.ToID, graph.EdgeType(edge.EdgeType))
@@ -129,31 +138,62 @@ func bar() string {
 	time.Sleep(100 * time.Millisecond)
 }
 
-// TestWatcherContextCancellation verifies that indexFile respects context cancellation
-func TestWatcherContextCancellation(t *testing.T) {
-	// This test verifies that our context checks in indexFile work correctly
+// countingProvider counts the texts it is asked to embed
+type countingProvider struct {
+	embedding.Provider
+	texts atomic.Int64
+}
 
-	// Create a cancelled context
-	ctx, cancel := context.WithCancel(context.Background())
-	cancel() // Cancel immediately
+func (p *countingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	p.texts.Add(int64(len(texts)))
+	return p.Provider.Embed(ctx, texts)
+}
+
+func (p *countingProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	p.texts.Add(1)
+	return p.Provider.EmbedSingle(ctx, text)
+}
 
-	// Create watcher
+// TestWatcherContextCancellation verifies that a batch of changes is not
+// parsed, embedded or stored once the watcher's context is cancelled
+func TestWatcherContextCancellation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := embedding.NewHashProvider(config.EmbeddingConfig{Dimension: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &countingProvider{Provider: hash}
+	var logs bytes.Buffer
 	w, err := NewWatcher(WatcherConfig{
 		Parser:     parser.NewParser(),
+		Embedding:  provider,
 		DebounceMs: 10,
+		Logger:     slog.New(slog.NewTextHandler(&logs, nil)),
 	})
 	if err != nil {
 		t.Fatalf("Failed to create watcher: %v", err)
 	}
+	defer w.Stop()
 
-	// Try to index with cancelled context
-	// Should return immediately with context.Canceled error
-	err = w.indexFile(ctx, "/fake/path/test.go")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.processBatch(ctx, []string{path}, nil)
+	if n := provider.texts.Load(); n != 0 {
+		t.Errorf("embedded %d texts after cancellation", n)
+	}
+	if strings.Contains(logs.String(), "indexed file") {
+		t.Errorf("indexed a file after cancellation:\n%s", logs.String())
+	}
 
-	if err == nil {
-		t.Error("indexFile should return error when context is cancelled")
-	} else if err != context.Canceled {
-		t.Errorf("Expected context.Canceled error, got: %v", err)
+	// The same batch is indexed with a live context
+	w.processBatch(context.Background(), []string{path}, nil)
+	if provider.texts.Load() == 0 || !strings.Contains(logs.String(), "indexed file") {
+		t.Errorf("expected the file to be indexed:\n%s", logs.String())
 	}
 }
 
@@ -264,3 +304,349 @@ func TestWatcherDeleteContextCancellation(t *testing.T) {
 		t.Fatal("handleDelete did not complete within 1 second - likely blocking or not respecting context cancellation")
 	}
 }
+
+// TestWatcherDiffTree verifies that reconciling a watched tree against stored
+// metadata finds new, modified and deleted files and skips unchanged ones
+func TestWatcherDiffTree(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	unchanged := write("same.go", "package a\n")
+	touched := write("touched.go", "package a\n")
+	modified := write("modified.go", "package a\nfunc B() {}\n")
+	added := write("added.go", "package a\n")
+	write("vendor/dep.go", "package dep\n")
+	write("notes.txt", "not source")
+	deletedPath := filepath.Join(tmpDir, "gone.go")
+	outside := "/elsewhere/other.go"
+
+	w, err := NewWatcher(WatcherConfig{
+		Parser:          parser.NewParser(),
+		ExcludePatterns: []string{"vendor"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Stop()
+
+	ctx := context.Background()
+	meta := func(path string) graph.FileMetadata {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := util.HashFile(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return graph.FileMetadata{FilePath: path, ModTime: info.ModTime().Unix(), ContentHash: hash}
+	}
+
+	touchedMeta := meta(touched)
+	touchedMeta.ModTime-- // mtime changed but content did not
+	modifiedMeta := meta(modified)
+	modifiedMeta.ModTime--
+	modifiedMeta.ContentHash = "stale"
+
+	stored := []graph.FileMetadata{
+		meta(unchanged),
+		touchedMeta,
+		modifiedMeta,
+		{FilePath: deletedPath},
+		{FilePath: outside},
+	}
+
+	changed, deleted, unchangedCount := w.diffTree(ctx, []string{tmpDir}, stored)
+
+	sort.Strings(changed)
+	wantChanged := []string{added, modified}
+	sort.Strings(wantChanged)
+	if strings.Join(changed, ",") != strings.Join(wantChanged, ",") {
+		t.Errorf("changed = %v, want %v", changed, wantChanged)
+	}
+	if len(deleted) != 1 || deleted[0] != deletedPath {
+		t.Errorf("deleted = %v, want [%s] (files outside watched dirs must be kept)", deleted, deletedPath)
+	}
+	if unchangedCount != 2 {
+		t.Errorf("unchanged = %d, want 2", unchangedCount)
+	}
+}
+
+// TestWatcherDeleteThenRestart verifies that a deleted file leaves no
+// metadata behind: a restarted watcher neither deletes it again nor refuses
+// to pair a file later moved to its path
+func TestWatcherDeleteThenRestart(t *testing.T) {
+	storage := graphtest.Storage(t)
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	w, err := NewWatcher(WatcherConfig{Parser: parser.NewParser(), Storage: storage})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Stop()
+
+	path := filepath.Join(tmpDir, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc A() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.processBatch(ctx, []string{path}, nil)
+	if meta, err := storage.GetFileMetadata(ctx, path); err != nil || meta == nil {
+		t.Fatalf("expected %s to be indexed, got %v (%v)", path, meta, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	w.processBatch(ctx, nil, []string{path})
+	if meta, err := storage.GetFileMetadata(ctx, path); err != nil || meta != nil {
+		t.Fatalf("expected the metadata of %s to be deleted, got %+v (%v)", path, meta, err)
+	}
+
+	restarted, err := NewWatcher(WatcherConfig{Parser: parser.NewParser(), Storage: storage})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer restarted.Stop()
+	result, err := restarted.Reconcile(ctx, []string{tmpDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Deleted != 0 {
+		t.Errorf("expected nothing left to delete after a restart, got %+v", result)
+	}
+
+	// Another file moved to the deleted path pairs as a move
+	other := filepath.Join(tmpDir, "b.go")
+	if err := os.WriteFile(other, []byte("package a\n\nfunc B() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restarted.processBatch(ctx, []string{other}, nil)
+	if err := os.Rename(other, path); err != nil {
+		t.Fatal(err)
+	}
+	moves, gone, changed := restarted.detectMoves(ctx, []string{other}, []string{path})
+	if len(moves) != 1 || moves[0] != (fileMove{from: other, to: path}) || len(gone)+len(changed) != 0 {
+		t.Errorf("expected %s to move to %s, got moves %v, gone %v, changed %v", other, path, moves, gone, changed)
+	}
+}
+
+// TestPairMoves verifies that removed files are paired with created files of
+// identical content, preferring the same base name
+func TestPairMoves(t *testing.T) {
+	removed := []graph.FileMetadata{
+		{FilePath: "/src/old/util.go", ContentHash: "h1"},
+		{FilePath: "/src/old/main.go", ContentHash: "h2"},
+		{FilePath: "/src/old/empty.go", ContentHash: "h3"},
+		{FilePath: "/src/old/gone.go", ContentHash: "h4"},
+		{FilePath: "/src/old/nohash.go"},
+	}
+	created := map[string]string{
+		"/src/new/helpers.go": "h1", // same content, different name
+		"/src/new/main.go":    "h2",
+		"/src/new/a_main.go":  "h2", // loses to the same base name
+		"/src/new/empty.py":   "h3", // different extension
+		"/src/new/fresh.go":   "h5",
+	}
+
+	moves := pairMoves(removed, created)
+
+	want := map[string]string{
+		"/src/old/util.go": "/src/new/helpers.go",
+		"/src/old/main.go": "/src/new/main.go",
+	}
+	if len(moves) != len(want) {
+		t.Fatalf("got %d moves %v, want %d", len(moves), moves, len(want))
+	}
+	for _, mv := range moves {
+		if want[mv.from] != mv.to {
+			t.Errorf("move %s -> %s, want -> %s", mv.from, mv.to, want[mv.from])
+		}
+	}
+}
+
+// pendingSnapshot returns a copy of the watcher's queued paths
+func pendingSnapshot(w *Watcher) map[string]bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make(map[string]bool, len(w.pendingFiles))
+	for path := range w.pendingFiles {
+		out[path] = true
+	}
+	return out
+}
+
+func waitForPending(t *testing.T, w *Watcher, path string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pendingSnapshot(w)[path] {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s was not queued; pending: %v", path, pendingSnapshot(w))
+}
+
+// TestWatcherNewDirectoryAndRename verifies that directories created after
+// Watch starts are watched and that renames queue both paths
+func TestWatcherNewDirectoryAndRename(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Long debounce keeps events queued so the test can inspect them
+	w, err := NewWatcher(WatcherConfig{
+		Parser:     parser.NewParser(),
+		DebounceMs: 60000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Watch(ctx, []string{tmpDir})
+	time.Sleep(100 * time.Millisecond)
+
+	// A file written into a directory created after Watch started
+	subDir := filepath.Join(tmpDir, "pkg", "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	newFile := filepath.Join(subDir, "new.go")
+	if err := os.WriteFile(newFile, []byte("package sub\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForPending(t, w, newFile)
+
+	watched := strings.Join(w.watcher.WatchList(), "\n")
+	if !strings.Contains(watched, subDir) {
+		t.Errorf("expected %s to be watched, watch list:\n%s", subDir, watched)
+	}
+
+	// A rename queues a delete of the old path and a create of the new one
+	renamed := filepath.Join(subDir, "renamed.go")
+	if err := os.Rename(newFile, renamed); err != nil {
+		t.Fatal(err)
+	}
+	waitForPending(t, w, newFile+"|DELETE")
+	waitForPending(t, w, renamed)
+}
+
+// TestWatcherBatchesUntilQuiet verifies that pending changes are held while
+// events keep arriving and flushed together once the queue is quiet
+func TestWatcherBatchesUntilQuiet(t *testing.T) {
+	w, err := NewWatcher(WatcherConfig{
+		Parser:     parser.NewParser(),
+		DebounceMs: 200,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Stop()
+
+	ctx := context.Background()
+	w.queueFile("/nonexistent/a.go")
+	w.queueFile("/nonexistent/b.go")
+
+	w.processPending(ctx)
+	if len(pendingSnapshot(w)) != 2 {
+		t.Fatal("expected changes to stay queued within the debounce interval")
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	w.processPending(ctx)
+	if n := len(pendingSnapshot(w)); n != 0 {
+		t.Fatalf("expected the whole batch to be flushed, %d still pending", n)
+	}
+}
+
+// TestWatcherDiffNodes verifies that a reparsed file only writes the nodes
+// that changed, and keeps the embeddings of nodes whose text did not
+func TestWatcherDiffNodes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shapes.go")
+	write := func(code string) {
+		if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w, err := NewWatcher(WatcherConfig{
+		Parser: parser.NewParser(parser.WithTreeCache(parser.NewTreeCache(parser.DefaultTreeCacheSize))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	write("package shapes\n\nimport \"strings\"\n\nfunc A() int { return 1 }\n\nfunc B() int { return 2 }\n\nfunc C() int { return 3 }\n\nfunc E() int { return 5 }\n")
+	first, err := w.parseFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stored nodes include those shared with other files, which the
+	// watcher looks up by ID
+	var stored []graph.CodeNode
+	shared := make(map[string]bool)
+	for _, n := range first.nodes {
+		n.Embedding = []float32{float32(len(stored) + 1)}
+		stored = append(stored, *n)
+		if n.FilePath != path {
+			shared[n.ID] = true
+		}
+	}
+	if len(shared) == 0 {
+		t.Fatal("expected the file to refer to a shared module node")
+	}
+	if ids := sharedNodeIDs(path, first.nodes); len(ids) != len(shared) || !shared[ids[0]] {
+		t.Errorf("expected the shared nodes %v to be looked up, got %v", shared, ids)
+	}
+
+	write("package shapes\n\nimport \"strings\"\n\nfunc A() int { return 1 }\n\nfunc B() int {\n\treturn 20\n}\n\nfunc C() int { return 3 }\n\nfunc D() int { return 4 }\n")
+	f, err := w.parseFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.diffNodes(f, stored)
+
+	ids := func(nodes []*graph.CodeNode) map[string]*graph.CodeNode {
+		m := make(map[string]*graph.CodeNode)
+		for _, n := range nodes {
+			m[strings.TrimPrefix(n.ID, path+"::")] = n
+		}
+		return m
+	}
+	changed, embed := ids(f.changed), ids(f.embed)
+	if _, ok := changed["A"]; ok {
+		t.Error("expected A, unchanged, not to be written")
+	}
+	if _, ok := embed["B"]; !ok {
+		t.Error("expected B, edited, to be embedded again")
+	}
+	if c, ok := changed["C"]; !ok || embed["C"] != nil || len(c.Embedding) == 0 {
+		t.Errorf("expected C, moved, to be written with its stored embedding, got %+v", c)
+	}
+	if _, ok := embed["D"]; !ok {
+		t.Error("expected D, new, to be embedded")
+	}
+	for _, n := range f.changed {
+		if shared[n.ID] {
+			t.Errorf("expected shared node %s, unchanged, not to be written", n.ID)
+		}
+	}
+	if !f.incremental || len(f.removed) != 1 || f.removed[0] != path+"::E" {
+		t.Errorf("expected E removed, got %v", f.removed)
+	}
+}
@@ -0,0 +1,357 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// bertModel is a pure-Go BERT encoder loaded from GGUF weights, as produced
+// by llama.cpp's converter for BERT-architecture embedding models (for
+// example all-MiniLM, bge-small or codebert).
+type bertModel struct {
+	dim     int
+	heads   int
+	ffn     int
+	maxPos  int
+	eps     float32
+	cls     bool // CLS pooling instead of mean pooling
+	threads int
+
+	tokEmb, posEmb, typeEmb []float32
+	embNormW, embNormB      []float32
+	layers                  []bertLayer
+
+	tokenizer *wordPiece
+}
+
+type bertLayer struct {
+	qW, qB, kW, kB, vW, vB []float32
+	oW, oB                 []float32
+	attnNormW, attnNormB   []float32
+	upW, upB, downW, downB []float32
+	outNormW, outNormB     []float32
+}
+
+// llama.cpp pooling types
+const (
+	poolingMean = 1
+	poolingCLS  = 2
+)
+
+func loadBERT(path string, threads int) (*bertModel, error) {
+	f, err := readGGUF(path)
+	if err != nil {
+		return nil, err
+	}
+
+	arch := f.metaString("general.architecture")
+	if arch != "bert" {
+		return nil, fmt.Errorf("unsupported GGUF architecture %q (only bert)", arch)
+	}
+
+	dim, _ := f.metaUint("bert.embedding_length")
+	heads, _ := f.metaUint("bert.attention.head_count")
+	blocks, _ := f.metaUint("bert.block_count")
+	ffn, _ := f.metaUint("bert.feed_forward_length")
+	maxPos, _ := f.metaUint("bert.context_length")
+	if dim == 0 || heads == 0 || blocks == 0 || ffn == 0 || dim%heads != 0 {
+		return nil, fmt.Errorf("GGUF model is missing BERT hyperparameters")
+	}
+	eps := 1e-12
+	if v, ok := f.metaFloat("bert.attention.layer_norm_epsilon"); ok {
+		eps = v
+	}
+	pooling, _ := f.metaUint("bert.pooling_type")
+
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	m := &bertModel{
+		dim:     int(dim),
+		heads:   int(heads),
+		ffn:     int(ffn),
+		maxPos:  int(maxPos),
+		eps:     float32(eps),
+		cls:     pooling == poolingCLS,
+		threads: threads,
+	}
+
+	// The converter stores word-initial pieces with a "▁" prefix and
+	// continuation pieces bare
+	tokens := f.metaStrings("tokenizer.ggml.tokens")
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("GGUF model has no tokenizer vocabulary")
+	}
+	m.tokenizer, err = newWordPiece(tokens, true, "▁", "")
+	if err != nil {
+		return nil, err
+	}
+
+	w := tensorLoader{f: f}
+	m.tokEmb = w.get("token_embd.weight", int(dim)*len(tokens))
+	m.typeEmb = w.optional("token_types.weight")
+	m.posEmb = w.get("position_embd.weight", -1)
+	m.embNormW = w.get("token_embd_norm.weight", int(dim))
+	m.embNormB = w.get("token_embd_norm.bias", int(dim))
+	if m.maxPos == 0 {
+		m.maxPos = len(m.posEmb) / m.dim
+	}
+
+	d, h := int(dim), int(ffn)
+	for i := 0; i < int(blocks); i++ {
+		p := fmt.Sprintf("blk.%d.", i)
+		m.layers = append(m.layers, bertLayer{
+			qW: w.get(p+"attn_q.weight", d*d), qB: w.get(p+"attn_q.bias", d),
+			kW: w.get(p+"attn_k.weight", d*d), kB: w.get(p+"attn_k.bias", d),
+			vW: w.get(p+"attn_v.weight", d*d), vB: w.get(p+"attn_v.bias", d),
+			oW: w.get(p+"attn_output.weight", d*d), oB: w.get(p+"attn_output.bias", d),
+			attnNormW: w.get(p+"attn_output_norm.weight", d), attnNormB: w.get(p+"attn_output_norm.bias", d),
+			upW: w.get(p+"ffn_up.weight", d*h), upB: w.get(p+"ffn_up.bias", h),
+			downW: w.get(p+"ffn_down.weight", d*h), downB: w.get(p+"ffn_down.bias", d),
+			outNormW: w.get(p+"layer_output_norm.weight", d), outNormB: w.get(p+"layer_output_norm.bias", d),
+		})
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return m, nil
+}
+
+// tensorLoader fetches tensors by name, recording the first error
+type tensorLoader struct {
+	f   *ggufFile
+	err error
+}
+
+func (l *tensorLoader) get(name string, size int) []float32 {
+	t, ok := l.f.tensors[name]
+	if !ok {
+		if l.err == nil {
+			l.err = fmt.Errorf("GGUF model is missing tensor %s", name)
+		}
+		return nil
+	}
+	if size >= 0 && len(t.data) != size {
+		if l.err == nil {
+			l.err = fmt.Errorf("GGUF tensor %s has %d values, expected %d", name, len(t.data), size)
+		}
+		return nil
+	}
+	return t.data
+}
+
+func (l *tensorLoader) optional(name string) []float32 {
+	return l.f.tensors[name].data
+}
+
+func (m *bertModel) dimension() int { return m.dim }
+
+func (m *bertModel) maxTokens() int { return m.maxPos }
+
+func (m *bertModel) wordPiece() *wordPiece { return m.tokenizer }
+
+func (m *bertModel) close() error { return nil }
+
+// encodeBatch runs each sequence through the encoder. Sequences are encoded
+// independently, so no padding is needed; parallelism comes from splitting
+// the matrix multiplications across threads.
+func (m *bertModel) encodeBatch(ctx context.Context, batch [][]int32) ([][]float32, error) {
+	out := make([][]float32, len(batch))
+	for i, ids := range batch {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vec, err := m.encode(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+// encode returns the pooled (not normalized) embedding of one sequence
+func (m *bertModel) encode(ctx context.Context, ids []int32) ([]float32, error) {
+	n, d := len(ids), m.dim
+	if n == 0 {
+		return nil, fmt.Errorf("cannot embed empty token sequence")
+	}
+	if n > m.maxPos {
+		return nil, fmt.Errorf("sequence of %d tokens exceeds model context of %d", n, m.maxPos)
+	}
+
+	// Embeddings: token + position + token type 0, then LayerNorm
+	x := make([]float32, n*d)
+	vocab := len(m.tokEmb) / d
+	for t, id := range ids {
+		if int(id) < 0 || int(id) >= vocab {
+			return nil, fmt.Errorf("token id %d out of range", id)
+		}
+		row := x[t*d : (t+1)*d]
+		copy(row, m.tokEmb[int(id)*d:(int(id)+1)*d])
+		addInto(row, m.posEmb[t*d:(t+1)*d])
+		if len(m.typeEmb) >= d {
+			addInto(row, m.typeEmb[:d])
+		}
+		layerNorm(row, m.embNormW, m.embNormB, m.eps)
+	}
+
+	for i := range m.layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		x = m.layer(&m.layers[i], x, n)
+	}
+
+	pooled := make([]float32, d)
+	if m.cls {
+		copy(pooled, x[:d])
+		return pooled, nil
+	}
+	for t := 0; t < n; t++ {
+		addInto(pooled, x[t*d:(t+1)*d])
+	}
+	for j := range pooled {
+		pooled[j] /= float32(n)
+	}
+	return pooled, nil
+}
+
+// layer applies one post-LayerNorm transformer block
+func (m *bertModel) layer(l *bertLayer, x []float32, n int) []float32 {
+	d := m.dim
+	hd := d / m.heads
+
+	q := m.linear(x, n, d, d, l.qW, l.qB)
+	k := m.linear(x, n, d, d, l.kW, l.kB)
+	v := m.linear(x, n, d, d, l.vW, l.vB)
+
+	// Self-attention, one head per task
+	ctxOut := make([]float32, n*d)
+	scale := float32(1 / math.Sqrt(float64(hd)))
+	parallel(m.heads, m.threads, func(h int) {
+		scores := make([]float32, n)
+		off := h * hd
+		for i := 0; i < n; i++ {
+			qi := q[i*d+off : i*d+off+hd]
+			maxScore := float32(math.Inf(-1))
+			for j := 0; j < n; j++ {
+				s := dot(qi, k[j*d+off:j*d+off+hd]) * scale
+				scores[j] = s
+				if s > maxScore {
+					maxScore = s
+				}
+			}
+			var sum float32
+			for j := range scores {
+				scores[j] = float32(math.Exp(float64(scores[j] - maxScore)))
+				sum += scores[j]
+			}
+			out := ctxOut[i*d+off : i*d+off+hd]
+			for j := 0; j < n; j++ {
+				wgt := scores[j] / sum
+				vj := v[j*d+off : j*d+off+hd]
+				for c := range out {
+					out[c] += wgt * vj[c]
+				}
+			}
+		}
+	})
+
+	attn := m.linear(ctxOut, n, d, d, l.oW, l.oB)
+	for t := 0; t < n; t++ {
+		row := attn[t*d : (t+1)*d]
+		addInto(row, x[t*d:(t+1)*d])
+		layerNorm(row, l.attnNormW, l.attnNormB, m.eps)
+	}
+
+	up := m.linear(attn, n, d, m.ffn, l.upW, l.upB)
+	for i, val := range up {
+		up[i] = gelu(val)
+	}
+	down := m.linear(up, n, m.ffn, d, l.downW, l.downB)
+	for t := 0; t < n; t++ {
+		row := down[t*d : (t+1)*d]
+		addInto(row, attn[t*d:(t+1)*d])
+		layerNorm(row, l.outNormW, l.outNormB, m.eps)
+	}
+	return down
+}
+
+// linear computes y = x·Wᵀ + b for n rows, with W stored as [out][in]
+func (m *bertModel) linear(x []float32, n, in, out int, w, b []float32) []float32 {
+	y := make([]float32, n*out)
+	parallel(out, m.threads, func(o int) {
+		wo := w[o*in : (o+1)*in]
+		bias := b[o]
+		for t := 0; t < n; t++ {
+			y[t*out+o] = dot(x[t*in:(t+1)*in], wo) + bias
+		}
+	})
+	return y
+}
+
+// parallel runs fn(0..n-1) across up to threads goroutines
+func parallel(n, threads int, fn func(i int)) {
+	if threads > n {
+		threads = n
+	}
+	if threads <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	chunk := (n + threads - 1) / threads
+	for start := 0; start < n; start += chunk {
+		end := min(start+chunk, n)
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				fn(i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+func dot(a, b []float32) float32 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+func addInto(dst, src []float32) {
+	for i := range dst {
+		dst[i] += src[i]
+	}
+}
+
+func layerNorm(x, w, b []float32, eps float32) {
+	var mean float32
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float32(len(x))
+	var variance float32
+	for _, v := range x {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float32(len(x))
+	inv := float32(1 / math.Sqrt(float64(variance+eps)))
+	for i := range x {
+		x[i] = (x[i]-mean)*inv*w[i] + b[i]
+	}
+}
+
+// gelu is the exact (erf) GELU used by BERT
+func gelu(x float32) float32 {
+	return 0.5 * x * (1 + float32(math.Erf(float64(x)/math.Sqrt2)))
+}
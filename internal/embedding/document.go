@@ -0,0 +1,162 @@
+package embedding
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/graph"
+)
+
+// DefaultDocumentTemplate puts what a node is and where it lives ahead of its
+// body, so that embeddings match on names, paths and documentation as well
+// as code.
+const DefaultDocumentTemplate = `{{.NodeType}} {{.Name}}{{with .Language}} ({{.}}){{end}}
+{{with .FilePath}}{{.}}
+{{end}}{{with .DocComment}}{{.}}
+{{end}}{{range $k, $v := .Annotations}}@{{$k}}{{with $v}} {{.}}{{end}}
+{{end}}{{.Body}}`
+
+// Document is the data available to a document template. Body is one chunk
+// of the node's content; Chunk counts from 1.
+type Document struct {
+	Name        string
+	NodeType    string
+	Language    string
+	FilePath    string
+	DocComment  string
+	Annotations map[string]string
+	Body        string
+	Chunk       int
+	Chunks      int
+}
+
+// DocumentBuilder renders nodes into the texts embedded for them
+type DocumentBuilder struct {
+	tmpl       *template.Template
+	chunkChars int
+	maxChunks  int
+}
+
+// NewDocumentBuilder creates a builder from the embedding settings. Zero
+// values select DefaultDocumentTemplate, 2000-character chunks and at most
+// 4 chunks per node.
+func NewDocumentBuilder(cfg config.EmbeddingConfig) (*DocumentBuilder, error) {
+	text := cfg.DocumentTemplate
+	if text == "" {
+		text = DefaultDocumentTemplate
+	}
+	tmpl, err := template.New("document").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid document template: %w", err)
+	}
+	// Catch references to unknown fields now rather than on every node
+	if err := tmpl.Execute(&strings.Builder{}, Document{}); err != nil {
+		return nil, fmt.Errorf("invalid document template: %w", err)
+	}
+
+	b := &DocumentBuilder{tmpl: tmpl, chunkChars: cfg.ChunkChars, maxChunks: cfg.MaxChunks}
+	if b.chunkChars <= 0 {
+		b.chunkChars = 2000
+	}
+	if b.maxChunks <= 0 {
+		b.maxChunks = 4
+	}
+	return b, nil
+}
+
+// DefaultDocumentBuilder returns a builder with the default settings
+func DefaultDocumentBuilder() *DocumentBuilder {
+	b, _ := NewDocumentBuilder(config.EmbeddingConfig{})
+	return b
+}
+
+// Texts returns one text per chunk of the node's content, or nil for nodes
+// without content. Content beyond the last chunk is dropped.
+func (b *DocumentBuilder) Texts(node *graph.CodeNode) []string {
+	if strings.TrimSpace(node.Content) == "" {
+		return nil
+	}
+
+	chunks := splitChunks(node.Content, b.chunkChars, b.maxChunks)
+	texts := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		doc := Document{
+			Name:        node.Name,
+			NodeType:    string(node.NodeType),
+			Language:    node.Language,
+			FilePath:    node.FilePath,
+			DocComment:  strings.TrimSpace(node.DocComment),
+			Annotations: node.Annotations,
+			Body:        chunk,
+			Chunk:       i + 1,
+			Chunks:      len(chunks),
+		}
+		var sb strings.Builder
+		if err := b.tmpl.Execute(&sb, doc); err != nil {
+			// The template was checked at construction; embed the raw chunk
+			texts = append(texts, chunk)
+			continue
+		}
+		texts = append(texts, sb.String())
+	}
+	return texts
+}
+
+// splitChunks splits text at line boundaries into at most maxChunks pieces
+// of at most size bytes. Lines longer than size are split at rune boundaries.
+func splitChunks(text string, size, maxChunks int) []string {
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if strings.TrimSpace(cur.String()) != "" {
+			chunks = append(chunks, strings.TrimRight(cur.String(), "\n"))
+		}
+		cur.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for len(line) > 0 && len(chunks) < maxChunks {
+			if cur.Len()+len(line) <= size {
+				cur.WriteString(line)
+				break
+			}
+			if cur.Len() > 0 {
+				flush()
+				continue
+			}
+			// A single line longer than a chunk
+			cut := size
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = size
+			}
+			cur.WriteString(line[:cut])
+			line = line[cut:]
+			flush()
+		}
+		if len(chunks) >= maxChunks {
+			break
+		}
+	}
+	if len(chunks) < maxChunks {
+		flush()
+	}
+	return chunks
+}
+
+// SplitVectors divides the vectors embedded from a node's texts into its
+// embedding and chunk embeddings
+func SplitVectors(vecs [][]float32) ([]float32, [][]float32) {
+	if len(vecs) == 0 {
+		return nil, nil
+	}
+	if len(vecs) == 1 {
+		return vecs[0], nil
+	}
+	return vecs[0], vecs[1:]
+}
@@ -0,0 +1,133 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/graph"
+)
+
+func TestDocumentBuilderTexts(t *testing.T) {
+	b := DefaultDocumentBuilder()
+	node := &graph.CodeNode{
+		Name:        "ParseConfig",
+		NodeType:    graph.NodeTypeFunction,
+		Language:    "go",
+		FilePath:    "internal/config/config.go",
+		DocComment:  "// ParseConfig reads the TOML file",
+		Annotations: map[string]string{"deprecated": ""},
+		Content:     "func ParseConfig() {}",
+	}
+
+	texts := b.Texts(node)
+	if len(texts) != 1 {
+		t.Fatalf("expected 1 text, got %d", len(texts))
+	}
+	want := "function ParseConfig (go)\ninternal/config/config.go\n// ParseConfig reads the TOML file\n@deprecated\nfunc ParseConfig() {}"
+	if texts[0] != want {
+		t.Errorf("Texts() = %q, want %q", texts[0], want)
+	}
+
+	if texts := b.Texts(&graph.CodeNode{Name: "empty", Content: "  "}); texts != nil {
+		t.Errorf("expected no texts for a node without content, got %v", texts)
+	}
+}
+
+func TestDocumentBuilderChunks(t *testing.T) {
+	b, err := NewDocumentBuilder(config.EmbeddingConfig{
+		DocumentTemplate: "{{.Name}} {{.Chunk}}/{{.Chunks}}\n{{.Body}}",
+		ChunkChars:       20,
+		MaxChunks:        3,
+	})
+	if err != nil {
+		t.Fatalf("NewDocumentBuilder failed: %v", err)
+	}
+
+	lines := []string{"line one", "line two", "line three", "line four", "line five", "line six", "line seven"}
+	texts := b.Texts(&graph.CodeNode{Name: "f", Content: strings.Join(lines, "\n")})
+	want := []string{
+		"f 1/3\nline one\nline two",
+		"f 2/3\nline three",
+		"f 3/3\nline four\nline five",
+	}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("Texts() = %q, want %q", texts, want)
+	}
+
+	// A single long line is split at rune boundaries
+	chunks := splitChunks(strings.Repeat("é", 15), 7, 10)
+	for _, c := range chunks {
+		if !strings.HasPrefix(c, "é") || len(c) > 7 {
+			t.Errorf("bad chunk %q in %q", c, chunks)
+		}
+	}
+	if strings.Join(chunks, "") != strings.Repeat("é", 15) {
+		t.Errorf("chunks %q lost content", chunks)
+	}
+}
+
+func TestNewDocumentBuilderRejectsBadTemplates(t *testing.T) {
+	for _, tmpl := range []string{"{{.Name", "{{.Signature}}"} {
+		if _, err := NewDocumentBuilder(config.EmbeddingConfig{DocumentTemplate: tmpl}); err == nil {
+			t.Errorf("expected error for template %q", tmpl)
+		}
+	}
+}
+
+func TestTaskPrefixes(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaEmbedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		prompts = append(prompts, req.Prompt)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(ollamaEmbedResponse{Embedding: []float32{1, 0}})
+	}))
+	defer server.Close()
+
+	cfg := config.EmbeddingConfig{Provider: "ollama", Model: "nomic-embed-text", BaseURL: server.URL, Dimension: 2}
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	prompts = nil // drop NewProvider's reachability probe
+	ctx := context.Background()
+	p.EmbedQuery(ctx, "parse config")
+	p.EmbedSingle(ctx, "func ParseConfig()")
+	if len(prompts) != 2 || prompts[0] != "search_query: parse config" || prompts[1] != "search_document: func ParseConfig()" {
+		t.Errorf("unexpected prompts %q", prompts)
+	}
+
+	tests := []struct {
+		cfg             config.EmbeddingConfig
+		query, document string
+	}{
+		{config.EmbeddingConfig{Model: "/models/multilingual-e5-small.onnx"}, "query: ", "passage: "},
+		{config.EmbeddingConfig{Model: "nomic-embed-text", QueryPrefix: "none", DocumentPrefix: "none"}, "", ""},
+		{config.EmbeddingConfig{Model: "bge-m3", QueryPrefix: "Q: "}, "Q: ", ""},
+		{config.EmbeddingConfig{Model: "text-embedding-3-small"}, "", ""},
+	}
+	for _, tt := range tests {
+		query, document := taskPrefixes(tt.cfg)
+		if query != tt.query || document != tt.document {
+			t.Errorf("taskPrefixes(%q) = %q, %q; want %q, %q", tt.cfg.Model, query, document, tt.query, tt.document)
+		}
+	}
+
+	// The hash fallback ignores the configured model's prefixes
+	cfg.Provider = "hash"
+	hp, _ := NewProvider(cfg)
+	q, _ := hp.EmbedQuery(ctx, "parse config")
+	d, _ := hp.EmbedSingle(ctx, "parse config")
+	if cosine(q, d) < 0.999 {
+		t.Error("expected hash query and document embeddings of the same text to match")
+	}
+}
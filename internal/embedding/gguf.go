@@ -0,0 +1,338 @@
+package embedding
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ggufFile is a parsed GGUF model: metadata key/values and tensors
+// dequantized to float32. Only the subset of the format used by embedding
+// models is supported.
+type ggufFile struct {
+	meta    map[string]any
+	tensors map[string]ggufTensor
+}
+
+type ggufTensor struct {
+	dims []uint64 // dims[0] varies fastest
+	data []float32
+}
+
+// GGUF metadata value types
+const (
+	ggufUint8   = 0
+	ggufInt8    = 1
+	ggufUint16  = 2
+	ggufInt16   = 3
+	ggufUint32  = 4
+	ggufInt32   = 5
+	ggufFloat32 = 6
+	ggufBool    = 7
+	ggufString  = 8
+	ggufArray   = 9
+	ggufUint64  = 10
+	ggufInt64   = 11
+	ggufFloat64 = 12
+)
+
+// ggml tensor types supported for weights
+const (
+	ggmlF32  = 0
+	ggmlF16  = 1
+	ggmlQ8_0 = 8
+	ggmlBF16 = 30
+)
+
+const ggufMagic = 0x46554747 // "GGUF" little-endian
+
+func readGGUF(path string) (*ggufFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF model: %w", err)
+	}
+	return parseGGUF(data)
+}
+
+func parseGGUF(data []byte) (*ggufFile, error) {
+	r := &ggufReader{data: data}
+
+	if r.u32() != ggufMagic {
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+	version := r.u32()
+	if version < 2 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+	tensorCount := r.u64()
+	kvCount := r.u64()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	f := &ggufFile{
+		meta:    make(map[string]any, kvCount),
+		tensors: make(map[string]ggufTensor, tensorCount),
+	}
+	for i := uint64(0); i < kvCount && r.err == nil; i++ {
+		key := r.str()
+		f.meta[key] = r.value(r.u32())
+	}
+
+	type tensorInfo struct {
+		name   string
+		dims   []uint64
+		typ    uint32
+		offset uint64
+	}
+	infos := make([]tensorInfo, 0, tensorCount)
+	for i := uint64(0); i < tensorCount && r.err == nil; i++ {
+		info := tensorInfo{name: r.str()}
+		nDims := r.u32()
+		for d := uint32(0); d < nDims && r.err == nil; d++ {
+			info.dims = append(info.dims, r.u64())
+		}
+		info.typ = r.u32()
+		info.offset = r.u64()
+		infos = append(infos, info)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	alignment := uint64(32)
+	if a, ok := f.metaUint("general.alignment"); ok && a > 0 {
+		alignment = a
+	}
+	base := (uint64(r.pos) + alignment - 1) / alignment * alignment
+
+	for _, info := range infos {
+		count := uint64(1)
+		for _, d := range info.dims {
+			count *= d
+		}
+		start := base + info.offset
+		values, err := dequantize(data, start, count, info.typ)
+		if err != nil {
+			return nil, fmt.Errorf("tensor %s: %w", info.name, err)
+		}
+		f.tensors[info.name] = ggufTensor{dims: info.dims, data: values}
+	}
+	return f, nil
+}
+
+func dequantize(data []byte, start, count uint64, typ uint32) ([]float32, error) {
+	var size uint64
+	switch typ {
+	case ggmlF32:
+		size = count * 4
+	case ggmlF16, ggmlBF16:
+		size = count * 2
+	case ggmlQ8_0:
+		if count%32 != 0 {
+			return nil, fmt.Errorf("Q8_0 tensor size %d is not a multiple of 32", count)
+		}
+		size = count / 32 * 34
+	default:
+		return nil, fmt.Errorf("unsupported tensor type %d (use an F32, F16, BF16 or Q8_0 model)", typ)
+	}
+	if start+size > uint64(len(data)) {
+		return nil, fmt.Errorf("tensor data out of bounds")
+	}
+	raw := data[start : start+size]
+
+	out := make([]float32, count)
+	switch typ {
+	case ggmlF32:
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+	case ggmlF16:
+		for i := range out {
+			out[i] = halfToFloat32(binary.LittleEndian.Uint16(raw[i*2:]))
+		}
+	case ggmlBF16:
+		for i := range out {
+			out[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(raw[i*2:])) << 16)
+		}
+	case ggmlQ8_0:
+		for b := 0; b < len(out)/32; b++ {
+			block := raw[b*34:]
+			scale := halfToFloat32(binary.LittleEndian.Uint16(block))
+			for j := 0; j < 32; j++ {
+				out[b*32+j] = scale * float32(int8(block[2+j]))
+			}
+		}
+	}
+	return out, nil
+}
+
+// halfToFloat32 converts an IEEE 754 half-precision value
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+
+	switch {
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: normalize the mantissa
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
+
+func (f *ggufFile) metaString(key string) string {
+	s, _ := f.meta[key].(string)
+	return s
+}
+
+func (f *ggufFile) metaUint(key string) (uint64, bool) {
+	switch v := f.meta[key].(type) {
+	case uint8:
+		return uint64(v), true
+	case uint16:
+		return uint64(v), true
+	case uint32:
+		return uint64(v), true
+	case uint64:
+		return v, true
+	case int32:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	case int64:
+		if v >= 0 {
+			return uint64(v), true
+		}
+	}
+	return 0, false
+}
+
+func (f *ggufFile) metaFloat(key string) (float64, bool) {
+	switch v := f.meta[key].(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func (f *ggufFile) metaStrings(key string) []string {
+	arr, _ := f.meta[key].([]any)
+	out := make([]string, 0, len(arr))
+	for _, v := range arr {
+		s, _ := v.(string)
+		out = append(out, s)
+	}
+	return out
+}
+
+// ggufReader decodes little-endian values, recording the first error
+type ggufReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *ggufReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("truncated GGUF file")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *ggufReader) u32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) u64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *ggufReader) str() string {
+	n := r.u64()
+	if n > uint64(len(r.data)) {
+		r.err = fmt.Errorf("truncated GGUF file")
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+func (r *ggufReader) value(typ uint32) any {
+	switch typ {
+	case ggufUint8:
+		if b := r.take(1); b != nil {
+			return b[0]
+		}
+	case ggufInt8:
+		if b := r.take(1); b != nil {
+			return int8(b[0])
+		}
+	case ggufUint16:
+		if b := r.take(2); b != nil {
+			return binary.LittleEndian.Uint16(b)
+		}
+	case ggufInt16:
+		if b := r.take(2); b != nil {
+			return int16(binary.LittleEndian.Uint16(b))
+		}
+	case ggufUint32:
+		return r.u32()
+	case ggufInt32:
+		return int32(r.u32())
+	case ggufFloat32:
+		return math.Float32frombits(r.u32())
+	case ggufBool:
+		if b := r.take(1); b != nil {
+			return b[0] != 0
+		}
+	case ggufString:
+		return r.str()
+	case ggufArray:
+		elemType := r.u32()
+		n := r.u64()
+		if n > uint64(len(r.data)) {
+			r.err = fmt.Errorf("truncated GGUF file")
+			return nil
+		}
+		arr := make([]any, 0, n)
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			arr = append(arr, r.value(elemType))
+		}
+		return arr
+	case ggufUint64:
+		return r.u64()
+	case ggufInt64:
+		return int64(r.u64())
+	case ggufFloat64:
+		return math.Float64frombits(r.u64())
+	default:
+		r.err = fmt.Errorf("unknown GGUF metadata type %d", typ)
+	}
+	return nil
+}
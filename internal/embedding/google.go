@@ -0,0 +1,140 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/httpclient"
+	"google.golang.org/api/option"
+)
+
+// googleMaxBatch is the most contents the API accepts per batch request
+const googleMaxBatch = 100
+
+type GoogleProvider struct {
+	client    *genai.Client
+	model     *genai.EmbeddingModel // documents
+	query     *genai.EmbeddingModel // search queries
+	dimension int
+	truncate  bool
+	batchSize int
+}
+
+func NewGoogleProvider(cfg config.EmbeddingConfig) (*GoogleProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("google API key required (set GOOGLE_API_KEY or GEMINI_API_KEY)")
+	}
+
+	// The REST client ignores the API key option when given an HTTP client,
+	// so the key goes in a header; the shared transport lets tests replay
+	opts := []option.ClientOption{
+		option.WithAPIKey(cfg.APIKey),
+		option.WithHTTPClient(httpclient.NewClientWithHeaders(60*time.Second, map[string]string{"x-goog-api-key": cfg.APIKey})),
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithEndpoint(cfg.BaseURL))
+	}
+	client, err := genai.NewClient(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Google AI client: %w", err)
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = "text-embedding-004"
+	}
+
+	dimension := cfg.Dimension
+	if dimension == 0 {
+		dimension = 768 // Default for text-embedding-004
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 || batchSize > googleMaxBatch {
+		batchSize = googleMaxBatch
+	}
+
+	// The API embeds retrieval queries and documents differently
+	docModel := client.EmbeddingModel(model)
+	docModel.TaskType = genai.TaskTypeRetrievalDocument
+	queryModel := client.EmbeddingModel(model)
+	queryModel.TaskType = genai.TaskTypeRetrievalQuery
+
+	return &GoogleProvider{
+		client:    client,
+		model:     docModel,
+		query:     queryModel,
+		dimension: dimension,
+		truncate:  cfg.Truncate,
+		batchSize: batchSize,
+	}, nil
+}
+
+func (p *GoogleProvider) Name() string {
+	return "google"
+}
+
+func (p *GoogleProvider) Dimension() int {
+	return p.dimension
+}
+
+// Close releases the underlying client
+func (p *GoogleProvider) Close() error {
+	return p.client.Close()
+}
+
+func (p *GoogleProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	return p.embedSingle(ctx, p.model, text)
+}
+
+// EmbedQuery embeds text with the retrieval query task type
+func (p *GoogleProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.embedSingle(ctx, p.query, text)
+}
+
+func (p *GoogleProvider) embedSingle(ctx context.Context, model *genai.EmbeddingModel, text string) ([]float32, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("cannot embed empty text")
+	}
+
+	resp, err := model.EmbedContent(ctx, genai.Text(text))
+	if err != nil {
+		return nil, fmt.Errorf("google embedding error: %w", err)
+	}
+	if resp.Embedding == nil {
+		return nil, fmt.Errorf("no embeddings returned")
+	}
+	return fitDimension(resp.Embedding.Values, p.dimension, p.truncate), nil
+}
+
+func (p *GoogleProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("cannot embed empty text list")
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += p.batchSize {
+		end := min(start+p.batchSize, len(texts))
+		batch := p.model.NewBatch()
+		for _, text := range texts[start:end] {
+			batch.AddContent(genai.Text(text))
+		}
+
+		resp, err := p.model.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("google embedding error: %w", err)
+		}
+		if len(resp.Embeddings) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Embeddings))
+		}
+		for _, emb := range resp.Embeddings {
+			embeddings = append(embeddings, fitDimension(emb.Values, p.dimension, p.truncate))
+		}
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,189 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// HashProvider produces deterministic embeddings without any model, by
+// hashing code-aware features of the text into a fixed number of buckets.
+// Texts that share identifiers, identifier parts or character trigrams get
+// similar vectors, which is enough for lexical similarity in demos and
+// integration tests. It is also the fallback when the configured provider is
+// unreachable.
+type HashProvider struct {
+	dimension int
+}
+
+// Feature weights: whole identifiers and their parts carry the signal;
+// bigrams and trigrams add word order and fuzzy matching (handle/handler).
+const (
+	hashWeightToken    = 1.0
+	hashWeightBigram   = 0.5
+	hashWeightTrigram  = 0.25
+	hashWeightStopword = 0.2
+)
+
+// hashStopwords are keywords common to most languages, which say little
+// about what a piece of code does
+var hashStopwords = map[string]bool{
+	"func": true, "function": true, "def": true, "fn": true, "return": true,
+	"if": true, "else": true, "for": true, "while": true, "var": true,
+	"let": true, "const": true, "class": true, "public": true, "private": true,
+	"static": true, "void": true, "new": true, "this": true, "self": true,
+	"the": true, "and": true, "or": true, "of": true, "to": true, "in": true,
+	"is": true, "nil": true, "null": true, "none": true, "true": true, "false": true,
+	"err": true, "error": true, "string": true, "int": true,
+}
+
+func NewHashProvider(cfg config.EmbeddingConfig) (*HashProvider, error) {
+	dimension := cfg.Dimension
+	if dimension == 0 {
+		dimension = 256
+	}
+	if dimension < 0 {
+		return nil, fmt.Errorf("invalid embedding dimension: %d", dimension)
+	}
+	return &HashProvider{dimension: dimension}, nil
+}
+
+func (p *HashProvider) Name() string {
+	return "hash"
+}
+
+func (p *HashProvider) Dimension() int {
+	return p.dimension
+}
+
+func (p *HashProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("cannot embed empty text")
+	}
+	return p.embed(text), nil
+}
+
+// EmbedQuery hashes a query exactly as it would a document
+func (p *HashProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.EmbedSingle(ctx, text)
+}
+
+func (p *HashProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("cannot embed empty text list")
+	}
+	results := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := p.EmbedSingle(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("text %d: %w", i, err)
+		}
+		results[i] = emb
+	}
+	return results, nil
+}
+
+func (p *HashProvider) embed(text string) []float32 {
+	// Accumulate feature weights first so repeated features grow sublinearly
+	features := make(map[string]float64)
+	var prev string
+	for _, ident := range splitIdentifiers(text) {
+		parts := splitIdentifierParts(ident)
+		lower := strings.ToLower(ident)
+		if len(parts) > 1 {
+			features["id:"+lower] += tokenWeight(lower)
+		}
+		for _, part := range parts {
+			features["w:"+part] += tokenWeight(part)
+			if prev != "" {
+				features["b:"+prev+" "+part] += hashWeightBigram
+			}
+			prev = part
+			padded := "<" + part + ">"
+			for i := 0; i+3 <= len(padded); i++ {
+				features["t:"+padded[i:i+3]] += hashWeightTrigram
+			}
+		}
+	}
+	if len(features) == 0 {
+		// Punctuation only: still give distinct texts distinct vectors
+		features["raw:"+text] = 1
+	}
+
+	vec := make([]float32, p.dimension)
+	for feature, weight := range features {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		value := float32(1 + math.Log(weight))
+		if weight < 1 {
+			value = float32(weight)
+		}
+		if sum>>63 == 1 {
+			value = -value
+		}
+		vec[sum%uint64(p.dimension)] += value
+	}
+	return normalize(vec)
+}
+
+func tokenWeight(token string) float64 {
+	if hashStopwords[token] {
+		return hashWeightStopword
+	}
+	return hashWeightToken
+}
+
+// splitIdentifiers returns the runs of letters, digits and underscores
+func splitIdentifiers(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// splitIdentifierParts splits an identifier on underscores, camelCase and
+// letter/digit boundaries, lowercasing the parts: "parseHTTPRequest2" gives
+// "parse", "http", "request", "2". Single letters are dropped.
+func splitIdentifierParts(ident string) []string {
+	var parts []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 1 || (len(cur) == 1 && unicode.IsDigit(cur[0])) {
+			parts = append(parts, strings.ToLower(string(cur)))
+		}
+		cur = cur[:0]
+	}
+
+	runes := []rune(ident)
+	for i, r := range runes {
+		if r == '_' {
+			flush()
+			continue
+		}
+		if len(cur) > 0 {
+			last := cur[len(cur)-1]
+			switch {
+			case unicode.IsDigit(r) != unicode.IsDigit(last):
+				flush()
+			case unicode.IsUpper(r) && unicode.IsLower(last):
+				// fooBar
+				flush()
+			case unicode.IsUpper(r) && unicode.IsUpper(last) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+				// HTTPRequest: the R starts a new word
+				flush()
+			}
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return parts
+}
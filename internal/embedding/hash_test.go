@@ -0,0 +1,108 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+func cosine(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
+
+func TestSplitIdentifierParts(t *testing.T) {
+	tests := map[string][]string{
+		"parseHTTPRequest2": {"parse", "http", "request", "2"},
+		"user_id":           {"user", "id"},
+		"XMLParser":         {"xml", "parser"},
+		"a":                 nil,
+		"getX":              {"get"},
+	}
+	for ident, want := range tests {
+		if got := splitIdentifierParts(ident); !reflect.DeepEqual(got, want) {
+			t.Errorf("splitIdentifierParts(%q) = %v, want %v", ident, got, want)
+		}
+	}
+}
+
+func TestHashProvider(t *testing.T) {
+	p, err := NewHashProvider(config.EmbeddingConfig{Dimension: 128})
+	if err != nil {
+		t.Fatalf("NewHashProvider failed: %v", err)
+	}
+	ctx := context.Background()
+
+	texts := []string{
+		"func parseUserRequest(req *http.Request) (*UserRequest, error)",
+		"def parse_user_request(request): return UserRequest(request)",
+		"func renderHistogram(buckets []float64) string",
+		"{}();",
+	}
+	embs, err := p.Embed(ctx, texts)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i, emb := range embs {
+		if len(emb) != 128 {
+			t.Fatalf("embedding %d has dimension %d, want 128", i, len(emb))
+		}
+		if norm := cosine(emb, emb); math.Abs(norm-1) > 1e-5 {
+			t.Errorf("embedding %d has norm %v, want 1", i, norm)
+		}
+	}
+
+	again, _ := p.EmbedSingle(ctx, texts[0])
+	if !reflect.DeepEqual(again, embs[0]) {
+		t.Error("expected identical embeddings for identical text")
+	}
+
+	// Naming style must not matter much; unrelated code must score lower
+	similar := cosine(embs[0], embs[1])
+	unrelated := cosine(embs[0], embs[2])
+	if similar <= unrelated+0.2 {
+		t.Errorf("similar code scored %.3f, unrelated %.3f", similar, unrelated)
+	}
+
+	if _, err := p.EmbedSingle(ctx, "   "); err == nil {
+		t.Error("expected error for empty text")
+	}
+}
+
+func TestNewProviderFallsBackToHash(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	cfg := config.EmbeddingConfig{
+		Provider:  "ollama",
+		Model:     "nomic-embed-text",
+		Dimension: 64,
+		BaseURL:   server.URL,
+		Fallback:  "hash",
+	}
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if p.Name() != "hash" || p.Dimension() != 64 {
+		t.Errorf("got provider %s with dimension %d, want hash with 64", p.Name(), p.Dimension())
+	}
+
+	// Without a fallback, an unreachable provider is an error
+	cfg.Fallback = "none"
+	if _, err := NewProvider(cfg); err == nil {
+		t.Error("expected an error for an unreachable provider without a fallback")
+	}
+
+	if _, err := NewProvider(config.EmbeddingConfig{Provider: "nope", Fallback: "hash"}); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
@@ -0,0 +1,304 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+)
+
+// HTTPProvider calls any JSON-over-HTTP embedding API, such as Voyage,
+// Cohere, Jina or Hugging Face TEI. Where the texts go in the request body
+// and where the vectors come from in the response are configured as dotted
+// paths in [embedding.http].
+type HTTPProvider struct {
+	url       string
+	model     string
+	apiKey    string
+	dimension int
+	truncate  bool
+	batchSize int
+	mapping   config.HTTPEmbeddingConfig
+	client    headerDoer
+}
+
+func NewHTTPProvider(cfg config.EmbeddingConfig) (*HTTPProvider, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("http embedding provider requires base_url to be the embeddings endpoint")
+	}
+
+	mapping := cfg.HTTP
+	if mapping.InputField == "" {
+		mapping.InputField = "input"
+	}
+	if mapping.ModelField == "" {
+		mapping.ModelField = "model"
+	}
+	if mapping.ResponseField == "" {
+		mapping.ResponseField = "data.*.embedding"
+	}
+
+	dimension := cfg.Dimension
+	if dimension == 0 {
+		dimension = 768
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = 32
+	}
+	if mapping.SingleInput {
+		batchSize = 1
+	}
+
+	return &HTTPProvider{
+		url:       cfg.BaseURL,
+		model:     cfg.Model,
+		apiKey:    cfg.APIKey,
+		dimension: dimension,
+		truncate:  cfg.Truncate,
+		batchSize: batchSize,
+		mapping:   mapping,
+		client:    withHeaders(httpclient.GetSharedClient(60*time.Second), cfg.Headers),
+	}, nil
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPProvider) Dimension() int {
+	return p.dimension
+}
+
+func (p *HTTPProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	embs, err := p.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embs[0], nil
+}
+
+// EmbedQuery embeds text with query_body merged into the request, for APIs
+// that take an input type (Cohere, Voyage, Jina)
+func (p *HTTPProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("cannot embed empty text")
+	}
+	vecs, err := p.request(ctx, []string{text}, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return fitDimension(vecs[0], p.dimension, p.truncate), nil
+}
+
+func (p *HTTPProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("cannot embed empty text list")
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("cannot embed empty text at index %d", i)
+		}
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += p.batchSize {
+		end := min(start+p.batchSize, len(texts))
+		vecs, err := p.request(ctx, texts[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(vecs))
+		}
+		for _, v := range vecs {
+			embeddings = append(embeddings, fitDimension(v, p.dimension, p.truncate))
+		}
+	}
+	return embeddings, nil
+}
+
+// request sends one batch and extracts its vectors from the response
+func (p *HTTPProvider) request(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	body := make(map[string]any)
+	maps.Copy(body, p.mapping.Body)
+	if query {
+		maps.Copy(body, p.mapping.QueryBody)
+	}
+	var input any = texts
+	if p.mapping.SingleInput {
+		input = texts[0]
+	}
+	setPath(body, p.mapping.InputField, input)
+	if p.mapping.ModelField != "-" && p.model != "" {
+		setPath(body, p.mapping.ModelField, p.model)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http embedding request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("http embedding error: %s - failed to read response body: %v", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, ratelimit.NewStatusError(resp, fmt.Sprintf("http embedding error: %s - %s", resp.Status, string(data)))
+	}
+
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("http embedding decode error: %w", err)
+	}
+	vecs, err := extractVectors(decoded, strings.Split(p.mapping.ResponseField, "."))
+	if err != nil {
+		return nil, fmt.Errorf("http embedding response: %w", err)
+	}
+	return vecs, nil
+}
+
+// setPath stores value at a dotted path, creating nested objects as needed
+func setPath(body map[string]any, path string, value any) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := body[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			body[key] = next
+		}
+		body = next
+	}
+	body[keys[len(keys)-1]] = value
+}
+
+// extractVectors follows a dotted path through decoded JSON. A "*" segment
+// iterates an array. The path must end at a vector or an array of vectors.
+func extractVectors(v any, path []string) ([][]float32, error) {
+	if len(path) == 0 {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array at the end of the path, got %T", v)
+		}
+		if len(arr) > 0 {
+			if _, nested := arr[0].([]any); nested {
+				var vecs [][]float32
+				for _, item := range arr {
+					vec, err := toVector(item)
+					if err != nil {
+						return nil, err
+					}
+					vecs = append(vecs, vec)
+				}
+				return vecs, nil
+			}
+		}
+		vec, err := toVector(arr)
+		if err != nil {
+			return nil, err
+		}
+		return [][]float32{vec}, nil
+	}
+
+	if path[0] == "*" {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected an array for \"*\", got %T", v)
+		}
+		var vecs [][]float32
+		for _, item := range arr {
+			got, err := extractVectors(item, path[1:])
+			if err != nil {
+				return nil, err
+			}
+			vecs = append(vecs, got...)
+		}
+		return vecs, nil
+	}
+
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an object with field %q, got %T", path[0], v)
+	}
+	field, ok := obj[path[0]]
+	if !ok {
+		return nil, fmt.Errorf("missing field %q", path[0])
+	}
+	return extractVectors(field, path[1:])
+}
+
+func toVector(v any) ([]float32, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a vector, got %T", v)
+	}
+	vec := make([]float32, len(arr))
+	for i, x := range arr {
+		f, ok := x.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected a number in vector, got %T", x)
+		}
+		vec[i] = float32(f)
+	}
+	return vec, nil
+}
+
+// headerDoer is the request interface shared by net/http and go-openai clients
+type headerDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// headerClient adds fixed headers to every request
+type headerClient struct {
+	client  headerDoer
+	headers map[string]string
+}
+
+// withHeaders returns client, or a wrapper setting headers on each request.
+// Values may reference environment variables as ${NAME}, so secrets can stay
+// out of the config file.
+func withHeaders(client headerDoer, headers map[string]string) headerDoer {
+	if len(headers) == 0 {
+		return client
+	}
+	expanded := make(map[string]string, len(headers))
+	for k, v := range headers {
+		expanded[k] = os.ExpandEnv(v)
+	}
+	return &headerClient{client: client, headers: expanded}
+}
+
+func (c *headerClient) Do(req *http.Request) (*http.Response, error) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return c.client.Do(req)
+}
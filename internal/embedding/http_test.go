@@ -0,0 +1,259 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/usage"
+)
+
+func TestHTTPProviderMapping(t *testing.T) {
+	var gotBody map[string]any
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("X-Api-Key")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		texts := gotBody["texts"].([]any)
+		vecs := make([][]float32, len(texts))
+		for i := range texts {
+			vecs[i] = []float32{float32(i), 1, 0}
+		}
+		// Cohere v2 style: {"embeddings": {"float": [[...], ...]}}
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": map[string]any{"float": vecs}})
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_EMBED_KEY", "secret")
+	p, err := NewHTTPProvider(config.EmbeddingConfig{
+		Provider:  "http",
+		BaseURL:   server.URL,
+		Model:     "embed-v4",
+		Dimension: 3,
+		BatchSize: 2,
+		Headers:   map[string]string{"X-Api-Key": "${TEST_EMBED_KEY}"},
+		HTTP: config.HTTPEmbeddingConfig{
+			InputField:    "texts",
+			Body:          map[string]any{"input_type": "search_document"},
+			QueryBody:     map[string]any{"input_type": "search_query"},
+			ResponseField: "embeddings.float",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPProvider failed: %v", err)
+	}
+
+	embs, err := p.Embed(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embs) != 3 || embs[1][0] != 1 || embs[2][0] != 0 {
+		t.Errorf("unexpected embeddings %v", embs)
+	}
+	if gotAuth != "secret" {
+		t.Errorf("expected header from env, got %q", gotAuth)
+	}
+	if gotBody["input_type"] != "search_document" || gotBody["model"] != "embed-v4" {
+		t.Errorf("unexpected request body %v", gotBody)
+	}
+
+	if _, err := p.EmbedQuery(context.Background(), "q"); err != nil {
+		t.Fatalf("EmbedQuery failed: %v", err)
+	}
+	if gotBody["input_type"] != "search_query" {
+		t.Errorf("expected query_body to override body, got %v", gotBody)
+	}
+}
+
+func TestHTTPProviderSingleInput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["model"]; ok {
+			t.Error("model field should be omitted")
+		}
+		params := body["params"].(map[string]any)
+		text := params["prompt"].(string)
+		json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{"vector": []float32{float32(len(text)), 0}}})
+	}))
+	defer server.Close()
+
+	p, err := NewHTTPProvider(config.EmbeddingConfig{
+		BaseURL:   server.URL,
+		Model:     "ignored",
+		Dimension: 2,
+		HTTP: config.HTTPEmbeddingConfig{
+			InputField:    "params.prompt",
+			ModelField:    "-",
+			SingleInput:   true,
+			ResponseField: "result.vector",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewHTTPProvider failed: %v", err)
+	}
+
+	embs, err := p.Embed(context.Background(), []string{"ab", "abcd"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if embs[0][0] != 2 || embs[1][0] != 4 {
+		t.Errorf("unexpected embeddings %v", embs)
+	}
+}
+
+func TestExtractVectors(t *testing.T) {
+	var decoded any
+	json.Unmarshal([]byte(`{"data":[{"embedding":[1,2]},{"embedding":[3,4]}]}`), &decoded)
+	vecs, err := extractVectors(decoded, strings.Split("data.*.embedding", "."))
+	if err != nil || len(vecs) != 2 || vecs[1][1] != 4 {
+		t.Errorf("extractVectors = %v, %v", vecs, err)
+	}
+
+	if _, err := extractVectors(decoded, strings.Split("results.*.embedding", ".")); err == nil {
+		t.Error("expected error for missing field")
+	}
+	if _, err := extractVectors(decoded, []string{"data"}); err == nil {
+		t.Error("expected error for a path ending at objects")
+	}
+}
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var gotHeader, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Tenant")
+		gotAuth = r.Header.Get("Authorization")
+		var req struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		data := make([]map[string]any, len(req.Input))
+		for i := range req.Input {
+			// Reverse order to check that Index is honoured
+			idx := len(req.Input) - 1 - i
+			data[i] = map[string]any{"object": "embedding", "index": idx, "embedding": []float32{3, 4, float32(idx), 9}}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": data})
+	}))
+	defer server.Close()
+
+	if _, err := NewProvider(config.EmbeddingConfig{Provider: "openai-compatible"}); err == nil {
+		t.Error("expected error without base_url")
+	}
+
+	p, err := NewProvider(config.EmbeddingConfig{
+		Provider:  "openai-compatible",
+		BaseURL:   server.URL + "/v1",
+		Model:     "bge-m3",
+		Dimension: 2,
+		Truncate:  true,
+		Headers:   map[string]string{"X-Tenant": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if p.Name() != "openai-compatible" {
+		t.Errorf("Name() = %s", p.Name())
+	}
+
+	embs, err := p.Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for _, emb := range embs {
+		if len(emb) != 2 || math.Abs(float64(emb[0])-0.6) > 1e-6 || math.Abs(float64(emb[1])-0.8) > 1e-6 {
+			t.Errorf("expected truncated, normalized [0.6 0.8], got %v", emb)
+		}
+	}
+	if gotHeader != "team-a" {
+		t.Errorf("expected custom header, got %q", gotHeader)
+	}
+	if gotAuth != "" {
+		t.Errorf("expected no Authorization without api_key, got %q", gotAuth)
+	}
+}
+
+func TestGoogleProvider(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		var req struct {
+			Requests []any `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		embs := make([]map[string]any, len(req.Requests))
+		for i := range embs {
+			embs[i] = map[string]any{"values": []float32{float32(i), 1, 2}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"embeddings": embs})
+	}))
+	defer server.Close()
+
+	if _, err := NewGoogleProvider(config.EmbeddingConfig{Provider: "google"}); err == nil {
+		t.Error("expected error without API key")
+	}
+
+	p, err := NewGoogleProvider(config.EmbeddingConfig{
+		Provider:  "google",
+		APIKey:    "test-key",
+		BaseURL:   server.URL,
+		Dimension: 3,
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewGoogleProvider failed: %v", err)
+	}
+	defer p.Close()
+
+	embs, err := p.Embed(context.Background(), []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embs) != 3 || embs[1][0] != 1 || embs[2][0] != 0 {
+		t.Errorf("unexpected embeddings %v", embs)
+	}
+	if !strings.HasSuffix(gotPath, "models/text-embedding-004:batchEmbedContents") {
+		t.Errorf("unexpected request path %s", gotPath)
+	}
+}
+
+func TestRemoteProviderRetriesAndRecordsUsage(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The first call is NewProvider's reachability probe.
+		if calls == 2 {
+			w.Header().Set("Retry-After-Ms", "10")
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": []float32{1, 0}}}})
+	}))
+	defer server.Close()
+
+	p, err := NewProvider(config.EmbeddingConfig{
+		Provider:   "http",
+		BaseURL:    server.URL,
+		Model:      "usage-test-model",
+		Dimension:  2,
+		MaxRetries: 1,
+		InputPrice: 0.5,
+	})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if _, err := p.EmbedSingle(context.Background(), "func main() {}"); err != nil {
+		t.Fatalf("expected the 503 to be retried, got %v", err)
+	}
+
+	got := usage.Default().Days(1)[0].ByProvider["embedding:http/usage-test-model"]
+	if got.Requests != 1 || got.Retries != 1 || got.InputTokens != 4 || got.EstimatedRequests != 1 {
+		t.Errorf("unexpected usage %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// localModel is an in-process encoder backend
+type localModel interface {
+	// encodeBatch returns one pooled, unnormalized vector per token sequence
+	encodeBatch(ctx context.Context, batch [][]int32) ([][]float32, error)
+	dimension() int
+	maxTokens() int
+	wordPiece() *wordPiece
+	close() error
+}
+
+// LocalProvider embeds text on the CPU inside the CodeLoom process, with no
+// network service. The model path selects the backend: ".gguf" files run on
+// a built-in BERT encoder, ".onnx" files run through ONNX Runtime.
+type LocalProvider struct {
+	model     localModel
+	batchSize int
+	maxTokens int
+}
+
+func NewLocalProvider(cfg config.EmbeddingConfig) (*LocalProvider, error) {
+	path := cfg.Model
+	if path == "" {
+		return nil, fmt.Errorf("local embedding provider requires model to be a path to an .onnx or .gguf file")
+	}
+
+	maxTokens := cfg.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 512
+	}
+	cls := strings.EqualFold(cfg.Pooling, "cls")
+
+	var (
+		model localModel
+		err   error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gguf":
+		model, err = loadBERT(path, cfg.Threads)
+	case ".onnx":
+		model, err = loadONNX(path, cfg.TokenizerPath, cfg.RuntimePath, cfg.Threads, maxTokens, cls)
+	default:
+		return nil, fmt.Errorf("local embedding model must be an .onnx or .gguf file: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local embedding model: %w", err)
+	}
+
+	if mt := model.maxTokens(); mt > 0 && mt < maxTokens {
+		maxTokens = mt
+	}
+	batchSize := cfg.BatchSize
+	if batchSize == 0 {
+		batchSize = 16
+	}
+
+	return &LocalProvider{
+		model:     model,
+		batchSize: batchSize,
+		maxTokens: maxTokens,
+	}, nil
+}
+
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalProvider) Dimension() int {
+	return p.model.dimension()
+}
+
+// Close releases the model
+func (p *LocalProvider) Close() error {
+	return p.model.close()
+}
+
+func (p *LocalProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	embs, err := p.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embs[0], nil
+}
+
+// EmbedQuery encodes a query with the same model and pooling as documents
+func (p *LocalProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.EmbedSingle(ctx, text)
+}
+
+// Embed tokenizes texts, truncating each to the model's context, and encodes
+// them batchSize at a time. Vectors are L2-normalized.
+func (p *LocalProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("cannot embed empty text list")
+	}
+
+	tok := p.model.wordPiece()
+	seqs := make([][]int32, len(texts))
+	for i, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, fmt.Errorf("cannot embed empty text at index %d", i)
+		}
+		seqs[i] = tok.encode(text, p.maxTokens)
+	}
+
+	results := make([][]float32, 0, len(texts))
+	for start := 0; start < len(seqs); start += p.batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(start+p.batchSize, len(seqs))
+		vecs, err := p.model.encodeBatch(ctx, seqs[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("local embedding failed: %w", err)
+		}
+		for _, v := range vecs {
+			results = append(results, normalize(v))
+		}
+	}
+	return results, nil
+}
+
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= inv
+	}
+	return v
+}
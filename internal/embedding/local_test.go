@@ -0,0 +1,313 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+func TestWordPieceEncode(t *testing.T) {
+	vocab := []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "foo", "##bar", "(", ")", "x", "cafe", "."}
+	tok, err := newWordPiece(vocab, true, "", "##")
+	if err != nil {
+		t.Fatalf("newWordPiece failed: %v", err)
+	}
+
+	tests := []struct {
+		text      string
+		maxTokens int
+		want      []int32
+	}{
+		{"FooBar(x)", 512, []int32{2, 4, 5, 6, 8, 7, 3}},
+		{"foo.bar", 512, []int32{2, 4, 10, 1, 3}}, // "bar" alone is not a word-initial piece
+		{"Café", 512, []int32{2, 9, 3}},           // accents are stripped when lowercasing
+		{"foo foo foo foo", 4, []int32{2, 4, 4, 3}},
+	}
+	for _, tt := range tests {
+		got := tok.encode(tt.text, tt.maxTokens)
+		if len(got) != len(tt.want) {
+			t.Errorf("encode(%q) = %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("encode(%q) = %v, want %v", tt.text, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if _, err := newWordPiece([]string{"a", "b"}, true, "", "##"); err == nil {
+		t.Error("expected error for vocabulary without special tokens")
+	}
+}
+
+func TestHalfToFloat32(t *testing.T) {
+	tests := map[uint16]float32{
+		0x0000: 0,
+		0x3c00: 1,
+		0xc000: -2,
+		0x3555: 0.33325195,
+		0x7bff: 65504,
+		0x0001: 5.9604645e-08, // smallest subnormal
+	}
+	for h, want := range tests {
+		if got := halfToFloat32(h); got != want {
+			t.Errorf("halfToFloat32(%#04x) = %v, want %v", h, got, want)
+		}
+	}
+}
+
+func TestDequantizeQ8_0(t *testing.T) {
+	block := make([]byte, 34)
+	binary.LittleEndian.PutUint16(block, 0x3800) // scale 0.5
+	for i := 0; i < 32; i++ {
+		block[2+i] = byte(int8(i - 16))
+	}
+
+	got, err := dequantize(block, 0, 32, ggmlQ8_0)
+	if err != nil {
+		t.Fatalf("dequantize failed: %v", err)
+	}
+	for i, v := range got {
+		if want := 0.5 * float32(i-16); v != want {
+			t.Fatalf("value %d = %v, want %v", i, v, want)
+		}
+	}
+
+	if _, err := dequantize(block, 0, 32, 2); err == nil {
+		t.Error("expected error for unsupported tensor type")
+	}
+}
+
+// ggufBuilder writes minimal GGUF v3 files for tests
+type ggufBuilder struct {
+	kv      bytes.Buffer
+	kvCount uint64
+	tensors []ggufTestTensor
+}
+
+type ggufTestTensor struct {
+	name string
+	dims []uint64
+	data []float32
+}
+
+func writeGGUFString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.LittleEndian, uint64(len(s)))
+	b.WriteString(s)
+}
+
+func (g *ggufBuilder) str(key, val string) {
+	writeGGUFString(&g.kv, key)
+	binary.Write(&g.kv, binary.LittleEndian, uint32(ggufString))
+	writeGGUFString(&g.kv, val)
+	g.kvCount++
+}
+
+func (g *ggufBuilder) u32(key string, val uint32) {
+	writeGGUFString(&g.kv, key)
+	binary.Write(&g.kv, binary.LittleEndian, uint32(ggufUint32))
+	binary.Write(&g.kv, binary.LittleEndian, val)
+	g.kvCount++
+}
+
+func (g *ggufBuilder) strs(key string, vals []string) {
+	writeGGUFString(&g.kv, key)
+	binary.Write(&g.kv, binary.LittleEndian, uint32(ggufArray))
+	binary.Write(&g.kv, binary.LittleEndian, uint32(ggufString))
+	binary.Write(&g.kv, binary.LittleEndian, uint64(len(vals)))
+	for _, v := range vals {
+		writeGGUFString(&g.kv, v)
+	}
+	g.kvCount++
+}
+
+// tensor adds an F32 tensor filled with a deterministic pattern
+func (g *ggufBuilder) tensor(name string, dims ...uint64) {
+	n := uint64(1)
+	for _, d := range dims {
+		n *= d
+	}
+	data := make([]float32, n)
+	seed := uint32(len(g.tensors)*7919 + 17)
+	for i := range data {
+		seed = seed*1664525 + 1013904223
+		data[i] = (float32(seed>>8)/float32(1<<24) - 0.5) * 0.4
+	}
+	g.tensors = append(g.tensors, ggufTestTensor{name: name, dims: dims, data: data})
+}
+
+func (g *ggufBuilder) bytes() []byte {
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, uint32(ggufMagic))
+	binary.Write(&out, binary.LittleEndian, uint32(3))
+	binary.Write(&out, binary.LittleEndian, uint64(len(g.tensors)))
+	binary.Write(&out, binary.LittleEndian, g.kvCount)
+	out.Write(g.kv.Bytes())
+
+	var data bytes.Buffer
+	for _, t := range g.tensors {
+		writeGGUFString(&out, t.name)
+		binary.Write(&out, binary.LittleEndian, uint32(len(t.dims)))
+		for _, d := range t.dims {
+			binary.Write(&out, binary.LittleEndian, d)
+		}
+		binary.Write(&out, binary.LittleEndian, uint32(ggmlF32))
+		binary.Write(&out, binary.LittleEndian, uint64(data.Len()))
+		binary.Write(&data, binary.LittleEndian, t.data)
+		for data.Len()%32 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	for out.Len()%32 != 0 {
+		out.WriteByte(0)
+	}
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
+
+// writeTinyBERT writes a 2-layer BERT with 8-dim embeddings
+func writeTinyBERT(t *testing.T) string {
+	t.Helper()
+	const dim, ffn, ctxLen = 8, 16, 32
+	vocab := []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "▁func", "▁main", "▁return", "▁x", "▁(", "▁)", "tion"}
+
+	g := &ggufBuilder{}
+	g.str("general.architecture", "bert")
+	g.u32("bert.embedding_length", dim)
+	g.u32("bert.attention.head_count", 2)
+	g.u32("bert.block_count", 2)
+	g.u32("bert.feed_forward_length", ffn)
+	g.u32("bert.context_length", ctxLen)
+	g.u32("bert.pooling_type", poolingMean)
+	g.strs("tokenizer.ggml.tokens", vocab)
+
+	g.tensor("token_embd.weight", dim, uint64(len(vocab)))
+	g.tensor("token_types.weight", dim, 2)
+	g.tensor("position_embd.weight", dim, ctxLen)
+	g.tensor("token_embd_norm.weight", dim)
+	g.tensor("token_embd_norm.bias", dim)
+	for i := 0; i < 2; i++ {
+		p := "blk." + string(rune('0'+i)) + "."
+		for _, name := range []string{"attn_q", "attn_k", "attn_v", "attn_output"} {
+			g.tensor(p+name+".weight", dim, dim)
+			g.tensor(p+name+".bias", dim)
+		}
+		g.tensor(p+"attn_output_norm.weight", dim)
+		g.tensor(p+"attn_output_norm.bias", dim)
+		g.tensor(p+"ffn_up.weight", dim, ffn)
+		g.tensor(p+"ffn_up.bias", ffn)
+		g.tensor(p+"ffn_down.weight", ffn, dim)
+		g.tensor(p+"ffn_down.bias", dim)
+		g.tensor(p+"layer_output_norm.weight", dim)
+		g.tensor(p+"layer_output_norm.bias", dim)
+	}
+
+	path := filepath.Join(t.TempDir(), "tiny-bert.gguf")
+	if err := os.WriteFile(path, g.bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLocalProviderGGUF(t *testing.T) {
+	provider, err := NewProvider(config.EmbeddingConfig{
+		Provider:  "local",
+		Model:     writeTinyBERT(t),
+		BatchSize: 2,
+		MaxTokens: 16,
+	})
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if provider.Dimension() != 8 {
+		t.Errorf("Dimension() = %d, want 8", provider.Dimension())
+	}
+
+	ctx := context.Background()
+	texts := []string{"func main() { return x }", "function", "x", strings.Repeat("return ", 100)}
+	embs, err := provider.Embed(ctx, texts)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(embs) != len(texts) {
+		t.Fatalf("got %d embeddings, want %d", len(embs), len(texts))
+	}
+
+	for i, emb := range embs {
+		if len(emb) != 8 {
+			t.Fatalf("embedding %d has dimension %d", i, len(emb))
+		}
+		var norm float64
+		for _, v := range emb {
+			if math.IsNaN(float64(v)) {
+				t.Fatalf("embedding %d contains NaN", i)
+			}
+			norm += float64(v) * float64(v)
+		}
+		if math.Abs(norm-1) > 1e-4 {
+			t.Errorf("embedding %d has norm %v, want 1", i, norm)
+		}
+	}
+
+	// Batching must not change results
+	single, err := provider.EmbedSingle(ctx, texts[1])
+	if err != nil {
+		t.Fatalf("EmbedSingle failed: %v", err)
+	}
+	for i := range single {
+		if math.Abs(float64(single[i]-embs[1][i])) > 1e-5 {
+			t.Fatalf("EmbedSingle differs from batched Embed at %d: %v vs %v", i, single[i], embs[1][i])
+		}
+	}
+
+	if embs[0][0] == embs[2][0] && embs[0][1] == embs[2][1] {
+		t.Error("expected different texts to produce different embeddings")
+	}
+
+	if _, err := provider.Embed(ctx, []string{"  "}); err == nil {
+		t.Error("expected error for empty text")
+	}
+}
+
+func TestLocalProviderRejectsUnknownModels(t *testing.T) {
+	if _, err := NewLocalProvider(config.EmbeddingConfig{Provider: "local", Model: "nomic-embed-text"}); err == nil {
+		t.Error("expected error for a model name that is not a file path")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.gguf")
+	os.WriteFile(bad, []byte("not a gguf file at all"), 0644)
+	if _, err := NewLocalProvider(config.EmbeddingConfig{Provider: "local", Model: bad}); err == nil {
+		t.Error("expected error for a corrupt GGUF file")
+	}
+}
+
+func TestChooseONNXOutput(t *testing.T) {
+	tests := []struct {
+		outputs []string
+		want    string
+	}{
+		{[]string{"last_hidden_state", "sentence_embedding"}, "sentence_embedding"},
+		{[]string{"sentence_embedding", "last_hidden_state"}, "sentence_embedding"},
+		{[]string{"logits", "last_hidden_state"}, "last_hidden_state"},
+		{[]string{"embeddings"}, "embeddings"},
+	}
+	for _, tt := range tests {
+		var outputs []ort.InputOutputInfo
+		for _, name := range tt.outputs {
+			outputs = append(outputs, ort.InputOutputInfo{Name: name})
+		}
+		if got := chooseOutput(outputs).Name; got != tt.want {
+			t.Errorf("chooseOutput(%q) = %q, want %q", tt.outputs, got, tt.want)
+		}
+	}
+}
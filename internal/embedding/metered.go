@@ -0,0 +1,87 @@
+package embedding
+
+import (
+	"context"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/usage"
+)
+
+// meteredProvider rate-limits and retries requests to a remote provider and
+// records their token usage and estimated cost
+type meteredProvider struct {
+	Provider
+	model   string
+	limiter *ratelimit.Limiter
+	policy  ratelimit.Policy
+	price   usage.Price
+	tracker *usage.Tracker
+}
+
+func withMetering(p Provider, cfg config.EmbeddingConfig) Provider {
+	return &meteredProvider{
+		Provider: p,
+		model:    cfg.Model,
+		limiter:  ratelimit.NewLimiter(cfg.RequestsPerMinute),
+		policy:   ratelimit.DefaultPolicy(cfg.MaxRetries),
+		price:    usage.Price{Input: cfg.InputPrice},
+		tracker:  usage.Default(),
+	}
+}
+
+func (m *meteredProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var vecs [][]float32
+	err := m.call(ctx, texts, func(ctx context.Context) error {
+		var err error
+		vecs, err = m.Provider.Embed(ctx, texts)
+		return err
+	})
+	return vecs, err
+}
+
+func (m *meteredProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	var vec []float32
+	err := m.call(ctx, []string{text}, func(ctx context.Context) error {
+		var err error
+		vec, err = m.Provider.EmbedSingle(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+func (m *meteredProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	var vec []float32
+	err := m.call(ctx, []string{text}, func(ctx context.Context) error {
+		var err error
+		vec, err = m.Provider.EmbedQuery(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+func (m *meteredProvider) call(ctx context.Context, texts []string, fn func(ctx context.Context) error) error {
+	ctx, counter := usage.WithCounter(ctx)
+	retries, err := ratelimit.Do(ctx, m.limiter, m.policy, fn)
+
+	input, _, reported := counter.Tokens()
+	if !reported {
+		chars := 0
+		for _, text := range texts {
+			chars += len(text)
+		}
+		input = usage.EstimateTokens(chars)
+	}
+	m.tracker.Add(usage.Record{
+		Kind:        usage.KindEmbedding,
+		Tool:        usage.Tool(ctx),
+		Provider:    m.Name(),
+		Model:       m.model,
+		InputTokens: input,
+		Estimated:   !reported,
+		Cost:        m.price.Cost(input, 0),
+		Retries:     retries,
+		Failed:      err != nil,
+	})
+	return err
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/heefoo/codeloom/internal/config"
 	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/ratelimit"
 )
 
 type OllamaProvider struct {
@@ -98,7 +99,7 @@ func (p *OllamaProvider) EmbedSingle(ctx context.Context, text string) ([]float3
 		if err != nil {
 			return nil, fmt.Errorf("ollama embedding error: %s - failed to read response body: %v", resp.Status, err)
 		}
-		return nil, fmt.Errorf("ollama embedding error: %s - %s", resp.Status, string(body))
+		return nil, ratelimit.NewStatusError(resp, fmt.Sprintf("ollama embedding error: %s - %s", resp.Status, string(body)))
 	}
 
 	var embedResp ollamaEmbedResponse
@@ -109,6 +110,12 @@ func (p *OllamaProvider) EmbedSingle(ctx context.Context, text string) ([]float3
 	return embedResp.Embedding, nil
 }
 
+// EmbedQuery sends a query like a document, as /api/embeddings takes no
+// input type
+func (p *OllamaProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.EmbedSingle(ctx, text)
+}
+
 func (p *OllamaProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, fmt.Errorf("cannot embed empty text list")
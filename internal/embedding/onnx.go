@@ -0,0 +1,245 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxModel runs an exported Hugging Face encoder through ONNX Runtime, which
+// is loaded from a shared library at first use.
+type onnxModel struct {
+	session   *ort.DynamicAdvancedSession
+	inputs    []string
+	output    string
+	pooled    bool // output is already one vector per sequence
+	cls       bool
+	dim       int
+	maxLen    int
+	tokenizer *wordPiece
+}
+
+var ortEnv struct {
+	once sync.Once
+	err  error
+}
+
+// initONNXRuntime loads the ONNX Runtime library once per process
+func initONNXRuntime(libPath string) error {
+	ortEnv.once.Do(func() {
+		if libPath == "" {
+			switch runtime.GOOS {
+			case "darwin":
+				libPath = "libonnxruntime.dylib"
+			case "windows":
+				libPath = "onnxruntime.dll"
+			default:
+				libPath = "libonnxruntime.so"
+			}
+		}
+		ort.SetSharedLibraryPath(libPath)
+		if err := ort.InitializeEnvironment(); err != nil {
+			ortEnv.err = fmt.Errorf("failed to load ONNX Runtime from %s (set embedding.runtime_path): %w", libPath, err)
+		}
+	})
+	return ortEnv.err
+}
+
+func loadONNX(modelPath, tokenizerPath, runtimePath string, threads, maxLen int, cls bool) (*onnxModel, error) {
+	tokenizer, err := loadWordPiece(findTokenizer(modelPath, tokenizerPath))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := initONNXRuntime(runtimePath); err != nil {
+		return nil, err
+	}
+
+	inputInfo, outputInfo, err := ort.GetInputOutputInfo(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect ONNX model: %w", err)
+	}
+
+	m := &onnxModel{cls: cls, maxLen: maxLen, tokenizer: tokenizer}
+	for _, in := range inputInfo {
+		switch in.Name {
+		case "input_ids", "attention_mask", "token_type_ids":
+			m.inputs = append(m.inputs, in.Name)
+		default:
+			return nil, fmt.Errorf("unsupported ONNX model input %q", in.Name)
+		}
+	}
+	if len(outputInfo) == 0 {
+		return nil, fmt.Errorf("ONNX model has no outputs")
+	}
+
+	chosen := chooseOutput(outputInfo)
+	m.output = chosen.Name
+	m.pooled = len(chosen.Dimensions) == 2
+	if n := len(chosen.Dimensions); n > 0 && chosen.Dimensions[n-1] > 0 {
+		m.dim = int(chosen.Dimensions[n-1])
+	}
+
+	opts, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ONNX session options: %w", err)
+	}
+	defer opts.Destroy()
+	if threads > 0 {
+		if err := opts.SetIntraOpNumThreads(threads); err != nil {
+			return nil, fmt.Errorf("failed to set ONNX thread count: %w", err)
+		}
+	}
+
+	m.session, err = ort.NewDynamicAdvancedSession(modelPath, m.inputs, []string{m.output}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ONNX model: %w", err)
+	}
+
+	// Dynamic output shapes hide the dimension; probe it with a short input
+	if m.dim == 0 {
+		probe, err := m.encodeBatch(context.Background(), [][]int32{{tokenizer.cls, tokenizer.sep}})
+		if err != nil {
+			m.close()
+			return nil, fmt.Errorf("failed to probe ONNX model: %w", err)
+		}
+		m.dim = len(probe[0])
+	}
+	return m, nil
+}
+
+// chooseOutput picks the output to embed with: a pooled sentence embedding
+// when the export has one, else the token states, else the first output
+func chooseOutput(outputs []ort.InputOutputInfo) ort.InputOutputInfo {
+	for _, name := range []string{"sentence_embedding", "last_hidden_state"} {
+		for _, out := range outputs {
+			if out.Name == name {
+				return out
+			}
+		}
+	}
+	return outputs[0]
+}
+
+// findTokenizer returns tokenizerPath, or a tokenizer.json or vocab.txt found
+// next to the model
+func findTokenizer(modelPath, tokenizerPath string) string {
+	if tokenizerPath != "" {
+		return tokenizerPath
+	}
+	dir := filepath.Dir(modelPath)
+	for _, name := range []string{"tokenizer.json", "vocab.txt"} {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return filepath.Join(dir, "tokenizer.json")
+}
+
+func (m *onnxModel) dimension() int { return m.dim }
+
+func (m *onnxModel) maxTokens() int { return m.maxLen }
+
+func (m *onnxModel) wordPiece() *wordPiece { return m.tokenizer }
+
+func (m *onnxModel) close() error {
+	if m.session == nil {
+		return nil
+	}
+	return m.session.Destroy()
+}
+
+// encodeBatch pads the batch to its longest sequence and runs it in one call
+func (m *onnxModel) encodeBatch(ctx context.Context, batch [][]int32) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	seqLen := 0
+	for _, ids := range batch {
+		seqLen = max(seqLen, len(ids))
+	}
+	b := len(batch)
+	ids := make([]int64, b*seqLen)
+	mask := make([]int64, b*seqLen)
+	for i, seq := range batch {
+		for j := 0; j < seqLen; j++ {
+			if j < len(seq) {
+				ids[i*seqLen+j] = int64(seq[j])
+				mask[i*seqLen+j] = 1
+			} else {
+				ids[i*seqLen+j] = int64(m.tokenizer.pad)
+			}
+		}
+	}
+
+	shape := ort.NewShape(int64(b), int64(seqLen))
+	inputs := make([]ort.Value, 0, len(m.inputs))
+	defer func() {
+		for _, v := range inputs {
+			v.Destroy()
+		}
+	}()
+	for _, name := range m.inputs {
+		var data []int64
+		switch name {
+		case "input_ids":
+			data = ids
+		case "attention_mask":
+			data = mask
+		default:
+			data = make([]int64, b*seqLen)
+		}
+		t, err := ort.NewTensor(shape, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create input tensor: %w", err)
+		}
+		inputs = append(inputs, t)
+	}
+
+	outputs := []ort.Value{nil}
+	if err := m.session.Run(inputs, outputs); err != nil {
+		return nil, fmt.Errorf("ONNX inference failed: %w", err)
+	}
+	defer outputs[0].Destroy()
+
+	out, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("ONNX model output %s is not float32", m.output)
+	}
+	data := out.GetData()
+
+	result := make([][]float32, b)
+	if m.pooled {
+		dim := len(data) / b
+		for i := range result {
+			result[i] = append([]float32(nil), data[i*dim:(i+1)*dim]...)
+		}
+		return result, nil
+	}
+
+	// [batch, seq, dim]: pool over real tokens only
+	dim := len(data) / (b * seqLen)
+	for i := range result {
+		vec := make([]float32, dim)
+		if m.cls {
+			copy(vec, data[i*seqLen*dim:i*seqLen*dim+dim])
+		} else {
+			n := len(batch[i])
+			for j := 0; j < n; j++ {
+				addInto(vec, data[(i*seqLen+j)*dim:(i*seqLen+j+1)*dim])
+			}
+			for k := range vec {
+				vec[k] /= float32(n)
+			}
+		}
+		result[i] = vec
+	}
+	return result, nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/heefoo/codeloom/internal/config"
 	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/usage"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -15,8 +17,14 @@ type OpenAIProvider struct {
 	client    *openai.Client
 	model     string
 	dimension int
+	truncate  bool
+	name      string
 }
 
+// NewOpenAIProvider creates a provider for OpenAI or, with provider
+// "openai-compatible", for any server implementing /v1/embeddings (vLLM,
+// LM Studio, llama.cpp, TEI). Compatible servers need base_url; api_key is
+// optional and headers are sent with every request.
 func NewOpenAIProvider(cfg config.EmbeddingConfig) (*OpenAIProvider, error) {
 	clientCfg := openai.DefaultConfig(cfg.APIKey)
 
@@ -24,7 +32,15 @@ func NewOpenAIProvider(cfg config.EmbeddingConfig) (*OpenAIProvider, error) {
 		clientCfg.BaseURL = cfg.BaseURL
 	}
 
-	clientCfg.HTTPClient = httpclient.GetSharedClient(60 * time.Second)
+	name := "openai"
+	if cfg.Provider == "openai-compatible" {
+		name = "openai-compatible"
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("openai-compatible embedding provider requires base_url")
+		}
+	}
+
+	clientCfg.HTTPClient = ratelimit.WithRetryAfter(withHeaders(httpclient.GetSharedClient(60*time.Second), cfg.Headers))
 
 	client := openai.NewClientWithConfig(clientCfg)
 
@@ -42,11 +58,13 @@ func NewOpenAIProvider(cfg config.EmbeddingConfig) (*OpenAIProvider, error) {
 		client:    client,
 		model:     model,
 		dimension: dimension,
+		truncate:  cfg.Truncate,
+		name:      name,
 	}, nil
 }
 
 func (p *OpenAIProvider) Name() string {
-	return "openai"
+	return p.name
 }
 
 func (p *OpenAIProvider) Dimension() int {
@@ -64,14 +82,21 @@ func (p *OpenAIProvider) EmbedSingle(ctx context.Context, text string) ([]float3
 		Model: openai.EmbeddingModel(p.model),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("openai embedding error: %w", err)
+		return nil, fmt.Errorf("%s embedding error: %w", p.name, err)
 	}
+	usage.Report(ctx, int64(resp.Usage.PromptTokens), 0)
 
 	if len(resp.Data) == 0 {
 		return nil, fmt.Errorf("no embeddings returned")
 	}
 
-	return resp.Data[0].Embedding, nil
+	return fitDimension(resp.Data[0].Embedding, p.dimension, p.truncate), nil
+}
+
+// EmbedQuery sends a query like a document; OpenAI embedding models are
+// symmetric
+func (p *OpenAIProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.EmbedSingle(ctx, text)
 }
 
 func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
@@ -80,8 +105,9 @@ func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32
 		Model: openai.EmbeddingModel(p.model),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("openai embedding error: %w", err)
+		return nil, fmt.Errorf("%s embedding error: %w", p.name, err)
 	}
+	usage.Report(ctx, int64(resp.Usage.PromptTokens), 0)
 
 	if len(resp.Data) != len(texts) {
 		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
@@ -91,7 +117,10 @@ func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32
 	// OpenAI API does not guarantee response order matches input order
 	embeddings := make([][]float32, len(resp.Data))
 	for _, data := range resp.Data {
-		embeddings[data.Index] = data.Embedding
+		if data.Index < 0 || data.Index >= len(embeddings) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		embeddings[data.Index] = fitDimension(data.Embedding, p.dimension, p.truncate)
 	}
 
 	return embeddings, nil
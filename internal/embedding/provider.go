@@ -3,24 +3,98 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/heefoo/codeloom/internal/config"
 )
 
+// Provider embeds text. Embed and EmbedSingle embed documents (code being
+// indexed); EmbedQuery embeds a search query, which many retrieval models
+// encode differently. Providers whose models have no query mode embed
+// queries as documents.
 type Provider interface {
 	Embed(ctx context.Context, texts []string) ([][]float32, error)
 	EmbedSingle(ctx context.Context, text string) ([]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
 	Dimension() int
 	Name() string
 }
 
+// probeTimeout bounds the startup check of network providers
+const probeTimeout = 5 * time.Second
+
+// NewProvider creates the configured provider, wrapped so that each request
+// is recorded as a trace span and texts get the model's task prefixes.
+// Requests to network providers are rate-limited, retried and metered.
+// Network providers are probed once, and one that cannot be created or
+// reached is an error unless cfg.Fallback is "hash", in which case a
+// HashProvider with the configured dimension is returned instead.
 func NewProvider(cfg config.EmbeddingConfig) (Provider, error) {
+	var (
+		p   Provider
+		err error
+	)
 	switch cfg.Provider {
 	case "ollama":
-		return NewOllamaProvider(cfg)
-	case "openai":
-		return NewOpenAIProvider(cfg)
+		p, err = NewOllamaProvider(cfg)
+	case "openai", "openai-compatible":
+		p, err = NewOpenAIProvider(cfg)
+	case "google":
+		p, err = NewGoogleProvider(cfg)
+	case "http":
+		p, err = NewHTTPProvider(cfg)
+	case "local":
+		p, err = NewLocalProvider(cfg)
+	case "hash":
+		p, err = NewHashProvider(cfg)
 	default:
 		return nil, fmt.Errorf("unknown embedding provider: %s", cfg.Provider)
 	}
+	if err == nil && isRemote(cfg.Provider) {
+		err = probe(p)
+	}
+	if err != nil {
+		if cfg.Fallback != "hash" || cfg.Provider == "hash" {
+			return nil, err
+		}
+		hp, hashErr := NewHashProvider(cfg)
+		if hashErr != nil {
+			return nil, err
+		}
+		logger().Warn("embedding provider unavailable, falling back to hash embeddings; semantic search will be lexical and vectors are not comparable with an index built by the configured provider",
+			"provider", cfg.Provider, "error", err)
+		p = hp
+	}
+	if p.Name() != "hash" && isRemote(cfg.Provider) {
+		p = withMetering(p, cfg)
+	}
+	return withTracing(withTaskPrefixes(p, cfg)), nil
+}
+
+func isRemote(provider string) bool {
+	switch provider {
+	case "ollama", "openai", "openai-compatible", "google", "http":
+		return true
+	}
+	return false
+}
+
+// probe embeds a short text to check that the provider is reachable
+func probe(p Provider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	if _, err := p.EmbedSingle(ctx, "ping"); err != nil {
+		return fmt.Errorf("%s embedding provider is unreachable: %w", p.Name(), err)
+	}
+	return nil
+}
+
+// fitDimension cuts vectors longer than dimension down to their first
+// dimension components and re-normalizes them, for models trained to allow
+// it (Matryoshka embeddings). Other vectors are returned unchanged.
+func fitDimension(vec []float32, dimension int, truncate bool) []float32 {
+	if !truncate || dimension <= 0 || len(vec) <= dimension {
+		return vec
+	}
+	return normalize(append([]float32(nil), vec[:dimension]...))
 }
@@ -0,0 +1,85 @@
+package embedding
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// knownPrefixes are the task prefixes that retrieval models were trained
+// with, matched against the lowercased model name (or file name).
+var knownPrefixes = []struct {
+	match, query, document string
+}{
+	{"nomic-embed", "search_query: ", "search_document: "},
+	{"e5-", "query: ", "passage: "},
+}
+
+// taskPrefixes returns the query and document prefixes for cfg. Explicit
+// query_prefix or document_prefix settings win; "none" disables a prefix.
+// Otherwise known models get the prefixes they expect.
+func taskPrefixes(cfg config.EmbeddingConfig) (query, document string) {
+	if cfg.QueryPrefix == "" && cfg.DocumentPrefix == "" {
+		model := strings.ToLower(filepath.Base(cfg.Model))
+		for _, known := range knownPrefixes {
+			if strings.Contains(model, known.match) {
+				return known.query, known.document
+			}
+		}
+		return "", ""
+	}
+	query, document = cfg.QueryPrefix, cfg.DocumentPrefix
+	if query == "none" {
+		query = ""
+	}
+	if document == "none" {
+		document = ""
+	}
+	return query, document
+}
+
+// prefixedProvider prepends task prefixes to queries and documents
+type prefixedProvider struct {
+	Provider
+	query, document string
+}
+
+// withTaskPrefixes wraps p when its model expects task prefixes. The hash
+// provider, including when it stands in as a fallback, and Google, which
+// takes a task type instead, are never wrapped.
+func withTaskPrefixes(p Provider, cfg config.EmbeddingConfig) Provider {
+	if p.Name() == "hash" || p.Name() == "google" {
+		return p
+	}
+	query, document := taskPrefixes(cfg)
+	if query == "" && document == "" {
+		return p
+	}
+	return &prefixedProvider{Provider: p, query: query, document: document}
+}
+
+func (p *prefixedProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	prefixed := make([]string, len(texts))
+	for i, text := range texts {
+		prefixed[i] = addPrefix(p.document, text)
+	}
+	return p.Provider.Embed(ctx, prefixed)
+}
+
+func (p *prefixedProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	return p.Provider.EmbedSingle(ctx, addPrefix(p.document, text))
+}
+
+func (p *prefixedProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return p.Provider.EmbedQuery(ctx, addPrefix(p.query, text))
+}
+
+// addPrefix leaves blank text alone so providers still reject it
+func addPrefix(prefix, text string) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	return prefix + text
+}
@@ -0,0 +1,63 @@
+package embedding
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/logging"
+	"github.com/heefoo/codeloom/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedProvider wraps a Provider with a span and a debug log line per
+// embedding request.
+type tracedProvider struct {
+	Provider
+}
+
+// logger returns the component logger for providers, resolved per call so
+// it follows the logger installed by logging.Setup.
+func logger() *slog.Logger {
+	return logging.Component(nil, "embedding")
+}
+
+func withTracing(p Provider) Provider {
+	return &tracedProvider{Provider: p}
+}
+
+func (t *tracedProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, span := telemetry.Start(ctx, "embedding.Embed",
+		attribute.String("embedding.provider", t.Name()),
+		attribute.Int("embedding.batch_size", len(texts)),
+	)
+	start := time.Now()
+	vecs, err := t.Provider.Embed(ctx, texts)
+	logger().Debug("embedding request", "provider", t.Name(), "texts", len(texts), "duration", time.Since(start), "error", err)
+	telemetry.End(span, err)
+	return vecs, err
+}
+
+func (t *tracedProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
+	ctx, span := telemetry.Start(ctx, "embedding.EmbedSingle",
+		attribute.String("embedding.provider", t.Name()),
+		attribute.Int("embedding.text_length", len(text)),
+	)
+	start := time.Now()
+	vec, err := t.Provider.EmbedSingle(ctx, text)
+	logger().Debug("embedding request", "provider", t.Name(), "texts", 1, "duration", time.Since(start), "error", err)
+	telemetry.End(span, err)
+	return vec, err
+}
+
+func (t *tracedProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	ctx, span := telemetry.Start(ctx, "embedding.EmbedQuery",
+		attribute.String("embedding.provider", t.Name()),
+		attribute.Int("embedding.text_length", len(text)),
+	)
+	start := time.Now()
+	vec, err := t.Provider.EmbedQuery(ctx, text)
+	logger().Debug("embedding query", "provider", t.Name(), "texts", 1, "duration", time.Since(start), "error", err)
+	telemetry.End(span, err)
+	return vec, err
+}
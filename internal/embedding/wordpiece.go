@@ -0,0 +1,224 @@
+package embedding
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// wordPiece is a BERT WordPiece tokenizer, used by the local provider to turn
+// text into the token IDs its models expect.
+type wordPiece struct {
+	vocab     map[string]int32
+	unk       int32
+	cls       int32
+	sep       int32
+	pad       int32
+	lowercase bool
+
+	// wordPrefix is prepended to word-initial pieces and contPrefix to
+	// continuation pieces. Hugging Face vocabularies use "" and "##"; GGUF
+	// BERT vocabularies use "▁" and "".
+	wordPrefix string
+	contPrefix string
+}
+
+// maxWordChars matches BERT: longer words become a single [UNK]
+const maxWordChars = 100
+
+func newWordPiece(tokens []string, lowercase bool, wordPrefix, contPrefix string) (*wordPiece, error) {
+	t := &wordPiece{
+		vocab:      make(map[string]int32, len(tokens)),
+		lowercase:  lowercase,
+		wordPrefix: wordPrefix,
+		contPrefix: contPrefix,
+	}
+	for i, tok := range tokens {
+		if _, exists := t.vocab[tok]; !exists {
+			t.vocab[tok] = int32(i)
+		}
+	}
+
+	var ok bool
+	if t.unk, ok = t.vocab["[UNK]"]; !ok {
+		return nil, fmt.Errorf("vocabulary has no [UNK] token")
+	}
+	if t.cls, ok = t.vocab["[CLS]"]; !ok {
+		return nil, fmt.Errorf("vocabulary has no [CLS] token")
+	}
+	if t.sep, ok = t.vocab["[SEP]"]; !ok {
+		return nil, fmt.Errorf("vocabulary has no [SEP] token")
+	}
+	t.pad = t.vocab["[PAD]"]
+	return t, nil
+}
+
+// loadWordPiece reads a Hugging Face tokenizer.json or a BERT vocab.txt
+func loadWordPiece(path string) (*wordPiece, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return loadTokenizerJSON(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vocabulary: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tokens = append(tokens, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+	// vocab.txt carries no casing flag; uncased models are by far the most common
+	return newWordPiece(tokens, true, "", "##")
+}
+
+func loadTokenizerJSON(path string) (*wordPiece, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer: %w", err)
+	}
+
+	var spec struct {
+		Normalizer *struct {
+			Type      string `json:"type"`
+			Lowercase *bool  `json:"lowercase"`
+		} `json:"normalizer"`
+		Model struct {
+			Type                    string           `json:"type"`
+			Vocab                   map[string]int32 `json:"vocab"`
+			ContinuingSubwordPrefix string           `json:"continuing_subword_prefix"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer: %w", err)
+	}
+	if spec.Model.Type != "WordPiece" {
+		return nil, fmt.Errorf("unsupported tokenizer model %q (only WordPiece)", spec.Model.Type)
+	}
+
+	tokens := make([]string, len(spec.Model.Vocab))
+	for tok, id := range spec.Model.Vocab {
+		if int(id) < 0 || int(id) >= len(tokens) {
+			return nil, fmt.Errorf("tokenizer vocabulary has out-of-range id %d", id)
+		}
+		tokens[id] = tok
+	}
+
+	lowercase := true
+	if spec.Normalizer != nil && spec.Normalizer.Lowercase != nil {
+		lowercase = *spec.Normalizer.Lowercase
+	}
+	contPrefix := spec.Model.ContinuingSubwordPrefix
+	if contPrefix == "" {
+		contPrefix = "##"
+	}
+	return newWordPiece(tokens, lowercase, "", contPrefix)
+}
+
+// encode tokenizes text as "[CLS] pieces [SEP]", truncated to maxTokens
+func (t *wordPiece) encode(text string, maxTokens int) []int32 {
+	ids := []int32{t.cls}
+	limit := maxTokens - 1 // room for [SEP]
+
+	for _, word := range t.basicTokenize(text) {
+		for _, id := range t.wordPieces(word) {
+			if len(ids) >= limit {
+				return append(ids, t.sep)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return append(ids, t.sep)
+}
+
+// basicTokenize cleans text and splits it on whitespace and punctuation, as
+// BERT's BasicTokenizer does
+func (t *wordPiece) basicTokenize(text string) []string {
+	if t.lowercase {
+		// Strip accents: decompose, then drop combining marks
+		text = strings.ToLower(norm.NFD.String(text))
+	}
+
+	var words []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || (unicode.IsControl(r) && !unicode.IsSpace(r)):
+			continue
+		case t.lowercase && unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsSpace(r):
+			flush()
+		case isPunctuation(r) || isCJK(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// wordPieces splits one word greedily into the longest vocabulary pieces
+func (t *wordPiece) wordPieces(word string) []int32 {
+	runes := []rune(word)
+	if len(runes) > maxWordChars {
+		return []int32{t.unk}
+	}
+
+	var ids []int32
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		found := int32(-1)
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start == 0 {
+				piece = t.wordPrefix + piece
+			} else {
+				piece = t.contPrefix + piece
+			}
+			if id, ok := t.vocab[piece]; ok {
+				found = id
+				break
+			}
+		}
+		if found < 0 {
+			return []int32{t.unk}
+		}
+		ids = append(ids, found)
+		start = end
+	}
+	return ids
+}
+
+// isPunctuation treats all non-alphanumeric ASCII as punctuation, like BERT,
+// which matters for code: "foo.bar(x)" splits into six tokens
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
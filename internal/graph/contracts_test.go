@@ -0,0 +1,95 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
+)
+
+func TestDeleteFilePrunesContracts(t *testing.T) {
+	storage := graphtest.Storage(t)
+	ctx := context.Background()
+
+	// Two protos declare rpc::Svc.Get, and one of them rpc::Svc.Put too
+	contract := func(id string) *graph.CodeNode {
+		return &graph.CodeNode{ID: id, Name: id[len("rpc::"):], NodeType: graph.NodeTypeMethod, Language: "protobuf"}
+	}
+	definedIn := func(contract, declaring string) *graph.CodeEdge {
+		return &graph.CodeEdge{ID: graph.FormatEdgeID(contract, declaring, graph.EdgeTypeDefinedIn), FromID: contract, ToID: declaring, EdgeType: graph.EdgeTypeDefinedIn, Weight: 1}
+	}
+	const proto, copied, server = "/src/svc.proto", "/src/vendor/svc.proto", "/src/server.go"
+	rpc := func(file, name string) *graph.CodeNode {
+		return &graph.CodeNode{ID: file + "::Svc." + name, Name: name, NodeType: graph.NodeTypeMethod, Language: "protobuf", FilePath: file}
+	}
+	if err := storage.UpdateFileAtomic(ctx, proto,
+		[]*graph.CodeNode{rpc(proto, "Get"), rpc(proto, "Put"), contract("rpc::Svc.Get"), contract("rpc::Svc.Put")},
+		[]*graph.CodeEdge{definedIn("rpc::Svc.Get", proto+"::Svc.Get"), definedIn("rpc::Svc.Put", proto+"::Svc.Put")}); err != nil {
+		t.Fatalf("failed to store %s: %v", proto, err)
+	}
+	if err := storage.UpdateFileAtomic(ctx, copied,
+		[]*graph.CodeNode{rpc(copied, "Get"), contract("rpc::Svc.Get")},
+		[]*graph.CodeEdge{definedIn("rpc::Svc.Get", copied+"::Svc.Get")}); err != nil {
+		t.Fatalf("failed to store %s: %v", copied, err)
+	}
+
+	// A server implementing both, which stays
+	get := &graph.CodeNode{ID: server + "::server.Get", Name: "Get", NodeType: graph.NodeTypeMethod, Language: "go", FilePath: server}
+	put := &graph.CodeNode{ID: server + "::server.Put", Name: "Put", NodeType: graph.NodeTypeMethod, Language: "go", FilePath: server}
+	implements := func(from, to string) *graph.CodeEdge {
+		return &graph.CodeEdge{ID: graph.FormatEdgeID(from, to, graph.EdgeTypeImplements), FromID: from, ToID: to, EdgeType: graph.EdgeTypeImplements, Weight: 1}
+	}
+	if err := storage.UpdateFileAtomic(ctx, server, []*graph.CodeNode{get, put},
+		[]*graph.CodeEdge{implements(get.ID, "rpc::Svc.Get"), implements(put.ID, "rpc::Svc.Put")}); err != nil {
+		t.Fatalf("failed to store %s: %v", server, err)
+	}
+
+	if err := storage.UpdateFileAtomic(ctx, proto, nil, nil); err != nil {
+		t.Fatalf("failed to delete %s: %v", proto, err)
+	}
+
+	left, err := storage.GetNodesByIDs(ctx, []string{"rpc::Svc.Get", "rpc::Svc.Put"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].ID != "rpc::Svc.Get" {
+		t.Errorf("expected only the contract still declared in %s left, got %+v", copied, left)
+	}
+	if in, err := storage.GetIncomingEdges(ctx, "rpc::Svc.Put"); err != nil || len(in) != 1 || in[0].FromID != put.ID {
+		t.Errorf("expected the server's edge into the removed contract kept, got %+v (%v)", in, err)
+	}
+	if in, err := storage.GetIncomingEdges(ctx, "rpc::Svc.Get"); err != nil || len(in) != 1 || in[0].FromID != get.ID {
+		t.Errorf("expected the server still implementing the declared contract, got %+v (%v)", in, err)
+	}
+
+	// Dropping the rpc from the last proto declaring it removes the contract
+	if err := storage.UpdateFileNodes(ctx, copied, nil, []string{copied + "::Svc.Get"}, nil); err != nil {
+		t.Fatalf("failed to update %s: %v", copied, err)
+	}
+	if left, err := storage.GetNodesByIDs(ctx, []string{"rpc::Svc.Get"}); err != nil || len(left) != 0 {
+		t.Errorf("expected the contract removed with its last declaration, got %+v (%v)", left, err)
+	}
+
+	// Restoring the proto relinks the server to both contracts
+	if err := storage.UpdateFileAtomic(ctx, proto,
+		[]*graph.CodeNode{rpc(proto, "Get"), rpc(proto, "Put"), contract("rpc::Svc.Get"), contract("rpc::Svc.Put")},
+		[]*graph.CodeEdge{definedIn("rpc::Svc.Get", proto+"::Svc.Get"), definedIn("rpc::Svc.Put", proto+"::Svc.Put")}); err != nil {
+		t.Fatalf("failed to restore %s: %v", proto, err)
+	}
+	for _, c := range []struct{ contract, from string }{{"rpc::Svc.Get", get.ID}, {"rpc::Svc.Put", put.ID}} {
+		if left, err := storage.GetNodesByIDs(ctx, []string{c.contract}); err != nil || len(left) != 1 {
+			t.Errorf("expected %s restored, got %+v (%v)", c.contract, left, err)
+		}
+		found := false
+		in, err := storage.GetIncomingEdges(ctx, c.contract)
+		for _, e := range in {
+			if e.FromID == c.from && e.EdgeType == graph.EdgeTypeImplements {
+				found = true
+			}
+		}
+		if err != nil || !found {
+			t.Errorf("expected %s implementing %s again, got %+v (%v)", c.from, c.contract, in, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package graphtest provides graph storage for tests that need a database.
+package graphtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/graph"
+)
+
+// Storage connects to the SurrealDB instance at CODELOOM_TEST_SURREALDB_URL,
+// in a database of the test's own with the schema migrated, and skips the
+// test when no instance is configured. CODELOOM_TEST_SURREALDB_USERNAME and
+// CODELOOM_TEST_SURREALDB_PASSWORD sign in when set.
+func Storage(t testing.TB) *graph.Storage {
+	t.Helper()
+	url := os.Getenv("CODELOOM_TEST_SURREALDB_URL")
+	if url == "" {
+		t.Skip("requires SurrealDB instance (set CODELOOM_TEST_SURREALDB_URL)")
+	}
+
+	storage, err := graph.NewStorage(graph.StorageConfig{
+		URL:       url,
+		Namespace: "test",
+		Database:  fmt.Sprintf("test_%d", time.Now().UnixNano()),
+		Username:  os.Getenv("CODELOOM_TEST_SURREALDB_USERNAME"),
+		Password:  os.Getenv("CODELOOM_TEST_SURREALDB_PASSWORD"),
+	})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+
+	if err := storage.RunMigrations(context.Background()); err != nil {
+		t.Fatalf("failed to run migrations: %v", err)
+	}
+	return storage
+}
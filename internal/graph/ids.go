@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// NodeIDScheme returns the version of the node ID scheme recorded by the last
+// RenameNodes call. Graphs written before schemes were recorded report 1.
+func (s *Storage) NodeIDScheme(ctx context.Context) (int, error) {
+	ctx, span := s.startSpan(ctx, "NodeIDScheme")
+	defer span.End()
+
+	query := `SELECT value FROM graph_meta:id_scheme`
+	results, err := runQuery[[]struct {
+		Value int `json:"value"`
+	}](ctx, s.db, query, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read node ID scheme: %w", err)
+	}
+
+	if results == nil || len(*results) == 0 || len((*results)[0].Result) == 0 {
+		return 1, nil
+	}
+	return (*results)[0].Result[0].Value, nil
+}
+
+// RenameNodes changes node IDs from the keys of renames to their values,
+// rewrites every edge into or out of a renamed node, and records scheme as
+// the node ID scheme, all in one transaction. Embeddings are kept.
+func (s *Storage) RenameNodes(ctx context.Context, renames map[string]string, scheme int) error {
+	ctx, span := s.startSpan(ctx, "RenameNodes")
+	defer span.End()
+
+	oldIDs := make([]string, 0, len(renames))
+	for oldID, newID := range renames {
+		if oldID != newID {
+			oldIDs = append(oldIDs, oldID)
+		}
+	}
+
+	nodes, edges, err := s.rekeyNodes(ctx, oldIDs, func(id string) string {
+		if newID, ok := renames[id]; ok {
+			return newID
+		}
+		return id
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare node rename: %w", err)
+	}
+
+	query := `BEGIN TRANSACTION;
+		` + rekeyRecords + `
+		UPSERT graph_meta:id_scheme SET value = $scheme;
+		` + graphVersionBump + `
+		COMMIT TRANSACTION;`
+
+	_, err = runQuery[any](ctx, s.db, query, map[string]any{
+		"oldIDs":   oldIDs,
+		"nodeData": nodeRecords(nodes),
+		"edgeData": edgeRecords(edges),
+		"scheme":   scheme,
+	})
+	if err != nil {
+		return fmt.Errorf("node rename failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// upsertNodes stores the records of nodeRecords passed as $nodeData
+const upsertNodes = `FOR $node IN $nodeData {
+				UPSERT nodes SET
+					id = $node.id,
+					name = $node.name,
+					node_type = $node.node_type,
+					language = $node.language,
+					file_path = $node.file_path,
+					start_line = $node.start_line,
+					end_line = $node.end_line,
+					content = $node.content,
+					doc_comment = $node.doc_comment,
+					annotations = $node.annotations,
+					embedding = $node.embedding,
+					chunk_embeddings = $node.chunk_embeddings,
+					complexity = $node.complexity
+				WHERE id = $node.id;
+			}`
+
+// upsertEdges stores the records of edgeRecords passed as $edgeData
+const upsertEdges = `FOR $edge IN $edgeData {
+				UPSERT edges SET
+					id = $edge.id,
+					from_id = $edge.from_id,
+					to_id = $edge.to_id,
+					edge_type = $edge.edge_type,
+					weight = $edge.weight
+				WHERE id = $edge.id;
+			}`
+
+// pruneContracts deletes the nodes of $contracts that no defined_in edge
+// comes from any more, with the edges out of them. Contract nodes, such as a
+// protobuf rpc, are shared by every file referring to them and stored with
+// none, so they go when the last file declaring them stops doing so. Edges
+// from the files using them are kept: contract IDs are derived from their
+// names, so a file declaring the contract again restores its node and
+// relinks them.
+const pruneContracts = `LET $declared = (SELECT VALUE from_id FROM edges WHERE edge_type = 'defined_in' AND from_id IN $contracts);
+				LET $orphans = array::complement($contracts, $declared);
+				DELETE FROM edges WHERE from_id IN $orphans;
+				DELETE FROM nodes WHERE id IN $orphans;`
+
+// declaredContracts returns the IDs of the contract nodes with defined_in
+// edges to any of nodeIDs
+func (s *Storage) declaredContracts(ctx context.Context, nodeIDs []string) ([]string, error) {
+	contracts := []string{}
+	if len(nodeIDs) == 0 {
+		return contracts, nil
+	}
+	results, err := runQuery[[]struct {
+		FromID string `json:"from_id"`
+	}](ctx, s.db, `SELECT from_id FROM edges WHERE edge_type = 'defined_in' AND to_id IN $ids`, map[string]any{
+		"ids": nodeIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query declared contracts: %w", err)
+	}
+	if results != nil && len(*results) > 0 {
+		for _, e := range (*results)[0].Result {
+			if !slices.Contains(contracts, e.FromID) {
+				contracts = append(contracts, e.FromID)
+			}
+		}
+	}
+	return contracts, nil
+}
+
+// nodeRecords returns the records upsertNodes stores for nodes
+func nodeRecords(nodes []*CodeNode) []map[string]any {
+	records := make([]map[string]any, len(nodes))
+	for i, node := range nodes {
+		annotations := node.Annotations
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		records[i] = map[string]any{
+			"id":               node.ID,
+			"name":             node.Name,
+			"node_type":        string(node.NodeType),
+			"language":         node.Language,
+			"file_path":        node.FilePath,
+			"start_line":       node.StartLine,
+			"end_line":         node.EndLine,
+			"content":          node.Content,
+			"doc_comment":      node.DocComment,
+			"annotations":      annotations,
+			"embedding":        node.Embedding,
+			"chunk_embeddings": node.ChunkEmbeddings,
+			"complexity":       node.Complexity,
+		}
+	}
+	return records
+}
+
+// edgeRecords returns the records upsertEdges stores for edges
+func edgeRecords(edges []*CodeEdge) []map[string]any {
+	records := make([]map[string]any, len(edges))
+	for i, edge := range edges {
+		records[i] = map[string]any{
+			"id":        edge.ID,
+			"from_id":   edge.FromID,
+			"to_id":     edge.ToID,
+			"edge_type": string(edge.EdgeType),
+			"weight":    edge.Weight,
+		}
+	}
+	return records
+}
+
+// UpdateFileNodes applies the changes of a reparsed file in one
+// transaction: nodes in changed are stored over their previous versions,
+// nodes with IDs in removed are deleted, and the edges out of the file's
+// nodes are replaced by edges. The file's other nodes are left as they are,
+// embeddings included. Edges from other files into removed nodes are
+// deleted, and contracts no file declares any more are pruned, as
+// UpdateFileAtomic does.
+func (s *Storage) UpdateFileNodes(ctx context.Context, filePath string, changed []*CodeNode, removed []string, edges []*CodeEdge) error {
+	ctx, span := s.startSpan(ctx, "UpdateFileNodes")
+	defer span.End()
+
+	s.lockFile(filePath)
+	defer s.unlockFile(filePath)
+
+	// Query for existing node IDs before starting the transaction, as
+	// UpdateFileAtomic does
+	results, err := runQuery[[]struct{ ID string }](ctx, s.db, `SELECT id FROM nodes WHERE `+fileNodes, map[string]any{
+		"path": filePath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query existing nodes: %w", err)
+	}
+	nodeIDs := make([]string, 0)
+	if results != nil && len(*results) > 0 {
+		for _, node := range (*results)[0].Result {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	contracts, err := s.declaredContracts(ctx, removed)
+	if err != nil {
+		return err
+	}
+
+	query := `BEGIN TRANSACTION;
+		DELETE FROM edges WHERE from_id IN $nodeIDs OR to_id IN $removed;
+		DELETE FROM nodes WHERE id IN $removed;
+		` + upsertNodes + `
+		` + upsertEdges + `
+		` + pruneContracts + `
+		` + graphVersionBump + `
+		COMMIT TRANSACTION;`
+	_, err = runQuery[any](ctx, s.db, query, map[string]any{
+		"nodeIDs":   nodeIDs,
+		"removed":   removed,
+		"nodeData":  nodeRecords(changed),
+		"edgeData":  edgeRecords(edges),
+		"contracts": contracts,
+	})
+	if err != nil {
+		return fmt.Errorf("incremental file update failed: %w", err)
+	}
+	return nil
+}
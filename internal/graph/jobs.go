@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// IndexJob is the persisted state of a background indexing job. Progress is
+// checkpointed after every completed file so that unfinished jobs can be
+// resumed after a restart.
+type IndexJob struct {
+	JobID           string   `json:"job_id"`
+	Name            string   `json:"name"`
+	Directory       string   `json:"directory"`
+	State           string   `json:"state"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+	SkipEmbeddings  bool     `json:"skip_embeddings"`
+	FilesTotal      int64    `json:"files_total"`
+	FilesSkipped    int64    `json:"files_skipped"`
+	FilesProcessed  int64    `json:"files_processed"`
+	NodesCreated    int64    `json:"nodes_created"`
+	LastFile        string   `json:"last_file,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	CreatedAt       int64    `json:"created_at"`
+	StartedAt       int64    `json:"started_at,omitempty"`
+	UpdatedAt       int64    `json:"updated_at"`
+	CompletedAt     int64    `json:"completed_at,omitempty"`
+}
+
+// UpsertIndexJob stores or updates a job checkpoint
+func (s *Storage) UpsertIndexJob(ctx context.Context, job *IndexJob) error {
+	ctx, span := s.startSpan(ctx, "UpsertIndexJob")
+	defer span.End()
+
+	query := `UPSERT index_jobs SET
+		job_id = $job_id,
+		name = $name,
+		directory = $directory,
+		state = $state,
+		exclude_patterns = $exclude_patterns,
+		skip_embeddings = $skip_embeddings,
+		files_total = $files_total,
+		files_skipped = $files_skipped,
+		files_processed = $files_processed,
+		nodes_created = $nodes_created,
+		last_file = $last_file,
+		error = $error,
+		created_at = $created_at,
+		started_at = $started_at,
+		updated_at = $updated_at,
+		completed_at = $completed_at
+	WHERE job_id = $job_id`
+
+	excludePatterns := job.ExcludePatterns
+	if excludePatterns == nil {
+		excludePatterns = []string{}
+	}
+
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
+		"job_id":           job.JobID,
+		"name":             job.Name,
+		"directory":        job.Directory,
+		"state":            job.State,
+		"exclude_patterns": excludePatterns,
+		"skip_embeddings":  job.SkipEmbeddings,
+		"files_total":      job.FilesTotal,
+		"files_skipped":    job.FilesSkipped,
+		"files_processed":  job.FilesProcessed,
+		"nodes_created":    job.NodesCreated,
+		"last_file":        job.LastFile,
+		"error":            job.Error,
+		"created_at":       job.CreatedAt,
+		"started_at":       job.StartedAt,
+		"updated_at":       job.UpdatedAt,
+		"completed_at":     job.CompletedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("index job upsert failed: %w", err)
+	}
+	return nil
+}
+
+// GetIndexJob retrieves a job by ID, returning nil if it does not exist
+func (s *Storage) GetIndexJob(ctx context.Context, jobID string) (*IndexJob, error) {
+	ctx, span := s.startSpan(ctx, "GetIndexJob")
+	defer span.End()
+
+	query := `SELECT * FROM index_jobs WHERE job_id = $job_id LIMIT 1`
+	results, err := runQuery[[]IndexJob](ctx, s.db, query, map[string]any{
+		"job_id": jobID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 || len((*results)[0].Result) == 0 {
+		return nil, nil
+	}
+	return &(*results)[0].Result[0], nil
+}
+
+// ListIndexJobs returns all persisted jobs, newest first
+func (s *Storage) ListIndexJobs(ctx context.Context) ([]IndexJob, error) {
+	ctx, span := s.startSpan(ctx, "ListIndexJobs")
+	defer span.End()
+
+	query := `SELECT * FROM index_jobs ORDER BY created_at DESC`
+	results, err := runQuery[[]IndexJob](ctx, s.db, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+	return (*results)[0].Result, nil
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Module granularities for BuildModuleGraph
+const (
+	GranularityFile      = "file"
+	GranularityDirectory = "directory"
+	GranularityPackage   = "package"
+)
+
+// Module node ID prefixes written by the parser. File modules are identified
+// by their bare file path.
+const (
+	PackageModulePrefix  = "package::"
+	ExternalModulePrefix = "external::"
+)
+
+// ModuleInfo is a module of a ModuleGraph
+type ModuleInfo struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"` // file, directory, package or external
+	Imports    int    `json:"imports"`
+	ImportedBy int    `json:"imported_by"`
+}
+
+// ModuleEdge is a dependency between modules, counting the file-level
+// imports it aggregates
+type ModuleEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// ModuleGraph is the import graph between modules at one granularity
+type ModuleGraph struct {
+	Granularity string       `json:"granularity"`
+	Modules     []ModuleInfo `json:"modules"`
+	Edges       []ModuleEdge `json:"edges"`
+}
+
+// GetModuleEdges returns the imports edges between modules and the contains
+// edges from packages to their files
+func (s *Storage) GetModuleEdges(ctx context.Context) (imports, contains []CodeEdge, err error) {
+	ctx, span := s.startSpan(ctx, "GetModuleEdges")
+	defer span.End()
+
+	imports, err = s.GetEdgesByType(ctx, EdgeTypeImports)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load import edges: %w", err)
+	}
+	contains, err = s.GetEdgesByType(ctx, EdgeTypeContains)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load package edges: %w", err)
+	}
+	return imports, contains, nil
+}
+
+// BuildModuleGraph aggregates file-level imports edges into a graph between
+// files, directories or packages. Files outside any package count as their
+// directory at package granularity, and dependencies of a module on itself
+// are dropped.
+func BuildModuleGraph(imports, contains []CodeEdge, granularity string) (*ModuleGraph, error) {
+	packageOf := make(map[string]string)   // file -> package ID
+	packageDirs := make(map[string]string) // package ID -> directory of its first file
+	for _, e := range contains {
+		if _, ok := packageOf[e.ToID]; !ok {
+			packageOf[e.ToID] = e.FromID
+		}
+		if dir, ok := packageDirs[e.FromID]; !ok || filepath.Dir(e.ToID) < dir {
+			packageDirs[e.FromID] = filepath.Dir(e.ToID)
+		}
+	}
+
+	var moduleOf func(id string) string
+	switch granularity {
+	case GranularityFile, "":
+		granularity = GranularityFile
+		moduleOf = func(id string) string { return id }
+	case GranularityDirectory:
+		moduleOf = func(id string) string {
+			if strings.HasPrefix(id, ExternalModulePrefix) {
+				return id
+			}
+			if strings.HasPrefix(id, PackageModulePrefix) {
+				if dir, ok := packageDirs[id]; ok {
+					return dir
+				}
+				return id
+			}
+			return filepath.Dir(id)
+		}
+	case GranularityPackage:
+		moduleOf = func(id string) string {
+			if strings.HasPrefix(id, ExternalModulePrefix) || strings.HasPrefix(id, PackageModulePrefix) {
+				return id
+			}
+			if pkg, ok := packageOf[id]; ok {
+				return pkg
+			}
+			return filepath.Dir(id)
+		}
+	default:
+		return nil, fmt.Errorf("unknown granularity %q", granularity)
+	}
+
+	type pair struct{ from, to string }
+	counts := make(map[pair]int)
+	for _, e := range imports {
+		from, to := moduleOf(e.FromID), moduleOf(e.ToID)
+		if from == to {
+			continue
+		}
+		counts[pair{from, to}]++
+	}
+
+	g := &ModuleGraph{Granularity: granularity, Modules: []ModuleInfo{}, Edges: []ModuleEdge{}}
+	modules := make(map[string]*ModuleInfo)
+	module := func(id string) *ModuleInfo {
+		if m, ok := modules[id]; ok {
+			return m
+		}
+		kind := granularity
+		if strings.HasPrefix(id, ExternalModulePrefix) {
+			kind = "external"
+		} else if strings.HasPrefix(id, PackageModulePrefix) {
+			kind = GranularityPackage
+		} else if granularity == GranularityPackage {
+			kind = GranularityDirectory
+		}
+		modules[id] = &ModuleInfo{ID: id, Kind: kind}
+		return modules[id]
+	}
+	for p, count := range counts {
+		g.Edges = append(g.Edges, ModuleEdge{From: p.from, To: p.to, Count: count})
+		module(p.from).Imports++
+		module(p.to).ImportedBy++
+	}
+
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	for _, m := range modules {
+		g.Modules = append(g.Modules, *m)
+	}
+	sort.Slice(g.Modules, func(i, j int) bool { return g.Modules[i].ID < g.Modules[j].ID })
+	return g, nil
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuildModuleGraph(t *testing.T) {
+	imports := []CodeEdge{
+		{FromID: "/r/cmd/main.go", ToID: "package::example.com/r/server"},
+		{FromID: "/r/cmd/main.go", ToID: "external::fmt"},
+		{FromID: "/r/server/http.go", ToID: "package::example.com/r/store"},
+		{FromID: "/r/server/http.go", ToID: "external::fmt"},
+		{FromID: "/r/server/grpc.go", ToID: "package::example.com/r/store"},
+		{FromID: "/r/web/app.js", ToID: "/r/web/util.js"},
+		{FromID: "/r/web/app.js", ToID: "/r/server/http.go"},
+	}
+	contains := []CodeEdge{
+		{FromID: "package::example.com/r/cmd", ToID: "/r/cmd/main.go"},
+		{FromID: "package::example.com/r/server", ToID: "/r/server/http.go"},
+		{FromID: "package::example.com/r/server", ToID: "/r/server/grpc.go"},
+		{FromID: "package::example.com/r/store", ToID: "/r/store/db.go"},
+	}
+
+	tests := []struct {
+		granularity string
+		edges       []string
+	}{
+		{GranularityFile, []string{
+			"/r/cmd/main.go -> external::fmt (1)",
+			"/r/cmd/main.go -> package::example.com/r/server (1)",
+			"/r/server/grpc.go -> package::example.com/r/store (1)",
+			"/r/server/http.go -> external::fmt (1)",
+			"/r/server/http.go -> package::example.com/r/store (1)",
+			"/r/web/app.js -> /r/server/http.go (1)",
+			"/r/web/app.js -> /r/web/util.js (1)",
+		}},
+		{GranularityDirectory, []string{
+			"/r/cmd -> /r/server (1)",
+			"/r/cmd -> external::fmt (1)",
+			"/r/server -> /r/store (2)",
+			"/r/server -> external::fmt (1)",
+			"/r/web -> /r/server (1)",
+		}},
+		{GranularityPackage, []string{
+			"/r/web -> package::example.com/r/server (1)",
+			"package::example.com/r/cmd -> external::fmt (1)",
+			"package::example.com/r/cmd -> package::example.com/r/server (1)",
+			"package::example.com/r/server -> external::fmt (1)",
+			"package::example.com/r/server -> package::example.com/r/store (2)",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity, func(t *testing.T) {
+			g, err := BuildModuleGraph(imports, contains, tt.granularity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range g.Edges {
+				got = append(got, fmt.Sprintf("%s -> %s (%d)", e.From, e.To, e.Count))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.edges) {
+				t.Errorf("got edges\n%v\nwant\n%v", got, tt.edges)
+			}
+		})
+	}
+
+	g, _ := BuildModuleGraph(imports, contains, GranularityPackage)
+	kinds := make(map[string]ModuleInfo)
+	for _, m := range g.Modules {
+		kinds[m.ID] = m
+	}
+	if m := kinds["external::fmt"]; m.Kind != "external" || m.ImportedBy != 2 {
+		t.Errorf("unexpected external module %+v", m)
+	}
+	if m := kinds["/r/web"]; m.Kind != GranularityDirectory || m.Imports != 1 {
+		t.Errorf("expected a file outside any package to count as its directory, got %+v", m)
+	}
+
+	if _, err := BuildModuleGraph(imports, contains, "crate"); err == nil {
+		t.Error("expected an error for an unknown granularity")
+	}
+}
@@ -0,0 +1,141 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// MovedNodeID returns the ID a node of oldPath gets when the file moves to
+// newPath. Node IDs are "<file path>::<qualified name>", so only the path prefix
+// changes and IDs from other files are returned unchanged. The file's module
+// node, whose ID is the file path itself, becomes newPath, and so do the
+// paths of code blocks embedded in the file, "<file path>#<block>".
+func MovedNodeID(id, oldPath, newPath string) string {
+	if id == oldPath {
+		return newPath
+	}
+	if rest, ok := strings.CutPrefix(id, oldPath+"::"); ok {
+		return newPath + "::" + rest
+	}
+	if rest, ok := strings.CutPrefix(id, oldPath+"#"); ok {
+		return newPath + "#" + rest
+	}
+	return id
+}
+
+// rekeyRecords replaces the nodes $oldIDs and the edges into and out of
+// them with the records of nodeRecords and edgeRecords passed as $nodeData
+// and $edgeData. A record's ID cannot be changed in place, so re-keyed
+// records are deleted and created again.
+const rekeyRecords = `DELETE FROM edges WHERE from_id IN $oldIDs OR to_id IN $oldIDs;
+		DELETE FROM nodes WHERE id IN $oldIDs;
+		` + upsertNodes + `
+		` + upsertEdges
+
+// rekeyNodes fetches the nodes with ids and every edge into or out of them,
+// and returns copies in which each node ID is replaced by newID(ID). The
+// copies keep every other field, embeddings included, and are stored with
+// rekeyRecords.
+func (s *Storage) rekeyNodes(ctx context.Context, ids []string, newID func(string) string) ([]*CodeNode, []*CodeEdge, error) {
+	nodes := []*CodeNode{}
+	edges := []*CodeEdge{}
+	if len(ids) == 0 {
+		return nodes, edges, nil
+	}
+
+	stored, err := s.GetNodesByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query nodes: %w", err)
+	}
+	for _, n := range stored {
+		node := n
+		node.ID = newID(n.ID)
+		nodes = append(nodes, &node)
+	}
+
+	edgeResults, err := runQuery[[]CodeEdge](ctx, s.db,
+		`SELECT * FROM edges WHERE from_id IN $ids OR to_id IN $ids`, map[string]any{
+			"ids": ids,
+		})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query edges: %w", err)
+	}
+	if edgeResults != nil && len(*edgeResults) > 0 {
+		for _, e := range (*edgeResults)[0].Result {
+			edge := e
+			edge.FromID = newID(e.FromID)
+			edge.ToID = newID(e.ToID)
+			edge.ID = FormatEdgeID(edge.FromID, edge.ToID, e.EdgeType)
+			edges = append(edges, &edge)
+		}
+	}
+	return nodes, edges, nil
+}
+
+// MoveFile re-keys everything stored for oldPath to newPath: node IDs and
+// file paths, edges in and out of those nodes (including edges from other
+// files), and file metadata. Embeddings are kept, so a renamed file does not
+// need to be re-embedded. Any data already stored for newPath is replaced.
+func (s *Storage) MoveFile(ctx context.Context, oldPath, newPath string) error {
+	ctx, span := s.startSpan(ctx, "MoveFile")
+	defer span.End()
+
+	if oldPath == newPath {
+		return nil
+	}
+
+	// Clear the destination first; UpdateFileAtomic takes its own lock
+	if err := s.UpdateFileAtomic(ctx, newPath, []*CodeNode{}, []*CodeEdge{}); err != nil {
+		return fmt.Errorf("failed to clear move destination: %w", err)
+	}
+
+	s.lockFile(oldPath)
+	defer s.unlockFile(oldPath)
+
+	// Fetch affected records before the transaction, as UpdateFileAtomic does
+	idResults, err := runQuery[[]struct{ ID string }](ctx, s.db, `SELECT id FROM nodes WHERE `+fileNodes, map[string]any{
+		"path": oldPath,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query nodes to move: %w", err)
+	}
+	oldIDs := []string{}
+	if idResults != nil && len(*idResults) > 0 {
+		for _, n := range (*idResults)[0].Result {
+			oldIDs = append(oldIDs, n.ID)
+		}
+	}
+
+	nodes, edges, err := s.rekeyNodes(ctx, oldIDs, func(id string) string {
+		return MovedNodeID(id, oldPath, newPath)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prepare file move: %w", err)
+	}
+	for _, node := range nodes {
+		node.FilePath = MovedNodeID(node.FilePath, oldPath, newPath)
+		if node.ID == newPath && node.NodeType == NodeTypeModule {
+			node.Name = filepath.Base(newPath)
+		}
+	}
+
+	query := `BEGIN TRANSACTION;
+		` + rekeyRecords + `
+		UPDATE file_metadata SET file_path = $newPath WHERE file_path = $oldPath;
+		` + graphVersionBump + `
+		COMMIT TRANSACTION;`
+
+	_, err = runQuery[any](ctx, s.db, query, map[string]any{
+		"oldPath":  oldPath,
+		"newPath":  newPath,
+		"oldIDs":   oldIDs,
+		"nodeData": nodeRecords(nodes),
+		"edgeData": edgeRecords(edges),
+	})
+	if err != nil {
+		return fmt.Errorf("file move failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package graph_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
+)
+
+func TestMoveFile(t *testing.T) {
+	storage := graphtest.Storage(t)
+	ctx := context.Background()
+
+	const oldPath, newPath = "/src/a.go", "/src/b/a.go"
+	embedding := []float32{0.1, 0.2, 0.3}
+	nodes := []*graph.CodeNode{
+		{ID: oldPath, Name: "a.go", NodeType: graph.NodeTypeModule, Language: "go", FilePath: oldPath},
+		{ID: oldPath + "::Foo", Name: "Foo", NodeType: graph.NodeTypeFunction, Language: "go", FilePath: oldPath,
+			Content: "func Foo() { Bar() }", Embedding: embedding, ChunkEmbeddings: [][]float32{embedding}},
+		{ID: oldPath + "::Bar", Name: "Bar", NodeType: graph.NodeTypeFunction, Language: "go", FilePath: oldPath},
+	}
+	edges := []*graph.CodeEdge{
+		{ID: graph.FormatEdgeID(oldPath+"::Foo", oldPath+"::Bar", graph.EdgeTypeCalls), FromID: oldPath + "::Foo", ToID: oldPath + "::Bar", EdgeType: graph.EdgeTypeCalls, Weight: 1},
+	}
+	if err := storage.UpdateFileAtomic(ctx, oldPath, nodes, edges); err != nil {
+		t.Fatalf("failed to store file: %v", err)
+	}
+
+	// A caller in another file, which keeps its ID
+	caller := &graph.CodeNode{ID: "/src/main.go::main", Name: "main", NodeType: graph.NodeTypeFunction, Language: "go", FilePath: "/src/main.go"}
+	callerEdge := &graph.CodeEdge{ID: graph.FormatEdgeID(caller.ID, oldPath+"::Foo", graph.EdgeTypeCalls), FromID: caller.ID, ToID: oldPath + "::Foo", EdgeType: graph.EdgeTypeCalls, Weight: 1}
+	if err := storage.UpdateFileAtomic(ctx, "/src/main.go", []*graph.CodeNode{caller}, []*graph.CodeEdge{callerEdge}); err != nil {
+		t.Fatalf("failed to store caller: %v", err)
+	}
+	if err := storage.UpsertFileMetadata(ctx, &graph.FileMetadata{FilePath: oldPath, ContentHash: "h", NodeCount: 3, EdgeCount: 1}); err != nil {
+		t.Fatalf("failed to store metadata: %v", err)
+	}
+
+	before, err := storage.GraphVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.MoveFile(ctx, oldPath, newPath); err != nil {
+		t.Fatalf("MoveFile failed: %v", err)
+	}
+	if after, err := storage.GraphVersion(ctx); err != nil || after <= before {
+		t.Errorf("expected the move to bump the graph version past %d, got %d (%v)", before, after, err)
+	}
+
+	if old, err := storage.GetNodesByFile(ctx, oldPath); err != nil || len(old) != 0 {
+		t.Errorf("expected no nodes left under %s, got %v (%v)", oldPath, old, err)
+	}
+	moved, err := storage.GetNodesByFile(ctx, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]graph.CodeNode)
+	for _, n := range moved {
+		byID[n.ID] = n
+	}
+	if len(byID) != 3 {
+		t.Fatalf("expected 3 nodes under %s, got %v", newPath, moved)
+	}
+	if module := byID[newPath]; module.Name != "a.go" || module.NodeType != graph.NodeTypeModule {
+		t.Errorf("expected the module node under its new path, got %+v", module)
+	}
+	foo, ok := byID[newPath+"::Foo"]
+	if !ok || len(foo.Embedding) != len(embedding) || len(foo.ChunkEmbeddings) != 1 || foo.Content != nodes[1].Content {
+		t.Errorf("expected Foo moved with its content and embeddings, got %+v", foo)
+	}
+
+	out, err := storage.GetOutgoingEdges(ctx, newPath+"::Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].ToID != newPath+"::Bar" || out[0].ID != graph.FormatEdgeID(newPath+"::Foo", newPath+"::Bar", graph.EdgeTypeCalls) {
+		t.Errorf("expected Foo -> Bar under the new path, got %+v", out)
+	}
+	in, err := storage.GetIncomingEdges(ctx, newPath+"::Foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in) != 1 || in[0].FromID != caller.ID {
+		t.Errorf("expected the call from main to follow Foo, got %+v", in)
+	}
+	if stale, err := storage.GetIncomingEdges(ctx, oldPath+"::Foo"); err != nil || len(stale) != 0 {
+		t.Errorf("expected no edges left into the old Foo, got %+v (%v)", stale, err)
+	}
+
+	if meta, err := storage.GetFileMetadata(ctx, newPath); err != nil || meta == nil || meta.ContentHash != "h" {
+		t.Errorf("expected the metadata moved to %s, got %+v (%v)", newPath, meta, err)
+	}
+}
@@ -3,13 +3,15 @@ package graph
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/surrealdb/surrealdb.go"
 )
 
@@ -27,6 +29,7 @@ type Storage struct {
 	db        *surrealdb.DB
 	namespace string
 	database  string
+	logger    *slog.Logger
 
 	// fileLocksMu protects the fileLocks map
 	fileLocksMu sync.Mutex
@@ -96,8 +99,14 @@ type StorageConfig struct {
 	Database  string
 	Username  string
 	Password  string
+	Logger    *slog.Logger // optional; defaults to slog.Default()
 }
 
+// fileNodes matches the nodes of the file $path, including those of the
+// code blocks embedded in it, such as notebook cells, whose file paths are
+// "<path>#<block>"
+const fileNodes = `(file_path = $path OR string::starts_with(file_path, $path + "#"))`
+
 type NodeType string
 
 const (
@@ -110,6 +119,10 @@ const (
 	NodeTypeStruct    NodeType = "struct"
 	NodeTypeEnum      NodeType = "enum"
 	NodeTypeMethod    NodeType = "method"
+	NodeTypeTable     NodeType = "table"
+	NodeTypeColumn    NodeType = "column"
+	NodeTypeOperation NodeType = "operation"
+	NodeTypeRoute     NodeType = "route"
 )
 
 type EdgeType string
@@ -120,7 +133,9 @@ const (
 	EdgeTypeUses       EdgeType = "uses"
 	EdgeTypeExtends    EdgeType = "extends"
 	EdgeTypeImplements EdgeType = "implements"
+	EdgeTypeContains   EdgeType = "contains"
 	EdgeTypeReferences EdgeType = "references"
+	EdgeTypeDefinedIn  EdgeType = "defined_in"
 )
 
 type CodeNode struct {
@@ -136,6 +151,10 @@ type CodeNode struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 	Embedding   []float32         `json:"embedding,omitempty"`
 	Complexity  float32           `json:"complexity,omitempty"`
+
+	// ChunkEmbeddings holds vectors for the rest of a long body, which is
+	// embedded in several chunks; Embedding always covers the first
+	ChunkEmbeddings [][]float32 `json:"chunk_embeddings,omitempty"`
 }
 
 type CodeEdge struct {
@@ -167,6 +186,24 @@ type FileMetadata struct {
 	CreatedAt     *time.Time `json:"created_at,omitempty"`
 	LastIndexedAt *time.Time `json:"last_indexed_at,omitempty"`
 	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+
+	// SyntaxErrors are the ranges of the file's current content that did
+	// not parse and ErrorRatio the share of its bytes they cover. Stale
+	// files parsed with more errors than the indexer's threshold allows, so
+	// their nodes are those of their last version that parsed.
+	SyntaxErrors []SyntaxError `json:"syntax_errors,omitempty"`
+	ErrorRatio   float64       `json:"error_ratio,omitempty"`
+	Stale        bool          `json:"stale,omitempty"`
+}
+
+// SyntaxError is a range of a file that did not parse
+type SyntaxError struct {
+	Path      string `json:"path,omitempty"` // virtual path of the cell or block, for documents
+	StartLine int    `json:"start_line"`
+	StartCol  int    `json:"start_col"`
+	EndLine   int    `json:"end_line"`
+	EndCol    int    `json:"end_col"`
+	Message   string `json:"message"`
 }
 
 func NewStorage(cfg StorageConfig) (*Storage, error) {
@@ -197,6 +234,7 @@ func NewStorage(cfg StorageConfig) (*Storage, error) {
 		db:        db,
 		namespace: cfg.Namespace,
 		database:  cfg.Database,
+		logger:    logging.Component(cfg.Logger, "graph"),
 	}, nil
 }
 
@@ -205,6 +243,9 @@ func (s *Storage) Close() error {
 }
 
 func (s *Storage) UpsertNode(ctx context.Context, node *CodeNode) error {
+	ctx, span := s.startSpan(ctx, "UpsertNode")
+	defer span.End()
+
 	// Use UPSERT to create or update the node
 	query := `UPSERT nodes SET
 		id = $id,
@@ -218,27 +259,32 @@ func (s *Storage) UpsertNode(ctx context.Context, node *CodeNode) error {
 		doc_comment = $doc_comment,
 		annotations = $annotations,
 		embedding = $embedding,
+		chunk_embeddings = $chunk_embeddings,
 		complexity = $complexity
 	WHERE id = $id`
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
-		"id":          node.ID,
-		"name":        node.Name,
-		"node_type":   string(node.NodeType),
-		"language":    node.Language,
-		"file_path":   node.FilePath,
-		"start_line":  node.StartLine,
-		"end_line":    node.EndLine,
-		"content":     node.Content,
-		"doc_comment": node.DocComment,
-		"annotations": node.Annotations,
-		"embedding":   node.Embedding,
-		"complexity":  node.Complexity,
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
+		"id":               node.ID,
+		"name":             node.Name,
+		"node_type":        string(node.NodeType),
+		"language":         node.Language,
+		"file_path":        node.FilePath,
+		"start_line":       node.StartLine,
+		"end_line":         node.EndLine,
+		"content":          node.Content,
+		"doc_comment":      node.DocComment,
+		"annotations":      node.Annotations,
+		"embedding":        node.Embedding,
+		"chunk_embeddings": node.ChunkEmbeddings,
+		"complexity":       node.Complexity,
 	})
 	return err
 }
 
 func (s *Storage) UpsertEdge(ctx context.Context, edge *CodeEdge) error {
+	ctx, span := s.startSpan(ctx, "UpsertEdge")
+	defer span.End()
+
 	// Use UPSERT to create or update the edge
 	query := `UPSERT edges SET
 		id = $id,
@@ -248,7 +294,7 @@ func (s *Storage) UpsertEdge(ctx context.Context, edge *CodeEdge) error {
 		weight = $weight
 	WHERE id = $id`
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
 		"id":        edge.ID,
 		"from_id":   edge.FromID,
 		"to_id":     edge.ToID,
@@ -261,6 +307,9 @@ func (s *Storage) UpsertEdge(ctx context.Context, edge *CodeEdge) error {
 // UpsertNodesBatch inserts multiple nodes in a single transaction
 // This is significantly faster than inserting nodes one at a time
 func (s *Storage) UpsertNodesBatch(ctx context.Context, nodes []*CodeNode) error {
+	ctx, span := s.startSpan(ctx, "UpsertNodesBatch")
+	defer span.End()
+
 	if len(nodes) == 0 {
 		return nil
 	}
@@ -275,18 +324,19 @@ func (s *Storage) UpsertNodesBatch(ctx context.Context, nodes []*CodeNode) error
 		}
 
 		nodeData[i] = map[string]any{
-			"id":          node.ID,
-			"name":        node.Name,
-			"node_type":   string(node.NodeType),
-			"language":    node.Language,
-			"file_path":   node.FilePath,
-			"start_line":  node.StartLine,
-			"end_line":    node.EndLine,
-			"content":     node.Content,
-			"doc_comment": node.DocComment,
-			"annotations": annotations,
-			"embedding":   node.Embedding,
-			"complexity":  node.Complexity,
+			"id":               node.ID,
+			"name":             node.Name,
+			"node_type":        string(node.NodeType),
+			"language":         node.Language,
+			"file_path":        node.FilePath,
+			"start_line":       node.StartLine,
+			"end_line":         node.EndLine,
+			"content":          node.Content,
+			"doc_comment":      node.DocComment,
+			"annotations":      annotations,
+			"embedding":        node.Embedding,
+			"chunk_embeddings": node.ChunkEmbeddings,
+			"complexity":       node.Complexity,
 		}
 	}
 
@@ -307,13 +357,14 @@ func (s *Storage) UpsertNodesBatch(ctx context.Context, nodes []*CodeNode) error
 				doc_comment = $node.doc_comment,
 				annotations = $node.annotations,
 				embedding = $node.embedding,
+				chunk_embeddings = $node.chunk_embeddings,
 				complexity = $node.complexity
 			WHERE id = $node.id;
 		};
 		COMMIT TRANSACTION;
 	`
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
 		"nodes": nodeData,
 	})
 	return err
@@ -321,6 +372,9 @@ func (s *Storage) UpsertNodesBatch(ctx context.Context, nodes []*CodeNode) error
 
 // UpsertEdgesBatch inserts multiple edges in a single transaction
 func (s *Storage) UpsertEdgesBatch(ctx context.Context, edges []*CodeEdge) error {
+	ctx, span := s.startSpan(ctx, "UpsertEdgesBatch")
+	defer span.End()
+
 	if len(edges) == 0 {
 		return nil
 	}
@@ -351,13 +405,16 @@ func (s *Storage) UpsertEdgesBatch(ctx context.Context, edges []*CodeEdge) error
 		COMMIT TRANSACTION;
 	`
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
 		"edges": edgeData,
 	})
 	return err
 }
 
 func (s *Storage) GetNode(ctx context.Context, id string) (*CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetNode")
+	defer span.End()
+
 	node, err := surrealdb.Select[CodeNode](ctx, s.db, "nodes:"+id)
 	if err != nil {
 		return nil, err
@@ -368,6 +425,25 @@ func (s *Storage) GetNode(ctx context.Context, id string) (*CodeNode, error) {
 // GetTransitiveDependencies returns all nodes that nodeID depends on, up to the specified depth.
 // Uses iterative BFS to traverse the dependency graph.
 func (s *Storage) GetTransitiveDependencies(ctx context.Context, nodeID string, depth int) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetTransitiveDependencies")
+	defer span.End()
+
+	return s.traverse(ctx, nodeID, depth, true)
+}
+
+// GetTransitiveDependents returns all nodes that depend on nodeID, up to the
+// specified depth, following edges backwards. It reaches code in other
+// languages through the contract nodes it implements or uses.
+func (s *Storage) GetTransitiveDependents(ctx context.Context, nodeID string, depth int) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetTransitiveDependents")
+	defer span.End()
+
+	return s.traverse(ctx, nodeID, depth, false)
+}
+
+// traverse walks the edges from nodeID breadth first, along their direction
+// when outgoing is set and against it otherwise
+func (s *Storage) traverse(ctx context.Context, nodeID string, depth int, outgoing bool) ([]CodeNode, error) {
 	if depth <= 0 {
 		depth = 3
 	}
@@ -391,9 +467,11 @@ func (s *Storage) GetTransitiveDependencies(ctx context.Context, nodeID string,
 		var nextLevel []string
 
 		for _, currentID := range currentLevel {
-			// Query edges where this node is the source (outgoing dependencies)
 			query := `SELECT * FROM edges WHERE from_id = $id`
-			edgeResults, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+			if !outgoing {
+				query = `SELECT * FROM edges WHERE to_id = $id`
+			}
+			edgeResults, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 				"id": currentID,
 			})
 			if err != nil {
@@ -406,9 +484,13 @@ func (s *Storage) GetTransitiveDependencies(ctx context.Context, nodeID string,
 
 			edges := (*edgeResults)[0].Result
 			for _, edge := range edges {
-				if !visited[edge.ToID] {
-					visited[edge.ToID] = true
-					nextLevel = append(nextLevel, edge.ToID)
+				next := edge.ToID
+				if !outgoing {
+					next = edge.FromID
+				}
+				if !visited[next] {
+					visited[next] = true
+					nextLevel = append(nextLevel, next)
 				}
 			}
 		}
@@ -422,7 +504,7 @@ func (s *Storage) GetTransitiveDependencies(ctx context.Context, nodeID string,
 				params[fmt.Sprintf("id%d", i)] = nid
 			}
 			query := fmt.Sprintf(`SELECT * FROM nodes WHERE id IN [%s]`, strings.Join(placeholders, ", "))
-			nodeResults, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, params)
+			nodeResults, err := runQuery[[]CodeNode](ctx, s.db, query, params)
 			if err == nil && nodeResults != nil && len(*nodeResults) > 0 {
 				result = append(result, (*nodeResults)[0].Result...)
 			}
@@ -443,6 +525,9 @@ func FormatEdgeID(fromID, toID string, edgeType EdgeType) string {
 // TraceCallChain finds a path of function calls from 'from' to 'to'.
 // Uses BFS to find the shortest path through call edges.
 func (s *Storage) TraceCallChain(ctx context.Context, from, to string) ([]CodeEdge, error) {
+	ctx, span := s.startSpan(ctx, "TraceCallChain")
+	defer span.End()
+
 	// First, try to find nodes by name if not full IDs
 	fromID, err := s.resolveNodeID(ctx, from)
 	if err != nil {
@@ -479,7 +564,7 @@ func (s *Storage) TraceCallChain(ctx context.Context, from, to string) ([]CodeEd
 
 		// Get outgoing call edges from current node
 		query := `SELECT * FROM edges WHERE from_id = $id AND edge_type = $edgeType`
-		edgeResults, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+		edgeResults, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 			"id":       current.nodeID,
 			"edgeType": string(EdgeTypeCalls),
 		})
@@ -519,6 +604,9 @@ func (s *Storage) TraceCallChain(ctx context.Context, from, to string) ([]CodeEd
 // SemanticSearch finds nodes similar to the query embedding using cosine similarity.
 // Fetches all nodes with embeddings and ranks them by similarity.
 func (s *Storage) SemanticSearch(ctx context.Context, queryEmbedding []float32, limit int) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "SemanticSearch")
+	defer span.End()
+
 	if len(queryEmbedding) == 0 {
 		return nil, fmt.Errorf("query embedding is empty")
 	}
@@ -534,7 +622,7 @@ func (s *Storage) SemanticSearch(ctx context.Context, queryEmbedding []float32,
 	// Fetch all nodes that have embeddings
 	// Note: For large codebases, this should be paginated or use an external vector DB
 	query := `SELECT * FROM nodes WHERE embedding != NONE LIMIT 10000`
-	results, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, nil)
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch nodes: %w", err)
 	}
@@ -555,16 +643,11 @@ func (s *Storage) SemanticSearch(ctx context.Context, queryEmbedding []float32,
 		default:
 		}
 
-		if len(node.Embedding) == 0 {
-			continue
-		}
-
-		// Ensure embedding dimensions match
-		if len(node.Embedding) != len(queryEmbedding) {
+		// A chunked node scores as its best-matching chunk
+		similarity, ok := bestSimilarity(queryEmbedding, node)
+		if !ok {
 			continue
 		}
-
-		similarity := cosineSimilarity(queryEmbedding, node.Embedding)
 		scored = append(scored, ScoredNode{
 			Node:  node,
 			Score: similarity,
@@ -588,6 +671,26 @@ func (s *Storage) SemanticSearch(ctx context.Context, queryEmbedding []float32,
 	return result, nil
 }
 
+// bestSimilarity returns the highest cosine similarity between the query and
+// the node's vectors of matching dimension, and false if there are none
+func bestSimilarity(query []float32, node CodeNode) (float64, bool) {
+	best, found := 0.0, false
+	for i := -1; i < len(node.ChunkEmbeddings); i++ {
+		vec := node.Embedding
+		if i >= 0 {
+			vec = node.ChunkEmbeddings[i]
+		}
+		// Ensure embedding dimensions match
+		if len(vec) == 0 || len(vec) != len(query) {
+			continue
+		}
+		if sim := cosineSimilarity(query, vec); !found || sim > best {
+			best, found = sim, true
+		}
+	}
+	return best, found
+}
+
 // cosineSimilarity calculates the cosine similarity between two vectors
 func cosineSimilarity(a, b []float32) float64 {
 	if len(a) != len(b) || len(a) == 0 {
@@ -612,7 +715,7 @@ func cosineSimilarity(a, b []float32) float64 {
 func (s *Storage) resolveNodeID(ctx context.Context, nameOrID string) (string, error) {
 	// First check if it's already a valid ID
 	query := `SELECT id FROM nodes WHERE id = $id`
-	results, err := surrealdb.Query[[]struct{ ID string }](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]struct{ ID string }](ctx, s.db, query, map[string]any{
 		"id": nameOrID,
 	})
 	if err == nil && results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
@@ -621,7 +724,7 @@ func (s *Storage) resolveNodeID(ctx context.Context, nameOrID string) (string, e
 
 	// Try to find by name
 	query = `SELECT id FROM nodes WHERE name = $name LIMIT 1`
-	results, err = surrealdb.Query[[]struct{ ID string }](ctx, s.db, query, map[string]any{
+	results, err = runQuery[[]struct{ ID string }](ctx, s.db, query, map[string]any{
 		"name": nameOrID,
 	})
 	if err == nil && results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
@@ -630,7 +733,7 @@ func (s *Storage) resolveNodeID(ctx context.Context, nameOrID string) (string, e
 
 	// Try partial name match
 	query = `SELECT id FROM nodes WHERE name CONTAINS $name LIMIT 1`
-	results, err = surrealdb.Query[[]struct{ ID string }](ctx, s.db, query, map[string]any{
+	results, err = runQuery[[]struct{ ID string }](ctx, s.db, query, map[string]any{
 		"name": nameOrID,
 	})
 	if err == nil && results != nil && len(*results) > 0 && len((*results)[0].Result) > 0 {
@@ -643,7 +746,7 @@ func (s *Storage) resolveNodeID(ctx context.Context, nameOrID string) (string, e
 // findNodeByID retrieves a node by its ID
 func (s *Storage) findNodeByID(ctx context.Context, id string) (*CodeNode, error) {
 	query := `SELECT * FROM nodes WHERE id = $id LIMIT 1`
-	results, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, map[string]any{
 		"id": id,
 	})
 	if err != nil {
@@ -668,8 +771,11 @@ func (s *Storage) nodeNameMatches(ctx context.Context, nodeID, name string) bool
 
 // GetAllEdges retrieves all edges from the graph
 func (s *Storage) GetAllEdges(ctx context.Context) ([]CodeEdge, error) {
+	ctx, span := s.startSpan(ctx, "GetAllEdges")
+	defer span.End()
+
 	query := `SELECT * FROM edges LIMIT 10000`
-	results, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, nil)
+	results, err := runQuery[[]CodeEdge](ctx, s.db, query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -682,8 +788,11 @@ func (s *Storage) GetAllEdges(ctx context.Context) ([]CodeEdge, error) {
 
 // GetEdgesByType retrieves edges filtered by type
 func (s *Storage) GetEdgesByType(ctx context.Context, edgeType EdgeType) ([]CodeEdge, error) {
+	ctx, span := s.startSpan(ctx, "GetEdgesByType")
+	defer span.End()
+
 	query := `SELECT * FROM edges WHERE edge_type = $edgeType`
-	results, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 		"edgeType": string(edgeType),
 	})
 	if err != nil {
@@ -697,8 +806,11 @@ func (s *Storage) GetEdgesByType(ctx context.Context, edgeType EdgeType) ([]Code
 }
 
 func (s *Storage) FindByName(ctx context.Context, name string) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "FindByName")
+	defer span.End()
+
 	query := `SELECT * FROM nodes WHERE name CONTAINS $name`
-	results, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, map[string]any{
 		"name": name,
 	})
 	if err != nil {
@@ -712,8 +824,11 @@ func (s *Storage) FindByName(ctx context.Context, name string) ([]CodeNode, erro
 }
 
 func (s *Storage) GetNodesByFile(ctx context.Context, filePath string) ([]CodeNode, error) {
-	query := `SELECT * FROM nodes WHERE file_path = $path`
-	results, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, map[string]any{
+	ctx, span := s.startSpan(ctx, "GetNodesByFile")
+	defer span.End()
+
+	query := `SELECT * FROM nodes WHERE ` + fileNodes
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	if err != nil {
@@ -726,9 +841,35 @@ func (s *Storage) GetNodesByFile(ctx context.Context, filePath string) ([]CodeNo
 	return (*results)[0].Result, nil
 }
 
+// GetNodesByIDs retrieves the nodes with the given IDs; IDs with no node are
+// skipped
+func (s *Storage) GetNodesByIDs(ctx context.Context, ids []string) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetNodesByIDs")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `SELECT * FROM nodes WHERE id IN $ids`
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, map[string]any{
+		"ids": ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+	return (*results)[0].Result, nil
+}
+
 func (s *Storage) GetAllNodes(ctx context.Context) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetAllNodes")
+	defer span.End()
+
 	query := `SELECT * FROM nodes LIMIT 10000`
-	results, err := surrealdb.Query[[]CodeNode](ctx, s.db, query, nil)
+	results, err := runQuery[[]CodeNode](ctx, s.db, query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -740,11 +881,14 @@ func (s *Storage) GetAllNodes(ctx context.Context) ([]CodeNode, error) {
 }
 
 func (s *Storage) DeleteNodesByFile(ctx context.Context, filePath string) error {
+	ctx, span := s.startSpan(ctx, "DeleteNodesByFile")
+	defer span.End()
+
 	s.lockFile(filePath)
 	defer s.unlockFile(filePath)
 
-	query := `DELETE FROM nodes WHERE file_path = $path`
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	query := `DELETE FROM nodes WHERE ` + fileNodes
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	return err
@@ -752,8 +896,11 @@ func (s *Storage) DeleteNodesByFile(ctx context.Context, filePath string) error
 
 // GetIncomingEdges returns all edges pointing to a node
 func (s *Storage) GetIncomingEdges(ctx context.Context, nodeID string) ([]CodeEdge, error) {
+	ctx, span := s.startSpan(ctx, "GetIncomingEdges")
+	defer span.End()
+
 	query := `SELECT * FROM edges WHERE to_id = $id`
-	results, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 		"id": nodeID,
 	})
 	if err != nil {
@@ -768,8 +915,11 @@ func (s *Storage) GetIncomingEdges(ctx context.Context, nodeID string) ([]CodeEd
 
 // GetOutgoingEdges returns all edges from a node
 func (s *Storage) GetOutgoingEdges(ctx context.Context, nodeID string) ([]CodeEdge, error) {
+	ctx, span := s.startSpan(ctx, "GetOutgoingEdges")
+	defer span.End()
+
 	query := `SELECT * FROM edges WHERE from_id = $id`
-	results, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 		"id": nodeID,
 	})
 	if err != nil {
@@ -784,9 +934,12 @@ func (s *Storage) GetOutgoingEdges(ctx context.Context, nodeID string) ([]CodeEd
 
 // GetCallers returns all nodes that call the given node
 func (s *Storage) GetCallers(ctx context.Context, nodeID string) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetCallers")
+	defer span.End()
+
 	// First get incoming call edges
 	query := `SELECT * FROM edges WHERE to_id = $id AND edge_type = $edgeType`
-	edgeResults, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+	edgeResults, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 		"id":       nodeID,
 		"edgeType": string(EdgeTypeCalls),
 	})
@@ -812,9 +965,12 @@ func (s *Storage) GetCallers(ctx context.Context, nodeID string) ([]CodeNode, er
 
 // GetCallees returns all nodes that are called by the given node
 func (s *Storage) GetCallees(ctx context.Context, nodeID string) ([]CodeNode, error) {
+	ctx, span := s.startSpan(ctx, "GetCallees")
+	defer span.End()
+
 	// Get outgoing call edges
 	query := `SELECT * FROM edges WHERE from_id = $id AND edge_type = $edgeType`
-	edgeResults, err := surrealdb.Query[[]CodeEdge](ctx, s.db, query, map[string]any{
+	edgeResults, err := runQuery[[]CodeEdge](ctx, s.db, query, map[string]any{
 		"id":       nodeID,
 		"edgeType": string(EdgeTypeCalls),
 	})
@@ -839,6 +995,9 @@ func (s *Storage) GetCallees(ctx context.Context, nodeID string) ([]CodeNode, er
 }
 
 func (s *Storage) RunMigrations(ctx context.Context) error {
+	ctx, span := s.startSpan(ctx, "RunMigrations")
+	defer span.End()
+
 	migrations := []string{
 		`DEFINE TABLE nodes SCHEMAFULL`,
 		`DEFINE FIELD id ON nodes TYPE string`,
@@ -853,6 +1012,7 @@ func (s *Storage) RunMigrations(ctx context.Context) error {
 		`DEFINE FIELD annotations ON nodes TYPE option<object>`,
 		`DEFINE FIELD embedding ON nodes TYPE option<array<float>>`,
 		`DEFINE FIELD complexity ON nodes TYPE option<float>`,
+		`DEFINE FIELD chunk_embeddings ON nodes TYPE option<array<array<float>>>`,
 		`DEFINE INDEX idx_nodes_id ON nodes FIELDS id UNIQUE`,
 		`DEFINE INDEX idx_nodes_file ON nodes FIELDS file_path`,
 		`DEFINE INDEX idx_nodes_name ON nodes FIELDS name`,
@@ -883,11 +1043,49 @@ func (s *Storage) RunMigrations(ctx context.Context) error {
 		`DEFINE FIELD file_size ON file_metadata TYPE option<int>`,
 		`DEFINE FIELD language ON file_metadata TYPE option<string>`,
 		`DEFINE FIELD modified_at ON file_metadata TYPE option<datetime>`,
+		`DEFINE FIELD error_ratio ON file_metadata TYPE option<float>`,
+		`DEFINE FIELD stale ON file_metadata TYPE option<bool>`,
+		`DEFINE FIELD syntax_errors ON file_metadata TYPE option<array<object>>`,
+		`DEFINE FIELD syntax_errors.* ON file_metadata TYPE object`,
+		`DEFINE FIELD syntax_errors.*.path ON file_metadata TYPE option<string>`,
+		`DEFINE FIELD syntax_errors.*.start_line ON file_metadata TYPE int`,
+		`DEFINE FIELD syntax_errors.*.start_col ON file_metadata TYPE int`,
+		`DEFINE FIELD syntax_errors.*.end_line ON file_metadata TYPE int`,
+		`DEFINE FIELD syntax_errors.*.end_col ON file_metadata TYPE int`,
+		`DEFINE FIELD syntax_errors.*.message ON file_metadata TYPE string`,
 		`DEFINE INDEX idx_file_metadata_path ON file_metadata FIELDS file_path UNIQUE`,
+
+		// Background indexing jobs, checkpointed per file for resume
+		`DEFINE TABLE index_jobs SCHEMAFULL`,
+		`DEFINE FIELD job_id ON index_jobs TYPE string`,
+		`DEFINE FIELD name ON index_jobs TYPE string`,
+		`DEFINE FIELD directory ON index_jobs TYPE string`,
+		`DEFINE FIELD state ON index_jobs TYPE string`,
+		`DEFINE FIELD exclude_patterns ON index_jobs TYPE array<string>`,
+		`DEFINE FIELD skip_embeddings ON index_jobs TYPE bool`,
+		`DEFINE FIELD files_total ON index_jobs TYPE int`,
+		`DEFINE FIELD files_skipped ON index_jobs TYPE int`,
+		`DEFINE FIELD files_processed ON index_jobs TYPE int`,
+		`DEFINE FIELD nodes_created ON index_jobs TYPE int`,
+		`DEFINE FIELD last_file ON index_jobs TYPE string`,
+		`DEFINE FIELD error ON index_jobs TYPE string`,
+		`DEFINE FIELD created_at ON index_jobs TYPE int`,
+		`DEFINE FIELD started_at ON index_jobs TYPE int`,
+		`DEFINE FIELD updated_at ON index_jobs TYPE int`,
+		`DEFINE FIELD completed_at ON index_jobs TYPE int`,
+		`DEFINE INDEX idx_index_jobs_id ON index_jobs FIELDS job_id UNIQUE`,
+		`DEFINE TABLE watched_dirs SCHEMAFULL`,
+		`DEFINE FIELD path ON watched_dirs TYPE string`,
+		`DEFINE FIELD added_at ON watched_dirs TYPE int`,
+		`DEFINE INDEX idx_watched_dirs_path ON watched_dirs FIELDS path UNIQUE`,
+
+		// Single-record table holding the graph version counter
+		`DEFINE TABLE graph_meta SCHEMAFULL`,
+		`DEFINE FIELD value ON graph_meta TYPE int DEFAULT 0`,
 	}
 
 	for _, m := range migrations {
-		if _, err := surrealdb.Query[any](ctx, s.db, m, nil); err != nil {
+		if _, err := runQuery[any](ctx, s.db, m, nil); err != nil {
 			// Check if this is an "already exists" error, which is benign
 			// Use lowercase matching for case-insensitivity across different SurrealDB versions
 			errStr := strings.ToLower(err.Error())
@@ -905,8 +1103,8 @@ func (s *Storage) RunMigrations(ctx context.Context) error {
 			// Real error that should be surfaced
 			// Log as warning to make it visible without breaking startup
 			// This helps diagnose configuration issues, permission problems, etc.
-			log.Printf("Warning: migration failed for query '%s': %v\n"+
-				"This may indicate a database configuration issue. Continuing anyway.", m, err)
+			s.logger.Warn("migration failed; this may indicate a database configuration issue, continuing anyway",
+				"query", m, "error", err)
 			continue
 		}
 	}
@@ -916,6 +1114,9 @@ func (s *Storage) RunMigrations(ctx context.Context) error {
 
 // UpsertFileMetadata stores or updates file metadata for incremental indexing
 func (s *Storage) UpsertFileMetadata(ctx context.Context, meta *FileMetadata) error {
+	ctx, span := s.startSpan(ctx, "UpsertFileMetadata")
+	defer span.End()
+
 	// Use default project if not specified
 	projectID := meta.ProjectID
 	if projectID == "" {
@@ -935,19 +1136,37 @@ func (s *Storage) UpsertFileMetadata(ctx context.Context, meta *FileMetadata) er
 		project_id = $project_id,
 		file_size = $file_size,
 		language = $language,
+		error_ratio = $error_ratio,
+		stale = $stale,
+		syntax_errors = $syntax_errors,
 		modified_at = time::now()
 	WHERE file_path = $file_path`
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
-		"file_path":    meta.FilePath,
-		"content_hash": meta.ContentHash,
-		"mod_time":     meta.ModTime,
-		"indexed_at":   meta.IndexedAt,
-		"node_count":   meta.NodeCount,
-		"edge_count":   meta.EdgeCount,
-		"project_id":   projectID,
-		"file_size":    meta.FileSize,
-		"language":     meta.Language,
+	syntaxErrors := make([]map[string]any, len(meta.SyntaxErrors))
+	for i, e := range meta.SyntaxErrors {
+		syntaxErrors[i] = map[string]any{
+			"path":       e.Path,
+			"start_line": e.StartLine,
+			"start_col":  e.StartCol,
+			"end_line":   e.EndLine,
+			"end_col":    e.EndCol,
+			"message":    e.Message,
+		}
+	}
+
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
+		"file_path":     meta.FilePath,
+		"content_hash":  meta.ContentHash,
+		"mod_time":      meta.ModTime,
+		"indexed_at":    meta.IndexedAt,
+		"node_count":    meta.NodeCount,
+		"edge_count":    meta.EdgeCount,
+		"project_id":    projectID,
+		"file_size":     meta.FileSize,
+		"language":      meta.Language,
+		"error_ratio":   meta.ErrorRatio,
+		"stale":         meta.Stale,
+		"syntax_errors": syntaxErrors,
 	})
 	if err != nil {
 		return fmt.Errorf("file metadata upsert failed: %w", err)
@@ -957,8 +1176,11 @@ func (s *Storage) UpsertFileMetadata(ctx context.Context, meta *FileMetadata) er
 
 // GetFileMetadata retrieves metadata for a specific file
 func (s *Storage) GetFileMetadata(ctx context.Context, filePath string) (*FileMetadata, error) {
+	ctx, span := s.startSpan(ctx, "GetFileMetadata")
+	defer span.End()
+
 	query := `SELECT * FROM file_metadata WHERE file_path = $path LIMIT 1`
-	results, err := surrealdb.Query[[]FileMetadata](ctx, s.db, query, map[string]any{
+	results, err := runQuery[[]FileMetadata](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	if err != nil {
@@ -974,8 +1196,55 @@ func (s *Storage) GetFileMetadata(ctx context.Context, filePath string) (*FileMe
 
 // GetAllFileMetadata retrieves all file metadata (for detecting deleted files)
 func (s *Storage) GetAllFileMetadata(ctx context.Context) ([]FileMetadata, error) {
+	ctx, span := s.startSpan(ctx, "GetAllFileMetadata")
+	defer span.End()
+
 	query := `SELECT * FROM file_metadata`
-	results, err := surrealdb.Query[[]FileMetadata](ctx, s.db, query, nil)
+	results, err := runQuery[[]FileMetadata](ctx, s.db, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+	return (*results)[0].Result, nil
+}
+
+// GetFileMetadataUnder retrieves metadata for all files inside dir (for
+// detecting removed directories)
+func (s *Storage) GetFileMetadataUnder(ctx context.Context, dir string) ([]FileMetadata, error) {
+	ctx, span := s.startSpan(ctx, "GetFileMetadataUnder")
+	defer span.End()
+
+	query := `SELECT * FROM file_metadata WHERE string::starts_with(file_path, $prefix)`
+	results, err := runQuery[[]FileMetadata](ctx, s.db, query, map[string]any{
+		"prefix": strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+	return (*results)[0].Result, nil
+}
+
+// GetFilesWithSyntaxErrors retrieves metadata for the files whose current
+// content does not parse cleanly, with the worst first. A non-empty prefix
+// keeps only the files under it.
+func (s *Storage) GetFilesWithSyntaxErrors(ctx context.Context, prefix string) ([]FileMetadata, error) {
+	ctx, span := s.startSpan(ctx, "GetFilesWithSyntaxErrors")
+	defer span.End()
+
+	query := `SELECT * FROM file_metadata
+		WHERE syntax_errors != NONE AND array::len(syntax_errors) > 0
+			AND string::starts_with(file_path, $prefix)
+		ORDER BY error_ratio DESC, file_path`
+	results, err := runQuery[[]FileMetadata](ctx, s.db, query, map[string]any{
+		"prefix": prefix,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -988,8 +1257,11 @@ func (s *Storage) GetAllFileMetadata(ctx context.Context) ([]FileMetadata, error
 
 // DeleteFileMetadata removes metadata for a specific file
 func (s *Storage) DeleteFileMetadata(ctx context.Context, filePath string) error {
+	ctx, span := s.startSpan(ctx, "DeleteFileMetadata")
+	defer span.End()
+
 	query := `DELETE FROM file_metadata WHERE file_path = $path`
-	_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	return err
@@ -997,9 +1269,12 @@ func (s *Storage) DeleteFileMetadata(ctx context.Context, filePath string) error
 
 // DeleteEdgesByFile removes all edges originating from nodes in a specific file
 func (s *Storage) DeleteEdgesByFile(ctx context.Context, filePath string) error {
+	ctx, span := s.startSpan(ctx, "DeleteEdgesByFile")
+	defer span.End()
+
 	// Get all node IDs for this file
-	query := `SELECT id FROM nodes WHERE file_path = $path`
-	results, err := surrealdb.Query[[]struct{ ID string }](ctx, s.db, query, map[string]any{
+	query := `SELECT id FROM nodes WHERE ` + fileNodes
+	results, err := runQuery[[]struct{ ID string }](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	if err != nil {
@@ -1017,7 +1292,7 @@ func (s *Storage) DeleteEdgesByFile(ctx context.Context, filePath string) error
 	}
 
 	query = `DELETE FROM edges WHERE from_id IN $ids OR to_id IN $ids`
-	_, err = surrealdb.Query[any](ctx, s.db, query, map[string]any{
+	_, err = runQuery[any](ctx, s.db, query, map[string]any{
 		"ids": nodeIDs,
 	})
 	return err
@@ -1027,6 +1302,9 @@ func (s *Storage) DeleteEdgesByFile(ctx context.Context, filePath string) error
 // This ensures data integrity - either all nodes and edges are stored, or none are.
 // If storing edges fails, the entire transaction is rolled back, preventing orphaned edges.
 func (s *Storage) StoreGraphAtomic(ctx context.Context, nodes []*CodeNode, edges []*CodeEdge) error {
+	ctx, span := s.startSpan(ctx, "StoreGraphAtomic")
+	defer span.End()
+
 	if len(nodes) == 0 && len(edges) == 0 {
 		return nil
 	}
@@ -1045,18 +1323,19 @@ func (s *Storage) StoreGraphAtomic(ctx context.Context, nodes []*CodeNode, edges
 			}
 
 			nodeData[i] = map[string]any{
-				"id":          node.ID,
-				"name":        node.Name,
-				"node_type":   string(node.NodeType),
-				"language":    node.Language,
-				"file_path":   node.FilePath,
-				"start_line":  node.StartLine,
-				"end_line":    node.EndLine,
-				"content":     node.Content,
-				"doc_comment": node.DocComment,
-				"annotations": annotations,
-				"embedding":   node.Embedding,
-				"complexity":  node.Complexity,
+				"id":               node.ID,
+				"name":             node.Name,
+				"node_type":        string(node.NodeType),
+				"language":         node.Language,
+				"file_path":        node.FilePath,
+				"start_line":       node.StartLine,
+				"end_line":         node.EndLine,
+				"content":          node.Content,
+				"doc_comment":      node.DocComment,
+				"annotations":      annotations,
+				"embedding":        node.Embedding,
+				"chunk_embeddings": node.ChunkEmbeddings,
+				"complexity":       node.Complexity,
 			}
 		}
 
@@ -1074,6 +1353,7 @@ func (s *Storage) StoreGraphAtomic(ctx context.Context, nodes []*CodeNode, edges
 					doc_comment = $node.doc_comment,
 					annotations = $node.annotations,
 					embedding = $node.embedding,
+					chunk_embeddings = $node.chunk_embeddings,
 					complexity = $node.complexity
 				WHERE id = $node.id;
 			}`)
@@ -1110,10 +1390,12 @@ func (s *Storage) StoreGraphAtomic(ctx context.Context, nodes []*CodeNode, edges
 		params["edgeData"] = edgeData
 	}
 
+	transactionParts = append(transactionParts, graphVersionBump)
+
 	// Combine into a single transaction
 	query := "BEGIN TRANSACTION;\n" + strings.Join(transactionParts, "\n") + "\nCOMMIT TRANSACTION;"
 
-	_, err := surrealdb.Query[any](ctx, s.db, query, params)
+	_, err := runQuery[any](ctx, s.db, query, params)
 	return err
 }
 
@@ -1121,32 +1403,19 @@ func (s *Storage) StoreGraphAtomic(ctx context.Context, nodes []*CodeNode, edges
 // This ensures data integrity by using a transaction:
 // 1. Delete old nodes and edges for file
 // 2. Store new nodes and edges
+// 3. Delete the contract nodes no file declares any more
 // All operations are performed in a single transaction; if any step fails, the entire transaction is rolled back.
 func (s *Storage) UpdateFileAtomic(ctx context.Context, filePath string, nodes []*CodeNode, edges []*CodeEdge) error {
+	ctx, span := s.startSpan(ctx, "UpdateFileAtomic")
+	defer span.End()
+
 	s.lockFile(filePath)
 	defer s.unlockFile(filePath)
 
-	if len(nodes) == 0 && len(edges) == 0 {
-		// If no new data, just delete old data atomically in a transaction
-		// This ensures we don't leave orphaned data if one delete fails
-		query := `BEGIN TRANSACTION;
-		           DELETE FROM edges WHERE from_id IN (SELECT id FROM nodes WHERE file_path = $path) OR to_id IN (SELECT id FROM nodes WHERE file_path = $path);
-		           DELETE FROM nodes WHERE file_path = $path;
-		           DELETE FROM file_metadata WHERE file_path = $path;
-		           COMMIT TRANSACTION;`
-		_, err := surrealdb.Query[any](ctx, s.db, query, map[string]any{
-			"path": filePath,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to delete file data atomically: %w", err)
-		}
-		return nil
-	}
-
 	// Query for existing node IDs before starting the transaction
 	// This is needed to delete edges since edges don't have file_path directly
-	query := `SELECT id FROM nodes WHERE file_path = $path`
-	results, err := surrealdb.Query[[]struct{ ID string }](ctx, s.db, query, map[string]any{
+	query := `SELECT id FROM nodes WHERE ` + fileNodes
+	results, err := runQuery[[]struct{ ID string }](ctx, s.db, query, map[string]any{
 		"path": filePath,
 	})
 	if err != nil {
@@ -1159,115 +1428,93 @@ func (s *Storage) UpdateFileAtomic(ctx context.Context, filePath string, nodes [
 			oldNodeIDs = append(oldNodeIDs, node.ID)
 		}
 	}
+	contracts, err := s.declaredContracts(ctx, oldNodeIDs)
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 && len(edges) == 0 {
+		// If no new data, just delete old data atomically in a transaction
+		// This ensures we don't leave orphaned data if one delete fails
+		query := `BEGIN TRANSACTION;
+		           DELETE FROM edges WHERE from_id IN (SELECT id FROM nodes WHERE ` + fileNodes + `) OR to_id IN (SELECT id FROM nodes WHERE ` + fileNodes + `);
+		           DELETE FROM nodes WHERE ` + fileNodes + `;
+		           DELETE FROM file_metadata WHERE file_path = $path;
+		           ` + pruneContracts + `
+		           ` + graphVersionBump + `
+		           COMMIT TRANSACTION;`
+		_, err := runQuery[any](ctx, s.db, query, map[string]any{
+			"path":      filePath,
+			"contracts": contracts,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete file data atomically: %w", err)
+		}
+		return nil
+	}
 
 	// Build the transaction query with deletion, node storage, and edge storage
 	var transactionParts []string
 
-	// Part 1: Delete old edges for this file (using pre-fetched node IDs)
+	// Part 1: Delete old edges for this file (using pre-fetched node IDs).
+	// Edges from other files into nodes that survive the update, such as
+	// imports of this file, are kept.
+	newNodeIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		newNodeIDs = append(newNodeIDs, node.ID)
+	}
 	if len(oldNodeIDs) > 0 {
 		transactionParts = append(transactionParts,
-			`DELETE FROM edges WHERE from_id IN $oldNodeIDs OR to_id IN $oldNodeIDs;`)
+			`DELETE FROM edges WHERE from_id IN $oldNodeIDs OR (to_id IN $oldNodeIDs AND to_id NOT IN $newNodeIDs);`)
 	}
 
 	// Part 2: Delete old nodes for this file
 	transactionParts = append(transactionParts,
-		`DELETE FROM nodes WHERE file_path = $filePath;`)
+		`DELETE FROM nodes WHERE `+fileNodes+`;`)
 
 	// Part 2.5: Note - file_metadata is NOT deleted here to avoid data loss
 	// The caller (indexer) will call UpsertFileMetadata after this function completes
 	// This ensures metadata is never left in an inconsistent state
 
-	// Prepare data outside the transaction to avoid variable scope issues
-	var nodeData []map[string]any
-	var edgeData []map[string]any
-
 	// Part 3: Store new nodes
 	if len(nodes) > 0 {
-		nodeData = make([]map[string]any, len(nodes))
-		for i, node := range nodes {
-			annotations := node.Annotations
-			if annotations == nil {
-				annotations = map[string]string{}
-			}
-
-			nodeData[i] = map[string]any{
-				"id":          node.ID,
-				"name":        node.Name,
-				"node_type":   string(node.NodeType),
-				"language":    node.Language,
-				"file_path":   node.FilePath,
-				"start_line":  node.StartLine,
-				"end_line":    node.EndLine,
-				"content":     node.Content,
-				"doc_comment": node.DocComment,
-				"annotations": annotations,
-				"embedding":   node.Embedding,
-				"complexity":  node.Complexity,
-			}
-		}
-
-		transactionParts = append(transactionParts,
-			`FOR $node IN $nodeData {
-				UPSERT nodes SET
-					id = $node.id,
-					name = $node.name,
-					node_type = $node.node_type,
-					language = $node.language,
-					file_path = $node.file_path,
-					start_line = $node.start_line,
-					end_line = $node.end_line,
-					content = $node.content,
-					doc_comment = $node.doc_comment,
-					annotations = $node.annotations,
-					embedding = $node.embedding,
-					complexity = $node.complexity
-				WHERE id = $node.id;
-			}`)
+		transactionParts = append(transactionParts, upsertNodes)
 	}
 
 	// Part 4: Store new edges
 	if len(edges) > 0 {
-		edgeData = make([]map[string]any, len(edges))
-		for i, edge := range edges {
-			edgeData[i] = map[string]any{
-				"id":        edge.ID,
-				"from_id":   edge.FromID,
-				"to_id":     edge.ToID,
-				"edge_type": string(edge.EdgeType),
-				"weight":    edge.Weight,
-			}
-		}
+		transactionParts = append(transactionParts, upsertEdges)
+	}
 
-		transactionParts = append(transactionParts,
-			`FOR $edge IN $edgeData {
-				UPSERT edges SET
-					id = $edge.id,
-					from_id = $edge.from_id,
-					to_id = $edge.to_id,
-					edge_type = $edge.edge_type,
-					weight = $edge.weight
-				WHERE id = $edge.id;
-			}`)
+	// Part 5: Delete the contracts the file no longer declares
+	if len(contracts) > 0 {
+		transactionParts = append(transactionParts, pruneContracts)
 	}
 
+	transactionParts = append(transactionParts, graphVersionBump)
+
 	// Combine into a single transaction
 	query = "BEGIN TRANSACTION;\n" + strings.Join(transactionParts, "\n") + "\nCOMMIT TRANSACTION;"
 
 	// Build parameters
 	var params map[string]any
 	params = make(map[string]any)
-	params["filePath"] = filePath
+	params["path"] = filePath
 	if len(oldNodeIDs) > 0 {
 		params["oldNodeIDs"] = oldNodeIDs
+		params["newNodeIDs"] = newNodeIDs
 	}
 	if len(nodes) > 0 {
-		params["nodeData"] = nodeData
+		params["nodeData"] = nodeRecords(nodes)
 	}
 	if len(edges) > 0 {
-		params["edgeData"] = edgeData
+		params["edgeData"] = edgeRecords(edges)
+	}
+	if len(contracts) > 0 {
+		params["contracts"] = contracts
 	}
 
-	_, err = surrealdb.Query[any](ctx, s.db, query, params)
+	_, err = runQuery[any](ctx, s.db, query, params)
 	if err != nil {
 		return fmt.Errorf("atomic file update failed: %w", err)
 	}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/heefoo/codeloom/internal/telemetry"
+	"github.com/surrealdb/surrealdb.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementAttrLen bounds the SurrealQL text recorded on query spans.
+const maxStatementAttrLen = 512
+
+// startSpan opens a span for a Storage method.
+func (s *Storage) startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return telemetry.Start(ctx, "graph.Storage."+method,
+		attribute.String("db.system", "surrealdb"),
+		attribute.String("db.namespace", s.namespace+"/"+s.database),
+	)
+}
+
+// runQuery runs a SurrealQL statement inside its own span, recording the
+// statement text and any error.
+func runQuery[T any](ctx context.Context, db *surrealdb.DB, sql string, vars map[string]any) (*[]surrealdb.QueryResult[T], error) {
+	statement := strings.Join(strings.Fields(sql), " ")
+	operation := statement
+	if i := strings.IndexByte(operation, ' '); i > 0 {
+		operation = operation[:i]
+	}
+	if len(statement) > maxStatementAttrLen {
+		statement = statement[:maxStatementAttrLen]
+	}
+
+	ctx, span := telemetry.Start(ctx, "surrealdb.query "+strings.ToUpper(operation),
+		attribute.String("db.system", "surrealdb"),
+		attribute.String("db.operation.name", strings.ToUpper(operation)),
+		attribute.String("db.query.text", statement),
+	)
+	results, err := surrealdb.Query[T](ctx, db, sql, vars)
+	telemetry.End(span, err)
+	return results, err
+}
@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// graphVersionBump increments the graph version. It runs inside every
+// transaction that replaces nodes or edges, so readers in other processes
+// see the new version exactly when they can see the new data.
+const graphVersionBump = `UPSERT graph_meta:version SET value = (value ?? 0) + 1;`
+
+// GraphVersion returns a counter that increases whenever StoreGraphAtomic,
+// UpdateFileAtomic or UpdateFileNodes changes the graph. Results derived
+// from the graph can be cached for as long as the version stays the same.
+func (s *Storage) GraphVersion(ctx context.Context) (int64, error) {
+	ctx, span := s.startSpan(ctx, "GraphVersion")
+	defer span.End()
+
+	query := `SELECT value FROM graph_meta:version`
+	results, err := runQuery[[]struct {
+		Value int64 `json:"value"`
+	}](ctx, s.db, query, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read graph version: %w", err)
+	}
+
+	if results == nil || len(*results) == 0 || len((*results)[0].Result) == 0 {
+		return 0, nil
+	}
+	return (*results)[0].Result[0].Value, nil
+}
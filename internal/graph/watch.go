@@ -0,0 +1,60 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WatchedDir is a directory the file watcher was asked to follow. Directories
+// are persisted so watching can resume after a restart.
+type WatchedDir struct {
+	Path    string `json:"path"`
+	AddedAt int64  `json:"added_at"`
+}
+
+// SetWatchedDirs replaces the set of persisted watched directories. An empty
+// list clears it.
+func (s *Storage) SetWatchedDirs(ctx context.Context, dirs []string) error {
+	ctx, span := s.startSpan(ctx, "SetWatchedDirs")
+	defer span.End()
+
+	now := time.Now().Unix()
+	rows := make([]map[string]any, len(dirs))
+	for i, dir := range dirs {
+		rows[i] = map[string]any{"path": dir, "added_at": now}
+	}
+
+	query := `BEGIN TRANSACTION;
+		DELETE FROM watched_dirs;
+		FOR $row IN $rows { CREATE watched_dirs CONTENT $row; };
+		COMMIT TRANSACTION;`
+	_, err := runQuery[any](ctx, s.db, query, map[string]any{
+		"rows": rows,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save watched directories: %w", err)
+	}
+	return nil
+}
+
+// GetWatchedDirs returns the persisted watched directories
+func (s *Storage) GetWatchedDirs(ctx context.Context) ([]string, error) {
+	ctx, span := s.startSpan(ctx, "GetWatchedDirs")
+	defer span.End()
+
+	query := `SELECT * FROM watched_dirs ORDER BY path`
+	results, err := runQuery[[]WatchedDir](ctx, s.db, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if results == nil || len(*results) == 0 {
+		return nil, nil
+	}
+	dirs := make([]string, 0, len((*results)[0].Result))
+	for _, d := range (*results)[0].Result {
+		dirs = append(dirs, d.Path)
+	}
+	return dirs, nil
+}
@@ -10,6 +10,11 @@ import (
 var (
 	transportOnce   sync.Once
 	sharedTransport *http.Transport
+
+	// overrideTransport, when set, replaces the shared transport for new
+	// clients (for example a cassette recorder in tests)
+	overrideMu        sync.RWMutex
+	overrideTransport http.RoundTripper
 )
 
 type clientEntry struct {
@@ -57,6 +62,51 @@ func getSharedTransport() *http.Transport {
 	return sharedTransport
 }
 
+// Transport returns the transport new clients use: the override set by
+// SetTransport, or the shared pooled transport
+func Transport() http.RoundTripper {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	if overrideTransport != nil {
+		return overrideTransport
+	}
+	return getSharedTransport()
+}
+
+// SetTransport routes clients created afterwards through rt; nil restores
+// the shared transport. Cached clients are dropped so that they are not
+// handed out with the old transport.
+func SetTransport(rt http.RoundTripper) {
+	overrideMu.Lock()
+	overrideTransport = rt
+	overrideMu.Unlock()
+	ClearCache()
+}
+
+// NewClientWithHeaders returns an uncached client on the current transport
+// that sets headers on every request, for SDKs that take an *http.Client
+// but authenticate through their own transport otherwise
+func NewClientWithHeaders(timeout time.Duration, headers map[string]string) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &headerTransport{next: Transport(), headers: headers},
+	}
+}
+
+type headerTransport struct {
+	next    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.next.RoundTrip(req)
+}
+
 // GetSharedClient returns a shared HTTP client with connection pooling
 // suitable for making multiple requests to external APIs.
 // Clients are cached per timeout value to balance connection pooling with timeout flexibility.
@@ -80,7 +130,7 @@ func GetSharedClient(timeout time.Duration) *http.Client {
 	// Create new client
 	client := &http.Client{
 		Timeout:   timeout,
-		Transport: getSharedTransport(),
+		Transport: Transport(),
 	}
 
 	newEntry := &clientEntry{
@@ -2,11 +2,8 @@ package indexer
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,8 +12,12 @@ import (
 
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/heefoo/codeloom/internal/parser"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/telemetry"
 	"github.com/heefoo/codeloom/internal/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Status represents the current indexing status
@@ -27,6 +28,8 @@ type Status struct {
 	FilesIndexed int64     `json:"files_indexed"` // Files successfully parsed
 	FilesSkipped int64     `json:"files_skipped"` // Files skipped (unchanged)
 	FilesDeleted int64     `json:"files_deleted"` // Files removed from index
+	FilesDone    int64     `json:"files_done"`    // Changed files fully stored, metadata included
+	LastFile     string    `json:"last_file"`     // Most recent file counted in FilesDone
 	NodesTotal   int64     `json:"nodes_total"`   // Total code elements (functions, classes, etc.)
 	NodesCreated int64     `json:"nodes_created"` // Code elements stored in DB
 	EdgesCreated int64     `json:"edges_created"`
@@ -47,10 +50,13 @@ type Indexer struct {
 	parser    *parser.Parser
 	storage   *graph.Storage
 	embedding embedding.Provider
+	documents *embedding.DocumentBuilder
+	logger    *slog.Logger
 
 	mu              sync.RWMutex
 	status          Status
 	excludePatterns []string
+	errorThreshold  float64
 }
 
 // Config holds indexer configuration
@@ -58,7 +64,14 @@ type Config struct {
 	Parser          *parser.Parser
 	Storage         *graph.Storage
 	Embedding       embedding.Provider // optional
+	Documents       *embedding.DocumentBuilder // optional; renders the texts embedded per node
 	ExcludePatterns []string
+	Logger          *slog.Logger // optional; defaults to slog.Default()
+
+	// ErrorThreshold is the share of a file in syntax errors above which
+	// an indexed file keeps its nodes instead of taking those of the new
+	// parse; 0 means parser.DefaultErrorThreshold
+	ErrorThreshold float64
 }
 
 // New creates a new Indexer
@@ -66,11 +79,17 @@ func New(cfg Config) *Indexer {
 	if cfg.ExcludePatterns == nil {
 		cfg.ExcludePatterns = DefaultExcludePatterns()
 	}
+	if cfg.Documents == nil {
+		cfg.Documents = embedding.DefaultDocumentBuilder()
+	}
 	return &Indexer{
 		parser:          cfg.Parser,
 		storage:         cfg.Storage,
 		embedding:       cfg.Embedding,
+		documents:       cfg.Documents,
+		logger:          logging.Component(cfg.Logger, "indexer"),
 		excludePatterns: cfg.ExcludePatterns,
+		errorThreshold:  cfg.ErrorThreshold,
 		status: Status{
 			State: "idle",
 		},
@@ -108,13 +127,15 @@ func (idx *Indexer) GetStatus() Status {
 }
 
 // retryEmbedding attempts to generate an embedding with exponential backoff retry
-// It makes up to maxRetries attempts before giving up
+// It makes up to maxRetries attempts before giving up. Transient errors are
+// not retried here: network providers already retry them under the
+// configured retry policy.
 func retryEmbedding(ctx context.Context, embProvider embedding.Provider, nodeID, content string, retryCount, successCount, failureCount *atomic.Int64) ([]float32, error) {
 	const maxRetries = 3
 	const initialBackoff = 500 * time.Millisecond // 500ms
 
-
 	var lastErr error
+	var attempts int
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		// Check context cancellation before each attempt
 		select {
@@ -130,18 +151,22 @@ func retryEmbedding(ctx context.Context, embProvider embedding.Provider, nodeID,
 			return emb, nil
 		}
 		lastErr = err
+		attempts = attempt + 1
 
 		// If this was the last attempt, don't wait
 		if attempt == maxRetries-1 {
 			break
 		}
+		if transient, _ := ratelimit.Classify(err); transient {
+			break
+		}
 
 		// Increment retry counter
 		retryCount.Add(1)
 
 		// Calculate backoff with exponential growth
 		backoff := time.Duration(1<<uint(attempt)) * initialBackoff
-		log.Printf("Retrying embedding for %s (attempt %d/%d, backoff %v): %v", nodeID, attempt+1, maxRetries, backoff, err)
+		utilLogger().Warn("retrying embedding", "node", nodeID, "attempt", attempt+1, "max_attempts", maxRetries, "backoff", backoff, "error", err)
 
 		// Wait for backoff duration or context cancellation
 		select {
@@ -154,7 +179,27 @@ func retryEmbedding(ctx context.Context, embProvider embedding.Provider, nodeID,
 
 	// All retries failed
 	failureCount.Add(1)
-	return nil, fmt.Errorf("embedding failed after %d attempts: %w", maxRetries, lastErr)
+	return nil, fmt.Errorf("embedding failed after %d attempts: %w", attempts, lastErr)
+}
+
+// embedNode embeds the document texts of node, one per chunk of its body, and
+// sets its embedding and chunk embeddings. If a chunk fails after all retries
+// the node keeps the vectors embedded so far.
+func (idx *Indexer) embedNode(ctx context.Context, node *graph.CodeNode, retryCount, successCount, failureCount *atomic.Int64) {
+	if idx.embedding == nil {
+		return
+	}
+	var vecs [][]float32
+	for _, text := range idx.documents.Texts(node) {
+		emb, err := retryEmbedding(ctx, idx.embedding, node.ID, text, retryCount, successCount, failureCount)
+		if err != nil {
+			idx.logger.Warn("embedding failed after all retries", "node", node.ID, "error", err)
+			// Continue without embedding rather than failing entirely
+			break
+		}
+		vecs = append(vecs, emb)
+	}
+	node.Embedding, node.ChunkEmbeddings = embedding.SplitVectors(vecs)
 }
 
 // fileInfo holds information about a file for change detection
@@ -166,35 +211,7 @@ type fileInfo struct {
 
 // computeFileHash computes SHA256 hash of file content
 func computeFileHash(ctx context.Context, filePath string) (string, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	h := sha256.New()
-
-	// Use a buffered reader with context checking
-	buf := make([]byte, 32*1024) // 32KB buffer
-	for {
-		// Check for context cancellation
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		default:
-		}
-
-		n, err := f.Read(buf)
-		if err != nil && err != io.EOF {
-			return "", err
-		}
-		if n == 0 {
-			break
-		}
-		h.Write(buf[:n])
-	}
-
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return util.HashFile(ctx, filePath)
 }
 
 // IndexDirectory indexes all supported files in a directory
@@ -204,6 +221,10 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 	if err != nil {
 		return fmt.Errorf("failed to resolve directory: %w", err)
 	}
+	idx.parser.AddProjectRoot(absDir)
+
+	ctx, span := telemetry.Start(ctx, "indexer.IndexDirectory", attribute.String("codeloom.directory", absDir))
+	defer span.End()
 
 	// Initialize status
 	idx.mu.Lock()
@@ -218,13 +239,16 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 
 	// Ensure migrations are run
 	if err := idx.storage.RunMigrations(ctx); err != nil {
-		log.Printf("Warning: migration error (may be okay): %v", err)
+		idx.logger.Warn("migration error (may be okay)", "error", err)
+	}
+	if err := idx.migrateNodeIDs(ctx); err != nil {
+		idx.logger.Warn("node ID migration failed; affected files are re-indexed when they change", "error", err)
 	}
 
 	// Load existing file metadata
 	existingMeta, err := idx.storage.GetAllFileMetadata(ctx)
 	if err != nil {
-		log.Printf("Warning: could not load file metadata: %v", err)
+		idx.logger.Warn("could not load file metadata", "error", err)
 		existingMeta = nil
 	}
 
@@ -242,6 +266,7 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 	}
 
 	// Collect all source files and detect changes
+	_, scanSpan := telemetry.Start(ctx, "indexer.scan")
 	var changedFiles []string
 	var unchangedFiles []string
 	currentFiles := make(map[string]bool)
@@ -281,7 +306,7 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 			// Mod time changed, compute hash to verify
 			hash, err := computeFileHash(ctx, path)
 			if err != nil {
-				log.Printf("Warning: could not hash file %s: %v", path, err)
+				idx.logger.Warn("could not hash file", "file", path, "error", err)
 				changedFiles = append(changedFiles, path)
 				return nil
 			}
@@ -302,6 +327,8 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 
 	if err != nil {
 		idx.setError(fmt.Sprintf("directory walk error: %v", err))
+		telemetry.End(scanSpan, err)
+		telemetry.End(span, err)
 		return err
 	}
 
@@ -319,17 +346,26 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 	idx.status.FilesDeleted = int64(len(deletedFiles))
 	idx.mu.Unlock()
 
+	scanSpan.SetAttributes(
+		attribute.Int("codeloom.files_total", len(currentFiles)),
+		attribute.Int("codeloom.files_changed", len(changedFiles)),
+		attribute.Int("codeloom.files_deleted", len(deletedFiles)),
+	)
+	scanSpan.End()
+
 	if progressCb != nil {
 		progressCb(idx.GetStatus())
 	}
 
 	// Clean up deleted files using atomic operations
 	// UpdateFileAtomic now deletes nodes, edges, and metadata atomically
+	deleteCtx, deleteSpan := telemetry.Start(ctx, "indexer.delete", attribute.Int("codeloom.files", len(deletedFiles)))
 	for _, path := range deletedFiles {
-		if err := idx.storage.UpdateFileAtomic(ctx, path, []*graph.CodeNode{}, []*graph.CodeEdge{}); err != nil {
-			log.Printf("Warning: failed to delete file %s atomically: %v", path, err)
+		if err := idx.storage.UpdateFileAtomic(deleteCtx, path, []*graph.CodeNode{}, []*graph.CodeEdge{}); err != nil {
+			idx.logger.Warn("failed to delete file atomically", "file", path, "error", err)
 		}
 	}
+	deleteSpan.End()
 
 	// If no changed files, we're done
 	if len(changedFiles) == 0 {
@@ -347,18 +383,29 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 
 	// Parse all changed files first, storing results per-file
 	type fileParseResult struct {
-		nodes []parser.CodeNode
-		edges []parser.CodeEdge
-		err   error
+		nodes        []parser.CodeNode
+		edges        []parser.CodeEdge
+		syntaxErrors []graph.SyntaxError
+		errorRatio   float64
+		stale        bool // keeps its stored nodes
+		err          error
 	}
 	fileResults := make(map[string]fileParseResult)
 	fileNodeCounts := make(map[string]int)
 	fileEdgeCounts := make(map[string]int)
 
+	parseCtx, parseSpan := telemetry.Start(ctx, "indexer.parse", attribute.Int("codeloom.files", len(changedFiles)))
 	for _, filePath := range changedFiles {
-		result, err := idx.parser.ParseFile(ctx, filePath)
+		if err := ctx.Err(); err != nil {
+			idx.setError(fmt.Sprintf("indexing cancelled: %v", err))
+			telemetry.End(parseSpan, err)
+			telemetry.End(span, err)
+			return err
+		}
+
+		result, err := idx.parser.ParseFile(parseCtx, filePath)
 		if err != nil {
-			log.Printf("Warning: failed to parse %s: %v", filePath, err)
+			idx.logger.Warn("failed to parse file", "file", filePath, "error", err)
 			idx.mu.Lock()
 			idx.status.Errors = append(idx.status.Errors, fmt.Sprintf("parse error: %s: %v", filePath, err))
 			idx.mu.Unlock()
@@ -366,11 +413,25 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 			continue
 		}
 
+		if prev := existingFiles[filePath]; keepLastGood(result, prev, idx.errorThreshold) {
+			idx.logger.Warn("too many syntax errors, keeping the last good nodes", "file", filePath, "error_ratio", result.ErrorRatio)
+			fileNodeCounts[filePath] = prev.NodeCount
+			fileEdgeCounts[filePath] = prev.EdgeCount
+			fileResults[filePath] = fileParseResult{
+				syntaxErrors: toSyntaxErrors(result.SyntaxErrors),
+				errorRatio:   result.ErrorRatio,
+				stale:        true,
+			}
+			continue
+		}
+
 		fileNodeCounts[filePath] = len(result.Nodes)
 		fileEdgeCounts[filePath] = len(result.Edges)
 		fileResults[filePath] = fileParseResult{
-			nodes: result.Nodes,
-			edges: result.Edges,
+			nodes:        result.Nodes,
+			edges:        result.Edges,
+			syntaxErrors: toSyntaxErrors(result.SyntaxErrors),
+			errorRatio:   result.ErrorRatio,
 		}
 	}
 
@@ -387,6 +448,9 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 	idx.status.NodesTotal = int64(totalNodes)
 	idx.mu.Unlock()
 
+	parseSpan.SetAttributes(attribute.Int("codeloom.nodes", totalNodes))
+	parseSpan.End()
+
 	if progressCb != nil {
 		progressCb(idx.GetStatus())
 	}
@@ -397,7 +461,17 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 	// Initialize embedding metrics counters
 	var retryCount, successCount, failureCount atomic.Int64
 
+	// Embedding and storing happen per file, so both share one phase span
+	storeCtx, storeSpan := telemetry.Start(ctx, "indexer.embed_and_store")
 	for _, filePath := range changedFiles {
+		// Stop between files so that every file counted in FilesDone is complete
+		if err := ctx.Err(); err != nil {
+			idx.setError(fmt.Sprintf("indexing cancelled: %v", err))
+			telemetry.End(storeSpan, err)
+			telemetry.End(span, err)
+			return err
+		}
+
 		result := fileResults[filePath]
 
 		// Skip files that failed to parse
@@ -408,29 +482,8 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 		// Generate embeddings for this file's nodes
 		nodesWithEmbeddings := make([]*graph.CodeNode, 0, len(result.nodes))
 		for i := range result.nodes {
-			node := &result.nodes[i]
-			var emb []float32
-			if idx.embedding != nil && node.Content != "" {
-				var embErr error
-				emb, embErr = retryEmbedding(ctx, idx.embedding, node.ID, node.Content, &retryCount, &successCount, &failureCount)
-				if embErr != nil {
-					log.Printf("Warning: embedding failed for %s after all retries: %v", node.ID, embErr)
-					// Continue without embedding rather than failing entirely
-				}
-			}
-			graphNode := &graph.CodeNode{
-				ID:          node.ID,
-				Name:        node.Name,
-				NodeType:    graph.NodeType(node.NodeType),
-				Language:    string(node.Language),
-				FilePath:    node.FilePath,
-				StartLine:   node.StartLine,
-				EndLine:     node.EndLine,
-				Content:     node.Content,
-				DocComment:  node.DocComment,
-				Annotations: node.Annotations,
-				Embedding:   emb,
-			}
+			graphNode := toGraphNode(&result.nodes[i])
+			idx.embedNode(storeCtx, graphNode, &retryCount, &successCount, &failureCount)
 			nodesWithEmbeddings = append(nodesWithEmbeddings, graphNode)
 		}
 
@@ -448,25 +501,28 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 			graphEdges = append(graphEdges, graphEdge)
 		}
 
-		// Atomically update file: delete old nodes/edges and store new ones in a single transaction
-		if err := idx.storage.UpdateFileAtomic(ctx, filePath, nodesWithEmbeddings, graphEdges); err != nil {
-			log.Printf("Warning: failed to update file %s atomically: %v", filePath, err)
-			idx.mu.Lock()
-			idx.status.Errors = append(idx.status.Errors, fmt.Sprintf("update error: %s: %v", filePath, err))
-			idx.mu.Unlock()
-			continue
+		// Atomically update file: delete old nodes/edges and store new ones in a single transaction.
+		// Stale files keep theirs and only have their metadata updated.
+		if !result.stale {
+			if err := idx.storage.UpdateFileAtomic(storeCtx, filePath, nodesWithEmbeddings, graphEdges); err != nil {
+				idx.logger.Warn("failed to update file atomically", "file", filePath, "error", err)
+				idx.mu.Lock()
+				idx.status.Errors = append(idx.status.Errors, fmt.Sprintf("update error: %s: %v", filePath, err))
+				idx.mu.Unlock()
+				continue
+			}
 		}
 
 		// Update file metadata
 		info, err := os.Stat(filePath)
 		if err != nil {
-			log.Printf("Warning: failed to stat %s: %v", filePath, err)
+			idx.logger.Warn("failed to stat file", "file", filePath, "error", err)
 			continue
 		}
 
-		hash, err := computeFileHash(ctx, filePath)
+		hash, err := computeFileHash(storeCtx, filePath)
 		if err != nil {
-			log.Printf("Warning: failed to hash %s: %v", filePath, err)
+			idx.logger.Warn("failed to hash file", "file", filePath, "error", err)
 			continue
 		}
 
@@ -482,27 +538,40 @@ func (idx *Indexer) IndexDirectory(ctx context.Context, dir string, progressCb f
 			EdgeCount:   fileEdgeCounts[filePath],
 			FileSize:    info.Size(),
 			Language:    string(lang),
+
+			SyntaxErrors: result.syntaxErrors,
+			ErrorRatio:   result.errorRatio,
+			Stale:        result.stale,
 		}
 
-		if err := idx.storage.UpsertFileMetadata(ctx, meta); err != nil {
-			log.Printf("Warning: failed to save metadata for %s: %v", filePath, err)
+		if err := idx.storage.UpsertFileMetadata(storeCtx, meta); err != nil {
+			idx.logger.Warn("failed to save file metadata", "file", filePath, "error", err)
 		}
 
-		// Update progress
+		// Update progress. The file is now complete: its metadata is saved, so a
+		// later run will skip it, which is what lets interrupted jobs resume.
 		atomic.AddInt32(&nodesProcessed, int32(len(nodesWithEmbeddings)))
+		idx.mu.Lock()
+		idx.status.NodesCreated = int64(atomic.LoadInt32(&nodesProcessed))
+		idx.status.FilesDone++
+		idx.status.LastFile = filePath
+		statusCopy := idx.status
+		if statusCopy.Errors != nil {
+			statusCopy.Errors = make([]string, len(idx.status.Errors))
+			copy(statusCopy.Errors, idx.status.Errors)
+		}
+		idx.mu.Unlock()
 		if progressCb != nil {
-			idx.mu.Lock()
-			idx.status.NodesCreated = int64(atomic.LoadInt32(&nodesProcessed))
-			statusCopy := idx.status
-			if statusCopy.Errors != nil {
-				statusCopy.Errors = make([]string, len(idx.status.Errors))
-				copy(statusCopy.Errors, idx.status.Errors)
-			}
-			idx.mu.Unlock()
 			progressCb(statusCopy)
 		}
 	}
 
+	storeSpan.SetAttributes(
+		attribute.Int64("codeloom.embeddings_ok", successCount.Load()),
+		attribute.Int64("codeloom.embeddings_failed", failureCount.Load()),
+	)
+	storeSpan.End()
+
 	// Update final status
 	var totalEdges int
 	for _, r := range fileResults {
@@ -539,6 +608,21 @@ func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse file: %w", err)
 	}
+	meta := &graph.FileMetadata{
+		FilePath:     absPath,
+		NodeCount:    len(result.Nodes),
+		EdgeCount:    len(result.Edges),
+		SyntaxErrors: toSyntaxErrors(result.SyntaxErrors),
+		ErrorRatio:   result.ErrorRatio,
+	}
+	if result.Degraded(idx.errorThreshold) {
+		prev, err := idx.storage.GetFileMetadata(ctx, absPath)
+		if err == nil && keepLastGood(result, prev, idx.errorThreshold) {
+			idx.logger.Warn("too many syntax errors, keeping the last good nodes", "file", absPath, "error_ratio", result.ErrorRatio)
+			meta.NodeCount, meta.EdgeCount, meta.Stale = prev.NodeCount, prev.EdgeCount, true
+			return idx.saveFileMetadata(ctx, meta)
+		}
+	}
 
 	// Initialize embedding metrics counters
 	var retryCount, successCount, failureCount atomic.Int64
@@ -546,29 +630,8 @@ func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
 	// Generate embeddings for all nodes before storing
 	nodesWithEmbeddings := make([]*graph.CodeNode, 0, len(result.Nodes))
 	for i := range result.Nodes {
-		node := &result.Nodes[i]
-		var emb []float32
-		if idx.embedding != nil && node.Content != "" {
-			var embErr error
-			emb, embErr = retryEmbedding(ctx, idx.embedding, node.ID, node.Content, &retryCount, &successCount, &failureCount)
-			if embErr != nil {
-				log.Printf("Warning: embedding failed for %s after all retries: %v", node.ID, embErr)
-				// Continue without embedding rather than failing entirely
-			}
-		}
-		graphNode := &graph.CodeNode{
-			ID:          node.ID,
-			Name:        node.Name,
-			NodeType:    graph.NodeType(node.NodeType),
-			Language:    string(node.Language),
-			FilePath:    node.FilePath,
-			StartLine:   node.StartLine,
-			EndLine:     node.EndLine,
-			Content:     node.Content,
-			DocComment:  node.DocComment,
-			Annotations: node.Annotations,
-			Embedding:   emb,
-		}
+		graphNode := toGraphNode(&result.Nodes[i])
+		idx.embedNode(ctx, graphNode, &retryCount, &successCount, &failureCount)
 		nodesWithEmbeddings = append(nodesWithEmbeddings, graphNode)
 	}
 
@@ -590,16 +653,38 @@ func (idx *Indexer) IndexFile(ctx context.Context, filePath string) error {
 	if err := idx.storage.UpdateFileAtomic(ctx, absPath, nodesWithEmbeddings, graphEdges); err != nil {
 		return fmt.Errorf("atomic file update failed for %s: %w", filePath, err)
 	}
+	if err := idx.saveFileMetadata(ctx, meta); err != nil {
+		idx.logger.Warn("failed to save file metadata", "file", absPath, "error", err)
+	}
 
 	// Log embedding metrics
 	if idx.embedding != nil {
-		log.Printf("File indexing complete: %d successes, %d retries, %d failures",
-			successCount.Load(), retryCount.Load(), failureCount.Load())
+		idx.logger.Info("file indexing complete", "file", absPath,
+			"embedding_successes", successCount.Load(), "embedding_retries", retryCount.Load(), "embedding_failures", failureCount.Load())
 	}
 
 	return nil
 }
 
+// saveFileMetadata records meta with the current state of its file on disk
+func (idx *Indexer) saveFileMetadata(ctx context.Context, meta *graph.FileMetadata) error {
+	info, err := os.Stat(meta.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	hash, err := computeFileHash(ctx, meta.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	meta.ContentHash = hash
+	meta.ModTime = info.ModTime().Unix()
+	meta.IndexedAt = time.Now().Unix()
+	meta.FileSize = info.Size()
+	meta.Language = string(idx.parser.DetectLanguage(meta.FilePath))
+	return idx.storage.UpsertFileMetadata(ctx, meta)
+}
+
 // DeleteFile removes all nodes and edges associated with a file from the index atomically
 func (idx *Indexer) DeleteFile(ctx context.Context, filePath string) error {
 	absPath, err := filepath.Abs(filePath)
@@ -3,11 +3,16 @@ package indexer
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/embedding"
+	"github.com/heefoo/codeloom/internal/ratelimit"
 )
 
 // TestComputeFileHashContextCancellation tests that computeFileHash respects context cancellation
@@ -134,13 +139,15 @@ func TestComputeFileHashCancellationDuringHash(t *testing.T) {
 	}
 }
 
-// mockEmbeddingProviderWithRetry mocks an embedding provider for testing retry logic
+// mockEmbeddingProviderWithRetry mocks an embedding provider for testing retry logic.
+// Calls that do not fail return vectors from the deterministic hash provider.
 type mockEmbeddingProviderWithRetry struct {
-	callCount       int
-	failUntil       int // Fail for first N calls
-	shouldFail     bool
-	failAfterN     int // Fail after N successful calls
-	embDimension    int
+	callCount    int
+	failUntil    int // Fail for first N calls
+	shouldFail   bool
+	failAfterN   int   // Fail after N successful calls
+	failErr      error // Returned by failing calls when set
+	embDimension int
 }
 
 func (m *mockEmbeddingProviderWithRetry) Embed(ctx context.Context, texts []string) ([][]float32, error) {
@@ -153,25 +160,30 @@ func (m *mockEmbeddingProviderWithRetry) EmbedSingle(ctx context.Context, text s
 	if m.failUntil > 0 && m.callCount <= m.failUntil {
 		return nil, fmt.Errorf("mock embedding failure %d", m.callCount)
 	}
+	if m.shouldFail && m.failErr != nil {
+		return nil, m.failErr
+	}
 	if m.shouldFail {
 		return nil, fmt.Errorf("mock embedding failure on call %d", m.callCount)
 	}
 	if m.failAfterN > 0 && m.callCount > m.failAfterN {
 		return nil, fmt.Errorf("mock embedding failure after %d calls", m.callCount)
 	}
-	// Return mock embedding
-	dim := m.embDimension
-	if dim == 0 {
-		dim = 128
-	}
-	emb := make([]float32, dim)
-	for i := range emb {
-		emb[i] = float32(i)
+	hash, err := embedding.NewHashProvider(config.EmbeddingConfig{Dimension: m.Dimension()})
+	if err != nil {
+		return nil, err
 	}
-	return emb, nil
+	return hash.EmbedSingle(ctx, text)
+}
+
+func (m *mockEmbeddingProviderWithRetry) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return m.EmbedSingle(ctx, text)
 }
 
 func (m *mockEmbeddingProviderWithRetry) Dimension() int {
+	if m.embDimension == 0 {
+		return 128
+	}
 	return m.embDimension
 }
 
@@ -296,6 +308,26 @@ func TestRetryEmbeddingFailureAfterMaxRetries(t *testing.T) {
 	}
 }
 
+// TestRetryEmbeddingSkipsTransientErrors tests that errors the provider's
+// retry policy already retried are not retried again
+func TestRetryEmbeddingSkipsTransientErrors(t *testing.T) {
+	provider := &mockEmbeddingProviderWithRetry{
+		shouldFail: true,
+		failErr:    &ratelimit.StatusError{StatusCode: http.StatusServiceUnavailable, Message: "overloaded"},
+	}
+
+	var retryCount, successCount, failureCount atomic.Int64
+	if _, err := retryEmbedding(context.Background(), provider, "test-node", "test content", &retryCount, &successCount, &failureCount); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if provider.callCount != 1 {
+		t.Errorf("Expected 1 call to provider, got %d", provider.callCount)
+	}
+	if retryCount.Load() != 0 || failureCount.Load() != 1 {
+		t.Errorf("Expected no retries and 1 failure, got %d and %d", retryCount.Load(), failureCount.Load())
+	}
+}
+
 // TestRetryEmbeddingContextCancellation tests that retry respects context cancellation
 func TestRetryEmbeddingContextCancellation(t *testing.T) {
 	// Create a context that will be cancelled quickly
@@ -0,0 +1,111 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/parser"
+)
+
+// migrateNodeIDs brings a graph stored under an older node ID scheme up to
+// parser.IDScheme. Each indexed file is re-parsed and its stored nodes are
+// renamed to their current IDs, keeping embeddings and rewriting edges.
+// Files whose nodes used to collide are marked stale, so the index run that
+// follows re-stores the nodes that were lost to the collisions.
+func (idx *Indexer) migrateNodeIDs(ctx context.Context) error {
+	scheme, err := idx.storage.NodeIDScheme(ctx)
+	if err != nil {
+		return err
+	}
+	if scheme >= parser.IDScheme {
+		return nil
+	}
+
+	metas, err := idx.storage.GetAllFileMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load file metadata: %w", err)
+	}
+
+	renames := make(map[string]string)
+	var stale []graph.FileMetadata
+	for _, meta := range metas {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := os.Stat(meta.FilePath); err != nil {
+			// Deleted files are removed by the index run
+			continue
+		}
+		parsed, err := idx.parser.ParseFile(ctx, meta.FilePath)
+		if err != nil {
+			idx.logger.Warn("could not re-parse file for ID migration", "file", meta.FilePath, "error", err)
+			stale = append(stale, meta)
+			continue
+		}
+		stored, err := idx.storage.GetNodesByFile(ctx, meta.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to load nodes of %s: %w", meta.FilePath, err)
+		}
+
+		fileRenames, complete := matchNodeIDs(stored, parsed.Nodes)
+		for oldID, newID := range fileRenames {
+			renames[oldID] = newID
+		}
+		if !complete {
+			stale = append(stale, meta)
+		}
+	}
+
+	if err := idx.storage.RenameNodes(ctx, renames, parser.IDScheme); err != nil {
+		return err
+	}
+	for _, meta := range stale {
+		meta.ContentHash = ""
+		meta.ModTime = 0
+		if err := idx.storage.UpsertFileMetadata(ctx, &meta); err != nil {
+			idx.logger.Warn("could not mark file for re-indexing", "file", meta.FilePath, "error", err)
+		}
+	}
+
+	idx.logger.Info("migrated node IDs", "from_scheme", scheme, "to_scheme", parser.IDScheme,
+		"renamed", len(renames), "files_reindexed", len(stale))
+	return nil
+}
+
+// matchNodeIDs pairs stored nodes with freshly parsed nodes of the same file
+// by name, type and position, and returns the renames for stored nodes whose
+// ID changed. complete is false when some parsed nodes have no stored
+// counterpart, such as nodes that overwrote each other under the old IDs or
+// the file's module node, or when stored nodes the parser no longer produces,
+// such as per-statement import nodes, are left behind.
+func matchNodeIDs(stored []graph.CodeNode, parsed []parser.CodeNode) (renames map[string]string, complete bool) {
+	type key struct {
+		name      string
+		nodeType  string
+		startLine int
+	}
+	current := make(map[key]string, len(parsed))
+	for _, n := range parsed {
+		if n.FilePath == "" {
+			// Package and external modules are not stored with the file
+			continue
+		}
+		current[key{n.Name, string(n.NodeType), n.StartLine}] = n.ID
+	}
+
+	renames = make(map[string]string)
+	matched := 0
+	for _, n := range stored {
+		newID, ok := current[key{n.Name, string(n.NodeType), n.StartLine}]
+		if !ok {
+			continue
+		}
+		matched++
+		if newID != n.ID {
+			renames[n.ID] = newID
+		}
+	}
+	return renames, matched == len(current) && matched == len(stored)
+}
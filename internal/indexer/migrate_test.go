@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
+	"github.com/heefoo/codeloom/internal/parser"
+)
+
+func TestMatchNodeIDs(t *testing.T) {
+	code := `class Button:
+    def render(self):
+        return "button"
+
+class Link:
+    def render(self):
+        return "link"
+`
+	parsed, err := parser.NewParser().ParseContent(context.Background(), "ui.py", parser.LangPython, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Under the old scheme both methods were ui.py::render and the second
+	// one overwrote the first
+	stored := []graph.CodeNode{
+		{ID: "ui.py", Name: "ui.py", NodeType: graph.NodeTypeModule, StartLine: 1},
+		{ID: "ui.py::Button", Name: "Button", NodeType: graph.NodeTypeClass, StartLine: 1},
+		{ID: "ui.py::Link", Name: "Link", NodeType: graph.NodeTypeClass, StartLine: 5},
+		{ID: "ui.py::render", Name: "render", NodeType: graph.NodeTypeFunction, StartLine: 6},
+	}
+	renames, complete := matchNodeIDs(stored, parsed.Nodes)
+	if complete {
+		t.Error("expected the file to need re-indexing for the lost method")
+	}
+	if len(renames) != 1 || renames["ui.py::render"] != "ui.py::Link.render" {
+		t.Errorf("unexpected renames %v", renames)
+	}
+
+	stored = append(stored, graph.CodeNode{ID: "ui.py::Button.render", Name: "render", NodeType: graph.NodeTypeFunction, StartLine: 2})
+	if _, complete := matchNodeIDs(stored, parsed.Nodes); !complete {
+		t.Error("expected every parsed node to be matched")
+	}
+
+	// Per-statement import nodes of older schemes are no longer produced
+	stored = append(stored, graph.CodeNode{ID: "ui.py::import_0", Name: "import", NodeType: "import", StartLine: 1})
+	if _, complete := matchNodeIDs(stored, parsed.Nodes); complete {
+		t.Error("expected a file with leftover nodes to need re-indexing")
+	}
+}
+
+func TestMigrateNodeIDs(t *testing.T) {
+	storage := graphtest.Storage(t)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "ui.py")
+	code := "class Button:\n    pass\n\nclass Link:\n    def render(self):\n        return \"link\"\n"
+	if err := os.WriteFile(path, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The graph as the first ID scheme stored it, with the method keyed by
+	// its bare name
+	oldID := path + "::render"
+	embedding := []float32{0.1, 0.2, 0.3}
+	nodes := []*graph.CodeNode{
+		{ID: path, Name: "ui.py", NodeType: graph.NodeTypeModule, Language: "python", FilePath: path, StartLine: 1, EndLine: 6},
+		{ID: path + "::Button", Name: "Button", NodeType: graph.NodeTypeClass, Language: "python", FilePath: path, StartLine: 1, EndLine: 2},
+		{ID: path + "::Link", Name: "Link", NodeType: graph.NodeTypeClass, Language: "python", FilePath: path, StartLine: 4, EndLine: 6},
+		{ID: oldID, Name: "render", NodeType: graph.NodeTypeFunction, Language: "python", FilePath: path, StartLine: 5, EndLine: 6, Embedding: embedding},
+	}
+	edges := []*graph.CodeEdge{
+		{ID: graph.FormatEdgeID(path+"::Link", oldID, graph.EdgeTypeContains), FromID: path + "::Link", ToID: oldID, EdgeType: graph.EdgeTypeContains, Weight: 1},
+	}
+	if err := storage.UpdateFileAtomic(ctx, path, nodes, edges); err != nil {
+		t.Fatalf("failed to store file: %v", err)
+	}
+	if err := storage.UpsertFileMetadata(ctx, &graph.FileMetadata{FilePath: path, ContentHash: "h"}); err != nil {
+		t.Fatalf("failed to store metadata: %v", err)
+	}
+
+	idx := New(Config{Parser: parser.NewParser(), Storage: storage})
+	if err := idx.migrateNodeIDs(ctx); err != nil {
+		t.Fatalf("migration failed: %v", err)
+	}
+
+	if scheme, err := storage.NodeIDScheme(ctx); err != nil || scheme != parser.IDScheme {
+		t.Errorf("expected scheme %d to be recorded, got %d (%v)", parser.IDScheme, scheme, err)
+	}
+	migrated, err := storage.GetNodesByFile(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]graph.CodeNode)
+	for _, n := range migrated {
+		byID[n.ID] = n
+	}
+	if _, ok := byID[oldID]; ok {
+		t.Errorf("expected %s to be gone, got %v", oldID, migrated)
+	}
+	newID := path + "::Link.render"
+	if render, ok := byID[newID]; !ok || len(render.Embedding) != len(embedding) {
+		t.Errorf("expected %s with its embedding, got %v", newID, migrated)
+	}
+
+	in, err := storage.GetIncomingEdges(ctx, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in) != 1 || in[0].FromID != path+"::Link" || in[0].ID != graph.FormatEdgeID(path+"::Link", newID, graph.EdgeTypeContains) {
+		t.Errorf("expected Link to contain %s, got %+v", newID, in)
+	}
+	if stale, err := storage.GetIncomingEdges(ctx, oldID); err != nil || len(stale) != 0 {
+		t.Errorf("expected no edges left into %s, got %+v (%v)", oldID, stale, err)
+	}
+}
@@ -3,12 +3,13 @@ package indexer
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/heefoo/codeloom/internal/parser"
 )
 
@@ -16,25 +17,15 @@ import (
 // Set to 4 to balance throughput vs overwhelming the embedding service
 const embeddingWorkerCount = 4
 
-// StoreNodeWithEmbedding generates an embedding (if available) and stores a node in the graph
-func StoreNodeWithEmbedding(
-	ctx context.Context,
-	node *parser.CodeNode,
-	storage *graph.Storage,
-	embProvider embedding.Provider,
-) error {
-	var emb []float32
-	var err error
-
-	if embProvider != nil && node.Content != "" {
-		emb, err = embProvider.EmbedSingle(ctx, node.Content)
-		if err != nil {
-			log.Printf("Warning: embedding failed for %s: %v", node.ID, err)
-			emb = nil
-		}
-	}
+// utilLogger is used by package-level helpers (no Indexer receiver), which
+// log through the default logger.
+func utilLogger() *slog.Logger {
+	return logging.Component(nil, "indexer")
+}
 
-	graphNode := &graph.CodeNode{
+// toGraphNode converts a parsed node to its stored form, without embeddings
+func toGraphNode(node *parser.CodeNode) *graph.CodeNode {
+	return &graph.CodeNode{
 		ID:          node.ID,
 		Name:        node.Name,
 		NodeType:    graph.NodeType(node.NodeType),
@@ -45,7 +36,29 @@ func StoreNodeWithEmbedding(
 		Content:     node.Content,
 		DocComment:  node.DocComment,
 		Annotations: node.Annotations,
-		Embedding:   emb,
+	}
+}
+
+// StoreNodeWithEmbedding generates an embedding (if available) and stores a node in the graph
+func StoreNodeWithEmbedding(
+	ctx context.Context,
+	node *parser.CodeNode,
+	storage *graph.Storage,
+	embProvider embedding.Provider,
+) error {
+	graphNode := toGraphNode(node)
+
+	if embProvider != nil {
+		var vecs [][]float32
+		for _, text := range embedding.DefaultDocumentBuilder().Texts(graphNode) {
+			emb, err := embProvider.EmbedSingle(ctx, text)
+			if err != nil {
+				utilLogger().Warn("embedding failed", "node", node.ID, "error", err)
+				break
+			}
+			vecs = append(vecs, emb)
+		}
+		graphNode.Embedding, graphNode.ChunkEmbeddings = embedding.SplitVectors(vecs)
 	}
 
 	if err := storage.UpsertNode(ctx, graphNode); err != nil {
@@ -58,8 +71,8 @@ func StoreNodeWithEmbedding(
 // embeddingBatch represents a batch of nodes to embed
 type embeddingBatch struct {
 	batchIndex  int // Order in original sequence
-	nodes       []parser.CodeNode
-	texts       []string // Texts to embed
+	nodes       []*graph.CodeNode
+	texts       []string // Texts to embed, one per chunk
 	textIndices []int    // Maps text index back to node index
 }
 
@@ -90,6 +103,8 @@ func StoreNodesBatch(
 
 	const batchSize = 100
 
+	documents := embedding.DefaultDocumentBuilder()
+
 	// Prepare all batches upfront
 	var batches []embeddingBatch
 	for batchStart := 0; batchStart < len(nodes); batchStart += batchSize {
@@ -97,17 +112,19 @@ func StoreNodesBatch(
 		if batchEnd > len(nodes) {
 			batchEnd = len(nodes)
 		}
-		batch := nodes[batchStart:batchEnd]
 
-		// Collect texts and track which nodes have content
+		// Collect texts and track which node each one belongs to
+		batch := make([]*graph.CodeNode, 0, batchEnd-batchStart)
 		var texts []string
 		var textIndices []int
 
-		for i, node := range batch {
-			if node.Content != "" {
-				texts = append(texts, node.Content)
-				textIndices = append(textIndices, i)
+		for i := batchStart; i < batchEnd; i++ {
+			graphNode := toGraphNode(&nodes[i])
+			for _, text := range documents.Texts(graphNode) {
+				texts = append(texts, text)
+				textIndices = append(textIndices, len(batch))
 			}
+			batch = append(batch, graphNode)
 		}
 
 		batches = append(batches, embeddingBatch{
@@ -154,7 +171,7 @@ func StoreNodesBatch(
 				if len(batch.texts) > 0 {
 					embeddings, err = embProvider.Embed(ctx, batch.texts)
 					if err != nil {
-						log.Printf("Warning: batch %d embedding failed: %v", batch.batchIndex, err)
+						utilLogger().Warn("batch embedding failed", "batch", batch.batchIndex, "error", err)
 					}
 				}
 
@@ -185,7 +202,7 @@ func StoreNodesBatch(
 	for result := range resultCh {
 		// Check for embedding errors - skip batches that failed
 		if result.err != nil {
-			log.Printf("Warning: skipping batch %d due to embedding error: %v", result.batchIndex, result.err)
+			utilLogger().Warn("skipping batch due to embedding error", "batch", result.batchIndex, "error", result.err)
 			continue
 		}
 		embeddingResults[result.batchIndex] = result.embeddings
@@ -206,35 +223,19 @@ func StoreNodesBatch(
 		embeddings := embeddingResults[i]
 		textIndices := textIndicesResults[i]
 
-		// Build batch of graph nodes
-		graphNodes := make([]*graph.CodeNode, len(batch.nodes))
-		for j, node := range batch.nodes {
-			var emb []float32
-
-			// Find embedding for this node if it had content
-			if embeddings != nil {
-				for k, textIdx := range textIndices {
-					if textIdx == j && k < len(embeddings) {
-						emb = embeddings[k]
-						break
-					}
+		// Attach each node's chunk vectors
+		if embeddings != nil {
+			vecs := make([][][]float32, len(batch.nodes))
+			for k, nodeIdx := range textIndices {
+				if k < len(embeddings) {
+					vecs[nodeIdx] = append(vecs[nodeIdx], embeddings[k])
 				}
 			}
-
-			graphNodes[j] = &graph.CodeNode{
-				ID:          node.ID,
-				Name:        node.Name,
-				NodeType:    graph.NodeType(node.NodeType),
-				Language:    string(node.Language),
-				FilePath:    node.FilePath,
-				StartLine:   node.StartLine,
-				EndLine:     node.EndLine,
-				Content:     node.Content,
-				DocComment:  node.DocComment,
-				Annotations: node.Annotations,
-				Embedding:   emb,
+			for j, node := range batch.nodes {
+				node.Embedding, node.ChunkEmbeddings = embedding.SplitVectors(vecs[j])
 			}
 		}
+		graphNodes := batch.nodes
 
 		// Batch insert all nodes at once
 		if err := storage.UpsertNodesBatch(ctx, graphNodes); err != nil {
@@ -270,19 +271,8 @@ func storeNodesWithoutEmbeddings(
 
 		// Build batch of graph nodes
 		graphNodes := make([]*graph.CodeNode, len(batch))
-		for i, node := range batch {
-			graphNodes[i] = &graph.CodeNode{
-				ID:          node.ID,
-				Name:        node.Name,
-				NodeType:    graph.NodeType(node.NodeType),
-				Language:    string(node.Language),
-				FilePath:    node.FilePath,
-				StartLine:   node.StartLine,
-				EndLine:     node.EndLine,
-				Content:     node.Content,
-				DocComment:  node.DocComment,
-				Annotations: node.Annotations,
-			}
+		for i := range batch {
+			graphNodes[i] = toGraphNode(&batch[i])
 		}
 
 		// Batch insert
@@ -313,7 +303,7 @@ func StoreEdgesBatch(
 	validEdges := make([]parser.CodeEdge, 0, len(edges))
 	for _, edge := range edges {
 		if edge.FromID == "" || edge.ToID == "" {
-			log.Printf("Warning: skipping edge with empty IDs: %+v", edge)
+			utilLogger().Warn("skipping edge with empty IDs", "from", edge.FromID, "to", edge.ToID, "type", edge.EdgeType)
 			continue
 		}
 		validEdges = append(validEdges, edge)
@@ -347,3 +337,19 @@ func StoreEdgesBatch(
 
 	return nil
 }
+
+// keepLastGood reports whether a file indexed before keeps its stored nodes
+// because its new parse has more syntax errors than threshold allows. Files
+// without nodes take what could be read.
+func keepLastGood(result *parser.ParseResult, prev *graph.FileMetadata, threshold float64) bool {
+	return result.Degraded(threshold) && prev != nil && prev.NodeCount > 0
+}
+
+// toSyntaxErrors converts the syntax errors of a parse to their stored form
+func toSyntaxErrors(errs []parser.SyntaxError) []graph.SyntaxError {
+	out := make([]graph.SyntaxError, len(errs))
+	for i, e := range errs {
+		out[i] = graph.SyntaxError(e)
+	}
+	return out
+}
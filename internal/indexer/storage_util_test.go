@@ -2,9 +2,15 @@ package indexer
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/embedding"
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
 	"github.com/heefoo/codeloom/internal/parser"
 )
 
@@ -18,9 +24,7 @@ import (
 // in the worker pool and before storing each batch. This allows graceful shutdown.
 func TestStoreNodesBatchContextCancellation(t *testing.T) {
 	// Create a mock embedding provider that simulates slow embedding
-	slowEmbedding := &mockEmbeddingProvider{
-		delay: 50 * time.Millisecond,
-	}
+	slowEmbedding := newMockEmbeddingProvider(50 * time.Millisecond)
 
 	// Create temporary storage (in-memory would be ideal, but we'll use nil storage)
 	// We're testing cancellation behavior, not actual storage
@@ -82,38 +86,109 @@ func TestStoreNodesBatchContextCancellation(t *testing.T) {
 	}
 }
 
-// mockEmbeddingProvider is a test helper that simulates embedding with configurable delay
+// mockEmbeddingProvider is a test helper that simulates embedding with
+// configurable delay; vectors come from the deterministic hash provider
 type mockEmbeddingProvider struct {
+	*embedding.HashProvider
 	delay time.Duration
 }
 
+func newMockEmbeddingProvider(delay time.Duration) *mockEmbeddingProvider {
+	hash, _ := embedding.NewHashProvider(config.EmbeddingConfig{Dimension: 3})
+	return &mockEmbeddingProvider{HashProvider: hash, delay: delay}
+}
+
 func (m *mockEmbeddingProvider) EmbedSingle(ctx context.Context, text string) ([]float32, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	case <-time.After(m.delay):
-		// Return a simple embedding
-		return []float32{0.1, 0.2, 0.3}, nil
+		return m.HashProvider.EmbedSingle(ctx, text)
 	}
 }
 
 func (m *mockEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	embeddings := make([][]float32, len(texts))
-	for i := range texts {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(m.delay):
-			embeddings[i] = []float32{0.1, 0.2, 0.3}
+	for i, text := range texts {
+		emb, err := m.EmbedSingle(ctx, text)
+		if err != nil {
+			return nil, err
 		}
+		embeddings[i] = emb
 	}
 	return embeddings, nil
 }
 
-func (m *mockEmbeddingProvider) Name() string {
-	return "mock"
+func (m *mockEmbeddingProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return m.EmbedSingle(ctx, text)
 }
 
-func (m *mockEmbeddingProvider) Dimension() int {
-	return 3
+func TestKeepLastGood(t *testing.T) {
+	broken := &parser.ParseResult{ErrorRatio: 0.5}
+	clean := &parser.ParseResult{}
+	indexed := &graph.FileMetadata{NodeCount: 3}
+
+	for _, tc := range []struct {
+		name      string
+		result    *parser.ParseResult
+		prev      *graph.FileMetadata
+		threshold float64
+		want      bool
+	}{
+		{"broken file indexed before", broken, indexed, 0, true},
+		{"clean parse", clean, indexed, 0, false},
+		{"new file", broken, nil, 0, false},
+		{"file without nodes", broken, &graph.FileMetadata{}, 0, false},
+		{"under a raised threshold", broken, indexed, 0.6, false},
+	} {
+		if got := keepLastGood(tc.result, tc.prev, tc.threshold); got != tc.want {
+			t.Errorf("%s: keepLastGood = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIndexFileKeepsLastGood(t *testing.T) {
+	storage := graphtest.Storage(t)
+	ctx := context.Background()
+	idx := New(Config{Parser: parser.NewParser(), Storage: storage})
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	good := "package main\n\nfunc main() {}\n\nfunc helper() {}\n"
+	if err := os.WriteFile(path, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexFile(ctx, path); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	stored, err := storage.GetNodesByFile(ctx, path)
+	if err != nil || len(stored) == 0 {
+		t.Fatalf("expected nodes to be stored, got %d (%v)", len(stored), err)
+	}
+
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main( {\n\t}}} ))) {{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexFile(ctx, path); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	meta, err := storage.GetFileMetadata(ctx, path)
+	if err != nil || meta == nil {
+		t.Fatalf("expected file metadata, got %v", err)
+	}
+	if !meta.Stale || len(meta.SyntaxErrors) == 0 || meta.ErrorRatio == 0 || meta.NodeCount != len(stored) {
+		t.Errorf("expected stale metadata with syntax errors and the kept node count, got %+v", meta)
+	}
+	if kept, err := storage.GetNodesByFile(ctx, path); err != nil || len(kept) != len(stored) {
+		t.Errorf("expected the %d last good nodes to be kept, got %d (%v)", len(stored), len(kept), err)
+	}
+
+	if err := os.WriteFile(path, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexFile(ctx, path); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	if meta, err := storage.GetFileMetadata(ctx, path); err != nil || meta == nil || meta.Stale || len(meta.SyntaxErrors) != 0 {
+		t.Errorf("expected a clean parse to clear the stale state, got %+v (%v)", meta, err)
+	}
 }
@@ -0,0 +1,364 @@
+// Package jobs runs indexing as background jobs that can be listed,
+// cancelled and resumed after a restart.
+//
+// Jobs run one at a time in submission order. Each job's progress is
+// checkpointed to the Store after every completed file; because the indexer
+// skips files whose stored metadata is current, re-running an unfinished job
+// continues from the last completed file rather than starting over.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/indexer"
+	"github.com/heefoo/codeloom/internal/logging"
+)
+
+// Job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// checkpointTimeout bounds each write of job state to the store.
+const checkpointTimeout = 10 * time.Second
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// Store persists job checkpoints. *graph.Storage implements it.
+type Store interface {
+	UpsertIndexJob(ctx context.Context, job *graph.IndexJob) error
+	ListIndexJobs(ctx context.Context) ([]graph.IndexJob, error)
+}
+
+// RunFunc indexes job.Directory, reporting progress through progress.
+type RunFunc func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error
+
+// Config holds manager configuration
+type Config struct {
+	Store  Store // optional; without it jobs are not persisted
+	Run    RunFunc
+	Logger *slog.Logger // optional; defaults to slog.Default()
+}
+
+// Request describes a job to submit
+type Request struct {
+	Name            string
+	Directory       string
+	ExcludePatterns []string
+	SkipEmbeddings  bool
+}
+
+// Manager owns the job queue and the single worker that runs it
+type Manager struct {
+	store  Store
+	run    RunFunc
+	logger *slog.Logger
+
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*graph.IndexJob
+	pending []string
+	cancels map[string]context.CancelFunc
+	closed  bool
+}
+
+// NewManager creates a Manager and starts its worker
+func NewManager(cfg Config) *Manager {
+	ctx, stop := context.WithCancel(context.Background())
+	m := &Manager{
+		store:   cfg.Store,
+		run:     cfg.Run,
+		logger:  logging.Component(cfg.Logger, "jobs"),
+		ctx:     ctx,
+		stop:    stop,
+		jobs:    make(map[string]*graph.IndexJob),
+		cancels: make(map[string]context.CancelFunc),
+	}
+	m.cond = sync.NewCond(&m.mu)
+
+	m.wg.Add(1)
+	go m.worker()
+	return m
+}
+
+// Submit queues a new job and returns it immediately
+func (m *Manager) Submit(req Request) (graph.IndexJob, error) {
+	absDir, err := filepath.Abs(req.Directory)
+	if err != nil {
+		return graph.IndexJob{}, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+	name := req.Name
+	if name == "" {
+		name = filepath.Base(absDir)
+	}
+
+	now := time.Now().Unix()
+	job := &graph.IndexJob{
+		JobID:           newJobID(),
+		Name:            name,
+		Directory:       absDir,
+		State:           StateQueued,
+		ExcludePatterns: req.ExcludePatterns,
+		SkipEmbeddings:  req.SkipEmbeddings,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return graph.IndexJob{}, errors.New("job manager is closed")
+	}
+	m.jobs[job.JobID] = job
+	m.pending = append(m.pending, job.JobID)
+	snapshot := *job
+	m.cond.Signal()
+	m.mu.Unlock()
+
+	m.checkpoint(snapshot)
+	return snapshot, nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (graph.IndexJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return graph.IndexJob{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// List returns snapshots of all known jobs, newest first
+func (m *Manager) List() []graph.IndexJob {
+	m.mu.Lock()
+	out := make([]graph.IndexJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		out = append(out, *job)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].JobID > out[j].JobID
+	})
+	return out
+}
+
+// Cancel stops a queued or running job. Finished jobs cannot be cancelled.
+func (m *Manager) Cancel(id string) (graph.IndexJob, error) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return graph.IndexJob{}, ErrNotFound
+	}
+
+	switch job.State {
+	case StateQueued:
+		// The worker skips jobs that are no longer queued
+		m.finishLocked(job, StateCancelled, "")
+		snapshot := *job
+		m.mu.Unlock()
+		m.checkpoint(snapshot)
+		return snapshot, nil
+	case StateRunning:
+		if cancel, ok := m.cancels[id]; ok {
+			cancel()
+		}
+		snapshot := *job
+		m.mu.Unlock()
+		return snapshot, nil
+	default:
+		snapshot := *job
+		m.mu.Unlock()
+		return snapshot, fmt.Errorf("job %s already %s", id, snapshot.State)
+	}
+}
+
+// Resume loads persisted jobs and re-queues those that were queued or running
+// when the previous process stopped. It returns the number re-queued.
+func (m *Manager) Resume(ctx context.Context) (int, error) {
+	if m.store == nil {
+		return 0, nil
+	}
+	stored, err := m.store.ListIndexJobs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load jobs: %w", err)
+	}
+
+	// Oldest first so resumed jobs keep their original order
+	sort.Slice(stored, func(i, j int) bool { return stored[i].CreatedAt < stored[j].CreatedAt })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resumed := 0
+	for i := range stored {
+		job := stored[i]
+		if _, exists := m.jobs[job.JobID]; exists {
+			continue
+		}
+		m.jobs[job.JobID] = &job
+		if job.State == StateQueued || job.State == StateRunning {
+			job.State = StateQueued
+			m.pending = append(m.pending, job.JobID)
+			resumed++
+			m.logger.Info("resuming index job", "job_id", job.JobID, "directory", job.Directory,
+				"files_processed", job.FilesProcessed, "last_file", job.LastFile)
+		}
+	}
+	if resumed > 0 {
+		m.cond.Signal()
+	}
+	return resumed, nil
+}
+
+// Close stops the worker. A job interrupted by Close keeps its persisted
+// running state, so the next Resume picks it up again.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	m.stop()
+	m.wg.Wait()
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		m.mu.Lock()
+		for len(m.pending) == 0 && !m.closed {
+			m.cond.Wait()
+		}
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		id := m.pending[0]
+		m.pending = m.pending[1:]
+		m.mu.Unlock()
+
+		m.runJob(id)
+	}
+}
+
+func (m *Manager) runJob(id string) {
+	m.mu.Lock()
+	job := m.jobs[id]
+	if job == nil || job.State != StateQueued {
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	m.cancels[id] = cancel
+
+	now := time.Now().Unix()
+	job.State = StateRunning
+	job.Error = ""
+	if job.StartedAt == 0 {
+		job.StartedAt = now
+	}
+	job.UpdatedAt = now
+	snapshot := *job
+	m.mu.Unlock()
+
+	m.checkpoint(snapshot)
+	m.logger.Info("index job started", "job_id", id, "directory", snapshot.Directory)
+
+	err := m.run(ctx, snapshot, func(st indexer.Status) {
+		m.mu.Lock()
+		job.FilesTotal = st.FilesTotal
+		job.FilesSkipped = st.FilesSkipped
+		job.FilesProcessed = st.FilesDone
+		job.NodesCreated = st.NodesCreated
+		if st.LastFile != "" {
+			job.LastFile = st.LastFile
+		}
+		job.UpdatedAt = time.Now().Unix()
+		snapshot := *job
+		m.mu.Unlock()
+		m.checkpoint(snapshot)
+	})
+
+	m.mu.Lock()
+	delete(m.cancels, id)
+	if m.ctx.Err() != nil {
+		// Shutting down: leave the persisted state as running for Resume
+		m.mu.Unlock()
+		m.logger.Info("index job interrupted by shutdown", "job_id", id)
+		return
+	}
+	switch {
+	case err == nil:
+		m.finishLocked(job, StateCompleted, "")
+	case errors.Is(err, context.Canceled):
+		m.finishLocked(job, StateCancelled, "")
+	default:
+		m.finishLocked(job, StateFailed, err.Error())
+	}
+	snapshot = *job
+	m.mu.Unlock()
+
+	m.checkpoint(snapshot)
+	m.logger.Info("index job finished", "job_id", id, "state", snapshot.State,
+		"files_processed", snapshot.FilesProcessed, "error", snapshot.Error)
+}
+
+func (m *Manager) finishLocked(job *graph.IndexJob, state, errMsg string) {
+	now := time.Now().Unix()
+	job.State = state
+	job.Error = errMsg
+	job.UpdatedAt = now
+	job.CompletedAt = now
+}
+
+// checkpoint persists a job snapshot. Failures are logged, not fatal: the
+// in-memory state stays authoritative for this process.
+func (m *Manager) checkpoint(job graph.IndexJob) {
+	if m.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkpointTimeout)
+	defer cancel()
+	if err := m.store.UpsertIndexJob(ctx, &job); err != nil {
+		m.logger.Warn("failed to checkpoint index job", "job_id", job.JobID, "error", err)
+	}
+}
+
+func newJobID() string {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("job_%d", time.Now().UnixNano())
+	}
+	return "job_" + hex.EncodeToString(b[:])
+}
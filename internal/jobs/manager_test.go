@@ -0,0 +1,260 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/indexer"
+)
+
+// memStore is an in-memory Store
+type memStore struct {
+	mu   sync.Mutex
+	jobs map[string]graph.IndexJob
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: make(map[string]graph.IndexJob)}
+}
+
+func (s *memStore) UpsertIndexJob(ctx context.Context, job *graph.IndexJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.JobID] = *job
+	return nil
+}
+
+func (s *memStore) ListIndexJobs(ctx context.Context) ([]graph.IndexJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]graph.IndexJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		out = append(out, job)
+	}
+	return out, nil
+}
+
+func (s *memStore) get(id string) graph.IndexJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+func waitForState(t *testing.T, m *Manager, id, state string) graph.IndexJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", id, err)
+		}
+		if job.State == state {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	job, _ := m.Get(id)
+	t.Fatalf("job %s did not reach state %s (last state %s)", id, state, job.State)
+	return job
+}
+
+// blockingRun reports one file of progress and then waits for cancellation
+func blockingRun(started chan<- string) RunFunc {
+	return func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+		progress(indexer.Status{FilesTotal: 3, FilesDone: 1, LastFile: "a.go"})
+		if started != nil {
+			started <- job.JobID
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
+func TestSubmitRunsToCompletion(t *testing.T) {
+	store := newMemStore()
+	m := NewManager(Config{
+		Store: store,
+		Run: func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+			progress(indexer.Status{FilesTotal: 2, FilesSkipped: 1, FilesDone: 1, NodesCreated: 7, LastFile: "main.go"})
+			return nil
+		},
+	})
+	defer m.Close()
+
+	job, err := m.Submit(Request{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job.State != StateQueued {
+		t.Errorf("expected queued state on submit, got %s", job.State)
+	}
+	if job.Name == "" {
+		t.Error("expected job name to default to the directory name")
+	}
+
+	done := waitForState(t, m, job.JobID, StateCompleted)
+	if done.FilesProcessed != 1 || done.FilesSkipped != 1 || done.NodesCreated != 7 {
+		t.Errorf("unexpected progress: %+v", done)
+	}
+	if done.LastFile != "main.go" {
+		t.Errorf("expected last file main.go, got %q", done.LastFile)
+	}
+	if done.CompletedAt == 0 || done.StartedAt == 0 {
+		t.Error("expected start and completion times to be set")
+	}
+
+	if stored := store.get(job.JobID); stored.State != StateCompleted {
+		t.Errorf("expected persisted state completed, got %s", stored.State)
+	}
+}
+
+func TestFailedJobRecordsError(t *testing.T) {
+	m := NewManager(Config{
+		Run: func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+			return errors.New("boom")
+		},
+	})
+	defer m.Close()
+
+	job, err := m.Submit(Request{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	failed := waitForState(t, m, job.JobID, StateFailed)
+	if failed.Error != "boom" {
+		t.Errorf("expected error boom, got %q", failed.Error)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	started := make(chan string, 1)
+	m := NewManager(Config{Run: blockingRun(started)})
+	defer m.Close()
+
+	job, err := m.Submit(Request{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+
+	if _, err := m.Cancel(job.JobID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	waitForState(t, m, job.JobID, StateCancelled)
+
+	if _, err := m.Cancel(job.JobID); err == nil {
+		t.Error("expected error cancelling a finished job")
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	started := make(chan string, 2)
+	m := NewManager(Config{Run: blockingRun(started)})
+	defer m.Close()
+
+	first, _ := m.Submit(Request{Directory: t.TempDir()})
+	second, _ := m.Submit(Request{Directory: t.TempDir()})
+	<-started
+
+	cancelled, err := m.Cancel(second.JobID)
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if cancelled.State != StateCancelled {
+		t.Errorf("expected queued job to be cancelled immediately, got %s", cancelled.State)
+	}
+
+	// The first job is still running and the cancelled one never starts
+	if _, err := m.Cancel(first.JobID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	waitForState(t, m, first.JobID, StateCancelled)
+	select {
+	case id := <-started:
+		t.Errorf("cancelled job %s should not have run", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCloseLeavesJobResumable(t *testing.T) {
+	store := newMemStore()
+	started := make(chan string, 1)
+	m := NewManager(Config{Store: store, Run: blockingRun(started)})
+
+	job, err := m.Submit(Request{Directory: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+	m.Close()
+
+	stored := store.get(job.JobID)
+	if stored.State != StateRunning {
+		t.Fatalf("expected persisted state running after shutdown, got %s", stored.State)
+	}
+	if stored.LastFile != "a.go" || stored.FilesProcessed != 1 {
+		t.Errorf("expected checkpointed progress, got %+v", stored)
+	}
+
+	if _, err := m.Submit(Request{Directory: t.TempDir()}); err == nil {
+		t.Error("expected Submit to fail after Close")
+	}
+
+	// A new manager resumes the interrupted job
+	var resumedFrom string
+	m2 := NewManager(Config{
+		Store: store,
+		Run: func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+			resumedFrom = job.LastFile
+			return nil
+		},
+	})
+	defer m2.Close()
+
+	n, err := m2.Resume(context.Background())
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 resumed job, got %d", n)
+	}
+	waitForState(t, m2, job.JobID, StateCompleted)
+	if resumedFrom != "a.go" {
+		t.Errorf("expected resumed job to carry last file a.go, got %q", resumedFrom)
+	}
+}
+
+func TestResumeSkipsFinishedJobs(t *testing.T) {
+	store := newMemStore()
+	store.jobs["job_done"] = graph.IndexJob{JobID: "job_done", State: StateCompleted, CreatedAt: 1}
+	store.jobs["job_failed"] = graph.IndexJob{JobID: "job_failed", State: StateFailed, CreatedAt: 2}
+
+	m := NewManager(Config{
+		Store: store,
+		Run: func(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+			t.Errorf("finished job %s should not run", job.JobID)
+			return nil
+		},
+	})
+	defer m.Close()
+
+	n, err := m.Resume(context.Background())
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected no resumed jobs, got %d", n)
+	}
+
+	list := m.List()
+	if len(list) != 2 || list[0].JobID != "job_failed" {
+		t.Errorf("expected finished jobs listed newest first, got %+v", list)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
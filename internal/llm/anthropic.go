@@ -3,11 +3,13 @@ package llm
 import (
 	"context"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/usage"
 )
 
 type AnthropicProvider struct {
@@ -18,7 +20,17 @@ type AnthropicProvider struct {
 }
 
 func NewAnthropicProvider(cfg config.LLMConfig) (*AnthropicProvider, error) {
-	opts := []option.RequestOption{}
+	timeout := time.Duration(cfg.TimeoutSecs) * time.Second
+	if timeout == 0 {
+		timeout = 120 * time.Second // Default 2 minute timeout
+	}
+
+	// Retries are left to the rate limiting middleware, which also honours
+	// Retry-After
+	opts := []option.RequestOption{
+		option.WithMaxRetries(0),
+		option.WithHTTPClient(httpclient.GetSharedClient(timeout)),
+	}
 
 	if cfg.APIKey != "" {
 		opts = append(opts, option.WithAPIKey(cfg.APIKey))
@@ -51,23 +63,7 @@ func (p *AnthropicProvider) Generate(ctx context.Context, messages []Message, op
 	}
 
 	// Convert messages
-	var systemPrompt string
-	anthropicMessages := []anthropic.MessageParam{}
-
-	for _, m := range messages {
-		switch m.Role {
-		case RoleSystem:
-			systemPrompt = m.Content
-		case RoleUser:
-			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(
-				anthropic.NewTextBlock(m.Content),
-			))
-		case RoleAssistant:
-			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(
-				anthropic.NewTextBlock(m.Content),
-			))
-		}
-	}
+	systemPrompt, anthropicMessages := toAnthropicMessages(messages)
 
 	maxTokens := int64(options.MaxTokens)
 	if maxTokens == 0 {
@@ -90,6 +86,7 @@ func (p *AnthropicProvider) Generate(ctx context.Context, messages []Message, op
 	if err != nil {
 		return "", fmt.Errorf("anthropic completion error: %w", err)
 	}
+	usage.Report(ctx, resp.Usage.InputTokens, resp.Usage.OutputTokens)
 
 	if len(resp.Content) == 0 {
 		return "", fmt.Errorf("no content in response")
@@ -106,25 +103,53 @@ func (p *AnthropicProvider) Generate(ctx context.Context, messages []Message, op
 	return result, nil
 }
 
-func (p *AnthropicProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
-	// Convert messages
+// toAnthropicMessages splits off the system prompt and converts the rest of
+// the conversation. Tool calls become tool_use blocks on the assistant turn;
+// consecutive tool results are merged into the single user turn that must
+// answer all of that turn's calls.
+func toAnthropicMessages(messages []Message) (string, []anthropic.MessageParam) {
 	var systemPrompt string
-	anthropicMessages := []anthropic.MessageParam{}
+	out := []anthropic.MessageParam{}
+	var results []anthropic.ContentBlockParamUnion
+
+	flushResults := func() {
+		if len(results) > 0 {
+			out = append(out, anthropic.NewUserMessage(results...))
+			results = nil
+		}
+	}
 
 	for _, m := range messages {
+		if m.Role != RoleTool {
+			flushResults()
+		}
 		switch m.Role {
 		case RoleSystem:
 			systemPrompt = m.Content
 		case RoleUser:
-			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(
+			out = append(out, anthropic.NewUserMessage(
 				anthropic.NewTextBlock(m.Content),
 			))
 		case RoleAssistant:
-			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(
-				anthropic.NewTextBlock(m.Content),
-			))
+			var blocks []anthropic.ContentBlockParamUnion
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+			}
+			for _, tc := range m.ToolCalls {
+				blocks = append(blocks, anthropic.NewToolUseBlockParam(tc.ID, tc.Name, toolArguments(tc.Arguments)))
+			}
+			out = append(out, anthropic.NewAssistantMessage(blocks...))
+		case RoleTool:
+			results = append(results, anthropic.NewToolResultBlock(m.ToolCallID, m.Content, false))
 		}
 	}
+	flushResults()
+	return systemPrompt, out
+}
+
+func (p *AnthropicProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	// Convert messages
+	systemPrompt, anthropicMessages := toAnthropicMessages(messages)
 
 	// Convert tools
 	anthropicTools := make([]anthropic.ToolParam, len(tools))
@@ -158,6 +183,7 @@ func (p *AnthropicProvider) GenerateWithTools(ctx context.Context, messages []Me
 	if err != nil {
 		return nil, fmt.Errorf("anthropic completion error: %w", err)
 	}
+	usage.Report(ctx, resp.Usage.InputTokens, resp.Usage.OutputTokens)
 
 	result := &ToolCallResponse{}
 
@@ -187,23 +213,7 @@ func (p *AnthropicProvider) Stream(ctx context.Context, messages []Message, opts
 	}
 
 	// Convert messages
-	var systemPrompt string
-	anthropicMessages := []anthropic.MessageParam{}
-
-	for _, m := range messages {
-		switch m.Role {
-		case RoleSystem:
-			systemPrompt = m.Content
-		case RoleUser:
-			anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(
-				anthropic.NewTextBlock(m.Content),
-			))
-		case RoleAssistant:
-			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(
-				anthropic.NewTextBlock(m.Content),
-			))
-		}
-	}
+	systemPrompt, anthropicMessages := toAnthropicMessages(messages)
 
 	maxTokens := int64(options.MaxTokens)
 	if maxTokens == 0 {
@@ -236,7 +246,7 @@ func (p *AnthropicProvider) Stream(ctx context.Context, messages []Message, opts
 			default:
 				if !stream.Next() {
 					if err := stream.Err(); err != nil {
-						log.Printf("anthropic stream error: %v", err)
+						logger().Error("stream error", "provider", "anthropic", "error", err)
 					}
 					return
 				}
@@ -0,0 +1,356 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/usage"
+)
+
+// Circuit breaker states reported by Health
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ProviderHealth is the circuit breaker state of one provider
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	Provider            string     `json:"provider"`
+	Model               string     `json:"model,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+}
+
+// WithToolName records the MCP tool a request is made for; routes match on
+// it and usage is accounted to it
+func WithToolName(ctx context.Context, name string) context.Context {
+	return usage.WithTool(ctx, name)
+}
+
+// ToolName returns the tool recorded by WithToolName, or ""
+func ToolName(ctx context.Context) string {
+	return usage.Tool(ctx)
+}
+
+type answererKey struct{}
+
+// answerer holds the name of the provider that answered a request
+type answerer struct {
+	mu   sync.Mutex
+	name string
+}
+
+// WithAnswerer returns a context in which a chain records the provider that
+// answers a request, and a function returning it, named as Answerer names
+// it. The function returns "" until a chain provider has answered.
+func WithAnswerer(ctx context.Context) (context.Context, func() string) {
+	a := &answerer{}
+	return context.WithValue(ctx, answererKey{}, a), func() string {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.name
+	}
+}
+
+// Answerer names the provider behind p that would answer a request made
+// with ctx now, as "<chain name>=<provider>/<model>": the first of the
+// chain's candidates whose circuit is not open. It returns "" when p is not
+// a chain.
+func Answerer(ctx context.Context, p Provider) string {
+	if t, ok := p.(*tracedProvider); ok {
+		p = t.Provider
+	}
+	c, ok := p.(*chainProvider)
+	if !ok {
+		return ""
+	}
+	candidates := c.candidates(ctx)
+	for _, m := range candidates {
+		if !m.breaker.open() {
+			return m.identity()
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0].identity()
+	}
+	return ""
+}
+
+// Health reports the state of each provider behind p. A provider outside a
+// chain has no circuit breaker and is always reported closed.
+func Health(p Provider) []ProviderHealth {
+	if t, ok := p.(*tracedProvider); ok {
+		p = t.Provider
+	}
+	if c, ok := p.(*chainProvider); ok {
+		return c.health()
+	}
+	return []ProviderHealth{{Name: p.Name(), Provider: p.Name(), State: BreakerClosed}}
+}
+
+// breaker skips a provider after threshold consecutive failures. Once the
+// cooldown has passed it lets a single trial request through (half-open);
+// success closes it again and failure restarts the cooldown.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	lastErr   error
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a request may be sent now
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of an allowed request
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.lastErr = nil
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// open reports whether requests are being skipped, without claiming the
+// trial request of a half-open breaker
+func (b *breaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold && (b.probing || b.now().Before(b.openUntil))
+}
+
+// release ends an allowed request without counting it, for requests the
+// caller abandoned
+func (b *breaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *breaker) health() ProviderHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := ProviderHealth{State: BreakerClosed, ConsecutiveFailures: b.failures}
+	if b.lastErr != nil {
+		h.LastError = b.lastErr.Error()
+	}
+	if b.failures >= b.threshold {
+		h.State = BreakerHalfOpen
+		if b.now().Before(b.openUntil) {
+			h.State = BreakerOpen
+			retryAt := b.openUntil
+			h.RetryAt = &retryAt
+		}
+	}
+	return h
+}
+
+type chainMember struct {
+	name     string
+	model    string
+	provider Provider
+	timeout  time.Duration
+	breaker  *breaker
+}
+
+// identity names the member's provider and model, as Answerer does
+func (m *chainMember) identity() string {
+	return m.name + "=" + m.provider.Name() + "/" + m.model
+}
+
+// chainProvider tries its providers in order until one succeeds. Routes
+// replace the order for requests made on behalf of a given tool.
+type chainProvider struct {
+	members []*chainMember
+	routes  map[string][]*chainMember
+}
+
+// newChain creates the providers in cfg.Providers. Each is metered and
+// traced on its own, so rate limits apply per provider and spans name the
+// provider that actually served a request.
+func newChain(cfg config.LLMConfig) (*chainProvider, error) {
+	c := &chainProvider{routes: make(map[string][]*chainMember)}
+	byName := make(map[string]*chainMember)
+	cooldown := time.Duration(cfg.BreakerCooldownSecs) * time.Second
+
+	for _, pc := range cfg.Providers {
+		name := pc.ChainName()
+		if byName[name] != nil {
+			c.Close()
+			return nil, fmt.Errorf("duplicate LLM provider name %q in chain", name)
+		}
+		memberCfg := cfg.Member(pc)
+		p, err := newSingleProvider(memberCfg)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("LLM provider %q: %w", name, err)
+		}
+		m := &chainMember{
+			name:     name,
+			model:    memberCfg.Model,
+			provider: withTracing(withMetering(p, memberCfg)),
+			timeout:  time.Duration(memberCfg.TimeoutSecs) * time.Second,
+			breaker:  newBreaker(cfg.BreakerThreshold, cooldown),
+		}
+		byName[name] = m
+		c.members = append(c.members, m)
+	}
+
+	for tool, names := range cfg.Routes {
+		for _, name := range names {
+			m := byName[name]
+			if m == nil {
+				c.Close()
+				return nil, fmt.Errorf("LLM route for %s refers to unknown provider %q", tool, name)
+			}
+			c.routes[tool] = append(c.routes[tool], m)
+		}
+	}
+	return c, nil
+}
+
+func (c *chainProvider) Name() string {
+	return "chain"
+}
+
+// candidates returns the providers to try for the tool in ctx
+func (c *chainProvider) candidates(ctx context.Context) []*chainMember {
+	if route, ok := c.routes[ToolName(ctx)]; ok {
+		return route
+	}
+	return c.members
+}
+
+// try calls each candidate in turn. With timed set, each call is bounded by
+// the provider's timeout; streams are not, as the timeout would cut them off.
+func (c *chainProvider) try(ctx context.Context, op string, timed bool, call func(ctx context.Context, p Provider) error) error {
+	var errs []error
+	for _, m := range c.candidates(ctx) {
+		if !m.breaker.allow() {
+			errs = append(errs, fmt.Errorf("%s: circuit open", m.name))
+			continue
+		}
+
+		callCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timed && m.timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, m.timeout)
+		}
+		err := call(callCtx, m.provider)
+		cancel()
+
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up, which says nothing about the provider
+			m.breaker.release()
+			return err
+		}
+		m.breaker.record(err)
+		if err == nil {
+			if a, ok := ctx.Value(answererKey{}).(*answerer); ok {
+				a.mu.Lock()
+				a.name = m.identity()
+				a.mu.Unlock()
+			}
+			return nil
+		}
+		logger().Warn("llm provider failed", "provider", m.name, "op", op, "tool", ToolName(ctx), "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no LLM providers configured")
+	}
+	return fmt.Errorf("all LLM providers failed: %w", errors.Join(errs...))
+}
+
+func (c *chainProvider) Generate(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	var out string
+	err := c.try(ctx, "generate", true, func(ctx context.Context, p Provider) error {
+		var err error
+		out, err = p.Generate(ctx, messages, opts...)
+		return err
+	})
+	return out, err
+}
+
+func (c *chainProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	var resp *ToolCallResponse
+	err := c.try(ctx, "generate_with_tools", true, func(ctx context.Context, p Provider) error {
+		var err error
+		resp, err = p.GenerateWithTools(ctx, messages, tools)
+		return err
+	})
+	return resp, err
+}
+
+// Stream falls back only while opening the stream; errors after the first
+// chunk reach the caller as a closed channel, as with a single provider.
+func (c *chainProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
+	var ch <-chan string
+	err := c.try(ctx, "stream", false, func(ctx context.Context, p Provider) error {
+		var err error
+		ch, err = p.Stream(ctx, messages, opts...)
+		return err
+	})
+	return ch, err
+}
+
+func (c *chainProvider) Close() error {
+	var errs []error
+	for _, m := range c.members {
+		if err := m.provider.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *chainProvider) health() []ProviderHealth {
+	out := make([]ProviderHealth, 0, len(c.members))
+	for _, m := range c.members {
+		h := m.breaker.health()
+		h.Name = m.name
+		h.Provider = m.provider.Name()
+		h.Model = m.model
+		out = append(out, h)
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// fakeProvider answers with its name, or fails while err is set
+type fakeProvider struct {
+	name  string
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (f *fakeProvider) Generate(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	f.calls++
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	if f.err != nil {
+		return "", f.err
+	}
+	return f.name, nil
+}
+
+func (f *fakeProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	out, err := f.Generate(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolCallResponse{Content: out}, nil
+}
+
+func (f *fakeProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
+	out, err := f.Generate(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan string, 1)
+	ch <- out
+	close(ch)
+	return ch, nil
+}
+
+func (f *fakeProvider) Name() string { return f.name }
+func (f *fakeProvider) Close() error { return nil }
+
+func testChain(providers ...*fakeProvider) *chainProvider {
+	c := &chainProvider{routes: make(map[string][]*chainMember)}
+	for _, p := range providers {
+		c.members = append(c.members, &chainMember{name: p.name, provider: p, breaker: newBreaker(2, time.Minute)})
+	}
+	return c
+}
+
+func TestChainFallback(t *testing.T) {
+	primary := &fakeProvider{name: "primary", err: errors.New("429 rate limited")}
+	local := &fakeProvider{name: "local"}
+	c := testChain(primary, local)
+	ctx := context.Background()
+
+	out, err := c.Generate(ctx, nil)
+	if err != nil || out != "local" {
+		t.Fatalf("Generate() = %q, %v; want fallback to local", out, err)
+	}
+
+	// A second failure opens the primary's circuit, so it is skipped
+	c.Generate(ctx, nil)
+	c.Generate(ctx, nil)
+	if primary.calls != 2 {
+		t.Errorf("expected open circuit to skip primary, got %d calls", primary.calls)
+	}
+	health := Health(c)
+	if health[0].State != BreakerOpen || health[0].RetryAt == nil || health[1].State != BreakerClosed {
+		t.Errorf("unexpected health %+v", health)
+	}
+
+	// After the cooldown one trial request goes through and closes it
+	now := time.Now().Add(2 * time.Minute)
+	c.members[0].breaker.now = func() time.Time { return now }
+	if Health(c)[0].State != BreakerHalfOpen {
+		t.Errorf("expected half-open after cooldown, got %+v", Health(c)[0])
+	}
+	primary.err = nil
+	if out, _ := c.Generate(ctx, nil); out != "primary" {
+		t.Errorf("expected trial request to reach primary, got %q", out)
+	}
+	if h := Health(c)[0]; h.State != BreakerClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("expected closed breaker after success, got %+v", h)
+	}
+
+	local.err = errors.New("connection refused")
+	primary.err = errors.New("overloaded")
+	_, err = c.Generate(ctx, nil)
+	if err == nil || !strings.Contains(err.Error(), "overloaded") || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected both provider errors, got %v", err)
+	}
+}
+
+func TestChainAnswerer(t *testing.T) {
+	primary := &fakeProvider{name: "primary", err: errors.New("overloaded")}
+	local := &fakeProvider{name: "local"}
+	c := testChain(primary, local)
+	c.members[1].model = "qwen"
+
+	if name := Answerer(context.Background(), c); name != "primary=primary/" {
+		t.Errorf("expected the primary to be expected to answer, got %q", name)
+	}
+	ctx, answeredBy := WithAnswerer(context.Background())
+	if _, err := c.Generate(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if name := answeredBy(); name != "local=local/qwen" {
+		t.Errorf("expected the fallback to be recorded as answering, got %q", name)
+	}
+
+	// Once the primary's circuit opens, the fallback answers
+	c.Generate(context.Background(), nil)
+	if name := Answerer(context.Background(), c); name != "local=local/qwen" {
+		t.Errorf("expected the fallback to be expected to answer, got %q", name)
+	}
+	if name := Answerer(context.Background(), local); name != "" {
+		t.Errorf("expected no answerer outside a chain, got %q", name)
+	}
+}
+
+func TestChainRoutesAndTimeouts(t *testing.T) {
+	large := &fakeProvider{name: "large", delay: time.Second}
+	local := &fakeProvider{name: "local"}
+	c := testChain(large, local)
+	c.members[0].timeout = 20 * time.Millisecond
+	c.routes["codeloom_context"] = []*chainMember{c.members[1]}
+
+	out, err := c.Generate(WithToolName(context.Background(), "codeloom_context"), nil)
+	if err != nil || out != "local" || large.calls != 0 {
+		t.Errorf("expected routed request to use only local, got %q, %v (large calls %d)", out, err, large.calls)
+	}
+
+	// The slow provider's timeout moves the request on to the next one
+	out, err = c.Generate(WithToolName(context.Background(), "codeloom_architecture"), nil)
+	if err != nil || out != "local" || large.calls != 1 {
+		t.Errorf("expected timeout fallback to local, got %q, %v", out, err)
+	}
+
+	// A request the caller cancels is not held against the provider
+	ctx, cancel := context.WithCancel(context.Background())
+	c.members[0].timeout = 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := c.Generate(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if h := Health(c)[0]; h.ConsecutiveFailures != 1 {
+		t.Errorf("expected cancellation not to count as a failure, got %+v", h)
+	}
+}
+
+func TestNewProviderChain(t *testing.T) {
+	cfg := config.DefaultConfig().LLM
+	cfg.Providers = []config.LLMProviderConfig{
+		{Provider: "ollama", Model: "qwen2.5-coder"},
+		{Name: "large", Provider: "openai", Model: "gpt-4o", TimeoutSecs: 30},
+	}
+	cfg.Routes = map[string][]string{"codeloom_context": {"ollama"}}
+
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	defer p.Close()
+	health := Health(p)
+	if len(health) != 2 || health[0].Name != "ollama" || health[1].Name != "large" || health[1].Provider != "openai" || health[1].Model != "gpt-4o" {
+		t.Errorf("unexpected chain health %+v", health)
+	}
+
+	cfg.Routes = map[string][]string{"codeloom_context": {"missing"}}
+	if _, err := NewProvider(cfg); err == nil {
+		t.Error("expected error for route to an unknown provider")
+	}
+}
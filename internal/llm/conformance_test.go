@@ -0,0 +1,345 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// The conformance tests run every provider through the same agent turn: the
+// model asks for two tools in parallel, and the calls and their results are
+// sent back. Each provider must parse both calls and encode the history in
+// its own API's pairing rules.
+
+var conformanceTools = []Tool{
+	{
+		Name:        "find_symbol",
+		Description: "Find a symbol by name",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name":  map[string]interface{}{"type": "string"},
+				"kinds": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "enum": []string{"function", "method"}}},
+			},
+			"required": []string{"name"},
+		},
+	},
+	{
+		Name:        "get_callers",
+		Description: "List callers of a node",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+		},
+	},
+}
+
+// wantCalls are the parallel calls every stub response contains
+var wantCalls = []ToolCall{
+	{Name: "find_symbol", Arguments: `{"name":"Parse"}`},
+	{Name: "get_callers", Arguments: `{"id":"parser.Parse"}`},
+}
+
+var wantResults = []string{"parser.go:10 func Parse", `{"callers":["main.run"]}`}
+
+func conformancePrompt() []Message {
+	return []Message{
+		{Role: RoleSystem, Content: "You analyse code."},
+		{Role: RoleUser, Content: "Who calls Parse?"},
+	}
+}
+
+// withToolTurn appends the assistant turn that made calls, and one result
+// per call, the way the agent records them
+func withToolTurn(messages []Message, calls []ToolCall) []Message {
+	messages = append(messages, Message{Role: RoleAssistant, Content: "Let me look.", ToolCalls: calls})
+	for i, call := range calls {
+		messages = append(messages, Message{Role: RoleTool, Content: wantResults[i], ToolCallID: call.ID, Name: call.Name})
+	}
+	return messages
+}
+
+type conformanceCase struct {
+	provider string
+	baseURL  func(server string) string
+	// response answers with wantCalls
+	response string
+	// skipParse is set when the client cannot read a stub response, so
+	// parsing is checked separately
+	skipParse bool
+	// checkRequest inspects the encoded history after the tool turn
+	checkRequest func(t *testing.T, req map[string]any, calls []ToolCall)
+}
+
+var conformanceCases = []conformanceCase{
+	{
+		provider: "openai",
+		baseURL:  func(server string) string { return server + "/v1" },
+		response: `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Let me look.","tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"find_symbol","arguments":"{\"name\":\"Parse\"}"}},
+			{"id":"call_b","type":"function","function":{"name":"get_callers","arguments":"{\"id\":\"parser.Parse\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":50,"completion_tokens":20}}`,
+		checkRequest: func(t *testing.T, req map[string]any, calls []ToolCall) {
+			messages := list(req["messages"])
+			if len(messages) != 5 {
+				t.Fatalf("expected system, user, assistant and two tool messages, got %v", messages)
+			}
+			toolCalls := list(object(messages[2])["tool_calls"])
+			if len(toolCalls) != 2 {
+				t.Fatalf("expected both calls on one assistant message, got %v", messages[2])
+			}
+			for i, call := range calls {
+				fn := object(object(toolCalls[i])["function"])
+				if object(toolCalls[i])["id"] != call.ID || fn["name"] != call.Name || fn["arguments"] != call.Arguments {
+					t.Errorf("call %d encoded as %v, want %+v", i, toolCalls[i], call)
+				}
+				result := object(messages[3+i])
+				if result["role"] != "tool" || result["tool_call_id"] != call.ID || result["content"] != wantResults[i] {
+					t.Errorf("result %d encoded as %v", i, result)
+				}
+				if _, ok := result["name"]; ok {
+					t.Errorf("tool message %d must not carry a name: %v", i, result)
+				}
+			}
+		},
+	},
+	{
+		provider: "anthropic",
+		baseURL:  func(server string) string { return server },
+		response: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5","stop_reason":"tool_use","content":[
+			{"type":"text","text":"Let me look."},
+			{"type":"tool_use","id":"toolu_a","name":"find_symbol","input":{"name":"Parse"}},
+			{"type":"tool_use","id":"toolu_b","name":"get_callers","input":{"id":"parser.Parse"}}],"usage":{"input_tokens":50,"output_tokens":20}}`,
+		checkRequest: func(t *testing.T, req map[string]any, calls []ToolCall) {
+			messages := list(req["messages"])
+			if len(messages) != 3 {
+				t.Fatalf("expected user, assistant and one user turn of results, got %v", messages)
+			}
+			blocks := list(object(messages[1])["content"])
+			results := list(object(messages[2])["content"])
+			if len(blocks) != 3 || len(results) != 2 {
+				t.Fatalf("expected text plus two tool_use blocks answered by two tool_result blocks, got %v and %v", blocks, results)
+			}
+			for i, call := range calls {
+				use := object(blocks[1+i])
+				if use["type"] != "tool_use" || use["id"] != call.ID || use["name"] != call.Name || !sameJSON(use["input"], call.Arguments) {
+					t.Errorf("call %d encoded as %v, want %+v", i, use, call)
+				}
+				result := object(results[i])
+				if result["type"] != "tool_result" || result["tool_use_id"] != call.ID || !reflect.DeepEqual(result["content"], []any{map[string]any{"type": "text", "text": wantResults[i]}}) {
+					t.Errorf("result %d encoded as %v", i, result)
+				}
+			}
+			if system := list(req["system"]); len(system) != 1 {
+				t.Errorf("expected the system prompt as a system block, got %v", req["system"])
+			}
+		},
+	},
+	{
+		provider: "ollama",
+		baseURL:  func(server string) string { return server },
+		response: `{"model":"qwen","done":true,"message":{"role":"assistant","content":"Let me look.","tool_calls":[
+			{"function":{"name":"find_symbol","arguments":{"name":"Parse"}}},
+			{"function":{"name":"get_callers","arguments":{"id":"parser.Parse"}}}]}}`,
+		checkRequest: func(t *testing.T, req map[string]any, calls []ToolCall) {
+			messages := list(req["messages"])
+			if len(messages) != 5 {
+				t.Fatalf("expected system, user, assistant and two tool messages, got %v", messages)
+			}
+			toolCalls := list(object(messages[2])["tool_calls"])
+			if len(toolCalls) != 2 {
+				t.Fatalf("expected both calls on one assistant message, got %v", messages[2])
+			}
+			for i, call := range calls {
+				fn := object(object(toolCalls[i])["function"])
+				if fn["name"] != call.Name || !sameJSON(fn["arguments"], call.Arguments) {
+					t.Errorf("call %d encoded as %v, want %+v", i, fn, call)
+				}
+				result := object(messages[3+i])
+				if result["role"] != "tool" || result["tool_name"] != call.Name || result["content"] != wantResults[i] {
+					t.Errorf("result %d encoded as %v", i, result)
+				}
+			}
+			if len(list(req["tools"])) != 2 {
+				t.Errorf("expected both tools declared, got %v", req["tools"])
+			}
+		},
+	},
+	{
+		// The genai chat client reads replies with gax's stream decoder,
+		// which cannot find the end of a stream under the encoding/json v2
+		// decoder; TestGoogleToolCallResponse covers parsing instead
+		provider:  "google",
+		baseURL:   func(server string) string { return server },
+		response:  `[]`,
+		skipParse: true,
+		checkRequest: func(t *testing.T, req map[string]any, calls []ToolCall) {
+			contents := list(req["contents"])
+			if len(contents) != 3 {
+				t.Fatalf("expected user, model and one user turn of function responses, got %v", contents)
+			}
+			question := list(object(contents[0])["parts"])
+			if text, _ := object(question[0])["text"].(string); text != "You analyse code.\n\nWho calls Parse?" {
+				t.Errorf("expected the system prompt before the question, got %q", text)
+			}
+			model := object(contents[1])
+			parts := list(model["parts"])
+			responses := list(object(contents[2])["parts"])
+			if model["role"] != "model" || len(parts) != 3 || len(responses) != 2 {
+				t.Fatalf("expected text plus two function calls answered by two function responses, got %v and %v", model, responses)
+			}
+			for i, call := range calls {
+				fc := object(object(parts[1+i])["functionCall"])
+				if fc["name"] != call.Name || !sameJSON(fc["args"], call.Arguments) {
+					t.Errorf("call %d encoded as %v, want %+v", i, parts[1+i], call)
+				}
+				fr := object(object(responses[i])["functionResponse"])
+				if fr["name"] != call.Name || fr["response"] == nil {
+					t.Errorf("result %d encoded as %v", i, responses[i])
+				}
+			}
+			if got := object(object(responses[1])["functionResponse"])["response"]; !sameJSON(got, wantResults[1]) {
+				t.Errorf("expected JSON object results to be passed as they are, got %v", got)
+			}
+			declarations := list(object(list(req["tools"])[0])["functionDeclarations"])
+			if len(list(req["tools"])) != 1 || len(declarations) != 2 {
+				t.Errorf("expected one tool declaring both functions, got %v", req["tools"])
+			}
+			kinds := object(object(object(object(declarations[0])["parameters"])["properties"])["kinds"])
+			if object(kinds["items"])["enum"] == nil {
+				t.Errorf("expected array items and enums in the parameter schema, got %v", kinds)
+			}
+		},
+	},
+}
+
+func TestProviderToolCallConformance(t *testing.T) {
+	for _, tc := range conformanceCases {
+		t.Run(tc.provider, func(t *testing.T) {
+			var mu sync.Mutex
+			var lastBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				lastBody = body
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			p, err := newSingleProvider(config.LLMConfig{
+				Provider: tc.provider,
+				Model:    "test-model",
+				APIKey:   "test-key",
+				BaseURL:  tc.baseURL(server.URL),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			ctx := context.Background()
+
+			calls := []ToolCall{
+				{ID: "call_a", Name: wantCalls[0].Name, Arguments: wantCalls[0].Arguments},
+				{ID: "call_b", Name: wantCalls[1].Name, Arguments: wantCalls[1].Arguments},
+			}
+			if !tc.skipParse {
+				resp, err := p.GenerateWithTools(ctx, conformancePrompt(), conformanceTools)
+				if err != nil {
+					t.Fatalf("first turn failed: %v", err)
+				}
+				checkToolCalls(t, resp)
+				calls = resp.ToolCalls
+			}
+
+			// The second turn sends the calls and both results back
+			_, err = p.GenerateWithTools(ctx, withToolTurn(conformancePrompt(), calls), conformanceTools)
+			if err != nil && !tc.skipParse {
+				t.Fatalf("second turn failed: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			var req map[string]any
+			if err := json.Unmarshal(lastBody, &req); err != nil {
+				t.Fatalf("request is not JSON: %v\n%s", err, lastBody)
+			}
+			tc.checkRequest(t, req, calls)
+		})
+	}
+}
+
+func TestGoogleToolCallResponse(t *testing.T) {
+	resp := &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		Content: &genai.Content{Role: "model", Parts: []genai.Part{
+			genai.Text("Let me look."),
+			genai.FunctionCall{Name: "find_symbol", Args: map[string]any{"name": "Parse"}},
+			genai.FunctionCall{Name: "get_callers", Args: map[string]any{"id": "parser.Parse"}},
+		}},
+	}}}
+	got, err := toolCallResponseFromGenai(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkToolCalls(t, got)
+
+	if _, err := toolCallResponseFromGenai(&genai.GenerateContentResponse{}); err == nil {
+		t.Error("expected an error without candidates")
+	}
+}
+
+func TestToGenaiContentsRequiresUserTurn(t *testing.T) {
+	_, _, err := toGenaiContents([]Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}})
+	if err == nil {
+		t.Error("expected an error when the conversation ends with the model")
+	}
+}
+
+// checkToolCalls asserts a response holds wantCalls with distinct IDs
+func checkToolCalls(t *testing.T, resp *ToolCallResponse) {
+	t.Helper()
+	if resp.Content != "Let me look." || len(resp.ToolCalls) != len(wantCalls) {
+		t.Fatalf("expected text and %d tool calls, got %+v", len(wantCalls), resp)
+	}
+	ids := map[string]bool{}
+	for i, call := range resp.ToolCalls {
+		if call.Name != wantCalls[i].Name || !sameJSON(call.Arguments, wantCalls[i].Arguments) {
+			t.Errorf("call %d = %+v, want %+v", i, call, wantCalls[i])
+		}
+		if call.ID == "" || ids[call.ID] {
+			t.Errorf("call %d has a missing or duplicate ID %q", i, call.ID)
+		}
+		ids[call.ID] = true
+	}
+}
+
+func list(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func object(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+// sameJSON compares a decoded value, or a JSON string, with a JSON string
+func sameJSON(got any, want string) bool {
+	if s, ok := got.(string); ok {
+		if err := json.Unmarshal([]byte(s), &got); err != nil {
+			return false
+		}
+	}
+	var w any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, w)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/usage"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
@@ -24,8 +26,23 @@ func NewGoogleProvider(cfg config.LLMConfig) (*GoogleProvider, error) {
 		return nil, fmt.Errorf("google API key required (set GOOGLE_API_KEY or GEMINI_API_KEY)")
 	}
 
+	timeout := time.Duration(cfg.TimeoutSecs) * time.Second
+	if timeout == 0 {
+		timeout = 120 * time.Second // Default 2 minute timeout
+	}
+
+	// The REST client ignores the API key option when given an HTTP client,
+	// so the key goes in a header; the shared transport lets tests replay
+	opts := []option.ClientOption{
+		option.WithAPIKey(cfg.APIKey),
+		option.WithHTTPClient(httpclient.NewClientWithHeaders(timeout, map[string]string{"x-goog-api-key": cfg.APIKey})),
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithEndpoint(cfg.BaseURL))
+	}
+
 	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(cfg.APIKey))
+	client, err := genai.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Google AI client: %w", err)
 	}
@@ -67,51 +84,17 @@ func (p *GoogleProvider) Generate(ctx context.Context, messages []Message, opts
 
 	// Convert messages to Gemini format
 	cs := model.StartChat()
-	var systemContent string
-
-	for _, msg := range messages {
-		switch msg.Role {
-		case RoleSystem:
-			systemContent = msg.Content
-		case RoleUser:
-			content := msg.Content
-			if systemContent != "" {
-				content = systemContent + "\n\n" + content
-				systemContent = ""
-			}
-			cs.History = append(cs.History, &genai.Content{
-				Parts: []genai.Part{genai.Text(content)},
-				Role:  "user",
-			})
-		case RoleAssistant:
-			cs.History = append(cs.History, &genai.Content{
-				Parts: []genai.Part{genai.Text(msg.Content)},
-				Role:  "model",
-			})
-		}
-	}
-
-	// Get last user message for sending
-	var lastUserMsg string
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == RoleUser {
-			lastUserMsg = messages[i].Content
-			// Remove the last user message from history since we'll send it
-			if len(cs.History) > 0 {
-				cs.History = cs.History[:len(cs.History)-1]
-			}
-			break
-		}
-	}
-
-	if lastUserMsg == "" {
-		return "", fmt.Errorf("no user message found")
+	history, last, err := toGenaiContents(messages)
+	if err != nil {
+		return "", err
 	}
+	cs.History = history
 
-	resp, err := cs.SendMessage(ctx, genai.Text(lastUserMsg))
+	resp, err := cs.SendMessage(ctx, last...)
 	if err != nil {
 		return "", fmt.Errorf("google generate error: %w", err)
 	}
+	reportGoogleUsage(ctx, resp)
 
 	// Extract text from response
 	if len(resp.Candidates) == 0 {
@@ -137,87 +120,54 @@ func (p *GoogleProvider) GenerateWithTools(ctx context.Context, messages []Messa
 		model.SetMaxOutputTokens(int32(p.maxTokens))
 	}
 
-	// Convert tools to Gemini format
-	var genaiTools []*genai.Tool
-	for _, tool := range tools {
-		// Convert parameters to OpenAPI schema
-		schema := convertToGenaiSchema(tool.Parameters)
-
-		genaiTools = append(genaiTools, &genai.Tool{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-				{
-					Name:        tool.Name,
-					Description: tool.Description,
-					Parameters:  schema,
-				},
-			},
-		})
-	}
-	model.Tools = genaiTools
-
-	// Convert messages to Gemini format
-	cs := model.StartChat()
-	var systemContent string
-
-	for _, msg := range messages {
-		switch msg.Role {
-		case RoleSystem:
-			systemContent = msg.Content
-		case RoleUser:
-			content := msg.Content
-			if systemContent != "" {
-				content = systemContent + "\n\n" + content
-				systemContent = ""
-			}
-			cs.History = append(cs.History, &genai.Content{
-				Parts: []genai.Part{genai.Text(content)},
-				Role:  "user",
-			})
-		case RoleAssistant:
-			cs.History = append(cs.History, &genai.Content{
-				Parts: []genai.Part{genai.Text(msg.Content)},
-				Role:  "model",
-			})
-		}
-	}
-
-	// Get last user message
-	var lastUserMsg string
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == RoleUser {
-			lastUserMsg = messages[i].Content
-			if len(cs.History) > 0 {
-				cs.History = cs.History[:len(cs.History)-1]
+	// Declare all tools together; the model may call several in one turn
+	if len(tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			declarations[i] = &genai.FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  convertToGenaiSchema(tool.Parameters),
 			}
-			break
 		}
+		model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
 	}
 
-	if lastUserMsg == "" {
-		return nil, fmt.Errorf("no user message found")
+	// Convert messages to Gemini format
+	cs := model.StartChat()
+	history, last, err := toGenaiContents(messages)
+	if err != nil {
+		return nil, err
 	}
+	cs.History = history
 
-	resp, err := cs.SendMessage(ctx, genai.Text(lastUserMsg))
+	resp, err := cs.SendMessage(ctx, last...)
 	if err != nil {
 		return nil, fmt.Errorf("google generate error: %w", err)
 	}
+	reportGoogleUsage(ctx, resp)
 
-	if len(resp.Candidates) == 0 {
+	return toolCallResponseFromGenai(resp)
+}
+
+// toolCallResponseFromGenai collects the text and function calls of the first
+// candidate. Gemini does not identify calls, so IDs are derived from their
+// position, which keeps parallel calls to the same function apart.
+func toolCallResponseFromGenai(resp *genai.GenerateContentResponse) (*ToolCallResponse, error) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
 		return nil, fmt.Errorf("no response candidates")
 	}
 
 	response := &ToolCallResponse{}
-
-	// Check for function calls
 	for _, part := range resp.Candidates[0].Content.Parts {
 		switch v := part.(type) {
 		case genai.FunctionCall:
 			argsJSON, err := json.Marshal(v.Args)
-			if err != nil {
+			if err != nil || v.Args == nil {
 				argsJSON = []byte("{}")
 			}
 			response.ToolCalls = append(response.ToolCalls, ToolCall{
-				ID:        fmt.Sprintf("call_%s", v.Name),
+				ID:        fmt.Sprintf("call_%d_%s", len(response.ToolCalls), v.Name),
 				Name:      v.Name,
 				Arguments: string(argsJSON),
 			})
@@ -229,20 +179,21 @@ func (p *GoogleProvider) GenerateWithTools(ctx context.Context, messages []Messa
 	return response, nil
 }
 
-func (p *GoogleProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
-	model := p.client.GenerativeModel(p.model)
-
-	// Set generation config
-	model.SetTemperature(p.temperature)
-	if p.maxTokens > 0 {
-		model.SetMaxOutputTokens(int32(p.maxTokens))
-	}
-
-	// Convert messages to Gemini format
-	cs := model.StartChat()
+// toGenaiContents converts a conversation into chat history plus the parts
+// of the final turn, which must come from the user or be tool results. The
+// system prompt is prepended to the next user message. Assistant tool calls
+// become function calls, and consecutive tool results are sent together as
+// function responses, named after the calls they answer.
+func toGenaiContents(messages []Message) ([]*genai.Content, []genai.Part, error) {
+	var history []*genai.Content
 	var systemContent string
+	callNames := make(map[string]string)
+	var results *genai.Content
 
 	for _, msg := range messages {
+		if msg.Role != RoleTool {
+			results = nil
+		}
 		switch msg.Role {
 		case RoleSystem:
 			systemContent = msg.Content
@@ -252,40 +203,76 @@ func (p *GoogleProvider) Stream(ctx context.Context, messages []Message, opts ..
 				content = systemContent + "\n\n" + content
 				systemContent = ""
 			}
-			cs.History = append(cs.History, &genai.Content{
+			history = append(history, &genai.Content{
 				Parts: []genai.Part{genai.Text(content)},
 				Role:  "user",
 			})
 		case RoleAssistant:
-			cs.History = append(cs.History, &genai.Content{
-				Parts: []genai.Part{genai.Text(msg.Content)},
-				Role:  "model",
+			var parts []genai.Part
+			if msg.Content != "" || len(msg.ToolCalls) == 0 {
+				parts = append(parts, genai.Text(msg.Content))
+			}
+			for _, tc := range msg.ToolCalls {
+				callNames[tc.ID] = tc.Name
+				parts = append(parts, genai.FunctionCall{Name: tc.Name, Args: toolArguments(tc.Arguments)})
+			}
+			history = append(history, &genai.Content{Parts: parts, Role: "model"})
+		case RoleTool:
+			name := msg.Name
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+			if results == nil {
+				results = &genai.Content{Role: "user"}
+				history = append(history, results)
+			}
+			results.Parts = append(results.Parts, genai.FunctionResponse{
+				Name:     name,
+				Response: toolResponse(msg.Content),
 			})
 		}
 	}
 
-	// Get last user message
-	var lastUserMsg string
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == RoleUser {
-			lastUserMsg = messages[i].Content
-			if len(cs.History) > 0 {
-				cs.History = cs.History[:len(cs.History)-1]
-			}
-			break
-		}
+	if len(history) == 0 || history[len(history)-1].Role != "user" {
+		return nil, nil, fmt.Errorf("no user message found")
 	}
+	last := history[len(history)-1].Parts
+	return history[:len(history)-1], last, nil
+}
 
-	if lastUserMsg == "" {
-		return nil, fmt.Errorf("no user message found")
+// toolResponse wraps a tool result as the JSON object Gemini expects. Results
+// that already are JSON objects are passed as they are.
+func toolResponse(content string) map[string]any {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(content), &obj); err == nil && obj != nil {
+		return obj
 	}
+	return map[string]any{"content": content}
+}
+
+func (p *GoogleProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
+	model := p.client.GenerativeModel(p.model)
+
+	// Set generation config
+	model.SetTemperature(p.temperature)
+	if p.maxTokens > 0 {
+		model.SetMaxOutputTokens(int32(p.maxTokens))
+	}
+
+	// Convert messages to Gemini format
+	cs := model.StartChat()
+	history, last, err := toGenaiContents(messages)
+	if err != nil {
+		return nil, err
+	}
+	cs.History = history
 
 	ch := make(chan string, 100)
 
 	go func() {
 		defer close(ch)
 
-		iter := cs.SendMessageStream(ctx, genai.Text(lastUserMsg))
+		iter := cs.SendMessageStream(ctx, last...)
 		for {
 			select {
 			case <-ctx.Done():
@@ -296,7 +283,7 @@ func (p *GoogleProvider) Stream(ctx context.Context, messages []Message, opts ..
 					return
 				}
 				if err != nil {
-					log.Printf("google stream error: %v", err)
+					logger().Error("stream error", "provider", "google", "error", err)
 					return
 				}
 
@@ -318,61 +305,86 @@ func (p *GoogleProvider) Stream(ctx context.Context, messages []Message, opts ..
 	return ch, nil
 }
 
-// convertToGenaiSchema converts a map-based schema to genai.Schema
+// convertToGenaiSchema converts a map-based JSON schema for tool parameters
+// to genai.Schema. Parameters are always an object.
 func convertToGenaiSchema(params map[string]interface{}) *genai.Schema {
 	if params == nil {
 		return nil
 	}
+	schema := convertSchemaNode(params)
+	schema.Type = genai.TypeObject
+	return schema
+}
+
+// convertSchemaNode converts one JSON schema node, including nested
+// properties, array items and enums
+func convertSchemaNode(node map[string]interface{}) *genai.Schema {
+	schema := &genai.Schema{}
+
+	if t, ok := node["type"].(string); ok {
+		switch t {
+		case "string":
+			schema.Type = genai.TypeString
+		case "integer":
+			schema.Type = genai.TypeInteger
+		case "number":
+			schema.Type = genai.TypeNumber
+		case "boolean":
+			schema.Type = genai.TypeBoolean
+		case "array":
+			schema.Type = genai.TypeArray
+		case "object":
+			schema.Type = genai.TypeObject
+		}
+	}
 
-	schema := &genai.Schema{
-		Type: genai.TypeObject,
+	if desc, ok := node["description"].(string); ok {
+		schema.Description = desc
 	}
+	schema.Enum = stringList(node["enum"])
+	schema.Required = stringList(node["required"])
 
-	if props, ok := params["properties"].(map[string]interface{}); ok {
+	if props, ok := node["properties"].(map[string]interface{}); ok {
 		schema.Properties = make(map[string]*genai.Schema)
 		for name, prop := range props {
-			propMap, ok := prop.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			propSchema := &genai.Schema{}
-
-			if t, ok := propMap["type"].(string); ok {
-				switch t {
-				case "string":
-					propSchema.Type = genai.TypeString
-				case "integer":
-					propSchema.Type = genai.TypeInteger
-				case "number":
-					propSchema.Type = genai.TypeNumber
-				case "boolean":
-					propSchema.Type = genai.TypeBoolean
-				case "array":
-					propSchema.Type = genai.TypeArray
-				case "object":
-					propSchema.Type = genai.TypeObject
-				}
-			}
-
-			if desc, ok := propMap["description"].(string); ok {
-				propSchema.Description = desc
+			if propMap, ok := prop.(map[string]interface{}); ok {
+				schema.Properties[name] = convertSchemaNode(propMap)
 			}
+		}
+	}
 
-			schema.Properties[name] = propSchema
+	if schema.Type == genai.TypeArray {
+		// Gemini rejects arrays without an item type
+		schema.Items = &genai.Schema{Type: genai.TypeString}
+		if items, ok := node["items"].(map[string]interface{}); ok {
+			schema.Items = convertSchemaNode(items)
 		}
 	}
 
-	if required, ok := params["required"].([]string); ok {
-		schema.Required = required
-	} else if required, ok := params["required"].([]interface{}); ok {
-		for _, r := range required {
-			if s, ok := r.(string); ok {
-				schema.Required = append(schema.Required, s)
+	return schema
+}
+
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		var out []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
 			}
 		}
+		return out
 	}
+	return nil
+}
 
-	return schema
+// reportGoogleUsage passes the response's token counts to the usage meter
+func reportGoogleUsage(ctx context.Context, resp *genai.GenerateContentResponse) {
+	if resp.UsageMetadata != nil {
+		usage.Report(ctx, int64(resp.UsageMetadata.PromptTokenCount), int64(resp.UsageMetadata.CandidatesTokenCount))
+	}
 }
 
 func (p *GoogleProvider) Close() error {
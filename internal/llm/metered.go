@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/usage"
+)
+
+// meteredProvider rate-limits and retries requests and records their token
+// usage and estimated cost. Tokens the provider does not report are
+// estimated from the text.
+type meteredProvider struct {
+	Provider
+	model   string
+	limiter *ratelimit.Limiter
+	policy  ratelimit.Policy
+	price   usage.Price
+	tracker *usage.Tracker
+}
+
+func withMetering(p Provider, cfg config.LLMConfig) Provider {
+	return &meteredProvider{
+		Provider: p,
+		model:    cfg.Model,
+		limiter:  ratelimit.NewLimiter(cfg.RequestsPerMinute),
+		policy:   ratelimit.DefaultPolicy(cfg.MaxRetries),
+		price:    usage.Price{Input: cfg.InputPrice, Output: cfg.OutputPrice},
+		tracker:  usage.Default(),
+	}
+}
+
+func (m *meteredProvider) Generate(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	ctx, counter := usage.WithCounter(ctx)
+	var out string
+	retries, err := ratelimit.Do(ctx, m.limiter, m.policy, func(ctx context.Context) error {
+		var err error
+		out, err = m.Provider.Generate(ctx, messages, opts...)
+		return err
+	})
+	m.record(ctx, counter, messages, len(out), retries, err)
+	return out, err
+}
+
+func (m *meteredProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	ctx, counter := usage.WithCounter(ctx)
+	var resp *ToolCallResponse
+	retries, err := ratelimit.Do(ctx, m.limiter, m.policy, func(ctx context.Context) error {
+		var err error
+		resp, err = m.Provider.GenerateWithTools(ctx, messages, tools)
+		return err
+	})
+	outChars := 0
+	if resp != nil {
+		outChars = len(resp.Content)
+		for _, call := range resp.ToolCalls {
+			outChars += len(call.Name) + len(call.Arguments)
+		}
+	}
+	m.record(ctx, counter, messages, outChars, retries, err)
+	return resp, err
+}
+
+// Stream retries only opening the stream. Usage is recorded when the stream
+// ends.
+func (m *meteredProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
+	ctx, counter := usage.WithCounter(ctx)
+	var ch <-chan string
+	retries, err := ratelimit.Do(ctx, m.limiter, m.policy, func(ctx context.Context) error {
+		var err error
+		ch, err = m.Provider.Stream(ctx, messages, opts...)
+		return err
+	})
+	if err != nil {
+		m.record(ctx, counter, messages, 0, retries, err)
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		outChars := 0
+		// Record before closing, so usage is in place once the caller is done
+		defer close(out)
+		defer func() { m.record(ctx, counter, messages, outChars, retries, ctx.Err()) }()
+		for chunk := range ch {
+			outChars += len(chunk)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Drain so the provider goroutine can exit
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (m *meteredProvider) record(ctx context.Context, counter *usage.Counter, messages []Message, outChars, retries int, err error) {
+	input, output, reported := counter.Tokens()
+	if !reported {
+		inChars := 0
+		for _, msg := range messages {
+			inChars += len(msg.Content)
+			for _, call := range msg.ToolCalls {
+				inChars += len(call.Name) + len(call.Arguments)
+			}
+		}
+		input = usage.EstimateTokens(inChars)
+		output = usage.EstimateTokens(outChars)
+	}
+	m.tracker.Add(usage.Record{
+		Kind:         usage.KindLLM,
+		Tool:         usage.Tool(ctx),
+		Provider:     m.Name(),
+		Model:        m.model,
+		InputTokens:  input,
+		OutputTokens: output,
+		Estimated:    !reported,
+		Cost:         m.price.Cost(input, output),
+		Retries:      retries,
+		Failed:       err != nil,
+	})
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/usage"
+)
+
+func TestMeteredProviderRetriesAndRecordsUsage(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After-Ms", "10")
+			http.Error(w, "busy", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"model":"qwen","message":{"role":"assistant","content":"done"},"done":true,"prompt_eval_count":120,"eval_count":30}`))
+	}))
+	defer server.Close()
+
+	cfg := config.LLMConfig{
+		Provider:   "ollama",
+		Model:      "qwen",
+		BaseURL:    server.URL,
+		MaxRetries: 2,
+		InputPrice: 1, OutputPrice: 2,
+	}
+	p, err := NewOllamaProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := withMetering(p, cfg).(*meteredProvider)
+	m.tracker = usage.NewTracker()
+
+	ctx := WithToolName(context.Background(), "codeloom_context")
+	out, err := m.Generate(ctx, []Message{{Role: RoleUser, Content: "explain"}})
+	if err != nil || out != "done" {
+		t.Fatalf("Generate() = %q, %v", out, err)
+	}
+
+	day := m.tracker.Days(1)[0]
+	got := day.ByTool["codeloom_context"]
+	if got.Requests != 1 || got.Retries != 1 || got.InputTokens != 120 || got.OutputTokens != 30 || got.EstimatedRequests != 0 {
+		t.Errorf("unexpected usage %+v", got)
+	}
+	if want := (120*1.0 + 30*2.0) / 1e6; got.CostUSD != want {
+		t.Errorf("expected cost %v, got %v", want, got.CostUSD)
+	}
+	if day.ByProvider["llm:ollama/qwen"].Requests != 1 {
+		t.Errorf("unexpected provider breakdown %+v", day.ByProvider)
+	}
+}
+
+func TestMeteredProviderEstimatesStreamUsage(t *testing.T) {
+	m := &meteredProvider{Provider: &fakeProvider{name: "fake"}, tracker: usage.NewTracker()}
+	ch, err := m.Stream(context.Background(), []Message{{Role: RoleUser, Content: "12345678"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+
+	totals := m.tracker.Days(1)[0].ByTool[usage.Background]
+	if totals.Requests != 1 || totals.InputTokens != 2 || totals.OutputTokens != 1 || totals.EstimatedRequests != 1 {
+		t.Errorf("unexpected estimated usage %+v", totals)
+	}
+}
@@ -7,12 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/heefoo/codeloom/internal/config"
 	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/usage"
 )
 
 type OllamaProvider struct {
@@ -35,6 +36,7 @@ type ollamaMessage struct {
 	Role      string           `json:"role"`
 	Content   string           `json:"content"`
 	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // the tool a "tool" message answers
 }
 
 type ollamaOptions struct {
@@ -67,6 +69,9 @@ type ollamaChatResponse struct {
 	Message    ollamaMessage `json:"message"`
 	Done       bool          `json:"done"`
 	DoneReason string        `json:"done_reason,omitempty"`
+
+	PromptEvalCount int64 `json:"prompt_eval_count,omitempty"`
+	EvalCount       int64 `json:"eval_count,omitempty"`
 }
 
 func NewOllamaProvider(cfg config.LLMConfig) (*OllamaProvider, error) {
@@ -102,13 +107,7 @@ func (p *OllamaProvider) Generate(ctx context.Context, messages []Message, opts
 		opt(options)
 	}
 
-	ollamaMessages := make([]ollamaMessage, len(messages))
-	for i, m := range messages {
-		ollamaMessages[i] = ollamaMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-		}
-	}
+	ollamaMessages := toOllamaMessages(messages)
 
 	req := ollamaChatRequest{
 		Model:    p.model,
@@ -142,25 +141,42 @@ func (p *OllamaProvider) Generate(ctx context.Context, messages []Message, opts
 		if err != nil {
 			return "", fmt.Errorf("ollama error: %s - failed to read response body: %v", resp.Status, err)
 		}
-		return "", fmt.Errorf("ollama error: %s - %s", resp.Status, string(body))
+		return "", ratelimit.NewStatusError(resp, fmt.Sprintf("ollama error: %s - %s", resp.Status, string(body)))
 	}
 
 	var chatResp ollamaChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return "", fmt.Errorf("ollama decode error: %w", err)
 	}
+	usage.Report(ctx, chatResp.PromptEvalCount, chatResp.EvalCount)
 
 	return chatResp.Message.Content, nil
 }
 
-func (p *OllamaProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
-	ollamaMessages := make([]ollamaMessage, len(messages))
+// toOllamaMessages converts a conversation. Ollama has no call IDs, so tool
+// results are matched to calls by order and tool name.
+func toOllamaMessages(messages []Message) []ollamaMessage {
+	out := make([]ollamaMessage, len(messages))
 	for i, m := range messages {
-		ollamaMessages[i] = ollamaMessage{
+		out[i] = ollamaMessage{
 			Role:    string(m.Role),
 			Content: m.Content,
 		}
+		if m.Role == RoleTool {
+			out[i].ToolName = m.Name
+		}
+		for _, tc := range m.ToolCalls {
+			args, _ := json.Marshal(toolArguments(tc.Arguments))
+			out[i].ToolCalls = append(out[i].ToolCalls, ollamaToolCall{
+				Function: ollamaToolCallFunction{Name: tc.Name, Arguments: args},
+			})
+		}
 	}
+	return out
+}
+
+func (p *OllamaProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	ollamaMessages := toOllamaMessages(messages)
 
 	ollamaTools := make([]ollamaTool, len(tools))
 	for i, t := range tools {
@@ -207,13 +223,14 @@ func (p *OllamaProvider) GenerateWithTools(ctx context.Context, messages []Messa
 		if err != nil {
 			return nil, fmt.Errorf("ollama error: %s - failed to read response body: %v", resp.Status, err)
 		}
-		return nil, fmt.Errorf("ollama error: %s - %s", resp.Status, string(body))
+		return nil, ratelimit.NewStatusError(resp, fmt.Sprintf("ollama error: %s - %s", resp.Status, string(body)))
 	}
 
 	var chatResp ollamaChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
 		return nil, fmt.Errorf("ollama decode error: %w", err)
 	}
+	usage.Report(ctx, chatResp.PromptEvalCount, chatResp.EvalCount)
 
 	result := &ToolCallResponse{
 		Content: chatResp.Message.Content,
@@ -239,13 +256,7 @@ func (p *OllamaProvider) Stream(ctx context.Context, messages []Message, opts ..
 		opt(options)
 	}
 
-	ollamaMessages := make([]ollamaMessage, len(messages))
-	for i, m := range messages {
-		ollamaMessages[i] = ollamaMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-		}
-	}
+	ollamaMessages := toOllamaMessages(messages)
 
 	req := ollamaChatRequest{
 		Model:    p.model,
@@ -279,7 +290,7 @@ func (p *OllamaProvider) Stream(ctx context.Context, messages []Message, opts ..
 		if err != nil {
 			return nil, fmt.Errorf("ollama error: %s - failed to read response body: %v", resp.Status, err)
 		}
-		return nil, fmt.Errorf("ollama error: %s - %s", resp.Status, string(body))
+		return nil, ratelimit.NewStatusError(resp, fmt.Sprintf("ollama error: %s - %s", resp.Status, string(body)))
 	}
 
 	// Create channel after all error checks to avoid leak on error paths
@@ -300,14 +311,14 @@ func (p *OllamaProvider) Stream(ctx context.Context, messages []Message, opts ..
 			if !scanner.Scan() {
 				// Check for scanner errors
 				if err := scanner.Err(); err != nil {
-					log.Printf("ollama stream error: scanner error: %v", err)
+					logger().Error("stream error: scanner error", "provider", "ollama", "error", err)
 				}
 				return
 			}
 
 			var chatResp ollamaChatResponse
 			if err := json.Unmarshal(scanner.Bytes(), &chatResp); err != nil {
-				log.Printf("ollama stream error: failed to unmarshal JSON: %v", err)
+				logger().Error("stream error: failed to unmarshal JSON", "provider", "ollama", "error", err)
 				continue
 			}
 			if chatResp.Message.Content != "" {
@@ -5,11 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"time"
 
 	"github.com/heefoo/codeloom/internal/config"
 	"github.com/heefoo/codeloom/internal/httpclient"
+	"github.com/heefoo/codeloom/internal/ratelimit"
+	"github.com/heefoo/codeloom/internal/usage"
 	openai "github.com/sashabaranov/go-openai"
 )
 
@@ -34,7 +35,7 @@ func NewOpenAIProvider(cfg config.LLMConfig) (*OpenAIProvider, error) {
 	if timeout == 0 {
 		timeout = 120 * time.Second // Default 2 minute timeout
 	}
-	clientCfg.HTTPClient = httpclient.GetSharedClient(timeout)
+	clientCfg.HTTPClient = ratelimit.WithRetryAfter(httpclient.GetSharedClient(timeout))
 
 	client := openai.NewClientWithConfig(clientCfg)
 
@@ -65,17 +66,7 @@ func (p *OpenAIProvider) Generate(ctx context.Context, messages []Message, opts
 		opt(options)
 	}
 
-	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, m := range messages {
-		openaiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-			Name:    m.Name,
-		}
-		if m.ToolCallID != "" {
-			openaiMessages[i].ToolCallID = m.ToolCallID
-		}
-	}
+	openaiMessages := toOpenAIMessages(messages)
 
 	req := openai.ChatCompletionRequest{
 		Model:       p.model,
@@ -88,6 +79,7 @@ func (p *OpenAIProvider) Generate(ctx context.Context, messages []Message, opts
 	if err != nil {
 		return "", fmt.Errorf("openai completion error: %w", err)
 	}
+	usage.Report(ctx, int64(resp.Usage.PromptTokens), int64(resp.Usage.CompletionTokens))
 
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no completion choices returned")
@@ -96,18 +88,36 @@ func (p *OpenAIProvider) Generate(ctx context.Context, messages []Message, opts
 	return resp.Choices[0].Message.Content, nil
 }
 
-func (p *OpenAIProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
-	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
+// toOpenAIMessages converts a conversation, including assistant tool calls
+// and the tool results that answer them by ID
+func toOpenAIMessages(messages []Message) []openai.ChatCompletionMessage {
+	out := make([]openai.ChatCompletionMessage, len(messages))
 	for i, m := range messages {
-		openaiMessages[i] = openai.ChatCompletionMessage{
+		out[i] = openai.ChatCompletionMessage{
 			Role:    string(m.Role),
 			Content: m.Content,
-			Name:    m.Name,
 		}
-		if m.ToolCallID != "" {
-			openaiMessages[i].ToolCallID = m.ToolCallID
+		if m.Role == RoleTool {
+			out[i].ToolCallID = m.ToolCallID
+		} else {
+			out[i].Name = m.Name
+		}
+		for _, tc := range m.ToolCalls {
+			out[i].ToolCalls = append(out[i].ToolCalls, openai.ToolCall{
+				ID:   tc.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      tc.Name,
+					Arguments: tc.Arguments,
+				},
+			})
 		}
 	}
+	return out
+}
+
+func (p *OpenAIProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	openaiMessages := toOpenAIMessages(messages)
 
 	openaiTools := make([]openai.Tool, len(tools))
 	for i, t := range tools {
@@ -137,6 +147,7 @@ func (p *OpenAIProvider) GenerateWithTools(ctx context.Context, messages []Messa
 	if err != nil {
 		return nil, fmt.Errorf("openai completion error: %w", err)
 	}
+	usage.Report(ctx, int64(resp.Usage.PromptTokens), int64(resp.Usage.CompletionTokens))
 
 	if len(resp.Choices) == 0 {
 		return nil, fmt.Errorf("no completion choices returned")
@@ -167,14 +178,7 @@ func (p *OpenAIProvider) Stream(ctx context.Context, messages []Message, opts ..
 		opt(options)
 	}
 
-	openaiMessages := make([]openai.ChatCompletionMessage, len(messages))
-	for i, m := range messages {
-		openaiMessages[i] = openai.ChatCompletionMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-			Name:    m.Name,
-		}
-	}
+	openaiMessages := toOpenAIMessages(messages)
 
 	req := openai.ChatCompletionRequest{
 		Model:       p.model,
@@ -204,7 +208,7 @@ func (p *OpenAIProvider) Stream(ctx context.Context, messages []Message, opts ..
 					return
 				}
 				if err != nil {
-					log.Printf("openai stream error: %v", err)
+					logger().Error("stream error", "provider", "openai", "error", err)
 					return
 				}
 				if len(resp.Choices) > 0 {
@@ -2,9 +2,12 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 
 	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/logging"
 )
 
 type Role string
@@ -16,11 +19,15 @@ const (
 	RoleTool      Role = "tool"
 )
 
+// Message is one turn of a conversation. An assistant message that called
+// tools carries the calls in ToolCalls; each result follows as a RoleTool
+// message whose ToolCallID (and Name) identify the call it answers.
 type Message struct {
-	Role       Role   `json:"role"`
-	Content    string `json:"content"`
-	Name       string `json:"name,omitempty"`
-	ToolCallID string `json:"tool_call_id,omitempty"`
+	Role       Role       `json:"role"`
+	Content    string     `json:"content"`
+	Name       string     `json:"name,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 }
 
 type Tool struct {
@@ -35,6 +42,18 @@ type ToolCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// toolArguments decodes a call's JSON arguments for providers that take them
+// as an object. Missing or malformed arguments become an empty object.
+func toolArguments(arguments string) map[string]interface{} {
+	args := map[string]interface{}{}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil || args == nil {
+			return map[string]interface{}{}
+		}
+	}
+	return args
+}
+
 type GenerateOptions struct {
 	Temperature   float32
 	MaxTokens     int
@@ -75,7 +94,27 @@ type ToolCallResponse struct {
 	ToolCalls []ToolCall `json:"tool_calls"`
 }
 
+// logger returns the component logger for providers. It is resolved on each
+// call so providers pick up the logger installed by logging.Setup.
+func logger() *slog.Logger {
+	return logging.Component(nil, "llm")
+}
+
+// NewProvider creates the configured provider, wrapped so that each request
+// is rate-limited, retried, metered and recorded as a trace span. With
+// [[llm.providers]] set it returns a fallback chain over them instead.
 func NewProvider(cfg config.LLMConfig) (Provider, error) {
+	if len(cfg.Providers) > 0 {
+		return newChain(cfg)
+	}
+	p, err := newSingleProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return withTracing(withMetering(p, cfg)), nil
+}
+
+func newSingleProvider(cfg config.LLMConfig) (Provider, error) {
 	switch cfg.Provider {
 	case "openai", "openai-compatible":
 		return NewOpenAIProvider(cfg)
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedProvider wraps a Provider with a span and a debug log line per LLM
+// request.
+type tracedProvider struct {
+	Provider
+}
+
+func withTracing(p Provider) Provider {
+	return &tracedProvider{Provider: p}
+}
+
+func (t *tracedProvider) Generate(ctx context.Context, messages []Message, opts ...Option) (string, error) {
+	ctx, span := telemetry.Start(ctx, "llm.Generate",
+		attribute.String("llm.provider", t.Name()),
+		attribute.Int("llm.messages", len(messages)),
+	)
+	start := time.Now()
+	out, err := t.Provider.Generate(ctx, messages, opts...)
+	logger().Debug("llm request", "provider", t.Name(), "op", "generate", "duration", time.Since(start), "error", err)
+	span.SetAttributes(attribute.Int("llm.response_length", len(out)))
+	telemetry.End(span, err)
+	return out, err
+}
+
+func (t *tracedProvider) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolCallResponse, error) {
+	ctx, span := telemetry.Start(ctx, "llm.GenerateWithTools",
+		attribute.String("llm.provider", t.Name()),
+		attribute.Int("llm.messages", len(messages)),
+		attribute.Int("llm.tools", len(tools)),
+	)
+	start := time.Now()
+	resp, err := t.Provider.GenerateWithTools(ctx, messages, tools)
+	logger().Debug("llm request", "provider", t.Name(), "op", "generate_with_tools", "duration", time.Since(start), "error", err)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("llm.tool_calls", len(resp.ToolCalls)))
+	}
+	telemetry.End(span, err)
+	return resp, err
+}
+
+// Stream keeps the span open until the underlying channel is drained.
+func (t *tracedProvider) Stream(ctx context.Context, messages []Message, opts ...Option) (<-chan string, error) {
+	ctx, span := telemetry.Start(ctx, "llm.Stream",
+		attribute.String("llm.provider", t.Name()),
+		attribute.Int("llm.messages", len(messages)),
+	)
+	ch, err := t.Provider.Stream(ctx, messages, opts...)
+	if err != nil {
+		telemetry.End(span, err)
+		return nil, err
+	}
+
+	out := make(chan string)
+	go func() {
+		var streamErr error
+		defer close(out)
+		defer func() { telemetry.End(span, streamErr) }()
+		for chunk := range ch {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				streamErr = ctx.Err()
+				// Drain so the provider goroutine can exit
+				for range ch {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
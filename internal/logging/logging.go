@@ -0,0 +1,164 @@
+// Package logging builds the structured slog logger shared by codeloom
+// components.
+//
+// Components derive their logger with Component, which tags records with a
+// "component" attribute and applies any per-component level override from
+// the configuration.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+// ComponentKey is the attribute key identifying the emitting component.
+const ComponentKey = "component"
+
+// Options selects the sink for Setup.
+type Options struct {
+	// Stdio is set when the MCP protocol owns stdout. Logs then go to the
+	// configured file or stderr, never stdout.
+	Stdio bool
+}
+
+// Setup builds the logger described by cfg, installs it as the slog and
+// standard library default, and returns a function that closes any file sink.
+func Setup(cfg config.LoggingConfig, opts Options) (*slog.Logger, func() error, error) {
+	var (
+		w      io.Writer = os.Stderr
+		closer           = func() error { return nil }
+	)
+
+	switch strings.ToLower(cfg.File) {
+	case "", "stderr":
+	case "stdout":
+		if !opts.Stdio {
+			w = os.Stdout
+		}
+	default:
+		rf, err := OpenRotatingFile(cfg.File, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = rf
+		closer = rf.Close
+	}
+
+	logger, err := New(cfg, w)
+	if err != nil {
+		closer()
+		return nil, nil, err
+	}
+
+	// slog.SetDefault also routes the standard log package through the
+	// handler, so remaining log.Printf calls honour the same sink.
+	slog.SetDefault(logger)
+	return logger, closer, nil
+}
+
+// New builds a logger writing to w in the configured format.
+func New(cfg config.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	base, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]slog.Level, len(cfg.Components))
+	for name, lvl := range cfg.Components {
+		parsed, err := ParseLevel(lvl)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", name, err)
+		}
+		overrides[name] = parsed
+	}
+
+	// The inner handler accepts everything; componentHandler does the filtering.
+	handlerOpts := &slog.HandlerOptions{Level: slog.Level(-8)}
+	var inner slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		inner = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		inner = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", cfg.Format)
+	}
+
+	return slog.New(&componentHandler{
+		inner:     inner,
+		level:     base,
+		overrides: overrides,
+	}), nil
+}
+
+// ParseLevel converts a level name (debug, info, warn, error) to a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level: %s", s)
+	}
+}
+
+// Component returns l tagged with the component name. A nil l uses the
+// current slog default.
+func Component(l *slog.Logger, name string) *slog.Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return l.With(ComponentKey, name)
+}
+
+// Discard returns a logger that drops every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// componentHandler filters records by level, using a per-component override
+// once a logger has been tagged through Component.
+type componentHandler struct {
+	inner     slog.Handler
+	level     slog.Level
+	overrides map[string]slog.Level
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.inner = h.inner.WithAttrs(attrs)
+	for _, a := range attrs {
+		if a.Key != ComponentKey {
+			continue
+		}
+		if lvl, ok := h.overrides[a.Value.String()]; ok {
+			nh.level = lvl
+		}
+	}
+	return &nh
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	nh := *h
+	nh.inner = h.inner.WithGroup(name)
+	return &nh
+}
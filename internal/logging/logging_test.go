@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+)
+
+func TestComponentLevelOverrides(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.LoggingConfig{
+		Level:      "warn",
+		Format:     "text",
+		Components: map[string]string{"indexer": "debug", "graph": "error"},
+	}, &buf)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	Component(logger, "indexer").Debug("indexer debug")
+	Component(logger, "graph").Warn("graph warn")
+	Component(logger, "watcher").Info("watcher info")
+	Component(logger, "watcher").Warn("watcher warn")
+
+	out := buf.String()
+	if !strings.Contains(out, "indexer debug") {
+		t.Errorf("expected indexer debug record with override, got:\n%s", out)
+	}
+	if strings.Contains(out, "graph warn") {
+		t.Errorf("graph warn should be filtered by error override, got:\n%s", out)
+	}
+	if strings.Contains(out, "watcher info") {
+		t.Errorf("watcher info should be filtered by base level, got:\n%s", out)
+	}
+	if !strings.Contains(out, "watcher warn") || !strings.Contains(out, "component=watcher") {
+		t.Errorf("expected tagged watcher warn record, got:\n%s", out)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.LoggingConfig{Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	Component(logger, "mcp").Info("http_request", "status", 200)
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if rec["msg"] != "http_request" || rec["component"] != "mcp" || rec["status"] != float64(200) {
+		t.Errorf("unexpected record: %v", rec)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	if _, err := New(config.LoggingConfig{Level: "loud"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+	if _, err := New(config.LoggingConfig{Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := New(config.LoggingConfig{Components: map[string]string{"graph": "chatty"}}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unknown component level")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "codeloom.log")
+	rf, err := OpenRotatingFile(path, 1, 2)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer rf.Close()
+
+	chunk := bytes.Repeat([]byte("x"), 600*1024)
+	for i := 0; i < 4; i++ {
+		if _, err := rf.Write(chunk); err != nil {
+			t.Fatalf("write %d failed: %v", i, err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected at most 2 backups, found %s.3", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1024*1024 {
+		t.Errorf("active file exceeds max size: %d bytes", info.Size())
+	}
+}
+
+func TestSetupStdioNeverUsesStdout(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = origStdout }()
+
+	path := filepath.Join(t.TempDir(), "codeloom.log")
+	for _, file := range []string{"stdout", path} {
+		logger, closer, err := Setup(config.LoggingConfig{File: file}, Options{Stdio: true})
+		if err != nil {
+			t.Fatalf("Setup(%q) failed: %v", file, err)
+		}
+		logger.Info("hello from stdio mode")
+		slog.Info("via default")
+		closer()
+	}
+
+	w.Close()
+	var captured bytes.Buffer
+	captured.ReadFrom(r)
+	if captured.Len() != 0 {
+		t.Errorf("stdio mode wrote to stdout: %q", captured.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "hello from stdio mode") {
+		t.Errorf("expected log file to contain record, got %q", data)
+	}
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// referenceEdgeTypes maps the captures of a references query to the edges
+// they become
+var referenceEdgeTypes = map[string]EdgeType{
+	"reference.class":          EdgeTypeUses,
+	"reference.type":           EdgeTypeUses,
+	"reference.extends":        EdgeTypeExtends,
+	"reference.implementation": EdgeTypeImplements,
+	"reference.table":          EdgeTypeUses,
+}
+
+// extractQueryEdges adds the calls and references found by a language's
+// queries. Calls are made by the function or method they are in, as the
+// extractors walking the tree attribute them; references by the innermost
+// node they are in. Calls whose name is the name of a definition, such as
+// the head of an Elixir def, are declarations rather than calls.
+func (p *Parser) extractQueryEdges(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte, result *ParseResult, funcRanges []lineRange, defNames map[nodeKey]bool, symbols SymbolTable) {
+	if spec.calls != nil {
+		for _, m := range firstMatches(spec.calls.matches(root, content), "reference.call") {
+			if name := m.node("name"); name != nil && defNames[keyOf(name)] {
+				continue
+			}
+			callee := spec.calleeName(m, content)
+			callerID := ""
+			line := int(m.node("reference.call").StartPoint().Row) + 1
+			for _, fr := range funcRanges {
+				if line >= fr.start && line <= fr.end {
+					callerID = fr.id
+					break
+				}
+			}
+			if callee == "" || callerID == "" {
+				continue
+			}
+			result.Edges = append(result.Edges, CodeEdge{
+				FromID:   callerID,
+				ToID:     resolveCallee(callee, filePath, symbols),
+				EdgeType: EdgeTypeCalls,
+			})
+		}
+	}
+
+	if spec.references == nil {
+		return
+	}
+	for _, m := range spec.references.matches(root, content) {
+		for capture, edgeType := range referenceEdgeTypes {
+			ref := m.node(capture)
+			if ref == nil {
+				continue
+			}
+			path := spec.namePath(m.captures["name"], content)
+			from := innermostNode(result.Nodes, filePath, int(ref.StartPoint().Row)+1)
+			if len(path) == 0 {
+				continue
+			}
+			// Tables are contracts, wherever they are created
+			target := ""
+			if capture == "reference.table" {
+				target = tableContract(path[len(path)-1])
+				if from == "" {
+					from = filePath
+				}
+			} else {
+				target = resolveCallee(strings.Join(path, "."), filePath, symbols)
+			}
+			if from == "" {
+				continue
+			}
+			result.Edges = append(result.Edges, CodeEdge{
+				FromID:   from,
+				ToID:     target,
+				EdgeType: edgeType,
+			})
+		}
+	}
+}
+
+// firstMatches keeps one match per node captured as capture: the one of the
+// earliest pattern
+func firstMatches(matches []queryMatch, capture string) []queryMatch {
+	index := make(map[nodeKey]int)
+	var kept []queryMatch
+	for _, m := range matches {
+		n := m.node(capture)
+		if n == nil {
+			continue
+		}
+		if i, ok := index[keyOf(n)]; ok {
+			if m.pattern < kept[i].pattern {
+				kept[i] = m
+			}
+			continue
+		}
+		index[keyOf(n)] = len(kept)
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// calleeName returns what a call match calls: its @name, prefixed by its
+// @receiver unless that is the current object
+func (spec *LanguageSpec) calleeName(m queryMatch, content []byte) string {
+	if spec.callee != nil {
+		return spec.callee(m.node("reference.call"), content)
+	}
+	name := nodeText(m.node("name"), content)
+	if name == "" {
+		return ""
+	}
+	receivers := m.captures["receiver"]
+	if len(receivers) == 0 {
+		return spec.normalizeName(name)
+	}
+	var parts []string
+	for _, r := range receivers {
+		parts = append(parts, nodeText(r, content))
+	}
+	return qualifiedCallee(spec.normalizeName(strings.Join(parts, ".")), name)
+}
+
+// normalizeName writes the language's name separators as "."
+func (spec *LanguageSpec) normalizeName(name string) string {
+	if len(spec.NameSeparators) == 0 {
+		return name
+	}
+	for _, sep := range spec.NameSeparators {
+		name = strings.ReplaceAll(name, sep, ".")
+	}
+	return strings.TrimPrefix(name, ".")
+}
+
+// innermostNode returns the ID of the smallest node of the file spanning
+// line, or ""
+func innermostNode(nodes []CodeNode, filePath string, line int) string {
+	id, span := "", 0
+	for _, n := range nodes {
+		if n.FilePath != filePath || n.ID == filePath || line < n.StartLine || line > n.EndLine {
+			continue
+		}
+		if id == "" || n.EndLine-n.StartLine < span {
+			id, span = n.ID, n.EndLine-n.StartLine
+		}
+	}
+	return id
+}
+
+// resolveCallee resolves a called or referenced name to a node ID: through
+// the symbol table when there is one, to an external node when the name is
+// qualified, and to a node of the same file otherwise
+func resolveCallee(callee string, filePath string, symbols SymbolTable) string {
+	if symbols != nil {
+		ctx := &ResolutionContext{FilePath: filePath}
+		if resolved, ok := symbols.Resolve(callee, ctx); ok {
+			return resolved
+		}
+	}
+
+	if strings.Contains(callee, ".") || strings.Contains(callee, "::") || strings.Contains(callee, "->") {
+		return fmt.Sprintf("external::%s", callee)
+	}
+
+	return fmt.Sprintf("%s::%s", filePath, callee)
+}
+
+// qualifiedCallee joins a call's receiver and name, dropping receivers that
+// refer to the current object
+func qualifiedCallee(receiver, name string) string {
+	receiver = strings.TrimSpace(receiver)
+	switch receiver {
+	case "", "self", "this", "$this", "super", "parent", "static", "base", "Self":
+		return name
+	}
+	return receiver + "." + name
+}
+
+// nodeText returns the source of n without surrounding whitespace, which
+// some grammars include in their tokens
+func nodeText(n *sitter.Node, content []byte) string {
+	if n == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content[n.StartByte():n.EndByte()]))
+}
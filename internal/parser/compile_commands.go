@@ -0,0 +1,469 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// C and C++ files are read with the flags their build compiles them with
+// when a compile_commands.json is found in their directory, one of its
+// parents up to the project root or a build directory in one of them, as
+// CMake, Bear and most build systems write it. Its entries give each
+// translation unit its language, include search paths and macros defined
+// with -D. A header takes the flags of the translation units that include
+// it, directly or through other headers, and is C++ when one of them is.
+// Without a compile database, a .h file is C++ when the sources around it
+// are mostly C++.
+
+// compileUnit is how a file is compiled
+type compileUnit struct {
+	lang      Language
+	std       string            // -std value, such as "c++17"
+	quote     []string          // -iquote directories, searched for #include "x.h" only
+	search    []string          // -I, -isystem and -idirafter directories, in order
+	defines   map[string]string // -D macros, with their values
+	undefined map[string]bool   // -U macros
+}
+
+// compileDB is a compile_commands.json, read
+type compileDB struct {
+	modTime time.Time
+	units   map[string]*compileUnit // by absolute file path
+
+	headersOnce sync.Once
+	headers     map[string]*compileUnit // units including each header
+}
+
+// compileDBs holds the compile databases a parser has read, and the project
+// roots that bound the search for them
+type compileDBs struct {
+	mu     sync.Mutex
+	roots  []string
+	byPath map[string]*compileDB
+}
+
+func newCompileDBs() *compileDBs {
+	return &compileDBs{byPath: make(map[string]*compileDB)}
+}
+
+// WithCompileCommands turns the lookup of compile_commands.json on or off;
+// it is on by default
+func WithCompileCommands(enabled bool) ParserOption {
+	return func(p *Parser) {
+		if enabled {
+			p.compileDBs = newCompileDBs()
+		} else {
+			p.compileDBs = nil
+		}
+	}
+}
+
+// AddProjectRoot bounds the search for the compile database of the files
+// under root to root. Files outside every root only use a database in their
+// own directory.
+func (p *Parser) AddProjectRoot(root string) {
+	if p.compileDBs == nil {
+		return
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	p.compileDBs.mu.Lock()
+	defer p.compileDBs.mu.Unlock()
+	for _, r := range p.compileDBs.roots {
+		if r == abs {
+			return
+		}
+	}
+	p.compileDBs.roots = append(p.compileDBs.roots, abs)
+}
+
+// compileUnitOf returns how a C or C++ file is compiled, or nil when no
+// compile database covers it
+func (p *Parser) compileUnitOf(filePath string) *compileUnit {
+	if p == nil || p.compileDBs == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil
+	}
+	db := p.compileDBs.find(filepath.Dir(abs))
+	if db == nil {
+		return nil
+	}
+	if u, ok := db.units[abs]; ok {
+		return u
+	}
+	db.headersOnce.Do(db.scanHeaders)
+	return db.headers[abs]
+}
+
+// find returns the compile database nearest to dir within its project root,
+// read once per change of the file
+func (c *compileDBs) find(dir string) *compileDB {
+	root := c.rootOf(dir)
+	path := ""
+	for d := dir; path == ""; d = filepath.Dir(d) {
+		for _, candidate := range []string{
+			filepath.Join(d, "compile_commands.json"),
+			filepath.Join(d, "build", "compile_commands.json"),
+		} {
+			if isFile(candidate) {
+				path = candidate
+				break
+			}
+		}
+		if d == root || filepath.Dir(d) == d {
+			break
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if db, ok := c.byPath[path]; ok && db.modTime.Equal(info.ModTime()) {
+		return db
+	}
+	db := readCompileDB(path)
+	db.modTime = info.ModTime()
+	c.byPath[path] = db
+	return db
+}
+
+// rootOf returns the outermost project root containing dir, or dir itself
+// when none does
+func (c *compileDBs) rootOf(dir string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	root := dir
+	for _, r := range c.roots {
+		if rel, err := filepath.Rel(r, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && len(r) < len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+// readCompileDB reads a compile database. Entries give their arguments as a
+// list or as a shell command line.
+func readCompileDB(path string) *compileDB {
+	db := &compileDB{units: make(map[string]*compileUnit)}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return db
+	}
+	var entries []struct {
+		Directory string   `json:"directory"`
+		File      string   `json:"file"`
+		Arguments []string `json:"arguments"`
+		Command   string   `json:"command"`
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return db
+	}
+	for _, e := range entries {
+		args := e.Arguments
+		if len(args) == 0 {
+			args = splitCommand(e.Command)
+		}
+		dir := e.Directory
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		file := e.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		file = filepath.Clean(file)
+		if _, ok := db.units[file]; !ok {
+			db.units[file] = parseCompileArgs(dir, file, args)
+		}
+	}
+	return db
+}
+
+// parseCompileArgs reads the flags of a compiler invocation
+func parseCompileArgs(dir, file string, args []string) *compileUnit {
+	u := &compileUnit{defines: make(map[string]string), undefined: make(map[string]bool)}
+	abs := func(p string) string {
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(dir, p)
+	}
+	explicit := Language("")
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		// value returns the value of a flag given joined (-Idir) or as the
+		// next argument (-I dir)
+		value := func(flag string) (string, bool) {
+			if arg == flag && i+1 < len(args) {
+				i++
+				return args[i], true
+			}
+			if rest, ok := strings.CutPrefix(arg, flag); ok && rest != "" {
+				return rest, true
+			}
+			return "", false
+		}
+		if v, ok := value("-iquote"); ok {
+			u.quote = append(u.quote, abs(v))
+		} else if v, ok := value("-isystem"); ok {
+			u.search = append(u.search, abs(v))
+		} else if v, ok := value("-idirafter"); ok {
+			u.search = append(u.search, abs(v))
+		} else if v, ok := value("-I"); ok {
+			u.search = append(u.search, abs(v))
+		} else if v, ok := value("-D"); ok {
+			name, val, found := strings.Cut(v, "=")
+			if !found {
+				val = "1"
+			}
+			u.defines[name] = val
+			delete(u.undefined, name)
+		} else if v, ok := value("-U"); ok {
+			delete(u.defines, v)
+			u.undefined[v] = true
+		} else if v, ok := strings.CutPrefix(arg, "-std="); ok {
+			u.std = v
+		} else if v, ok := value("-x"); ok {
+			switch v {
+			case "c", "c-header":
+				explicit = LangC
+			case "c++", "c++-header":
+				explicit = LangCPP
+			}
+		}
+	}
+
+	switch {
+	case explicit != "":
+		u.lang = explicit
+	case strings.Contains(u.std, "++"):
+		u.lang = LangCPP
+	case len(args) > 0 && strings.Contains(filepath.Base(args[0]), "++"):
+		u.lang = LangCPP
+	case languageOf(file) == LangCPP:
+		u.lang = LangCPP
+	default:
+		u.lang = LangC
+	}
+	return u
+}
+
+// splitCommand splits a shell command line into its arguments, honouring
+// quotes and backslashes
+func splitCommand(command string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	quote := byte(0)
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\\' && i+1 < len(command) && quote != '\'':
+			i++
+			arg.WriteByte(command[i])
+			inArg = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				arg.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// includeDirective matches #include "x.h" and #include <x.h>
+var includeDirective = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+
+// scanHeaders follows the includes of the translation units to the headers
+// they reach. Units searching the same directories in the same language
+// reach the same headers, so each such group is followed once. A header
+// reached by C and C++ units takes a C++ unit's flags.
+func (db *compileDB) scanHeaders() {
+	db.headers = make(map[string]*compileUnit)
+	includes := make(map[string][][2]string) // file -> its includes: delimiter, path
+	includesOf := func(file string) [][2]string {
+		if incs, ok := includes[file]; ok {
+			return incs
+		}
+		var incs [][2]string
+		if f, err := os.Open(file); err == nil {
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				if m := includeDirective.FindStringSubmatch(scanner.Text()); m != nil {
+					incs = append(incs, [2]string{m[1], m[2]})
+				}
+			}
+			f.Close()
+		}
+		includes[file] = incs
+		return incs
+	}
+
+	files := make([]string, 0, len(db.units))
+	for file := range db.units {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	groups := make(map[string][]string) // group -> its files
+	first := make(map[string]*compileUnit)
+	for _, file := range files {
+		u := db.units[file]
+		key := string(u.lang) + "\x00" + strings.Join(u.quote, "\x00") + "\x01" + strings.Join(u.search, "\x00")
+		if _, ok := first[key]; !ok {
+			first[key] = u
+		}
+		groups[key] = append(groups[key], file)
+	}
+	for key, files := range groups {
+		u := first[key]
+		seen := make(map[string]bool)
+		queue := append([]string(nil), files...)
+		for len(queue) > 0 {
+			file := queue[0]
+			queue = queue[1:]
+			for _, inc := range includesOf(file) {
+				header := u.resolveInclude(filepath.Dir(file), inc[1], inc[0] == `"`)
+				if header == "" || seen[header] {
+					continue
+				}
+				seen[header] = true
+				if _, isUnit := db.units[header]; !isUnit {
+					if prev, ok := db.headers[header]; !ok || (prev.lang != LangCPP && u.lang == LangCPP) {
+						db.headers[header] = u
+					}
+				}
+				queue = append(queue, header)
+			}
+		}
+	}
+}
+
+// resolveInclude returns the file an include names, searching the
+// including file's directory for quoted includes and then the unit's
+// include paths, or ""
+func (u *compileUnit) resolveInclude(dir, spec string, quoted bool) string {
+	var dirs []string
+	if quoted {
+		dirs = append(append([]string{dir}, u.quote...), u.search...)
+	} else {
+		dirs = u.search
+	}
+	for _, d := range dirs {
+		if target := filepath.Join(d, filepath.FromSlash(spec)); isFile(target) {
+			return target
+		}
+	}
+	return ""
+}
+
+// pathLike writes an absolute path relative to the working directory when
+// filePath is relative, as the IDs of the files it names are
+func pathLike(filePath, target string) string {
+	if filepath.IsAbs(filePath) {
+		return target
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return target
+	}
+	if rel, err := filepath.Rel(wd, target); err == nil {
+		return rel
+	}
+	return target
+}
+
+// cHeaderLanguage classifies a .h file: by the translation units including
+// it when a compile database covers it, and by the sources in its directory,
+// or in its parent's tree when it has none, otherwise
+func cHeaderLanguage(p *Parser, filePath string) Language {
+	if !strings.EqualFold(filepath.Ext(filePath), ".h") {
+		return LangC
+	}
+	if u := p.compileUnitOf(filePath); u != nil {
+		return u.lang
+	}
+	dir := filepath.Dir(filePath)
+	if lang, ok := headerDirs.Load(dir); ok {
+		return lang.(Language)
+	}
+	lang := LangC
+	c, cpp := countSources(dir, 0)
+	if c+cpp == 0 {
+		c, cpp = countSources(filepath.Dir(dir), 1)
+	}
+	if cpp > c {
+		lang = LangCPP
+	}
+	headerDirs.Store(dir, lang)
+	return lang
+}
+
+// headerDirs holds the language of the headers of each directory without a
+// compile database, which is the same for all of them
+var headerDirs sync.Map
+
+// countSources counts the C and C++ source files of a directory and, to
+// depth levels, its subdirectories
+func countSources(dir string, depth int) (c, cpp int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if depth > 0 && !strings.HasPrefix(e.Name(), ".") {
+				dc, dcpp := countSources(filepath.Join(dir, e.Name()), depth-1)
+				c, cpp = c+dc, cpp+dcpp
+			}
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".c":
+			c++
+		case ".cpp", ".cc", ".cxx":
+			cpp++
+		}
+	}
+	return c, cpp
+}
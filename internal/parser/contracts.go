@@ -0,0 +1,455 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Protobuf services, SQL schemas, GraphQL schemas and OpenAPI specs declare
+// contracts that code in other languages implements or uses. Each contract
+// gets a node shared by every file that refers to it, like package modules,
+// with a defined_in edge to the node declaring it. Storage removes the node
+// once no file declares it any more, keeping the edges of the code using it
+// for when a file declares it again:
+//
+//	rpc::<Service>.<Method>             a protobuf rpc
+//	table::<table>                      a SQL table or view, lowercased
+//	graphql::<Query|Mutation|...>.<f>   a field of a GraphQL root type
+//	http::<METHOD> <path>               an API operation, its path parameters
+//	                                    written as {}
+//
+// Code is linked to the contracts it implements or uses by convention:
+// gRPC servers (a Go struct embedding UnimplementedXServer, a Python
+// XServicer, a Java XImplBase, a C# X.XBase or a Ruby X::Service subclass)
+// implement the rpcs of their methods, GraphQL resolvers (a method of a
+// Query, queryResolver or Mutation object) implement their field, string
+// literals holding SQL use the tables they read or write, GraphQL operations
+// use the fields they select, and routes registered with an HTTP verb
+// implement the operation. The edges point at the contract nodes, so they
+// resolve whichever file declares the contract.
+
+const (
+	rpcContractPrefix     = "rpc::"
+	tableContractPrefix   = "table::"
+	graphqlContractPrefix = "graphql::"
+	httpContractPrefix    = "http::"
+)
+
+// addContracts adds the contract nodes a file declares, with defined_in
+// edges to the nodes declaring them
+func (p *Parser) addContracts(spec *LanguageSpec, filePath string, result *ParseResult) {
+	if spec.contract == nil {
+		return
+	}
+	shared := make(map[string]bool)
+	for _, n := range result.Nodes {
+		if n.FilePath != filePath {
+			continue
+		}
+		id := spec.contract(&n)
+		if id == "" {
+			continue
+		}
+		if !shared[id] {
+			shared[id] = true
+			result.Nodes = append(result.Nodes, CodeNode{
+				ID:       id,
+				Name:     contractName(id),
+				NodeType: n.NodeType,
+				Language: spec.Name,
+				arity:    -1,
+			})
+		}
+		if p.extractEdges {
+			result.Edges = append(result.Edges, CodeEdge{FromID: id, ToID: n.ID, EdgeType: EdgeTypeDefinedIn})
+		}
+	}
+}
+
+// contractName returns a contract ID without its prefix
+func contractName(id string) string {
+	if i := strings.Index(id, "::"); i >= 0 {
+		return id[i+2:]
+	}
+	return id
+}
+
+// tableContract returns the contract of a table name as written, which may
+// be qualified by a schema or quoted
+func tableContract(name string) string {
+	name = strings.Trim(name, "\"`[]")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = strings.Trim(name[i+1:], "\"`[]")
+	}
+	if name == "" {
+		return ""
+	}
+	return tableContractPrefix + strings.ToLower(name)
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]*\}|<[^>]*>|:\w+`)
+
+// httpContract returns the contract of an operation on a path, whose
+// parameters may be written {id}, <id> or :id
+func httpContract(method, path string) string {
+	path = pathParam.ReplaceAllString(path, "{}")
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return httpContractPrefix + strings.ToUpper(method) + " " + path
+}
+
+// localName returns a node's ID without its file
+func localName(n *CodeNode) string {
+	return strings.TrimPrefix(n.ID, n.FilePath+"::")
+}
+
+// linkContracts adds the edges from the file's code to the contracts it
+// implements or uses
+func (p *Parser) linkContracts(root *sitter.Node, filePath string, content []byte, result *ParseResult) {
+	l := &contractLinker{result: result, seen: make(map[CodeEdge]bool)}
+	for _, e := range result.Edges {
+		l.seen[e] = true
+	}
+	l.linkServers(filePath)
+	l.linkResolvers(filePath)
+	l.linkStrings(root, filePath, content)
+}
+
+type contractLinker struct {
+	result *ParseResult
+	seen   map[CodeEdge]bool
+}
+
+func (l *contractLinker) add(from, to string, edgeType EdgeType) {
+	e := CodeEdge{FromID: from, ToID: to, EdgeType: edgeType}
+	if from == "" || to == "" || l.seen[e] {
+		return
+	}
+	l.seen[e] = true
+	l.result.Edges = append(l.result.Edges, e)
+}
+
+var (
+	goGRPCServer  = regexp.MustCompile(`\bUnimplemented(\w+)Server\b`)
+	grpcServicer  = regexp.MustCompile(`^(\w+)Servicer$`)
+	grpcImplBase  = regexp.MustCompile(`^(\w+?)(?:Coroutine)?ImplBase$`)
+	grpcNoService = map[string]bool{"__init__": true, "initialize": true, "constructor": true, "init": true}
+)
+
+// linkServers links the methods of gRPC servers to the rpcs they implement
+func (l *contractLinker) linkServers(filePath string) {
+	for _, n := range l.result.Nodes {
+		if n.FilePath != filePath || (n.NodeType != NodeTypeClass && n.NodeType != NodeTypeStruct) {
+			continue
+		}
+		service := grpcService(n, l.result.Edges)
+		if service == "" {
+			continue
+		}
+		prefix := n.ID + "."
+		for _, m := range l.result.Nodes {
+			rest, ok := strings.CutPrefix(m.ID, prefix)
+			if !ok || strings.Contains(rest, ".") || (m.NodeType != NodeTypeMethod && m.NodeType != NodeTypeFunction) {
+				continue
+			}
+			if strings.HasPrefix(rest, "_") || grpcNoService[rest] || rest == n.Name {
+				continue
+			}
+			if n.Language == LangGo && !unicode.IsUpper([]rune(rest)[0]) {
+				continue
+			}
+			l.add(m.ID, rpcContractPrefix+service+"."+upperCamel(rest), EdgeTypeImplements)
+		}
+	}
+}
+
+// grpcService returns the service a class or struct serves, or ""
+func grpcService(n CodeNode, edges []CodeEdge) string {
+	if n.Language == LangGo {
+		if m := goGRPCServer.FindStringSubmatch(n.Content); m != nil {
+			return m[1]
+		}
+		return ""
+	}
+	for _, e := range edges {
+		if e.FromID != n.ID || (e.EdgeType != EdgeTypeExtends && e.EdgeType != EdgeTypeImplements) {
+			continue
+		}
+		target := e.ToID
+		if i := strings.LastIndex(target, "::"); i >= 0 {
+			target = target[i+2:]
+		}
+		parts := strings.Split(target, ".")
+		last := parts[len(parts)-1]
+		if m := grpcServicer.FindStringSubmatch(last); m != nil {
+			return m[1]
+		}
+		if m := grpcImplBase.FindStringSubmatch(last); m != nil {
+			return m[1]
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		outer := parts[len(parts)-2]
+		if last == outer+"Base" || (last == "Service" && n.Language == LangRuby) {
+			return outer
+		}
+	}
+	return ""
+}
+
+// graphqlRoots maps the names of resolver objects to the root type whose
+// fields they resolve
+var graphqlRoots = map[string]string{
+	"query":                 "Query",
+	"mutation":              "Mutation",
+	"subscription":          "Subscription",
+	"queryresolver":         "Query",
+	"mutationresolver":      "Mutation",
+	"subscriptionresolver":  "Subscription",
+	"queryresolvers":        "Query",
+	"mutationresolvers":     "Mutation",
+	"subscriptionresolvers": "Subscription",
+}
+
+// linkResolvers links GraphQL resolvers to the fields they resolve
+func (l *contractLinker) linkResolvers(filePath string) {
+	for _, n := range l.result.Nodes {
+		if n.FilePath != filePath || (n.NodeType != NodeTypeFunction && n.NodeType != NodeTypeMethod) {
+			continue
+		}
+		path := strings.Split(localName(&n), ".")
+		if len(path) < 2 {
+			continue
+		}
+		root := graphqlRoots[strings.ToLower(path[len(path)-2])]
+		if root == "" {
+			continue
+		}
+		field := path[len(path)-1]
+		if rest, ok := strings.CutPrefix(field, "resolve_"); ok {
+			field = rest
+		} else if rest, ok := strings.CutPrefix(field, "resolve"); ok && rest != "" && unicode.IsUpper([]rune(rest)[0]) {
+			field = rest
+		}
+		if field == "" || strings.HasPrefix(field, "_") {
+			continue
+		}
+		l.add(n.ID, graphqlContractPrefix+root+"."+lowerCamel(field), EdgeTypeImplements)
+	}
+}
+
+var (
+	sqlStatement  = regexp.MustCompile(`(?is)^\s*(select|insert|update|delete|with|merge|replace)\s`)
+	sqlClause     = regexp.MustCompile(`(?i)\b(?:where|values|set|join|group\s+by|order\s+by|limit|returning)\b|[*=?;]|\$\d`)
+	sqlTable      = regexp.MustCompile(`(?i)\b(?:from|join|into)\s+((?:["\x60]?\w+["\x60]?\.)?["\x60]?\w+["\x60]?)`)
+	sqlUpdate     = regexp.MustCompile(`(?i)\bupdate\s+((?:["\x60]?\w+["\x60]?\.)?["\x60]?\w+["\x60]?)\s+set\b`)
+	gqlOperation  = regexp.MustCompile(`^\s*(query|mutation|subscription)\b[^{]*\{`)
+	gqlSelection  = regexp.MustCompile(`\.\.\.|[A-Za-z_]\w*|"(?:[^"\\]|\\.)*"|[{}():@$]`)
+	verbRoute     = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\s+(/\S*)$`)
+	trailingIdent = regexp.MustCompile(`(\w+)\s*$`)
+	httpVerbs     = map[string]bool{"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true}
+	sqlKeywords   = map[string]bool{"select": true, "where": true, "set": true, "values": true, "lateral": true, "unnest": true}
+)
+
+// linkStrings links the string literals of the file holding SQL, GraphQL
+// operations or routes to their contracts
+func (l *contractLinker) linkStrings(root *sitter.Node, filePath string, content []byte) {
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if strings.Contains(n.Type(), "string") && n.IsNamed() {
+			l.linkString(n, filePath, content)
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+}
+
+func (l *contractLinker) linkString(n *sitter.Node, filePath string, content []byte) {
+	text := unquoteLiteral(nodeText(n, content))
+	if text == "" {
+		return
+	}
+	line := int(n.StartPoint().Row) + 1
+	from := innermostNode(l.result.Nodes, filePath, line)
+	if from == "" {
+		from = filePath
+	}
+
+	// Lowercase statements need a clause besides the table, to tell them
+	// from prose such as "select an option from the list"
+	if m := sqlStatement.FindStringSubmatch(text); m != nil && (m[1] == strings.ToUpper(m[1]) || sqlClause.MatchString(text)) {
+		for _, re := range []*regexp.Regexp{sqlTable, sqlUpdate} {
+			for _, m := range re.FindAllStringSubmatch(text, -1) {
+				if sqlKeywords[strings.ToLower(m[1])] {
+					continue
+				}
+				l.add(from, tableContract(m[1]), EdgeTypeUses)
+			}
+		}
+		return
+	}
+	if m := gqlOperation.FindStringSubmatch(text); m != nil {
+		root := strings.ToUpper(m[1][:1]) + m[1][1:]
+		for _, field := range selectedFields(text[len(m[0])-1:]) {
+			l.add(from, graphqlContractPrefix+root+"."+field, EdgeTypeUses)
+		}
+		return
+	}
+	if m := verbRoute.FindStringSubmatch(text); m != nil {
+		l.add(l.handlerOf(n, from, filePath, line), httpContract(m[1], m[2]), EdgeTypeImplements)
+		return
+	}
+	if strings.HasPrefix(text, "/") && !strings.ContainsAny(text, " \n") {
+		if method := routeMethod(n, content); method != "" {
+			l.add(l.handlerOf(n, from, filePath, line), httpContract(method, text), EdgeTypeImplements)
+		}
+	}
+}
+
+// handlerOf returns the function a route is registered for: the one it is
+// in, or the one a decorator holding it decorates
+func (l *contractLinker) handlerOf(n *sitter.Node, from, filePath string, line int) string {
+	decorated := false
+	for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+		if t := parent.Type(); t == "decorator" || t == "attribute" || t == "attribute_list" {
+			decorated = true
+			break
+		}
+	}
+	if !decorated {
+		return from
+	}
+	best := ""
+	bestLine := 0
+	for _, c := range l.result.Nodes {
+		if c.FilePath != filePath || (c.NodeType != NodeTypeFunction && c.NodeType != NodeTypeMethod) || c.StartLine <= line {
+			continue
+		}
+		if best == "" || c.StartLine < bestLine {
+			best, bestLine = c.ID, c.StartLine
+		}
+	}
+	if best == "" {
+		return from
+	}
+	return best
+}
+
+// routeMethod returns the HTTP method of the call or annotation a path is
+// the first argument of, such as app.get("/users") or @GetMapping("/users")
+func routeMethod(n *sitter.Node, content []byte) string {
+	args := n.Parent()
+	if args == nil || args.NamedChildCount() == 0 || !args.NamedChild(0).Equal(n) {
+		return ""
+	}
+	call := args.Parent()
+	if call == nil || !strings.Contains(args.Type(), "argument") {
+		return ""
+	}
+	callee := call.ChildByFieldName("function")
+	if callee == nil {
+		callee = call.ChildByFieldName("name")
+	}
+	if callee == nil && call.NamedChildCount() > 0 {
+		callee = call.NamedChild(0)
+	}
+	m := trailingIdent.FindStringSubmatch(nodeText(callee, content))
+	if m == nil {
+		return ""
+	}
+	name := m[1]
+	if verb, ok := strings.CutSuffix(name, "Mapping"); ok {
+		name = verb
+	}
+	if method := strings.ToUpper(name); httpVerbs[method] {
+		return method
+	}
+	return ""
+}
+
+// selectedFields returns the fields a GraphQL selection set, starting at
+// its opening brace, selects at its top level
+func selectedFields(selection string) []string {
+	tokens := gqlSelection.FindAllString(selection, -1)
+	var fields []string
+	depth, parens := 0, 0
+	for i, tok := range tokens {
+		switch tok {
+		case "{":
+			depth++
+			continue
+		case "}":
+			depth--
+			if depth == 0 {
+				return fields
+			}
+			continue
+		case "(":
+			parens++
+			continue
+		case ")":
+			parens--
+			continue
+		}
+		if depth != 1 || parens != 0 || !isIdentStart(tok[0]) {
+			continue
+		}
+		if i > 0 && (tokens[i-1] == "@" || tokens[i-1] == "..." || tokens[i-1] == "$" || tokens[i-1] == "on") {
+			continue
+		}
+		if i+1 < len(tokens) && tokens[i+1] == ":" {
+			continue // an alias
+		}
+		fields = append(fields, tok)
+	}
+	return fields
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// unquoteLiteral returns the text of a string literal without its prefix,
+// such as f or r, and quotes
+func unquoteLiteral(s string) string {
+	s = strings.TrimLeft(s, "rRbBfFuU@$")
+	for _, q := range []string{`"""`, `'''`, `"`, `'`, "`"} {
+		if strings.HasPrefix(s, q) && strings.HasSuffix(s, q) && len(s) >= 2*len(q) {
+			return strings.TrimSpace(s[len(q) : len(s)-len(q)])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// upperCamel writes a method name as an rpc name: get_user and getUser
+// become GetUser
+func upperCamel(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		r := []rune(part)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+	return b.String()
+}
+
+// lowerCamel writes a resolver name as a GraphQL field name: user_by_id and
+// UserByID become userById and userByID
+func lowerCamel(name string) string {
+	name = upperCamel(name)
+	if name == "" {
+		return ""
+	}
+	r := []rune(name)
+	return string(unicode.ToLower(r[0])) + string(r[1:])
+}
@@ -0,0 +1,426 @@
+package parser
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseEdges parses a sample and returns its nodes by local ID and its edges
+// of the given types as "from -type-> to", with local IDs
+func parseEdges(t *testing.T, file string, lang Language, code string, types ...EdgeType) (map[string]CodeNode, []string) {
+	t.Helper()
+	p := NewParser()
+	if got := p.DetectLanguage(file); got != lang {
+		t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, lang)
+	}
+	result, err := p.ParseContent(context.Background(), file, lang, []byte(code))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	local := func(id string) string {
+		return strings.TrimPrefix(id, file+"::")
+	}
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[local(n.ID)] = n
+	}
+	var edges []string
+	for _, e := range result.Edges {
+		for _, et := range types {
+			if e.EdgeType == et {
+				edges = append(edges, local(e.FromID)+" -"+string(et)+"-> "+local(e.ToID))
+			}
+		}
+	}
+	sort.Strings(edges)
+	return nodes, edges
+}
+
+func checkEdges(t *testing.T, got, want []string) {
+	t.Helper()
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("edges:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExtractProtobuf(t *testing.T) {
+	code := `syntax = "proto3";
+
+package acme.users;
+
+import "google/protobuf/timestamp.proto";
+
+// A user of the system
+message User {
+  string id = 1;
+  message Address { string city = 1; }
+  Address address = 2;
+}
+
+enum Role {
+  ROLE_UNSPECIFIED = 0;
+}
+
+// Manages users
+service UserService {
+  // Fetches one user
+  rpc GetUser(GetUserRequest) returns (User);
+  rpc ListUsers(ListUsersRequest) returns (stream User) {}
+}
+`
+	nodes, edges := parseEdges(t, "users.proto", LangProtobuf, code, EdgeTypeDefinedIn, EdgeTypeUses, EdgeTypeImports, EdgeTypeContains)
+	checkSample(t, nodes, nil, map[string]NodeType{
+		"User":                     NodeTypeStruct,
+		"User.Address":             NodeTypeStruct,
+		"Role":                     NodeTypeEnum,
+		"UserService":              NodeTypeInterface,
+		"UserService.GetUser":      NodeTypeOperation,
+		"UserService.ListUsers":    NodeTypeOperation,
+		"rpc::UserService.GetUser": NodeTypeOperation,
+	}, nil)
+	if doc := nodes["UserService.GetUser"].DocComment; doc != "Fetches one user" {
+		t.Errorf("expected doc comment 'Fetches one user', got %q", doc)
+	}
+	if n := nodes["rpc::UserService.GetUser"]; n.FilePath != "" {
+		t.Errorf("expected a shared contract node, got file path %q", n.FilePath)
+	}
+	checkEdges(t, edges, []string{
+		"package::acme.users -contains-> users.proto",
+		"users.proto -imports-> external::google/protobuf/timestamp",
+		"User -uses-> User.Address",
+		"UserService.GetUser -uses-> GetUserRequest",
+		"UserService.GetUser -uses-> User",
+		"UserService.ListUsers -uses-> ListUsersRequest",
+		"UserService.ListUsers -uses-> User",
+		"rpc::UserService.GetUser -defined_in-> UserService.GetUser",
+		"rpc::UserService.ListUsers -defined_in-> UserService.ListUsers",
+	})
+}
+
+func TestExtractSQL(t *testing.T) {
+	code := `-- Registered users
+CREATE TABLE users (
+  id BIGINT PRIMARY KEY,
+  email TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.orders (
+  id BIGINT PRIMARY KEY,
+  user_id BIGINT REFERENCES users(id)
+);
+
+CREATE VIEW active_users AS SELECT * FROM users WHERE id > 0;
+
+ALTER TABLE orders ADD COLUMN total NUMERIC;
+
+CREATE FUNCTION order_count(uid BIGINT) RETURNS BIGINT AS $$ SELECT count(*) FROM orders WHERE user_id = uid $$ LANGUAGE sql;
+
+DELETE FROM Orders WHERE id = 0;
+`
+	nodes, edges := parseEdges(t, "schema.sql", LangSQL, code, EdgeTypeDefinedIn, EdgeTypeUses)
+	checkSample(t, nodes, nil, map[string]NodeType{
+		"users":          NodeTypeTable,
+		"users.email":    NodeTypeColumn,
+		"orders":         NodeTypeTable,
+		"orders.user_id": NodeTypeColumn,
+		"orders.total":   NodeTypeColumn,
+		"active_users":   NodeTypeTable,
+		"order_count":    NodeTypeFunction,
+		"table::users":   NodeTypeTable,
+	}, nil)
+	if doc := nodes["users"].DocComment; doc != "Registered users" {
+		t.Errorf("expected doc comment 'Registered users', got %q", doc)
+	}
+	checkEdges(t, edges, []string{
+		"table::users -defined_in-> users",
+		"table::orders -defined_in-> orders",
+		"table::active_users -defined_in-> active_users",
+		"orders.user_id -uses-> table::users",
+		"active_users -uses-> table::users",
+		"order_count -uses-> table::orders",
+		"schema.sql -uses-> table::orders",
+	})
+}
+
+func TestExtractGraphQL(t *testing.T) {
+	code := `"""A user of the system"""
+type User implements Node & Entity @key(fields: "id") {
+  id: ID!
+  "Their orders"
+  orders(first: Int = 10, status: OrderStatus): [Order!]!
+}
+
+interface Node { id: ID! }
+
+enum OrderStatus { OPEN CLOSED }
+
+union SearchResult = User | Order
+
+scalar Time
+
+directive @key(fields: String!) repeatable on OBJECT | INTERFACE
+
+type Query {
+  "Looks up a user"
+  user(id: ID!): User
+  search(term: String!, filter: Filter = {limit: 5}): [SearchResult!]!
+}
+
+extend type Mutation {
+  createUser(input: NewUser!): User!
+}
+
+query CurrentUser($id: ID!) {
+  me: user(id: $id) { id ...UserFields }
+  search(term: "x") @include(if: true) { __typename }
+}
+
+fragment UserFields on User { id }
+`
+	nodes, edges := parseEdges(t, "schema.graphql", LangGraphQL, code, EdgeTypeDefinedIn, EdgeTypeUses, EdgeTypeImplements)
+	checkSample(t, nodes, nil, map[string]NodeType{
+		"User":                NodeTypeType,
+		"Node":                NodeTypeInterface,
+		"OrderStatus":         NodeTypeEnum,
+		"SearchResult":        NodeTypeType,
+		"Time":                NodeTypeType,
+		"Query":               NodeTypeType,
+		"Query.user":          NodeTypeOperation,
+		"Query.search":        NodeTypeOperation,
+		"Mutation.createUser": NodeTypeOperation,
+		"CurrentUser":         NodeTypeFunction,
+		"graphql::Query.user": NodeTypeOperation,
+		"schema.graphql":      NodeTypeModule,
+	}, nil)
+	if _, ok := nodes["User.orders"]; ok {
+		t.Error("expected only the fields of root types to be nodes")
+	}
+	user := nodes["User"]
+	if user.DocComment != "A user of the system" || user.StartLine != 1 || user.EndLine != 6 {
+		t.Errorf("expected User on lines 1-6 with its description, got %d-%d %q", user.StartLine, user.EndLine, user.DocComment)
+	}
+	if op := nodes["Query.user"]; op.DocComment != "Looks up a user" || op.Signature != "user(id: ID!): User" {
+		t.Errorf("expected Query.user documented with its signature, got %q %q", op.DocComment, op.Signature)
+	}
+	checkEdges(t, edges, []string{
+		"User -implements-> Node",
+		"User -implements-> Entity",
+		"User -uses-> Order",
+		"User -uses-> OrderStatus",
+		"SearchResult -uses-> User",
+		"SearchResult -uses-> Order",
+		"Query -uses-> User",
+		"Query -uses-> Filter",
+		"Query -uses-> SearchResult",
+		"Query.user -uses-> User",
+		"Query.search -uses-> Filter",
+		"Query.search -uses-> SearchResult",
+		"Mutation -uses-> NewUser",
+		"Mutation -uses-> User",
+		"Mutation.createUser -uses-> NewUser",
+		"Mutation.createUser -uses-> User",
+		"CurrentUser -uses-> graphql::Query.user",
+		"CurrentUser -uses-> graphql::Query.search",
+		"graphql::Query.user -defined_in-> Query.user",
+		"graphql::Query.search -defined_in-> Query.search",
+		"graphql::Mutation.createUser -defined_in-> Mutation.createUser",
+	})
+}
+
+func TestExtractOpenAPI(t *testing.T) {
+	code := `openapi: 3.0.3
+info:
+  title: Users
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+    get:
+      operationId: getUser
+      summary: Fetches one user
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+    delete:
+      responses:
+        "204":
+          description: Deleted
+components:
+  schemas:
+    User:
+      description: A user
+      properties:
+        address:
+          $ref: "#/components/schemas/Address"
+    Address:
+      type: object
+`
+	nodes, edges := parseEdges(t, "api/openapi.yaml", LangOpenAPI, code, EdgeTypeDefinedIn, EdgeTypeUses)
+	checkSample(t, nodes, nil, map[string]NodeType{
+		"/users/{id}":         NodeTypeRoute,
+		"/users/{id}.get":     NodeTypeOperation,
+		"/users/{id}.delete":  NodeTypeOperation,
+		"User":                NodeTypeType,
+		"Address":             NodeTypeType,
+		"http::GET /users/{}": NodeTypeOperation,
+		"api/openapi.yaml":    NodeTypeModule,
+	}, nil)
+	get := nodes["/users/{id}.get"]
+	if get.Name != "getUser" || get.Signature != "GET /users/{id}" || get.DocComment != "Fetches one user" {
+		t.Errorf("expected getUser with its signature and summary, got %+v", get)
+	}
+	if get.StartLine != 9 || get.EndLine != 17 {
+		t.Errorf("expected getUser on lines 9-17, got %d-%d", get.StartLine, get.EndLine)
+	}
+	if del := nodes["/users/{id}.delete"]; del.Name != "DELETE /users/{id}" {
+		t.Errorf("expected an operation without operationId named by its method and path, got %q", del.Name)
+	}
+	checkEdges(t, edges, []string{
+		"/users/{id}.get -uses-> User",
+		"User -uses-> Address",
+		"http::GET /users/{} -defined_in-> /users/{id}.get",
+		"http::DELETE /users/{} -defined_in-> /users/{id}.delete",
+	})
+
+	p := NewParser()
+	if got := p.DetectLanguage("config.yaml"); got != "" {
+		t.Errorf("expected other YAML files not to be read, got %s", got)
+	}
+	for _, file := range []string{"swagger.json", "svc.openapi.yml", "OpenAPI-v2.yaml"} {
+		if got := p.DetectLanguage(file); got != LangOpenAPI {
+			t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, LangOpenAPI)
+		}
+	}
+	result, err := p.ParseContent(context.Background(), "openapi.yaml", LangOpenAPI, []byte("name: not a spec\n"))
+	if err != nil || len(result.Nodes) != 1 || result.Nodes[0].NodeType != NodeTypeModule {
+		t.Errorf("expected only a module node for a file that is not a spec, got %+v %v", result, err)
+	}
+}
+
+func TestContractLinks(t *testing.T) {
+	contracts := []EdgeType{EdgeTypeUses, EdgeTypeImplements}
+	filter := func(edges []string) []string {
+		var kept []string
+		for _, e := range edges {
+			for _, prefix := range []string{rpcContractPrefix, tableContractPrefix, graphqlContractPrefix, httpContractPrefix} {
+				if strings.Contains(e, "-> "+prefix) {
+					kept = append(kept, e)
+				}
+			}
+		}
+		return kept
+	}
+
+	t.Run("go", func(t *testing.T) {
+		code := `package users
+
+type server struct {
+	pb.UnimplementedUserServiceServer
+	db *sql.DB
+}
+
+func (s *server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	row := s.db.QueryRow(` + "`SELECT id, email FROM users u JOIN public.\"Orders\" o ON o.user_id = u.id WHERE u.id = $1`" + `, req.Id)
+	return scan(row)
+}
+
+func (s *server) helper() {}
+
+func (r *queryResolver) User(ctx context.Context, id string) (*User, error) { return nil, nil }
+
+func routes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /users/{id}", handleUser)
+}
+`
+		_, edges := parseEdges(t, "server.go", LangGo, code, contracts...)
+		checkEdges(t, filter(edges), []string{
+			"server.GetUser -implements-> rpc::UserService.GetUser",
+			"server.GetUser -uses-> table::users",
+			"server.GetUser -uses-> table::orders",
+			"queryResolver.User -implements-> graphql::Query.user",
+			"routes -implements-> http::GET /users/{}",
+		})
+	})
+
+	t.Run("python", func(t *testing.T) {
+		code := `class UserServiceServicer(user_pb2_grpc.UserServiceServicer):
+    def __init__(self, db):
+        self.db = db
+
+    def get_user(self, request, context):
+        return self.db.execute(f"update users set seen = now() where id = {request.id}")
+
+class Query(graphene.ObjectType):
+    def resolve_user_by_email(self, info, email):
+        return None
+
+@app.get("/users/<int:id>")
+def read_user(id):
+    return client.execute("""
+        query Me { me { id } }
+    """)
+
+PURGE = "DELETE FROM sessions WHERE expired"
+`
+		_, edges := parseEdges(t, "service.py", LangPython, code, contracts...)
+		checkEdges(t, filter(edges), []string{
+			"UserServiceServicer.get_user -implements-> rpc::UserService.GetUser",
+			"UserServiceServicer.get_user -uses-> table::users",
+			"Query.resolve_user_by_email -implements-> graphql::Query.userByEmail",
+			"read_user -implements-> http::GET /users/{}",
+			"read_user -uses-> graphql::Query.me",
+			"service.py -uses-> table::sessions",
+		})
+	})
+
+	t.Run("java", func(t *testing.T) {
+		code := `class UserService extends UserServiceGrpc.UserServiceImplBase {
+    UserService() {}
+
+    @Override
+    public void getUser(GetUserRequest req, StreamObserver<User> out) {
+        jdbc.query("INSERT INTO audit (event) VALUES ('read')");
+    }
+}
+
+@RestController
+class UserController {
+    @GetMapping("/users/{id}")
+    public User show(@PathVariable long id) { return null; }
+}
+`
+		_, edges := parseEdges(t, "UserService.java", LangJava, code, contracts...)
+		checkEdges(t, filter(edges), []string{
+			"UserService.getUser -implements-> rpc::UserService.GetUser",
+			"UserService.getUser -uses-> table::audit",
+			"UserController.show -implements-> http::GET /users/{}",
+		})
+	})
+
+	t.Run("javascript", func(t *testing.T) {
+		code := `const resolvers = {
+  Query: {
+    user: (parent, args) => db.query("SELECT * FROM users WHERE id = $1", [args.id]),
+  },
+};
+
+app.post('/users/:id/orders', createOrder);
+
+const text = "select an option from the list";
+`
+		_, edges := parseEdges(t, "resolvers.js", LangJavaScript, code, contracts...)
+		checkEdges(t, filter(edges), []string{
+			"resolvers.Query.user -implements-> graphql::Query.user",
+			"resolvers.Query.user -uses-> table::users",
+			"resolvers.js -implements-> http::POST /users/{}/orders",
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// definition is a declaration found by a definitions query
+type definition struct {
+	node    *sitter.Node
+	kind    NodeType
+	names   []*sitter.Node
+	scope   bool // also names the nodes inside it
+	pattern int
+}
+
+// extractDefinitions adds the nodes a language's definitions query finds,
+// with their IDs qualified by the scopes around them. It returns the name
+// nodes of the definitions, which are not references to them.
+func (p *Parser) extractDefinitions(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte, result *ParseResult) map[nodeKey]bool {
+	defs := make(map[nodeKey]*definition)
+	scopes := make(map[nodeKey]string)
+	scopePatterns := make(map[nodeKey]int)
+	for _, m := range spec.definitions.matches(root, content) {
+		var def *definition
+		for capture, nodes := range m.captures {
+			if kind, ok := strings.CutPrefix(capture, "definition."); ok {
+				def = &definition{node: nodes[0], kind: NodeType(kind), names: m.captures["name"], pattern: m.pattern}
+			}
+		}
+		if def != nil {
+			def.scope = m.node("scope") != nil && m.node("scope").Equal(def.node)
+			prev, ok := defs[keyOf(def.node)]
+			switch {
+			case ok && m.pattern == prev.pattern:
+				// A pattern matches once per node of a path such as
+				// M.nested.deep, which make up one name
+				prev.names = mergeNodes(prev.names, def.names)
+			case !ok || m.pattern < prev.pattern:
+				defs[keyOf(def.node)] = def
+			}
+			continue
+		}
+
+		scope := m.node("scope")
+		if scope == nil {
+			continue
+		}
+		key := keyOf(scope)
+		if prev, ok := scopePatterns[key]; ok && prev <= m.pattern {
+			continue
+		}
+		name := ""
+		if spec.scopeName != nil {
+			name = spec.scopeName(scope, content)
+		}
+		if name == "" {
+			name = strings.Join(spec.namePath(m.captures["name"], content), ".")
+		}
+		scopes[key], scopePatterns[key] = name, m.pattern
+	}
+
+	sorted := make([]*definition, 0, len(defs))
+	for key, def := range defs {
+		if def.scope {
+			scopes[key] = strings.Join(spec.namePath(def.names, content), ".")
+		}
+		sorted = append(sorted, def)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i].node, sorted[j].node
+		if a.StartByte() != b.StartByte() {
+			return a.StartByte() < b.StartByte()
+		}
+		return a.EndByte() > b.EndByte()
+	})
+
+	docs := spec.docComments(root, content)
+	names := make(map[nodeKey]bool)
+	for _, def := range sorted {
+		for _, name := range def.names {
+			names[keyOf(name)] = true
+		}
+		path := spec.namePath(def.names, content)
+		if len(path) == 0 {
+			continue
+		}
+		n := p.declNode(def.node, path[len(path)-1], def.kind, filePath, spec.Name, content)
+		n.ID = filePath + "::" + strings.Join(path, ".")
+		trimLeadingSpace(&n, def.node, content)
+		if doc, ok := docs[keyOf(def.node)]; ok {
+			n.DocComment = doc
+		}
+		if spec.refine != nil {
+			spec.refine(p, &n, def.node, content)
+		}
+
+		n.arity = -1
+		if n.NodeType == NodeTypeFunction || n.NodeType == NodeTypeMethod {
+			n.arity = spec.parameterCount(def.node)
+		}
+		var chain []string
+		for parent := def.node.Parent(); parent != nil; parent = parent.Parent() {
+			if name := scopes[keyOf(parent)]; name != "" {
+				chain = append([]string{name}, chain...)
+			}
+		}
+		if len(chain) > 0 {
+			prefix := filePath + "::"
+			if rest, ok := strings.CutPrefix(n.ID, prefix); ok {
+				n.ID = prefix + strings.Join(chain, ".") + "." + rest
+			}
+		}
+		result.Nodes = append(result.Nodes, n)
+	}
+	return names
+}
+
+// mergeNodes adds the nodes of more missing from nodes, keeping them in
+// document order
+func mergeNodes(nodes, more []*sitter.Node) []*sitter.Node {
+	seen := make(map[nodeKey]bool, len(nodes))
+	for _, n := range nodes {
+		seen[keyOf(n)] = true
+	}
+	for _, n := range more {
+		if !seen[keyOf(n)] {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].StartByte() < nodes[j].StartByte() })
+	return nodes
+}
+
+// namePath returns the text of the @name captures of a match, written with
+// "." for the language's name separators. Names that are string literals,
+// such as a TypeScript module "x" or a JS "key": value, lose their quotes.
+func (spec *LanguageSpec) namePath(names []*sitter.Node, content []byte) []string {
+	var path []string
+	for _, n := range names {
+		name := strings.Trim(nodeText(n, content), "\"'`")
+		for _, sep := range spec.NameSeparators {
+			name = strings.ReplaceAll(name, sep, ".")
+		}
+		if name = strings.TrimPrefix(name, "."); name != "" {
+			path = append(path, name)
+		}
+	}
+	return path
+}
+
+// parameterCount returns the arity of a function definition
+func (spec *LanguageSpec) parameterCount(def *sitter.Node) int {
+	if spec.arity != nil {
+		return spec.arity(def)
+	}
+	return parameterCount(def)
+}
+
+// docComments returns the documentation the docs query attaches to nodes,
+// by the node it documents
+func (spec *LanguageSpec) docComments(root *sitter.Node, content []byte) map[nodeKey]string {
+	docs := make(map[nodeKey]string)
+	if spec.docs == nil {
+		return docs
+	}
+	for _, m := range spec.docs.matches(root, content) {
+		target := m.node("doc.target")
+		if target == nil {
+			continue
+		}
+		if _, ok := docs[keyOf(target)]; ok {
+			continue
+		}
+		var parts []string
+		for _, doc := range m.captures["doc"] {
+			if isCommentNode(doc.Type()) {
+				parts = append(parts, cleanComment(nodeText(doc, content)))
+			} else {
+				parts = append(parts, cleanDocstring(nodeText(doc, content)))
+			}
+		}
+		docs[keyOf(target)] = strings.Join(parts, " ")
+	}
+	return docs
+}
+
+// trimLeadingSpace drops the whitespace some grammars, such as Lua's,
+// include at the start of a node, moving its start line to the first
+// non-blank byte
+func trimLeadingSpace(n *CodeNode, def *sitter.Node, content []byte) {
+	start := def.StartByte()
+	for start < def.EndByte() && isSpace(content[start]) {
+		start++
+	}
+	if start == def.StartByte() {
+		return
+	}
+	n.StartLine += bytes.Count(content[def.StartByte():start], []byte("\n"))
+	n.Content = string(content[start:def.EndByte()])
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
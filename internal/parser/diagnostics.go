@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Tree-sitter reads files with syntax errors as well as it can: the code it
+// cannot read is wrapped in ERROR nodes, and tokens it expected but did not
+// find are inserted as MISSING nodes. Their ranges are reported with the
+// parse result, together with the share of the file's bytes in errors, so
+// callers can tell a file being edited from one that parses cleanly and keep
+// the nodes of its last good version when too much of it is unreadable.
+
+// DefaultErrorThreshold is the error ratio above which a parse is degraded
+const DefaultErrorThreshold = 0.2
+
+// maxSyntaxErrors bounds the syntax errors reported for a file. The error
+// ratio counts them all.
+const maxSyntaxErrors = 100
+
+// SyntaxError is a range of a file its grammar could not read. Lines and
+// columns count from 1; columns count bytes.
+type SyntaxError struct {
+	Path      string `json:"path,omitempty"` // virtual path of the cell or block, for documents
+	StartLine int    `json:"start_line"`
+	StartCol  int    `json:"start_col"`
+	EndLine   int    `json:"end_line"`
+	EndCol    int    `json:"end_col"`
+	Message   string `json:"message"`
+}
+
+// Degraded reports whether more of the file is in syntax errors than
+// threshold allows, in which case its previous nodes are better kept than
+// replaced by these. A threshold of 0 is DefaultErrorThreshold.
+func (r *ParseResult) Degraded(threshold float64) bool {
+	if threshold <= 0 {
+		threshold = DefaultErrorThreshold
+	}
+	return r.ErrorRatio > threshold
+}
+
+// collectSyntaxErrors returns the syntax errors of a tree and the share of
+// content they cover. MISSING nodes cover no bytes.
+func collectSyntaxErrors(root *sitter.Node, content []byte) ([]SyntaxError, float64) {
+	if root == nil || !root.HasError() {
+		return nil, 0
+	}
+	var errs []SyntaxError
+	covered := 0
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		switch {
+		case n.IsMissing():
+			if len(errs) < maxSyntaxErrors {
+				errs = append(errs, syntaxError(n, fmt.Sprintf("missing %q", n.Type())))
+			}
+		case n.IsError():
+			covered += int(n.EndByte() - n.StartByte())
+			if len(errs) < maxSyntaxErrors {
+				errs = append(errs, syntaxError(n, fmt.Sprintf("unexpected %q", errorSnippet(n, content))))
+			}
+		case n.HasError():
+			for i := 0; i < int(n.ChildCount()); i++ {
+				walk(n.Child(i))
+			}
+		}
+	}
+	walk(root)
+	if len(content) == 0 {
+		return errs, 0
+	}
+	return errs, min(float64(covered)/float64(len(content)), 1)
+}
+
+func syntaxError(n *sitter.Node, message string) SyntaxError {
+	start, end := n.StartPoint(), n.EndPoint()
+	return SyntaxError{
+		StartLine: int(start.Row) + 1,
+		StartCol:  int(start.Column) + 1,
+		EndLine:   int(end.Row) + 1,
+		EndCol:    int(end.Column) + 1,
+		Message:   message,
+	}
+}
+
+// errorSnippet returns the start of the code an ERROR node wraps: its first
+// line, shortened
+func errorSnippet(n *sitter.Node, content []byte) string {
+	start, end := int(n.StartByte()), int(n.EndByte())
+	if start >= end || end > len(content) {
+		return ""
+	}
+	text, _, _ := strings.Cut(string(content[start:end]), "\n")
+	text = strings.TrimSpace(text)
+	if r := []rune(text); len(r) > 40 {
+		text = string(r[:40]) + "…"
+	}
+	return text
+}
+
+// wholeFileError is the syntax error of a file that could not be read at
+// all
+func wholeFileError(content []byte, err error) SyntaxError {
+	lines := strings.Split(string(content), "\n")
+	return SyntaxError{
+		StartLine: 1,
+		StartCol:  1,
+		EndLine:   len(lines),
+		EndCol:    len(lines[len(lines)-1]) + 1,
+		Message:   err.Error(),
+	}
+}
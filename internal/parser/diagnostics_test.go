@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxErrors(t *testing.T) {
+	p := NewParser()
+	ctx := context.Background()
+
+	clean, err := p.ParseContent(ctx, "ok.go", LangGo, []byte("package main\n\nfunc main() {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clean.SyntaxErrors) != 0 || clean.ErrorRatio != 0 || clean.Degraded(0) {
+		t.Errorf("expected a clean parse, got %v (ratio %v)", clean.SyntaxErrors, clean.ErrorRatio)
+	}
+
+	code := "package main\n\n" +
+		"func kept() int { return 1 }\n\n" +
+		"func broken( {\n" +
+		"\treturn @@ 2\n" +
+		"}\n"
+	result, err := p.ParseContent(ctx, "broken.go", LangGo, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.SyntaxErrors) == 0 || result.ErrorRatio <= 0 || result.ErrorRatio > 1 {
+		t.Fatalf("expected syntax errors, got %v (ratio %v)", result.SyntaxErrors, result.ErrorRatio)
+	}
+	for _, e := range result.SyntaxErrors {
+		if e.StartLine < 5 || e.EndLine > 7 || e.Message == "" {
+			t.Errorf("expected errors on lines 5-7, got %+v", e)
+		}
+	}
+	found := false
+	for _, n := range result.Nodes {
+		if n.ID == "broken.go::kept" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the readable function kept, got %v", result.Nodes)
+	}
+	if !result.Degraded(result.ErrorRatio/2) || result.Degraded(1) {
+		t.Errorf("unexpected thresholds for ratio %v", result.ErrorRatio)
+	}
+
+	doc := "# Notes\n\n```go\npackage main\n\nfunc ok() {}\n```\n\n```python\ndef broken(:\n    pass\n```\n"
+	md, err := p.ParseContent(ctx, "notes.md", LangMarkdown, []byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.SyntaxErrors) == 0 {
+		t.Fatalf("expected the Python block's errors, got %v", md.SyntaxErrors)
+	}
+	for _, e := range md.SyntaxErrors {
+		if e.Path != "notes.md#block-2" || e.StartLine != 10 {
+			t.Errorf("expected errors on line 10 of block 2, got %+v", e)
+		}
+	}
+
+	nb, err := p.ParseContent(ctx, "bad.ipynb", LangNotebook, []byte(`{"cells": [`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nb.ErrorRatio != 1 || len(nb.SyntaxErrors) != 1 || !strings.Contains(nb.SyntaxErrors[0].Message, "notebook") {
+		t.Errorf("expected the notebook to be one syntax error, got %v", nb.SyntaxErrors)
+	}
+}
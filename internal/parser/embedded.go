@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"context"
+	"strings"
+)
+
+// Notebooks and Markdown documents hold code in other languages. Each code
+// cell or fenced block is parsed by its language as a file of its own whose
+// path is virtual: the document's path followed by "#cell-<n>" or
+// "#block-<n>", counting cells or fenced blocks from 1. Its nodes carry that
+// path, so results point back at the cell or block, and the blocks of a
+// document resolve calls and references to each other's definitions. Lines
+// of Markdown blocks are lines of the document; lines of notebook cells,
+// which are stored as JSON, are lines of the cell.
+
+// codeBlock is code embedded in a document
+type codeBlock struct {
+	path   string // virtual path: "<document>#cell-7"
+	lang   Language
+	code   []byte
+	offset int // lines of the document before the block's first line
+}
+
+// parseBlocks parses the code blocks of a document, adding the document's
+// module node. The document's error ratio is that of its code.
+func (p *Parser) parseBlocks(ctx context.Context, lang Language, filePath string, lines int, blocks []codeBlock) (*ParseResult, error) {
+	result := &ParseResult{
+		Nodes: []CodeNode{fileModule(lang, filePath, lines)},
+		Edges: []CodeEdge{},
+	}
+	code, errBytes := 0, 0.0
+	for _, b := range blocks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		block, err := p.ParseContent(ctx, b.path, b.lang, b.code)
+		if err != nil {
+			return nil, err
+		}
+		for i := range block.Nodes {
+			if n := &block.Nodes[i]; n.FilePath == b.path {
+				n.StartLine += b.offset
+				n.EndLine += b.offset
+			}
+		}
+		for _, e := range block.SyntaxErrors {
+			if len(result.SyntaxErrors) == maxSyntaxErrors {
+				break
+			}
+			e.Path = b.path
+			e.StartLine += b.offset
+			e.EndLine += b.offset
+			result.SyntaxErrors = append(result.SyntaxErrors, e)
+		}
+		code += len(b.code)
+		errBytes += block.ErrorRatio * float64(len(b.code))
+		result.Nodes = append(result.Nodes, block.Nodes...)
+		result.Edges = append(result.Edges, block.Edges...)
+	}
+	if code > 0 {
+		result.ErrorRatio = errBytes / float64(code)
+	}
+	return result, nil
+}
+
+// blockLanguages are the language tags of code blocks that are neither a
+// language's name nor one of its extensions
+var blockLanguages = map[string]Language{
+	"golang":     LangGo,
+	"python3":    LangPython,
+	"ipython":    LangPython,
+	"ipython3":   LangPython,
+	"jsx":        LangJavaScript,
+	"node":       LangJavaScript,
+	"shell":      LangBash,
+	"sh":         LangBash,
+	"zsh":        LangBash,
+	"c++":        LangCPP,
+	"c#":         LangCSharp,
+	"cs":         LangCSharp,
+	"postgresql": LangSQL,
+	"postgres":   LangSQL,
+	"mysql":      LangSQL,
+	"sqlite":     LangSQL,
+	"psql":       LangSQL,
+}
+
+// blockLanguage returns the language a code block's tag names, or "" when
+// it names none or a document format
+func blockLanguage(tag string) Language {
+	tag = strings.ToLower(strings.Trim(tag, "{}."))
+	lang, ok := blockLanguages[tag]
+	if !ok && tag != "" {
+		if languageSpec(Language(tag)) != nil {
+			lang = Language(tag)
+		} else {
+			lang = languageOf("block." + tag)
+		}
+	}
+	if lang == LangMarkdown || lang == LangNotebook {
+		return ""
+	}
+	return lang
+}
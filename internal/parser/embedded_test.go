@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractNotebook(t *testing.T) {
+	cells := []map[string]any{
+		{"cell_type": "markdown", "source": []string{"# Load\n", "def not_code(): pass"}},
+		{"cell_type": "code", "source": []string{"%matplotlib inline\n", "!pip install pandas\n", "def load(path):\n", "    return read_csv(path)\n"}},
+		{"cell_type": "code", "source": "%%sql\nSELECT * FROM events WHERE day = today()"},
+		{"cell_type": "code", "source": "frame = load('x.csv')\ndef summarize():\n    return load('y.csv')\n"},
+		{"cell_type": "code", "source": "%%html\n<b>not code</b>"},
+		{"cell_type": "code", "source": "int Twice(int x) => x * 2;", "metadata": map[string]any{
+			"polyglot_notebook": map[string]any{"kernelName": "csharp"},
+		}},
+	}
+	content, err := json.Marshal(map[string]any{
+		"cells":    cells,
+		"metadata": map[string]any{"kernelspec": map[string]any{"language": "python"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser()
+	file := "analysis.ipynb"
+	if got := p.DetectLanguage(file); got != LangNotebook {
+		t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, LangNotebook)
+	}
+	result, err := p.ParseContent(context.Background(), file, LangNotebook, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[n.ID] = n
+	}
+	for id, want := range map[string]struct {
+		nodeType NodeType
+		lang     Language
+		filePath string
+		line     int
+	}{
+		"analysis.ipynb":                   {NodeTypeModule, LangNotebook, "analysis.ipynb", 1},
+		"analysis.ipynb#cell-2":            {NodeTypeModule, LangPython, "analysis.ipynb#cell-2", 1},
+		"analysis.ipynb#cell-2::load":      {NodeTypeFunction, LangPython, "analysis.ipynb#cell-2", 3},
+		"analysis.ipynb#cell-4::summarize": {NodeTypeFunction, LangPython, "analysis.ipynb#cell-4", 2},
+		"analysis.ipynb#cell-6::Twice":     {NodeTypeFunction, LangCSharp, "analysis.ipynb#cell-6", 1},
+		"analysis.ipynb#cell-3":            {NodeTypeModule, LangSQL, "analysis.ipynb#cell-3", 1},
+	} {
+		n, ok := nodes[id]
+		if !ok {
+			t.Errorf("expected node %s, got %v", id, result.Nodes)
+			continue
+		}
+		if n.NodeType != want.nodeType || n.Language != want.lang || n.FilePath != want.filePath || n.StartLine != want.line {
+			t.Errorf("node %s: got %s %s in %s at line %d, want %+v", id, n.NodeType, n.Language, n.FilePath, n.StartLine, want)
+		}
+	}
+	for _, id := range []string{"analysis.ipynb#cell-1::not_code", "analysis.ipynb#cell-5"} {
+		if _, ok := nodes[id]; ok {
+			t.Errorf("expected no node %s for a cell that is not code", id)
+		}
+	}
+
+	edges := make(map[CodeEdge]bool)
+	for _, e := range result.Edges {
+		edges[e] = true
+	}
+	for _, want := range []CodeEdge{
+		// Cells call functions defined in other cells
+		{FromID: "analysis.ipynb#cell-4::summarize", ToID: "analysis.ipynb#cell-2::load", EdgeType: EdgeTypeCalls},
+		{FromID: "analysis.ipynb#cell-3", ToID: "table::events", EdgeType: EdgeTypeUses},
+	} {
+		if !edges[want] {
+			t.Errorf("expected edge %+v, got %v", want, result.Edges)
+		}
+	}
+
+	result, err = p.ParseContent(context.Background(), file, LangNotebook, []byte("{not json"))
+	if err != nil || len(result.Nodes) != 1 {
+		t.Errorf("expected only a module node for a broken notebook, got %+v %v", result, err)
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	code := "# Design\n" +
+		"\n" +
+		"```go\n" +
+		"func Handle(w http.ResponseWriter) {\n" +
+		"\tRender(w)\n" +
+		"}\n" +
+		"```\n" +
+		"\n" +
+		"```\n" +
+		"plain text\n" +
+		"```\n" +
+		"\n" +
+		"1. Then:\n" +
+		"   ~~~python title=\"x.py\"\n" +
+		"   def render(w):\n" +
+		"       pass\n" +
+		"   ~~~\n" +
+		"\n" +
+		"````mermaid\n" +
+		"graph TD\n" +
+		"````\n"
+
+	p := NewParser()
+	for _, file := range []string{"design.md", "page.mdx"} {
+		if got := p.DetectLanguage(file); got == "" {
+			t.Errorf("expected %s to be read", file)
+		}
+	}
+	result, err := p.ParseContent(context.Background(), "design.md", LangMarkdown, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[n.ID] = n
+	}
+
+	handle, ok := nodes["design.md#block-1::Handle"]
+	if !ok || handle.Language != LangGo || handle.FilePath != "design.md#block-1" {
+		t.Fatalf("expected Go function Handle in design.md#block-1, got %v", result.Nodes)
+	}
+	if handle.StartLine != 4 || handle.EndLine != 6 {
+		t.Errorf("expected Handle on lines 4-6 of the document, got %d-%d", handle.StartLine, handle.EndLine)
+	}
+	render, ok := nodes["design.md#block-3::render"]
+	if !ok || render.Language != LangPython || render.StartLine != 15 {
+		t.Errorf("expected Python function render on line 15 of design.md#block-3, got %+v", render)
+	}
+	if render.Content != "def render(w):\n    pass" {
+		t.Errorf("expected the block's indentation removed, got %q", render.Content)
+	}
+	for _, id := range []string{"design.md#block-2", "design.md#block-4"} {
+		if _, ok := nodes[id]; ok {
+			t.Errorf("expected no node for %s, whose language is not read", id)
+		}
+	}
+	if module := nodes["design.md"]; module.NodeType != NodeTypeModule || module.Language != LangMarkdown {
+		t.Errorf("expected a module node for the document, got %+v", module)
+	}
+}
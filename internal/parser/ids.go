@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Node IDs have the form "<file path>::<qualified name>". The qualified name
+// is the node's name prefixed by its enclosing scopes (classes, impls,
+// namespaces, modules and functions), joined with ".": a Python method
+// render of class Button in ui.py is "ui.py::Button.render" and a Go method
+// Start on *Server is "server.go::Server.Start".
+//
+// Nodes that would still share an ID, such as overloaded methods or Julia
+// methods of one function, get their arity appended ("Foo.bar/2"), and those
+// that still collide get their start line ("Foo.bar/2@14").
+//
+// The file itself is a module node whose ID is the bare file path, and
+// package and external modules use the "package::" and "external::" prefixes
+// (see modules.go).
+
+// IDScheme is the version of the node ID format described above. Bump it
+// whenever the format changes, so stored graphs are migrated.
+const IDScheme = 3
+
+// qualifyNode prefixes the ID of a node a language's extractor found at n
+// with the scopes enclosing n, and records its arity for disambiguation.
+// Languages described by queries name their scopes in the query instead.
+func (p *Parser) qualifyNode(node *CodeNode, n *sitter.Node, spec *LanguageSpec, content []byte) {
+	node.arity = -1
+	if node.NodeType == NodeTypeFunction || node.NodeType == NodeTypeMethod {
+		node.arity = spec.parameterCount(n)
+	}
+	if spec.scopeName == nil {
+		return
+	}
+
+	var scopes []string
+	for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+		if name := spec.scopeName(parent, content); name != "" {
+			scopes = append([]string{name}, scopes...)
+		}
+	}
+	if len(scopes) == 0 {
+		return
+	}
+	prefix := node.FilePath + "::"
+	if rest, ok := strings.CutPrefix(node.ID, prefix); ok {
+		node.ID = prefix + strings.Join(scopes, ".") + "." + rest
+	}
+}
+
+// parameterCount returns the number of parameters of a function or method
+// node, or -1 when the grammar does not expose them
+func parameterCount(n *sitter.Node) int {
+	if value := n.ChildByFieldName("value"); value != nil && isJSFunction(value) {
+		// A JS/TS binding of a function expression
+		n = value
+		if n.ChildByFieldName("parameter") != nil {
+			return 1 // x => ...
+		}
+	}
+	return countParameters(n.ChildByFieldName("parameters"))
+}
+
+// countParameters returns the number of parameters in a parameter list, or
+// -1 when there is none
+func countParameters(params *sitter.Node) int {
+	if params == nil {
+		return -1
+	}
+	count := 0
+	for i := 0; i < int(params.NamedChildCount()); i++ {
+		if !isCommentNode(params.NamedChild(i).Type()) {
+			count++
+		}
+	}
+	return count
+}
+
+// disambiguateIDs makes node IDs unique within a file by appending the arity,
+// then the start line, to every node of a group that shares an ID
+func disambiguateIDs(nodes []CodeNode) {
+	for _, suffix := range []func(n *CodeNode) string{
+		func(n *CodeNode) string {
+			if n.arity < 0 {
+				return ""
+			}
+			return fmt.Sprintf("/%d", n.arity)
+		},
+		func(n *CodeNode) string { return fmt.Sprintf("@%d", n.StartLine) },
+	} {
+		groups := make(map[string][]int)
+		for i := range nodes {
+			groups[nodes[i].ID] = append(groups[nodes[i].ID], i)
+		}
+		for _, group := range groups {
+			if len(group) < 2 {
+				continue
+			}
+			for _, i := range group {
+				nodes[i].ID += suffix(&nodes[i])
+			}
+		}
+	}
+}
+
+// resolveLocalTargets points edges at same-file guesses ("<file>::<name>")
+// to the node they mean when its ID is qualified, provided exactly one node
+// of the kind the edge refers to has that name in the file: a function or
+// method for calls, a type for uses, extends and implements
+func resolveLocalTargets(result *ParseResult, filePath string) {
+	ids := make(map[string]bool, len(result.Nodes))
+	callables := make(map[string][]string)
+	types := make(map[string][]string)
+	for _, n := range result.Nodes {
+		ids[n.ID] = true
+		switch n.NodeType {
+		case NodeTypeFunction, NodeTypeMethod:
+			callables[n.Name] = append(callables[n.Name], n.ID)
+		case NodeTypeClass, NodeTypeStruct, NodeTypeInterface, NodeTypeEnum, NodeTypeType:
+			types[n.Name] = append(types[n.Name], n.ID)
+		}
+	}
+
+	prefix := filePath + "::"
+	for i := range result.Edges {
+		e := &result.Edges[i]
+		name, ok := strings.CutPrefix(e.ToID, prefix)
+		if block, inBlock := strings.CutPrefix(e.ToID, filePath+"#"); inBlock {
+			// The code blocks of a file, such as notebook cells, see each
+			// other's definitions
+			_, name, ok = strings.Cut(block, "::")
+		}
+		if !ok || ids[e.ToID] {
+			continue
+		}
+		byName := types
+		if e.EdgeType == EdgeTypeCalls {
+			byName = callables
+		}
+		if candidates := byName[name]; len(candidates) == 1 {
+			e.ToID = candidates[0]
+		}
+	}
+}
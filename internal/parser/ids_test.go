@@ -0,0 +1,308 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// idFixtures holds, for every registered language, code whose definitions
+// share names across scopes or overload one another, and the qualified
+// names expected after "<file>::". TestNodeIDsAreUnique fails for a
+// registered language without one.
+var idFixtures = map[Language]struct {
+	file   string
+	code   string
+	want   []string
+	blocks []string // IDs of the nodes of code blocks, under virtual paths
+}{
+	LangGo: {"a.go", `package a
+
+type Server struct{}
+type Client struct{}
+
+func (s *Server) Close() error { return nil }
+func (c Client) Close() error  { return nil }
+func Close()                   {}
+`, []string{"Server.Close", "Client.Close", "Close"}, nil},
+
+	LangPython: {"a.py", `class Button:
+    def __init__(self, label):
+        self.label = label
+
+    def render(self):
+        def helper():
+            pass
+        return helper()
+
+class Link:
+    def __init__(self, href):
+        self.href = href
+
+    def render(self):
+        return self.href
+`, []string{"Button.__init__", "Button.render", "Button.render.helper", "Link.__init__", "Link.render"}, nil},
+
+	LangJavaScript: {"a.js", `class A { toString() { return "a" } }
+class B { toString() { return "b" } }
+function toString() {}
+`, []string{"A.toString", "B.toString", "toString"}, nil},
+
+	LangTypeScript: {"a.ts", `class A { render(): void {} }
+class B { render(x: number): void {} }
+`, []string{"A.render", "B.render"}, nil},
+
+	LangTSX: {"a.tsx", `class A { render() { return <div/> } }
+class B { render() { return <span/> } }
+function render() {}
+`, []string{"A.render", "B.render", "render"}, nil},
+
+	LangJava: {"A.java", `class A {
+    int size() { return 0; }
+    int add(int x) { return x; }
+    int add(int x, int y) { return x + y; }
+    class Inner { int size() { return 1; } }
+}
+`, []string{"A", "A.size", "A.add/1", "A.add/2", "A.Inner", "A.Inner.size"}, nil},
+
+	LangRust: {"a.rs", `struct Point;
+impl Point { fn fmt(&self) {} }
+impl Display for Point { fn fmt(&self, f: &mut Formatter) {} }
+impl Debug for Point { fn fmt(&self, f: &mut Formatter) {} }
+mod util { fn fmt() {} }
+`, []string{"Point", "Point.fmt", "<Point as Display>.fmt", "<Point as Debug>.fmt", "util.fmt"}, nil},
+
+	LangC: {"a.c", `struct point { int x; };
+int area(int w, int h) { return w * h; }
+`, []string{"point", "area"}, nil},
+
+	LangCPP: {"a.cpp", `namespace geo {
+class Shape {
+    int area() { return 0; }
+    int area(int scale) { return scale; }
+};
+}
+int area() { return 1; }
+`, []string{"geo.Shape.area/0", "geo.Shape.area/1", "area"}, nil},
+
+	LangClojure: {"a.clj", `(ns app.core)
+(defn run [] 1)
+(defn run [x] x)
+(defmacro run [& body] body)
+`, []string{"run@2", "run@3", "macro_run"}, nil},
+
+	LangJulia: {"a.jl", `module A
+function run() 1 end
+function run(x) x end
+end
+module B
+function run() 2 end
+end
+`, []string{"A.run@2", "A.run@3", "B.run"}, nil},
+
+	LangCommonLisp: {"a.lisp", `(defun run () 1)
+(defun run (x) x)
+(defmacro run (&body body) body)
+`, []string{"run@1", "run@2", "macro_run"}, nil},
+
+	LangRuby: {"a.rb", `module App
+  class A
+    def size; 0; end
+    def self.size; 1; end
+  end
+  class B
+    def size; 2; end
+  end
+end
+`, []string{"App.A.size/0@3", "App.A.size/0@4", "App.B.size"}, nil},
+
+	LangPHP: {"a.php", `<?php
+namespace App;
+class A { function size() { return 0; } }
+class B { function size() { return 1; } }
+function size() {}
+`, []string{"A.size", "B.size", "size"}, nil},
+
+	LangCSharp: {"a.cs", `namespace App {
+class A {
+  int Size() { return 0; }
+  int Add(int x) { return x; }
+  int Add(int x, int y) { return x + y; }
+}
+class B { int Size() { return 1; } }
+}
+`, []string{"A.Size", "A.Add/1", "A.Add/2", "B.Size"}, nil},
+
+	LangKotlin: {"a.kt", `class A {
+  fun size(): Int = 0
+  fun add(x: Int): Int = x
+  fun add(x: Int, y: Int): Int = x + y
+}
+class B { fun size(): Int = 1 }
+`, []string{"A.size", "A.add/1", "A.add/2", "B.size"}, nil},
+
+	LangSwift: {"a.swift", `class A {
+  func size() -> Int { return 0 }
+  func add(x: Int) -> Int { return x }
+  func add(x: Int, y: Int) -> Int { return x + y }
+}
+struct B { func size() -> Int { return 1 } }
+`, []string{"A.size", "A.add/1", "A.add/2", "B.size"}, nil},
+
+	LangScala: {"a.scala", `object A {
+  def size(): Int = 0
+  def add(x: Int): Int = x
+  def add(x: Int, y: Int): Int = x + y
+}
+class B { def size(): Int = 1 }
+`, []string{"A.size", "A.add/1", "A.add/2", "B.size"}, nil},
+
+	LangLua: {"a.lua", `local A = {}
+function A.run() return 1 end
+local B = {}
+function B.run() return 2 end
+function run() end
+`, []string{"A.run", "B.run", "run"}, nil},
+
+	LangBash: {"a.sh", `build() { echo a; }
+clean() { echo c; }
+build() { echo b; }
+`, []string{"build@1", "clean", "build@3"}, nil},
+
+	LangElixir: {"a.ex", `defmodule A do
+  def run(), do: 1
+  def run(x), do: x
+end
+defmodule B do
+  def run(), do: 2
+end
+`, []string{"A.run/0", "A.run/1", "B.run"}, nil},
+
+	LangProtobuf: {"a.proto", `syntax = "proto3";
+message Req { string id = 1; }
+service Users { rpc Get(Req) returns (Req); }
+service Posts { rpc Get(Req) returns (Req); }
+`, []string{"Users.Get", "Posts.Get"}, nil},
+
+	LangSQL: {"a.sql", `CREATE TABLE users (id INT);
+CREATE TABLE posts (id INT);
+`, []string{"users.id", "posts.id"}, nil},
+
+	LangGraphQL: {"a.graphql", `type User { id: ID! }
+type Query { user(id: ID!): User }
+type Mutation { user(id: ID!): User }
+`, []string{"Query.user", "Mutation.user"}, nil},
+
+	LangOpenAPI: {"openapi.yaml", `openapi: 3.0.3
+paths:
+  /users:
+    get:
+      operationId: listUsers
+  /posts:
+    get:
+      operationId: listPosts
+`, []string{"/users.get", "/posts.get"}, nil},
+
+	LangNotebook: {"a.ipynb", `{"cells": [
+  {"cell_type": "code", "source": "def run():\n    pass\n"},
+  {"cell_type": "code", "source": "def run():\n    return 1\n"}
+], "metadata": {"kernelspec": {"language": "python"}}}
+`, nil, []string{"a.ipynb#cell-1::run", "a.ipynb#cell-2::run"}},
+
+	LangMarkdown: {"a.md", "# Run\n\n```python\ndef run():\n    pass\n```\n\n```python\ndef run():\n    return 1\n```\n", nil, []string{"a.md#block-1::run", "a.md#block-2::run"}},
+}
+
+func TestNodeIDsAreUnique(t *testing.T) {
+	p := NewParser()
+	for _, lang := range Languages() {
+		t.Run(string(lang), func(t *testing.T) {
+			tt, ok := idFixtures[lang]
+			if !ok {
+				t.Fatalf("no ID uniqueness fixture for %s", lang)
+			}
+			result, err := p.ParseContent(context.Background(), tt.file, lang, []byte(tt.code))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.SyntaxErrors) > 0 {
+				// The fixture is valid code, so the grammar is not the
+				// language's, as when its vendored sources are missing
+				t.Skipf("the %s grammar cannot read the fixture: %+v", lang, result.SyntaxErrors)
+			}
+
+			seen := make(map[string]bool)
+			for _, n := range result.Nodes {
+				if seen[n.ID] {
+					t.Errorf("duplicate node ID %s", n.ID)
+				}
+				seen[n.ID] = true
+			}
+			want := tt.blocks
+			for _, name := range tt.want {
+				want = append(want, tt.file+"::"+name)
+			}
+			for _, id := range want {
+				if !seen[id] {
+					t.Errorf("missing node %s", id)
+				}
+			}
+			if t.Failed() {
+				for _, n := range result.Nodes {
+					t.Logf("%s (%s)", n.ID, n.NodeType)
+				}
+			}
+		})
+	}
+}
+
+func TestOverloadsWithSameArityUseLines(t *testing.T) {
+	code := `class A {
+    void log(String s) {}
+    void log(int i) {}
+}
+`
+	result, err := NewParser().ParseContent(context.Background(), "A.java", LangJava, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range result.Nodes {
+		if n.Name == "log" {
+			ids = append(ids, n.ID)
+		}
+	}
+	want := "A.java::A.log/1@2,A.java::A.log/1@3"
+	if got := strings.Join(ids, ","); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCallEdgesTargetQualifiedIDs(t *testing.T) {
+	code := `class Lexer {
+    int run() { return scan(); }
+    int scan() { return 0; }
+}
+
+class Parser {
+    int parse() { return tokenize(); }
+    int tokenize() { return 1; }
+    int scan() { return 2; }
+}
+`
+	result, err := NewParser().ParseContent(context.Background(), "P.java", LangJava, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edges := make(map[string]string)
+	for _, e := range result.Edges {
+		edges[e.FromID] = e.ToID
+	}
+	if got := edges["P.java::Parser.parse"]; got != "P.java::Parser.tokenize" {
+		t.Errorf("expected Parser.parse to call P.java::Parser.tokenize, got %q", got)
+	}
+	// Two methods are named scan, so the call is left unresolved
+	if got := edges["P.java::Lexer.run"]; got != "P.java::scan" {
+		t.Errorf("expected an ambiguous call to keep its unresolved target, got %q", got)
+	}
+}
@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// A LanguageSpec declares how a language is read: the files it covers, its
+// tree-sitter grammar and the queries that find what the graph records.
+//
+// Queries follows the tags.scm convention and holds up to five files, each
+// optional:
+//
+//	definitions.scm  @definition.<kind> on a declaration and @name on its name,
+//	                 where <kind> is a node type such as function, method,
+//	                 class or interface. A node also captured as @scope names
+//	                 the nodes inside it; @scope alone marks a scope that is
+//	                 not a node, such as a Rust impl.
+//	calls.scm        @reference.call on a call, @name on what it calls and
+//	                 optionally @receiver on what it is called on
+//	references.scm   @reference.class, @reference.type (uses edges),
+//	                 @reference.extends and @reference.implementation, each
+//	                 with @name on the type referred to, and @reference.table
+//	                 on a SQL table used
+//	imports.scm      @import.module on a module as written, @import.file on
+//	                 a path relative to the file, @import.system on a system
+//	                 header, @import.submodule on a child module, and
+//	                 @import.prefix, @import.name and @import.all around them;
+//	                 matches sharing an @import node form one import
+//	docs.scm         @doc on the documentation of the node captured as
+//	                 @doc.target, when it is not the comment right before it
+//
+// Earlier patterns take precedence when several match the same node. Besides
+// #eq?, #match? and #any-of?, patterns may use #has-parent? and #kind-eq?
+// (and their #not- forms) to test the node around or the type of a capture.
+type LanguageSpec struct {
+	Name       Language
+	Extensions []string // with the dot: ".go"
+	Grammar    func() *sitter.Language
+	Queries    fs.FS
+
+	// FilePatterns match file names, such as "openapi*.yaml", for formats
+	// whose extension is shared with files of other kinds
+	FilePatterns []string
+
+	// NameSeparators are written as "." in names, such as "::" in Ruby
+	NameSeparators []string
+
+	// The built-in languages fill in what their queries cannot express
+	refine         func(p *Parser, node *CodeNode, def *sitter.Node, content []byte)
+	scopeName      func(n *sitter.Node, content []byte) string
+	callee         func(call *sitter.Node, content []byte) string
+	arity          func(def *sitter.Node) int
+	extractNodes   func(p *Parser, n *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult)
+	collectImports func(root *sitter.Node, content []byte) []importRef
+	packageOf      func(p *Parser, root *sitter.Node, filePath string, content []byte) string
+	resolveImport  func(p *Parser, filePath, pkg string, ref importRef) string
+	symbolTable    func(p *Parser, root *sitter.Node, filePath string, content []byte) SymbolTable
+	edgeExtractor  func(symbols SymbolTable) edgeExtractorInterface
+
+	// classify returns the language of a file of the spec's extensions when
+	// it depends on the project, such as a C header in a C++ project
+	classify func(p *Parser, filePath string) Language
+	// preprocess returns the source the grammar parses, with the same byte
+	// offsets as the file's content
+	preprocess func(p *Parser, filePath string, content []byte) []byte
+
+	// parse reads formats without a tree-sitter grammar
+	parse func(ctx context.Context, p *Parser, filePath string, content []byte) (*ParseResult, error)
+	// contract returns the cross-language contract a node defines, such as
+	// the "rpc::" of a protobuf rpc (see contracts.go), or ""
+	contract func(node *CodeNode) string
+
+	language    *sitter.Language
+	definitions *query
+	calls       *query
+	references  *query
+	imports     *query
+	docs        *query
+}
+
+var registry = struct {
+	sync.RWMutex
+	specs      map[Language]*LanguageSpec
+	extensions map[string]Language
+	patterns   []filePattern
+}{
+	specs:      make(map[Language]*LanguageSpec),
+	extensions: make(map[string]Language),
+}
+
+// RegisterLanguage adds a language, compiling its queries. Its extensions
+// must not belong to another language.
+func RegisterLanguage(spec LanguageSpec) error {
+	if spec.Name == "" {
+		return errors.New("language spec has no name")
+	}
+	if spec.Grammar == nil && spec.parse == nil {
+		return fmt.Errorf("language %s has no grammar", spec.Name)
+	}
+	if spec.Grammar != nil {
+		spec.language = spec.Grammar()
+	}
+
+	if spec.Queries != nil && spec.language != nil {
+		for _, q := range []struct {
+			file string
+			dst  **query
+		}{
+			{"definitions.scm", &spec.definitions},
+			{"calls.scm", &spec.calls},
+			{"references.scm", &spec.references},
+			{"imports.scm", &spec.imports},
+			{"docs.scm", &spec.docs},
+		} {
+			source, err := fs.ReadFile(spec.Queries, q.file)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("language %s: %w", spec.Name, err)
+			}
+			if *q.dst, err = compileQuery(spec.language, source); err != nil {
+				return fmt.Errorf("language %s: %s: %w", spec.Name, q.file, err)
+			}
+		}
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.specs[spec.Name]; ok {
+		return fmt.Errorf("language %s is already registered", spec.Name)
+	}
+	for _, ext := range spec.Extensions {
+		ext = strings.ToLower(ext)
+		if other, ok := registry.extensions[ext]; ok {
+			return fmt.Errorf("language %s: extension %s belongs to %s", spec.Name, ext, other)
+		}
+	}
+	for _, ext := range spec.Extensions {
+		registry.extensions[strings.ToLower(ext)] = spec.Name
+	}
+	for _, pattern := range spec.FilePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("language %s: file pattern %q: %w", spec.Name, pattern, err)
+		}
+		registry.patterns = append(registry.patterns, filePattern{strings.ToLower(pattern), spec.Name})
+	}
+	registry.specs[spec.Name] = &spec
+	return nil
+}
+
+type filePattern struct {
+	pattern string
+	lang    Language
+}
+
+// Languages returns the registered languages, sorted
+func Languages() []Language {
+	registry.RLock()
+	defer registry.RUnlock()
+	langs := make([]Language, 0, len(registry.specs))
+	for lang := range registry.specs {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool { return langs[i] < langs[j] })
+	return langs
+}
+
+// languageSpec returns the spec of a registered language, or nil
+func languageSpec(lang Language) *LanguageSpec {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.specs[lang]
+}
+
+// languageOf returns the language a file's name or extension belongs to,
+// or ""
+func languageOf(filename string) Language {
+	registry.RLock()
+	defer registry.RUnlock()
+	base := strings.ToLower(filepath.Base(filename))
+	for _, fp := range registry.patterns {
+		if ok, _ := filepath.Match(fp.pattern, base); ok {
+			return fp.lang
+		}
+	}
+	return registry.extensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// mustRegisterLanguage registers a built-in language, whose queries are
+// known to compile
+func mustRegisterLanguage(spec LanguageSpec) {
+	if err := RegisterLanguage(spec); err != nil {
+		panic(err)
+	}
+}
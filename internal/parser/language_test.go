@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRegisterLanguage(t *testing.T) {
+	if err := RegisterLanguage(LanguageSpec{Name: "golang2", Extensions: []string{".GO"}, Grammar: languageSpec(LangGo).Grammar}); err == nil {
+		t.Error("expected an error registering an extension of another language")
+	}
+	if err := RegisterLanguage(LanguageSpec{Name: LangGo, Grammar: languageSpec(LangGo).Grammar}); err == nil {
+		t.Error("expected an error registering a language twice")
+	}
+
+	spec := LanguageSpec{
+		Name:       "gotmpl",
+		Extensions: []string{".gotmpl"},
+		Grammar:    languageSpec(LangGo).Grammar,
+		Queries: fstest.MapFS{
+			"definitions.scm": {Data: []byte("(function_declaration name: (identifier) @name) @definition.function")},
+			"calls.scm":       {Data: []byte("(call_expression function: (identifier) @name) @reference.call")},
+		},
+	}
+	if err := RegisterLanguage(spec); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		delete(registry.specs, spec.Name)
+		delete(registry.extensions, ".gotmpl")
+	})
+	p := NewParser()
+	if got := p.DetectLanguage("page.gotmpl"); got != "gotmpl" {
+		t.Fatalf("DetectLanguage = %q, want gotmpl", got)
+	}
+	code := "package page\n\nfunc render() { helper() }\n\nfunc helper() {}\n"
+	result, err := p.ParseContent(context.Background(), "page.gotmpl", "gotmpl", []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, n := range result.Nodes {
+		ids[n.ID] = true
+	}
+	if !ids["page.gotmpl::render"] || !ids["page.gotmpl::helper"] {
+		t.Errorf("expected render and helper nodes, got %v", ids)
+	}
+	found := false
+	for _, e := range result.Edges {
+		found = found || (e.EdgeType == EdgeTypeCalls && e.FromID == "page.gotmpl::render" && e.ToID == "page.gotmpl::helper")
+	}
+	if !found {
+		t.Errorf("expected render to call helper, got %v", result.Edges)
+	}
+
+	bad := LanguageSpec{
+		Name:    "broken",
+		Grammar: languageSpec(LangGo).Grammar,
+		Queries: fstest.MapFS{"calls.scm": {Data: []byte("(no_such_node) @reference.call")}},
+	}
+	if err := RegisterLanguage(bad); err == nil || !strings.Contains(err.Error(), "calls.scm") {
+		t.Errorf("expected an error naming calls.scm, got %v", err)
+	}
+}
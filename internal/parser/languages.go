@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/heefoo/codeloom/internal/parser/grammars/clojure_lang"
+	"github.com/heefoo/codeloom/internal/parser/grammars/commonlisp_lang"
+	"github.com/heefoo/codeloom/internal/parser/grammars/julia_lang"
+	"github.com/smacker/go-tree-sitter/bash"
+	"github.com/smacker/go-tree-sitter/c"
+	"github.com/smacker/go-tree-sitter/cpp"
+	"github.com/smacker/go-tree-sitter/csharp"
+	"github.com/smacker/go-tree-sitter/elixir"
+	"github.com/smacker/go-tree-sitter/golang"
+	"github.com/smacker/go-tree-sitter/java"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/kotlin"
+	"github.com/smacker/go-tree-sitter/lua"
+	"github.com/smacker/go-tree-sitter/php"
+	"github.com/smacker/go-tree-sitter/protobuf"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/ruby"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/scala"
+	"github.com/smacker/go-tree-sitter/sql"
+	"github.com/smacker/go-tree-sitter/swift"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+//go:embed queries
+var builtinQueries embed.FS
+
+// queries returns the query files of a built-in language
+func queries(dir string) fs.FS {
+	sub, err := fs.Sub(builtinQueries, "queries/"+dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+func init() {
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangGo,
+		Extensions:    []string{".go"},
+		Grammar:       golang.GetLanguage,
+		Queries:       queries("go"),
+		refine:        goRefine,
+		packageOf:     goPackage,
+		resolveImport: resolveGoImport,
+		symbolTable:   goSymbolTable,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangPython,
+		Extensions:    []string{".py"},
+		Grammar:       python.GetLanguage,
+		Queries:       queries("python"),
+		packageOf:     pythonPackage,
+		resolveImport: resolvePythonImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangC,
+		Extensions:    []string{".c", ".h"},
+		Grammar:       c.GetLanguage,
+		Queries:       queries("c"),
+		refine:        cRefine,
+		arity:         cArity,
+		resolveImport: resolveCInclude,
+		symbolTable:   cSymbolTable,
+		classify:      cHeaderLanguage,
+		preprocess:    cPreprocess,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangCPP,
+		Extensions:    []string{".cpp", ".cc", ".cxx", ".hpp", ".hxx"},
+		Grammar:       cpp.GetLanguage,
+		Queries:       queries("cpp"),
+		refine:        cppRefine,
+		scopeName:     cppScopeName,
+		arity:         cArity,
+		resolveImport: resolveCInclude,
+		symbolTable:   cSymbolTable,
+		preprocess:    cppPreprocess,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangJavaScript,
+		Extensions:    []string{".js", ".mjs", ".cjs"},
+		Grammar:       javascript.GetLanguage,
+		Queries:       queries("javascript"),
+		refine:        jsRefine,
+		resolveImport: resolveJSImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangTypeScript,
+		Extensions:    []string{".ts", ".mts", ".cts"},
+		Grammar:       typescript.GetLanguage,
+		Queries:       queries("typescript"),
+		refine:        jsRefine,
+		resolveImport: resolveJSImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangTSX,
+		Extensions:    []string{".tsx"},
+		Grammar:       tsx.GetLanguage,
+		Queries:       queries("typescript"),
+		refine:        jsRefine,
+		resolveImport: resolveJSImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangRust,
+		Extensions:    []string{".rs"},
+		Grammar:       rust.GetLanguage,
+		Queries:       queries("rust"),
+		scopeName:     rustScopeName,
+		resolveImport: resolveRustImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangJava,
+		Extensions:    []string{".java"},
+		Grammar:       java.GetLanguage,
+		Queries:       queries("java"),
+		packageOf:     javaPackage,
+		resolveImport: resolveJavaImport(".java"),
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:           LangRuby,
+		Extensions:     []string{".rb", ".rake", ".gemspec"},
+		Grammar:        ruby.GetLanguage,
+		Queries:        queries("ruby"),
+		NameSeparators: []string{"::"},
+		arity:          rubyArity,
+		resolveImport:  resolveRubyImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:           LangPHP,
+		Extensions:     []string{".php"},
+		Grammar:        php.GetLanguage,
+		Queries:        queries("php"),
+		NameSeparators: []string{`\`},
+		packageOf:      declaredNamespace,
+		resolveImport:  resolvePHPImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangCSharp,
+		Extensions:    []string{".cs"},
+		Grammar:       csharp.GetLanguage,
+		Queries:       queries("csharp"),
+		packageOf:     declaredNamespace,
+		resolveImport: resolveCSharpImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangKotlin,
+		Extensions:    []string{".kt", ".kts"},
+		Grammar:       kotlin.GetLanguage,
+		Queries:       queries("kotlin"),
+		arity:         kotlinArity,
+		packageOf:     declaredNamespace,
+		resolveImport: resolveJavaImport(".kt"),
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangSwift,
+		Extensions:    []string{".swift"},
+		Grammar:       swift.GetLanguage,
+		Queries:       queries("swift"),
+		arity:         swiftArity,
+		packageOf:     swiftPackage,
+		resolveImport: resolveSwiftImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangScala,
+		Extensions:    []string{".scala", ".sc"},
+		Grammar:       scala.GetLanguage,
+		Queries:       queries("scala"),
+		packageOf:     declaredNamespace,
+		resolveImport: resolveJavaImport(".scala"),
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangLua,
+		Extensions:    []string{".lua"},
+		Grammar:       lua.GetLanguage,
+		Queries:       queries("lua"),
+		refine:        luaRefine,
+		callee:        luaCallee,
+		arity:         luaArity,
+		resolveImport: resolveLuaImport,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:       LangBash,
+		Extensions: []string{".sh", ".bash"},
+		Grammar:    bash.GetLanguage,
+		Queries:    queries("bash"),
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangElixir,
+		Extensions:    []string{".ex", ".exs"},
+		Grammar:       elixir.GetLanguage,
+		Queries:       queries("elixir"),
+		refine:        elixirRefine,
+		arity:         elixirArity,
+		packageOf:     elixirPackage,
+		resolveImport: resolveElixirImport,
+	})
+
+	// Schemas and specs declare the contracts code in other languages
+	// implements or uses (see contracts.go)
+	mustRegisterLanguage(LanguageSpec{
+		Name:          LangProtobuf,
+		Extensions:    []string{".proto"},
+		Grammar:       protobuf.GetLanguage,
+		Queries:       queries("protobuf"),
+		packageOf:     protobufPackage,
+		resolveImport: resolveProtoImport,
+		contract:      protobufContract,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:       LangSQL,
+		Extensions: []string{".sql"},
+		Grammar:    sql.GetLanguage,
+		Queries:    queries("sql"),
+		contract:   sqlContract,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:       LangGraphQL,
+		Extensions: []string{".graphql", ".graphqls", ".gql"},
+		parse:      parseGraphQL,
+		contract:   graphqlContract,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:         LangOpenAPI,
+		FilePatterns: []string{"openapi*.yaml", "openapi*.yml", "openapi*.json", "swagger*.yaml", "swagger*.yml", "swagger*.json", "*.openapi.yaml", "*.openapi.yml", "*.openapi.json"},
+		parse:        parseOpenAPI,
+		contract:     openapiContract,
+	})
+
+	// Documents hold code blocks in the languages above (see embedded.go)
+	mustRegisterLanguage(LanguageSpec{
+		Name:       LangNotebook,
+		Extensions: []string{".ipynb"},
+		parse:      parseNotebook,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:       LangMarkdown,
+		Extensions: []string{".md", ".mdx", ".markdown"},
+		parse:      parseMarkdown,
+	})
+
+	// The Lisp family and Julia are read by extractors walking the tree
+	mustRegisterLanguage(LanguageSpec{
+		Name:           LangClojure,
+		Extensions:     []string{".clj", ".cljs", ".cljc", ".edn"},
+		Grammar:        clojure_lang.GetLanguage,
+		extractNodes:   (*Parser).extractClojureNodes,
+		collectImports: clojureImports,
+		resolveImport:  resolveClojureImport,
+		symbolTable:    clojureSymbolTable,
+		edgeExtractor:  clojureEdgeExtractor,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:           LangJulia,
+		Extensions:     []string{".jl"},
+		Grammar:        julia_lang.GetLanguage,
+		extractNodes:   (*Parser).extractJuliaNodes,
+		scopeName:      juliaScopeName,
+		collectImports: juliaImports,
+	})
+	mustRegisterLanguage(LanguageSpec{
+		Name:         LangCommonLisp,
+		Extensions:   []string{".lisp", ".lsp", ".cl", ".asd", ".asdf"},
+		Grammar:      commonlisp_lang.GetLanguage,
+		extractNodes: (*Parser).extractCommonLispNodes,
+	})
+}
@@ -0,0 +1,768 @@
+package parser
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Every parsed file gets a module node whose ID is the file path. Files that
+// belong to a package or namespace (a Go import path, Java, Kotlin or Scala
+// package, Python package, PHP or C# namespace, Swift package target, Elixir
+// module, Clojure namespace, Julia module or Lisp package) are linked from a
+// "package::<name>" module node with a contains edge. Imports become imports
+// edges from the file to the file or package they resolve to within the
+// repository, or to an "external::<name>" module node when they do not
+// resolve. Package and external module nodes are shared by every file that
+// refers to them, so they carry no file path.
+
+const (
+	packageModulePrefix  = "package::"
+	externalModulePrefix = "external::"
+)
+
+// importRef is one import as written in a file
+type importRef struct {
+	spec  string   // module as written: "fmt", "./util", "..models", "crate::db"
+	names []string // imported names that may be submodules (Python "from a import b")
+	all   bool     // Java on-demand import (a.b.*)
+	file  bool     // path relative to the importing file (require_relative, source)
+}
+
+// extractModules adds the file's module node, its package and its imports
+func (p *Parser) extractModules(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte, result *ParseResult) {
+	lang := spec.Name
+	result.Nodes = append(result.Nodes, fileModule(lang, filePath, int(root.EndPoint().Row)+1))
+
+	shared := make(map[string]bool)
+	addShared := func(id, name string) {
+		if shared[id] {
+			return
+		}
+		shared[id] = true
+		result.Nodes = append(result.Nodes, CodeNode{
+			ID:       id,
+			Name:     name,
+			NodeType: NodeTypeModule,
+			Language: lang,
+			arity:    -1,
+		})
+	}
+
+	pkg := p.packageOf(spec, root, filePath, content)
+	if pkg != "" {
+		addShared(packageModulePrefix+pkg, pkg)
+	}
+	if !p.extractEdges {
+		return
+	}
+	if pkg != "" {
+		result.Edges = append(result.Edges, CodeEdge{
+			FromID:   packageModulePrefix + pkg,
+			ToID:     filePath,
+			EdgeType: EdgeTypeContains,
+		})
+	}
+
+	seen := map[string]bool{filePath: true}
+	for _, ref := range collectImports(spec, root, content) {
+		target := resolveImport(p, spec, filePath, pkg, ref)
+		if target == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		if name, ok := strings.CutPrefix(target, externalModulePrefix); ok {
+			addShared(target, name)
+		} else if name, ok := strings.CutPrefix(target, packageModulePrefix); ok {
+			addShared(target, name)
+		}
+		result.Edges = append(result.Edges, CodeEdge{
+			FromID:   filePath,
+			ToID:     target,
+			EdgeType: EdgeTypeImports,
+		})
+	}
+}
+
+// fileModule returns the module node of a file
+func fileModule(lang Language, filePath string, endLine int) CodeNode {
+	return CodeNode{
+		ID:        filePath,
+		Name:      filepath.Base(filePath),
+		NodeType:  NodeTypeModule,
+		Language:  lang,
+		FilePath:  filePath,
+		StartLine: 1,
+		EndLine:   endLine,
+		arity:     -1,
+	}
+}
+
+// packageOf returns the package or namespace the file belongs to, or "".
+// For languages read by extractors, the first namespace, module or package
+// form names the file's.
+func (p *Parser) packageOf(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte) string {
+	if spec.packageOf != nil {
+		return spec.packageOf(p, root, filePath, content)
+	}
+	if spec.extractNodes == nil {
+		return ""
+	}
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		scratch := &ParseResult{}
+		spec.extractNodes(p, root.NamedChild(i), filePath, spec.Name, content, scratch)
+		for _, n := range scratch.Nodes {
+			if n.NodeType == NodeTypeModule {
+				return n.Name
+			}
+		}
+	}
+	return ""
+}
+
+// pythonPackage returns the dotted name of the package the file's directory
+// is, if it is one
+func pythonPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	dir := filepath.Dir(filePath)
+	if !isFile(filepath.Join(dir, "__init__.py")) {
+		return ""
+	}
+	rel, err := filepath.Rel(pythonRoot(dir), dir)
+	if err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", ".")
+}
+
+// swiftPackage returns the target of a Swift package the file is in:
+// Sources/<Target>/...
+func swiftPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	dir := filepath.Dir(filePath)
+	if pkgRoot := findUp(dir, "Package.swift"); pkgRoot != "" {
+		rel, err := filepath.Rel(filepath.Join(pkgRoot, "Sources"), dir)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
+		}
+	}
+	return ""
+}
+
+// elixirPackage returns the first module the file defines
+func elixirPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		n := root.NamedChild(i)
+		if _, nodeType := elixirDefinition(n, content); nodeType == NodeTypeModule {
+			if name := elixirDefinitionName(n, content); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// declaredNamespace returns the package or namespace declared at the top of a
+// Kotlin, Scala, PHP or C# file
+func declaredNamespace(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		switch child.Type() {
+		case "package_header":
+			// Kotlin: package com.acme.web
+			if id := childOfType(child, "identifier"); id != nil {
+				return nodeText(id, content)
+			}
+		case "package_clause", "namespace_definition", "namespace_declaration", "file_scoped_namespace_declaration":
+			return nodeText(child.ChildByFieldName("name"), content)
+		}
+	}
+	return ""
+}
+
+// collectImports finds the file's imports with its language's imports query.
+// Matches sharing an @import node make up one import, whose @import.module
+// captures are the segments of its module.
+func collectImports(spec *LanguageSpec, root *sitter.Node, content []byte) []importRef {
+	if spec.collectImports != nil {
+		return spec.collectImports(root, content)
+	}
+	if spec.imports == nil {
+		return nil
+	}
+
+	type pending struct {
+		ref     importRef
+		modules []string
+		prefix  string
+	}
+	var imports []*pending
+	grouped := make(map[nodeKey]*pending)
+	unquote := func(n *sitter.Node) string {
+		return strings.Trim(nodeText(n, content), "\"'`")
+	}
+	for _, m := range spec.imports.matches(root, content) {
+		imp := &pending{}
+		if n := m.node("import"); n != nil {
+			if prev, ok := grouped[keyOf(n)]; ok {
+				imp = prev
+			} else {
+				grouped[keyOf(n)] = imp
+				imports = append(imports, imp)
+			}
+		} else {
+			imports = append(imports, imp)
+		}
+
+		for _, n := range m.captures["import.module"] {
+			imp.modules = append(imp.modules, unquote(n))
+		}
+		if n := m.node("import.file"); n != nil {
+			imp.modules = append(imp.modules, unquote(n))
+			imp.ref.file = true
+		}
+		if n := m.node("import.system"); n != nil {
+			imp.modules = append(imp.modules, "<"+strings.Trim(nodeText(n, content), "<>")+">")
+		}
+		if n := m.node("import.submodule"); n != nil {
+			imp.modules = append(imp.modules, "self::"+nodeText(n, content))
+		}
+		if n := m.node("import.prefix"); n != nil {
+			imp.prefix = nodeText(n, content)
+		}
+		for _, n := range m.captures["import.name"] {
+			imp.ref.names = append(imp.ref.names, nodeText(n, content))
+		}
+		if m.node("import.all") != nil {
+			imp.ref.all = true
+		}
+	}
+
+	sep := "."
+	if len(spec.NameSeparators) > 0 {
+		sep = spec.NameSeparators[0]
+	}
+	var refs []importRef
+	for _, imp := range imports {
+		ref := imp.ref
+		if ref.spec = strings.Join(imp.modules, "."); ref.spec == "" {
+			continue
+		}
+		if imp.prefix != "" {
+			ref.spec = imp.prefix + sep + ref.spec
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// clojureImports returns the namespaces of require and use forms
+func clojureImports(root *sitter.Node, content []byte) []importRef {
+	var refs []importRef
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == "list_lit" && n.NamedChildCount() > 0 {
+			head := nodeText(n.NamedChild(0), content)
+			if head == ":require" || head == ":use" || head == "require" || head == "use" {
+				for i := 1; i < int(n.NamedChildCount()); i++ {
+					child := n.NamedChild(i)
+					if child.Type() == "vec_lit" && child.NamedChildCount() > 0 {
+						child = child.NamedChild(0)
+					}
+					if child.Type() == "sym_lit" {
+						refs = append(refs, importRef{spec: strings.TrimPrefix(nodeText(child, content), "'")})
+					}
+				}
+				return
+			}
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+	return refs
+}
+
+// juliaImports returns the top-level modules of using and import statements
+func juliaImports(root *sitter.Node, content []byte) []importRef {
+	var refs []importRef
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == "using_statement" || n.Type() == "import_statement" {
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				child := n.NamedChild(i)
+				name := string(content[child.StartByte():child.EndByte()])
+				if child.Type() == "selected_import" && child.NamedChildCount() > 0 {
+					name = string(content[child.NamedChild(0).StartByte():child.NamedChild(0).EndByte()])
+				}
+				name = strings.SplitN(name, ":", 2)[0]
+				if name = strings.TrimSpace(strings.SplitN(name, ".", 2)[0]); name != "" {
+					refs = append(refs, importRef{spec: name})
+				}
+			}
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+	return refs
+}
+
+// resolveImport returns the module an import refers to: a file path or
+// "package::<name>" within the repository, or "external::<name>"
+func resolveImport(p *Parser, spec *LanguageSpec, filePath, pkg string, ref importRef) string {
+	if spec.resolveImport != nil {
+		return spec.resolveImport(p, filePath, pkg, ref)
+	}
+	if ref.file {
+		return resolveFileImport(filepath.Dir(filePath), ref.spec, "")
+	}
+	return externalModulePrefix + ref.spec
+}
+
+// resolveCInclude resolves #include "x.h" relative to the including file,
+// and both forms through the include paths of the file's compile command
+// (see compile_commands.go)
+func resolveCInclude(p *Parser, filePath, pkg string, ref importRef) string {
+	quoted := !strings.HasPrefix(ref.spec, "<")
+	spec := strings.Trim(ref.spec, "<>")
+	if quoted {
+		if target := filepath.Join(filepath.Dir(filePath), filepath.FromSlash(spec)); isFile(target) {
+			return target
+		}
+	}
+	if u := p.compileUnitOf(filePath); u != nil {
+		if target := u.resolveInclude(filepath.Dir(filePath), spec, quoted); target != "" {
+			return pathLike(filePath, target)
+		}
+	}
+	return externalModulePrefix + spec
+}
+
+// resolveRubyImport resolves require_relative from the file and require from
+// the lib directory of the load path: require "shop/item" loads
+// lib/shop/item.rb
+func resolveRubyImport(p *Parser, filePath, pkg string, ref importRef) string {
+	dir := filepath.Dir(filePath)
+	if ref.file {
+		return resolveFileImport(dir, ref.spec, ".rb")
+	}
+	rel := filepath.FromSlash(ref.spec)
+	if filepath.Ext(rel) == "" {
+		rel += ".rb"
+	}
+	if base := findUp(dir, filepath.Join("lib", rel)); base != "" {
+		return filepath.Join(base, "lib", rel)
+	}
+	return externalModulePrefix + strings.SplitN(ref.spec, "/", 2)[0]
+}
+
+// resolvePHPImport resolves require and include from the file, and use of a
+// class, or a function or constant, from a namespace
+func resolvePHPImport(p *Parser, filePath, pkg string, ref importRef) string {
+	dir := filepath.Dir(filePath)
+	if ref.file {
+		return resolveFileImport(dir, strings.TrimPrefix(ref.spec, "/"), "")
+	}
+	return resolveNamespaceImport(dir, pkg, strings.TrimPrefix(ref.spec, "\\"), "\\", ".php", true)
+}
+
+func resolveCSharpImport(p *Parser, filePath, pkg string, ref importRef) string {
+	return resolveNamespaceImport(filepath.Dir(filePath), pkg, ref.spec, ".", ".cs", false)
+}
+
+// resolveSwiftImport resolves an import of another target of the file's
+// Swift package
+func resolveSwiftImport(p *Parser, filePath, pkg string, ref importRef) string {
+	if pkgRoot := findUp(filepath.Dir(filePath), "Package.swift"); pkgRoot != "" && isDir(filepath.Join(pkgRoot, "Sources", ref.spec)) {
+		return packageModulePrefix + ref.spec
+	}
+	return externalModulePrefix + ref.spec
+}
+
+// resolveLuaImport resolves require "a.b", which loads a/b.lua or
+// a/b/init.lua
+func resolveLuaImport(p *Parser, filePath, pkg string, ref importRef) string {
+	rel := filepath.FromSlash(strings.ReplaceAll(ref.spec, ".", "/"))
+	for _, candidate := range []string{rel + ".lua", filepath.Join(rel, "init.lua")} {
+		if base := findUp(filepath.Dir(filePath), candidate); base != "" {
+			return filepath.Join(base, candidate)
+		}
+	}
+	return externalModulePrefix + strings.SplitN(ref.spec, ".", 2)[0]
+}
+
+// resolvePythonImport resolves relative imports from the file's package and
+// absolute ones from the directory or the root of its packages
+func resolvePythonImport(p *Parser, filePath, pkg string, ref importRef) string {
+	dir := filepath.Dir(filePath)
+	spec := ref.spec
+	var bases []string
+	if dots := len(spec) - len(strings.TrimLeft(spec, ".")); dots > 0 {
+		// from .. import x: one directory up per dot after the first
+		base := dir
+		for i := 1; i < dots; i++ {
+			base = filepath.Dir(base)
+		}
+		bases = []string{base}
+		spec = spec[dots:]
+	} else {
+		bases = []string{dir, pythonRoot(dir)}
+	}
+
+	for _, base := range bases {
+		// from a import b may import the submodule a.b
+		for _, name := range ref.names {
+			if target := pythonModuleFile(base, joinDotted(spec, name)); target != "" {
+				return target
+			}
+		}
+		if target := pythonModuleFile(base, spec); target != "" {
+			return target
+		}
+	}
+	if spec == "" {
+		return ""
+	}
+	return externalModulePrefix + strings.SplitN(spec, ".", 2)[0]
+}
+
+func joinDotted(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "." + b
+}
+
+// pythonModuleFile returns the file defining the dotted module under base
+func pythonModuleFile(base, dotted string) string {
+	if dotted == "" {
+		if target := filepath.Join(base, "__init__.py"); isFile(target) {
+			return target
+		}
+		return ""
+	}
+	path := filepath.Join(base, filepath.FromSlash(strings.ReplaceAll(dotted, ".", "/")))
+	if isFile(path + ".py") {
+		return path + ".py"
+	}
+	if target := filepath.Join(path, "__init__.py"); isFile(target) {
+		return target
+	}
+	return ""
+}
+
+// pythonRoot returns the directory absolute imports in dir resolve from: the
+// parent of the outermost package containing dir
+func pythonRoot(dir string) string {
+	for isFile(filepath.Join(dir, "__init__.py")) {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return dir
+}
+
+var jsExtensions = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}
+
+// resolveJSImport resolves relative imports to files, trying the extensions
+// and index files bundlers do, and bare ones to npm packages
+func resolveJSImport(p *Parser, filePath, pkg string, ref importRef) string {
+	dir, spec := filepath.Dir(filePath), ref.spec
+	if !strings.HasPrefix(spec, ".") && !strings.HasPrefix(spec, "/") {
+		return externalModulePrefix + jsPackageName(spec)
+	}
+
+	base := filepath.Join(dir, filepath.FromSlash(spec))
+	if isFile(base) {
+		return base
+	}
+	// TypeScript imports compiled names: ./util.js may be ./util.ts
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	for _, candidate := range []string{base, stem, filepath.Join(base, "index")} {
+		for _, ext := range jsExtensions {
+			if isFile(candidate + ext) {
+				return candidate + ext
+			}
+		}
+	}
+	return ""
+}
+
+// jsPackageName returns the npm package of a bare specifier: "lodash/fp" is
+// "lodash" and "@scope/pkg/sub" is "@scope/pkg"
+func jsPackageName(spec string) string {
+	parts := strings.Split(spec, "/")
+	if strings.HasPrefix(spec, "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// resolveJavaImport returns the import resolver of a language that lays
+// packages out as directories of ext files: Java, Kotlin and Scala
+func resolveJavaImport(ext string) func(p *Parser, filePath, pkg string, ref importRef) string {
+	return func(p *Parser, filePath, pkg string, ref importRef) string {
+		return resolvePackageImport(filepath.Dir(filePath), pkg, ref, ext)
+	}
+}
+
+func resolvePackageImport(dir, pkg string, ref importRef, ext string) string {
+	// The source root is the directory the file's package path hangs off
+	root := dir
+	if pkg != "" {
+		pkgPath := filepath.FromSlash(strings.ReplaceAll(pkg, ".", "/"))
+		if strings.HasSuffix(dir, string(filepath.Separator)+pkgPath) {
+			root = strings.TrimSuffix(dir, string(filepath.Separator)+pkgPath)
+		}
+	}
+
+	parts := strings.Split(ref.spec, ".")
+	if !ref.all {
+		// a.b.C, or a.b.C.member for static imports
+		for i := len(parts); i > 0; i-- {
+			target := filepath.Join(root, filepath.Join(parts[:i]...)) + ext
+			if isFile(target) {
+				return target
+			}
+		}
+		parts = parts[:len(parts)-1]
+	}
+	for i := len(parts); i > 0; i-- {
+		if isDir(filepath.Join(root, filepath.Join(parts[:i]...))) {
+			return packageModulePrefix + strings.Join(parts[:i], ".")
+		}
+	}
+	return externalModulePrefix + ref.spec
+}
+
+// resolveFileImport resolves an import of a path relative to the importing
+// file, adding ext when the path has no extension
+func resolveFileImport(dir, spec, ext string) string {
+	target := filepath.FromSlash(spec)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	if ext != "" && filepath.Ext(target) == "" {
+		target += ext
+	}
+	if isFile(target) {
+		return target
+	}
+	return ""
+}
+
+// resolveNamespaceImport resolves a PHP or C# import from a file in namespace
+// ns. Directories that follow the namespace lead to the file declaring an
+// imported class; otherwise imports sharing the file's root namespace are
+// taken to be from the repository. class reports whether the last segment of
+// spec names a class rather than a namespace.
+func resolveNamespaceImport(dir, ns, spec, sep, ext string, class bool) string {
+	// Find the directory the file's namespace is laid out from: App\Http in
+	// app/Http hangs off app with App still to match
+	nsParts := strings.Split(ns, sep)
+	root := dir
+	for len(nsParts) > 0 && filepath.Base(root) == nsParts[len(nsParts)-1] {
+		root = filepath.Dir(root)
+		nsParts = nsParts[:len(nsParts)-1]
+	}
+
+	parts := strings.Split(spec, sep)
+	rel := parts
+	for i := 0; i < len(nsParts) && len(rel) > 0 && rel[0] == nsParts[i]; i++ {
+		rel = rel[1:]
+	}
+	if len(rel) > 0 {
+		if target := filepath.Join(root, filepath.Join(rel...)) + ext; isFile(target) {
+			return target
+		}
+	}
+
+	namespace := parts
+	if class && len(namespace) > 1 {
+		namespace = namespace[:len(namespace)-1]
+	}
+	if ns != "" && parts[0] == strings.Split(ns, sep)[0] {
+		return packageModulePrefix + strings.Join(namespace, sep)
+	}
+	return externalModulePrefix + parts[0]
+}
+
+// resolveElixirImport resolves an alias, import, require or use of a module:
+// Shop.LineItem is defined in lib/shop/line_item.ex of the Mix project
+func resolveElixirImport(p *Parser, filePath, module string, ref importRef) string {
+	dir, spec := filepath.Dir(filePath), ref.spec
+	if project := findUp(dir, "mix.exs"); project != "" {
+		parts := strings.Split(spec, ".")
+		for i, part := range parts {
+			parts[i] = elixirUnderscore(part)
+		}
+		if target := filepath.Join(project, "lib", filepath.Join(parts...)) + ".ex"; isFile(target) {
+			return target
+		}
+	}
+	root := strings.SplitN(spec, ".", 2)[0]
+	if module != "" && root == strings.SplitN(module, ".", 2)[0] {
+		// A module of the same application defined in a file that does
+		// not follow the naming convention
+		return ""
+	}
+	return externalModulePrefix + root
+}
+
+// elixirUnderscore converts a module name segment to its file name:
+// LineItem is line_item and HTTPClient is http_client
+func elixirUnderscore(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'A' && c <= 'Z' {
+			prevLower := i > 0 && (name[i-1] < 'A' || name[i-1] > 'Z') && name[i-1] != '_'
+			nextLower := i > 0 && i+1 < len(name) && name[i+1] >= 'a' && name[i+1] <= 'z' &&
+				name[i-1] >= 'A' && name[i-1] <= 'Z'
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// resolveRustImport resolves a use of crate::, self:: or super:: paths to the
+// file of the longest module they name
+func resolveRustImport(p *Parser, filePath, pkg string, ref importRef) string {
+	segments := strings.Split(strings.TrimPrefix(ref.spec, "::"), "::")
+	var base string
+	switch segments[0] {
+	case "crate":
+		crate := findUp(filepath.Dir(filePath), "Cargo.toml")
+		if crate == "" {
+			return ""
+		}
+		base = filepath.Join(crate, "src")
+		segments = segments[1:]
+	case "self", "super":
+		base = rustModuleDir(filePath)
+		for len(segments) > 0 && (segments[0] == "self" || segments[0] == "super") {
+			if segments[0] == "super" {
+				base = filepath.Dir(base)
+			}
+			segments = segments[1:]
+		}
+	default:
+		return externalModulePrefix + segments[0]
+	}
+
+	// The longest prefix of the path that names a module file
+	for i := len(segments); i > 0; i-- {
+		path := filepath.Join(base, filepath.Join(segments[:i]...))
+		if isFile(path + ".rs") {
+			return path + ".rs"
+		}
+		if target := filepath.Join(path, "mod.rs"); isFile(target) {
+			return target
+		}
+	}
+	return ""
+}
+
+// rustModuleDir returns the directory holding the submodules of the module a
+// file defines
+func rustModuleDir(filePath string) string {
+	dir := filepath.Dir(filePath)
+	switch filepath.Base(filePath) {
+	case "mod.rs", "lib.rs", "main.rs":
+		return dir
+	}
+	return filepath.Join(dir, strings.TrimSuffix(filepath.Base(filePath), ".rs"))
+}
+
+// resolveClojureImport resolves a required namespace to its file under the
+// source root
+func resolveClojureImport(p *Parser, filePath, ns string, ref importRef) string {
+	spec := ref.spec
+	// The source root is the directory the file's namespace path hangs off
+	root := filepath.Dir(filePath)
+	if parts := strings.Split(ns, "."); len(parts) > 1 {
+		for range parts[:len(parts)-1] {
+			root = filepath.Dir(root)
+		}
+	}
+	path := filepath.Join(root, filepath.FromSlash(strings.ReplaceAll(strings.ReplaceAll(spec, "-", "_"), ".", "/")))
+	for _, ext := range []string{".clj", ".cljc", ".cljs"} {
+		if isFile(path + ext) {
+			return path + ext
+		}
+	}
+	return externalModulePrefix + spec
+}
+
+// javaPackage returns the name in the file's package declaration
+func javaPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		if child.Type() != "package_declaration" {
+			continue
+		}
+		for j := 0; j < int(child.NamedChildCount()); j++ {
+			name := child.NamedChild(j)
+			if name.Type() == "scoped_identifier" || name.Type() == "identifier" {
+				return string(content[name.StartByte():name.EndByte()])
+			}
+		}
+	}
+	return ""
+}
+
+// findGoModule returns the directory of the go.mod governing dir and the
+// module path it declares
+func findGoModule(dir string) (root, path string) {
+	root = findUp(dir, "go.mod")
+	if root == "" {
+		return "", ""
+	}
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module"); ok {
+			return root, strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return "", ""
+}
+
+// findUp returns the closest directory at or above dir containing name
+func findUp(dir, name string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,361 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files (relative path -> content) under a temp directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// moduleEdges parses file and returns its imports and contains edges as
+// "type target", with paths relative to root
+func moduleEdges(t *testing.T, root, file string) []string {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(file))
+	p := NewParser()
+	p.AddProjectRoot(root)
+	result, err := p.ParseFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := func(id string) string {
+		if r, err := filepath.Rel(root, id); err == nil && !strings.HasPrefix(r, "..") {
+			return filepath.ToSlash(r)
+		}
+		return id
+	}
+	var edges []string
+	for _, e := range result.Edges {
+		switch e.EdgeType {
+		case EdgeTypeImports:
+			if e.FromID != path {
+				t.Errorf("import edge from %s, want the file", e.FromID)
+			}
+			edges = append(edges, "imports "+rel(e.ToID))
+		case EdgeTypeContains:
+			if e.ToID != path {
+				t.Errorf("contains edge to %s, want the file", e.ToID)
+			}
+			edges = append(edges, "in "+e.FromID)
+		}
+	}
+	sort.Strings(edges)
+
+	var fileModules int
+	for _, n := range result.Nodes {
+		if n.ID == path && n.NodeType == NodeTypeModule {
+			fileModules++
+		}
+	}
+	if fileModules != 1 {
+		t.Errorf("expected one module node for %s, got %d", file, fileModules)
+	}
+	return edges
+}
+
+func TestModuleImports(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		file  string
+		want  []string
+	}{
+		{"go", map[string]string{
+			"go.mod": "module example.com/app\n\ngo 1.22\n",
+			"cmd/main.go": `package main
+
+import (
+	"fmt"
+	"example.com/app/internal/store"
+	"example.com/app/missing"
+	"github.com/pkg/errors"
+)
+`,
+			"internal/store/db.go": "package store\n",
+		}, "cmd/main.go", []string{
+			"imports external::example.com/app/missing",
+			"imports external::fmt",
+			"imports external::github.com/pkg/errors",
+			"imports package::example.com/app/internal/store",
+			"in package::example.com/app/cmd",
+		}},
+
+		{"python", map[string]string{
+			"app/__init__.py":        "",
+			"app/models/__init__.py": "",
+			"app/models/user.py":     "",
+			"app/db.py":              "",
+			"app/views/__init__.py":  "",
+			"app/views/home.py": `import os.path
+import app.db
+from .. import models
+from ..models.user import User
+from . import missing
+import requests as r
+`,
+		}, "app/views/home.py", []string{
+			"imports app/db.py",
+			"imports app/models/__init__.py",
+			"imports app/models/user.py",
+			"imports app/views/__init__.py",
+			"imports external::os",
+			"imports external::requests",
+			"in package::app.views",
+		}},
+
+		{"typescript", map[string]string{
+			"src/util.ts":          "",
+			"src/lib/index.ts":     "",
+			"src/styles/theme.tsx": "",
+			"src/app.ts": `import { a } from "./util.js";
+import * as lib from "./lib";
+export { theme } from "./styles/theme";
+import React from "react";
+import { x } from "@scope/pkg/sub";
+const fs = require("fs");
+const lazy = import("./util");
+`,
+		}, "src/app.ts", []string{
+			"imports external::@scope/pkg",
+			"imports external::fs",
+			"imports external::react",
+			"imports src/lib/index.ts",
+			"imports src/styles/theme.tsx",
+			"imports src/util.ts",
+		}},
+
+		{"java", map[string]string{
+			"src/com/acme/util/Strings.java": "package com.acme.util;\n",
+			"src/com/acme/model/User.java":   "package com.acme.model;\n",
+			"src/com/acme/App.java": `package com.acme;
+
+import com.acme.util.Strings;
+import com.acme.model.*;
+import static com.acme.util.Strings.trim;
+import java.util.List;
+`,
+		}, "src/com/acme/App.java", []string{
+			"imports external::java.util.List",
+			"imports package::com.acme.model",
+			"imports src/com/acme/util/Strings.java",
+			"in package::com.acme",
+		}},
+
+		{"rust", map[string]string{
+			"Cargo.toml":        "[package]\nname = \"app\"\n",
+			"src/db/mod.rs":     "",
+			"src/db/pool.rs":    "",
+			"src/config.rs":     "",
+			"src/server/mod.rs": "",
+			"src/main.rs": `mod config;
+mod server;
+use crate::db::pool::Pool;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+`,
+		}, "src/main.rs", []string{
+			"imports external::serde",
+			"imports external::std",
+			"imports src/config.rs",
+			"imports src/db/pool.rs",
+			"imports src/server/mod.rs",
+		}},
+
+		{"c", map[string]string{
+			"include/util.h": "",
+			"src/local.h":    "",
+			"src/main.c": `#include <stdio.h>
+#include "local.h"
+#include "../include/util.h"
+#include "generated.h"
+`,
+		}, "src/main.c", []string{
+			"imports external::generated.h",
+			"imports external::stdio.h",
+			"imports include/util.h",
+			"imports src/local.h",
+		}},
+
+		{"kotlin", map[string]string{
+			"src/com/acme/util/Strings.kt": "package com.acme.util\n",
+			"src/com/acme/model/User.kt":   "package com.acme.model\n",
+			"src/com/acme/App.kt": `package com.acme
+
+import com.acme.util.Strings
+import com.acme.model.*
+import kotlin.math.max as mx
+`,
+		}, "src/com/acme/App.kt", []string{
+			"imports external::kotlin.math.max",
+			"imports package::com.acme.model",
+			"imports src/com/acme/util/Strings.kt",
+			"in package::com.acme",
+		}},
+
+		{"php", map[string]string{
+			"app/Models/User.php": "<?php\nnamespace App\\Models;\n",
+			"app/Http/boot.php":   "<?php\n",
+			"app/Http/Controller.php": `<?php
+namespace App\Http;
+
+use App\Models\User;
+use App\Support\{Str, Arr as A};
+use Illuminate\Http\Request;
+require_once __DIR__ . '/boot.php';
+`,
+		}, "app/Http/Controller.php", []string{
+			"imports app/Http/boot.php",
+			"imports app/Models/User.php",
+			"imports external::Illuminate",
+			"imports package::App\\Support",
+			"in package::App\\Http",
+		}},
+
+		{"csharp", map[string]string{
+			"src/Models/User.cs": "namespace Acme.Models;\n",
+			"src/Web/Home.cs": `using System;
+using Json = Newtonsoft.Json;
+using Acme.Models;
+using Acme.Data;
+
+namespace Acme.Web;
+`,
+		}, "src/Web/Home.cs", []string{
+			"imports external::Newtonsoft",
+			"imports external::System",
+			"imports package::Acme.Data",
+			"imports package::Acme.Models",
+			"in package::Acme.Web",
+		}},
+
+		{"swift", map[string]string{
+			"Package.swift":             "// swift-tools-version:5.9\n",
+			"Sources/Models/User.swift": "",
+			"Sources/App/main.swift": `import Foundation
+import struct Models.User
+`,
+		}, "Sources/App/main.swift", []string{
+			"imports external::Foundation",
+			"imports package::Models",
+			"in package::App",
+		}},
+
+		{"ruby", map[string]string{
+			"lib/shop/item.rb": "",
+			"lib/shop/util.rb": "",
+			"lib/shop/cart.rb": `require "json"
+require "shop/item"
+require_relative "util"
+require_relative "missing"
+`,
+		}, "lib/shop/cart.rb", []string{
+			"imports external::json",
+			"imports lib/shop/item.rb",
+			"imports lib/shop/util.rb",
+		}},
+
+		{"lua", map[string]string{
+			"lib/util.lua":     "",
+			"lib/net/init.lua": "",
+			"lib/main.lua": `local json = require("cjson.safe")
+local util = require "util"
+local net = require("net")
+`,
+		}, "lib/main.lua", []string{
+			"imports external::cjson",
+			"imports lib/net/init.lua",
+			"imports lib/util.lua",
+		}},
+
+		{"bash", map[string]string{
+			"scripts/common.sh": "",
+			"scripts/deploy.sh": `source ./common.sh
+. "$DIR/env.sh"
+`,
+		}, "scripts/deploy.sh", []string{
+			"imports scripts/common.sh",
+		}},
+
+		{"elixir", map[string]string{
+			"mix.exs":                 "",
+			"lib/shop/line_item.ex":   "",
+			"lib/shop/http_client.ex": "",
+			"lib/shop/cart.ex": `defmodule Shop.Cart do
+  alias Shop.{LineItem, HTTPClient}
+  alias Shop.Undocumented
+  import Ecto.Query
+  use GenServer
+end
+`,
+		}, "lib/shop/cart.ex", []string{
+			"imports external::Ecto",
+			"imports external::GenServer",
+			"imports lib/shop/http_client.ex",
+			"imports lib/shop/line_item.ex",
+			"in package::Shop.Cart",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := writeTree(t, tt.files)
+			got := moduleEdges(t, root, tt.file)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSharedModuleNodes(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() { fmt.Println(os.Args) }
+`
+	result, err := NewParser().ParseContent(context.Background(), "main.go", LangGo, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modules := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		if n.NodeType == NodeTypeModule {
+			modules[n.ID] = n
+		}
+		if n.NodeType == NodeTypeImport {
+			t.Errorf("unexpected import node %s", n.ID)
+		}
+	}
+	if n, ok := modules["main.go"]; !ok || n.Name != "main.go" || n.Content != "" {
+		t.Errorf("expected a file module node without content, got %+v", n)
+	}
+	for _, id := range []string{"external::fmt", "external::os"} {
+		if n, ok := modules[id]; !ok || n.FilePath != "" {
+			t.Errorf("expected shared module node %s without a file, got %+v", id, n)
+		}
+	}
+}
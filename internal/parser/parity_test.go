@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// specChanges are the lines the language specs extract that the
+// hand-written extractors they replaced did not, by sample, each with the
+// reason it is intended. They are taken out of the output before it is
+// compared with the golden file, so the recording stays as it was made.
+var specChanges = map[string][]struct {
+	line   string
+	reason string
+}{
+	"python/shapes.py": {
+		// The old extractor handed Python's call nodes to the generic edge
+		// extractor, which only read call_expression, so no Python call
+		// was ever recorded
+		{"edge calls testdata/parity/python/shapes.py::Shape.area -> testdata/parity/python/shapes.py::Shape._compute",
+			"a call on self resolves to the method of the enclosing class"},
+		{"edge calls testdata/parity/python/shapes.py::Shape._compute -> external::helpers.measure",
+			"a call through an imported module resolves to an external node"},
+		{"edge calls testdata/parity/python/shapes.py::Circle.area -> testdata/parity/python/shapes.py::Circle.area.square",
+			"a call to a function nested in the caller resolves to it"},
+		{"edge calls testdata/parity/python/shapes.py::make -> testdata/parity/python/shapes.py::Circle",
+			"a constructor call resolves to the class"},
+		{"edge calls testdata/parity/python/shapes.py::make -> testdata/parity/python/shapes.py::Shape",
+			"a constructor call resolves to the class"},
+	},
+}
+
+// TestExtractionParity parses the samples under testdata/parity and compares
+// the nodes and edges with the golden files next to them. The golden files
+// were recorded with the hand-written extractors the language specs replaced,
+// less the intended differences in specChanges, so a difference is a change
+// in extraction; rerun with -update only when the change is intended.
+func TestExtractionParity(t *testing.T) {
+	samples, err := filepath.Glob(filepath.Join("testdata", "parity", "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range samples {
+		if strings.HasSuffix(sample, ".golden") {
+			continue
+		}
+		t.Run(filepath.ToSlash(sample), func(t *testing.T) {
+			var got strings.Builder
+			for _, mode := range []struct {
+				name string
+				opts []ParserOption
+			}{
+				{"default", nil},
+				{"symbol table", []ParserOption{WithSymbolTable()}},
+			} {
+				result, err := NewParser(mode.opts...).ParseFile(context.Background(), sample)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rendered := renderResult(result)
+				for _, change := range specChanges[strings.TrimPrefix(filepath.ToSlash(sample), "testdata/parity/")] {
+					var found bool
+					if rendered, found = withoutLine(rendered, change.line); !found {
+						t.Errorf("%s: no longer extracted (%s): %s", mode.name, change.reason, change.line)
+					}
+				}
+				fmt.Fprintf(&got, "# %s\n%s", mode.name, rendered)
+			}
+
+			golden := sample + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("extraction differs from %s:\n%s", golden, lineDiff(string(want), got.String()))
+			}
+		})
+	}
+}
+
+func TestReferenceEdges(t *testing.T) {
+	tests := []struct {
+		sample string
+		want   []string
+	}{
+		{"python/shapes.py", []string{"extends Circle -> Shape"}},
+		{"ruby/cart.rb", []string{"extends Shop.Cart -> Base"}},
+		{"kotlin/UserService.kt", []string{"implements UserService -> Service"}},
+		{"php/User.php", []string{"extends User -> Model", "implements User -> JsonSerializable"}},
+		{"tsx/button.tsx", []string{"extends Counter -> external::React.Component"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			file := filepath.Join("testdata", "parity", filepath.FromSlash(tt.sample))
+			result, err := NewParser().ParseFile(context.Background(), file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]bool)
+			for _, e := range result.Edges {
+				local := func(id string) string { return strings.TrimPrefix(id, file+"::") }
+				got[fmt.Sprintf("%s %s -> %s", e.EdgeType, local(e.FromID), local(e.ToID))] = true
+			}
+			for _, want := range tt.want {
+				if !got[want] {
+					t.Errorf("missing edge %s, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+// renderResult prints nodes and edges one per line, sorted. Uses, extends
+// and implements edges came with the references queries and have no
+// recording to compare with.
+func renderResult(result *ParseResult) string {
+	var nodes []string
+	for _, n := range result.Nodes {
+		var annotations []string
+		for k, v := range n.Annotations {
+			annotations = append(annotations, k+"="+v)
+		}
+		sort.Strings(annotations)
+		firstLine, _, _ := strings.Cut(n.Content, "\n")
+		nodes = append(nodes, fmt.Sprintf("node %s %s name=%q lines=%d-%d doc=%q annotations=%q content=%d:%q",
+			n.ID, n.NodeType, n.Name, n.StartLine, n.EndLine, n.DocComment, annotations, len(n.Content), firstLine))
+	}
+	sort.Strings(nodes)
+
+	var edges []string
+	for _, e := range result.Edges {
+		switch e.EdgeType {
+		case EdgeTypeUses, EdgeTypeExtends, EdgeTypeImplements:
+			continue
+		}
+		edges = append(edges, fmt.Sprintf("edge %s %s -> %s", e.EdgeType, e.FromID, e.ToID))
+	}
+	sort.Strings(edges)
+	return strings.Join(append(nodes, edges...), "\n") + "\n"
+}
+
+// withoutLine removes the first occurrence of line from text
+func withoutLine(text, line string) (string, bool) {
+	lines := strings.Split(text, "\n")
+	i := slices.Index(lines, line)
+	if i < 0 {
+		return text, false
+	}
+	return strings.Join(slices.Delete(lines, i, i+1), "\n"), true
+}
+
+// lineDiff lists the lines only in want (-) or only in got (+)
+func lineDiff(want, got string) string {
+	count := func(s string) map[string]int {
+		m := make(map[string]int)
+		for _, line := range strings.Split(s, "\n") {
+			m[line]++
+		}
+		return m
+	}
+	wantLines, gotLines := count(want), count(got)
+	var diff []string
+	for line, n := range wantLines {
+		for i := gotLines[line]; i < n; i++ {
+			diff = append(diff, "- "+line)
+		}
+	}
+	for line, n := range gotLines {
+		for i := wantLines[line]; i < n; i++ {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i][2:] < diff[j][2:] })
+	return strings.Join(diff, "\n")
+}
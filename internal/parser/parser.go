@@ -7,21 +7,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"sync"
 
-	"github.com/heefoo/codeloom/internal/parser/grammars/clojure_lang"
-	"github.com/heefoo/codeloom/internal/parser/grammars/commonlisp_lang"
-	"github.com/heefoo/codeloom/internal/parser/grammars/julia_lang"
 	"github.com/heefoo/codeloom/internal/util"
 	sitter "github.com/smacker/go-tree-sitter"
-	"github.com/smacker/go-tree-sitter/c"
-	"github.com/smacker/go-tree-sitter/cpp"
-	"github.com/smacker/go-tree-sitter/golang"
-	"github.com/smacker/go-tree-sitter/java"
-	"github.com/smacker/go-tree-sitter/javascript"
-	"github.com/smacker/go-tree-sitter/python"
-	"github.com/smacker/go-tree-sitter/rust"
-	"github.com/smacker/go-tree-sitter/typescript/typescript"
 )
 
 type Language string
@@ -33,11 +21,27 @@ const (
 	LangPython     Language = "python"
 	LangJavaScript Language = "javascript"
 	LangTypeScript Language = "typescript"
+	LangTSX        Language = "tsx"
 	LangRust       Language = "rust"
 	LangJava       Language = "java"
 	LangClojure    Language = "clojure"
 	LangJulia      Language = "julia"
 	LangCommonLisp Language = "commonlisp"
+	LangRuby       Language = "ruby"
+	LangPHP        Language = "php"
+	LangCSharp     Language = "csharp"
+	LangKotlin     Language = "kotlin"
+	LangSwift      Language = "swift"
+	LangScala      Language = "scala"
+	LangLua        Language = "lua"
+	LangBash       Language = "bash"
+	LangElixir     Language = "elixir"
+	LangProtobuf   Language = "protobuf"
+	LangSQL        Language = "sql"
+	LangGraphQL    Language = "graphql"
+	LangOpenAPI    Language = "openapi"
+	LangNotebook   Language = "notebook"
+	LangMarkdown   Language = "markdown"
 )
 
 type NodeType string
@@ -54,6 +58,10 @@ const (
 	NodeTypeType      NodeType = "type"
 	NodeTypeMacro     NodeType = "macro"
 	NodeTypeModule    NodeType = "module"
+	NodeTypeTable     NodeType = "table"
+	NodeTypeColumn    NodeType = "column"
+	NodeTypeOperation NodeType = "operation" // RPC, GraphQL query or mutation, API operation
+	NodeTypeRoute     NodeType = "route"
 )
 
 type EdgeType string
@@ -64,6 +72,8 @@ const (
 	EdgeTypeUses       EdgeType = "uses"
 	EdgeTypeExtends    EdgeType = "extends"
 	EdgeTypeImplements EdgeType = "implements"
+	EdgeTypeContains   EdgeType = "contains"
+	EdgeTypeDefinedIn  EdgeType = "defined_in"
 )
 
 type CodeNode struct {
@@ -80,6 +90,8 @@ type CodeNode struct {
 	Signature   string            `json:"signature,omitempty"`
 	DocComment  string            `json:"doc_comment,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"` // @semantic fields
+
+	arity int // parameter count used to tell overloads apart, -1 if unknown
 }
 
 type CodeEdge struct {
@@ -92,13 +104,18 @@ type ParseResult struct {
 	Nodes      []CodeNode
 	Edges      []CodeEdge
 	FilesTotal int // Total files found (supported languages)
+
+	// SyntaxErrors are the ranges of the file its grammar could not read
+	// and ErrorRatio the share of its bytes they cover (see diagnostics.go)
+	SyntaxErrors []SyntaxError
+	ErrorRatio   float64
 }
 
 type Parser struct {
-	languages         map[Language]*sitter.Language
-	mu                sync.RWMutex
-	enableSymbolTable bool // Opt-in for Go, Clojure, C symbol resolution
-	extractEdges      bool // Enable edge extraction
+	enableSymbolTable bool        // Opt-in for Go, Clojure, C symbol resolution
+	extractEdges      bool        // Enable edge extraction
+	trees             *TreeCache  // Optional; reparses files incrementally (see tree_cache.go)
+	compileDBs        *compileDBs // nil when compile_commands.json is not read (see compile_commands.go)
 }
 
 // ParserOption configures the parser
@@ -120,66 +137,33 @@ func WithEdgeExtraction() ParserOption {
 
 func NewParser(opts ...ParserOption) *Parser {
 	p := &Parser{
-		languages:    make(map[Language]*sitter.Language),
 		extractEdges: true, // Enable by default
+		compileDBs:   newCompileDBs(),
 	}
 
 	for _, opt := range opts {
 		opt(p)
 	}
 
-	// Register built-in languages
-	p.languages[LangC] = c.GetLanguage()
-	p.languages[LangCPP] = cpp.GetLanguage()
-	p.languages[LangGo] = golang.GetLanguage()
-	p.languages[LangPython] = python.GetLanguage()
-	p.languages[LangJavaScript] = javascript.GetLanguage()
-	p.languages[LangTypeScript] = typescript.GetLanguage()
-	p.languages[LangRust] = rust.GetLanguage()
-	p.languages[LangJava] = java.GetLanguage()
-
-	// Register custom grammars (Lisp family + Julia)
-	p.languages[LangClojure] = clojure_lang.GetLanguage()
-	p.languages[LangJulia] = julia_lang.GetLanguage()
-	p.languages[LangCommonLisp] = commonlisp_lang.GetLanguage()
-
 	return p
 }
 
+// GetLanguage returns the grammar of a registered language, or nil
 func (p *Parser) GetLanguage(lang Language) *sitter.Language {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
-	return p.languages[lang]
+	if spec := languageSpec(lang); spec != nil {
+		return spec.language
+	}
+	return nil
 }
 
+// DetectLanguage returns the registered language of a file by its
+// extension, or ""
 func (p *Parser) DetectLanguage(filename string) Language {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".c", ".h":
-		return LangC
-	case ".cpp", ".cc", ".cxx", ".hpp", ".hxx":
-		return LangCPP
-	case ".go":
-		return LangGo
-	case ".py":
-		return LangPython
-	case ".js", ".mjs", ".cjs":
-		return LangJavaScript
-	case ".ts", ".tsx":
-		return LangTypeScript
-	case ".rs":
-		return LangRust
-	case ".java":
-		return LangJava
-	case ".clj", ".cljs", ".cljc", ".edn":
-		return LangClojure
-	case ".jl":
-		return LangJulia
-	case ".lisp", ".lsp", ".cl", ".asd", ".asdf":
-		return LangCommonLisp
-	default:
-		return ""
+	lang := languageOf(filename)
+	if spec := languageSpec(lang); spec != nil && spec.classify != nil {
+		return spec.classify(p, filename)
 	}
+	return lang
 }
 
 // IsSupportedFile returns true if the file extension is supported
@@ -202,18 +186,31 @@ func (p *Parser) ParseFile(ctx context.Context, filePath string) (*ParseResult,
 }
 
 func (p *Parser) ParseContent(ctx context.Context, filePath string, lang Language, content []byte) (*ParseResult, error) {
-	language := p.GetLanguage(lang)
-	if language == nil {
+	spec := languageSpec(lang)
+	if spec == nil {
 		return nil, fmt.Errorf("language not supported: %s", lang)
 	}
 
-	parser := sitter.NewParser()
-	parser.SetLanguage(language)
-	defer parser.Close()
+	if spec.parse != nil {
+		result, err := spec.parse(ctx, p, filePath, content)
+		if err != nil {
+			return nil, err
+		}
+		disambiguateIDs(result.Nodes)
+		p.addContracts(spec, filePath, result)
+		if p.extractEdges {
+			resolveLocalTargets(result, filePath)
+		}
+		return result, nil
+	}
 
-	tree, err := parser.ParseCtx(ctx, nil, content)
+	source := content
+	if spec.preprocess != nil {
+		source = spec.preprocess(p, filePath, content)
+	}
+	tree, err := p.parseTree(ctx, spec, filePath, source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse: %w", err)
+		return nil, err
 	}
 	defer tree.Close()
 
@@ -222,13 +219,20 @@ func (p *Parser) ParseContent(ctx context.Context, filePath string, lang Languag
 		Edges: []CodeEdge{},
 	}
 
-	// Extract nodes based on language
 	rootNode := tree.RootNode()
-	p.extractNodes(rootNode, filePath, lang, content, result)
+	result.SyntaxErrors, result.ErrorRatio = collectSyntaxErrors(rootNode, content)
+	defNames := p.extractNodes(spec, rootNode, filePath, content, result)
+	disambiguateIDs(result.Nodes)
+	p.extractModules(spec, rootNode, filePath, content, result)
+	p.addContracts(spec, filePath, result)
 
 	// Extract edges if enabled
 	if p.extractEdges {
-		p.extractEdgesForFile(rootNode, filePath, lang, content, result)
+		p.extractEdgesForFile(spec, rootNode, filePath, content, result, defNames)
+		if spec.contract == nil {
+			p.linkContracts(rootNode, filePath, content, result)
+		}
+		resolveLocalTargets(result, filePath)
 	}
 
 	return result, nil
@@ -241,8 +245,8 @@ type lineRange struct {
 	id    string
 }
 
-// extractEdgesForFile extracts call edges from the AST
-func (p *Parser) extractEdgesForFile(root *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
+// extractEdgesForFile extracts call and reference edges from the AST
+func (p *Parser) extractEdgesForFile(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte, result *ParseResult, defNames map[nodeKey]bool) {
 	// Build a sorted list of function line ranges for O(log n) lookup
 	var funcRanges []lineRange
 	for _, node := range result.Nodes {
@@ -255,49 +259,23 @@ func (p *Parser) extractEdgesForFile(root *sitter.Node, filePath string, lang La
 		}
 	}
 
-	// Create appropriate extractor based on language and symbol table setting
 	var symbolTable SymbolTable
-
-	if p.enableSymbolTable {
-		switch lang {
-		case LangGo:
-			goSymTable := NewGoSymbolTable()
-			goSymTable.BuildFromAST(root, filePath, content)
-			symbolTable = goSymTable
-		case LangClojure:
-			cljSymTable := NewClojureSymbolTable()
-			cljSymTable.BuildFromAST(root, filePath, content)
-			symbolTable = cljSymTable
-		case LangC, LangCPP:
-			cSymTable := NewCSymbolTable()
-			cSymTable.BuildFromAST(root, filePath, content)
-			symbolTable = cSymTable
-		}
+	if p.enableSymbolTable && spec.symbolTable != nil {
+		symbolTable = spec.symbolTable(p, root, filePath, content)
 	}
 
-	// Single-pass edge extraction with function context lookup
-	if lang == LangClojure {
-		var cljSymTable *ClojureSymbolTable
-		if symbolTable != nil {
-			cljSymTable = symbolTable.(*ClojureSymbolTable)
-		}
-		extractor := NewClojureEdgeExtractor(cljSymTable)
-		p.extractEdgesSinglePass(root, filePath, content, result, funcRanges, extractor)
+	if spec.calls != nil || spec.references != nil {
+		p.extractQueryEdges(spec, root, filePath, content, result, funcRanges, defNames, symbolTable)
 		return
 	}
 
-	if lang == LangC || lang == LangCPP {
-		var cSymTable *CSymbolTable
-		if symbolTable != nil {
-			cSymTable = symbolTable.(*CSymbolTable)
-		}
-		extractor := NewCEdgeExtractor(cSymTable)
-		p.extractEdgesSinglePass(root, filePath, content, result, funcRanges, extractor)
-		return
+	// Languages without queries walk the tree with their edge extractor
+	var extractor edgeExtractorInterface
+	if spec.edgeExtractor != nil {
+		extractor = spec.edgeExtractor(symbolTable)
+	} else {
+		extractor = NewEdgeExtractor(symbolTable)
 	}
-
-	// Generic extractor
-	extractor := NewEdgeExtractor(symbolTable)
 	p.extractEdgesSinglePass(root, filePath, content, result, funcRanges, extractor)
 }
 
@@ -351,487 +329,29 @@ func (p *Parser) extractEdgesSinglePass(node *sitter.Node, filePath string, cont
 	}
 }
 
-func (p *Parser) extractNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	nodeType := node.Type()
-
-	// Extract based on node type
-	switch lang {
-	case LangGo:
-		p.extractGoNodes(node, filePath, lang, content, result)
-	case LangPython:
-		p.extractPythonNodes(node, filePath, lang, content, result)
-	case LangC, LangCPP:
-		p.extractCNodes(node, filePath, lang, content, result)
-	case LangJavaScript, LangTypeScript:
-		p.extractJSNodes(node, filePath, lang, content, result)
-	case LangRust:
-		p.extractRustNodes(node, filePath, lang, content, result)
-	case LangJava:
-		p.extractJavaNodes(node, filePath, lang, content, result)
-	case LangClojure:
-		p.extractClojureNodes(node, filePath, lang, content, result)
-	case LangJulia:
-		p.extractJuliaNodes(node, filePath, lang, content, result)
-	case LangCommonLisp:
-		p.extractCommonLispNodes(node, filePath, lang, content, result)
-	default:
-		// Generic extraction
-		p.extractGenericNodes(node, filePath, lang, content, result)
-	}
-
-	// Recursively process children
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		p.extractNodes(child, filePath, lang, content, result)
-	}
-
-	_ = nodeType // silence unused warning
-}
-
-func (p *Parser) extractGoNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "function_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			codeNode := CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeFunction,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				StartCol:    int(node.StartPoint().Column),
-				EndCol:      int(node.EndPoint().Column),
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			}
-			result.Nodes = append(result.Nodes, codeNode)
-		}
-
-	case "method_declaration":
-		name := p.getChildByField(node, "name", content)
-		receiver := p.getChildByField(node, "receiver", content)
-		if name != "" {
-			fullName := name
-			if receiver != "" {
-				fullName = fmt.Sprintf("%s.%s", receiver, name)
-			}
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, fullName),
-				Name:        fullName,
-				NodeType:    NodeTypeMethod,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "type_declaration":
-		// Check for struct or interface
-		for i := 0; i < int(node.ChildCount()); i++ {
-			child := node.Child(i)
-			if child.Type() == "type_spec" {
-				typeName := p.getChildByField(child, "name", content)
-				typeNode := child.ChildByFieldName("type")
-				if typeNode != nil && typeName != "" {
-					var nt NodeType
-					switch typeNode.Type() {
-					case "struct_type":
-						nt = NodeTypeStruct
-					case "interface_type":
-						nt = NodeTypeInterface
-					default:
-						nt = NodeTypeType
-					}
-					result.Nodes = append(result.Nodes, CodeNode{
-						ID:          fmt.Sprintf("%s::%s", filePath, typeName),
-						Name:        typeName,
-						NodeType:    nt,
-						Language:    lang,
-						FilePath:    filePath,
-						StartLine:   int(node.StartPoint().Row) + 1,
-						EndLine:     int(node.EndPoint().Row) + 1,
-						Content:     string(content[node.StartByte():node.EndByte()]),
-						DocComment:  p.extractDocComment(node, content),
-						Annotations: p.extractAnnotations(node, content),
-					})
-				}
-			}
-		}
-
-	case "import_declaration":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::import_%d", filePath, node.StartPoint().Row),
-			Name:      "import",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
+// extractNodes adds the nodes of a file: those its language's definitions
+// query finds, or its extractor finds walking the tree. It returns the name
+// nodes of the definitions the query found.
+func (p *Parser) extractNodes(spec *LanguageSpec, root *sitter.Node, filePath string, content []byte, result *ParseResult) map[nodeKey]bool {
+	if spec.definitions != nil {
+		return p.extractDefinitions(spec, root, filePath, content, result)
 	}
-}
-
-func (p *Parser) extractPythonNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "function_definition":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeFunction,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "class_definition":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeClass,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "import_statement", "import_from_statement":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::import_%d", filePath, node.StartPoint().Row),
-			Name:      "import",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
+	if spec.extractNodes != nil {
+		p.walkNodes(spec, root, filePath, content, result)
 	}
+	return nil
 }
 
-func (p *Parser) extractCNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "function_definition":
-		// Get declarator which contains the function name
-		declarator := node.ChildByFieldName("declarator")
-		if declarator != nil {
-			name := p.extractFunctionName(declarator, content)
-			if name != "" {
-				result.Nodes = append(result.Nodes, CodeNode{
-					ID:          fmt.Sprintf("%s::%s", filePath, name),
-					Name:        name,
-					NodeType:    NodeTypeFunction,
-					Language:    lang,
-					FilePath:    filePath,
-					StartLine:   int(node.StartPoint().Row) + 1,
-					EndLine:     int(node.EndPoint().Row) + 1,
-					Content:     string(content[node.StartByte():node.EndByte()]),
-					DocComment:  p.extractDocComment(node, content),
-					Annotations: p.extractAnnotations(node, content),
-				})
-			}
-		}
-
-	case "struct_specifier":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeStruct,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "enum_specifier":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeEnum,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "preproc_include":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::include_%d", filePath, node.StartPoint().Row),
-			Name:      "include",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
+// walkNodes runs a language's extractor on every node of the tree
+func (p *Parser) walkNodes(spec *LanguageSpec, node *sitter.Node, filePath string, content []byte, result *ParseResult) {
+	extracted := len(result.Nodes)
+	spec.extractNodes(p, node, filePath, spec.Name, content, result)
+	for i := extracted; i < len(result.Nodes); i++ {
+		p.qualifyNode(&result.Nodes[i], node, spec, content)
 	}
-}
-
-func (p *Parser) extractJSNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "function_declaration", "function":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeFunction,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
 
-	case "class_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeClass,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "method_definition":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeMethod,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "import_statement":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::import_%d", filePath, node.StartPoint().Row),
-			Name:      "import",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
-	}
-}
-
-func (p *Parser) extractRustNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "function_item":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeFunction,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "struct_item":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeStruct,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "enum_item":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeEnum,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "trait_item":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeInterface,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "use_declaration":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::use_%d", filePath, node.StartPoint().Row),
-			Name:      "use",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
-	}
-}
-
-func (p *Parser) extractJavaNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	switch node.Type() {
-	case "method_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeMethod,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "class_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeClass,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "interface_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeInterface,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "enum_declaration":
-		name := p.getChildByField(node, "name", content)
-		if name != "" {
-			result.Nodes = append(result.Nodes, CodeNode{
-				ID:          fmt.Sprintf("%s::%s", filePath, name),
-				Name:        name,
-				NodeType:    NodeTypeEnum,
-				Language:    lang,
-				FilePath:    filePath,
-				StartLine:   int(node.StartPoint().Row) + 1,
-				EndLine:     int(node.EndPoint().Row) + 1,
-				Content:     string(content[node.StartByte():node.EndByte()]),
-				DocComment:  p.extractDocComment(node, content),
-				Annotations: p.extractAnnotations(node, content),
-			})
-		}
-
-	case "import_declaration":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::import_%d", filePath, node.StartPoint().Row),
-			Name:      "import",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
+	for i := 0; i < int(node.ChildCount()); i++ {
+		p.walkNodes(spec, node.Child(i), filePath, content, result)
 	}
 }
 
@@ -928,7 +448,7 @@ func (p *Parser) extractClojureNodes(node *sitter.Node, filePath string, lang La
 		result.Nodes = append(result.Nodes, CodeNode{
 			ID:        fmt.Sprintf("%s::ns_%s", filePath, formName),
 			Name:      formName,
-			NodeType:  NodeTypeImport,
+			NodeType:  NodeTypeModule,
 			Language:  lang,
 			FilePath:  filePath,
 			StartLine: int(node.StartPoint().Row) + 1,
@@ -975,6 +495,20 @@ func (p *Parser) getClojureDocstring(node *sitter.Node, content []byte) string {
 	return ""
 }
 
+func clojureSymbolTable(p *Parser, root *sitter.Node, filePath string, content []byte) SymbolTable {
+	symbols := NewClojureSymbolTable()
+	symbols.BuildFromAST(root, filePath, content)
+	return symbols
+}
+
+func clojureEdgeExtractor(symbols SymbolTable) edgeExtractorInterface {
+	var cljSymbols *ClojureSymbolTable
+	if symbols != nil {
+		cljSymbols = symbols.(*ClojureSymbolTable)
+	}
+	return NewClojureEdgeExtractor(cljSymbols)
+}
+
 func (p *Parser) extractJuliaNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
 	switch node.Type() {
 	case "function_definition", "short_function_definition":
@@ -1057,7 +591,7 @@ func (p *Parser) extractJuliaNodes(node *sitter.Node, filePath string, lang Lang
 			result.Nodes = append(result.Nodes, CodeNode{
 				ID:          fmt.Sprintf("%s::module_%s", filePath, name),
 				Name:        name,
-				NodeType:    NodeTypeImport,
+				NodeType:    NodeTypeModule,
 				Language:    lang,
 				FilePath:    filePath,
 				StartLine:   int(node.StartPoint().Row) + 1,
@@ -1068,18 +602,6 @@ func (p *Parser) extractJuliaNodes(node *sitter.Node, filePath string, lang Lang
 			})
 		}
 
-	case "import_statement", "using_statement":
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::import_%d", filePath, node.StartPoint().Row),
-			Name:      "import",
-			NodeType:  NodeTypeImport,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
-
 	case "macro_definition":
 		name := p.getChildByField(node, "name", content)
 		if name != "" {
@@ -1100,6 +622,14 @@ func (p *Parser) extractJuliaNodes(node *sitter.Node, filePath string, lang Lang
 	}
 }
 
+// juliaScopeName names the module a Julia definition is in
+func juliaScopeName(n *sitter.Node, content []byte) string {
+	if n.Type() != "module_definition" {
+		return ""
+	}
+	return nodeText(n.ChildByFieldName("name"), content)
+}
+
 func (p *Parser) extractCommonLispNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
 	switch node.Type() {
 	case "defun_form":
@@ -1211,7 +741,7 @@ func (p *Parser) extractCommonLispNodes(node *sitter.Node, filePath string, lang
 			result.Nodes = append(result.Nodes, CodeNode{
 				ID:        fmt.Sprintf("%s::package_%s", filePath, name),
 				Name:      name,
-				NodeType:  NodeTypeImport,
+				NodeType:  NodeTypeModule,
 				Language:  lang,
 				FilePath:  filePath,
 				StartLine: int(node.StartPoint().Row) + 1,
@@ -1402,6 +932,7 @@ func cleanComment(comment string) string {
 		line = strings.TrimPrefix(line, "#")
 		line = strings.TrimPrefix(line, ";")
 		line = strings.TrimPrefix(line, ";;")
+		line = strings.TrimPrefix(line, "--")
 		line = strings.TrimSpace(line)
 		if line != "" {
 			cleaned = append(cleaned, line)
@@ -1480,27 +1011,6 @@ func (p *Parser) extractJuliaFunctionName(node *sitter.Node, content []byte) str
 	return ""
 }
 
-func (p *Parser) extractGenericNodes(node *sitter.Node, filePath string, lang Language, content []byte, result *ParseResult) {
-	// Generic extraction for unknown languages
-	nodeType := node.Type()
-	if strings.Contains(nodeType, "function") || strings.Contains(nodeType, "method") {
-		name := p.getChildByField(node, "name", content)
-		if name == "" {
-			name = fmt.Sprintf("anonymous_%d", node.StartPoint().Row)
-		}
-		result.Nodes = append(result.Nodes, CodeNode{
-			ID:        fmt.Sprintf("%s::%s", filePath, name),
-			Name:      name,
-			NodeType:  NodeTypeFunction,
-			Language:  lang,
-			FilePath:  filePath,
-			StartLine: int(node.StartPoint().Row) + 1,
-			EndLine:   int(node.EndPoint().Row) + 1,
-			Content:   string(content[node.StartByte():node.EndByte()]),
-		})
-	}
-}
-
 func (p *Parser) getChildByField(node *sitter.Node, field string, content []byte) string {
 	child := node.ChildByFieldName(field)
 	if child != nil {
@@ -1509,35 +1019,21 @@ func (p *Parser) getChildByField(node *sitter.Node, field string, content []byte
 	return ""
 }
 
-func (p *Parser) extractFunctionName(node *sitter.Node, content []byte) string {
-	// For C/C++, function name can be nested in declarators
-	switch node.Type() {
-	case "identifier":
-		return string(content[node.StartByte():node.EndByte()])
-	case "function_declarator":
-		declarator := node.ChildByFieldName("declarator")
-		if declarator != nil {
-			return p.extractFunctionName(declarator, content)
-		}
-	case "pointer_declarator":
-		declarator := node.ChildByFieldName("declarator")
-		if declarator != nil {
-			return p.extractFunctionName(declarator, content)
-		}
-	}
-
-	// Try first child as fallback
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		if child.Type() == "identifier" {
-			return string(content[child.StartByte():child.EndByte()])
-		}
-		if name := p.extractFunctionName(child, content); name != "" {
-			return name
-		}
+// declNode builds the node of a declaration n named name, with its doc
+// comment and annotations
+func (p *Parser) declNode(n *sitter.Node, name string, nodeType NodeType, filePath string, lang Language, content []byte) CodeNode {
+	return CodeNode{
+		ID:          fmt.Sprintf("%s::%s", filePath, name),
+		Name:        name,
+		NodeType:    nodeType,
+		Language:    lang,
+		FilePath:    filePath,
+		StartLine:   int(n.StartPoint().Row) + 1,
+		EndLine:     int(n.EndPoint().Row) + 1,
+		Content:     string(content[n.StartByte():n.EndByte()]),
+		DocComment:  p.extractDocComment(n, content),
+		Annotations: p.extractAnnotations(n, content),
 	}
-
-	return ""
 }
 
 // shouldExclude checks if a path should be excluded based on patterns
@@ -0,0 +1,41 @@
+package parser
+
+import "testing"
+
+func TestExtractBash(t *testing.T) {
+	code := `#!/bin/bash
+set -e
+
+# Greets someone
+greet() {
+  echo "hi $1"
+  log_msg "greeted"
+}
+
+function log_msg {
+  printf '%s\n' "$1"
+  "$HANDLER" "$1"
+}
+
+greet world
+`
+	nodes, calls := parseSample(t, "deploy.sh", LangBash, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"greet":   NodeTypeFunction,
+		"log_msg": NodeTypeFunction,
+	}, []string{
+		"greet -> log_msg",
+	})
+
+	if doc := nodes["greet"].DocComment; doc != "Greets someone" {
+		t.Errorf("expected doc comment 'Greets someone', got %q", doc)
+	}
+	for call := range calls {
+		if call == "greet -> echo" || call == "log_msg -> printf" {
+			t.Errorf("did not expect a call edge to a builtin: %s", call)
+		}
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected one call edge, got %v", calls)
+	}
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// cArity counts the parameters of a C/C++ function definition, whose
+// parameter list is inside its declarator
+func cArity(def *sitter.Node) int {
+	return countParameters(cParameterList(def.ChildByFieldName("declarator")))
+}
+
+// cParameterList finds the parameter list of a C/C++ function declarator,
+// which may be wrapped in pointer or reference declarators
+func cParameterList(declarator *sitter.Node) *sitter.Node {
+	for d := declarator; d != nil; d = d.ChildByFieldName("declarator") {
+		if d.Type() == "function_declarator" {
+			return d.ChildByFieldName("parameters")
+		}
+	}
+	return nil
+}
+
+func cSymbolTable(p *Parser, root *sitter.Node, filePath string, content []byte) SymbolTable {
+	symbols := NewCSymbolTable()
+	symbols.parser = p
+	symbols.BuildFromAST(root, filePath, content)
+	return symbols
+}
+
+func cPreprocess(p *Parser, filePath string, content []byte) []byte {
+	return preprocessC(p, LangC, filePath, content)
+}
+
+func cppPreprocess(p *Parser, filePath string, content []byte) []byte {
+	return preprocessC(p, LangCPP, filePath, content)
+}
+
+// cRefine ends a macro at its last line rather than at the newline that
+// ends its directive
+func cRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	if def.Type() == "preproc_function_def" {
+		node.Content = strings.TrimRight(node.Content, "\r\n")
+		node.EndLine = node.StartLine + strings.Count(node.Content, "\n")
+	}
+}
+
+// cppRefine includes the template header of a template in its node, makes
+// functions defined in a class methods, and names functions defined out of
+// their class or namespace by their qualified name: Shape::area defined in
+// namespace geo is geo.Shape.area.
+func cppRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	cRefine(p, node, def, content)
+	outer := def
+	if parent := def.Parent(); parent != nil && parent.Type() == "template_declaration" {
+		outer = parent
+		node.StartLine = int(parent.StartPoint().Row) + 1
+		node.Content = string(content[parent.StartByte():def.EndByte()])
+		if node.DocComment == "" {
+			node.DocComment = p.extractDocComment(parent, content)
+		}
+	}
+	if def.Type() != "function_definition" {
+		return
+	}
+	if parent := outer.Parent(); parent != nil && parent.Type() == "field_declaration_list" {
+		node.NodeType = NodeTypeMethod
+		return
+	}
+
+	var name *sitter.Node
+	for d := def.ChildByFieldName("declarator"); d != nil; {
+		if d.Type() == "function_declarator" {
+			name = d.ChildByFieldName("declarator")
+			break
+		}
+		if d.Type() == "reference_declarator" {
+			d = d.NamedChild(0)
+		} else {
+			d = d.ChildByFieldName("declarator")
+		}
+	}
+	if name == nil || name.Type() != "qualified_identifier" {
+		return
+	}
+	path, scope := cppQualifiedPath(name, content)
+	if len(path) == 0 {
+		return
+	}
+	node.Name = path[len(path)-1]
+	node.ID = node.FilePath + "::" + strings.Join(path, ".")
+	if cppIsMember(path, scope) {
+		node.NodeType = NodeTypeMethod
+	}
+}
+
+// cppQualifiedPath returns the segments of a qualified name, without
+// template arguments, and the node of its innermost scope
+func cppQualifiedPath(n *sitter.Node, content []byte) ([]string, *sitter.Node) {
+	var path []string
+	var scope *sitter.Node
+	segment := func(n *sitter.Node) {
+		if n.Type() == "template_type" || n.Type() == "template_function" {
+			n = n.ChildByFieldName("name")
+		}
+		if text := nodeText(n, content); text != "" {
+			path = append(path, text)
+		}
+	}
+	for n != nil && n.Type() == "qualified_identifier" {
+		if s := n.ChildByFieldName("scope"); s != nil {
+			segment(s)
+			scope = s
+		}
+		n = n.ChildByFieldName("name")
+	}
+	if n != nil {
+		segment(n)
+	}
+	return path, scope
+}
+
+// cppIsMember reports whether a function defined by a qualified name is a
+// member of a class: its scope is a template, it is a constructor or
+// destructor, or its scope is capitalized, as class names conventionally
+// are and namespace names are not
+func cppIsMember(path []string, scope *sitter.Node) bool {
+	if scope == nil || len(path) < 2 {
+		return false
+	}
+	if scope.Type() == "template_type" {
+		return true
+	}
+	class, name := path[len(path)-2], path[len(path)-1]
+	if name == class || name == "~"+class {
+		return true
+	}
+	return class[0] >= 'A' && class[0] <= 'Z'
+}
+
+// cppScopeName names a namespace declared with a nested name, such as
+// namespace a::b, by its path
+func cppScopeName(n *sitter.Node, content []byte) string {
+	name := n.ChildByFieldName("name")
+	if n.Type() != "namespace_definition" || name == nil || name.Type() != "nested_namespace_specifier" {
+		return ""
+	}
+	var path []string
+	for i := 0; i < int(name.NamedChildCount()); i++ {
+		path = append(path, nodeText(name.NamedChild(i), content))
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,317 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileCommands(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"include/geo/shape.h": "#pragma once\n" +
+			"namespace geo {\n" +
+			"class Shape {\n" +
+			"public:\n" +
+			"    virtual double area() const = 0;\n" +
+			"};\n" +
+			"}\n",
+		"src/lib/util.h": "int clamp(int x);\n",
+		"src/circle.cpp": "#include <geo/shape.h>\n" +
+			"#include \"util.h\"\n" +
+			"#include <vector>\n" +
+			"#ifdef FAST_MATH\n" +
+			"double fast_area(double r) { return 3 * r * r; }\n" +
+			"#else\n" +
+			"double exact_area(double r) { return 3.14159 * r * r; }\n" +
+			"#endif\n" +
+			"#if defined(LOGGING) && LEVEL > 2\n" +
+			"void trace() {}\n" +
+			"#endif\n" +
+			"#ifdef PLATFORM_MACRO\n" +
+			"void platform() {}\n" +
+			"#endif\n",
+		"legacy/main.c":   "#include \"config.h\"\n",
+		"legacy/config.h": "#define SIZE 4\n",
+	})
+	dir := filepath.Join(root, "build")
+	db, err := json.Marshal([]map[string]any{
+		{
+			"directory": dir,
+			"file":      "../src/circle.cpp",
+			"arguments": []string{"clang++", "-std=c++20", "-I", "../include", "-iquote../src/lib", "-DFAST_MATH", "-ULOGGING", "-c", "../src/circle.cpp"},
+		},
+		{
+			"directory": root,
+			"file":      "legacy/main.c",
+			"command":   `cc -DNAME="\"a b\"" -c legacy/main.c`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "compile_commands.json"), db, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser()
+	p.AddProjectRoot(root)
+	for file, want := range map[string]Language{
+		"include/geo/shape.h": LangCPP, // included by a C++ translation unit
+		"legacy/config.h":     LangC,
+		"src/circle.cpp":      LangCPP,
+	} {
+		if got := p.DetectLanguage(filepath.Join(root, file)); got != want {
+			t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, want)
+		}
+	}
+
+	if u := p.compileUnitOf(filepath.Join(root, "legacy", "main.c")); u == nil || u.defines["NAME"] != `"a b"` {
+		t.Errorf("expected NAME defined by the shell command, got %+v", u)
+	}
+
+	if got := moduleEdges(t, root, "src/circle.cpp"); strings.Join(got, ", ") != "imports external::vector, imports include/geo/shape.h, imports src/lib/util.h" {
+		t.Errorf("unexpected imports: %v", got)
+	}
+
+	result, err := p.ParseFile(context.Background(), filepath.Join(root, "src", "circle.cpp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, n := range result.Nodes {
+		names[n.Name] = true
+	}
+	for name, want := range map[string]bool{
+		"fast_area":  true,  // -DFAST_MATH
+		"exact_area": false, // its #else branch
+		"trace":      false, // -ULOGGING
+		"platform":   true,  // not known, so kept
+	} {
+		if names[name] != want {
+			t.Errorf("node %s found: %v, want %v", name, names[name], want)
+		}
+	}
+
+	header, err := p.ParseFile(context.Background(), filepath.Join(root, "include", "geo", "shape.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, n := range header.Nodes {
+		if strings.HasSuffix(n.ID, "::geo.Shape.area") && n.NodeType == NodeTypeMethod && n.Language == LangCPP {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected method geo.Shape.area read as C++, got %v", header.Nodes)
+	}
+}
+
+func TestCompileCommandsLookup(t *testing.T) {
+	outer := t.TempDir()
+	root := filepath.Join(outer, "project")
+	file := filepath.Join(root, "src", "main.c")
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("int main(void) { return 0; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeDB := func(dir, define string, mod time.Time) {
+		t.Helper()
+		db, err := json.Marshal([]map[string]any{{
+			"directory": filepath.Dir(file),
+			"file":      "main.c",
+			"arguments": []string{"cc", "-D" + define, "-c", "main.c"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "compile_commands.json")
+		if err := os.WriteFile(path, db, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defined := func(p *Parser, name string) bool {
+		u := p.compileUnitOf(file)
+		return u != nil && u.defines[name] != ""
+	}
+
+	// A database above the project root is not the project's
+	writeDB(outer, "OUTSIDE", time.Now())
+	p := NewParser()
+	p.AddProjectRoot(root)
+	if defined(p, "OUTSIDE") {
+		t.Error("expected the search to stop at the project root")
+	}
+
+	// Edits to the database are picked up
+	now := time.Now()
+	writeDB(root, "FIRST", now)
+	if !defined(p, "FIRST") {
+		t.Error("expected the project's database to be read")
+	}
+	writeDB(root, "SECOND", now.Add(time.Second))
+	if !defined(p, "SECOND") || defined(p, "FIRST") {
+		t.Error("expected the edited database to be re-read")
+	}
+
+	disabled := NewParser(WithCompileCommands(false))
+	disabled.AddProjectRoot(root)
+	if disabled.compileUnitOf(file) != nil {
+		t.Error("expected no compile database when the lookup is off")
+	}
+}
+
+func TestHeaderLanguageWithoutCompileCommands(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"cpp/include/widget.h": "class Widget {};\n",
+		"cpp/src/widget.cpp":   "",
+		"cpp/src/main.cc":      "",
+		"c/list.h":             "struct list;\n",
+		"c/list.c":             "",
+	})
+	p := NewParser()
+	for file, want := range map[string]Language{
+		"cpp/include/widget.h": LangCPP,
+		"c/list.h":             LangC,
+	} {
+		if got := p.DetectLanguage(filepath.Join(root, file)); got != want {
+			t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, want)
+		}
+	}
+}
+
+func TestPreprocessC(t *testing.T) {
+	code := "#ifndef LIST_H\n" +
+		"#define LIST_H\n" +
+		"#define MODE 2\n" +
+		"#if 0\n" +
+		"void disabled(void) {}\n" +
+		"#elif MODE == 2\n" +
+		"void second(void) {}\n" +
+		"#else\n" +
+		"void third(void) {}\n" +
+		"#endif\n" +
+		"#ifdef __cplusplus\n" +
+		"extern \"C\" {\n" +
+		"#endif\n" +
+		"#ifdef _WIN32\n" +
+		"#define EXPORT 1\n" +
+		"#endif\n" +
+		"#if EXPORT\n" +
+		"void exported(void) {}\n" +
+		"#endif\n" +
+		"#define MAX(a, b) \\\n" +
+		"    ((a) > (b) ? (a) : (b))\n" +
+		"int biggest(int x) { return MAX(x, 0); }\n" +
+		"#endif\n"
+
+	result, err := NewParser().ParseContent(context.Background(), "list.h", LangC, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[n.ID] = n
+	}
+	for id, want := range map[string]bool{
+		"list.h::disabled": false,
+		"list.h::second":   true,
+		"list.h::third":    false,
+		"list.h::exported": true, // EXPORT may be defined
+		"list.h::biggest":  true,
+	} {
+		if _, ok := nodes[id]; ok != want {
+			t.Errorf("node %s found: %v, want %v", id, ok, want)
+		}
+	}
+	if second := nodes["list.h::second"]; second.Content != "void second(void) {}" {
+		t.Errorf("expected the source as written, got %q", second.Content)
+	}
+
+	macro, ok := nodes["list.h::MAX"]
+	if !ok || macro.NodeType != NodeTypeMacro || macro.StartLine != 20 || macro.EndLine != 21 {
+		t.Errorf("expected macro MAX on lines 20-21, got %+v", macro)
+	}
+	calls := false
+	for _, e := range result.Edges {
+		if e.FromID == "list.h::biggest" && e.ToID == "list.h::MAX" && e.EdgeType == EdgeTypeCalls {
+			calls = true
+		}
+	}
+	if !calls {
+		t.Errorf("expected biggest to call the macro MAX, got %v", result.Edges)
+	}
+}
+
+func TestExtractCPP(t *testing.T) {
+	code := "namespace geo::shapes {\n" +
+		"// A box of anything\n" +
+		"template <typename T>\n" +
+		"class Box {\n" +
+		"public:\n" +
+		"    Box() {}\n" +
+		"    ~Box() {}\n" +
+		"    T get() const;\n" +
+		"    bool operator==(const Box &other) const { return true; }\n" +
+		"};\n" +
+		"\n" +
+		"template <typename T>\n" +
+		"T Box<T>::get() const { return value; }\n" +
+		"\n" +
+		"class Circle;\n" +
+		"}\n" +
+		"\n" +
+		"double geo::shapes::Circle::area() const { return 0; }\n" +
+		"Circle &geo::Canvas::draw() { return *this; }\n" +
+		"void util::log() {}\n"
+
+	result, err := NewParser().ParseContent(context.Background(), "box.cpp", LangCPP, []byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[n.ID] = n
+	}
+	for id, want := range map[string]struct {
+		nodeType NodeType
+		name     string
+		start    int
+	}{
+		"box.cpp::geo.shapes.Box":            {NodeTypeClass, "Box", 3},
+		"box.cpp::geo.shapes.Box.Box":        {NodeTypeMethod, "Box", 6},
+		"box.cpp::geo.shapes.Box.~Box":       {NodeTypeMethod, "~Box", 7},
+		"box.cpp::geo.shapes.Box.operator==": {NodeTypeMethod, "operator==", 9},
+		"box.cpp::geo.shapes.Box.get":        {NodeTypeMethod, "get", 12},
+		"box.cpp::geo.shapes.Circle.area":    {NodeTypeMethod, "area", 18},
+		"box.cpp::geo.Canvas.draw":           {NodeTypeMethod, "draw", 19},
+		"box.cpp::util.log":                  {NodeTypeFunction, "log", 20},
+	} {
+		n, ok := nodes[id]
+		if !ok {
+			t.Errorf("expected node %s, got %v", id, result.Nodes)
+			continue
+		}
+		if n.NodeType != want.nodeType || n.Name != want.name || n.StartLine != want.start {
+			t.Errorf("node %s: got %s %q at line %d, want %+v", id, n.NodeType, n.Name, n.StartLine, want)
+		}
+	}
+	if box := nodes["box.cpp::geo.shapes.Box"]; box.DocComment != "A box of anything" || !strings.HasPrefix(box.Content, "template <typename T>") {
+		t.Errorf("expected the template header and its comment on Box, got %q %q", box.DocComment, box.Content)
+	}
+	if _, ok := nodes["box.cpp::geo.shapes.Circle"]; ok {
+		t.Error("expected no node for a class declaration")
+	}
+}
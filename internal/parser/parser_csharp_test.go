@@ -0,0 +1,49 @@
+package parser
+
+import "testing"
+
+func TestExtractCSharp(t *testing.T) {
+	code := `using System;
+
+namespace App.Services
+{
+    /// <summary>Users</summary>
+    public class UserService : IUserService
+    {
+        public UserService(IRepo repo) { }
+        public User Find(int id) { return Lookup(id); }
+        private User Lookup(int id) { Console.WriteLine(id); return Parse<int>(id); }
+        public string Name { get; set; }
+    }
+    public interface IUserService { User Find(int id); }
+    public struct Point { }
+    public enum Color { Red }
+    public record Person(string Name);
+    public delegate void Handler(int x);
+}
+`
+	nodes, calls := parseSample(t, "UserService.cs", LangCSharp, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"UserService":             NodeTypeClass,
+		"UserService.UserService": NodeTypeMethod,
+		"UserService.Find":        NodeTypeMethod,
+		"UserService.Lookup":      NodeTypeMethod,
+		"IUserService":            NodeTypeInterface,
+		"IUserService.Find":       NodeTypeMethod,
+		"Point":                   NodeTypeStruct,
+		"Color":                   NodeTypeEnum,
+		"Person":                  NodeTypeClass,
+		"Handler":                 NodeTypeType,
+	}, []string{
+		"UserService.Find -> UserService.Lookup",
+		"UserService.Lookup -> external::Console.WriteLine",
+		"UserService.Lookup -> Parse",
+	})
+
+	if doc := nodes["UserService"].DocComment; doc != "<summary>Users</summary>" {
+		t.Errorf("expected class doc comment, got %q", doc)
+	}
+	if _, ok := nodes["UserService.Name"]; ok {
+		t.Error("did not expect a node for a property")
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Elixir definitions are macro calls: def total(cart) is a call to def whose
+// first argument is the call head total(cart)
+
+var elixirDefinitionTypes = map[string]NodeType{
+	"defmodule":   NodeTypeModule,
+	"defprotocol": NodeTypeInterface,
+	"defimpl":     NodeTypeModule,
+	"def":         NodeTypeFunction,
+	"defp":        NodeTypeFunction,
+	"defdelegate": NodeTypeFunction,
+	"defguard":    NodeTypeFunction,
+	"defguardp":   NodeTypeFunction,
+	"defmacro":    NodeTypeMacro,
+	"defmacrop":   NodeTypeMacro,
+}
+
+// elixirDefinition returns the defining macro of a call node and the node
+// type it defines, or "" when the call is not a definition
+func elixirDefinition(n *sitter.Node, content []byte) (string, NodeType) {
+	if n.Type() != "call" {
+		return "", ""
+	}
+	target := n.ChildByFieldName("target")
+	if target == nil || target.Type() != "identifier" {
+		return "", ""
+	}
+	keyword := nodeText(target, content)
+	nodeType, ok := elixirDefinitionTypes[keyword]
+	if !ok {
+		return "", ""
+	}
+	return keyword, nodeType
+}
+
+// elixirDefinitionName returns the module or function a definition names
+func elixirDefinitionName(n *sitter.Node, content []byte) string {
+	head := elixirDefinitionHead(n)
+	if head == nil {
+		return ""
+	}
+	switch head.Type() {
+	case "alias", "identifier":
+		return nodeText(head, content)
+	case "call":
+		return nodeText(head.ChildByFieldName("target"), content)
+	}
+	return ""
+}
+
+// elixirDefinitionHead returns the first argument of a definition, looking
+// through guards: the head of def f(x) when x > 0 is f(x)
+func elixirDefinitionHead(n *sitter.Node) *sitter.Node {
+	args := childOfType(n, "arguments")
+	if args == nil || args.NamedChildCount() == 0 {
+		return nil
+	}
+	head := args.NamedChild(0)
+	if head.Type() == "binary_operator" {
+		if left := head.ChildByFieldName("left"); left != nil {
+			head = left
+		}
+	}
+	return head
+}
+
+// elixirArity returns the number of parameters of a function definition
+func elixirArity(n *sitter.Node) int {
+	head := elixirDefinitionHead(n)
+	if head == nil {
+		return -1
+	}
+	if head.Type() == "identifier" {
+		return 0
+	}
+	if args := childOfType(head, "arguments"); args != nil {
+		return int(args.NamedChildCount())
+	}
+	return 0
+}
+
+// elixirDoc returns the @doc or @moduledoc of a definition: the attribute
+// right before a function, or the first one in a module body
+func elixirDoc(n *sitter.Node, content []byte) string {
+	attr, attrName := n.PrevNamedSibling(), "doc"
+	if keyword, _ := elixirDefinition(n, content); keyword == "defmodule" || keyword == "defprotocol" {
+		attr, attrName = nil, "moduledoc"
+		if body := childOfType(n, "do_block"); body != nil && body.NamedChildCount() > 0 {
+			attr = body.NamedChild(0)
+		}
+	}
+	if attr == nil || attr.Type() != "unary_operator" {
+		return ""
+	}
+	call := attr.ChildByFieldName("operand")
+	if call == nil || call.Type() != "call" {
+		return ""
+	}
+	if nodeText(call.ChildByFieldName("target"), content) != attrName {
+		return ""
+	}
+	args := childOfType(call, "arguments")
+	if args == nil || args.NamedChildCount() == 0 {
+		return ""
+	}
+	return cleanDocstring(nodeText(args.NamedChild(0), content))
+}
+
+// elixirRefine documents a definition with its @doc or @moduledoc only
+func elixirRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	node.DocComment = elixirDoc(def, content)
+}
@@ -0,0 +1,49 @@
+package parser
+
+import "testing"
+
+func TestExtractElixir(t *testing.T) {
+	code := `defmodule Shop.Cart do
+  @moduledoc "Shopping carts"
+  alias Shop.Item
+
+  @doc "Total"
+  def total(cart), do: sum(cart.items)
+
+  defp sum(items) when is_list(items) do
+    Enum.map(items, &Item.price/1)
+  end
+
+  defmacro debug(x), do: x
+end
+
+defprotocol Shop.Priced do
+  def price(x)
+end
+`
+	nodes, calls := parseSample(t, "cart.ex", LangElixir, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"Shop.Cart":         NodeTypeModule,
+		"Shop.Cart.total":   NodeTypeFunction,
+		"Shop.Cart.sum":     NodeTypeFunction,
+		"Shop.Cart.debug":   NodeTypeMacro,
+		"Shop.Priced":       NodeTypeInterface,
+		"Shop.Priced.price": NodeTypeFunction,
+	}, []string{
+		"Shop.Cart.total -> Shop.Cart.sum",
+		"Shop.Cart.sum -> external::Enum.map",
+	})
+
+	if doc := nodes["Shop.Cart"].DocComment; doc != "Shopping carts" {
+		t.Errorf("expected module doc 'Shopping carts', got %q", doc)
+	}
+	if doc := nodes["Shop.Cart.total"].DocComment; doc != "Total" {
+		t.Errorf("expected function doc 'Total', got %q", doc)
+	}
+	for call := range calls {
+		switch call {
+		case "Shop.Cart.total -> external::cart.items", "Shop.Cart.sum -> sum":
+			t.Errorf("unexpected call edge %s", call)
+		}
+	}
+}
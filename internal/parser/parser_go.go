@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// goRefine names a method after its receiver, "(s *Server).Start", with the
+// receiver type as its scope, and spans a type with its whole declaration,
+// which is where its doc comment is
+func goRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	switch def.Type() {
+	case "method_declaration":
+		receiver := def.ChildByFieldName("receiver")
+		if receiver == nil {
+			return
+		}
+		node.Name = nodeText(receiver, content) + "." + node.Name
+		if recvType := goReceiverType(receiver, content); recvType != "" {
+			node.ID = node.FilePath + "::" + recvType + "." + strings.TrimPrefix(node.ID, node.FilePath+"::")
+		}
+
+	case "type_spec":
+		decl := def.Parent()
+		if decl == nil || decl.Type() != "type_declaration" {
+			return
+		}
+		node.StartLine = int(decl.StartPoint().Row) + 1
+		node.EndLine = int(decl.EndPoint().Row) + 1
+		node.Content = string(content[decl.StartByte():decl.EndByte()])
+		node.DocComment = p.extractDocComment(decl, content)
+		node.Annotations = p.extractAnnotations(decl, content)
+	}
+}
+
+// goPackage returns the import path of the file's directory within its Go
+// module
+func goPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	dir := filepath.Dir(filePath)
+	modRoot, modPath := findGoModule(dir)
+	if modPath == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(modRoot, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." {
+		return modPath
+	}
+	return modPath + "/" + filepath.ToSlash(rel)
+}
+
+// resolveGoImport resolves imports of the file's own module to its packages
+func resolveGoImport(p *Parser, filePath, pkg string, ref importRef) string {
+	if modRoot, modPath := findGoModule(filepath.Dir(filePath)); modPath != "" {
+		if rel, ok := strings.CutPrefix(ref.spec, modPath); ok && (rel == "" || strings.HasPrefix(rel, "/")) {
+			if isDir(filepath.Join(modRoot, filepath.FromSlash(rel))) {
+				return packageModulePrefix + ref.spec
+			}
+		}
+	}
+	return externalModulePrefix + ref.spec
+}
+
+func goSymbolTable(p *Parser, root *sitter.Node, filePath string, content []byte) SymbolTable {
+	symbols := NewGoSymbolTable()
+	symbols.BuildFromAST(root, filePath, content)
+	return symbols
+}
@@ -0,0 +1,449 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// There is no tree-sitter grammar for GraphQL, so schemas are read by a
+// scanner that knows the shape of type system definitions. Types, inputs,
+// unions and scalars become type nodes, interfaces and enums their own
+// kinds, and the fields of the Query, Mutation and Subscription types
+// operation nodes. Named operations in executable documents become function
+// nodes that use the root fields they select.
+
+type gqlToken struct {
+	text       string
+	start, end int
+	line       int
+	str        bool // a string or block string
+}
+
+// graphqlRootTypes are the types whose fields are operations
+var graphqlRootTypes = map[string]bool{"Query": true, "Mutation": true, "Subscription": true}
+
+// graphqlBuiltins are the scalars every schema has
+var graphqlBuiltins = map[string]bool{"String": true, "Int": true, "Float": true, "Boolean": true, "ID": true}
+
+// parseGraphQL reads a GraphQL schema or executable document
+func parseGraphQL(ctx context.Context, p *Parser, filePath string, content []byte) (*ParseResult, error) {
+	g := &graphqlReader{
+		p:        p,
+		filePath: filePath,
+		content:  content,
+		tokens:   scanGraphQL(content),
+		result:   &ParseResult{Nodes: []CodeNode{}, Edges: []CodeEdge{}},
+	}
+	g.read()
+	g.result.Nodes = append(g.result.Nodes, fileModule(LangGraphQL, filePath, bytes.Count(content, []byte("\n"))+1))
+	return g.result, nil
+}
+
+// graphqlContract returns the contract of a root field
+func graphqlContract(n *CodeNode) string {
+	if n.NodeType != NodeTypeOperation {
+		return ""
+	}
+	return graphqlContractPrefix + localName(n)
+}
+
+type graphqlReader struct {
+	p        *Parser
+	filePath string
+	content  []byte
+	tokens   []gqlToken
+	pos      int
+	result   *ParseResult
+}
+
+func (g *graphqlReader) peek() string {
+	if g.pos >= len(g.tokens) {
+		return ""
+	}
+	return g.tokens[g.pos].text
+}
+
+func (g *graphqlReader) next() gqlToken {
+	if g.pos >= len(g.tokens) {
+		return gqlToken{}
+	}
+	g.pos++
+	return g.tokens[g.pos-1]
+}
+
+// atName reports whether the current token is a name other than a keyword
+func (g *graphqlReader) atName() bool {
+	if g.pos >= len(g.tokens) {
+		return false
+	}
+	tok := g.tokens[g.pos]
+	return !tok.str && isIdentStart(tok.text[0]) && !isGraphQLKeyword(tok.text)
+}
+
+func (g *graphqlReader) read() {
+	for g.pos < len(g.tokens) {
+		start := g.tokens[g.pos]
+		doc := ""
+		if start.str {
+			doc = graphqlDescription(g.next().text)
+			if g.pos >= len(g.tokens) {
+				return
+			}
+		}
+		if g.peek() == "extend" {
+			g.next()
+		}
+		if g.pos >= len(g.tokens) {
+			return
+		}
+		switch def := g.tokens[g.pos]; def.text {
+		case "type", "input", "interface", "enum", "union", "scalar":
+			g.next()
+			g.readType(start, def.text, doc)
+		case "query", "mutation", "subscription", "{":
+			g.readOperation(start)
+		case "schema", "fragment", "directive":
+			g.next()
+			g.skipDefinition()
+		default:
+			g.next()
+		}
+	}
+}
+
+// readType reads a type system definition after its keyword
+func (g *graphqlReader) readType(start gqlToken, keyword, doc string) {
+	name := g.next()
+	if name.text == "" || name.str || !isIdentStart(name.text[0]) {
+		return
+	}
+	nodeType := NodeTypeType
+	switch keyword {
+	case "interface":
+		nodeType = NodeTypeInterface
+	case "enum":
+		nodeType = NodeTypeEnum
+	}
+	id := g.filePath + "::" + name.text
+	end := name
+
+	var implements, uses []string
+	if g.peek() == "implements" {
+		g.next()
+		for g.peek() == "&" || g.atName() {
+			if tok := g.next(); tok.text != "&" {
+				implements = append(implements, tok.text)
+				end = tok
+			}
+		}
+	}
+	end = g.skipDirectives(end)
+	if keyword == "union" && g.peek() == "=" {
+		g.next()
+		for g.peek() == "|" || g.atName() {
+			if tok := g.next(); tok.text != "|" {
+				uses = append(uses, tok.text)
+				end = tok
+			}
+		}
+	}
+
+	var fields []CodeNode
+	var fieldUses [][]string
+	if g.peek() == "{" {
+		g.next()
+		for g.pos < len(g.tokens) && g.peek() != "}" {
+			fieldStart := g.tokens[g.pos]
+			fieldDoc := ""
+			if fieldStart.str {
+				fieldDoc = graphqlDescription(g.next().text)
+			}
+			fieldName := g.next()
+			if fieldName.text == "" || !isIdentStart(fieldName.text[0]) {
+				continue
+			}
+			fieldEnd, types := g.readFieldRest(fieldName)
+			uses = append(uses, types...)
+			if keyword != "type" || !graphqlRootTypes[name.text] {
+				continue
+			}
+			fields = append(fields, CodeNode{
+				ID:         id + "." + fieldName.text,
+				Name:       fieldName.text,
+				NodeType:   NodeTypeOperation,
+				Language:   LangGraphQL,
+				FilePath:   g.filePath,
+				StartLine:  fieldStart.line,
+				EndLine:    fieldEnd.line,
+				Content:    string(g.content[fieldStart.start:fieldEnd.end]),
+				Signature:  string(g.content[fieldName.start:fieldEnd.end]),
+				DocComment: fieldDoc,
+				arity:      -1,
+			})
+			fieldUses = append(fieldUses, types)
+		}
+		end = g.next()
+	}
+
+	g.result.Nodes = append(g.result.Nodes, CodeNode{
+		ID:         id,
+		Name:       name.text,
+		NodeType:   nodeType,
+		Language:   LangGraphQL,
+		FilePath:   g.filePath,
+		StartLine:  start.line,
+		EndLine:    end.line,
+		Content:    string(g.content[start.start:end.end]),
+		DocComment: doc,
+		arity:      -1,
+	})
+	g.result.Nodes = append(g.result.Nodes, fields...)
+	if !g.p.extractEdges {
+		return
+	}
+	for _, iface := range implements {
+		g.addEdge(id, iface, EdgeTypeImplements)
+	}
+	for _, t := range uses {
+		g.addEdge(id, t, EdgeTypeUses)
+	}
+	for i, f := range fields {
+		for _, t := range fieldUses[i] {
+			g.addEdge(f.ID, t, EdgeTypeUses)
+		}
+	}
+}
+
+// readFieldRest reads a field's arguments, type, default value and
+// directives, returning its last token and the types it refers to
+func (g *graphqlReader) readFieldRest(end gqlToken) (gqlToken, []string) {
+	var types []string
+	if g.peek() == "(" {
+		depth := 0
+		for g.pos < len(g.tokens) {
+			tok := g.next()
+			end = tok
+			switch tok.text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			case ":", "[":
+				if t := g.peek(); t != "" && isIdentStart(t[0]) && !g.tokens[g.pos].str {
+					types = append(types, t)
+				}
+			}
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if g.peek() == ":" {
+		g.next()
+		for g.peek() == "[" || g.peek() == "]" || g.peek() == "!" {
+			end = g.next()
+		}
+		if t := g.peek(); t != "" && isIdentStart(t[0]) {
+			end = g.next()
+			types = append(types, end.text)
+		}
+		for g.peek() == "]" || g.peek() == "!" {
+			end = g.next()
+		}
+	}
+	if g.peek() == "=" {
+		g.next()
+		end = g.skipValue()
+	}
+	return g.skipDirectives(end), types
+}
+
+// readOperation reads a query, mutation or subscription of an executable
+// document
+func (g *graphqlReader) readOperation(start gqlToken) {
+	root, name := "Query", ""
+	if g.peek() != "{" {
+		kind := g.next().text
+		root = strings.ToUpper(kind[:1]) + kind[1:]
+		if t := g.peek(); t != "" && isIdentStart(t[0]) {
+			name = g.next().text
+		}
+	}
+	for g.pos < len(g.tokens) && g.peek() != "{" {
+		if g.peek() == "(" {
+			g.skipValue()
+			continue
+		}
+		g.next()
+	}
+	if g.pos >= len(g.tokens) {
+		return
+	}
+	open := g.tokens[g.pos]
+	end := g.skipValue()
+
+	from := g.filePath
+	if name != "" {
+		from = g.filePath + "::" + name
+		g.result.Nodes = append(g.result.Nodes, CodeNode{
+			ID:        from,
+			Name:      name,
+			NodeType:  NodeTypeFunction,
+			Language:  LangGraphQL,
+			FilePath:  g.filePath,
+			StartLine: start.line,
+			EndLine:   end.line,
+			Content:   string(g.content[start.start:end.end]),
+			arity:     -1,
+		})
+	}
+	if !g.p.extractEdges {
+		return
+	}
+	for _, field := range selectedFields(string(g.content[open.start:end.end])) {
+		g.result.Edges = append(g.result.Edges, CodeEdge{
+			FromID:   from,
+			ToID:     graphqlContractPrefix + root + "." + field,
+			EdgeType: EdgeTypeUses,
+		})
+	}
+}
+
+// skipDefinition skips a schema, fragment or directive definition
+func (g *graphqlReader) skipDefinition() {
+	for g.pos < len(g.tokens) {
+		switch g.peek() {
+		case "{", "(":
+			g.skipValue()
+			if g.peek() != "on" && g.peek() != "{" {
+				return
+			}
+		default:
+			if tok := g.tokens[g.pos]; tok.str || (isGraphQLKeyword(tok.text) && tok.text != "on" && tok.text != "repeatable") {
+				return
+			}
+			g.next()
+		}
+	}
+}
+
+// skipDirectives skips the directives at the current token, returning the
+// last token read
+func (g *graphqlReader) skipDirectives(end gqlToken) gqlToken {
+	for g.peek() == "@" {
+		g.next()
+		end = g.next()
+		if g.peek() == "(" {
+			end = g.skipValue()
+		}
+	}
+	return end
+}
+
+// skipValue skips a value or a balanced group of brackets, returning its
+// last token
+func (g *graphqlReader) skipValue() gqlToken {
+	depth := 0
+	var tok gqlToken
+	for g.pos < len(g.tokens) {
+		tok = g.next()
+		switch tok.text {
+		case "{", "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		}
+		if depth <= 0 {
+			return tok
+		}
+	}
+	return tok
+}
+
+func (g *graphqlReader) addEdge(from, typeName string, edgeType EdgeType) {
+	if graphqlBuiltins[typeName] {
+		return
+	}
+	g.result.Edges = append(g.result.Edges, CodeEdge{
+		FromID:   from,
+		ToID:     g.filePath + "::" + typeName,
+		EdgeType: edgeType,
+	})
+}
+
+// isGraphQLKeyword reports whether a name starts a definition
+func isGraphQLKeyword(name string) bool {
+	switch name {
+	case "type", "input", "interface", "enum", "union", "scalar", "extend", "schema",
+		"directive", "query", "mutation", "subscription", "fragment", "on", "repeatable":
+		return true
+	}
+	return false
+}
+
+// graphqlDescription returns the text of a description string
+func graphqlDescription(s string) string {
+	if rest, ok := strings.CutPrefix(s, `"""`); ok {
+		s = strings.TrimSuffix(rest, `"""`)
+	} else {
+		s = strings.Trim(s, `"`)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// scanGraphQL splits a GraphQL document into tokens, dropping comments,
+// commas and whitespace
+func scanGraphQL(content []byte) []gqlToken {
+	var tokens []gqlToken
+	line := 1
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ',' || c == 0xEF || c == 0xBB || c == 0xBF:
+			i++
+		case c == '#':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			start, startLine := i, line
+			if bytes.HasPrefix(content[i:], []byte(`"""`)) {
+				end := bytes.Index(content[i+3:], []byte(`"""`))
+				if end < 0 {
+					end = len(content) - i - 3
+				} else {
+					end += 3
+				}
+				i += 3 + end
+			} else {
+				i++
+				for i < len(content) && content[i] != '"' && content[i] != '\n' {
+					if content[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i++
+			}
+			i = min(i, len(content))
+			line += bytes.Count(content[start:i], []byte("\n"))
+			tokens = append(tokens, gqlToken{text: string(content[start:i]), start: start, end: i, line: startLine, str: true})
+		case c == '.' && bytes.HasPrefix(content[i:], []byte("...")):
+			tokens = append(tokens, gqlToken{text: "...", start: i, end: i + 3, line: line})
+			i += 3
+		case isIdentStart(c) || c == '-' || (c >= '0' && c <= '9'):
+			start, number := i, !isIdentStart(c)
+			for i++; i < len(content) && (isIdentStart(content[i]) || (content[i] >= '0' && content[i] <= '9') || number && content[i] == '.'); i++ {
+			}
+			tokens = append(tokens, gqlToken{text: string(content[start:i]), start: start, end: i, line: line})
+		default:
+			tokens = append(tokens, gqlToken{text: string(c), start: i, end: i + 1, line: line})
+			i++
+		}
+	}
+	return tokens
+}
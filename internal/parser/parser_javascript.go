@@ -0,0 +1,52 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// jsRefine spans a function or class bound to a variable, property or field
+// with its whole statement, and documents TypeScript declarations with the
+// comment before an enclosing export
+func jsRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	decl := jsDeclaration(def)
+	switch def.Type() {
+	case "variable_declarator", "pair", "field_definition", "public_field_definition":
+		node.StartLine = int(decl.StartPoint().Row) + 1
+		node.EndLine = int(decl.EndPoint().Row) + 1
+		node.Content = string(content[decl.StartByte():decl.EndByte()])
+	case "interface_declaration", "type_alias_declaration", "enum_declaration", "internal_module", "module":
+	default:
+		return
+	}
+	node.DocComment = p.extractDocComment(decl, content)
+	node.Annotations = p.extractAnnotations(decl, content)
+}
+
+func isJSFunction(n *sitter.Node) bool {
+	switch n.Type() {
+	case "arrow_function", "function", "function_expression", "generator_function":
+		return true
+	}
+	return false
+}
+
+// jsDeclaration returns the statement holding a declaration, including an
+// enclosing export, which is where its doc comment is attached. A declarator
+// sharing its statement with others stands on its own.
+func jsDeclaration(n *sitter.Node) *sitter.Node {
+	decl := n
+	if parent := n.Parent(); n.Type() == "variable_declarator" && parent != nil {
+		if parent.NamedChildCount() != 1 {
+			return n
+		}
+		decl = parent
+	}
+	if parent := decl.Parent(); parent != nil && parent.Type() == "export_statement" {
+		decl = parent
+	}
+	if parent := decl.Parent(); parent != nil && parent.Type() == "expression_statement" {
+		// namespace N {} is wrapped in an expression statement
+		decl = parent
+	}
+	return decl
+}
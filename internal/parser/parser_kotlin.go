@@ -0,0 +1,21 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// kotlinArity counts the parameters of a function, which the grammar does
+// not give a field
+func kotlinArity(def *sitter.Node) int {
+	return countParameters(childOfType(def, "function_value_parameters"))
+}
+
+// childOfType returns the first child of n with the given type
+func childOfType(n *sitter.Node, nodeType string) *sitter.Node {
+	for i := 0; i < int(n.ChildCount()); i++ {
+		if child := n.Child(i); child.Type() == nodeType {
+			return child
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package parser
+
+import "testing"
+
+func TestExtractKotlin(t *testing.T) {
+	code := `package com.acme.app
+
+/** A user service */
+class UserService(private val repo: Repo) : Service {
+    fun find(id: Int): User = lookup(id)
+    private fun lookup(id: Int): User { return repo.get(id) }
+    companion object { fun create() = UserService(Repo()) }
+}
+interface Service
+object Registry { fun register() {} }
+data class User(val id: Int)
+enum class Color { RED }
+typealias Users = List<User>
+fun main() { println(Registry.register()) }
+`
+	nodes, calls := parseSample(t, "UserService.kt", LangKotlin, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"UserService":        NodeTypeClass,
+		"UserService.find":   NodeTypeMethod,
+		"UserService.lookup": NodeTypeMethod,
+		"UserService.create": NodeTypeMethod,
+		"Service":            NodeTypeInterface,
+		"Registry":           NodeTypeClass,
+		"Registry.register":  NodeTypeMethod,
+		"User":               NodeTypeClass,
+		"Color":              NodeTypeEnum,
+		"Users":              NodeTypeType,
+		"main":               NodeTypeFunction,
+	}, []string{
+		"UserService.find -> UserService.lookup",
+		"UserService.lookup -> external::repo.get",
+		"UserService.create -> UserService",
+		"main -> external::Registry.register",
+	})
+
+	if doc := nodes["UserService"].DocComment; doc != "A user service" {
+		t.Errorf("expected class doc comment 'A user service', got %q", doc)
+	}
+}
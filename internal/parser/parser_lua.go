@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// luaRefine makes a function named Account:deposit a method
+func luaRefine(p *Parser, node *CodeNode, def *sitter.Node, content []byte) {
+	if name := def.ChildByFieldName("name"); name != nil && childOfType(name, "table_colon") != nil {
+		node.NodeType = NodeTypeMethod
+	}
+}
+
+// luaArity counts the parameters of a function statement, or of the
+// function a variable is bound to
+func luaArity(def *sitter.Node) int {
+	if value := def.ChildByFieldName("value"); value != nil {
+		def = value
+	}
+	params := childOfType(def, "parameter_list")
+	if params == nil && childOfType(def, "function_body_paren") != nil {
+		return 0
+	}
+	return countParameters(params)
+}
+
+// luaCallee reads what a call calls from the run of tokens the grammar
+// writes its path as
+func luaCallee(n *sitter.Node, content []byte) string {
+	if n.Type() != "function_call" {
+		return ""
+	}
+	var path []string
+	method := ""
+	colon := false
+callee:
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		switch child.Type() {
+		case "identifier":
+			if colon {
+				method = nodeText(child, content)
+			} else {
+				path = append(path, nodeText(child, content))
+			}
+		case "self_call_colon":
+			colon = true
+		case "function_call_paren", "function_arguments", "string_argument", "table_argument":
+			break callee
+		default:
+			// Calls on computed values such as M["x"]() or f()()
+			return ""
+		}
+	}
+
+	if colon {
+		return qualifiedCallee(strings.Join(path, "."), method)
+	}
+	if len(path) == 0 {
+		return ""
+	}
+	return qualifiedCallee(strings.Join(path[:len(path)-1], "."), path[len(path)-1])
+}
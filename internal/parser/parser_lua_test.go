@@ -0,0 +1,39 @@
+package parser
+
+import "testing"
+
+func TestExtractLua(t *testing.T) {
+	code := `local M = {}
+
+-- Adds numbers
+local function add(a, b) return a + b end
+function M.greet(name) print(name) end
+function Account:deposit(v) self.balance = add(self.balance, v) end
+local helper = function() return M.greet("x") end
+function Account:report() self:deposit(0) end
+return M
+`
+	nodes, calls := parseSample(t, "m.lua", LangLua, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"add":             NodeTypeFunction,
+		"M.greet":         NodeTypeFunction,
+		"Account.deposit": NodeTypeMethod,
+		"Account.report":  NodeTypeMethod,
+		"helper":          NodeTypeFunction,
+	}, []string{
+		"Account.deposit -> add",
+		"helper -> external::M.greet",
+		"Account.report -> Account.deposit",
+	})
+
+	add := nodes["add"]
+	if add.DocComment != "Adds numbers" {
+		t.Errorf("expected doc comment 'Adds numbers', got %q", add.DocComment)
+	}
+	if add.StartLine != 4 || add.Content != "local function add(a, b) return a + b end" {
+		t.Errorf("expected add on line 4 without leading whitespace, got line %d %q", add.StartLine, add.Content)
+	}
+	if n := nodes["M.greet"]; n.Name != "greet" {
+		t.Errorf("expected name greet, got %q", n.Name)
+	}
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// parseMarkdown reads the fenced code blocks of a Markdown or MDX document
+// whose info string names a language (see embedded.go)
+func parseMarkdown(ctx context.Context, p *Parser, filePath string, content []byte) (*ParseResult, error) {
+	lines := strings.SplitAfter(string(content), "\n")
+	var blocks []codeBlock
+	count := 0
+	for i := 0; i < len(lines); i++ {
+		indent, fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+		count++
+		var code strings.Builder
+		start := i
+		for i++; i < len(lines) && !closesFence(lines[i], fence); i++ {
+			line := lines[i]
+			for j := 0; j < indent && strings.HasPrefix(line, " "); j++ {
+				line = line[1:]
+			}
+			code.WriteString(line)
+		}
+
+		tag, _, _ := strings.Cut(info, " ")
+		if lang := blockLanguage(tag); lang != "" {
+			blocks = append(blocks, codeBlock{
+				path:   fmt.Sprintf("%s#block-%d", filePath, count),
+				lang:   lang,
+				code:   []byte(code.String()),
+				offset: start + 1,
+			})
+		}
+	}
+	return p.parseBlocks(ctx, LangMarkdown, filePath, len(lines), blocks)
+}
+
+// openingFence returns the indentation, fence and info string of a line
+// opening a fenced code block: three or more backticks or tildes
+func openingFence(line string) (indent int, fence, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	indent = len(line) - len(trimmed)
+	if indent > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return 0, "", "", false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == trimmed[0] {
+		n++
+	}
+	if n < 3 {
+		return 0, "", "", false
+	}
+	fence, info = trimmed[:n], strings.TrimSpace(trimmed[n:])
+	if fence[0] == '`' && strings.Contains(info, "`") {
+		return 0, "", "", false
+	}
+	return indent, fence, info, true
+}
+
+// closesFence reports whether a line closes a block opened by fence
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == "" && len(line)-len(strings.TrimLeft(line, " ")) <= 3
+}
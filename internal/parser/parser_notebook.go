@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// notebook is the part of a Jupyter notebook that holds code
+type notebook struct {
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"` // a string or a list of lines
+		Metadata struct {
+			VSCode struct {
+				LanguageID string `json:"languageId"`
+			} `json:"vscode"`
+			Polyglot struct {
+				KernelName string `json:"kernelName"`
+			} `json:"polyglot_notebook"`
+			DotnetInteractive struct {
+				Language string `json:"language"`
+			} `json:"dotnet_interactive"`
+		} `json:"metadata"`
+	} `json:"cells"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+}
+
+// cellMagics are the languages of the cells IPython cell magics run. Cells
+// of other magics are not read.
+var cellMagics = map[string]Language{
+	"sql":        LangSQL,
+	"bash":       LangBash,
+	"sh":         LangBash,
+	"javascript": LangJavaScript,
+	"js":         LangJavaScript,
+	"capture":    LangPython,
+	"time":       LangPython,
+	"timeit":     LangPython,
+}
+
+// parseNotebook reads the code cells of a Jupyter notebook (see
+// embedded.go). A cell's language is the one its polyglot metadata or cell
+// magic names, and the notebook's kernel language otherwise.
+func parseNotebook(ctx context.Context, p *Parser, filePath string, content []byte) (*ParseResult, error) {
+	lines := bytes.Count(content, []byte("\n")) + 1
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		result, perr := p.parseBlocks(ctx, LangNotebook, filePath, lines, nil)
+		if perr != nil {
+			return nil, perr
+		}
+		result.SyntaxErrors = []SyntaxError{wholeFileError(content, fmt.Errorf("invalid notebook JSON: %w", err))}
+		result.ErrorRatio = 1
+		return result, nil
+	}
+
+	kernel := nb.Metadata.LanguageInfo.Name
+	if kernel == "" {
+		kernel = nb.Metadata.KernelSpec.Language
+	}
+	if kernel == "" {
+		kernel = string(LangPython)
+	}
+
+	var blocks []codeBlock
+	for i, cell := range nb.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		source := cellSource(cell.Source)
+		tag := kernel
+		for _, t := range []string{cell.Metadata.VSCode.LanguageID, cell.Metadata.Polyglot.KernelName, cell.Metadata.DotnetInteractive.Language} {
+			if t != "" {
+				tag = t
+				break
+			}
+		}
+		lang := blockLanguage(tag)
+		if magic, ok := strings.CutPrefix(source, "%%"); ok {
+			line, _, _ := strings.Cut(magic, "\n")
+			name := ""
+			if fields := strings.Fields(line); len(fields) > 0 {
+				name = fields[0]
+			}
+			lang = cellMagics[name]
+			// The magic's line stays, blank, so lines keep their numbers
+			_, rest, _ := strings.Cut(source, "\n")
+			source = "\n" + rest
+		}
+		if lang == "" {
+			continue
+		}
+		if lang == LangPython {
+			source = commentShellLines(source)
+		}
+		blocks = append(blocks, codeBlock{
+			path: fmt.Sprintf("%s#cell-%d", filePath, i+1),
+			lang: lang,
+			code: []byte(source),
+		})
+	}
+	return p.parseBlocks(ctx, LangNotebook, filePath, lines, blocks)
+}
+
+// cellSource returns the source of a cell, stored as a string or as a list
+// of lines
+func cellSource(raw json.RawMessage) string {
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	var source string
+	if err := json.Unmarshal(raw, &source); err == nil {
+		return source
+	}
+	return ""
+}
+
+// commentShellLines turns the line magics (%) and shell escapes (!) of a
+// Python cell into comments, which keeps the rest parseable
+func commentShellLines(source string) string {
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+			lines[i] = line[:len(line)-len(trimmed)] + "#" + trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,196 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPI and Swagger specs, in YAML or JSON, are read as documents. Each
+// path becomes a route node and each operation on it an operation node
+// named by its operationId, or by its method and path when it has none.
+// Schemas become type nodes, and operations and schemas use the schemas
+// they refer to with $ref.
+
+// parseOpenAPI reads an OpenAPI or Swagger spec. Files that are not one,
+// or do not parse, get only their module node; those that do not parse
+// are one syntax error.
+func parseOpenAPI(ctx context.Context, p *Parser, filePath string, content []byte) (*ParseResult, error) {
+	result := &ParseResult{Nodes: []CodeNode{}, Edges: []CodeEdge{}}
+	lines := strings.Split(string(content), "\n")
+	result.Nodes = append(result.Nodes, fileModule(LangOpenAPI, filePath, bytes.Count(content, []byte("\n"))+1))
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		result.SyntaxErrors = []SyntaxError{wholeFileError(content, err)}
+		result.ErrorRatio = 1
+		return result, nil
+	}
+	if len(doc.Content) == 0 {
+		return result, nil
+	}
+	root := doc.Content[0]
+	if mappingValue(root, "openapi") == nil && mappingValue(root, "swagger") == nil {
+		return result, nil
+	}
+
+	o := &openapiReader{p: p, filePath: filePath, lines: lines, result: result}
+	schemas := mappingValue(mappingValue(root, "components"), "schemas")
+	if schemas == nil {
+		schemas = mappingValue(root, "definitions")
+	}
+	forEachPair(schemas, func(key, value *yaml.Node) {
+		id := filePath + "::" + key.Value
+		o.addNode(id, key.Value, NodeTypeType, key, value, "", schemaDoc(value))
+		o.addRefs(id, value)
+	})
+
+	forEachPair(mappingValue(root, "paths"), func(key, value *yaml.Node) {
+		path := key.Value
+		routeID := filePath + "::" + path
+		o.addNode(routeID, path, NodeTypeRoute, key, value, "", "")
+		forEachPair(value, func(opKey, op *yaml.Node) {
+			method := strings.ToUpper(opKey.Value)
+			if !httpVerbs[method] && method != "TRACE" {
+				return
+			}
+			signature := method + " " + path
+			name := signature
+			if opID := mappingValue(op, "operationId"); opID != nil && opID.Value != "" {
+				name = opID.Value
+			}
+			doc := ""
+			if summary := mappingValue(op, "summary"); summary != nil {
+				doc = summary.Value
+			} else if description := mappingValue(op, "description"); description != nil {
+				doc = description.Value
+			}
+			id := routeID + "." + strings.ToLower(method)
+			o.addNode(id, name, NodeTypeOperation, opKey, op, signature, strings.Join(strings.Fields(doc), " "))
+			o.addRefs(id, op)
+		})
+	})
+	return result, nil
+}
+
+// openapiContract returns the contract of an operation
+func openapiContract(n *CodeNode) string {
+	if n.NodeType != NodeTypeOperation {
+		return ""
+	}
+	method, path, ok := strings.Cut(n.Signature, " ")
+	if !ok {
+		return ""
+	}
+	return httpContract(method, path)
+}
+
+type openapiReader struct {
+	p        *Parser
+	filePath string
+	lines    []string
+	result   *ParseResult
+}
+
+// addNode adds the node of a mapping entry, spanning its key and value
+func (o *openapiReader) addNode(id, name string, nodeType NodeType, key, value *yaml.Node, signature, doc string) {
+	start, end := key.Line, lastLine(value)
+	if end < start {
+		end = start
+	}
+	if end > len(o.lines) {
+		end = len(o.lines)
+	}
+	o.result.Nodes = append(o.result.Nodes, CodeNode{
+		ID:         id,
+		Name:       name,
+		NodeType:   nodeType,
+		Language:   LangOpenAPI,
+		FilePath:   o.filePath,
+		StartLine:  start,
+		EndLine:    end,
+		StartCol:   key.Column - 1,
+		Content:    strings.Join(o.lines[start-1:end], "\n"),
+		Signature:  signature,
+		DocComment: doc,
+		arity:      -1,
+	})
+}
+
+// addRefs adds uses edges from a node to the local schemas its $refs name
+func (o *openapiReader) addRefs(from string, n *yaml.Node) {
+	if !o.p.extractEdges {
+		return
+	}
+	seen := make(map[string]bool)
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			if ref := mappingValue(n, "$ref"); ref != nil {
+				name := ""
+				for _, prefix := range []string{"#/components/schemas/", "#/definitions/"} {
+					if rest, ok := strings.CutPrefix(ref.Value, prefix); ok {
+						name = rest
+					}
+				}
+				if name != "" && !seen[name] {
+					seen[name] = true
+					o.result.Edges = append(o.result.Edges, CodeEdge{
+						FromID:   from,
+						ToID:     o.filePath + "::" + name,
+						EdgeType: EdgeTypeUses,
+					})
+				}
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(n)
+}
+
+// schemaDoc returns the description of a schema
+func schemaDoc(schema *yaml.Node) string {
+	if d := mappingValue(schema, "description"); d != nil {
+		return strings.Join(strings.Fields(d.Value), " ")
+	}
+	return ""
+}
+
+// mappingValue returns the value of a key of a mapping, or nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// forEachPair calls fn with the keys and values of a mapping
+func forEachPair(n *yaml.Node, fn func(key, value *yaml.Node)) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		fn(n.Content[i], n.Content[i+1])
+	}
+}
+
+// lastLine returns the last line a YAML node spans
+func lastLine(n *yaml.Node) int {
+	last := n.Line + strings.Count(strings.TrimRight(n.Value, "\n"), "\n")
+	if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		last++
+	}
+	for _, c := range n.Content {
+		last = max(last, lastLine(c))
+	}
+	return last
+}
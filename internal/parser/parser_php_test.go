@@ -0,0 +1,48 @@
+package parser
+
+import "testing"
+
+func TestExtractPHP(t *testing.T) {
+	code := `<?php
+namespace App\Models;
+
+use App\Support\Str;
+
+/** A user */
+class User extends Model {
+    public function name(): string { return Str::upper($this->name); }
+    public static function find($id) { return self::query($id); }
+    public function query($id) { return $this->db?->select($id); }
+}
+interface Named { public function name(): string; }
+trait Greets { function greet() { echo helper(); } }
+function helper() { return $fn("x"); }
+enum Suit { case Hearts; }
+`
+	nodes, calls := parseSample(t, "User.php", LangPHP, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"User":         NodeTypeClass,
+		"User.name":    NodeTypeMethod,
+		"User.find":    NodeTypeMethod,
+		"Named":        NodeTypeInterface,
+		"Named.name":   NodeTypeMethod,
+		"Greets":       NodeTypeInterface,
+		"Greets.greet": NodeTypeMethod,
+		"helper":       NodeTypeFunction,
+		"Suit":         NodeTypeEnum,
+	}, []string{
+		"User.name -> external::Str.upper",
+		"User.find -> User.query",
+		"User.query -> external::$this->db.select",
+		"Greets.greet -> helper",
+	})
+
+	if doc := nodes["User"].DocComment; doc != "A user" {
+		t.Errorf("expected class doc comment 'A user', got %q", doc)
+	}
+	for call := range calls {
+		if call == "helper -> $fn" || call == "helper -> external::$fn" {
+			t.Errorf("did not expect a call edge for a variable function: %s", call)
+		}
+	}
+}
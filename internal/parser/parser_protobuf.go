@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// protobufPackage returns the package the file declares
+func protobufPackage(p *Parser, root *sitter.Node, filePath string, content []byte) string {
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		if child.Type() != "package" {
+			continue
+		}
+		for j := 0; j < int(child.NamedChildCount()); j++ {
+			if ident := child.NamedChild(j); ident.Type() == "full_ident" {
+				return nodeText(ident, content)
+			}
+		}
+	}
+	return ""
+}
+
+// resolveProtoImport resolves an import from the include root it is written
+// relative to: the file's directory or one above it
+func resolveProtoImport(p *Parser, filePath, pkg string, ref importRef) string {
+	rel := filepath.FromSlash(ref.spec)
+	if base := findUp(filepath.Dir(filePath), rel); base != "" && isFile(filepath.Join(base, rel)) {
+		return filepath.Join(base, rel)
+	}
+	return externalModulePrefix + strings.TrimSuffix(ref.spec, ".proto")
+}
+
+// protobufContract returns the contract of an rpc: its service and name,
+// which generated gRPC code keeps whatever the package
+func protobufContract(n *CodeNode) string {
+	if n.NodeType != NodeTypeOperation {
+		return ""
+	}
+	return rpcContractPrefix + localName(n)
+}
@@ -0,0 +1,14 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// rubyArity counts the parameters of a method; def total has no parameter
+// list
+func rubyArity(def *sitter.Node) int {
+	if params := def.ChildByFieldName("parameters"); params != nil {
+		return countParameters(params)
+	}
+	return 0
+}
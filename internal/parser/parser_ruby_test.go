@@ -0,0 +1,53 @@
+package parser
+
+import "testing"
+
+func TestExtractRuby(t *testing.T) {
+	code := `require 'json'
+
+module Shop
+  # A cart
+  class Cart < Base
+    def initialize(items)
+      @items = items
+    end
+
+    def total
+      @items.sum { |i| price(i) }
+    end
+
+    def self.build
+      new([])
+    end
+  end
+end
+
+def price(item)
+  item.amount
+end
+
+def helper(x)
+  Shop::Cart.build
+end
+`
+	nodes, calls := parseSample(t, "cart.rb", LangRuby, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"Shop":                 NodeTypeModule,
+		"Shop.Cart":            NodeTypeClass,
+		"Shop.Cart.initialize": NodeTypeMethod,
+		"Shop.Cart.total":      NodeTypeMethod,
+		"Shop.Cart.build":      NodeTypeMethod,
+		"price":                NodeTypeFunction,
+		"helper":               NodeTypeFunction,
+	}, []string{
+		"Shop.Cart.total -> price",
+		"helper -> external::Shop.Cart.build",
+	})
+
+	if doc := nodes["Shop.Cart"].DocComment; doc != "A cart" {
+		t.Errorf("expected class doc comment 'A cart', got %q", doc)
+	}
+	if n := nodes["Shop.Cart.total"]; n.StartLine != 10 || n.EndLine != 12 {
+		t.Errorf("expected total on lines 10-12, got %d-%d", n.StartLine, n.EndLine)
+	}
+}
@@ -0,0 +1,20 @@
+package parser
+
+import (
+	"fmt"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// rustScopeName names an impl after the type it implements. Trait impls may
+// define methods an inherent impl also has, so they are named <T as Trait>.
+func rustScopeName(n *sitter.Node, content []byte) string {
+	if n.Type() != "impl_item" {
+		return ""
+	}
+	typ := nodeText(n.ChildByFieldName("type"), content)
+	if trait := nodeText(n.ChildByFieldName("trait"), content); trait != "" && typ != "" {
+		return fmt.Sprintf("<%s as %s>", typ, trait)
+	}
+	return typ
+}
@@ -0,0 +1,40 @@
+package parser
+
+import "testing"
+
+func TestExtractScala(t *testing.T) {
+	code := `package com.acme
+
+/** A service */
+class UserService(repo: Repo) extends Service {
+  def find(id: Int): User = lookup(id)
+  private def lookup(id: Int): User = repo.get(id)
+}
+trait Service { def name: String }
+object Main { def main(args: Array[String]): Unit = println(parse[Int]("1")) }
+case class User(id: Int)
+type Users = List[User]
+def parse[T](s: String): T = ???
+`
+	nodes, calls := parseSample(t, "UserService.scala", LangScala, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"UserService":        NodeTypeClass,
+		"UserService.find":   NodeTypeMethod,
+		"UserService.lookup": NodeTypeMethod,
+		"Service":            NodeTypeInterface,
+		"Service.name":       NodeTypeMethod,
+		"Main":               NodeTypeClass,
+		"Main.main":          NodeTypeMethod,
+		"User":               NodeTypeClass,
+		"Users":              NodeTypeType,
+		"parse":              NodeTypeFunction,
+	}, []string{
+		"UserService.find -> UserService.lookup",
+		"UserService.lookup -> external::repo.get",
+		"Main.main -> parse",
+	})
+
+	if doc := nodes["UserService"].DocComment; doc != "A service" {
+		t.Errorf("expected class doc comment 'A service', got %q", doc)
+	}
+}
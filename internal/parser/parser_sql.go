@@ -0,0 +1,9 @@
+package parser
+
+// sqlContract returns the contract of a table or view
+func sqlContract(n *CodeNode) string {
+	if n.NodeType != NodeTypeTable {
+		return ""
+	}
+	return tableContract(n.Name)
+}
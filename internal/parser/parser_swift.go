@@ -0,0 +1,17 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// swiftArity counts the parameters of a function, which are children of the
+// declaration itself
+func swiftArity(def *sitter.Node) int {
+	count := 0
+	for i := 0; i < int(def.NamedChildCount()); i++ {
+		if def.NamedChild(i).Type() == "parameter" {
+			count++
+		}
+	}
+	return count
+}
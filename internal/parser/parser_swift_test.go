@@ -0,0 +1,53 @@
+package parser
+
+import "testing"
+
+func TestExtractSwift(t *testing.T) {
+	code := `import Foundation
+
+/// A shape
+protocol Shape { func area() -> Double }
+class Circle: Shape {
+    init(r: Double) { self.r = r }
+    func area() -> Double { let s = self.scale(); return compute(r) * s }
+    func scale() -> Double { 1 }
+}
+struct Point { func dist() -> Double { return sqrt(2) } }
+enum Color { case red }
+extension Circle { func describe() { print(self.area()) } }
+typealias Radius = Double
+func compute(_ x: Double) -> Double { x * x }
+`
+	nodes, calls := parseSample(t, "Shape.swift", LangSwift, code)
+	checkSample(t, nodes, calls, map[string]NodeType{
+		"Shape":           NodeTypeInterface,
+		"Shape.area":      NodeTypeMethod,
+		"Circle":          NodeTypeClass,
+		"Circle.init":     NodeTypeMethod,
+		"Circle.area":     NodeTypeMethod,
+		"Circle.scale":    NodeTypeMethod,
+		"Point":           NodeTypeStruct,
+		"Point.dist":      NodeTypeMethod,
+		"Color":           NodeTypeEnum,
+		"Circle.describe": NodeTypeMethod,
+		"Radius":          NodeTypeType,
+		"compute":         NodeTypeFunction,
+	}, []string{
+		"Circle.area -> compute",
+		"Circle.area -> Circle.scale",
+		"Circle.describe -> print",
+	})
+
+	if doc := nodes["Shape"].DocComment; doc != "A shape" {
+		t.Errorf("expected protocol doc comment 'A shape', got %q", doc)
+	}
+	var circles int
+	for _, n := range nodes {
+		if n.Name == "Circle" {
+			circles++
+		}
+	}
+	if circles != 1 {
+		t.Errorf("expected an extension not to add a Circle node, got %d", circles)
+	}
+}
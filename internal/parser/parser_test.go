@@ -2,6 +2,7 @@ package parser
 
 import (
 	"context"
+	"strings"
 	"testing"
 )
 
@@ -163,3 +164,157 @@ int init_system(void) {
 		t.Errorf("Expected summary='Initializes the system components', got %q", initNode.Annotations["summary"])
 	}
 }
+
+func TestExtractJSBindings(t *testing.T) {
+	code := `/** Handles requests */
+export const handler = async (req) => {
+    const inner = () => req;
+    return inner();
+};
+const named = function impl(a, b) {};
+const Widget = class {};
+class Store {
+    load = async (id) => id;
+    static limit = 10;
+    save() {}
+}
+const api = {
+    get() {},
+    post: async (body) => body,
+    retries: 3,
+};
+`
+	p := NewParser()
+	result, err := p.ParseContent(context.Background(), "api.js", LangJavaScript, []byte(code))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[n.ID] = n
+	}
+	want := map[string]NodeType{
+		"api.js::handler":       NodeTypeFunction,
+		"api.js::handler.inner": NodeTypeFunction,
+		"api.js::named":         NodeTypeFunction,
+		"api.js::Widget":        NodeTypeClass,
+		"api.js::Store.load":    NodeTypeMethod,
+		"api.js::Store.save":    NodeTypeMethod,
+		"api.js::api.get":       NodeTypeMethod,
+		"api.js::api.post":      NodeTypeMethod,
+	}
+	for id, nt := range want {
+		if n, ok := nodes[id]; !ok || n.NodeType != nt {
+			t.Errorf("expected %s node %s, got %+v", nt, id, n)
+		}
+	}
+	for _, id := range []string{"api.js::impl", "api.js::Store.limit", "api.js::api.retries"} {
+		if _, ok := nodes[id]; ok {
+			t.Errorf("did not expect node %s", id)
+		}
+	}
+
+	handler := nodes["api.js::handler"]
+	if handler.StartLine != 2 || handler.DocComment == "" || !strings.HasPrefix(handler.Content, "export const handler") {
+		t.Errorf("expected the exported declaration with its doc comment, got %+v", handler)
+	}
+}
+
+func TestExtractTSDeclarations(t *testing.T) {
+	code := `export interface Props { label: string }
+type ID = string;
+enum Color { Red, Green }
+abstract class Shape {
+    abstract area(): number;
+    scale = (factor: number) => factor;
+}
+namespace Geometry {
+    export const origin = () => 0;
+}
+`
+	p := NewParser()
+	for _, tt := range []struct {
+		file string
+		lang Language
+		code string
+	}{
+		{"shapes.ts", LangTypeScript, code},
+		{"shapes.tsx", LangTSX, code + "const Button = (props: Props) => <button>{props.label}</button>;\n"},
+	} {
+		if got := p.DetectLanguage(tt.file); got != tt.lang {
+			t.Errorf("DetectLanguage(%s) = %s, want %s", tt.file, got, tt.lang)
+		}
+		result, err := p.ParseContent(context.Background(), tt.file, tt.lang, []byte(tt.code))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+
+		nodes := make(map[string]NodeType)
+		for _, n := range result.Nodes {
+			nodes[strings.TrimPrefix(n.ID, tt.file+"::")] = n.NodeType
+		}
+		want := map[string]NodeType{
+			"Props":           NodeTypeInterface,
+			"ID":              NodeTypeType,
+			"Color":           NodeTypeEnum,
+			"Shape":           NodeTypeClass,
+			"Shape.scale":     NodeTypeMethod,
+			"Geometry":        NodeTypeModule,
+			"Geometry.origin": NodeTypeFunction,
+		}
+		if tt.lang == LangTSX {
+			want["Button"] = NodeTypeFunction
+		}
+		for name, nt := range want {
+			if nodes[name] != nt {
+				t.Errorf("%s: expected %s node %s, got %q", tt.file, nt, name, nodes[name])
+			}
+		}
+	}
+}
+
+// parseSample parses code as file, checking that the file's extension detects
+// lang, and returns its nodes and call edges with IDs relative to the file.
+// Calls are listed as "from -> to".
+func parseSample(t *testing.T, file string, lang Language, code string) (map[string]CodeNode, map[string]bool) {
+	t.Helper()
+	p := NewParser()
+	if got := p.DetectLanguage(file); got != lang {
+		t.Errorf("DetectLanguage(%s) = %s, want %s", file, got, lang)
+	}
+	result, err := p.ParseContent(context.Background(), file, lang, []byte(code))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	local := func(id string) string {
+		return strings.TrimPrefix(id, file+"::")
+	}
+	nodes := make(map[string]CodeNode)
+	for _, n := range result.Nodes {
+		nodes[local(n.ID)] = n
+	}
+	calls := make(map[string]bool)
+	for _, e := range result.Edges {
+		if e.EdgeType == EdgeTypeCalls {
+			calls[local(e.FromID)+" -> "+local(e.ToID)] = true
+		}
+	}
+	return nodes, calls
+}
+
+// checkSample reports missing nodes of the wanted types and missing calls
+func checkSample(t *testing.T, nodes map[string]CodeNode, calls map[string]bool, wantNodes map[string]NodeType, wantCalls []string) {
+	t.Helper()
+	for id, nt := range wantNodes {
+		if n, ok := nodes[id]; !ok || n.NodeType != nt {
+			t.Errorf("expected %s node %s, got %+v", nt, id, n)
+		}
+	}
+	for _, call := range wantCalls {
+		if !calls[call] {
+			t.Errorf("expected call %s, got %v", call, calls)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package parser
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// C and C++ sources are parsed after a light preprocessing pass: the
+// branches of #if, #ifdef and #ifndef conditionals that are known not to be
+// compiled are blanked, together with the directives of the conditional, so
+// the grammar sees the code the compiler sees. A condition is known when the
+// macros it tests are: those defined or undefined with -D and -U in the
+// file's compile command (see compile_commands.go), those the file itself
+// defines or undefines before it, and __cplusplus, which only C++ defines.
+// Conditionals on other macros, such as those of included headers or of the
+// platform, keep all their branches. Blanking keeps every byte offset and
+// line, so nodes still point at the source as written.
+
+// conditional is an #if being read
+type conditional struct {
+	known  bool // its branches are chosen and its directives blanked
+	active bool // the current branch is compiled
+	taken  bool // a branch has been compiled
+	outer  bool // the code around the conditional is compiled
+}
+
+// preprocessC returns the source of a C or C++ file with the branches it
+// does not compile blanked
+func preprocessC(p *Parser, lang Language, filePath string, content []byte) []byte {
+	macros := make(map[string]string)
+	undefined := make(map[string]bool)
+	if u := p.compileUnitOf(filePath); u != nil {
+		for name, value := range u.defines {
+			macros[name] = value
+		}
+		for name := range u.undefined {
+			undefined[name] = true
+		}
+		if lang == LangCPP {
+			macros["__cplusplus"] = cplusplusVersion(u.std)
+		}
+	}
+	if lang == LangCPP {
+		if _, ok := macros["__cplusplus"]; !ok {
+			macros["__cplusplus"] = cplusplusVersion("")
+		}
+	} else {
+		undefined["__cplusplus"] = true
+	}
+
+	var source []byte
+	blank := func(start, end int) {
+		if source == nil {
+			source = bytes.Clone(content)
+		}
+		for i := start; i < end; i++ {
+			if source[i] != '\n' {
+				source[i] = ' '
+			}
+		}
+	}
+
+	var stack []*conditional
+	compiled := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+	for start := 0; start < len(content); {
+		// A directive continues over lines ending with a backslash
+		end := start
+		for {
+			nl := bytes.IndexByte(content[end:], '\n')
+			if nl < 0 {
+				end = len(content)
+				break
+			}
+			end += nl + 1
+			if !bytes.HasSuffix(bytes.TrimRight(content[start:end-1], "\r"), []byte(`\`)) {
+				break
+			}
+		}
+		line := string(content[start:end])
+		next := end
+		directive, args := splitDirective(line)
+		on := compiled()
+
+		switch directive {
+		case "if", "ifdef", "ifndef":
+			c := &conditional{outer: on}
+			value, known := false, false
+			if on {
+				switch directive {
+				case "ifdef":
+					value, known = isDefined(args, macros, undefined)
+				case "ifndef":
+					value, known = isDefined(args, macros, undefined)
+					value = !value
+					if next, name := nextDirective(content[end:]); !known && next == "define" && firstWord(name) == firstWord(args) {
+						// An include guard is read as on the first inclusion
+						value, known = true, true
+					}
+				default:
+					value, known = evalCondition(args, macros, undefined)
+				}
+			}
+			c.known = known || !on
+			c.active = on && (value || !known)
+			c.taken = c.active
+			stack = append(stack, c)
+			if c.known {
+				blank(start, end)
+			}
+		case "elif", "elifdef", "elifndef", "else":
+			if len(stack) == 0 {
+				break
+			}
+			c := stack[len(stack)-1]
+			if !c.known {
+				// Unknown conditionals keep all their branches
+				break
+			}
+			blank(start, end)
+			value, known := true, true
+			switch directive {
+			case "elif":
+				value, known = evalCondition(args, macros, undefined)
+			case "elifdef":
+				value, known = isDefined(args, macros, undefined)
+			case "elifndef":
+				value, known = isDefined(args, macros, undefined)
+				value = !value
+			}
+			// An unknown #elif after branches known not to be compiled is
+			// taken to be the branch compiled
+			c.active = c.outer && !c.taken && (value || !known)
+			c.taken = c.taken || c.active
+		case "endif":
+			if len(stack) == 0 {
+				break
+			}
+			if stack[len(stack)-1].known {
+				blank(start, end)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if !on {
+				blank(start, end)
+				break
+			}
+			if directive != "define" && directive != "undef" {
+				break
+			}
+			name, value := macroDefinition(args)
+			delete(macros, name)
+			delete(undefined, name)
+			if slices.ContainsFunc(stack, func(c *conditional) bool { return !c.known }) {
+				// Defined in a branch that may not be compiled, the macro
+				// is no longer known
+				break
+			}
+			if directive == "define" {
+				macros[name] = value
+			} else {
+				undefined[name] = true
+			}
+		}
+		start = next
+	}
+	if source == nil {
+		return content
+	}
+	return source
+}
+
+// splitDirective returns the name and arguments of a preprocessor directive
+// line, without comments, or "" when it is not one
+func splitDirective(line string) (string, string) {
+	trimmed := strings.TrimLeft(line, " \t")
+	rest, ok := strings.CutPrefix(trimmed, "#")
+	if !ok {
+		return "", ""
+	}
+	rest = strings.ReplaceAll(rest, "\\\r\n", " ")
+	rest = strings.ReplaceAll(rest, "\\\n", " ")
+	if i := strings.Index(rest, "//"); i >= 0 {
+		rest = rest[:i]
+	}
+	for {
+		i := strings.Index(rest, "/*")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(rest[i+2:], "*/")
+		if j < 0 {
+			rest = rest[:i]
+			break
+		}
+		rest = rest[:i] + " " + rest[i+2+j+2:]
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	name := rest
+	for i, r := range rest {
+		if !isIdentRune(r) {
+			name = rest[:i]
+			break
+		}
+	}
+	return name, strings.TrimSpace(rest[len(name):])
+}
+
+// nextDirective returns the directive of the first line of content that is
+// not blank, or ""
+func nextDirective(content []byte) (string, string) {
+	for len(content) > 0 {
+		line, rest, _ := bytes.Cut(content, []byte("\n"))
+		if len(bytes.TrimSpace(line)) > 0 {
+			return splitDirective(string(line))
+		}
+		content = rest
+	}
+	return "", ""
+}
+
+// macroDefinition returns the name and value of a #define
+func macroDefinition(args string) (string, string) {
+	name := firstWord(args)
+	value := strings.TrimSpace(args[len(name):])
+	if strings.HasPrefix(args[len(name):], "(") {
+		// A function-like macro has no value a condition can test
+		return name, ""
+	}
+	return name, value
+}
+
+func firstWord(s string) string {
+	for i, r := range s {
+		if !isIdentRune(r) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// isDefined reports whether a macro is defined and whether that is known
+func isDefined(name string, macros map[string]string, undefined map[string]bool) (defined, known bool) {
+	name = firstWord(name)
+	if _, ok := macros[name]; ok {
+		return true, true
+	}
+	return false, undefined[name]
+}
+
+// cplusplusVersion returns the value of __cplusplus for a -std value
+func cplusplusVersion(std string) string {
+	_, version, _ := strings.Cut(std, "++")
+	switch version {
+	case "98", "03":
+		return "199711L"
+	case "11", "0x":
+		return "201103L"
+	case "14", "1y":
+		return "201402L"
+	case "20", "2a":
+		return "202002L"
+	case "23", "2b":
+		return "202302L"
+	case "26", "2c":
+		return "202400L"
+	}
+	return "201703L"
+}
+
+// evalCondition evaluates the condition of an #if: its value, and whether
+// it is known
+func evalCondition(expr string, macros map[string]string, undefined map[string]bool) (bool, bool) {
+	e := &condEval{tokens: condTokens(expr), macros: macros, undefined: undefined}
+	v, known := e.or()
+	if e.pos != len(e.tokens) {
+		return false, false
+	}
+	return v != 0, known
+}
+
+// condEval evaluates #if conditions made of integers, macros, defined,
+// !, comparisons, && and ||, in parentheses. Anything else is unknown.
+type condEval struct {
+	tokens    []string
+	pos       int
+	macros    map[string]string
+	undefined map[string]bool
+	depth     int
+}
+
+func (e *condEval) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return ""
+}
+
+func (e *condEval) or() (int64, bool) {
+	v, known := e.and()
+	for e.peek() == "||" {
+		e.pos++
+		w, wKnown := e.and()
+		switch {
+		case known && v != 0, wKnown && w != 0:
+			v, known = 1, true
+		default:
+			v, known = 0, known && wKnown
+		}
+	}
+	return v, known
+}
+
+func (e *condEval) and() (int64, bool) {
+	v, known := e.compare()
+	for e.peek() == "&&" {
+		e.pos++
+		w, wKnown := e.compare()
+		switch {
+		case known && v == 0, wKnown && w == 0:
+			v, known = 0, true
+		default:
+			v, known = 1, known && wKnown
+		}
+	}
+	return v, known
+}
+
+func (e *condEval) compare() (int64, bool) {
+	v, known := e.unary()
+	for {
+		op := e.peek()
+		switch op {
+		case "==", "!=", "<", ">", "<=", ">=":
+		default:
+			return v, known
+		}
+		e.pos++
+		w, wKnown := e.unary()
+		var r bool
+		switch op {
+		case "==":
+			r = v == w
+		case "!=":
+			r = v != w
+		case "<":
+			r = v < w
+		case ">":
+			r = v > w
+		case "<=":
+			r = v <= w
+		case ">=":
+			r = v >= w
+		}
+		v, known = 0, known && wKnown
+		if r {
+			v = 1
+		}
+	}
+}
+
+func (e *condEval) unary() (int64, bool) {
+	tok := e.peek()
+	e.pos++
+	switch {
+	case tok == "!":
+		v, known := e.unary()
+		if v == 0 {
+			return 1, known
+		}
+		return 0, known
+	case tok == "(":
+		v, known := e.or()
+		if e.peek() != ")" {
+			e.pos = len(e.tokens) + 1
+			return 0, false
+		}
+		e.pos++
+		return v, known
+	case tok == "defined":
+		name := e.peek()
+		parens := name == "("
+		if parens {
+			e.pos++
+			name = e.peek()
+		}
+		e.pos++
+		if parens {
+			if e.peek() != ")" {
+				e.pos = len(e.tokens) + 1
+				return 0, false
+			}
+			e.pos++
+		}
+		defined, known := isDefined(name, e.macros, e.undefined)
+		if defined {
+			return 1, known
+		}
+		return 0, known
+	case tok != "" && tok[0] >= '0' && tok[0] <= '9':
+		v, err := strconv.ParseInt(strings.TrimRight(tok, "uUlL"), 0, 64)
+		return v, err == nil
+	case tok != "" && isIdentRune(rune(tok[0])):
+		value, ok := e.macros[tok]
+		if !ok {
+			return 0, e.undefined[tok]
+		}
+		// A macro's value is read as a condition of its own
+		if e.depth > 8 {
+			return 0, false
+		}
+		inner := &condEval{tokens: condTokens(value), macros: e.macros, undefined: e.undefined, depth: e.depth + 1}
+		v, known := inner.or()
+		return v, known && inner.pos == len(inner.tokens) && len(inner.tokens) > 0
+	}
+	e.pos = len(e.tokens) + 1
+	return 0, false
+}
+
+// condTokens splits a condition into identifiers, numbers and operators
+func condTokens(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case isIdentRune(rune(c)):
+			j := i
+			for j < len(expr) && (isIdentRune(rune(expr[j])) || (expr[i] >= '0' && expr[i] <= '9' && expr[j] == '.')) {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			op := expr[i : i+1]
+			if i+1 < len(expr) {
+				switch two := expr[i : i+2]; two {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,9 @@
+; Builtins never name a script function, and paths and commands computed at
+; run time name none we know of
+((command
+  name: (command_name) @name) @reference.call
+  (#not-any-of? @name
+    "." ":" "[" "[[" "alias" "break" "builtin" "cd" "command" "continue" "declare" "echo"
+    "eval" "exec" "exit" "export" "false" "getopts" "local" "printf" "pwd" "read" "readonly"
+    "return" "set" "shift" "source" "test" "trap" "true" "type" "typeset" "unset" "wait")
+  (#not-match? @name "[$/`\"']"))
@@ -0,0 +1,7 @@
+; source ./lib.sh and . lib.sh, unless the path is only known at run time
+((command
+  name: (command_name) @command
+  .
+  argument: (_) @import.file)
+  (#any-of? @command "source" ".")
+  (#not-match? @import.file "[$`]"))
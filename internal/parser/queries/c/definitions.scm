@@ -0,0 +1,34 @@
+; The name of a function is inside its declarator, which pointer
+; declarators may wrap
+(function_definition
+  declarator: (function_declarator
+    declarator: (identifier) @name)) @definition.function
+
+(function_definition
+  declarator: (pointer_declarator
+    declarator: (function_declarator
+      declarator: (identifier) @name))) @definition.function
+
+(function_definition
+  declarator: (pointer_declarator
+    declarator: (pointer_declarator
+      declarator: (function_declarator
+        declarator: (identifier) @name)))) @definition.function
+
+; Function-like macros
+(preproc_function_def
+  name: (identifier) @name) @definition.macro
+
+; Only a body defines a struct, enum or union: struct node *next refers to
+; one
+(struct_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.struct @scope
+
+(enum_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.enum
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (_)) @scope
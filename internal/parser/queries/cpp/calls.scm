@@ -0,0 +1,7 @@
+; obj.method() and ptr->method() call the field
+(call_expression
+  function: (field_expression
+    field: (_) @name)) @reference.call
+
+(call_expression
+  function: (_) @name) @reference.call
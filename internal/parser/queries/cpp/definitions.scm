@@ -0,0 +1,72 @@
+; The name of a function is inside its declarator, which pointer and
+; reference declarators may wrap. Methods defined out of their class are
+; named by their qualified name, and functions defined in a class are
+; methods (see cppRefine).
+(function_definition
+  declarator: (function_declarator
+    declarator: [
+      (identifier)
+      (field_identifier)
+      (qualified_identifier)
+      (operator_name)
+      (destructor_name)
+    ] @name)) @definition.function
+
+(function_definition
+  declarator: [
+    (pointer_declarator
+      declarator: (function_declarator
+        declarator: [
+          (identifier)
+          (field_identifier)
+          (qualified_identifier)
+          (operator_name)
+        ] @name))
+    (reference_declarator
+      (function_declarator
+        declarator: [
+          (identifier)
+          (field_identifier)
+          (qualified_identifier)
+          (operator_name)
+        ] @name))
+  ]) @definition.function
+
+(function_definition
+  declarator: (pointer_declarator
+    declarator: (pointer_declarator
+      declarator: (function_declarator
+        declarator: [
+          (identifier)
+          (field_identifier)
+          (qualified_identifier)
+        ] @name)))) @definition.function
+
+; Function-like macros
+(preproc_function_def
+  name: (identifier) @name) @definition.macro
+
+; Only a body defines a class, struct, enum or union: class Shape; declares
+; one and struct Point p refers to one. Templates are defined by the class
+; or function inside them.
+(class_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.class @scope
+
+(struct_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.struct @scope
+
+(enum_specifier
+  name: (type_identifier) @name
+  body: (_)) @definition.enum
+
+(union_specifier
+  name: (type_identifier) @name
+  body: (_)) @scope
+
+(namespace_definition
+  name: [
+    (namespace_identifier)
+    (nested_namespace_specifier)
+  ] @name) @scope
@@ -0,0 +1,9 @@
+(preproc_include
+  path: [
+    (string_literal)
+    (identifier)
+  ] @import.module)
+
+; <stdio.h> never resolves within the repository
+(preproc_include
+  path: (system_lib_string) @import.system)
@@ -0,0 +1,5 @@
+(base_class_clause
+  [
+    (type_identifier)
+    (qualified_identifier)
+  ] @name @reference.extends)
@@ -0,0 +1,18 @@
+(invocation_expression
+  function: [
+    (identifier) @name
+    ; Parse<int>(s)
+    (generic_name
+      .
+      (identifier) @name)
+  ]) @reference.call
+
+(invocation_expression
+  function: (member_access_expression
+    expression: (_) @receiver
+    name: [
+      (identifier) @name
+      (generic_name
+        .
+        (identifier) @name)
+    ])) @reference.call
@@ -0,0 +1,26 @@
+(class_declaration
+  name: (identifier) @name) @definition.class @scope
+
+(record_declaration
+  name: (identifier) @name) @definition.class @scope
+
+(struct_declaration
+  name: (identifier) @name) @definition.struct @scope
+
+(interface_declaration
+  name: (identifier) @name) @definition.interface @scope
+
+(enum_declaration
+  name: (identifier) @name) @definition.enum @scope
+
+(delegate_declaration
+  name: (identifier) @name) @definition.type @scope
+
+(method_declaration
+  name: (identifier) @name) @definition.method @scope
+
+(constructor_declaration
+  name: (identifier) @name) @definition.method @scope
+
+(local_function_statement
+  name: (identifier) @name) @definition.function @scope
@@ -0,0 +1,5 @@
+; The namespace is the last child: using Json = Newtonsoft.Json names the
+; alias first
+(using_directive
+  (_) @import.module
+  .)
@@ -0,0 +1,8 @@
+(base_list
+  [
+    (identifier) @name
+    (qualified_name) @name
+    (generic_name
+      .
+      (identifier) @name)
+  ] @reference.extends)
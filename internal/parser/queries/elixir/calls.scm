@@ -0,0 +1,22 @@
+; Definitions and special forms are macros called like functions that are
+; not calls worth an edge
+((call
+  target: (identifier) @name) @reference.call
+  (#not-any-of? @name
+    "defmodule" "defprotocol" "defimpl" "def" "defp" "defdelegate" "defguard" "defguardp"
+    "defmacro" "defmacrop" "alias" "import" "require" "use" "if" "unless" "case" "cond" "with"
+    "for" "fn" "quote" "unquote" "receive" "try" "raise" "defstruct" "defexception"
+    "defoverridable"))
+
+(call
+  target: (dot
+    left: (_) @receiver
+    right: (identifier) @name)
+  (arguments)) @reference.call
+
+; cart.items without arguments reads a map field
+((call
+  target: (dot
+    left: (_) @receiver
+    right: (identifier) @name)) @reference.call
+  (#not-kind-eq? @receiver identifier))
@@ -0,0 +1,82 @@
+; Definitions are macro calls: def total(cart) is a call to def whose first
+; argument is the call head total(cart)
+
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    [
+      (alias) @name
+      (identifier) @name
+      (call
+        target: (identifier) @name)
+      ; def f(x) when x > 0
+      (binary_operator
+        left: [
+          (alias) @name
+          (identifier) @name
+          (call
+            target: (identifier) @name)
+        ])
+    ])) @definition.module @scope
+  (#any-of? @keyword "defmodule" "defimpl"))
+
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    [
+      (alias) @name
+      (identifier) @name
+      (call
+        target: (identifier) @name)
+      ; def f(x) when x > 0
+      (binary_operator
+        left: [
+          (alias) @name
+          (identifier) @name
+          (call
+            target: (identifier) @name)
+        ])
+    ])) @definition.interface @scope
+  (#any-of? @keyword "defprotocol"))
+
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    [
+      (alias) @name
+      (identifier) @name
+      (call
+        target: (identifier) @name)
+      ; def f(x) when x > 0
+      (binary_operator
+        left: [
+          (alias) @name
+          (identifier) @name
+          (call
+            target: (identifier) @name)
+        ])
+    ])) @definition.function
+  (#any-of? @keyword "def" "defp" "defdelegate" "defguard" "defguardp"))
+
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    [
+      (alias) @name
+      (identifier) @name
+      (call
+        target: (identifier) @name)
+      ; def f(x) when x > 0
+      (binary_operator
+        left: [
+          (alias) @name
+          (identifier) @name
+          (call
+            target: (identifier) @name)
+        ])
+    ])) @definition.macro
+  (#any-of? @keyword "defmacro" "defmacrop"))
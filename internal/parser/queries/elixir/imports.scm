@@ -0,0 +1,17 @@
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    (alias) @import.module))
+  (#any-of? @keyword "alias" "import" "require" "use"))
+
+; alias Shop.{Cart, Order}
+((call
+  target: (identifier) @keyword
+  (arguments
+    .
+    (dot
+      left: (_) @import.prefix
+      right: (tuple
+        (_) @import.module))))
+  (#any-of? @keyword "alias" "import" "require" "use"))
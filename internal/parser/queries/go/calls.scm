@@ -0,0 +1,9 @@
+(call_expression
+  function: [
+    (identifier)
+    (selector_expression)
+  ] @name) @reference.call
+
+; Generic instantiations and other callee expressions: the first name in them
+(call_expression
+  function: (_ (identifier) @name)) @reference.call
@@ -0,0 +1,2 @@
+(import_spec
+  path: (_) @import.module)
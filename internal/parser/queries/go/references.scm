@@ -0,0 +1,15 @@
+; Embedded structs and interfaces
+(field_declaration
+  !name
+  type: [
+    (type_identifier) @name
+    (pointer_type (type_identifier) @name)
+    (qualified_type) @name
+  ]) @reference.type
+
+(interface_type
+  (type_elem
+    [
+      (type_identifier) @name
+      (qualified_type) @name
+    ]) @reference.type)
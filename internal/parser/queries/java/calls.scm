@@ -0,0 +1,2 @@
+(method_invocation
+  name: (identifier) @name) @reference.call
@@ -0,0 +1,20 @@
+(method_declaration
+  name: (identifier) @name) @definition.method @scope
+
+(class_declaration
+  name: (identifier) @name) @definition.class @scope
+
+(interface_declaration
+  name: (identifier) @name) @definition.interface @scope
+
+(enum_declaration
+  name: (identifier) @name) @definition.enum @scope
+
+(record_declaration
+  name: (identifier) @name) @scope
+
+(annotation_type_declaration
+  name: (identifier) @name) @scope
+
+(constructor_declaration
+  name: (identifier) @name) @scope
@@ -0,0 +1,9 @@
+(import_declaration
+  [
+    (scoped_identifier)
+    (identifier)
+  ] @import.module) @import
+
+; import a.b.*
+(import_declaration
+  (asterisk) @import.all) @import
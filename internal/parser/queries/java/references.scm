@@ -0,0 +1,28 @@
+(superclass
+  [
+    (type_identifier) @name
+    (scoped_type_identifier) @name
+    (generic_type
+      .
+      (_) @name)
+  ] @reference.extends)
+
+(super_interfaces
+  (type_list
+    [
+      (type_identifier) @name
+      (scoped_type_identifier) @name
+      (generic_type
+        .
+        (_) @name)
+    ] @reference.implementation))
+
+(extends_interfaces
+  (type_list
+    [
+      (type_identifier) @name
+      (scoped_type_identifier) @name
+      (generic_type
+        .
+        (_) @name)
+    ] @reference.extends))
@@ -0,0 +1,9 @@
+(call_expression
+  function: [
+    (identifier)
+    (member_expression)
+  ] @name) @reference.call
+
+; Other callee expressions: the first name in them
+(call_expression
+  function: (_ (identifier) @name)) @reference.call
@@ -0,0 +1,84 @@
+; Functions and classes bound to a variable, property or field are named
+; after the binding, which the patterns further down capture
+
+(function_declaration
+  name: (identifier) @name) @definition.function @scope
+
+(generator_function_declaration
+  name: (identifier) @name) @definition.function @scope
+
+((function_expression
+  name: (identifier) @name) @definition.function @scope
+  (#not-has-parent? @definition.function variable_declarator pair field_definition))
+
+(class_declaration
+  name: (_) @name) @definition.class @scope
+
+((class
+  name: (_) @name) @scope
+  (#not-has-parent? @scope variable_declarator pair field_definition))
+
+(method_definition
+  name: (_) @name) @definition.method @scope
+
+; const handler = async (req) => {...}, object-literal methods written as
+; properties and class fields holding functions
+(variable_declarator
+  name: (identifier) @name
+  value: [
+    (arrow_function)
+    (function_expression)
+    (generator_function)
+  ]) @definition.function @scope
+
+(pair
+  key: [
+    (property_identifier)
+    (string)
+  ] @name
+  value: [
+    (arrow_function)
+    (function_expression)
+    (generator_function)
+  ]) @definition.method @scope
+
+(field_definition
+  property: [
+    (property_identifier)
+    (private_property_identifier)
+  ] @name
+  value: [
+    (arrow_function)
+    (function_expression)
+    (generator_function)
+  ]) @definition.method @scope
+
+(variable_declarator
+  name: (identifier) @name
+  value: (class)) @definition.class @scope
+
+(pair
+  key: [
+    (property_identifier)
+    (string)
+  ] @name
+  value: (class)) @definition.class @scope
+
+(field_definition
+  property: [
+    (property_identifier)
+    (private_property_identifier)
+  ] @name
+  value: (class)) @definition.class @scope
+
+; The methods of an object literal are named after its binding
+(variable_declarator
+  name: (identifier) @name
+  value: (object)) @scope
+
+(pair
+  key: [
+    (property_identifier)
+    (string)
+  ] @name
+  value: (object)) @scope
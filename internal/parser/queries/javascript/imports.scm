@@ -0,0 +1,22 @@
+; import x from "m" and export { x } from "m"
+(import_statement
+  source: (string) @import.module)
+
+(export_statement
+  source: (string) @import.module)
+
+; require("m") and import("m")
+((call_expression
+  function: (identifier) @function
+  arguments: (arguments
+    .
+    (string) @import.module
+    .))
+  (#eq? @function "require"))
+
+(call_expression
+  function: (import)
+  arguments: (arguments
+    .
+    (string) @import.module
+    .))
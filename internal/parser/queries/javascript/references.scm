@@ -0,0 +1,5 @@
+(class_heritage
+  [
+    (identifier)
+    (member_expression)
+  ] @name @reference.extends)
@@ -0,0 +1,14 @@
+(call_expression
+  .
+  (simple_identifier) @name) @reference.call
+
+; repo.get(id) calls the last name of a navigation
+(call_expression
+  .
+  (navigation_expression
+    .
+    (_) @receiver
+    (navigation_suffix
+      (simple_identifier) @name
+      .)
+    .)) @reference.call
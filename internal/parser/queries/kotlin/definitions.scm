@@ -0,0 +1,28 @@
+(class_declaration
+  "interface"
+  (type_identifier) @name) @definition.interface @scope
+
+(class_declaration
+  (type_identifier) @name
+  (enum_class_body)) @definition.enum @scope
+
+(class_declaration
+  (type_identifier) @name) @definition.class @scope
+
+(object_declaration
+  (type_identifier) @name) @definition.class @scope
+
+; Functions in a class body are methods
+(class_body
+  (function_declaration
+    (simple_identifier) @name) @definition.method @scope)
+
+(enum_class_body
+  (function_declaration
+    (simple_identifier) @name) @definition.method @scope)
+
+(function_declaration
+  (simple_identifier) @name) @definition.function @scope
+
+(type_alias
+  (type_identifier) @name) @definition.type
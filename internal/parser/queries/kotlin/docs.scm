@@ -0,0 +1,21 @@
+; The grammar attaches a comment before a declaration to the end of whatever
+; precedes it, such as the package header or the last import
+((_
+  [
+    (line_comment)
+    (multiline_comment)
+  ] @doc
+  .)
+  .
+  (_) @doc.target)
+
+((_
+  (_
+    [
+      (line_comment)
+      (multiline_comment)
+    ] @doc
+    .)
+  .)
+  .
+  (_) @doc.target)
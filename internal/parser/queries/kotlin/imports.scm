@@ -0,0 +1,6 @@
+(import_header
+  (identifier) @import.module) @import
+
+; import a.b.*
+(import_header
+  (wildcard_import) @import.all) @import
@@ -0,0 +1,9 @@
+; A superclass is constructed, an interface is not
+(delegation_specifier
+  (constructor_invocation
+    (user_type
+      (type_identifier) @name)) @reference.extends)
+
+(delegation_specifier
+  (user_type
+    (type_identifier) @name) @reference.implementation)
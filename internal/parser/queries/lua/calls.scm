@@ -0,0 +1,3 @@
+; What a call calls is read by the Lua spec's callee hook, as the grammar
+; writes its path as a run of sibling tokens
+(function_call) @reference.call
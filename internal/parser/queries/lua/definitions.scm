@@ -0,0 +1,15 @@
+; function add(), local function add(), function M.greet() and
+; function Account:deposit(). Each identifier of a path matches on its own;
+; the Lua spec makes the functions named with a colon methods.
+(function_statement
+  name: (identifier) @name) @definition.function @scope
+
+(function_statement
+  name: (function_name
+    (identifier) @name)) @definition.function @scope
+
+; local helper = function() ... end
+(variable_declaration
+  name: (variable_declarator
+    (identifier) @name)
+  value: (function)) @definition.function
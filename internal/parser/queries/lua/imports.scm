@@ -0,0 +1,11 @@
+; require("a.b") and require "a.b"
+((function_call
+  prefix: (identifier) @function
+  args: [
+    (string_argument) @import.module
+    (function_arguments
+      .
+      (string) @import.module
+      .)
+  ])
+  (#eq? @function "require"))
@@ -0,0 +1,18 @@
+; Calls through variables and closures name no function
+(function_call_expression
+  function: [
+    (name)
+    (qualified_name)
+  ] @name) @reference.call
+
+(member_call_expression
+  object: (_) @receiver
+  name: (_) @name) @reference.call
+
+(nullsafe_member_call_expression
+  object: (_) @receiver
+  name: (_) @name) @reference.call
+
+(scoped_call_expression
+  scope: (_) @receiver
+  name: (_) @name) @reference.call
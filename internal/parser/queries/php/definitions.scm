@@ -0,0 +1,17 @@
+(function_definition
+  name: (name) @name) @definition.function @scope
+
+(method_declaration
+  name: (name) @name) @definition.method @scope
+
+(class_declaration
+  name: (name) @name) @definition.class @scope
+
+(interface_declaration
+  name: (name) @name) @definition.interface @scope
+
+(trait_declaration
+  name: (name) @name) @definition.interface @scope
+
+(enum_declaration
+  name: (name) @name) @definition.enum @scope
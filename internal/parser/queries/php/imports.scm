@@ -0,0 +1,23 @@
+(namespace_use_declaration
+  (namespace_use_clause
+    .
+    (_) @import.module))
+
+; use App\Support\{Str, Arr}
+(namespace_use_declaration
+  (namespace_name) @import.prefix
+  (namespace_use_group
+    (namespace_use_group_clause
+      (namespace_name) @import.module)))
+
+; require 'x.php' and require __DIR__ . '/x.php'
+((_
+  .
+  [
+    (string) @import.file
+    (binary_expression
+      left: (_) @dir
+      right: (string) @import.file)
+  ]) @import
+  (#kind-eq? @import require_expression require_once_expression include_expression include_once_expression)
+  (#eq? @dir "__DIR__"))
@@ -0,0 +1,11 @@
+(base_clause
+  [
+    (name)
+    (qualified_name)
+  ] @name @reference.extends)
+
+(class_interface_clause
+  [
+    (name)
+    (qualified_name)
+  ] @name @reference.implementation)
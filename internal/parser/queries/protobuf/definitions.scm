@@ -0,0 +1,11 @@
+(message
+  (message_name (identifier) @name)) @definition.struct @scope
+
+(enum
+  (enum_name (identifier) @name)) @definition.enum
+
+(service
+  (service_name (identifier) @name)) @definition.interface @scope
+
+(rpc
+  (rpc_name (identifier) @name)) @definition.operation
@@ -0,0 +1,2 @@
+(import
+  path: (string) @import.module) @import
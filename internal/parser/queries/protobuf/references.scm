@@ -0,0 +1,10 @@
+(rpc
+  (message_or_enum_type) @name @reference.type)
+
+(field
+  (type
+    (message_or_enum_type) @name @reference.type))
+
+(map_field
+  (type
+    (message_or_enum_type) @name @reference.type))
@@ -0,0 +1,5 @@
+(class_definition
+  name: (identifier) @name) @definition.class @scope
+
+(function_definition
+  name: (identifier) @name) @definition.function @scope
@@ -0,0 +1,18 @@
+; import a.b, c as d
+(import_statement
+  name: [
+    (dotted_name) @import.module
+    (aliased_import
+      name: (dotted_name) @import.module)
+  ])
+
+; from a import b, c: b and c may be submodules of a
+(import_from_statement
+  module_name: (_) @import.module) @import
+
+(import_from_statement
+  name: [
+    (dotted_name) @import.name
+    (aliased_import
+      name: (dotted_name) @import.name)
+  ]) @import
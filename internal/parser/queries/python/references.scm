@@ -0,0 +1,6 @@
+(class_definition
+  superclasses: (argument_list
+    [
+      (identifier)
+      (attribute)
+    ] @name @reference.extends))
@@ -0,0 +1,6 @@
+(call
+  receiver: (_) @receiver
+  method: (_) @name) @reference.call
+
+(call
+  method: (_) @name) @reference.call
@@ -0,0 +1,28 @@
+; Methods defined in a class or module body are methods, others functions
+(class
+  (body_statement
+    (method
+      name: (_) @name) @definition.method @scope))
+
+(module
+  (body_statement
+    (method
+      name: (_) @name) @definition.method @scope))
+
+(singleton_class
+  (body_statement
+    (method
+      name: (_) @name) @definition.method @scope))
+
+(method
+  name: (_) @name) @definition.function @scope
+
+; def self.build
+(singleton_method
+  name: (_) @name) @definition.method @scope
+
+(class
+  name: (_) @name) @definition.class @scope
+
+(module
+  name: (_) @name) @definition.module @scope
@@ -0,0 +1,7 @@
+; The grammar attaches the comments before a class body to the class, so
+; they document the first definition in it
+((comment) @doc
+  .
+  (body_statement
+    .
+    (_) @doc.target))
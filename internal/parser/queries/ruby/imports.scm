@@ -0,0 +1,17 @@
+((call
+  !receiver
+  method: (identifier) @method
+  arguments: (argument_list
+    .
+    (string) @import.module
+    .))
+  (#any-of? @method "require" "load"))
+
+((call
+  !receiver
+  method: (identifier) @method
+  arguments: (argument_list
+    .
+    (string) @import.file
+    .))
+  (#eq? @method "require_relative"))
@@ -0,0 +1,5 @@
+(superclass
+  [
+    (constant)
+    (scope_resolution)
+  ] @name @reference.extends)
@@ -0,0 +1,10 @@
+(call_expression
+  function: [
+    (identifier)
+    (field_expression)
+    (scoped_identifier)
+  ] @name) @reference.call
+
+; Generic functions and other callee expressions: the first name in them
+(call_expression
+  function: (_ (identifier) @name)) @reference.call
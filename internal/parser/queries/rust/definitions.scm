@@ -0,0 +1,17 @@
+(function_item
+  name: (identifier) @name) @definition.function @scope
+
+(struct_item
+  name: (type_identifier) @name) @definition.struct
+
+(enum_item
+  name: (type_identifier) @name) @definition.enum
+
+(trait_item
+  name: (type_identifier) @name) @definition.interface @scope
+
+(mod_item
+  name: (identifier) @name) @scope
+
+; Named after the implemented type, and the trait when there is one
+(impl_item) @scope
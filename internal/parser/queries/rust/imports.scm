@@ -0,0 +1,21 @@
+; The path a use declaration imports from, without its use list, glob or
+; alias
+((use_declaration
+  argument: (_) @import.module)
+  (#not-kind-eq? @import.module scoped_use_list use_as_clause use_wildcard use_list))
+
+(use_declaration
+  argument: [
+    (scoped_use_list
+      path: (_) @import.module)
+    (use_as_clause
+      path: (_) @import.module)
+    (use_wildcard
+      .
+      (_) @import.module)
+  ])
+
+; mod foo; loads foo.rs or foo/mod.rs
+(mod_item
+  !body
+  name: (identifier) @import.submodule)
@@ -0,0 +1,15 @@
+(call_expression
+  function: [
+    (identifier) @name
+    (field_expression
+      value: (_) @receiver
+      field: (identifier) @name)
+    ; parse[Int](s)
+    (generic_function
+      function: [
+        (identifier) @name
+        (field_expression
+          value: (_) @receiver
+          field: (identifier) @name)
+      ])
+  ]) @reference.call
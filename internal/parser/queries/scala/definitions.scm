@@ -0,0 +1,29 @@
+(class_definition
+  name: (identifier) @name) @definition.class @scope
+
+(object_definition
+  name: (identifier) @name) @definition.class @scope
+
+(trait_definition
+  name: (identifier) @name) @definition.interface @scope
+
+(enum_definition
+  name: (identifier) @name) @definition.enum @scope
+
+(type_definition
+  name: (type_identifier) @name) @definition.type @scope
+
+; Functions in a class, object or trait body are methods
+(template_body
+  [
+    (function_definition
+      name: (identifier) @name)
+    (function_declaration
+      name: (identifier) @name)
+  ] @definition.method @scope)
+
+(function_definition
+  name: (identifier) @name) @definition.function @scope
+
+(function_declaration
+  name: (identifier) @name) @definition.function @scope
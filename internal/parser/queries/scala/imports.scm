@@ -0,0 +1,10 @@
+; Each identifier of a path matches on its own, making up one import
+(import_declaration
+  (identifier) @import.module) @import
+
+; import a.b._ and import a.b.{C, D}
+(import_declaration
+  [
+    (namespace_wildcard)
+    (namespace_selectors)
+  ] @import.all) @import
@@ -0,0 +1,6 @@
+(extends_clause
+  type: [
+    (type_identifier) @name
+    (generic_type
+      type: (type_identifier) @name)
+  ] @reference.extends)
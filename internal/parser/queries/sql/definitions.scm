@@ -0,0 +1,31 @@
+(statement
+  (create_table
+    (object_reference
+      name: (identifier) @name))) @definition.table @scope
+
+(statement
+  (create_view
+    (object_reference
+      name: (identifier) @name))) @definition.table
+
+(statement
+  (create_materialized_view
+    (object_reference
+      name: (identifier) @name))) @definition.table
+
+(statement
+  (create_function
+    (object_reference
+      name: (identifier) @name))) @definition.function
+
+(column_definitions
+  (column_definition
+    name: (identifier) @name) @definition.column)
+
+(alter_table
+  (object_reference
+    name: (identifier) @name)) @scope
+
+(add_column
+  (column_definition
+    name: (identifier) @name) @definition.column)
@@ -0,0 +1,16 @@
+(relation
+  (object_reference
+    name: (identifier) @name) @reference.table)
+
+(insert
+  (object_reference
+    name: (identifier) @name) @reference.table)
+
+(from
+  (object_reference
+    name: (identifier) @name) @reference.table)
+
+(column_definition
+  (keyword_references)
+  (object_reference
+    name: (identifier) @name) @reference.table)
@@ -0,0 +1,47 @@
+; Classes, structs, enums, actors and extensions share one node type.
+; Extensions add to a type declared elsewhere, whose name scopes their
+; members.
+(class_declaration
+  declaration_kind: "struct"
+  name: (type_identifier) @name) @definition.struct @scope
+
+(class_declaration
+  declaration_kind: "enum"
+  name: (type_identifier) @name) @definition.enum @scope
+
+(class_declaration
+  declaration_kind: [
+    "class"
+    "actor"
+  ]
+  name: (type_identifier) @name) @definition.class @scope
+
+(class_declaration
+  declaration_kind: "extension"
+  name: (_) @name) @scope
+
+(protocol_declaration
+  name: (type_identifier) @name) @definition.interface @scope
+
+; Functions in a type body are methods
+([
+  (class_body
+    (function_declaration
+      name: (simple_identifier) @name) @definition.method @scope)
+  (enum_class_body
+    (function_declaration
+      name: (simple_identifier) @name) @definition.method @scope)
+])
+
+(protocol_body
+  (protocol_function_declaration
+    name: (simple_identifier) @name) @definition.method)
+
+(function_declaration
+  name: (simple_identifier) @name) @definition.function @scope
+
+(init_declaration
+  name: "init" @name) @definition.method @scope
+
+(typealias_declaration
+  name: (type_identifier) @name) @definition.type
@@ -0,0 +1,5 @@
+; import struct Models.User imports from the Models module
+(import_declaration
+  (identifier
+    .
+    (simple_identifier) @import.module))
@@ -0,0 +1,3 @@
+(inheritance_specifier
+  inherits_from: (user_type
+    (type_identifier) @name) @reference.extends)
@@ -0,0 +1,17 @@
+(extends_clause
+  value: [
+    (identifier)
+    (member_expression)
+  ] @name @reference.extends)
+
+(implements_clause
+  [
+    (type_identifier)
+    (nested_type_identifier)
+  ] @name @reference.implementation)
+
+(extends_type_clause
+  type: [
+    (type_identifier)
+    (nested_type_identifier)
+  ] @name @reference.extends)
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// query is a compiled .scm query and the predicates of its patterns
+type query struct {
+	q          *sitter.Query
+	predicates [][]predicate // by pattern index
+}
+
+// predicate is one #op? test on a capture
+type predicate struct {
+	op      string
+	capture string
+	other   string   // capture compared by #eq? @a @b
+	args    []string // literal arguments
+	re      *regexp.Regexp
+}
+
+// queryMatch is one match of a pattern, with the nodes of each capture in
+// the order they were captured
+type queryMatch struct {
+	pattern  int
+	captures map[string][]*sitter.Node
+}
+
+// node returns the first node captured as name, or nil
+func (m queryMatch) node(name string) *sitter.Node {
+	if nodes := m.captures[name]; len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+func compileQuery(lang *sitter.Language, source []byte) (*query, error) {
+	q, err := sitter.NewQuery(source, lang)
+	if err != nil {
+		return nil, err
+	}
+	compiled := &query{q: q, predicates: make([][]predicate, q.PatternCount())}
+	for i := range compiled.predicates {
+		for _, steps := range q.PredicatesForPattern(uint32(i)) {
+			pred, err := parsePredicate(q, steps)
+			if err != nil {
+				return nil, fmt.Errorf("pattern %d: %w", i, err)
+			}
+			compiled.predicates[i] = append(compiled.predicates[i], pred)
+		}
+	}
+	return compiled, nil
+}
+
+func parsePredicate(q *sitter.Query, steps []sitter.QueryPredicateStep) (predicate, error) {
+	var pred predicate
+	var operands []sitter.QueryPredicateStep
+	for _, step := range steps {
+		if step.Type != sitter.QueryPredicateStepTypeDone {
+			operands = append(operands, step)
+		}
+	}
+	if len(operands) < 2 || operands[0].Type != sitter.QueryPredicateStepTypeString ||
+		operands[1].Type != sitter.QueryPredicateStepTypeCapture {
+		return pred, fmt.Errorf("predicates take a capture first")
+	}
+	pred.op = q.StringValueForId(operands[0].ValueId)
+	pred.capture = q.CaptureNameForId(operands[1].ValueId)
+	for _, operand := range operands[2:] {
+		if operand.Type == sitter.QueryPredicateStepTypeCapture {
+			pred.other = q.CaptureNameForId(operand.ValueId)
+		} else {
+			pred.args = append(pred.args, q.StringValueForId(operand.ValueId))
+		}
+	}
+
+	switch strings.TrimPrefix(pred.op, "not-") {
+	case "eq?":
+		if len(pred.args) == 0 && pred.other == "" {
+			return pred, fmt.Errorf("#%s needs a value", pred.op)
+		}
+	case "match?":
+		if len(pred.args) != 1 {
+			return pred, fmt.Errorf("#%s needs a regular expression", pred.op)
+		}
+		re, err := regexp.Compile(pred.args[0])
+		if err != nil {
+			return pred, err
+		}
+		pred.re = re
+	case "any-of?", "has-parent?", "kind-eq?":
+		if len(pred.args) == 0 {
+			return pred, fmt.Errorf("#%s needs values", pred.op)
+		}
+	default:
+		return pred, fmt.Errorf("unknown predicate #%s", pred.op)
+	}
+	return pred, nil
+}
+
+// matches returns the matches of q in the tree under root that satisfy
+// their predicates, in document order
+func (q *query) matches(root *sitter.Node, content []byte) []queryMatch {
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(q.q, root)
+
+	var matches []queryMatch
+	for {
+		m, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		match := queryMatch{pattern: int(m.PatternIndex), captures: make(map[string][]*sitter.Node)}
+		for _, c := range m.Captures {
+			name := q.q.CaptureNameForId(c.Index)
+			match.captures[name] = append(match.captures[name], c.Node)
+		}
+		if q.satisfies(match, content) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// satisfies reports whether a match passes the predicates of its pattern.
+// A predicate on a capture holding several nodes must hold for each.
+func (q *query) satisfies(m queryMatch, content []byte) bool {
+	for _, pred := range q.predicates[m.pattern] {
+		negated := strings.HasPrefix(pred.op, "not-")
+		for _, n := range m.captures[pred.capture] {
+			if pred.test(n, m, content) == negated {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (pred predicate) test(n *sitter.Node, m queryMatch, content []byte) bool {
+	text := nodeText(n, content)
+	switch strings.TrimPrefix(pred.op, "not-") {
+	case "eq?":
+		if pred.other != "" {
+			return text == nodeText(m.node(pred.other), content)
+		}
+		return text == pred.args[0]
+	case "match?":
+		return pred.re.MatchString(text)
+	case "any-of?":
+		return containsString(pred.args, text)
+	case "has-parent?":
+		return n.Parent() != nil && containsString(pred.args, n.Parent().Type())
+	case "kind-eq?":
+		return containsString(pred.args, n.Type())
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeKey identifies a syntax node within one tree
+type nodeKey struct {
+	start, end uint32
+	symbol     sitter.Symbol
+}
+
+func keyOf(n *sitter.Node) nodeKey {
+	return nodeKey{start: n.StartByte(), end: n.EndByte(), symbol: n.Symbol()}
+}
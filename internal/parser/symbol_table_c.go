@@ -20,6 +20,9 @@ type CSymbolTable struct {
 
 	// currentFile tracks the file being processed
 	currentFile string
+
+	// parser resolves includes through its compile databases; optional
+	parser *Parser
 }
 
 // NewCSymbolTable creates a new C symbol table
@@ -116,6 +119,9 @@ func (t *CSymbolTable) walkAndRegister(node *sitter.Node, filePath string, conte
 	case "preproc_include":
 		t.handleInclude(node, content)
 
+	case "preproc_function_def":
+		t.handleMacro(node, filePath, content)
+
 	case "function_definition":
 		t.handleFunctionDefinition(node, filePath, content)
 
@@ -137,22 +143,30 @@ func (t *CSymbolTable) walkAndRegister(node *sitter.Node, filePath string, conte
 }
 
 func (t *CSymbolTable) handleInclude(node *sitter.Node, content []byte) {
-	// #include "file.h" or #include <file.h>
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		switch child.Type() {
-		case "string_literal":
-			// "file.h"
-			path := strings.Trim(string(content[child.StartByte():child.EndByte()]), "\"")
-			name := filepath.Base(path)
-			t.RegisterImport(name, path)
-
-		case "system_lib_string":
-			// <file.h>
-			path := strings.Trim(string(content[child.StartByte():child.EndByte()]), "<>")
-			name := filepath.Base(path)
-			t.RegisterImport(name, path)
-		}
+	// #include "file.h" or #include <file.h>, resolved to the file it names
+	// when it is found
+	path := node.ChildByFieldName("path")
+	if path == nil {
+		return
+	}
+	spec := string(content[path.StartByte():path.EndByte()])
+	switch path.Type() {
+	case "string_literal":
+		spec = strings.Trim(spec, "\"")
+	case "system_lib_string":
+	default:
+		return
+	}
+	target := resolveCInclude(t.parser, t.currentFile, "", importRef{spec: spec})
+	t.RegisterImport(filepath.Base(strings.Trim(spec, "<>")), target)
+}
+
+func (t *CSymbolTable) handleMacro(node *sitter.Node, filePath string, content []byte) {
+	// #define NAME(args) ...
+	nameNode := node.ChildByFieldName("name")
+	if nameNode != nil {
+		name := string(content[nameNode.StartByte():nameNode.EndByte()])
+		t.Register(name, fmt.Sprintf("%s::%s", filePath, name), NodeTypeMacro)
 	}
 }
 
@@ -316,17 +330,6 @@ func (e *CEdgeExtractor) ExtractEdges(node *sitter.Node, callerID string, filePa
 				})
 			}
 		}
-
-	case "preproc_include":
-		// Record include as an import edge
-		header := e.extractIncludePath(node, content)
-		if header != "" {
-			result.Edges = append(result.Edges, CodeEdge{
-				FromID:   filePath,
-				ToID:     fmt.Sprintf("header::%s", header),
-				EdgeType: EdgeTypeImports,
-			})
-		}
 	}
 
 	// Recurse
@@ -370,19 +373,6 @@ func (e *CEdgeExtractor) extractCCallee(node *sitter.Node, content []byte) strin
 	return ""
 }
 
-func (e *CEdgeExtractor) extractIncludePath(node *sitter.Node, content []byte) string {
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		switch child.Type() {
-		case "string_literal":
-			return strings.Trim(string(content[child.StartByte():child.EndByte()]), "\"")
-		case "system_lib_string":
-			return strings.Trim(string(content[child.StartByte():child.EndByte()]), "<>")
-		}
-	}
-	return ""
-}
-
 func (e *CEdgeExtractor) resolveCCallee(callee string, filePath string) string {
 	if e.symbolTable != nil {
 		ctx := &ResolutionContext{FilePath: filePath}
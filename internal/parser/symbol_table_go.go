@@ -152,7 +152,7 @@ func (t *GoSymbolTable) walkAndRegister(node *sitter.Node, filePath string, cont
 			// Include receiver type in ID
 			receiver := ""
 			if recvNode := node.ChildByFieldName("receiver"); recvNode != nil {
-				receiver = t.extractReceiverType(recvNode, content)
+				receiver = goReceiverType(recvNode, content)
 			}
 			fullName := name
 			if receiver != "" {
@@ -236,7 +236,9 @@ func (t *GoSymbolTable) extractGoImportSpec(node *sitter.Node, content []byte) {
 	}
 }
 
-func (t *GoSymbolTable) extractReceiverType(node *sitter.Node, content []byte) string {
+// goReceiverType returns the type name of a method receiver, without any
+// pointer or type parameters
+func goReceiverType(node *sitter.Node, content []byte) string {
 	// Walk to find the type name in receiver
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
@@ -244,13 +246,13 @@ func (t *GoSymbolTable) extractReceiverType(node *sitter.Node, content []byte) s
 		case "parameter_declaration":
 			// Look for type
 			if typeNode := child.ChildByFieldName("type"); typeNode != nil {
-				return t.extractTypeName(typeNode, content)
+				return goTypeName(typeNode, content)
 			}
 			// Fallback: look for identifier
 			for j := 0; j < int(child.ChildCount()); j++ {
 				subChild := child.Child(j)
 				if subChild.Type() == "type_identifier" || subChild.Type() == "pointer_type" {
-					return t.extractTypeName(subChild, content)
+					return goTypeName(subChild, content)
 				}
 			}
 		}
@@ -258,7 +260,7 @@ func (t *GoSymbolTable) extractReceiverType(node *sitter.Node, content []byte) s
 	return ""
 }
 
-func (t *GoSymbolTable) extractTypeName(node *sitter.Node, content []byte) string {
+func goTypeName(node *sitter.Node, content []byte) string {
 	switch node.Type() {
 	case "type_identifier", "identifier":
 		return string(content[node.StartByte():node.EndByte()])
@@ -273,7 +275,7 @@ func (t *GoSymbolTable) extractTypeName(node *sitter.Node, content []byte) strin
 	case "generic_type":
 		// Get base type name
 		if nameNode := node.ChildByFieldName("type"); nameNode != nil {
-			return t.extractTypeName(nameNode, content)
+			return goTypeName(nameNode, content)
 		}
 	}
 
@@ -0,0 +1,16 @@
+#!/bin/bash
+source ./lib.sh
+. "$DIR/env.sh"
+
+# Greets someone
+greet() {
+  echo "hi $1"
+  log_msg "greeted"
+}
+
+function log_msg {
+  printf '%s\n' "$1"
+  "$HANDLER" "$1"
+}
+
+greet world
@@ -0,0 +1,36 @@
+#include <stdlib.h>
+#include "list.h"
+
+/* A singly linked node */
+struct node {
+    int value;
+    struct node *next;
+};
+
+enum color { RED, GREEN };
+
+typedef struct node node_t;
+
+// Allocates a node
+static struct node *node_new(int value) {
+    struct node *n = malloc(sizeof(struct node));
+    n->value = value;
+    return n;
+}
+
+int list_sum(struct node *head) {
+    int total = 0;
+    for (struct node *n = head; n; n = n->next) {
+        total += n->value;
+    }
+    log_value(total);
+    return total;
+}
+
+void list_free(struct node *head) {
+    while (head) {
+        struct node *next = head->next;
+        free(head);
+        head = next;
+    }
+}
@@ -0,0 +1,26 @@
+#include <vector>
+#include "shapes.h"
+
+namespace geo {
+
+// A point
+struct Point {
+    double x, y;
+};
+
+class Shape {
+public:
+    virtual double area() const = 0;
+    void describe() { print(area()); }
+};
+
+double distance(const Point &a, const Point &b) {
+    return std::sqrt(square(a.x - b.x) + square(a.y - b.y));
+}
+
+}  // namespace geo
+
+int main() {
+    geo::Point p{1, 2};
+    return geo::distance(p, p) > 0;
+}
@@ -0,0 +1,26 @@
+using System;
+using App.Models;
+
+namespace App.Services
+{
+    /// <summary>Users</summary>
+    public class UserService : IUserService
+    {
+        public UserService(IRepo repo) { }
+        public User Find(int id) { return Lookup(id); }
+        private User Lookup(int id) { Console.WriteLine(id); return null; }
+        public string Name { get; set; }
+    }
+    public interface IUserService { User Find(int id); }
+    public struct Point { }
+    public enum Color { Red }
+    public record Person(string Name);
+}
+namespace App.Util
+{
+    static class Strings
+    {
+        public static T Parse<T>(string s) { return Convert<T>(s); }
+        delegate void Handler(int x);
+    }
+}
@@ -0,0 +1,25 @@
+defmodule Shop.Cart do
+  @moduledoc "Shopping carts"
+  alias Shop.Item
+  alias Shop.{Order, Line}
+  import Enum
+
+  @doc "Total"
+  def total(cart), do: sum(cart.items)
+
+  defp sum(items) when is_list(items) do
+    Enum.map(items, &Item.price/1)
+  end
+
+  def empty, do: %{}
+
+  defmacro debug(x), do: x
+end
+
+defprotocol Shop.Priced do
+  def price(x)
+end
+
+defimpl Shop.Priced, for: Shop.Item do
+  def price(item), do: item.amount
+end
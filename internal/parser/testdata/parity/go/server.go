@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	str "strings"
+)
+
+// Server serves requests
+type Server struct {
+	addr string
+}
+
+// Handler handles one request
+type Handler interface {
+	Serve(w http.ResponseWriter, r *http.Request)
+}
+
+type ID string
+
+// @semantic
+// summary: Starts the server
+// side_effects: opens a socket
+func (s *Server) Start() error {
+	fmt.Println("starting", s.addr)
+	return s.listen(str.TrimSpace(s.addr))
+}
+
+func (s Server) listen(addr string) error {
+	handler := func(w http.ResponseWriter) {
+		write(w, "ok")
+	}
+	_ = handler
+	return http.ListenAndServe(addr, nil)
+}
+
+// New creates a server
+func New(addr string) *Server {
+	return &Server{addr: addr}
+}
+
+func write(w http.ResponseWriter, msg string) {
+	w.Write([]byte(msg))
+}
@@ -0,0 +1,36 @@
+package com.acme;
+
+import java.util.List;
+import com.acme.util.*;
+import static com.acme.util.Strings.trim;
+
+/** The application */
+public class App {
+    private final List<String> names;
+
+    public App(List<String> names) {
+        this.names = names;
+    }
+
+    /** Runs the app */
+    public void run() {
+        for (String n : names) {
+            System.out.println(trim(n));
+        }
+        helper(1);
+        helper(1, 2);
+    }
+
+    private int helper(int a) { return a; }
+    private int helper(int a, int b) { return a + b; }
+
+    interface Listener {
+        void onEvent(String e);
+    }
+
+    enum Mode { FAST, SLOW }
+
+    record Pair(int a, int b) {
+        int sum() { return a + b; }
+    }
+}
@@ -0,0 +1,31 @@
+import { db } from "./db.js";
+const fs = require("fs");
+
+/** Handles requests */
+export const handler = async (req) => {
+    const inner = () => req;
+    return inner();
+};
+const named = function impl(a, b) {};
+const Widget = class {};
+
+// A store
+class Store {
+    load = async (id) => db.get(id);
+    static limit = 10;
+    save(item) {
+        return this.load(item.id);
+    }
+}
+const api = {
+    get() { return fetch("/api"); },
+    post: async (body) => body,
+    retries: 3,
+};
+function* ids() {
+    yield 1;
+}
+export default function main() {
+    api.get();
+    handler({});
+}
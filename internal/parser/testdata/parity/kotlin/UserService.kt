@@ -0,0 +1,17 @@
+package com.acme.app
+
+import com.acme.util.Strings
+import com.acme.model.*
+
+/** A user service */
+class UserService(private val repo: Repo) : Service {
+    fun find(id: Int): User = lookup(id)
+    private fun lookup(id: Int): User { return repo.get(id) }
+    companion object { fun create() = UserService(Repo()) }
+}
+interface Service
+object Registry { fun register() {} }
+data class User(val id: Int)
+enum class Color { RED }
+typealias Users = List<User>
+fun main() { println(UserService.create().find(1)) }
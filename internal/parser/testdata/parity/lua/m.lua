@@ -0,0 +1,12 @@
+local json = require("json")
+local util = require "lib.util"
+local M = {}
+
+-- Adds numbers
+local function add(a, b) return a + b end
+function M.greet(name) print(name) end
+function Account:deposit(v) self.balance = add(self.balance, v) end
+local helper = function() return M.greet("x") end
+function Account:report() self:deposit(0) end
+function M.nested.deep() end
+return M
@@ -0,0 +1,17 @@
+<?php
+namespace App\Models;
+
+use App\Support\Str;
+use App\Support\{Arr, Collection as C};
+require_once __DIR__ . '/boot.php';
+
+/** A user */
+class User extends Model implements JsonSerializable {
+    public function name(): string { return Str::upper($this->name); }
+    public static function find($id) { return self::query($id); }
+    public function query($id) { return $this->db?->select($id); }
+}
+interface Named { public function name(): string; }
+trait Greets { function greet() { echo helper(); } }
+function helper() { return strlen("x") + $fn("y"); }
+enum Suit { case Hearts; }
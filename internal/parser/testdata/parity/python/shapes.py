@@ -0,0 +1,29 @@
+import os.path
+from collections import OrderedDict as OD
+from . import helpers
+
+
+class Shape:
+    """A shape."""
+
+    def area(self):
+        """Returns the area."""
+        return self._compute()
+
+    def _compute(self):
+        return helpers.measure(self)
+
+
+class Circle(Shape):
+    def __init__(self, r):
+        self.r = r
+
+    def area(self):
+        def square(x):
+            return x * x
+        return 3.14 * square(self.r)
+
+
+# Builds a default shape
+def make(kind, *args):
+    return Circle(*args) if kind == "circle" else Shape()
@@ -0,0 +1,32 @@
+require 'json'
+require_relative 'util'
+
+module Shop
+  # A cart
+  class Cart < Base
+    include Enumerable
+    def initialize(items)
+      @items = items
+    end
+
+    def total
+      @items.sum { |i| price(i) }
+    end
+
+    def self.build
+      new([])
+    end
+  end
+end
+
+def helper(x)
+  puts x
+  Shop::Cart.build
+end
+
+class Shop::Order
+  def submit(cart, *opts)
+    cart.total
+    notify(self)
+  end
+end
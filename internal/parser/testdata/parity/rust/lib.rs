@@ -0,0 +1,52 @@
+use std::collections::HashMap;
+use std::fmt::{self, Display};
+use crate::db::Pool;
+
+mod config;
+
+/// A point
+pub struct Point {
+    x: i32,
+    y: i32,
+}
+
+pub enum Shape {
+    Circle(f64),
+}
+
+/// Things with an area
+pub trait Area {
+    fn area(&self) -> f64;
+}
+
+impl Point {
+    pub fn new(x: i32, y: i32) -> Self {
+        Point { x, y }
+    }
+
+    fn norm(&self) -> f64 {
+        ((self.x * self.x + self.y * self.y) as f64).sqrt()
+    }
+}
+
+impl Display for Point {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "({}, {})", self.x, self.y)
+    }
+}
+
+mod geometry {
+    pub fn origin() -> super::Point {
+        super::Point::new(0, 0)
+    }
+}
+
+pub fn run() {
+    let p = Point::new(1, 2);
+    println!("{}", p.norm());
+    helper(&p);
+}
+
+fn helper(p: &Point) -> HashMap<i32, i32> {
+    HashMap::new()
+}
@@ -0,0 +1,16 @@
+package com.acme
+
+import scala.collection.mutable
+import com.acme.util._
+
+/** A service */
+class UserService(repo: Repo) extends Service {
+  def find(id: Int): User = lookup(id)
+  private def lookup(id: Int): User = repo.get(id)
+}
+trait Service { def name: String }
+object Main { def main(args: Array[String]): Unit = println(parse[Int]("1")) }
+case class User(id: Int)
+enum Color { case Red }
+type Users = List[User]
+def parse[T](s: String): T = ???
@@ -0,0 +1,15 @@
+import Foundation
+
+/// A shape
+protocol Shape { func area() -> Double }
+class Circle: Shape {
+    init(r: Double) { self.r = r }
+    func area() -> Double { return compute(r) }
+    func scale(by f: Double, _ g: Double) -> Double { let a = area(); return a * f }
+}
+struct Point { func dist() -> Double { return sqrt(2) } }
+enum Color { case red }
+actor Cache { func get() {} }
+extension Circle { func describe() { print(self.area()) } }
+typealias Radius = Double
+func compute(_ x: Double) -> Double { x * x }
@@ -0,0 +1,17 @@
+import React from "react";
+
+type Props = { label: string; onClick: () => void };
+
+/** A button */
+export const Button = (props: Props) => <button onClick={props.onClick}>{props.label}</button>;
+
+export function App() {
+    const click = () => console.log("clicked");
+    return <Button label="go" onClick={click} />;
+}
+
+class Counter extends React.Component {
+    render() {
+        return <div>{format(this.state)}</div>;
+    }
+}
@@ -0,0 +1,29 @@
+import { helper } from "./util";
+
+export interface Props { label: string }
+type ID = string;
+enum Color { Red, Green }
+
+/** Base shape */
+abstract class Shape {
+    abstract area(): number;
+    scale = (factor: number) => factor * this.area();
+    describe(): string {
+        return helper(this.area());
+    }
+}
+
+namespace Geometry {
+    export const origin = () => 0;
+    export function dist(a: number, b: number): number {
+        return Math.abs(a - b);
+    }
+}
+
+declare module "ext" {
+    export function ext(): void;
+}
+
+export function area(s: Shape): number {
+    return s.area();
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// A parser with a tree cache keeps the syntax tree of each file it parses,
+// with the source it was parsed from. When the file is parsed again, the
+// bytes between the common prefix and suffix of the old and new source are
+// the edit: the old tree is edited with it and handed to tree-sitter, which
+// reuses the subtrees outside the edit instead of reading the whole file.
+// The result is the same as a parse from scratch, so a cached tree is never
+// wrong, only more or less useful. Trees are kept for the most recently
+// parsed files.
+
+// DefaultTreeCacheSize is the number of files whose trees a watcher keeps
+const DefaultTreeCacheSize = 128
+
+// TreeCache holds the last syntax trees of recently parsed files. It is
+// safe for concurrent use.
+type TreeCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+}
+
+type cachedTree struct {
+	path   string
+	lang   Language
+	tree   *sitter.Tree
+	source []byte
+}
+
+// NewTreeCache returns a cache for the trees of max files, or nil when max
+// is not positive; a nil cache never hits
+func NewTreeCache(max int) *TreeCache {
+	if max <= 0 {
+		return nil
+	}
+	return &TreeCache{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// WithTreeCache reparses files incrementally from the trees kept in cache
+func WithTreeCache(cache *TreeCache) ParserOption {
+	return func(p *Parser) {
+		p.trees = cache
+	}
+}
+
+// take removes and returns the tree cached for a file in lang, or nil.
+// Trees are not safe for concurrent use, so the caller owns it.
+func (c *TreeCache) take(path string, lang Language) *cachedTree {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[path]
+	if !ok {
+		return nil
+	}
+	c.order.Remove(el)
+	delete(c.entries, path)
+	entry := el.Value.(*cachedTree)
+	if entry.lang != lang {
+		entry.tree.Close()
+		return nil
+	}
+	return entry
+}
+
+// put caches the tree of a file, closing the trees it replaces or evicts
+func (c *TreeCache) put(path string, lang Language, tree *sitter.Tree, source []byte) {
+	if c == nil {
+		tree.Close()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		el.Value.(*cachedTree).tree.Close()
+		c.order.Remove(el)
+	}
+	c.entries[path] = c.order.PushFront(&cachedTree{path: path, lang: lang, tree: tree, source: source})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		entry := oldest.Value.(*cachedTree)
+		entry.tree.Close()
+		c.order.Remove(oldest)
+		delete(c.entries, entry.path)
+	}
+}
+
+// Forget drops the trees of a file, or of the files under a directory, and
+// of the code blocks embedded in them, as when they are deleted or moved
+func (c *TreeCache) Forget(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, el := range c.entries {
+		if key == path || strings.HasPrefix(key, path+"#") || strings.HasPrefix(key, path+string(filepath.Separator)) {
+			el.Value.(*cachedTree).tree.Close()
+			c.order.Remove(el)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// parseTree parses the source of a file, incrementally from its cached tree
+// when the parser has one
+func (p *Parser) parseTree(ctx context.Context, spec *LanguageSpec, filePath string, source []byte) (*sitter.Tree, error) {
+	parser := sitter.NewParser()
+	parser.SetLanguage(spec.language)
+	defer parser.Close()
+
+	var old *sitter.Tree
+	if cached := p.trees.take(filePath, spec.Name); cached != nil {
+		old = cached.tree
+		defer old.Close()
+		old.Edit(editBetween(cached.source, source))
+	}
+	tree, err := parser.ParseCtx(ctx, old, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	if p.trees != nil {
+		p.trees.put(filePath, spec.Name, tree.Copy(), source)
+	}
+	return tree, nil
+}
+
+// editBetween returns the edit turning old into new: the bytes between
+// their common prefix and suffix
+func editBetween(old, new []byte) sitter.EditInput {
+	start := 0
+	for start < len(old) && start < len(new) && old[start] == new[start] {
+		start++
+	}
+	oldEnd, newEnd := len(old), len(new)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == new[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	return sitter.EditInput{
+		StartIndex:  uint32(start),
+		OldEndIndex: uint32(oldEnd),
+		NewEndIndex: uint32(newEnd),
+		StartPoint:  pointAt(old, start),
+		OldEndPoint: pointAt(old, oldEnd),
+		NewEndPoint: pointAt(new, newEnd),
+	}
+}
+
+// pointAt returns the row and byte column of an offset of source
+func pointAt(source []byte, offset int) sitter.Point {
+	before := source[:offset]
+	return sitter.Point{
+		Row:    uint32(bytes.Count(before, []byte("\n"))),
+		Column: uint32(offset - (bytes.LastIndexByte(before, '\n') + 1)),
+	}
+}
+
+// Forget drops the cached trees of a file or directory, as when it is
+// deleted or moved
+func (p *Parser) Forget(filePath string) {
+	p.trees.Forget(filePath)
+}
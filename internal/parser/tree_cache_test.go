@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalReparse(t *testing.T) {
+	cache := NewTreeCache(2)
+	incremental := NewParser(WithTreeCache(cache))
+	scratch := NewParser()
+	ctx := context.Background()
+
+	versions := []string{
+		"package shapes\n\nfunc Area(r float64) float64 { return 3 * r * r }\n\nfunc Perimeter(r float64) float64 { return 6 * r }\n",
+		// Edit in the middle of a body
+		"package shapes\n\nfunc Area(r float64) float64 { return 3.14 * r * r }\n\nfunc Perimeter(r float64) float64 { return 6 * r }\n",
+		// Insert a function at the start, shifting the rest
+		"package shapes\n\n// Scale multiplies\nfunc Scale(x float64) float64 { return Area(x) }\n\nfunc Area(r float64) float64 { return 3.14 * r * r }\n\nfunc Perimeter(r float64) float64 { return 6 * r }\n",
+		// Break it, then fix it with a change at each end
+		"package shapes\n\n// Scale multiplies\nfunc Scale(x float64) float64 { return Area(x) \n\nfunc Area(r float64) float64 { return 3.14 * r * r }\n",
+		"package geometry\n\nfunc Area(r float64) float64 { return 3.14 * r * r }\n\nfunc Diameter(r float64) float64 { return 2 * r }\n",
+		"",
+		"package geometry\n",
+	}
+	for i, v := range versions {
+		got, err := incremental.ParseContent(ctx, "shapes.go", LangGo, []byte(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := scratch.ParseContent(ctx, "shapes.go", LangGo, []byte(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: incremental parse differs from a parse from scratch:\n%+v\n%+v", i, got, want)
+		}
+	}
+
+	// Markdown blocks are cached under their virtual paths
+	if _, err := incremental.ParseContent(ctx, "notes.md", LangMarkdown, []byte("```go\npackage notes\n```\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.entries["notes.md#block-1"]; !ok {
+		t.Errorf("expected the block's tree cached, got %v", keys(cache))
+	}
+	if _, ok := cache.entries["shapes.go"]; !ok || cache.order.Len() != 2 {
+		t.Errorf("expected the two most recent trees, got %v", keys(cache))
+	}
+	incremental.Forget("notes.md")
+	if got := keys(cache); got != "shapes.go" {
+		t.Errorf("expected only shapes.go left, got %v", got)
+	}
+}
+
+func TestEditBetween(t *testing.T) {
+	for _, tc := range []struct {
+		old, new string
+		want     string
+	}{
+		{"abc\ndef\n", "abc\ndXf\n", "5-6-6 (1,1)-(1,2)-(1,2)"},
+		{"abc\n", "abc\nx\n", "4-4-6 (1,0)-(1,0)-(2,0)"},
+		{"abc", "abc", "3-3-3 (0,3)-(0,3)-(0,3)"},
+		{"aaa", "aa", "2-3-2 (0,2)-(0,3)-(0,2)"},
+	} {
+		e := editBetween([]byte(tc.old), []byte(tc.new))
+		got := fmt.Sprintf("%d-%d-%d (%d,%d)-(%d,%d)-(%d,%d)", e.StartIndex, e.OldEndIndex, e.NewEndIndex,
+			e.StartPoint.Row, e.StartPoint.Column, e.OldEndPoint.Row, e.OldEndPoint.Column, e.NewEndPoint.Row, e.NewEndPoint.Column)
+		if got != tc.want {
+			t.Errorf("editBetween(%q, %q) = %s, want %s", tc.old, tc.new, got, tc.want)
+		}
+	}
+}
+
+func keys(c *TreeCache) string {
+	var out []string
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		out = append(out, el.Value.(*cachedTree).path)
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,229 @@
+// Package prompts holds the versioned prompt templates used by the agentic
+// tools. Built-in templates are embedded; a project can override any of them
+// with a file of the same name in its prompts directory.
+package prompts
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/logging"
+)
+
+// Template names
+const (
+	Context      = "context"
+	Impact       = "impact"
+	Architecture = "architecture"
+	Quality      = "quality"
+)
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// builtin describes the embedded templates. Bump Version whenever a
+// template's text changes.
+var builtin = []Template{
+	{Name: Context, Version: 1, Description: "Answer a question about the codebase from the most relevant code"},
+	{Name: Impact, Version: 1, Description: "Analyse what a code change would affect, using code and dependency information"},
+	{Name: Architecture, Version: 1, Description: "Describe the architecture from the codebase structure and relevant code"},
+	{Name: Quality, Version: 1, Description: "Find code quality issues from relevant code and codebase metrics"},
+}
+
+// Data is what templates can refer to. Fields a tool does not gather are
+// empty.
+type Data struct {
+	Query             string
+	Focus             string
+	CodeContext       string // the most relevant code for the query
+	DependencyContext string // dependencies and callers of the matching code
+	StructureContext  string // node counts by type and language
+	MetricsContext    string // size and complexity metrics
+}
+
+// Template is one prompt template
+type Template struct {
+	Name        string
+	Description string
+	Version     int    // built-in version; overrides report the version they replace
+	Path        string // override file, empty for a built-in template
+	Source      string
+
+	tmpl *template.Template
+}
+
+// ID identifies the template text, such as "impact@v1" or
+// "impact@override-1a2b3c4d"; it changes whenever the text does
+func (t Template) ID() string {
+	if t.Path == "" {
+		return fmt.Sprintf("%s@v%d", t.Name, t.Version)
+	}
+	sum := sha256.Sum256([]byte(t.Source))
+	return t.Name + "@override-" + hex.EncodeToString(sum[:4])
+}
+
+// Execute renders the template
+func (t Template) Execute(data Data) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", t.ID(), err)
+	}
+	return sb.String(), nil
+}
+
+// Store resolves templates, preferring <dir>/<name>.tmpl over the built-in
+// one. Override files are re-read when they change. A relative dir is
+// resolved against the project root set with SetRoot, or the working
+// directory until one is set.
+type Store struct {
+	dir string
+
+	mu        sync.Mutex
+	root      string
+	overrides map[string]override
+}
+
+type override struct {
+	modTime  time.Time
+	template *Template // nil when the file does not parse
+}
+
+// NewStore returns a store that looks for overrides in dir; an empty dir
+// uses only the built-in templates
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, overrides: make(map[string]override)}
+}
+
+// SetRoot resolves a relative prompts directory against the project at root
+// from now on. It returns the resolved directory and whether it changed.
+func (s *Store) SetRoot(root string) (string, bool) {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	s.mu.Lock()
+	before := s.resolve()
+	s.root = root
+	after := s.resolve()
+	s.mu.Unlock()
+	return after, after != before
+}
+
+// Dir returns the directory overrides are read from, empty when there is
+// none
+func (s *Store) Dir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resolve()
+}
+
+// resolve returns the overrides directory; s.mu must be held
+func (s *Store) resolve() string {
+	if s.dir == "" || filepath.IsAbs(s.dir) {
+		return s.dir
+	}
+	if s.root != "" {
+		return filepath.Join(s.root, s.dir)
+	}
+	if abs, err := filepath.Abs(s.dir); err == nil {
+		return abs
+	}
+	return s.dir
+}
+
+// List returns every template, with overrides applied, in a stable order
+func (s *Store) List() []Template {
+	out := make([]Template, 0, len(builtin))
+	for _, b := range builtin {
+		t, err := s.Get(b.Name)
+		if err == nil {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Get returns the named template. An override that fails to parse is
+// logged and the built-in template is used instead.
+func (s *Store) Get(name string) (Template, error) {
+	var base *Template
+	for i := range builtin {
+		if builtin[i].Name == name {
+			base = &builtin[i]
+		}
+	}
+	if base == nil {
+		return Template{}, fmt.Errorf("unknown prompt template: %s", name)
+	}
+
+	if t := s.override(*base); t != nil {
+		return *t, nil
+	}
+
+	data, err := builtinFS.ReadFile("templates/" + name + ".tmpl")
+	if err != nil {
+		return Template{}, err
+	}
+	t := *base
+	if err := t.parse(string(data)); err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// override returns the project's version of base, or nil when there is none
+// or it is invalid
+func (s *Store) override(base Template) *Template {
+	dir := s.Dir()
+	if dir == "" {
+		return nil
+	}
+	path := filepath.Join(dir, base.Name+".tmpl")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.overrides[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.template
+	}
+
+	var result *Template
+	data, err := os.ReadFile(path)
+	if err == nil {
+		t := base
+		t.Path = path
+		err = t.parse(string(data))
+		if err == nil {
+			result = &t
+		}
+	}
+	if err != nil {
+		logging.Component(nil, "prompts").Warn("ignoring invalid prompt override", "path", path, "error", err)
+	}
+	s.overrides[path] = override{modTime: info.ModTime(), template: result}
+	return result
+}
+
+func (t *Template) parse(source string) error {
+	// Editors end files with a newline that is not part of the prompt
+	t.Source = strings.TrimSuffix(source, "\n")
+	tmpl, err := template.New(t.Name).Parse(t.Source)
+	if err != nil {
+		return fmt.Errorf("failed to parse prompt %s: %w", t.Name, err)
+	}
+	t.tmpl = tmpl
+	// Catch references to fields Data does not have before the template is
+	// used by a tool
+	_, err = t.Execute(Data{})
+	return err
+}
@@ -0,0 +1,126 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuiltinTemplatesRender(t *testing.T) {
+	store := NewStore("")
+	list := store.List()
+	if len(list) != 4 {
+		t.Fatalf("expected 4 templates, got %d", len(list))
+	}
+
+	data := Data{
+		Query:             "QUERY",
+		CodeContext:       "CODE",
+		DependencyContext: "DEPS",
+		StructureContext:  "STRUCTURE",
+		MetricsContext:    "METRICS",
+	}
+	want := map[string][]string{
+		Context:      {"QUERY", "CODE"},
+		Impact:       {"QUERY", "CODE", "DEPS"},
+		Architecture: {"QUERY", "STRUCTURE", "CODE"},
+		Quality:      {"QUERY", "CODE", "METRICS"},
+	}
+	for _, tmpl := range list {
+		if tmpl.ID() != tmpl.Name+"@v1" || tmpl.Path != "" {
+			t.Errorf("unexpected built-in template identity %s %q", tmpl.ID(), tmpl.Path)
+		}
+		out, err := tmpl.Execute(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range want[tmpl.Name] {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s prompt is missing %s:\n%s", tmpl.Name, s, out)
+			}
+		}
+		if strings.HasSuffix(out, "\n") || !strings.Contains(out, `"confidence": "high/medium/low"`) {
+			t.Errorf("%s prompt does not end with its JSON format:\n%s", tmpl.Name, out)
+		}
+	}
+
+	if _, err := store.Get("missing"); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "impact.tmpl")
+	write := func(text string, mod time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := NewStore(dir)
+	now := time.Now()
+
+	write("Impact of {{.Query}} on {{.DependencyContext}}\n", now)
+	tmpl, err := store.Get(Impact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := tmpl.Execute(Data{Query: "rename", DependencyContext: "callers"})
+	if tmpl.Path != path || !strings.HasPrefix(tmpl.ID(), "impact@override-") || out != "Impact of rename on callers" {
+		t.Errorf("unexpected override %s: %q", tmpl.ID(), out)
+	}
+	first := tmpl.ID()
+
+	write("Changed {{.Query}}", now.Add(time.Second))
+	tmpl, _ = store.Get(Impact)
+	if out, _ := tmpl.Execute(Data{Query: "q"}); out != "Changed q" || tmpl.ID() == first {
+		t.Errorf("expected the edited override to be reloaded, got %s %q", tmpl.ID(), out)
+	}
+
+	// A template referring to a field Data lacks falls back to the built-in
+	write("{{.Unknown}}", now.Add(2*time.Second))
+	if tmpl, _ := store.Get(Impact); tmpl.Path != "" || tmpl.ID() != "impact@v1" {
+		t.Errorf("expected fallback to the built-in template, got %s", tmpl.ID())
+	}
+
+	if tmpl, _ := store.Get(Quality); tmpl.Path != "" {
+		t.Errorf("expected templates without an override file to be built-in, got %s", tmpl.Path)
+	}
+}
+
+func TestRelativeDirResolvesAgainstRoot(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".codeloom", "prompts")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "context.tmpl"), []byte("Project: {{.Query}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(filepath.Join(".codeloom", "prompts"))
+	if tmpl, _ := store.Get(Context); tmpl.Path != "" {
+		t.Fatalf("expected no override before the root is known, got %s", tmpl.Path)
+	}
+
+	resolved, changed := store.SetRoot(root)
+	if resolved != dir || !changed {
+		t.Fatalf("expected %s to be resolved under the root, got %s (changed %v)", dir, resolved, changed)
+	}
+	if tmpl, _ := store.Get(Context); tmpl.Path != filepath.Join(dir, "context.tmpl") {
+		t.Errorf("expected the project's override, got %q", tmpl.Path)
+	}
+	if _, changed := store.SetRoot(root); changed {
+		t.Error("expected setting the same root again to leave the directory unchanged")
+	}
+
+	if abs, _ := NewStore(dir).SetRoot(t.TempDir()); abs != dir {
+		t.Errorf("expected an absolute directory to ignore the root, got %s", abs)
+	}
+}
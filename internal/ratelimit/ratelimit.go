@@ -0,0 +1,281 @@
+// Package ratelimit paces requests to model provider APIs and retries the
+// ones that fail transiently, honouring the server's Retry-After.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+)
+
+// Limiter is a token bucket allowing a steady rate of requests with short
+// bursts. A nil Limiter never waits.
+type Limiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens per second
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+// NewLimiter allows perMinute requests a minute, in bursts of up to a
+// tenth of that. It returns nil, meaning no limit, when perMinute <= 0.
+func NewLimiter(perMinute int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	burst := max(1, float64(perMinute)/10)
+	return &Limiter{
+		rate:   float64(perMinute) / 60,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	for {
+		l.mu.Lock()
+		now := l.now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+
+		var wait time.Duration
+		switch {
+		case now.Before(l.pausedUntil):
+			wait = l.pausedUntil.Sub(now)
+		case l.tokens >= 1:
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		default:
+			wait = time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pause holds back every request for d, after the server asked us to wait
+func (l *Limiter) Pause(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Policy controls retries. The delay before retry n is BaseDelay*2^n, capped
+// at MaxDelay, with jitter, unless the server sent Retry-After. A request is
+// not retried when Retry-After exceeds MaxDelay.
+type Policy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultPolicy returns a policy with maxRetries retries starting at half a
+// second and waiting at most a minute
+func DefaultPolicy(maxRetries int) Policy {
+	return Policy{MaxRetries: maxRetries, BaseDelay: 500 * time.Millisecond, MaxDelay: time.Minute}
+}
+
+func (p Policy) backoff(retry int) time.Duration {
+	d := p.BaseDelay << retry
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Jitter between half and the full delay so clients spread out
+	return d/2 + rand.N(d/2+1)
+}
+
+// Do calls fn, waiting on limiter before each attempt, and retries it while
+// it fails transiently. It returns the number of retries made.
+func Do(ctx context.Context, limiter *Limiter, policy Policy, fn func(ctx context.Context) error) (int, error) {
+	for retry := 0; ; retry++ {
+		if err := limiter.Wait(ctx); err != nil {
+			return retry, err
+		}
+
+		attemptCtx, h := withHint(ctx)
+		err := fn(attemptCtx)
+		if err == nil || ctx.Err() != nil {
+			return retry, err
+		}
+		transient, after := Classify(err)
+		if !transient || retry >= policy.MaxRetries {
+			return retry, err
+		}
+		if after == 0 {
+			after = time.Duration(h.after.Load())
+		}
+
+		delay := policy.backoff(retry)
+		if after > 0 {
+			if after > policy.MaxDelay {
+				return retry, err
+			}
+			delay = after
+			limiter.Pause(after)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return retry, err
+		case <-timer.C:
+		}
+	}
+}
+
+// StatusError is an error response from a provider's HTTP API
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Message    string
+}
+
+// NewStatusError describes a failed response with message, keeping its
+// status code and Retry-After for Classify
+func NewStatusError(resp *http.Response, message string) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: RetryAfter(resp.Header, time.Now()),
+		Message:    message,
+	}
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// Retryable reports whether a response status is worth retrying: timeouts,
+// rate limits and server errors other than 501 Not Implemented
+func Retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests ||
+		(status >= 500 && status != http.StatusNotImplemented)
+}
+
+// Classify reports whether err is transient, and how long the server asked
+// us to wait when it said. It knows the error types of the provider SDKs.
+func Classify(err error) (transient bool, retryAfter time.Duration) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return Retryable(statusErr.StatusCode), statusErr.RetryAfter
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		if anthropicErr.Response != nil {
+			retryAfter = RetryAfter(anthropicErr.Response.Header, time.Now())
+		}
+		return Retryable(anthropicErr.StatusCode), retryAfter
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return Retryable(googleErr.Code), RetryAfter(googleErr.Header, time.Now())
+	}
+	var openaiErr *openai.APIError
+	if errors.As(err, &openaiErr) {
+		return Retryable(openaiErr.HTTPStatusCode), 0
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return Retryable(requestErr.HTTPStatusCode), 0
+	}
+
+	return errors.Is(err, syscall.ECONNRESET), 0
+}
+
+// RetryAfter parses the Retry-After header, as seconds or an HTTP date, or
+// the millisecond retry-after-ms header some APIs send. It returns 0 when
+// neither is present.
+func RetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Doer is the request interface of net/http and the provider SDK clients
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type hintKey struct{}
+
+// hint carries a Retry-After seen by WithRetryAfter back to Do
+type hint struct {
+	after atomic.Int64
+}
+
+func withHint(ctx context.Context) (context.Context, *hint) {
+	h := &hint{}
+	return context.WithValue(ctx, hintKey{}, h), h
+}
+
+type retryAfterDoer struct {
+	client Doer
+}
+
+// WithRetryAfter wraps client so that Do sees the Retry-After of failed
+// responses, for client libraries whose errors drop the headers
+func WithRetryAfter(client Doer) Doer {
+	return &retryAfterDoer{client: client}
+}
+
+func (d *retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err == nil && Retryable(resp.StatusCode) {
+		if h, ok := req.Context().Value(hintKey{}).(*hint); ok {
+			h.after.Store(int64(RetryAfter(resp.Header, time.Now())))
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Retry-After": {"3"}}, 3 * time.Second},
+		{http.Header{"Retry-After": {now.Add(10 * time.Second).Format(http.TimeFormat)}}, 10 * time.Second},
+		{http.Header{"Retry-After": {"1"}, "Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{http.Header{"Retry-After": {"soon"}}, 0},
+		{http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		if got := RetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("RetryAfter(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// get issues a request to url and turns non-OK responses into StatusErrors
+func get(ctx context.Context, client Doer, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return NewStatusError(resp, fmt.Sprintf("stub error: %s", resp.Status))
+	}
+	return nil
+}
+
+func TestDoRetriesTransientFailures(t *testing.T) {
+	var calls int
+	var gaps []time.Duration
+	last := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		gaps = append(gaps, time.Since(last))
+		last = time.Now()
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After-Ms", "80")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	policy := Policy{MaxRetries: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}
+	retries, err := Do(context.Background(), nil, policy, func(ctx context.Context) error {
+		return get(ctx, server.Client(), server.URL)
+	})
+	if err != nil || retries != 2 || calls != 3 {
+		t.Fatalf("Do() = %d, %v after %d calls; want 2 retries and success", retries, err, calls)
+	}
+	if gaps[1] < 80*time.Millisecond {
+		t.Errorf("expected Retry-After of 80ms to be honoured, waited %v", gaps[1])
+	}
+}
+
+func TestDoStopsOnPermanentFailures(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/slow" {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	policy := Policy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	retries, err := Do(context.Background(), nil, policy, func(ctx context.Context) error {
+		return get(ctx, server.Client(), server.URL)
+	})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest || retries != 0 || calls != 1 {
+		t.Errorf("expected a 400 not to be retried, got %d retries, %v", retries, err)
+	}
+
+	// A Retry-After beyond MaxDelay fails now rather than blocking the caller
+	calls = 0
+	_, err = Do(context.Background(), nil, policy, func(ctx context.Context) error {
+		return get(ctx, server.Client(), server.URL+"/slow")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected long Retry-After to give up after one call, got %d calls, %v", calls, err)
+	}
+}
+
+func TestWithRetryAfterHint(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// A client library error without headers still picks up Retry-After
+	client := WithRetryAfter(server.Client())
+	policy := Policy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+	_, err := Do(context.Background(), nil, policy, func(ctx context.Context) error {
+		if err := get(ctx, client, server.URL); err != nil {
+			return &StatusError{StatusCode: http.StatusTooManyRequests, Message: err.Error()}
+		}
+		return nil
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected hinted Retry-After to stop retries, got %d calls, %v", calls, err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(600) // 10 a second, bursts of 60
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Errorf("expected the burst to pass without waiting, took %v", time.Since(start))
+	}
+	start = time.Now()
+	l.Wait(ctx)
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("expected to wait for a token after the burst, waited %v", waited)
+	}
+
+	l.Pause(time.Hour)
+	cancelled, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(cancelled); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected paused limiter to wait until the deadline, got %v", err)
+	}
+
+	if NewLimiter(0) != nil || (*Limiter)(nil).Wait(ctx) != nil {
+		t.Error("expected a zero rate to mean no limiter")
+	}
+}
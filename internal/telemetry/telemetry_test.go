@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupNoneExporter(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TelemetryConfig{Exporter: "none"})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), config.TelemetryConfig{Exporter: "zipkin"}); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
+
+func TestFileExporterWritesSpans(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(prev)
+
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := Setup(context.Background(), config.TelemetryConfig{
+		Exporter:    "file",
+		FilePath:    path,
+		ServiceName: "codeloom-test",
+	})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("boom"))
+	End(parent, nil)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+
+	type spanRecord struct {
+		Name        string
+		SpanContext struct{ TraceID string }
+		Parent      struct{ SpanID string }
+		Status      struct{ Code string }
+	}
+	spans := make(map[string]spanRecord)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec spanRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid span line %q: %v", line, err)
+		}
+		spans[rec.Name] = rec
+	}
+
+	parentRec, ok := spans["parent"]
+	if !ok {
+		t.Fatalf("parent span not exported: %s", data)
+	}
+	childRec, ok := spans["child"]
+	if !ok {
+		t.Fatalf("child span not exported: %s", data)
+	}
+	if childRec.SpanContext.TraceID != parentRec.SpanContext.TraceID {
+		t.Errorf("child trace %s != parent trace %s", childRec.SpanContext.TraceID, parentRec.SpanContext.TraceID)
+	}
+	if childRec.Status.Code != "Error" {
+		t.Errorf("expected child status Error, got %q", childRec.Status.Code)
+	}
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 
 	"github.com/heefoo/codeloom/internal/agent"
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/logging"
 )
 
+func logger() *slog.Logger {
+	return logging.Component(nil, "tools")
+}
+
 type GraphTools struct {
 	storage   graph.StorageInterface
 	embedding embedding.Provider
@@ -70,7 +75,7 @@ func (g *GraphTools) semanticSearchTool() agent.Tool {
 			}
 
 			// Generate embedding for query
-			queryEmbedding, err := g.embedding.EmbedSingle(ctx, query)
+			queryEmbedding, err := g.embedding.EmbedQuery(ctx, query)
 			if err != nil {
 				return "", fmt.Errorf("embedding error: %w", err)
 			}
@@ -89,7 +94,7 @@ func (g *GraphTools) semanticSearchTool() agent.Tool {
 
 			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
-				log.Printf("Warning: failed to marshal semantic search result: %v", err)
+				logger().Warn("failed to marshal semantic search result", "error", err)
 				return "", fmt.Errorf("failed to marshal result: %w", err)
 			}
 			return string(jsonBytes), nil
@@ -146,7 +151,7 @@ func (g *GraphTools) getTransitiveDependenciesTool() agent.Tool {
 
 			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
-				log.Printf("Warning: failed to marshal dependencies result: %v", err)
+				logger().Warn("failed to marshal dependencies result", "error", err)
 				return "", fmt.Errorf("failed to marshal result: %w", err)
 			}
 			return string(jsonBytes), nil
@@ -203,7 +208,7 @@ func (g *GraphTools) traceCallChainTool() agent.Tool {
 
 			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
-				log.Printf("Warning: failed to marshal trace result: %v", err)
+				logger().Warn("failed to marshal trace result", "error", err)
 				return "", fmt.Errorf("failed to marshal result: %w", err)
 			}
 			return string(jsonBytes), nil
@@ -247,7 +252,7 @@ func (g *GraphTools) findByNameTool() agent.Tool {
 
 			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
-				log.Printf("Warning: failed to marshal find result: %v", err)
+				logger().Warn("failed to marshal find result", "error", err)
 				return "", fmt.Errorf("failed to marshal result: %w", err)
 			}
 			return string(jsonBytes), nil
@@ -291,7 +296,7 @@ func (g *GraphTools) getNodesByFileTool() agent.Tool {
 
 			jsonBytes, err := json.MarshalIndent(result, "", "  ")
 			if err != nil {
-				log.Printf("Warning: failed to marshal file nodes result: %v", err)
+				logger().Warn("failed to marshal file nodes result", "error", err)
 				return "", fmt.Errorf("failed to marshal result: %w", err)
 			}
 			return string(jsonBytes), nil
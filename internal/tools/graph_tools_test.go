@@ -108,6 +108,10 @@ func (m *mockEmbeddingProvider) EmbedSingle(ctx context.Context, text string) ([
 	return result, nil
 }
 
+func (m *mockEmbeddingProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return m.EmbedSingle(ctx, text)
+}
+
 func (m *mockEmbeddingProvider) Dimension() int {
 	if m.dimension != 0 {
 		return m.dimension
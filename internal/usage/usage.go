@@ -0,0 +1,236 @@
+// Package usage records the tokens and estimated cost of LLM and embedding
+// requests, per day, tool, provider and model.
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of request
+const (
+	KindLLM       = "llm"
+	KindEmbedding = "embedding"
+)
+
+// Background is the tool name recorded for requests made outside a tool
+// call, such as indexing jobs and the file watcher
+const Background = "background"
+
+type toolKey struct{}
+
+// WithTool records the MCP tool a request is made for
+func WithTool(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolKey{}, name)
+}
+
+// Tool returns the tool recorded by WithTool, or ""
+func Tool(ctx context.Context) string {
+	name, _ := ctx.Value(toolKey{}).(string)
+	return name
+}
+
+// Counter collects the tokens providers report for one request. It is safe
+// for concurrent use, as providers may split a request across goroutines.
+type Counter struct {
+	input, output atomic.Int64
+	reported      atomic.Bool
+}
+
+type counterKey struct{}
+
+// WithCounter returns a context whose requests report into a new Counter
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	c := &Counter{}
+	return context.WithValue(ctx, counterKey{}, c), c
+}
+
+// Report adds tokens counted by the provider to the request in ctx. It does
+// nothing outside a metered request.
+func Report(ctx context.Context, input, output int64) {
+	c, _ := ctx.Value(counterKey{}).(*Counter)
+	if c == nil {
+		return
+	}
+	c.input.Add(input)
+	c.output.Add(output)
+	c.reported.Store(true)
+}
+
+// Tokens returns the input and output tokens reported so far, and whether
+// the provider reported any
+func (c *Counter) Tokens() (input, output int64, reported bool) {
+	return c.input.Load(), c.output.Load(), c.reported.Load()
+}
+
+// EstimateTokens approximates the token count of text for providers that
+// do not report usage, at about four characters per token
+func EstimateTokens(chars int) int64 {
+	return int64((chars + 3) / 4)
+}
+
+// Price is the cost of a model in USD per million tokens
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+// Cost returns the estimated cost of the given tokens
+func (p Price) Cost(input, output int64) float64 {
+	return (float64(input)*p.Input + float64(output)*p.Output) / 1e6
+}
+
+// Record is the outcome of one request, including its retries
+type Record struct {
+	Kind         string
+	Tool         string
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	Estimated    bool // tokens were estimated rather than reported
+	Cost         float64
+	Retries      int
+	Failed       bool
+}
+
+// Totals accumulates records
+type Totals struct {
+	Requests          int64   `json:"requests"`
+	Failures          int64   `json:"failures"`
+	Retries           int64   `json:"retries"`
+	InputTokens       int64   `json:"input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	EstimatedRequests int64   `json:"estimated_requests"`
+	CostUSD           float64 `json:"cost_usd"`
+}
+
+func (t *Totals) add(r Record) {
+	t.Requests++
+	if r.Failed {
+		t.Failures++
+	}
+	t.Retries += int64(r.Retries)
+	t.InputTokens += r.InputTokens
+	t.OutputTokens += r.OutputTokens
+	if r.Estimated {
+		t.EstimatedRequests++
+	}
+	t.CostUSD += r.Cost
+}
+
+// Merge adds o to t
+func (t *Totals) Merge(o Totals) {
+	t.Requests += o.Requests
+	t.Failures += o.Failures
+	t.Retries += o.Retries
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.EstimatedRequests += o.EstimatedRequests
+	t.CostUSD += o.CostUSD
+}
+
+// Day is the usage of one calendar day (UTC)
+type Day struct {
+	Date       string            `json:"date"`
+	Totals     Totals            `json:"totals"`
+	ByTool     map[string]Totals `json:"by_tool"`
+	ByProvider map[string]Totals `json:"by_provider"`
+}
+
+// Tracker accumulates records in memory, keeping the last maxDays days
+type Tracker struct {
+	mu      sync.Mutex
+	days    map[string]*Day
+	maxDays int
+	now     func() time.Time
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{days: make(map[string]*Day), maxDays: 31, now: time.Now}
+}
+
+var defaultTracker = NewTracker()
+
+// Default returns the process-wide tracker that providers record into
+func Default() *Tracker {
+	return defaultTracker
+}
+
+// Add records one request
+func (t *Tracker) Add(r Record) {
+	if r.Tool == "" {
+		r.Tool = Background
+	}
+	provider := r.Kind + ":" + r.Provider
+	if r.Model != "" {
+		provider += "/" + r.Model
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	date := t.now().UTC().Format(time.DateOnly)
+	day := t.days[date]
+	if day == nil {
+		day = &Day{Date: date, ByTool: make(map[string]Totals), ByProvider: make(map[string]Totals)}
+		t.days[date] = day
+		t.prune()
+	}
+	day.Totals.add(r)
+	tool := day.ByTool[r.Tool]
+	tool.add(r)
+	day.ByTool[r.Tool] = tool
+	p := day.ByProvider[provider]
+	p.add(r)
+	day.ByProvider[provider] = p
+}
+
+// prune drops the oldest days beyond maxDays; callers hold mu
+func (t *Tracker) prune() {
+	if len(t.days) <= t.maxDays {
+		return
+	}
+	dates := make([]string, 0, len(t.days))
+	for date := range t.days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates[:len(dates)-t.maxDays] {
+		delete(t.days, date)
+	}
+}
+
+// Days returns copies of the most recent n days with usage, newest first
+func (t *Tracker) Days(n int) []Day {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dates := make([]string, 0, len(t.days))
+	for date := range t.days {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	if n > 0 && len(dates) > n {
+		dates = dates[:n]
+	}
+
+	out := make([]Day, 0, len(dates))
+	for _, date := range dates {
+		day := *t.days[date]
+		day.ByTool = copyTotals(day.ByTool)
+		day.ByProvider = copyTotals(day.ByProvider)
+		out = append(out, day)
+	}
+	return out
+}
+
+func copyTotals(m map[string]Totals) map[string]Totals {
+	out := make(map[string]Totals, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrackerAggregates(t *testing.T) {
+	tr := NewTracker()
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	price := Price{Input: 3, Output: 15}
+	tr.Add(Record{Kind: KindLLM, Tool: "codeloom_context", Provider: "anthropic", Model: "claude", InputTokens: 1000, OutputTokens: 200, Cost: price.Cost(1000, 200), Retries: 1})
+	tr.Add(Record{Kind: KindEmbedding, Provider: "ollama", InputTokens: 50, Estimated: true})
+	now = now.Add(2 * time.Hour)
+	tr.Add(Record{Kind: KindLLM, Tool: "codeloom_context", Provider: "anthropic", Model: "claude", Failed: true})
+
+	days := tr.Days(0)
+	if len(days) != 2 || days[0].Date != "2026-03-02" || days[1].Date != "2026-03-01" {
+		t.Fatalf("unexpected days %+v", days)
+	}
+	first := days[1]
+	if first.Totals.Requests != 2 || first.Totals.InputTokens != 1050 || first.Totals.EstimatedRequests != 1 {
+		t.Errorf("unexpected day totals %+v", first.Totals)
+	}
+	if got := first.ByTool["codeloom_context"].CostUSD; math.Abs(got-0.006) > 1e-9 {
+		t.Errorf("expected cost 0.006, got %v", got)
+	}
+	if first.ByTool[Background].Requests != 1 || first.ByProvider["llm:anthropic/claude"].Retries != 1 {
+		t.Errorf("unexpected breakdowns %+v %+v", first.ByTool, first.ByProvider)
+	}
+	if days[0].Totals.Failures != 1 {
+		t.Errorf("expected the failure on the second day, got %+v", days[0].Totals)
+	}
+
+	// Only the most recent days are kept
+	for i := 0; i < 40; i++ {
+		now = now.Add(24 * time.Hour)
+		tr.Add(Record{Kind: KindLLM, Provider: "ollama"})
+	}
+	if n := len(tr.Days(0)); n != tr.maxDays {
+		t.Errorf("expected %d days kept, got %d", tr.maxDays, n)
+	}
+	if n := len(tr.Days(7)); n != 7 {
+		t.Errorf("expected 7 days, got %d", n)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	// Reporting outside a metered request is ignored
+	Report(context.Background(), 10, 10)
+
+	ctx, c := WithCounter(WithTool(context.Background(), "codeloom_search"))
+	if _, _, reported := c.Tokens(); reported {
+		t.Error("expected no usage before a report")
+	}
+	Report(ctx, 10, 2)
+	Report(ctx, 5, 0)
+	if in, out, reported := c.Tokens(); in != 15 || out != 2 || !reported {
+		t.Errorf("Tokens() = %d, %d, %v", in, out, reported)
+	}
+	if Tool(ctx) != "codeloom_search" {
+		t.Errorf("expected tool to survive WithCounter, got %q", Tool(ctx))
+	}
+	if EstimateTokens(9) != 3 {
+		t.Errorf("EstimateTokens(9) = %d", EstimateTokens(9))
+	}
+}
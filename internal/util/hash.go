@@ -0,0 +1,46 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// HashFile computes the SHA256 hash of a file's content, checking ctx between
+// reads so large files can be abandoned early.
+//
+// The indexer and watcher both use it, so content hashes stored in
+// file_metadata are comparable no matter which component wrote them.
+func HashFile(ctx context.Context, filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	// Use a buffered reader with context checking
+	buf := make([]byte, 32*1024) // 32KB buffer
+	for {
+		// Check for context cancellation
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		n, err := f.Read(buf)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if n == 0 {
+			break
+		}
+		h.Write(buf[:n])
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
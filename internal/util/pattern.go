@@ -1,7 +1,7 @@
 package util
 
 import (
-	"log"
+	"log/slog"
 	"path/filepath"
 )
 
@@ -14,7 +14,7 @@ import (
 func MatchPattern(pattern, name string) bool {
 	matched, err := filepath.Match(pattern, name)
 	if err != nil {
-		log.Printf("Warning: invalid pattern '%s': %v. Pattern will not match any files.", pattern, err)
+		slog.Warn("invalid pattern will not match any files", "pattern", pattern, "error", err)
 		return false
 	}
 	return matched
@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseCache keeps agentic tool answers so repeating a question does not
+// re-run the LLM analysis. Keys include the graph version, so entries from
+// before an index update are never served; they are dropped as soon as an
+// answer for a newer version is stored.
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]*list.Element
+	order   *list.List // most recently used at the front
+	now     func() time.Time
+}
+
+type cachedResponse struct {
+	key     string
+	text    string
+	version int64
+	created time.Time
+}
+
+// newResponseCache returns nil when caching is disabled; a nil cache never
+// hits
+func newResponseCache(ttl time.Duration, max int) *responseCache {
+	if max <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &responseCache{
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *responseCache) get(key string) (cachedResponse, bool) {
+	if c == nil {
+		return cachedResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	entry := el.Value.(cachedResponse)
+	if c.now().Sub(entry.created) > c.ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return cachedResponse{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+func (c *responseCache) put(key, text string, version int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Answers for older graph versions can no longer be requested
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if entry := el.Value.(cachedResponse); entry.version < version {
+			c.order.Remove(el)
+			delete(c.entries, entry.key)
+		}
+		el = next
+	}
+
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(cachedResponse{key: key, text: text, version: version, created: c.now()})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(cachedResponse).key)
+	}
+}
+
+// agenticCacheKey identifies an agentic answer by the tool, the normalised
+// request, the model, the graph version and the code context gathered for
+// the prompt, which covers the content of every relevant node
+func agenticCacheKey(tool string, req AgenticRequest, model string, version int64, contexts ...string) string {
+	h := sha256.New()
+	for _, part := range []string{tool, normalizeQuery(req.Query), req.Focus, strconv.Itoa(req.Limit), model, strconv.FormatInt(version, 10)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, c := range contexts {
+		sum := sha256.Sum256([]byte(c))
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeQuery folds case, whitespace and trailing punctuation, which do
+// not change the question
+func normalizeQuery(q string) string {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+	return strings.TrimRight(q, "?.! ")
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// countingLLM answers with the number of calls made so far
+type countingLLM struct {
+	mockLLM
+	calls int
+}
+
+func (c *countingLLM) Generate(ctx context.Context, messages []llm.Message, opts ...llm.Option) (string, error) {
+	c.calls++
+	return fmt.Sprintf("answer %d", c.calls), nil
+}
+
+func TestResponseCacheLimits(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newResponseCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	c.put("a", "A", 1)
+	c.put("b", "B", 1)
+	c.get("a") // a is now the most recently used
+	c.put("c", "C", 1)
+	if _, ok := c.get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if got, ok := c.get("a"); !ok || got.text != "A" {
+		t.Errorf("expected a to survive eviction, got %+v %v", got, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Error("expected entry to expire after the TTL")
+	}
+
+	c.put("old", "O", 1)
+	c.put("new", "N", 2)
+	if _, ok := c.get("old"); ok {
+		t.Error("expected entries for older graph versions to be dropped")
+	}
+
+	if newResponseCache(time.Minute, 0) != nil {
+		t.Error("expected max entries of 0 to disable the cache")
+	}
+	var disabled *responseCache
+	disabled.put("a", "A", 1)
+	if _, ok := disabled.get("a"); ok {
+		t.Error("expected a nil cache never to hit")
+	}
+}
+
+func TestAgenticCacheKey(t *testing.T) {
+	base := AgenticRequest{Query: "How does  Parsing work?", Limit: 5}
+	key := agenticCacheKey("codeloom_context", base, "ollama/qwen", 3, "ctx")
+
+	same := AgenticRequest{Query: "how does parsing work", Limit: 5}
+	if agenticCacheKey("codeloom_context", same, "ollama/qwen", 3, "ctx") != key {
+		t.Error("expected case, spacing and trailing punctuation to be ignored")
+	}
+
+	for name, other := range map[string]string{
+		"tool":    agenticCacheKey("codeloom_impact", base, "ollama/qwen", 3, "ctx"),
+		"model":   agenticCacheKey("codeloom_context", base, "openai/gpt-4o", 3, "ctx"),
+		"version": agenticCacheKey("codeloom_context", base, "ollama/qwen", 4, "ctx"),
+		"context": agenticCacheKey("codeloom_context", base, "ollama/qwen", 3, "changed ctx"),
+	} {
+		if other == key {
+			t.Errorf("expected a different %s to change the key", name)
+		}
+	}
+}
+
+func TestAgenticToolsUseResponseCache(t *testing.T) {
+	fake := &countingLLM{}
+	s := NewServer(ServerConfig{LLM: fake, Config: config.DefaultConfig()})
+
+	call := func(args map[string]any) (string, map[string]any) {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "codeloom_architecture"
+		req.Params.Arguments = args
+		result, err := s.handleAgenticArchitecture(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache, _ := result.Meta.AdditionalFields["cache"].(map[string]any)
+		return result.Content[0].(mcp.TextContent).Text, cache
+	}
+
+	first, meta := call(map[string]any{"query": "How is the API layered?"})
+	if first != "answer 1" || meta["hit"] != false {
+		t.Fatalf("unexpected first answer %q, cache %v", first, meta)
+	}
+	again, meta := call(map[string]any{"query": "how is the API layered"})
+	if again != first || meta["hit"] != true || fake.calls != 1 {
+		t.Errorf("expected a cache hit, got %q, cache %v after %d calls", again, meta, fake.calls)
+	}
+	fresh, meta := call(map[string]any{"query": "How is the API layered?", "no_cache": true})
+	if fresh != "answer 2" || meta["hit"] != false {
+		t.Errorf("expected no_cache to bypass the cache, got %q, cache %v", fresh, meta)
+	}
+	if latest, _ := call(map[string]any{"query": "How is the API layered?"}); latest != "answer 2" {
+		t.Errorf("expected no_cache to refresh the cached answer, got %q", latest)
+	}
+}
+
+// TestAgenticCacheKeepsFallbackAnswers verifies that an answer from a
+// fallback provider is not served once the primary answers again
+func TestAgenticCacheKeepsFallbackAnswers(t *testing.T) {
+	var primaryDown atomic.Bool
+	primaryDown.Store(true)
+	stub := func(answer string, down *atomic.Bool) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if down != nil && down.Load() {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"message":"unavailable","type":"invalid_request_error"}}`))
+				return
+			}
+			w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":` + quote(answer) + `},"finish_reason":"stop"}]}`))
+		}))
+	}
+	primary := stub("primary answer", &primaryDown)
+	defer primary.Close()
+	fallback := stub("fallback answer", nil)
+	defer fallback.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.LLM.Providers = []config.LLMProviderConfig{
+		{Name: "primary", Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-test", BaseURL: primary.URL + "/v1"},
+		{Name: "fallback", Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-test", BaseURL: fallback.URL + "/v1"},
+	}
+	p, err := llm.NewProvider(cfg.LLM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	s := NewServer(ServerConfig{LLM: p, Config: cfg})
+
+	call := func() (string, map[string]any) {
+		t.Helper()
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "codeloom_architecture"
+		req.Params.Arguments = map[string]any{"query": "How is the API layered?"}
+		result, err := s.handleAgenticArchitecture(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache, _ := result.Meta.AdditionalFields["cache"].(map[string]any)
+		return result.Content[0].(mcp.TextContent).Text, cache
+	}
+
+	if answer, meta := call(); answer != "fallback answer" || meta["hit"] != false {
+		t.Fatalf("expected the fallback to answer, got %q, cache %v", answer, meta)
+	}
+	primaryDown.Store(false)
+	if answer, meta := call(); answer != "primary answer" || meta["hit"] != false {
+		t.Errorf("expected the primary to answer instead of the cached fallback answer, got %q, cache %v", answer, meta)
+	}
+	if answer, meta := call(); answer != "primary answer" || meta["hit"] != true {
+		t.Errorf("expected the primary's answer from the cache, got %q, cache %v", answer, meta)
+	}
+}
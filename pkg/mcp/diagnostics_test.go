@@ -0,0 +1,25 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/graph"
+)
+
+func TestBuildDiagnosticsFilters(t *testing.T) {
+	errs := []graph.SyntaxError{{StartLine: 3, StartCol: 1, EndLine: 3, EndCol: 9, Message: `unexpected "@@"`}}
+	metas := []graph.FileMetadata{
+		{FilePath: "/r/api/handler.go", Language: "go", SyntaxErrors: errs, ErrorRatio: 0.4, Stale: true},
+		{FilePath: "/r/apiclient/client.go", SyntaxErrors: errs, ErrorRatio: 0.1},
+		{FilePath: "/r/api/clean.go"},
+	}
+
+	files := buildDiagnostics(metas, "/r/api")
+	if len(files) != 1 || files[0].File != "/r/api/handler.go" || !files[0].Stale || len(files[0].Errors) != 1 {
+		t.Errorf("expected only the broken file under /r/api, got %+v", files)
+	}
+
+	if files = buildDiagnostics(metas, ""); len(files) != 2 {
+		t.Errorf("expected every file with syntax errors, got %+v", files)
+	}
+}
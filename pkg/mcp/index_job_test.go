@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/embedding"
+	"github.com/heefoo/codeloom/internal/graph"
+	"github.com/heefoo/codeloom/internal/graph/graphtest"
+	"github.com/heefoo/codeloom/internal/indexer"
+	"github.com/heefoo/codeloom/internal/parser"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestJobIndexerKeepsSharedIndexer verifies that a job's own options do not
+// replace the shared indexer used by the jobs after it
+func TestJobIndexerKeepsSharedIndexer(t *testing.T) {
+	cfg := config.DefaultConfig()
+	s := NewServer(ServerConfig{Config: cfg})
+	provider, err := embedding.NewHashProvider(cfg.Embedding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.embedding = provider
+	shared := indexer.New(indexer.Config{Parser: parser.NewParser(), Embedding: provider})
+	s.indexer = shared
+
+	if idx := s.jobIndexer(graph.IndexJob{Directory: "/src", SkipEmbeddings: true}); idx == shared {
+		t.Fatal("expected a skip-embeddings job to get an indexer of its own")
+	}
+	if idx := s.jobIndexer(graph.IndexJob{Directory: "/src", ExcludePatterns: []string{"testdata"}}); idx == shared {
+		t.Fatal("expected a job with exclude patterns to get an indexer of its own")
+	}
+	if s.indexer != shared {
+		t.Fatal("expected the shared indexer to be kept")
+	}
+	if idx := s.jobIndexer(graph.IndexJob{Directory: "/src"}); idx != shared {
+		t.Error("expected a default job after them to use the shared indexer")
+	}
+}
+
+// TestIndexStatusReportsJobIndexer verifies that index status reports the
+// job that ran on an indexer of its own rather than the idle shared one
+func TestIndexStatusReportsJobIndexer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(ServerConfig{Config: config.DefaultConfig()})
+	s.storage = graphtest.Storage(t)
+	s.indexer = indexer.New(indexer.Config{Parser: parser.NewParser(), Storage: s.storage})
+
+	ctx := context.Background()
+	job := graph.IndexJob{Directory: dir, SkipEmbeddings: true}
+	if err := s.runIndexJob(ctx, job, func(indexer.Status) {}); err != nil {
+		t.Fatalf("index job failed: %v", err)
+	}
+
+	result, err := s.handleIndexStatus(ctx, mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status map[string]any
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status["directory"] != dir || status["files_indexed"] != float64(1) {
+		t.Errorf("expected the job's status, got %v", status)
+	}
+}
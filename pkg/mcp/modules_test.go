@@ -0,0 +1,29 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/graph"
+)
+
+func TestBuildModuleGraphFilters(t *testing.T) {
+	imports := []graph.CodeEdge{
+		{FromID: "/r/api/handler.go", ToID: "/r/store/db.go"},
+		{FromID: "/r/api/handler.go", ToID: "external::net/http"},
+		{FromID: "/r/apiclient/client.go", ToID: "/r/api/handler.go"},
+		{FromID: "/r/store/db.go", ToID: "external::database/sql"},
+	}
+
+	g, err := buildModuleGraph(imports, nil, graph.GranularityFile, "/r/api", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Edges) != 1 || g.Edges[0].From != "/r/api/handler.go" || g.Edges[0].To != "/r/store/db.go" {
+		t.Errorf("expected only the internal import made under /r/api, got %+v", g.Edges)
+	}
+
+	g, _ = buildModuleGraph(imports, nil, graph.GranularityDirectory, "", true)
+	if len(g.Edges) != 4 {
+		t.Errorf("expected every import without filters, got %+v", g.Edges)
+	}
+}
@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/llm"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// promptLLM records the prompt it was asked to answer
+type promptLLM struct {
+	mockLLM
+	prompt string
+}
+
+func (p *promptLLM) Generate(ctx context.Context, messages []llm.Message, opts ...llm.Option) (string, error) {
+	p.prompt = messages[len(messages)-1].Content
+	return "ok", nil
+}
+
+func TestHandlePrompt(t *testing.T) {
+	s := NewServer(ServerConfig{Config: config.DefaultConfig()})
+
+	req := mcp.GetPromptRequest{}
+	req.Params.Arguments = map[string]string{"query": "What breaks if Parse changes?"}
+	result, err := s.handlePrompt(context.Background(), "impact", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(result.Description, "(impact@v1)") || len(result.Messages) != 1 {
+		t.Fatalf("unexpected prompt result %+v", result)
+	}
+	text := result.Messages[0].Content.(mcp.TextContent).Text
+	if !strings.Contains(text, "What breaks if Parse changes?") {
+		t.Errorf("expected the query in the prompt, got:\n%s", text)
+	}
+
+	req.Params.Arguments = map[string]string{}
+	if _, err := s.handlePrompt(context.Background(), "impact", req); err == nil {
+		t.Error("expected an error without a query")
+	}
+}
+
+func TestAgenticToolsUsePromptOverrides(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "context.tmpl"), []byte("Custom: {{.Query}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.LLM.PromptsDir = dir
+	fake := &promptLLM{}
+	s := NewServer(ServerConfig{LLM: fake, Config: cfg})
+
+	req := mcp.CallToolRequest{}
+	req.Params.Name = "codeloom_context"
+	req.Params.Arguments = map[string]any{"query": "where is config loaded"}
+	if _, err := s.handleAgenticContext(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if fake.prompt != "Custom: where is config loaded" {
+		t.Errorf("expected the override to be used, got %q", fake.prompt)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,19 +21,36 @@ import (
 	"github.com/heefoo/codeloom/internal/embedding"
 	"github.com/heefoo/codeloom/internal/graph"
 	"github.com/heefoo/codeloom/internal/indexer"
+	"github.com/heefoo/codeloom/internal/jobs"
 	"github.com/heefoo/codeloom/internal/llm"
+	"github.com/heefoo/codeloom/internal/logging"
 	"github.com/heefoo/codeloom/internal/parser"
+	"github.com/heefoo/codeloom/internal/prompts"
+	"github.com/heefoo/codeloom/internal/telemetry"
+	"github.com/heefoo/codeloom/internal/usage"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Server struct {
 	llm       llm.Provider
 	config    *config.Config
+	logger    *slog.Logger // tagged with component=mcp
+	rootLog   *slog.Logger // handed to indexer, storage and watcher
 	mcp       *server.MCPServer
 	indexer   *indexer.Indexer
+	jobIdx    *indexer.Indexer // ran the latest index job; reported by index status
+	jobs      *jobs.Manager
 	storage   *graph.Storage
 	embedding embedding.Provider
+	documents *embedding.DocumentBuilder
+	cache     *responseCache // agentic answers; nil when disabled
+	prompts   *prompts.Store
 	watcher   *daemon.Watcher
 	watchCtx  context.Context
 	watchStop context.CancelFunc
@@ -42,12 +62,27 @@ type Server struct {
 type ServerConfig struct {
 	LLM    llm.Provider
 	Config *config.Config
+	Logger *slog.Logger // optional; defaults to slog.Default()
 }
 
 func NewServer(cfg ServerConfig) *Server {
 	s := &Server{
-		llm:    cfg.LLM,
-		config: cfg.Config,
+		llm:     cfg.LLM,
+		config:  cfg.Config,
+		rootLog: cfg.Logger,
+	}
+	if s.rootLog == nil {
+		s.rootLog = slog.Default()
+	}
+	s.logger = logging.Component(s.rootLog, "mcp")
+	promptsDir := ""
+	if s.config != nil {
+		s.cache = newResponseCache(time.Duration(s.config.LLM.CacheTTLSecs)*time.Second, s.config.LLM.CacheMaxEntries)
+		promptsDir = s.config.LLM.PromptsDir
+	}
+	s.prompts = prompts.NewStore(promptsDir)
+	if dir := s.prompts.Dir(); dir != "" {
+		s.logger.Info("prompt overrides directory", "dir", dir)
 	}
 
 	// Create MCP server
@@ -55,10 +90,14 @@ func NewServer(cfg ServerConfig) *Server {
 		"codeloom",
 		"0.1.0",
 		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(false),
+		server.WithToolHandlerMiddleware(withToolTracing),
+		server.WithToolHandlerMiddleware(withToolName),
 	)
 
 	// Register tools
 	s.registerTools(mcpServer)
+	s.registerPrompts(mcpServer)
 
 	s.mcp = mcpServer
 	return s
@@ -80,6 +119,7 @@ func (s *Server) initializeIndexer() error {
 		Database:  s.config.Database.SurrealDB.Database,
 		Username:  s.config.Database.SurrealDB.Username,
 		Password:  s.config.Database.SurrealDB.Password,
+		Logger:    s.rootLog,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
@@ -89,22 +129,101 @@ func (s *Server) initializeIndexer() error {
 	// Create embedding provider (optional)
 	embProvider, err := embedding.NewProvider(s.config.Embedding)
 	if err != nil {
-		log.Printf("Warning: embedding provider not available: %v", err)
+		s.logger.Warn("embedding provider not available", "error", err)
 	}
 	s.embedding = embProvider
 
+	documents, err := embedding.NewDocumentBuilder(s.config.Embedding)
+	if err != nil {
+		s.logger.Warn("using the default document template", "error", err)
+		documents = embedding.DefaultDocumentBuilder()
+	}
+	s.documents = documents
+
 	// Create parser and indexer
-	p := parser.NewParser()
+	p := parser.NewParser(parser.WithCompileCommands(s.config.Server.CompileCommands))
 	s.indexer = indexer.New(indexer.Config{
 		Parser:          p,
 		Storage:         storage,
 		Embedding:       embProvider,
+		Documents:       documents,
 		ExcludePatterns: indexer.DefaultExcludePatterns(),
+		Logger:          s.rootLog,
+		ErrorThreshold:  s.config.Server.ParseErrorThreshold,
+	})
+
+	s.jobs = jobs.NewManager(jobs.Config{
+		Store:  storage,
+		Run:    s.runIndexJob,
+		Logger: s.rootLog,
 	})
 
 	return nil
 }
 
+// ResumeJobs connects to storage and re-queues index jobs left unfinished by
+// a previous run. Progress is resumed from the last completed file.
+func (s *Server) ResumeJobs(ctx context.Context) (int, error) {
+	if err := s.initializeIndexer(); err != nil {
+		return 0, err
+	}
+	return s.jobs.Resume(ctx)
+}
+
+// runIndexJob is the jobs.RunFunc for background indexing
+func (s *Server) runIndexJob(ctx context.Context, job graph.IndexJob, progress func(indexer.Status)) error {
+	s.setProjectRoot(job.Directory)
+	idx := s.jobIndexer(job)
+	s.mu.Lock()
+	s.jobIdx = idx
+	s.mu.Unlock()
+	return idx.IndexDirectory(ctx, job.Directory, progress)
+}
+
+// statusIndexer returns the indexer whose status is reported: the one that
+// ran the latest index job, which may be a job's own, or else the shared one
+func (s *Server) statusIndexer() *indexer.Indexer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.jobIdx != nil {
+		return s.jobIdx
+	}
+	return s.indexer
+}
+
+// jobIndexer returns the shared indexer, or an indexer of the job's own when
+// it excludes more files or skips embeddings. The shared indexer is never
+// replaced, so the options of one job do not carry over to the next.
+func (s *Server) jobIndexer(job graph.IndexJob) *indexer.Indexer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(job.ExcludePatterns) == 0 && !job.SkipEmbeddings {
+		return s.indexer
+	}
+	embProvider := s.embedding
+	if job.SkipEmbeddings {
+		embProvider = nil
+	}
+	return indexer.New(indexer.Config{
+		Parser:          parser.NewParser(parser.WithCompileCommands(s.config.Server.CompileCommands)),
+		Storage:         s.storage,
+		Embedding:       embProvider,
+		Documents:       s.documents,
+		ExcludePatterns: append(indexer.DefaultExcludePatterns(), job.ExcludePatterns...),
+		Logger:          s.rootLog,
+		ErrorThreshold:  s.config.Server.ParseErrorThreshold,
+	})
+}
+
+// setProjectRoot resolves a relative prompts directory against the project
+// being indexed or watched, logging the directory when it changes
+func (s *Server) setProjectRoot(root string) {
+	if dir, changed := s.prompts.SetRoot(root); changed {
+		s.logger.Info("prompt overrides directory", "dir", dir)
+	}
+}
+
 func (s *Server) registerTools(mcpServer *server.MCPServer) {
 	// ==========================================================================
 	// CODEBASE INDEXING TOOLS
@@ -143,11 +262,44 @@ Example: {"directory": "./src", "exclude_patterns": ["test", "mock"]}`,
 					"description": "Skip embedding generation for faster indexing (disables semantic search)",
 					"default":     false,
 				},
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional job name (defaults to the directory name)",
+				},
 			},
 			Required: []string{"directory"},
 		},
 	}, s.handleIndex)
 
+	// codeloom_jobs tool
+	mcpServer.AddTool(mcp.Tool{
+		Name: "codeloom_jobs",
+		Description: `List, inspect or cancel CodeLoom background indexing jobs.
+
+PURPOSE: codeloom_index returns a job_id immediately and indexes in the background.
+Use this tool to follow progress or stop a job. Unfinished jobs resume automatically
+from the last completed file when the server restarts.
+
+Returns: job state (queued/running/completed/failed/cancelled), files processed, progress_percent, last_file.
+
+Example: {"action": "get", "job_id": "job_1a2b3c4d5e6f"}`,
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"description": "Action to perform: 'list' all jobs, 'get' one job, or 'cancel' a job",
+					"enum":        []string{"list", "get", "cancel"},
+				},
+				"job_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Job ID returned by codeloom_index (required for 'get' and 'cancel')",
+				},
+			},
+			Required: []string{"action"},
+		},
+	}, s.handleJobs)
+
 	// codeloom_index_status tool
 	mcpServer.AddTool(mcp.Tool{
 		Name: "codeloom_index_status",
@@ -201,6 +353,30 @@ Example: {"action": "start", "directories": ["./src", "./pkg"]}`,
 		},
 	}, s.handleWatch)
 
+	// codeloom_usage tool
+	mcpServer.AddTool(mcp.Tool{
+		Name: "codeloom_usage",
+		Description: `Report LLM and embedding usage and estimated cost.
+
+PURPOSE: See how many requests, retries and tokens CodeLoom's model providers have used,
+and what they cost, per day, per tool and per provider since the server started.
+Costs use the input_price and output_price configured for each provider (USD per million tokens).
+
+Returns: for each day (newest first), totals plus by_tool and by_provider breakdowns.
+
+Example: {"days": 7}`,
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"days": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of most recent days to report",
+					"default":     7,
+				},
+			},
+		},
+	}, s.handleUsage)
+
 	// ==========================================================================
 	// CODE ANALYSIS TOOLS (LLM-powered)
 	// ==========================================================================
@@ -234,6 +410,10 @@ Example: {"query": "How does the payment processing work?"}`,
 					"description": "Analysis focus: 'search' for finding code, 'builder' for implementation, 'question' for understanding",
 					"enum":        []string{"search", "builder", "question"},
 				},
+				"no_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the response cache and run a fresh analysis",
+				},
 			},
 			Required: []string{"query"},
 		},
@@ -268,6 +448,10 @@ Example: {"query": "What if I rename the DatabaseConnection class?"}`,
 					"description": "Focus: 'dependencies' for what this code uses, 'call_chain' for what calls this code",
 					"enum":        []string{"dependencies", "call_chain"},
 				},
+				"no_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the response cache and run a fresh analysis",
+				},
 			},
 			Required: []string{"query"},
 		},
@@ -302,6 +486,10 @@ Example: {"query": "How is the backend API structured?"}`,
 					"description": "Focus: 'structure' for module organization, 'api_surface' for public interfaces",
 					"enum":        []string{"structure", "api_surface"},
 				},
+				"no_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the response cache and run a fresh analysis",
+				},
 			},
 			Required: []string{"query"},
 		},
@@ -336,6 +524,10 @@ Example: {"query": "Find complex functions that need refactoring"}`,
 					"description": "Focus: 'complexity' for complex code, 'coupling' for dependencies, 'hotspots' for frequently changed areas",
 					"enum":        []string{"complexity", "coupling", "hotspots"},
 				},
+				"no_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Skip the response cache and run a fresh analysis",
+				},
 			},
 			Required: []string{"query"},
 		},
@@ -457,6 +649,76 @@ Example: {"from": "main", "to": "database.Query", "max_depth": 10}`,
 			Required: []string{"from", "to"},
 		},
 	}, s.handleTraceCallChain)
+
+	// codeloom_modules tool
+	mcpServer.AddTool(mcp.Tool{
+		Name: "codeloom_modules",
+		Description: `Get the import graph between SOURCE CODE modules.
+
+PURPOSE: Show which files, directories or packages import which, including external dependencies.
+REQUIRES: Run codeloom_index first to populate the code graph.
+
+WHEN TO USE:
+- "Which packages depend on the storage layer?"
+- "What does the web directory import?"
+- "Which external libraries does this project use?"
+
+NOT FOR: Function-level dependencies. Use codeloom_dependencies for those.
+
+Returns: modules with their import counts, and dependency edges weighted by the number of file imports they stand for.
+Modules are file paths or directories, "package::<name>" for packages and namespaces, and "external::<name>" for dependencies outside the repository.
+
+Example: {"granularity": "package", "path": "internal", "include_external": false}`,
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"granularity": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{graph.GranularityFile, graph.GranularityDirectory, graph.GranularityPackage},
+					"description": "Level to aggregate imports at",
+					"default":     graph.GranularityFile,
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Only include imports made by files under this path",
+				},
+				"include_external": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Include dependencies outside the repository",
+					"default":     true,
+				},
+			},
+		},
+	}, s.handleModules)
+
+	// codeloom_diagnostics tool
+	mcpServer.AddTool(mcp.Tool{
+		Name: "codeloom_diagnostics",
+		Description: `List SOURCE CODE files that currently fail to parse cleanly.
+
+PURPOSE: Show the syntax errors found when files were last indexed, and which files still answer queries from their last version that parsed.
+REQUIRES: Run codeloom_index first to populate the code graph.
+
+WHEN TO USE:
+- "Why is this function missing from search results?"
+- "Which files have syntax errors?"
+- Checking whether results for a file being edited are current
+
+NOT FOR: Compiler or linter errors such as type errors. Only what the parser could not read is reported.
+
+Returns: files with the share of their bytes in syntax errors (error_ratio), the error ranges with lines and columns from 1, and stale: true when the file had more errors than the parse error threshold allows, so its nodes are those of its last good version.
+
+Example: {"path": "internal/parser"}`,
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Only include files under this path",
+				},
+			},
+		},
+	}, s.handleDiagnostics)
 }
 
 // ==========================================================================
@@ -464,9 +726,10 @@ Example: {"from": "main", "to": "database.Query", "max_depth": 10}`,
 // ==========================================================================
 
 type AgenticRequest struct {
-	Query string `json:"query"`
-	Limit int    `json:"limit"`
-	Focus string `json:"focus,omitempty"`
+	Query   string `json:"query"`
+	Limit   int    `json:"limit"`
+	Focus   string `json:"focus,omitempty"`
+	NoCache bool   `json:"no_cache,omitempty"`
 }
 
 func (s *Server) handleIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -482,6 +745,9 @@ func (s *Server) handleIndex(ctx context.Context, request mcp.CallToolRequest) (
 	if dir == "" {
 		return errorResult("directory is required")
 	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return errorResult(fmt.Sprintf("directory not found: %s", dir))
+	}
 
 	// Initialize indexer if needed
 	if err := s.initializeIndexer(); err != nil {
@@ -504,46 +770,170 @@ func (s *Server) handleIndex(ctx context.Context, request mcp.CallToolRequest) (
 		skipEmbeddings = se
 	}
 
-	// Update indexer if exclude patterns or skip_embeddings provided
-	if len(excludePatterns) > 0 || skipEmbeddings {
-		allPatterns := append(indexer.DefaultExcludePatterns(), excludePatterns...)
-		embProvider := s.embedding
-		if skipEmbeddings {
-			embProvider = nil
-		}
-		s.mu.Lock()
-		s.indexer = indexer.New(indexer.Config{
-			Parser:          parser.NewParser(),
-			Storage:         s.storage,
-			Embedding:       embProvider,
-			ExcludePatterns: allPatterns,
-		})
-		s.mu.Unlock()
+	name, _ := args["name"].(string)
+
+	job, err := s.jobs.Submit(jobs.Request{
+		Name:            name,
+		Directory:       dir,
+		ExcludePatterns: excludePatterns,
+		SkipEmbeddings:  skipEmbeddings,
+	})
+	if err != nil {
+		return errorResult(fmt.Sprintf("failed to start indexing job: %v", err))
 	}
 
-	// Run indexing with a reasonable timeout, derived from parent context
-	indexCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
-	defer cancel()
+	result := jobResult(job)
+	result["message"] = "Indexing started in the background. Use codeloom_jobs with action 'get' to follow progress."
 
-	err := s.indexer.IndexDirectory(indexCtx, dir, nil)
+	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		return errorResult(fmt.Sprintf("indexing failed: %v", err))
+		s.logger.Error("failed to marshal index job result", "error", err)
+		return errorResult(fmt.Sprintf("Failed to format index job: %v", err))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonBytes),
+			},
+		},
+	}, nil
+}
+
+func (s *Server) handleJobs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	if args == nil {
+		return errorResult("arguments must be an object")
+	}
+
+	action, ok := args["action"].(string)
+	if !ok || action == "" {
+		return errorResult("action is required: 'list', 'get' or 'cancel'")
+	}
+	jobID, _ := args["job_id"].(string)
+
+	s.mu.RLock()
+	manager := s.jobs
+	s.mu.RUnlock()
+
+	var result map[string]interface{}
+	switch action {
+	case "list":
+		list := []map[string]interface{}{}
+		if manager != nil {
+			for _, job := range manager.List() {
+				list = append(list, jobResult(job))
+			}
+		}
+		result = map[string]interface{}{
+			"jobs":  list,
+			"count": len(list),
+		}
+	case "get", "cancel":
+		if jobID == "" {
+			return errorResult(fmt.Sprintf("job_id is required for '%s'", action))
+		}
+		if manager == nil {
+			return errorResult(fmt.Sprintf("job %s not found", jobID))
+		}
+		var job graph.IndexJob
+		var err error
+		if action == "get" {
+			job, err = manager.Get(jobID)
+		} else {
+			job, err = manager.Cancel(jobID)
+		}
+		if errors.Is(err, jobs.ErrNotFound) {
+			return errorResult(fmt.Sprintf("job %s not found", jobID))
+		}
+		if err != nil {
+			return errorResult(err.Error())
+		}
+		result = jobResult(job)
+		if action == "cancel" {
+			result["cancel_requested"] = true
+		}
+	default:
+		return errorResult(fmt.Sprintf("unknown action: %s (use 'list', 'get' or 'cancel')", action))
+	}
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("failed to marshal jobs result", "error", err)
+		return errorResult(fmt.Sprintf("Failed to format jobs result: %v", err))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonBytes),
+			},
+		},
+	}, nil
+}
+
+// jobResult formats a job for tool output, adding a progress percentage over
+// all files found (unchanged files count as done).
+func jobResult(job graph.IndexJob) map[string]interface{} {
+	result := map[string]interface{}{
+		"job_id":          job.JobID,
+		"name":            job.Name,
+		"directory":       job.Directory,
+		"state":           job.State,
+		"files_total":     job.FilesTotal,
+		"files_skipped":   job.FilesSkipped,
+		"files_processed": job.FilesProcessed,
+		"nodes_created":   job.NodesCreated,
+		"skip_embeddings": job.SkipEmbeddings,
+		"created_at":      time.Unix(job.CreatedAt, 0).UTC().Format(time.RFC3339),
+	}
+	if len(job.ExcludePatterns) > 0 {
+		result["exclude_patterns"] = job.ExcludePatterns
+	}
+	if job.LastFile != "" {
+		result["last_file"] = job.LastFile
+	}
+	if job.Error != "" {
+		result["error"] = job.Error
+	}
+	if job.FilesTotal > 0 {
+		done := job.FilesSkipped + job.FilesProcessed
+		result["progress_percent"] = float64(done*1000/job.FilesTotal) / 10
+	}
+	if job.StartedAt > 0 {
+		result["started_at"] = time.Unix(job.StartedAt, 0).UTC().Format(time.RFC3339)
+	}
+	if job.CompletedAt > 0 {
+		result["completed_at"] = time.Unix(job.CompletedAt, 0).UTC().Format(time.RFC3339)
+		if job.StartedAt > 0 {
+			result["duration"] = (time.Duration(job.CompletedAt-job.StartedAt) * time.Second).String()
+		}
+	}
+	return result
+}
+
+func (s *Server) handleUsage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	days := 7
+	if args := request.GetArguments(); args != nil {
+		if v, ok := args["days"].(float64); ok && v > 0 {
+			days = int(v)
+		}
 	}
 
-	status := s.indexer.GetStatus()
+	report := usage.Default().Days(days)
+	var total usage.Totals
+	for _, day := range report {
+		total.Merge(day.Totals)
+	}
 	result := map[string]interface{}{
-		"success":       true,
-		"directory":     status.Directory,
-		"nodes_created": status.NodesCreated,
-		"edges_created": status.EdgesCreated,
-		"duration":      status.CompletedAt.Sub(status.StartedAt).String(),
-		"errors_count":  len(status.Errors),
+		"days":  report,
+		"total": total,
 	}
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling index status result: %v", err)
-		return errorResult(fmt.Sprintf("Failed to format index status: %v", err))
+		s.logger.Error("failed to marshal usage result", "error", err)
+		return errorResult(fmt.Sprintf("Failed to format usage: %v", err))
 	}
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -563,7 +953,7 @@ func (s *Server) handleIndexStatus(ctx context.Context, request mcp.CallToolRequ
 		}
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			log.Printf("Error marshaling unindexed status result: %v", err)
+			s.logger.Error("failed to marshal unindexed status result", "error", err)
 			return errorResult(fmt.Sprintf("Failed to format status: %v", err))
 		}
 		return &mcp.CallToolResult{
@@ -576,7 +966,7 @@ func (s *Server) handleIndexStatus(ctx context.Context, request mcp.CallToolRequ
 		}, nil
 	}
 
-	status := s.indexer.GetStatus()
+	status := s.statusIndexer().GetStatus()
 	result := map[string]interface{}{
 		"state":         status.State,
 		"directory":     status.Directory,
@@ -598,7 +988,7 @@ func (s *Server) handleIndexStatus(ctx context.Context, request mcp.CallToolRequ
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling index status with timestamps: %v", err)
+		s.logger.Error("failed to marshal index status with timestamps", "error", err)
 		return errorResult(fmt.Sprintf("Failed to format index status: %v", err))
 	}
 	return &mcp.CallToolResult{
@@ -611,256 +1001,64 @@ func (s *Server) handleIndexStatus(ctx context.Context, request mcp.CallToolRequ
 	}, nil
 }
 
-func (s *Server) handleAgenticContext(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if s.llm == nil {
-		return errorResult("LLM provider not configured. Set CODELOOM_LLM_PROVIDER and required API keys.")
-	}
-
-	args := request.GetArguments()
-	if args == nil {
-		return errorResult("arguments must be an object")
-	}
-	req := parseAgenticRequest(args)
-
-	// Use a fresh context with timeout, derived from parent context
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
-
-	// Get code context from graph if available
-	codeContext := s.gatherCodeContext(ctx, req.Query, req.Limit)
-
-	prompt := fmt.Sprintf(`You are a code analysis expert. Analyze the following query about a codebase.
-
-## Query
-%s
-
-## Relevant Code from the Codebase
-%s
-
-## Instructions
-Based on the code above, provide a comprehensive answer to the query.
-
-Provide your analysis in this JSON format:
-{
-  "summary": "Brief summary answering the query",
-  "analysis": "Detailed analysis based on the actual code",
-  "highlights": ["Key code locations as file:line references"],
-  "related_locations": ["Other relevant files/functions to explore"],
-  "risks": ["Potential risks or concerns"],
-  "next_steps": ["Recommended actions"],
-  "confidence": "high/medium/low"
-}`, req.Query, codeContext)
-
-	messages := []llm.Message{
-		{Role: llm.RoleUser, Content: prompt},
-	}
-
-	result, err := s.llm.Generate(ctx, messages)
-	if err != nil {
-		return nil, err
+// registerPrompts exposes the agentic prompt templates as MCP prompts, so
+// clients can run them with their own model. Each prompt gathers the same
+// context as the matching tool.
+func (s *Server) registerPrompts(mcpServer *server.MCPServer) {
+	for _, t := range s.prompts.List() {
+		name := t.Name
+		mcpServer.AddPrompt(mcp.NewPrompt("codeloom_"+name,
+			mcp.WithPromptDescription(t.Description),
+			mcp.WithArgument("query", mcp.RequiredArgument(), mcp.ArgumentDescription("Question or change to analyze")),
+			mcp.WithArgument("focus", mcp.ArgumentDescription("Optional analysis focus, as for the codeloom_"+name+" tool")),
+			mcp.WithArgument("limit", mcp.ArgumentDescription("Number of code matches to include (default 5)")),
+		), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return s.handlePrompt(ctx, name, request)
+		})
 	}
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: result,
-			},
-		},
-	}, nil
 }
 
-func (s *Server) handleAgenticImpact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if s.llm == nil {
-		return errorResult("LLM provider not configured. Set CODELOOM_LLM_PROVIDER and required API keys.")
+func (s *Server) handlePrompt(ctx context.Context, name string, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	req := AgenticRequest{Query: args["query"], Focus: args["focus"], Limit: 5}
+	if req.Query == "" {
+		return nil, fmt.Errorf("query argument is required")
 	}
-
-	args := request.GetArguments()
-	if args == nil {
-		return errorResult("arguments must be an object")
+	if l, err := strconv.Atoi(args["limit"]); err == nil && l > 0 {
+		req.Limit = l
 	}
-	req := parseAgenticRequest(args)
 
 	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
 
-	// Get code context and dependency information
-	codeContext := s.gatherCodeContext(ctx, req.Query, req.Limit)
-	dependencyContext := s.gatherDependencyContext(ctx, req.Query)
-
-	prompt := fmt.Sprintf(`You are a code impact analysis expert. Analyze the potential impact of changes described in this query.
-
-## Query
-%s
-
-## Relevant Code
-%s
-
-## Dependency Information
-%s
-
-## Instructions
-Analyze what would be affected if changes were made based on the query. Consider:
-- Direct dependencies (what this code uses)
-- Reverse dependencies (what uses this code)
-- Potential cascading effects
-
-Provide your analysis in this JSON format:
-{
-  "summary": "Brief summary of impact",
-  "analysis": "Detailed impact analysis based on the actual code",
-  "affected_locations": ["Files and locations as file:line that would be affected"],
-  "risks": ["Risks of making changes"],
-  "next_steps": ["Recommended steps before making changes"],
-  "confidence": "high/medium/low"
-}`, req.Query, codeContext, dependencyContext)
-
-	messages := []llm.Message{
-		{Role: llm.RoleUser, Content: prompt},
-	}
-
-	result, err := s.llm.Generate(ctx, messages)
+	tmpl, err := s.prompts.Get(name)
 	if err != nil {
 		return nil, err
 	}
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: result,
-			},
-		},
-	}, nil
-}
-
-func (s *Server) handleAgenticArchitecture(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if s.llm == nil {
-		return errorResult("LLM provider not configured. Set CODELOOM_LLM_PROVIDER and required API keys.")
-	}
-
-	args := request.GetArguments()
-	if args == nil {
-		return errorResult("arguments must be an object")
-	}
-	req := parseAgenticRequest(args)
-
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
-
-	// Get structural overview of the codebase
-	structureContext := s.gatherStructureContext(ctx, req.Query)
-	codeContext := s.gatherCodeContext(ctx, req.Query, req.Limit)
-
-	prompt := fmt.Sprintf(`You are a software architecture expert. Analyze the architecture of this codebase.
-
-## Query
-%s
-
-## Codebase Structure
-%s
-
-## Relevant Code
-%s
-
-## Instructions
-Analyze the architectural patterns, module organization, and design decisions visible in the code.
-
-Provide your analysis in this JSON format:
-{
-  "summary": "Brief architectural summary",
-  "analysis": "Detailed architectural analysis based on the actual code structure",
-  "highlights": ["Key architectural components with file:line references"],
-  "related_locations": ["Relevant files and modules"],
-  "risks": ["Architectural concerns"],
-  "next_steps": ["Recommended architectural improvements"],
-  "confidence": "high/medium/low"
-}`, req.Query, structureContext, codeContext)
-
-	messages := []llm.Message{
-		{Role: llm.RoleUser, Content: prompt},
-	}
-
-	result, err := s.llm.Generate(ctx, messages)
+	text, err := tmpl.Execute(s.gatherPromptData(ctx, name, req))
 	if err != nil {
 		return nil, err
 	}
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: result,
-			},
-		},
-	}, nil
+	return mcp.NewGetPromptResult(
+		fmt.Sprintf("%s (%s)", tmpl.Description, tmpl.ID()),
+		[]mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text))},
+	), nil
 }
 
-func (s *Server) handleAgenticQuality(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if s.llm == nil {
-		return errorResult("LLM provider not configured. Set CODELOOM_LLM_PROVIDER and required API keys.")
-	}
-
-	args := request.GetArguments()
-	if args == nil {
-		return errorResult("arguments must be an object")
-	}
-	req := parseAgenticRequest(args)
-
-	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
-
-	// Get code context with focus on potential quality issues
-	codeContext := s.gatherCodeContext(ctx, req.Query, req.Limit*2) // More samples for quality analysis
-	metricsContext := s.gatherMetricsContext(ctx)
-
-	prompt := fmt.Sprintf(`You are a code quality expert. Analyze code quality issues in this codebase.
-
-## Query
-%s
-
-## Relevant Code
-%s
-
-## Codebase Metrics
-%s
-
-## Instructions
-Analyze the code for quality issues such as:
-- Complexity (long functions, deep nesting)
-- Code duplication
-- Tight coupling between modules
-- Missing error handling
-- Poor naming conventions
-- Lack of documentation
-
-Provide your analysis in this JSON format:
-{
-  "summary": "Brief quality summary",
-  "analysis": "Detailed quality analysis based on the actual code",
-  "hotspots": ["Code quality hotspots with file:line references"],
-  "risk_notes": ["Quality risks"],
-  "next_steps": ["Specific recommended improvements"],
-  "confidence": "high/medium/low"
-}`, req.Query, codeContext, metricsContext)
-
-	messages := []llm.Message{
-		{Role: llm.RoleUser, Content: prompt},
-	}
+func (s *Server) handleAgenticContext(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.runAgentic(ctx, request, "codeloom_context", prompts.Context)
+}
 
-	result, err := s.llm.Generate(ctx, messages)
-	if err != nil {
-		return nil, err
-	}
+func (s *Server) handleAgenticImpact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.runAgentic(ctx, request, "codeloom_impact", prompts.Impact)
+}
 
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: result,
-			},
-		},
-	}, nil
+func (s *Server) handleAgenticArchitecture(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.runAgentic(ctx, request, "codeloom_architecture", prompts.Architecture)
+}
+
+func (s *Server) handleAgenticQuality(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.runAgentic(ctx, request, "codeloom_quality", prompts.Quality)
 }
 
 func (s *Server) handleSemanticSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -893,7 +1091,7 @@ func (s *Server) handleSemanticSearch(ctx context.Context, request mcp.CallToolR
 	}
 
 	// Generate embedding for query
-	queryEmb, err := s.embedding.EmbedSingle(ctx, query)
+	queryEmb, err := s.embedding.EmbedQuery(ctx, query)
 	if err != nil {
 		return errorResult(fmt.Sprintf("Failed to generate query embedding: %v", err))
 	}
@@ -930,7 +1128,7 @@ func (s *Server) handleSemanticSearch(ctx context.Context, request mcp.CallToolR
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling semantic search result: %v", err)
+		s.logger.Error("failed to marshal semantic search result", "error", err)
 		return errorResult(fmt.Sprintf("Failed to format search results: %v", err))
 	}
 	return &mcp.CallToolResult{
@@ -988,7 +1186,7 @@ func (s *Server) handleTransitiveDeps(ctx context.Context, request mcp.CallToolR
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling transitive dependencies result: %v", err)
+		s.logger.Error("failed to marshal transitive dependencies result", "error", err)
 		return errorResult(fmt.Sprintf("Failed to format dependencies: %v", err))
 	}
 	return &mcp.CallToolResult{
@@ -1001,6 +1199,147 @@ func (s *Server) handleTransitiveDeps(ctx context.Context, request mcp.CallToolR
 	}, nil
 }
 
+func (s *Server) handleModules(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	if args == nil {
+		return errorResult("arguments must be an object")
+	}
+
+	granularity := graph.GranularityFile
+	if g, ok := args["granularity"].(string); ok && g != "" {
+		granularity = g
+	}
+	path, _ := args["path"].(string)
+	includeExternal := true
+	if b, ok := args["include_external"].(bool); ok {
+		includeExternal = b
+	}
+
+	// Check if indexer is initialized
+	if s.indexer == nil || s.storage == nil {
+		return errorResult("Code graph not initialized. Run codeloom_index first to index your codebase.")
+	}
+
+	imports, contains, err := s.storage.GetModuleEdges(ctx)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to get module graph: %v", err))
+	}
+	moduleGraph, err := buildModuleGraph(imports, contains, granularity, path, includeExternal)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	jsonBytes, err := json.Marshal(moduleGraph)
+	if err != nil {
+		s.logger.Error("failed to marshal module graph", "error", err)
+		return errorResult(fmt.Sprintf("Failed to format module graph: %v", err))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonBytes),
+			},
+		},
+	}, nil
+}
+
+// buildModuleGraph aggregates the imports made by files under path, leaving
+// out external dependencies unless includeExternal is set
+func buildModuleGraph(imports, contains []graph.CodeEdge, granularity, path string, includeExternal bool) (*graph.ModuleGraph, error) {
+	if path != "" {
+		// Indexed file paths are absolute
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	filtered := make([]graph.CodeEdge, 0, len(imports))
+	for _, e := range imports {
+		if path != "" && e.FromID != path && !strings.HasPrefix(e.FromID, path+string(filepath.Separator)) {
+			continue
+		}
+		if !includeExternal && strings.HasPrefix(e.ToID, graph.ExternalModulePrefix) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return graph.BuildModuleGraph(filtered, contains, granularity)
+}
+
+// fileDiagnostics are the syntax errors of a file
+type fileDiagnostics struct {
+	File       string              `json:"file"`
+	Language   string              `json:"language,omitempty"`
+	ErrorRatio float64             `json:"error_ratio"`
+	Stale      bool                `json:"stale"`
+	Errors     []graph.SyntaxError `json:"errors"`
+}
+
+func (s *Server) handleDiagnostics(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.GetArguments()
+	if args == nil {
+		return errorResult("arguments must be an object")
+	}
+	path, _ := args["path"].(string)
+	if path != "" {
+		// Indexed file paths are absolute
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	// Check if indexer is initialized
+	if s.indexer == nil || s.storage == nil {
+		return errorResult("Code graph not initialized. Run codeloom_index first to index your codebase.")
+	}
+
+	metas, err := s.storage.GetFilesWithSyntaxErrors(ctx, path)
+	if err != nil {
+		return errorResult(fmt.Sprintf("Failed to get diagnostics: %v", err))
+	}
+	files := buildDiagnostics(metas, path)
+
+	jsonBytes, err := json.Marshal(map[string]interface{}{
+		"files":     files,
+		"count":     len(files),
+		"threshold": s.config.Server.ParseErrorThreshold,
+	})
+	if err != nil {
+		s.logger.Error("failed to marshal diagnostics", "error", err)
+		return errorResult(fmt.Sprintf("Failed to format diagnostics: %v", err))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonBytes),
+			},
+		},
+	}, nil
+}
+
+// buildDiagnostics lists the files with syntax errors that are path or
+// under it, all of them when path is empty
+func buildDiagnostics(metas []graph.FileMetadata, path string) []fileDiagnostics {
+	files := make([]fileDiagnostics, 0, len(metas))
+	for _, m := range metas {
+		if len(m.SyntaxErrors) == 0 {
+			continue
+		}
+		if path != "" && m.FilePath != path && !strings.HasPrefix(m.FilePath, path+string(filepath.Separator)) {
+			continue
+		}
+		files = append(files, fileDiagnostics{
+			File:       m.FilePath,
+			Language:   m.Language,
+			ErrorRatio: m.ErrorRatio,
+			Stale:      m.Stale,
+			Errors:     m.SyntaxErrors,
+		})
+	}
+	return files
+}
+
 func (s *Server) handleTraceCallChain(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := request.GetArguments()
 	if args == nil {
@@ -1049,7 +1388,7 @@ func (s *Server) handleTraceCallChain(ctx context.Context, request mcp.CallToolR
 
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling call chain result: %v", err)
+		s.logger.Error("failed to marshal call chain result", "error", err)
 		return errorResult(fmt.Sprintf("Failed to format call chain: %v", err))
 	}
 	return &mcp.CallToolResult{
@@ -1062,6 +1401,108 @@ func (s *Server) handleTraceCallChain(ctx context.Context, request mcp.CallToolR
 	}, nil
 }
 
+// startWatcher replaces any running watcher with one following dirs. The
+// indexer must already be initialized.
+func (s *Server) startWatcher(ctx context.Context, dirs []string) error {
+	// Stop existing watcher if running and wait for goroutine to finish
+	s.mu.Lock()
+	if s.watcher != nil {
+		s.watcher.Stop()
+		if s.watchStop != nil {
+			s.watchStop()
+		}
+	}
+	// Wait for any existing watcher goroutine to finish before starting new one
+	s.mu.Unlock()
+	s.watchWg.Wait()
+
+	// Create new watcher
+	watcher, err := daemon.NewWatcher(daemon.WatcherConfig{
+		Parser:          parser.NewParser(parser.WithTreeCache(parser.NewTreeCache(parser.DefaultTreeCacheSize)), parser.WithCompileCommands(s.config.Server.CompileCommands)),
+		Storage:         s.storage,
+		Embedding:       s.embedding,
+		Documents:       s.documents,
+		ExcludePatterns: indexer.DefaultExcludePatterns(),
+		DebounceMs:      s.config.Server.WatcherDebounceMs,
+		IndexTimeoutMs:  s.config.Server.IndexTimeoutMs,
+		Logger:          s.rootLog,
+		ErrorThreshold:  s.config.Server.ParseErrorThreshold,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	// Create context for watcher, derived from parent context
+	watchCtx, watchStop := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.watcher = watcher
+	s.watchCtx = watchCtx
+	s.watchStop = watchStop
+	s.watchDirs = dirs
+	s.mu.Unlock()
+	if len(dirs) > 0 {
+		s.setProjectRoot(dirs[0])
+	}
+
+	// Start watching in background and track with WaitGroup
+	s.watchWg.Add(1)
+	go func() {
+		defer s.watchWg.Done()
+		if err := watcher.Watch(watchCtx, dirs); err != nil {
+			if err != context.Canceled {
+				s.logger.Error("watcher stopped", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// saveWatchedDirs persists the watched directories. Failure only costs the
+// ability to resume watching after a restart, so it is logged, not returned.
+func (s *Server) saveWatchedDirs(ctx context.Context, dirs []string) {
+	s.mu.RLock()
+	storage := s.storage
+	s.mu.RUnlock()
+	if storage == nil {
+		return
+	}
+	if err := storage.SetWatchedDirs(ctx, dirs); err != nil {
+		s.logger.Warn("failed to persist watched directories", "error", err)
+	}
+}
+
+// ResumeWatch restarts watching the directories persisted by the last
+// codeloom_watch start. Directories that no longer exist are skipped. It
+// returns the directories now being watched.
+func (s *Server) ResumeWatch(ctx context.Context) ([]string, error) {
+	if err := s.initializeIndexer(); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.storage.GetWatchedDirs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watched directories: %w", err)
+	}
+
+	var dirs []string
+	for _, dir := range saved {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			s.logger.Warn("skipping missing watched directory", "dir", dir)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	if err := s.startWatcher(ctx, dirs); err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
 func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := request.GetArguments()
 	if args == nil {
@@ -1087,57 +1528,22 @@ func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (
 		if len(dirs) == 0 {
 			return errorResult("directories are required for 'start' action")
 		}
+		// Store absolute paths so a restart from another directory resumes them
+		for i, dir := range dirs {
+			if absDir, err := filepath.Abs(dir); err == nil {
+				dirs[i] = absDir
+			}
+		}
 
 		// Initialize indexer/storage if needed
 		if err := s.initializeIndexer(); err != nil {
 			return errorResult(fmt.Sprintf("failed to initialize indexer: %v", err))
 		}
 
-		// Stop existing watcher if running and wait for goroutine to finish
-		s.mu.Lock()
-		if s.watcher != nil {
-			s.watcher.Stop()
-			if s.watchStop != nil {
-				s.watchStop()
-			}
-		}
-		// Wait for any existing watcher goroutine to finish before starting new one
-		s.mu.Unlock()
-		s.watchWg.Wait()
-
-		// Create new watcher
-		watcher, err := daemon.NewWatcher(daemon.WatcherConfig{
-			Parser:          parser.NewParser(),
-			Storage:         s.storage,
-			Embedding:       s.embedding,
-			ExcludePatterns: indexer.DefaultExcludePatterns(),
-			DebounceMs:      s.config.Server.WatcherDebounceMs,
-			IndexTimeoutMs:  s.config.Server.IndexTimeoutMs,
-		})
-		if err != nil {
-			return errorResult(fmt.Sprintf("failed to create watcher: %v", err))
+		if err := s.startWatcher(ctx, dirs); err != nil {
+			return errorResult(err.Error())
 		}
-
-		// Create context for watcher, derived from parent context
-		watchCtx, watchStop := context.WithCancel(ctx)
-
-		s.mu.Lock()
-		s.watcher = watcher
-		s.watchCtx = watchCtx
-		s.watchStop = watchStop
-		s.watchDirs = dirs
-		s.mu.Unlock()
-
-		// Start watching in background and track with WaitGroup
-		s.watchWg.Add(1)
-		go func() {
-			defer s.watchWg.Done()
-			if err := watcher.Watch(watchCtx, dirs); err != nil {
-				if err != context.Canceled {
-					log.Printf("Watcher error: %v", err)
-				}
-			}
-		}()
+		s.saveWatchedDirs(ctx, dirs)
 
 		result := map[string]interface{}{
 			"status":      "started",
@@ -1146,7 +1552,7 @@ func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (
 		}
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			log.Printf("Error marshaling watch start result: %v", err)
+			s.logger.Error("failed to marshal watch start result", "error", err)
 			return errorResult(fmt.Sprintf("Failed to format watch status: %v", err))
 		}
 		return &mcp.CallToolResult{
@@ -1179,6 +1585,9 @@ func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (
 		// Wait for watcher goroutine to finish before returning
 		s.watchWg.Wait()
 
+		// Forget the directories so a restart with --watch does not resume them
+		s.saveWatchedDirs(ctx, nil)
+
 		result := map[string]interface{}{
 			"status":              "stopped",
 			"previously_watching": watchedDirs,
@@ -1186,7 +1595,7 @@ func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (
 		}
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			log.Printf("Error marshaling watch stop result: %v", err)
+			s.logger.Error("failed to marshal watch stop result", "error", err)
 			return errorResult(fmt.Sprintf("Failed to format watch status: %v", err))
 		}
 		return &mcp.CallToolResult{
@@ -1216,7 +1625,7 @@ func (s *Server) handleWatch(ctx context.Context, request mcp.CallToolRequest) (
 
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			log.Printf("Error marshaling watch status result: %v", err)
+			s.logger.Error("failed to marshal watch status result", "error", err)
 			return errorResult(fmt.Sprintf("Failed to format watch status: %v", err))
 		}
 		return &mcp.CallToolResult{
@@ -1250,9 +1659,151 @@ func parseAgenticRequest(args map[string]interface{}) AgenticRequest {
 	if f, ok := args["focus"].(string); ok {
 		req.Focus = f
 	}
+	if nc, ok := args["no_cache"].(bool); ok {
+		req.NoCache = nc
+	}
 	return req
 }
 
+// runAgentic answers an agentic tool call: it gathers the context the named
+// prompt template uses, renders the template and asks the LLM
+func (s *Server) runAgentic(ctx context.Context, request mcp.CallToolRequest, tool, name string) (*mcp.CallToolResult, error) {
+	if s.llm == nil {
+		return errorResult("LLM provider not configured. Set CODELOOM_LLM_PROVIDER and required API keys.")
+	}
+
+	args := request.GetArguments()
+	if args == nil {
+		return errorResult("arguments must be an object")
+	}
+	req := parseAgenticRequest(args)
+
+	// Use a fresh context with timeout, derived from parent context
+	ctx, cancel := context.WithTimeout(ctx, 120*time.Second)
+	defer cancel()
+
+	tmpl, err := s.prompts.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	data := s.gatherPromptData(ctx, name, req)
+	prompt, err := tmpl.Execute(data)
+	if err != nil {
+		return errorResult(err.Error())
+	}
+
+	// The template ID is part of the key, so editing a prompt retires the
+	// answers it produced
+	return s.generateAgentic(ctx, tool, req, prompt,
+		tmpl.ID(), data.CodeContext, data.DependencyContext, data.StructureContext, data.MetricsContext)
+}
+
+// gatherPromptData collects the context the named prompt template uses
+func (s *Server) gatherPromptData(ctx context.Context, name string, req AgenticRequest) prompts.Data {
+	data := prompts.Data{Query: req.Query, Focus: req.Focus}
+	switch name {
+	case prompts.Context:
+		data.CodeContext = s.gatherCodeContext(ctx, req.Query, req.Limit)
+	case prompts.Impact:
+		data.CodeContext = s.gatherCodeContext(ctx, req.Query, req.Limit)
+		data.DependencyContext = s.gatherDependencyContext(ctx, req.Query)
+	case prompts.Architecture:
+		data.StructureContext = s.gatherStructureContext(ctx, req.Query)
+		data.CodeContext = s.gatherCodeContext(ctx, req.Query, req.Limit)
+	case prompts.Quality:
+		// More samples for quality analysis
+		data.CodeContext = s.gatherCodeContext(ctx, req.Query, req.Limit*2)
+		data.MetricsContext = s.gatherMetricsContext(ctx)
+	}
+	return data
+}
+
+// generateAgentic runs prompt through the LLM, or answers from the response
+// cache when the same question was asked against the same graph version and
+// code context. contexts identify everything else the prompt was built from.
+// Cache use is reported under "cache" in the result's _meta.
+func (s *Server) generateAgentic(ctx context.Context, tool string, req AgenticRequest, prompt string, contexts ...string) (*mcp.CallToolResult, error) {
+	var key, identity string
+	version, ok := s.graphVersion(ctx)
+	useCache := s.cache != nil && ok
+	if useCache {
+		identity = s.llmIdentity(ctx)
+		key = agenticCacheKey(tool, req, identity, version, contexts...)
+		if !req.NoCache {
+			if hit, found := s.cache.get(key); found {
+				return agenticResult(hit.text, map[string]any{
+					"hit":           true,
+					"age_seconds":   int(s.cache.now().Sub(hit.created).Seconds()),
+					"graph_version": version,
+				}), nil
+			}
+		}
+	}
+
+	messages := []llm.Message{
+		{Role: llm.RoleUser, Content: prompt},
+	}
+
+	ctx, answeredBy := llm.WithAnswerer(ctx)
+	result, err := s.llm.Generate(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if !useCache {
+		return agenticResult(result, nil), nil
+	}
+	// A fallback's answer is kept under its own model, not the one asked
+	if answered := answeredBy(); answered != "" && answered != identity {
+		key = agenticCacheKey(tool, req, answered, version, contexts...)
+	}
+	s.cache.put(key, result, version)
+	return agenticResult(result, map[string]any{"hit": false, "graph_version": version}), nil
+}
+
+func agenticResult(text string, cache map[string]any) *mcp.CallToolResult {
+	result := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+	if cache != nil {
+		result.Meta = mcp.NewMetaFromMap(map[string]any{"cache": cache})
+	}
+	return result
+}
+
+// graphVersion returns the indexed graph's version. Without storage the
+// graph cannot change, so the version is 0; ok is false when the version
+// cannot be read and cached answers must not be trusted.
+func (s *Server) graphVersion(ctx context.Context) (int64, bool) {
+	if s.storage == nil {
+		return 0, true
+	}
+	version, err := s.storage.GraphVersion(ctx)
+	if err != nil {
+		s.logger.Warn("failed to read graph version, bypassing response cache", "error", err)
+		return 0, false
+	}
+	return version, true
+}
+
+// llmIdentity names the model expected to answer a request made with ctx,
+// so answers from one model are not served after switching to another. In a
+// chain it is the first provider whose circuit is not open.
+func (s *Server) llmIdentity(ctx context.Context) string {
+	if name := llm.Answerer(ctx, s.llm); name != "" {
+		return name
+	}
+	if s.config == nil {
+		return s.llm.Name()
+	}
+	return s.config.LLM.Provider + "/" + s.config.LLM.Model
+}
+
 func errorResult(msg string) (*mcp.CallToolResult, error) {
 	result := map[string]interface{}{
 		"error":   true,
@@ -1260,7 +1811,7 @@ func errorResult(msg string) (*mcp.CallToolResult, error) {
 	}
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
-		log.Printf("Error marshaling error result: %v", err)
+		slog.Error("failed to marshal error result", "error", err)
 		// Fallback to plain text if JSON marshaling fails
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -1308,7 +1859,7 @@ func (s *Server) gatherCodeContext(ctx context.Context, query string, limit int)
 	// Try semantic search first if embeddings are available
 	if s.embedding != nil {
 		// Generate embedding for query
-		queryEmb, err := s.embedding.EmbedSingle(ctx, query)
+		queryEmb, err := s.embedding.EmbedQuery(ctx, query)
 		if err != nil {
 			// Fall back to name-based search if embedding fails
 			return s.gatherCodeContextByName(ctx, query, limit)
@@ -1345,7 +1896,7 @@ func (s *Server) gatherDependencyContext(ctx context.Context, query string) stri
 
 	if s.embedding != nil {
 		// Try semantic search first
-		queryEmb, embedErr := s.embedding.EmbedSingle(ctx, query)
+		queryEmb, embedErr := s.embedding.EmbedQuery(ctx, query)
 		if embedErr == nil {
 			nodes, err = s.storage.SemanticSearch(ctx, queryEmb, 3)
 		}
@@ -1359,7 +1910,7 @@ func (s *Server) gatherDependencyContext(ctx context.Context, query string) stri
 			if nameErr != nil {
 				// Log error but continue trying other names
 				// This allows partial results instead of failing completely
-				log.Printf("Warning: failed to search for name '%s' in dependency context: %v", name, nameErr)
+				s.logger.Warn("failed to search for name in dependency context", "name", name, "error", nameErr)
 				continue
 			}
 			nodes = append(nodes, nameNodes...)
@@ -1387,17 +1938,37 @@ func (s *Server) gatherDependencyContext(ctx context.Context, query string) stri
 		if err == nil && len(deps) > 0 {
 			sb.WriteString(fmt.Sprintf("**%s** depends on:\n", node.Name))
 			for _, dep := range deps {
-				sb.WriteString(fmt.Sprintf("  - %s (%s) at %s:%d\n", dep.Name, dep.NodeType, dep.FilePath, dep.StartLine))
+				writeDependencyLine(&sb, dep)
 			}
 			sb.WriteString("\n")
 		}
 
 		// Get callers (what calls this node)
 		callers, err := s.storage.GetCallers(ctx, node.ID)
+		listed := map[string]bool{node.ID: true}
 		if err == nil && len(callers) > 0 {
 			sb.WriteString(fmt.Sprintf("**%s** is called by:\n", node.Name))
 			for _, caller := range callers {
-				sb.WriteString(fmt.Sprintf("  - %s (%s) at %s:%d\n", caller.Name, caller.NodeType, caller.FilePath, caller.StartLine))
+				listed[caller.ID] = true
+				writeDependencyLine(&sb, caller)
+			}
+			sb.WriteString("\n")
+		}
+
+		// Get the rest of what depends on this node, which reaches across
+		// languages through the rpcs, tables and operations it implements or
+		// uses
+		dependents, err := s.storage.GetTransitiveDependents(ctx, node.ID, 3)
+		var others []graph.CodeNode
+		for _, dep := range dependents {
+			if !listed[dep.ID] {
+				others = append(others, dep)
+			}
+		}
+		if err == nil && len(others) > 0 {
+			sb.WriteString(fmt.Sprintf("**%s** is depended on by:\n", node.Name))
+			for _, dep := range others {
+				writeDependencyLine(&sb, dep)
 			}
 			sb.WriteString("\n")
 		}
@@ -1409,6 +1980,16 @@ func (s *Server) gatherDependencyContext(ctx context.Context, query string) stri
 	return sb.String()
 }
 
+// writeDependencyLine lists a node in a dependency analysis. Shared nodes,
+// such as packages and contracts, have no location.
+func writeDependencyLine(sb *strings.Builder, n graph.CodeNode) {
+	if n.FilePath == "" {
+		sb.WriteString(fmt.Sprintf("  - %s (%s)\n", n.Name, n.NodeType))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("  - %s (%s) at %s:%d\n", n.Name, n.NodeType, n.FilePath, n.StartLine))
+}
+
 // gatherStructureContext provides an overview of the codebase structure
 func (s *Server) gatherStructureContext(ctx context.Context, query string) string {
 	if s.storage == nil {
@@ -1515,14 +2096,24 @@ func (s *Server) gatherMetricsContext(ctx context.Context) string {
 // SERVER METHODS
 // ==========================================================================
 
+// ServeStdio serves MCP over stdin/stdout. While it runs, os.Stdout points at
+// stderr so that stray writes from any package cannot corrupt the protocol
+// stream; only the MCP server holds the real stdout.
 func (s *Server) ServeStdio(ctx context.Context) error {
-	log.Println("Starting MCP server on stdio...")
-	return server.ServeStdio(s.mcp)
+	s.logger.Info("starting MCP server", "transport", "stdio")
+
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = protocolOut }()
+
+	stdio := server.NewStdioServer(s.mcp)
+	stdio.SetErrorLogger(slog.NewLogLogger(s.logger.Handler(), slog.LevelError))
+	return stdio.Listen(ctx, os.Stdin, protocolOut)
 }
 
 func (s *Server) ServeSSE(ctx context.Context, port int) error {
 	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Starting MCP server (SSE) on http://localhost%s\n", addr)
+	s.logger.Info("starting MCP server", "transport", "sse", "url", "http://localhost"+addr)
 
 	mux := http.NewServeMux()
 	srv := &http.Server{
@@ -1536,10 +2127,10 @@ func (s *Server) ServeSSE(ctx context.Context, port int) error {
 		server.WithHTTPServer(srv),
 	)
 
-	mux.Handle("/sse", wrapHTTPHandler(sseHandler.SSEHandler(), "sse"))
-	mux.Handle("/message", wrapHTTPHandler(sseHandler.MessageHandler(), "sse"))
-	mux.Handle("/health", wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "sse"))
-	mux.Handle("/ready", wrapHTTPHandler(http.HandlerFunc(s.handleReady), "sse"))
+	mux.Handle("/sse", s.wrapHTTPHandler(sseHandler.SSEHandler(), "sse"))
+	mux.Handle("/message", s.wrapHTTPHandler(sseHandler.MessageHandler(), "sse"))
+	mux.Handle("/health", s.wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "sse"))
+	mux.Handle("/ready", s.wrapHTTPHandler(http.HandlerFunc(s.handleReady), "sse"))
 
 	srv.Handler = mux
 
@@ -1564,7 +2155,7 @@ func (s *Server) ServeStreamableHTTP(ctx context.Context, port int, endpointPath
 	addr := fmt.Sprintf(":%d", port)
 	path := normalizeHTTPPath(endpointPath)
 
-	log.Printf("Starting MCP server (Streamable HTTP) on http://localhost%s%s\n", addr, path)
+	s.logger.Info("starting MCP server", "transport", "streamable-http", "url", "http://localhost"+addr+path)
 
 	mux := http.NewServeMux()
 	srv := &http.Server{
@@ -1577,9 +2168,9 @@ func (s *Server) ServeStreamableHTTP(ctx context.Context, port int, endpointPath
 		server.WithStreamableHTTPServer(srv),
 	)
 
-	mux.Handle(path, wrapHTTPHandler(httpServer, "streamable-http"))
-	mux.Handle("/health", wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "streamable-http"))
-	mux.Handle("/ready", wrapHTTPHandler(http.HandlerFunc(s.handleReady), "streamable-http"))
+	mux.Handle(path, s.wrapHTTPHandler(httpServer, "streamable-http"))
+	mux.Handle("/health", s.wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "streamable-http"))
+	mux.Handle("/ready", s.wrapHTTPHandler(http.HandlerFunc(s.handleReady), "streamable-http"))
 
 	srv.Handler = mux
 
@@ -1599,8 +2190,8 @@ func (s *Server) ServeHTTPMulti(ctx context.Context, port int, endpointPath stri
 	addr := fmt.Sprintf(":%d", port)
 	path := normalizeHTTPPath(endpointPath)
 
-	log.Printf("Starting MCP server (SSE + Streamable HTTP) on http://localhost%s\n", addr)
-	log.Printf("SSE endpoints: /sse (GET), /message (POST); Streamable HTTP endpoint: %s\n", path)
+	s.logger.Info("starting MCP server", "transport", "both", "url", "http://localhost"+addr,
+		"sse_endpoints", "/sse (GET), /message (POST)", "streamable_http_endpoint", path)
 
 	mux := http.NewServeMux()
 	srv := &http.Server{
@@ -1619,21 +2210,18 @@ func (s *Server) ServeHTTPMulti(ctx context.Context, port int, endpointPath stri
 		server.WithStreamableHTTPServer(srv),
 	)
 
-	mux.Handle("/sse", wrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/sse", s.wrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if shouldServeSSE(r) {
 			sseHandler.SSEHandler().ServeHTTP(w, r)
 			return
 		}
-		logJSON("warn", "sse_compat_streamable", map[string]interface{}{
-			"method": r.Method,
-			"path":   r.URL.Path,
-		})
+		s.logger.Warn("sse_compat_streamable", "transport", "sse", "method", r.Method, "path", r.URL.Path)
 		streamable.ServeHTTP(w, r)
 	}), "sse"))
-	mux.Handle("/message", wrapHTTPHandler(sseHandler.MessageHandler(), "sse"))
-	mux.Handle(path, wrapHTTPHandler(streamable, "streamable-http"))
-	mux.Handle("/health", wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "multi"))
-	mux.Handle("/ready", wrapHTTPHandler(http.HandlerFunc(s.handleReady), "multi"))
+	mux.Handle("/message", s.wrapHTTPHandler(sseHandler.MessageHandler(), "sse"))
+	mux.Handle(path, s.wrapHTTPHandler(streamable, "streamable-http"))
+	mux.Handle("/health", s.wrapHTTPHandler(http.HandlerFunc(s.handleHealth), "multi"))
+	mux.Handle("/ready", s.wrapHTTPHandler(http.HandlerFunc(s.handleReady), "multi"))
 
 	srv.Handler = mux
 
@@ -1690,8 +2278,54 @@ func (sr *statusRecorder) Push(target string, opts *http.PushOptions) error {
 	return http.ErrNotSupported
 }
 
-func wrapHTTPHandler(next http.Handler, component string) http.Handler {
-	return withCORS(withRequestLogging(next, component))
+func (s *Server) wrapHTTPHandler(next http.Handler, component string) http.Handler {
+	return withCORS(withTracing(withRequestLogging(next, component, s.logger), component))
+}
+
+// withTracing continues any trace propagated in the request headers and
+// wraps the request in a server span, so tool spans become its children.
+func withTracing(next http.Handler, component string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(telemetry.ScopeName).Start(ctx, "HTTP "+r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("codeloom.transport", component),
+			),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+// withToolName records the tool name in the context so LLM routes can match it.
+func withToolName(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return next(llm.WithToolName(ctx, request.Params.Name), request)
+	}
+}
+
+// withToolTracing records a span for every MCP tool call.
+func withToolTracing(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, span := telemetry.Start(ctx, "mcp.tool "+request.Params.Name,
+			attribute.String("mcp.tool.name", request.Params.Name),
+		)
+		result, err := next(ctx, request)
+		if err == nil && result != nil && result.IsError {
+			span.SetStatus(codes.Error, "tool returned error result")
+		}
+		telemetry.End(span, err)
+		return result, err
+	}
 }
 
 func withCORS(next http.Handler) http.Handler {
@@ -1707,18 +2341,18 @@ func withCORS(next http.Handler) http.Handler {
 	})
 }
 
-func withRequestLogging(next http.Handler, component string) http.Handler {
+func withRequestLogging(next http.Handler, component string, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 		next.ServeHTTP(recorder, r)
-		logJSON("info", "http_request", map[string]interface{}{
-			"component":   component,
-			"method":      r.Method,
-			"path":        r.URL.Path,
-			"status":      recorder.status,
-			"duration_ms": time.Since(start).Milliseconds(),
-		})
+		logger.Info("http_request",
+			"transport", component,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
 	})
 }
 
@@ -1735,13 +2369,26 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	embeddingReady := s.embedding != nil
 	s.mu.RUnlock()
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
+	payload := map[string]interface{}{
 		"status":              "ok",
 		"ready":               true,
 		"indexer_initialized": indexerReady,
 		"storage_initialized": storageReady,
 		"embedding_available": embeddingReady,
-	})
+		"llm_available":       false,
+	}
+	if s.llm != nil {
+		health := llm.Health(s.llm)
+		available := false
+		for _, h := range health {
+			if h.State != llm.BreakerOpen {
+				available = true
+			}
+		}
+		payload["llm_available"] = available
+		payload["llm_providers"] = health
+	}
+	writeJSON(w, http.StatusOK, payload)
 }
 
 func writeJSON(w http.ResponseWriter, status int, payload map[string]interface{}) {
@@ -1750,7 +2397,7 @@ func writeJSON(w http.ResponseWriter, status int, payload map[string]interface{}
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
 	if err := enc.Encode(payload); err != nil {
-		log.Printf("Failed to write JSON response: %v", err)
+		slog.Error("failed to write JSON response", "error", err)
 	}
 }
 
@@ -1764,23 +2411,6 @@ func normalizeHTTPPath(path string) string {
 	return path
 }
 
-func logJSON(level, msg string, fields map[string]interface{}) {
-	payload := map[string]interface{}{
-		"level": level,
-		"msg":   msg,
-		"ts":    time.Now().UTC().Format(time.RFC3339Nano),
-	}
-	for k, v := range fields {
-		payload[k] = v
-	}
-	data, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("Failed to marshal log payload: %v", err)
-		return
-	}
-	fmt.Fprintln(os.Stdout, string(data))
-}
-
 // Close cleans up resources
 func (s *Server) Close() error {
 	var errs []error
@@ -1800,6 +2430,14 @@ func (s *Server) Close() error {
 	// Wait for watcher goroutine to finish before proceeding with cleanup
 	s.watchWg.Wait()
 
+	// Stop background jobs; a running job stays resumable
+	s.mu.RLock()
+	manager := s.jobs
+	s.mu.RUnlock()
+	if manager != nil {
+		manager.Close()
+	}
+
 	// Close LLM provider
 	if s.llm != nil {
 		if err := s.llm.Close(); err != nil {
@@ -1833,7 +2471,7 @@ func (s *Server) gatherCodeContextByName(ctx context.Context, query string, limi
 		if err != nil {
 			// Log error but continue trying other names
 			// This allows partial results instead of failing completely
-			log.Printf("Warning: failed to search for name '%s': %v", name, err)
+			s.logger.Warn("failed to search for name", "name", name, "error", err)
 			continue
 		}
 		allNodes = append(allNodes, nodes...)
@@ -165,9 +165,9 @@ func TestGatherDependencyContextErrorHandling(t *testing.T) {
 	sourceCode := string(sourceBytes)
 
 	// Check that gatherDependencyContext contains error logging for FindByName
-	// Looking for pattern: "log.Printf(\"Warning: failed to search for name"
+	// Looking for pattern: "s.logger.Warn(\"failed to search for name"
 	if strings.Contains(sourceCode, "gatherDependencyContext") &&
-		strings.Contains(sourceCode, "log.Printf(\"Warning: failed to search for name") {
+		strings.Contains(sourceCode, "s.logger.Warn(\"failed to search for name") {
 		t.Log("✓ Error logging is present in gatherDependencyContext function")
 	} else {
 		t.Error("Expected error logging in gatherDependencyContext, but pattern not found in source code")
@@ -175,7 +175,7 @@ func TestGatherDependencyContextErrorHandling(t *testing.T) {
 
 	// Verify consistency with gatherCodeContextByName
 	if strings.Contains(sourceCode, "gatherCodeContextByName") &&
-		strings.Contains(sourceCode, "log.Printf(\"Warning: failed to search for name") {
+		strings.Contains(sourceCode, "s.logger.Warn(\"failed to search for name") {
 		t.Log("✓ Error logging is consistent between gatherDependencyContext and gatherCodeContextByName")
 	} else {
 		t.Error("Expected error logging to be consistent across both functions")
@@ -363,9 +363,20 @@ func TestSkipEmbeddingsParameter(t *testing.T) {
 		t.Log("✓ skip_embeddings parameter is read from request arguments")
 	}
 
+	// Indexing runs as a background job; the per-job indexer is built in jobIndexer
+	jobIndexerStart := strings.Index(sourceCode, "func (s *Server) jobIndexer")
+	if jobIndexerStart == -1 {
+		t.Fatal("Could not find jobIndexer function")
+	}
+	jobIndexerEnd := len(sourceCode)
+	if next := strings.Index(sourceCode[jobIndexerStart+1:], "\nfunc "); next != -1 {
+		jobIndexerEnd = jobIndexerStart + 1 + next
+	}
+	jobIndexerCode := sourceCode[jobIndexerStart:jobIndexerEnd]
+
 	// Verify that embedProvider is conditionally set to nil
 	embProviderPattern := `embProvider := s.embedding`
-	if !strings.Contains(handleIndexCode, embProviderPattern) {
+	if !strings.Contains(jobIndexerCode, embProviderPattern) {
 		t.Error("Expected to find conditional embedding provider assignment")
 	} else {
 		t.Log("✓ Conditional embedding provider assignment is present")
@@ -373,7 +384,7 @@ func TestSkipEmbeddingsParameter(t *testing.T) {
 
 	// Verify that embedProvider is set to nil when skipEmbeddings is true
 	nilEmbedPattern := `embProvider = nil`
-	if !strings.Contains(handleIndexCode, nilEmbedPattern) {
+	if !strings.Contains(jobIndexerCode, nilEmbedPattern) {
 		t.Error("Expected to find nil assignment for embedding provider when skip_embeddings is true")
 	} else {
 		t.Log("✓ nil assignment for embedding provider is present")
@@ -381,10 +392,10 @@ func TestSkipEmbeddingsParameter(t *testing.T) {
 
 	// Verify that embProvider is used in indexer.New
 	indexerNewPattern := `Embedding:       embProvider`
-	if !strings.Contains(handleIndexCode, indexerNewPattern) {
+	if !strings.Contains(jobIndexerCode, indexerNewPattern) {
 		// Try alternate spacing pattern
 		altPattern := `Embedding: embProvider`
-		if !strings.Contains(handleIndexCode, altPattern) {
+		if !strings.Contains(jobIndexerCode, altPattern) {
 			t.Error("Expected to find embProvider used in indexer.New")
 		} else {
 			t.Log("✓ embProvider is used in indexer.New configuration")
@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/logging"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHTTPTraceContextPropagatesToToolSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	tool := withToolTracing(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return errorResult("not indexed")
+	})
+
+	s := NewServer(ServerConfig{Config: config.DefaultConfig(), Logger: logging.Discard()})
+	handler := s.wrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "codeloom_search"
+		if _, err := tool(r.Context(), req); err != nil {
+			t.Errorf("tool returned error: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}), "streamable-http")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans (http + tool), got %d", len(spans))
+	}
+
+	var httpSpan, toolSpan tracetest.SpanStub
+	for _, s := range spans {
+		switch s.Name {
+		case "HTTP POST /mcp":
+			httpSpan = s
+		case "mcp.tool codeloom_search":
+			toolSpan = s
+		}
+	}
+	if httpSpan.Name == "" || toolSpan.Name == "" {
+		t.Fatalf("unexpected span names: %v, %v", spans[0].Name, spans[1].Name)
+	}
+	if got := httpSpan.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("http span trace ID = %s, want %s", got, traceID)
+	}
+	if toolSpan.Parent.SpanID() != httpSpan.SpanContext.SpanID() {
+		t.Errorf("tool span is not a child of the http span")
+	}
+	if toolSpan.Status.Description == "" {
+		t.Errorf("expected tool span to record the error result")
+	}
+}
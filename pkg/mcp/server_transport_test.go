@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/heefoo/codeloom/internal/config"
+	"github.com/heefoo/codeloom/internal/llm"
 	"github.com/mark3labs/mcp-go/server"
 )
 
@@ -37,6 +38,27 @@ func TestHealthReadyHandlers(t *testing.T) {
 	if payload["status"] != "ok" {
 		t.Fatalf("expected ready status 'ok', got %v", payload["status"])
 	}
+	if payload["llm_available"] != false {
+		t.Fatalf("expected llm_available false without an LLM provider, got %v", payload["llm_available"])
+	}
+
+	llmCfg := config.DefaultConfig().LLM
+	llmCfg.Providers = []config.LLMProviderConfig{{Provider: "ollama"}, {Provider: "openai"}}
+	provider, err := llm.NewProvider(llmCfg)
+	if err != nil {
+		t.Fatalf("failed to create LLM chain: %v", err)
+	}
+	s = NewServer(ServerConfig{LLM: provider, Config: config.DefaultConfig()})
+	readyRec = httptest.NewRecorder()
+	s.handleReady(readyRec, readyReq)
+	payload = nil
+	if err := json.Unmarshal(readyRec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("failed to parse ready response: %v", err)
+	}
+	providers, _ := payload["llm_providers"].([]interface{})
+	if payload["llm_available"] != true || len(providers) != 2 {
+		t.Fatalf("expected LLM chain health in ready response, got %v", payload)
+	}
 }
 
 func TestSSEEndpoint(t *testing.T) {
@@ -24,6 +24,10 @@ func (m *MockProvider) Name() string {
 	return "mock"
 }
 
+func (m *MockProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return m.EmbedSingle(ctx, text)
+}
+
 func (m *MockProvider) Dimension() int {
 	return 128
 }